@@ -0,0 +1,163 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/index"
+)
+
+// aliasIndexReader reads the fields alias fields name in
+// place of the aliases
+type aliasIndexReader struct {
+	index.IndexReader
+	aliases map[string]string
+}
+
+func newAliasIndexReader(indexReader index.IndexReader, aliases map[string]string) *aliasIndexReader {
+	return &aliasIndexReader{
+		IndexReader: indexReader,
+		aliases:     aliases,
+	}
+}
+
+func (r *aliasIndexReader) field(field string) string {
+	if target, ok := r.aliases[field]; ok {
+		return target
+	}
+	return field
+}
+
+func (r *aliasIndexReader) TermFieldReader(term []byte, field string) (index.TermFieldReader, error) {
+	return r.IndexReader.TermFieldReader(term, r.field(field))
+}
+
+func (r *aliasIndexReader) FieldDict(field string) (index.FieldDict, error) {
+	return r.IndexReader.FieldDict(r.field(field))
+}
+
+func (r *aliasIndexReader) FieldDictRange(field string, startTerm []byte, endTerm []byte) (index.FieldDict, error) {
+	return r.IndexReader.FieldDictRange(r.field(field), startTerm, endTerm)
+}
+
+func (r *aliasIndexReader) FieldDictPrefix(field string, termPrefix []byte) (index.FieldDict, error) {
+	return r.IndexReader.FieldDictPrefix(r.field(field), termPrefix)
+}
+
+// FieldDictAutomaton walks the terms of the reader it wraps
+// when that reader cannot walk automata itself
+func (r *aliasIndexReader) FieldDictAutomaton(field string, automaton index.Automaton) (index.FieldDict, error) {
+	if automatonReader, ok := r.IndexReader.(index.AutomatonIndexReader); ok {
+		return automatonReader.FieldDictAutomaton(r.field(field), automaton)
+	}
+	fieldDict, err := r.IndexReader.FieldDict(r.field(field))
+	if err != nil {
+		return nil, err
+	}
+	return &automatonFieldDict{
+		FieldDict: fieldDict,
+		automaton: automaton,
+	}, nil
+}
+
+// TermDocSet returns no set when the reader it wraps
+// keeps none, the searchers fall back to their readers
+func (r *aliasIndexReader) TermDocSet(term []byte, field string) (index.DocSet, error) {
+	if docSetReader, ok := r.IndexReader.(index.DocSetIndexReader); ok {
+		return docSetReader.TermDocSet(term, r.field(field))
+	}
+	return nil, nil
+}
+
+// SortField is the field the wrapped reader is sorted by,
+// searches resolve a sort on one of its aliases to it
+func (r *aliasIndexReader) SortField() string {
+	if sortedReader, ok := r.IndexReader.(index.SortedIndexReader); ok {
+		return sortedReader.SortField()
+	}
+	return ""
+}
+
+func (r *aliasIndexReader) SortedDocIDReader() (index.SortedDocIDReader, error) {
+	if sortedReader, ok := r.IndexReader.(index.SortedIndexReader); ok {
+		return sortedReader.SortedDocIDReader()
+	}
+	return nil, fmt.Errorf("index is not sorted")
+}
+
+// DocValueReader visits the values of the fields of the
+// aliases by the names of the aliases
+func (r *aliasIndexReader) DocValueReader(fields []string) (index.DocValueReader, error) {
+	names := make(map[string][]string, len(fields))
+	targets := make([]string, 0, len(fields))
+	for _, field := range fields {
+		target := r.field(field)
+		if _, ok := names[target]; !ok {
+			targets = append(targets, target)
+		}
+		names[target] = append(names[target], field)
+	}
+	dvReader, err := r.IndexReader.DocValueReader(targets)
+	if err != nil {
+		return nil, err
+	}
+	return &aliasDocValueReader{
+		dvReader: dvReader,
+		names:    names,
+	}, nil
+}
+
+type aliasDocValueReader struct {
+	dvReader index.DocValueReader
+	names    map[string][]string
+}
+
+func (r *aliasDocValueReader) VisitDocValues(id string, visitor index.DocValueVisitor) error {
+	return r.dvReader.VisitDocValues(id, func(field string, term []byte) {
+		names, ok := r.names[field]
+		if !ok {
+			visitor(field, term)
+			return
+		}
+		for _, name := range names {
+			visitor(name, term)
+		}
+	})
+}
+
+// automatonFieldDict leaves out the terms of a dictionary
+// the automaton does not match
+type automatonFieldDict struct {
+	index.FieldDict
+	automaton index.Automaton
+}
+
+func (d *automatonFieldDict) Next() (*index.DictEntry, error) {
+	entry, err := d.FieldDict.Next()
+	for err == nil && entry != nil {
+		if d.matches(entry.Term) {
+			return entry, nil
+		}
+		entry, err = d.FieldDict.Next()
+	}
+	return entry, err
+}
+
+func (d *automatonFieldDict) matches(term string) bool {
+	state := d.automaton.Start()
+	for i := 0; i < len(term); i++ {
+		state = d.automaton.Accept(state, term[i])
+		if !d.automaton.CanMatch(state) {
+			return false
+		}
+	}
+	return d.automaton.IsMatch(state)
+}
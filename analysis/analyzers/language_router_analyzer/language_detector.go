@@ -0,0 +1,99 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package language_router_analyzer
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+// forEachTrigram calls fn for every character trigram of
+// the lower cased words of text, each word is padded with
+// a space on both sides so that word starts and ends are
+// part of the model
+func forEachTrigram(text string, fn func(string)) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			fn(string(runes[i : i+3]))
+		}
+	}
+}
+
+type languageProfile struct {
+	counts map[string]float64
+	total  float64
+}
+
+// LanguageDetector is a character trigram model of each
+// language, built from a list of words in the language
+// such as its stop words.  The language of a text is the
+// one giving its trigrams the highest likelihood, with
+// add-one smoothing for trigrams missing from a model.
+type LanguageDetector struct {
+	languages  []string
+	profiles   []*languageProfile
+	vocabulary float64
+}
+
+func NewLanguageDetector(words map[string]analysis.TokenMap) *LanguageDetector {
+	rv := LanguageDetector{}
+	for language := range words {
+		rv.languages = append(rv.languages, language)
+	}
+	sort.Strings(rv.languages)
+
+	vocabulary := make(map[string]bool)
+	for _, language := range rv.languages {
+		profile := languageProfile{
+			counts: make(map[string]float64),
+		}
+		for word := range words[language] {
+			forEachTrigram(word, func(trigram string) {
+				profile.counts[trigram]++
+				profile.total++
+				vocabulary[trigram] = true
+			})
+		}
+		rv.profiles = append(rv.profiles, &profile)
+	}
+	rv.vocabulary = float64(len(vocabulary))
+	return &rv
+}
+
+// Detect returns the most likely language of the text, ok
+// is false when the text has no trigrams to go by
+func (d *LanguageDetector) Detect(text []byte) (language string, ok bool) {
+	scores := make([]float64, len(d.profiles))
+	trigrams := 0
+	forEachTrigram(string(text), func(trigram string) {
+		trigrams++
+		for i, profile := range d.profiles {
+			scores[i] += math.Log((profile.counts[trigram] + 1) / (profile.total + d.vocabulary))
+		}
+	})
+	if trigrams == 0 || len(d.languages) == 0 {
+		return "", false
+	}
+	best := 0
+	for i := range scores {
+		if scores[i] > scores[best] {
+			best = i
+		}
+	}
+	return d.languages[best], true
+}
@@ -0,0 +1,115 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package language_router_analyzer
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/analyzers/standard_analyzer"
+	"github.com/blevesearch/bleve/registry"
+)
+
+// The language router detects the language of each input
+// and analyzes it with the analyzer configured for that
+// language, so fields holding text in several languages
+// get the right stemming and stop words.  The "languages"
+// config maps language codes to analyzer names, the model
+// of each language is built from the token map named in
+// "profiles", by default its stop words "stop_<code>".
+// Input of no detectable language, or of a language
+// without an analyzer, is analyzed with the
+// "default_analyzer", by default the standard analyzer.
+const Name = "language_router"
+
+// LanguageRouter is the tokenizer of a language router
+// analyzer, it runs the whole chain of the analyzer it
+// routes to
+type LanguageRouter struct {
+	detector        *LanguageDetector
+	analyzers       map[string]*analysis.Analyzer
+	defaultAnalyzer *analysis.Analyzer
+}
+
+func NewLanguageRouter(detector *LanguageDetector, analyzers map[string]*analysis.Analyzer, defaultAnalyzer *analysis.Analyzer) *LanguageRouter {
+	return &LanguageRouter{
+		detector:        detector,
+		analyzers:       analyzers,
+		defaultAnalyzer: defaultAnalyzer,
+	}
+}
+
+// AnalyzerFor returns the detected language of the input
+// and the analyzer used for it
+func (r *LanguageRouter) AnalyzerFor(input []byte) (string, *analysis.Analyzer) {
+	language, ok := r.detector.Detect(input)
+	if ok {
+		analyzer, ok := r.analyzers[language]
+		if ok {
+			return language, analyzer
+		}
+	}
+	return "", r.defaultAnalyzer
+}
+
+func (r *LanguageRouter) Tokenize(input []byte) analysis.TokenStream {
+	_, analyzer := r.AnalyzerFor(input)
+	return analyzer.Analyze(input)
+}
+
+func AnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (*analysis.Analyzer, error) {
+	languages, ok := config["languages"].(map[string]interface{})
+	if !ok || len(languages) == 0 {
+		return nil, fmt.Errorf("must specify languages")
+	}
+	profileNames, _ := config["profiles"].(map[string]interface{})
+
+	analyzers := make(map[string]*analysis.Analyzer, len(languages))
+	words := make(map[string]analysis.TokenMap, len(languages))
+	for language, analyzerName := range languages {
+		analyzerNameStr, ok := analyzerName.(string)
+		if !ok {
+			return nil, fmt.Errorf("analyzer for language '%s' must be a string", language)
+		}
+		analyzer, err := cache.AnalyzerNamed(analyzerNameStr)
+		if err != nil {
+			return nil, err
+		}
+		analyzers[language] = analyzer
+
+		profileName := "stop_" + language
+		if profileNameVal, ok := profileNames[language].(string); ok {
+			profileName = profileNameVal
+		}
+		profile, err := cache.TokenMapNamed(profileName)
+		if err != nil {
+			return nil, fmt.Errorf("error building language model for '%s': %v", language, err)
+		}
+		words[language] = profile
+	}
+
+	defaultAnalyzerName, ok := config["default_analyzer"].(string)
+	if !ok {
+		defaultAnalyzerName = standard_analyzer.Name
+	}
+	defaultAnalyzer, err := cache.AnalyzerNamed(defaultAnalyzerName)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := analysis.Analyzer{
+		Tokenizer: NewLanguageRouter(NewLanguageDetector(words), analyzers, defaultAnalyzer),
+	}
+	return &rv, nil
+}
+
+func init() {
+	registry.RegisterAnalyzer(Name, AnalyzerConstructor)
+}
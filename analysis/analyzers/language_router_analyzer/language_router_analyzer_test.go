@@ -0,0 +1,106 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package language_router_analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	_ "github.com/blevesearch/bleve/analysis/language/en"
+	_ "github.com/blevesearch/bleve/analysis/language/fr"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestLanguageRouterAnalyzer(t *testing.T) {
+	tests := []struct {
+		input    []byte
+		language string
+		output   analysis.TokenStream
+	}{
+		{
+			input:    []byte("the cats were running through the houses"),
+			language: "en",
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("cat"),
+					Position: 2,
+					Start:    4,
+					End:      8,
+				},
+				&analysis.Token{
+					Term:     []byte("run"),
+					Position: 4,
+					Start:    14,
+					End:      21,
+				},
+				&analysis.Token{
+					Term:     []byte("hous"),
+					Position: 7,
+					Start:    34,
+					End:      40,
+				},
+			},
+		},
+		{
+			input:    []byte("les chats sont dans la maison avec nous"),
+			language: "fr",
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("chat"),
+					Position: 2,
+					Start:    4,
+					End:      9,
+				},
+				&analysis.Token{
+					Term:     []byte("maison"),
+					Position: 6,
+					Start:    23,
+					End:      29,
+				},
+			},
+		},
+		{
+			input:    []byte("1234"),
+			language: "",
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("1234"),
+					Position: 1,
+					Start:    0,
+					End:      4,
+					Type:     analysis.Numeric,
+				},
+			},
+		},
+	}
+
+	cache := registry.NewCache()
+	analyzer, err := AnalyzerConstructor(map[string]interface{}{
+		"languages": map[string]interface{}{
+			"en": "en",
+			"fr": "fr",
+		},
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := analyzer.Tokenizer.(*LanguageRouter)
+	for _, test := range tests {
+		language, _ := router.AnalyzerFor(test.input)
+		if language != test.language {
+			t.Errorf("expected language %q, got %q for %s", test.language, language, test.input)
+		}
+		actual := analyzer.Analyze(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v", test.output, actual)
+		}
+	}
+}
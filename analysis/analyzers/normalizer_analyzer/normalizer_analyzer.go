@@ -0,0 +1,49 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package normalizer_analyzer
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/analyzers/custom_analyzer"
+	"github.com/blevesearch/bleve/analysis/tokenizers/single_token"
+	"github.com/blevesearch/bleve/registry"
+)
+
+// A normalizer is an analyzer for keyword style fields,
+// the whole input is kept as a single token which is then
+// passed through the configured "char_filters" and
+// "token_filters", so exact match fields can still be
+// lowercased or folded.  As the same normalizer is used
+// for indexing and for match queries on the field,
+// queries are normalized consistently.  Only token
+// filters which modify a token in place (such as
+// lowercasing or folding) should be used.
+const Name = "normalizer"
+
+func AnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (*analysis.Analyzer, error) {
+	_, ok := config["tokenizer"]
+	if ok {
+		return nil, fmt.Errorf("normalizers do not accept a tokenizer")
+	}
+
+	customConfig := make(map[string]interface{}, len(config)+1)
+	for k, v := range config {
+		customConfig[k] = v
+	}
+	customConfig["tokenizer"] = single_token.Name
+
+	return custom_analyzer.AnalyzerConstructor(customConfig, cache)
+}
+
+func init() {
+	registry.RegisterAnalyzer(Name, AnalyzerConstructor)
+}
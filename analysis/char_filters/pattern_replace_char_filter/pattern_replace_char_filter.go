@@ -0,0 +1,96 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pattern_replace_char_filter
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "pattern_replace"
+
+// PatternReplaceCharFilter replaces every match of a
+// regular expression with the replacement, which may refer
+// to capture groups using $1 or ${name}.  Unlike the
+// regexp char filter the output can be shorter or longer
+// than the input, the offset corrections returned from
+// FilterWithCorrections map token offsets back to the
+// original text.
+type PatternReplaceCharFilter struct {
+	r           *regexp.Regexp
+	replacement []byte
+}
+
+func NewPatternReplaceCharFilter(r *regexp.Regexp, replacement []byte) *PatternReplaceCharFilter {
+	return &PatternReplaceCharFilter{
+		r:           r,
+		replacement: replacement,
+	}
+}
+
+func (s *PatternReplaceCharFilter) Filter(input []byte) []byte {
+	output, _ := s.FilterWithCorrections(input)
+	return output
+}
+
+func (s *PatternReplaceCharFilter) FilterWithCorrections(input []byte) ([]byte, analysis.OffsetCorrections) {
+	matches := s.r.FindAllSubmatchIndex(input, -1)
+	if len(matches) == 0 {
+		return input, nil
+	}
+
+	rv := make([]byte, 0, len(input))
+	var corrections analysis.OffsetCorrections
+	diff := 0
+	last := 0
+	for _, match := range matches {
+		rv = append(rv, input[last:match[0]]...)
+		outputStart := len(rv)
+		rv = s.r.Expand(rv, s.replacement, input, match)
+		matchLen := match[1] - match[0]
+		replacementLen := len(rv) - outputStart
+
+		if replacementLen < matchLen {
+			// the text following the replacement moves back
+			diff += matchLen - replacementLen
+			corrections = corrections.Add(outputStart+replacementLen, diff)
+		} else if replacementLen > matchLen {
+			// the extra output all maps to the end of the match
+			for i := 1; i <= replacementLen-matchLen; i++ {
+				corrections = corrections.Add(outputStart+matchLen+i, diff-i)
+			}
+			diff -= replacementLen - matchLen
+		}
+		last = match[1]
+	}
+	rv = append(rv, input[last:]...)
+
+	return rv, corrections
+}
+
+func PatternReplaceCharFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.CharFilter, error) {
+	patternStr, ok := config["pattern"].(string)
+	if !ok {
+		return nil, fmt.Errorf("must specify pattern")
+	}
+	r, err := regexp.Compile(patternStr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build pattern replace char filter: %v", err)
+	}
+	replacement, _ := config["replacement"].(string)
+	return NewPatternReplaceCharFilter(r, []byte(replacement)), nil
+}
+
+func init() {
+	registry.RegisterCharFilter(Name, PatternReplaceCharFilterConstructor)
+}
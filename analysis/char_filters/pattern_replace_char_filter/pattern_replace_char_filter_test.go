@@ -0,0 +1,104 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pattern_replace_char_filter
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/tokenizers/unicode"
+)
+
+func TestPatternReplaceCharFilter(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		replacement string
+		input       []byte
+		output      []byte
+	}{
+		{
+			pattern:     `(\d+)-(\d+)-(\d+)`,
+			replacement: "$1$2$3",
+			input:       []byte("call 555-123-4567 now"),
+			output:      []byte("call 5551234567 now"),
+		},
+		{
+			pattern:     `(?P<user>\w+)@(?P<host>\w+)`,
+			replacement: "${host} ${user}",
+			input:       []byte("mail joe@home"),
+			output:      []byte("mail home joe"),
+		},
+		{
+			pattern:     `&`,
+			replacement: "and",
+			input:       []byte("cats & dogs"),
+			output:      []byte("cats and dogs"),
+		},
+		{
+			pattern:     `x`,
+			replacement: "",
+			input:       []byte("nothing to see"),
+			output:      []byte("nothing to see"),
+		},
+	}
+
+	for _, test := range tests {
+		filter := NewPatternReplaceCharFilter(regexp.MustCompile(test.pattern), []byte(test.replacement))
+		output := filter.Filter(test.input)
+		if !reflect.DeepEqual(output, test.output) {
+			t.Errorf("Expected:\n`%s`\ngot:\n`%s`\nfor:\n`%s`\n", string(test.output), string(output), string(test.input))
+		}
+	}
+}
+
+func TestPatternReplaceCharFilterOffsets(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		replacement string
+		input       []byte
+		output      analysis.TokenStream
+	}{
+		{
+			pattern:     `(\d+)-(\d+)-(\d+)`,
+			replacement: "$1$2$3",
+			input:       []byte("call 555-123-4567 now"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("call"), Position: 1, Start: 0, End: 4, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("5551234567"), Position: 2, Start: 5, End: 17, Type: analysis.Numeric},
+				&analysis.Token{Term: []byte("now"), Position: 3, Start: 18, End: 21, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			pattern:     `&`,
+			replacement: "and",
+			input:       []byte("cats & dogs"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("cats"), Position: 1, Start: 0, End: 4, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("and"), Position: 2, Start: 5, End: 6, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("dogs"), Position: 3, Start: 7, End: 11, Type: analysis.AlphaNumeric},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		analyzer := analysis.Analyzer{
+			CharFilters: []analysis.CharFilter{
+				NewPatternReplaceCharFilter(regexp.MustCompile(test.pattern), []byte(test.replacement)),
+			},
+			Tokenizer: unicode.NewUnicodeTokenizer(),
+		}
+		actual := analyzer.Analyze(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v", test.output, actual)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package epoch
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+// MillisName parses milliseconds and SecondsName seconds
+// since the epoch, either may have a fraction
+const MillisName = "epoch_millis"
+const SecondsName = "epoch_second"
+
+type EpochDateTimeParser struct {
+	unit time.Duration
+}
+
+func NewEpochDateTimeParser(unit time.Duration) *EpochDateTimeParser {
+	return &EpochDateTimeParser{
+		unit: unit,
+	}
+}
+
+func (p *EpochDateTimeParser) ParseDateTime(input string) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	if i, err := strconv.ParseInt(input, 10, 64); err == nil {
+		if i > math.MaxInt64/int64(p.unit) || i < math.MinInt64/int64(p.unit) {
+			return time.Time{}, analysis.ErrInvalidDateTime
+		}
+		return time.Unix(0, i*int64(p.unit)).UTC(), nil
+	}
+	f, err := strconv.ParseFloat(input, 64)
+	if err != nil || math.IsNaN(f) {
+		return time.Time{}, analysis.ErrInvalidDateTime
+	}
+	ns := f * float64(p.unit)
+	if ns >= math.MaxInt64 || ns <= math.MinInt64 {
+		return time.Time{}, analysis.ErrInvalidDateTime
+	}
+	return time.Unix(0, int64(ns)).UTC(), nil
+}
+
+func MillisDateTimeParserConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.DateTimeParser, error) {
+	return NewEpochDateTimeParser(time.Millisecond), nil
+}
+
+func SecondsDateTimeParserConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.DateTimeParser, error) {
+	return NewEpochDateTimeParser(time.Second), nil
+}
+
+func init() {
+	registry.RegisterDateTimeParser(MillisName, MillisDateTimeParserConstructor)
+	registry.RegisterDateTimeParser(SecondsName, SecondsDateTimeParserConstructor)
+}
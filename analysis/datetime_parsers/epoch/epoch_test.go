@@ -0,0 +1,68 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package epoch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+func TestEpochDateTimeParser(t *testing.T) {
+	tests := []struct {
+		unit          time.Duration
+		input         string
+		expectedTime  time.Time
+		expectedError error
+	}{
+		{
+			unit:         time.Millisecond,
+			input:        "1407081570123",
+			expectedTime: time.Date(2014, 8, 3, 15, 59, 30, 123000000, time.UTC),
+		},
+		{
+			unit:         time.Second,
+			input:        "1407081570",
+			expectedTime: time.Date(2014, 8, 3, 15, 59, 30, 0, time.UTC),
+		},
+		{
+			unit:         time.Second,
+			input:        "1407081570.5",
+			expectedTime: time.Date(2014, 8, 3, 15, 59, 30, 500000000, time.UTC),
+		},
+		{
+			unit:         time.Second,
+			input:        "-86400",
+			expectedTime: time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			unit:          time.Second,
+			input:         "99999999999999999",
+			expectedError: analysis.ErrInvalidDateTime,
+		},
+		{
+			unit:          time.Millisecond,
+			input:         "2014-08-03",
+			expectedError: analysis.ErrInvalidDateTime,
+		},
+	}
+
+	for _, test := range tests {
+		actualTime, actualErr := NewEpochDateTimeParser(test.unit).ParseDateTime(test.input)
+		if actualErr != test.expectedError {
+			t.Errorf("expected error %#v, got %#v for %s", test.expectedError, actualErr, test.input)
+			continue
+		}
+		if !actualTime.Equal(test.expectedTime) {
+			t.Errorf("expected time %v, got %v for %s", test.expectedTime, actualTime, test.input)
+		}
+	}
+}
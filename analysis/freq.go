@@ -15,6 +15,7 @@ type TokenLocation struct {
 	Start          int
 	End            int
 	Position       int
+	Payload        []byte
 }
 
 type TokenFreq struct {
@@ -51,6 +52,7 @@ func TokenFrequency(tokens TokenStream, arrayPositions []uint64) TokenFrequencie
 				Start:          token.Start,
 				End:            token.End,
 				Position:       token.Position,
+				Payload:        token.Payload,
 			})
 		} else {
 			rv[string(token.Term)] = &TokenFreq{
@@ -61,6 +63,7 @@ func TokenFrequency(tokens TokenStream, arrayPositions []uint64) TokenFrequencie
 						Start:          token.Start,
 						End:            token.End,
 						Position:       token.Position,
+						Payload:        token.Payload,
 					},
 				},
 			}
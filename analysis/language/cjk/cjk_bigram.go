@@ -11,6 +11,8 @@ package cjk
 
 import (
 	"container/ring"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/registry"
@@ -18,24 +20,109 @@ import (
 
 const BigramName = "cjk_bigram"
 
+// the scripts which can be bigrammed
+const (
+	Han = 1 << iota
+	Hiragana
+	Katakana
+	Hangul
+)
+
+const AllScripts = Han | Hiragana | Katakana | Hangul
+
+var scriptTables = []struct {
+	script int
+	table  *unicode.RangeTable
+}{
+	{Han, unicode.Han},
+	{Hiragana, unicode.Hiragana},
+	{Katakana, unicode.Katakana},
+	{Hangul, unicode.Hangul},
+}
+
+// CJKBigramFilter forms bigrams of adjacent characters in
+// the scripts it is configured for, tokens in the other
+// scripts are passed through unchanged.  Tokens of more
+// than one character, such as katakana or hangul words,
+// are split into their characters first.
 type CJKBigramFilter struct {
+	scripts       int
 	outputUnigram bool
 }
 
 func NewCJKBigramFilter(outputUnigram bool) *CJKBigramFilter {
+	return NewCJKBigramFilterForScripts(AllScripts, outputUnigram)
+}
+
+func NewCJKBigramFilterForScripts(scripts int, outputUnigram bool) *CJKBigramFilter {
 	return &CJKBigramFilter{
+		scripts:       scripts,
 		outputUnigram: outputUnigram,
 	}
 }
 
+// bigrammed reports whether the token is in one of the
+// configured scripts.  Hangul is not reported as
+// ideographic by the tokenizers, so it is recognized by
+// its characters alone.
+func (s *CJKBigramFilter) bigrammed(token *analysis.Token) bool {
+	r, _ := utf8.DecodeRune(token.Term)
+	for _, st := range scriptTables {
+		if unicode.Is(st.table, r) {
+			if st.script != Hangul && token.Type != analysis.Ideographic {
+				return false
+			}
+			return s.scripts&st.script != 0
+		}
+	}
+	return false
+}
+
+// split returns a token for every character of the token,
+// shift counts the positions added by splitting so far
+func split(token *analysis.Token, shift *int) analysis.TokenStream {
+	position := token.Position + *shift
+	if utf8.RuneCount(token.Term) == 1 {
+		token.Position = position
+		return analysis.TokenStream{token}
+	}
+	rv := make(analysis.TokenStream, 0, len(token.Term))
+	start := 0
+	for start < len(token.Term) {
+		_, size := utf8.DecodeRune(token.Term[start:])
+		rv = append(rv, &analysis.Token{
+			Term:     token.Term[start : start+size],
+			Start:    token.Start + start,
+			End:      token.Start + start + size,
+			Position: position + len(rv),
+			Type:     analysis.Ideographic,
+		})
+		start += size
+	}
+	*shift += len(rv) - 1
+	return rv
+}
+
 func (s *CJKBigramFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
 	r := ring.New(2)
 	itemsInRing := 0
+	shift := 0
 
 	rv := make(analysis.TokenStream, 0, len(input))
 
-	for _, token := range input {
-		if token.Type == analysis.Ideographic {
+	for _, inputToken := range input {
+		if !s.bigrammed(inputToken) {
+			// flush anything already buffered
+			flushToken := s.flush(r, &itemsInRing)
+			if flushToken != nil {
+				rv = append(rv, flushToken)
+			}
+			// output this token as is
+			inputToken.Position += shift
+			rv = append(rv, inputToken)
+			continue
+		}
+		for _, token := range split(inputToken, &shift) {
 			if itemsInRing > 0 {
 				// if items already buffered
 				// check to see if this is aligned
@@ -64,14 +151,6 @@ func (s *CJKBigramFilter) Filter(input analysis.TokenStream) analysis.TokenStrea
 			if bigramToken != nil {
 				rv = append(rv, bigramToken)
 			}
-		} else {
-			// flush anything already buffered
-			flushToken := s.flush(r, &itemsInRing)
-			if flushToken != nil {
-				rv = append(rv, flushToken)
-			}
-			// output this token as is
-			rv = append(rv, token)
 		}
 	}
 
@@ -92,6 +171,11 @@ func (s *CJKBigramFilter) flush(r *ring.Ring, itemsInRing *int) *analysis.Token
 	var rv *analysis.Token
 	if *itemsInRing == 1 {
 		rv = s.buildUnigram(r, itemsInRing)
+	} else if *itemsInRing == 2 && s.outputUnigram {
+		// the unigram of the last token buffered
+		// has not been output yet
+		one := 1
+		rv = s.buildUnigram(r, &one)
 	}
 	r.Value = nil
 	*itemsInRing = 0
@@ -158,7 +242,26 @@ func CJKBigramFilterConstructor(config map[string]interface{}, cache *registry.C
 	if ok {
 		outputUnigram = outVal
 	}
-	return NewCJKBigramFilter(outputUnigram), nil
+	outVal, ok = config["output_unigrams"].(bool)
+	if ok {
+		outputUnigram = outVal
+	}
+	scripts := 0
+	for _, flag := range []struct {
+		key    string
+		script int
+	}{
+		{"han", Han},
+		{"hiragana", Hiragana},
+		{"katakana", Katakana},
+		{"hangul", Hangul},
+	} {
+		enabled, ok := config[flag.key].(bool)
+		if !ok || enabled {
+			scripts |= flag.script
+		}
+	}
+	return NewCJKBigramFilterForScripts(scripts, outputUnigram), nil
 }
 
 func init() {
@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
 )
 
 func TestCJKBigramFilter(t *testing.T) {
@@ -418,3 +419,78 @@ func TestCJKBigramFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestCJKBigramFilterScripts(t *testing.T) {
+	input := func() analysis.TokenStream {
+		return analysis.TokenStream{
+			&analysis.Token{Term: []byte("東"), Start: 0, End: 3, Position: 1, Type: analysis.Ideographic},
+			&analysis.Token{Term: []byte("京"), Start: 3, End: 6, Position: 2, Type: analysis.Ideographic},
+			&analysis.Token{Term: []byte("タワー"), Start: 6, End: 15, Position: 3, Type: analysis.Ideographic},
+			&analysis.Token{Term: []byte("한국어"), Start: 16, End: 25, Position: 4, Type: analysis.AlphaNumeric},
+			&analysis.Token{Term: []byte("abc"), Start: 26, End: 29, Position: 5, Type: analysis.AlphaNumeric},
+		}
+	}
+
+	tests := []struct {
+		config map[string]interface{}
+		output analysis.TokenStream
+	}{
+		{
+			config: map[string]interface{}{},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("東京"), Start: 0, End: 6, Position: 1, Type: analysis.Double},
+				&analysis.Token{Term: []byte("京タ"), Start: 3, End: 9, Position: 2, Type: analysis.Double},
+				&analysis.Token{Term: []byte("タワ"), Start: 6, End: 12, Position: 3, Type: analysis.Double},
+				&analysis.Token{Term: []byte("ワー"), Start: 9, End: 15, Position: 4, Type: analysis.Double},
+				&analysis.Token{Term: []byte("한국"), Start: 16, End: 22, Position: 6, Type: analysis.Double},
+				&analysis.Token{Term: []byte("국어"), Start: 19, End: 25, Position: 7, Type: analysis.Double},
+				&analysis.Token{Term: []byte("abc"), Start: 26, End: 29, Position: 9, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"han":    false,
+				"hangul": false,
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("東"), Start: 0, End: 3, Position: 1, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("京"), Start: 3, End: 6, Position: 2, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("タワ"), Start: 6, End: 12, Position: 3, Type: analysis.Double},
+				&analysis.Token{Term: []byte("ワー"), Start: 9, End: 15, Position: 4, Type: analysis.Double},
+				&analysis.Token{Term: []byte("한국어"), Start: 16, End: 25, Position: 6, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("abc"), Start: 26, End: 29, Position: 7, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"han":             false,
+				"hiragana":        false,
+				"katakana":        false,
+				"output_unigrams": true,
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("東"), Start: 0, End: 3, Position: 1, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("京"), Start: 3, End: 6, Position: 2, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("タワー"), Start: 6, End: 15, Position: 3, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("한"), Start: 16, End: 19, Position: 4, Type: analysis.Single},
+				&analysis.Token{Term: []byte("한국"), Start: 16, End: 22, Position: 4, Type: analysis.Double},
+				&analysis.Token{Term: []byte("국"), Start: 19, End: 22, Position: 5, Type: analysis.Single},
+				&analysis.Token{Term: []byte("국어"), Start: 19, End: 25, Position: 5, Type: analysis.Double},
+				&analysis.Token{Term: []byte("어"), Start: 22, End: 25, Position: 6, Type: analysis.Single},
+				&analysis.Token{Term: []byte("abc"), Start: 26, End: 29, Position: 7, Type: analysis.AlphaNumeric},
+			},
+		},
+	}
+
+	cache := registry.NewCache()
+	for _, test := range tests {
+		filter, err := CJKBigramFilterConstructor(test.config, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := filter.Filter(input())
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %s, got %s", test.output, actual)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package co
+
+import (
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const ArticlesName = "articles_co"
+
+// the elided forms of the articles, pronouns and
+// prepositions of Corsican
+
+var CorsicanArticles = []byte(`
+c
+d
+l
+m
+n
+s
+t
+un
+`)
+
+func ArticlesTokenMapConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenMap, error) {
+	rv := analysis.NewTokenMap()
+	err := rv.LoadBytes(CorsicanArticles)
+	return rv, err
+}
+
+func init() {
+	registry.RegisterTokenMap(ArticlesName, ArticlesTokenMapConstructor)
+}
@@ -0,0 +1,110 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package de
+
+import (
+	"bytes"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const LightStemmerName = "stemmer_de_light"
+
+// GermanLightStemmerFilter follows the light stemmer by
+// Jacques Savoy, it removes umlauts and accents as well
+// as the most common inflectional endings
+type GermanLightStemmerFilter struct {
+}
+
+func NewGermanLightStemmerFilter() *GermanLightStemmerFilter {
+	return &GermanLightStemmerFilter{}
+}
+
+func (s *GermanLightStemmerFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		if token.KeyWord {
+			continue
+		}
+		runes := bytes.Runes(token.Term)
+		runes = stem(runes)
+		token.Term = analysis.BuildTermFromRunes(runes)
+	}
+	return input
+}
+
+func stem(input []rune) []rune {
+	for i, r := range input {
+		switch r {
+		case 'ä', 'à', 'á', 'â':
+			input[i] = 'a'
+		case 'ö', 'ò', 'ó', 'ô':
+			input[i] = 'o'
+		case 'ï', 'ì', 'í', 'î':
+			input[i] = 'i'
+		case 'ü', 'ù', 'ú', 'û':
+			input[i] = 'u'
+		}
+	}
+	input = step1(input)
+	return step2(input)
+}
+
+func stEnding(r rune) bool {
+	switch r {
+	case 'b', 'd', 'f', 'g', 'h', 'k', 'l', 'm', 'n', 't':
+		return true
+	}
+	return false
+}
+
+func step1(input []rune) []rune {
+	inputLen := len(input)
+
+	if inputLen > 5 && input[inputLen-3] == 'e' && input[inputLen-2] == 'r' && input[inputLen-1] == 'n' {
+		return input[0 : inputLen-3]
+	}
+	if inputLen > 4 && input[inputLen-2] == 'e' {
+		switch input[inputLen-1] {
+		case 'm', 'n', 'r', 's':
+			return input[0 : inputLen-2]
+		}
+	}
+	if inputLen > 3 && input[inputLen-1] == 'e' {
+		return input[0 : inputLen-1]
+	}
+	if inputLen > 3 && input[inputLen-1] == 's' && stEnding(input[inputLen-2]) {
+		return input[0 : inputLen-1]
+	}
+	return input
+}
+
+func step2(input []rune) []rune {
+	inputLen := len(input)
+
+	if inputLen > 5 && input[inputLen-3] == 'e' && input[inputLen-2] == 's' && input[inputLen-1] == 't' {
+		return input[0 : inputLen-3]
+	}
+	if inputLen > 4 && input[inputLen-2] == 'e' && (input[inputLen-1] == 'r' || input[inputLen-1] == 'n') {
+		return input[0 : inputLen-2]
+	}
+	if inputLen > 4 && input[inputLen-2] == 's' && input[inputLen-1] == 't' && stEnding(input[inputLen-3]) {
+		return input[0 : inputLen-2]
+	}
+	return input
+}
+
+func GermanLightStemmerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewGermanLightStemmerFilter(), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(LightStemmerName, GermanLightStemmerFilterConstructor)
+}
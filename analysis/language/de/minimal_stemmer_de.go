@@ -0,0 +1,91 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package de
+
+import (
+	"bytes"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const MinimalStemmerName = "stemmer_de_min"
+
+// GermanMinimalStemmerFilter only removes umlauts and
+// plural endings
+type GermanMinimalStemmerFilter struct {
+}
+
+func NewGermanMinimalStemmerFilter() *GermanMinimalStemmerFilter {
+	return &GermanMinimalStemmerFilter{}
+}
+
+func (s *GermanMinimalStemmerFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		if token.KeyWord {
+			continue
+		}
+		runes := bytes.Runes(token.Term)
+		runes = minstem(runes)
+		token.Term = analysis.BuildTermFromRunes(runes)
+	}
+	return input
+}
+
+func minstem(input []rune) []rune {
+
+	inputLen := len(input)
+
+	if inputLen < 5 {
+		return input
+	}
+
+	for i, r := range input {
+		switch r {
+		case 'ä':
+			input[i] = 'a'
+		case 'ö':
+			input[i] = 'o'
+		case 'ü':
+			input[i] = 'u'
+		}
+	}
+
+	if inputLen > 6 && input[inputLen-3] == 'n' && input[inputLen-2] == 'e' && input[inputLen-1] == 'n' {
+		return input[0 : inputLen-3]
+	}
+
+	if inputLen > 5 {
+		switch input[inputLen-1] {
+		case 'n', 'r', 's':
+			if input[inputLen-2] == 'e' {
+				return input[0 : inputLen-2]
+			}
+		case 'e':
+			if input[inputLen-2] == 'n' {
+				return input[0 : inputLen-2]
+			}
+		}
+	}
+
+	switch input[inputLen-1] {
+	case 'n', 'e', 's', 'r':
+		return input[0 : inputLen-1]
+	}
+	return input
+}
+
+func GermanMinimalStemmerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewGermanMinimalStemmerFilter(), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(MinimalStemmerName, GermanMinimalStemmerFilterConstructor)
+}
@@ -10,6 +10,8 @@
 package en
 
 import (
+	"fmt"
+
 	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/registry"
 
@@ -20,7 +22,24 @@ import (
 
 const AnalyzerName = "en"
 
+// stemmers are the choices for the stemmer key of the
+// analyzer config, "none" disables stemming
+var stemmers = map[string]string{
+	"porter":  porter.Name,
+	"minimal": MinimalStemmerName,
+	"none":    "",
+}
+
 func AnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (*analysis.Analyzer, error) {
+	stemmer := "porter"
+	stemmerVal, ok := config["stemmer"].(string)
+	if ok {
+		stemmer = stemmerVal
+	}
+	stemmerName, ok := stemmers[stemmer]
+	if !ok {
+		return nil, fmt.Errorf("unknown stemmer '%s' for analyzer %s", stemmer, AnalyzerName)
+	}
 	tokenizer, err := cache.TokenizerNamed(unicode.Name)
 	if err != nil {
 		return nil, err
@@ -37,19 +56,21 @@ func AnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (
 	if err != nil {
 		return nil, err
 	}
-	stemmerEnFilter, err := cache.TokenFilterNamed(porter.Name)
-	if err != nil {
-		return nil, err
-	}
 	rv := analysis.Analyzer{
 		Tokenizer: tokenizer,
 		TokenFilters: []analysis.TokenFilter{
 			possEnFilter,
 			toLowerFilter,
 			stopEnFilter,
-			stemmerEnFilter,
 		},
 	}
+	if stemmerName != "" {
+		stemmerEnFilter, err := cache.TokenFilterNamed(stemmerName)
+		if err != nil {
+			return nil, err
+		}
+		rv.TokenFilters = append(rv.TokenFilters, stemmerEnFilter)
+	}
 	return &rv, nil
 }
 
@@ -0,0 +1,73 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package en
+
+import (
+	"bytes"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const MinimalStemmerName = "stemmer_en_min"
+
+// EnglishMinimalStemmerFilter only removes plural
+// endings, following the s-stemmer described by Donna
+// Harman in "How effective is suffixing?"
+type EnglishMinimalStemmerFilter struct {
+}
+
+func NewEnglishMinimalStemmerFilter() *EnglishMinimalStemmerFilter {
+	return &EnglishMinimalStemmerFilter{}
+}
+
+func (s *EnglishMinimalStemmerFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		if token.KeyWord {
+			continue
+		}
+		runes := bytes.Runes(token.Term)
+		runes = minstem(runes)
+		token.Term = analysis.BuildTermFromRunes(runes)
+	}
+	return input
+}
+
+func minstem(input []rune) []rune {
+
+	inputLen := len(input)
+
+	if inputLen < 3 || input[inputLen-1] != 's' {
+		return input
+	}
+
+	switch input[inputLen-2] {
+	case 'u', 's':
+		return input
+	case 'e':
+		if inputLen > 3 && input[inputLen-3] == 'i' && input[inputLen-4] != 'a' && input[inputLen-4] != 'e' {
+			input[inputLen-3] = 'y'
+			return input[0 : inputLen-2]
+		}
+		switch input[inputLen-3] {
+		case 'i', 'a', 'o', 'e':
+			return input
+		}
+	}
+	return input[0 : inputLen-1]
+}
+
+func EnglishMinimalStemmerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewEnglishMinimalStemmerFilter(), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(MinimalStemmerName, EnglishMinimalStemmerFilterConstructor)
+}
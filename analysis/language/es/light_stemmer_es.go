@@ -0,0 +1,77 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package es
+
+import (
+	"bytes"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const LightStemmerName = "stemmer_es_light"
+
+// SpanishLightStemmerFilter removes accents, gender and
+// plural endings, following the light stemmer by
+// Jacques Savoy
+type SpanishLightStemmerFilter struct {
+}
+
+func NewSpanishLightStemmerFilter() *SpanishLightStemmerFilter {
+	return &SpanishLightStemmerFilter{}
+}
+
+func (s *SpanishLightStemmerFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		if token.KeyWord {
+			continue
+		}
+		runes := bytes.Runes(token.Term)
+		runes = stem(runes)
+		token.Term = analysis.BuildTermFromRunes(runes)
+	}
+	return input
+}
+
+func stem(input []rune) []rune {
+
+	inputLen := len(input)
+
+	if inputLen < 5 {
+		return input
+	}
+
+	removeAccents(input, false)
+
+	switch input[inputLen-1] {
+	case 'o', 'a', 'e':
+		return input[0 : inputLen-1]
+	case 's':
+		if input[inputLen-2] == 'e' && input[inputLen-3] == 's' && input[inputLen-4] == 'e' {
+			return input[0 : inputLen-2]
+		}
+		if input[inputLen-2] == 'e' && input[inputLen-3] == 'c' {
+			input[inputLen-3] = 'z'
+			return input[0 : inputLen-2]
+		}
+		if input[inputLen-2] == 'o' || input[inputLen-2] == 'a' || input[inputLen-2] == 'e' {
+			return input[0 : inputLen-2]
+		}
+	}
+	return input
+}
+
+func SpanishLightStemmerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewSpanishLightStemmerFilter(), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(LightStemmerName, SpanishLightStemmerFilterConstructor)
+}
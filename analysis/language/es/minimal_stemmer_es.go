@@ -0,0 +1,73 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package es
+
+import (
+	"bytes"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const MinimalStemmerName = "stemmer_es_min"
+
+// SpanishMinimalStemmerFilter only removes plural endings
+// and accents
+type SpanishMinimalStemmerFilter struct {
+}
+
+func NewSpanishMinimalStemmerFilter() *SpanishMinimalStemmerFilter {
+	return &SpanishMinimalStemmerFilter{}
+}
+
+func (s *SpanishMinimalStemmerFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		if token.KeyWord {
+			continue
+		}
+		runes := bytes.Runes(token.Term)
+		runes = minstem(runes)
+		token.Term = analysis.BuildTermFromRunes(runes)
+	}
+	return input
+}
+
+func minstem(input []rune) []rune {
+
+	inputLen := len(input)
+
+	if inputLen < 4 || input[inputLen-1] != 's' {
+		return input
+	}
+
+	removeAccents(input, true)
+
+	switch input[inputLen-2] {
+	case 'a', 'o':
+		return input[0 : inputLen-1]
+	case 'e':
+		if input[inputLen-3] == 's' && input[inputLen-4] == 'e' {
+			return input[0 : inputLen-2]
+		}
+		if input[inputLen-3] == 'c' {
+			input[inputLen-3] = 'z'
+		}
+		return input[0 : inputLen-2]
+	}
+	return input
+}
+
+func SpanishMinimalStemmerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewSpanishMinimalStemmerFilter(), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(MinimalStemmerName, SpanishMinimalStemmerFilterConstructor)
+}
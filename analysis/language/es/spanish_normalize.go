@@ -0,0 +1,33 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package es
+
+// removeAccents replaces the accented vowels, and ñ when
+// enye is set, by their unaccented form
+func removeAccents(input []rune, enye bool) {
+	for i, r := range input {
+		switch r {
+		case 'à', 'á', 'â', 'ä':
+			input[i] = 'a'
+		case 'ò', 'ó', 'ô', 'ö':
+			input[i] = 'o'
+		case 'è', 'é', 'ê', 'ë':
+			input[i] = 'e'
+		case 'ù', 'ú', 'û', 'ü':
+			input[i] = 'u'
+		case 'ì', 'í', 'î', 'ï':
+			input[i] = 'i'
+		case 'ñ':
+			if enye {
+				input[i] = 'n'
+			}
+		}
+	}
+}
@@ -202,3 +202,46 @@ func TestFrenchAnalyzer(t *testing.T) {
 		}
 	}
 }
+
+func TestFrenchAnalyzerStemmer(t *testing.T) {
+	tests := []struct {
+		stemmer string
+		output  string
+	}{
+		{
+			stemmer: "light",
+			output:  "cheval",
+		},
+		{
+			stemmer: "minimal",
+			output:  "cheval",
+		},
+		{
+			stemmer: "none",
+			output:  "chevaux",
+		},
+	}
+
+	cache := registry.NewCache()
+	for _, test := range tests {
+		analyzer, err := cache.DefineAnalyzer("fr_"+test.stemmer, map[string]interface{}{
+			"type":    AnalyzerName,
+			"stemmer": test.stemmer,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := analyzer.Analyze([]byte("chevaux"))
+		if len(actual) != 1 || string(actual[0].Term) != test.output {
+			t.Errorf("expected %s for stemmer %s, got %v", test.output, test.stemmer, actual)
+		}
+	}
+
+	_, err := cache.DefineAnalyzer("fr_unknown", map[string]interface{}{
+		"type":    AnalyzerName,
+		"stemmer": "aggressive",
+	})
+	if err == nil {
+		t.Errorf("expected error for unknown stemmer")
+	}
+}
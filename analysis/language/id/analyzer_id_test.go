@@ -0,0 +1,56 @@
+package id
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestIndonesianAnalyzer(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output analysis.TokenStream
+	}{
+		// lowercasing and stemming
+		{
+			input: []byte("Kebaikan"),
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term: []byte("baik"),
+				},
+			},
+		},
+		{
+			input: []byte("membangunkan"),
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term: []byte("bangun"),
+				},
+			},
+		},
+		// stop word
+		{
+			input:  []byte("adalah"),
+			output: analysis.TokenStream{},
+		},
+	}
+
+	cache := registry.NewCache()
+	analyzer, err := cache.AnalyzerNamed(AnalyzerName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		actual := analyzer.Analyze(test.input)
+		if len(actual) != len(test.output) {
+			t.Fatalf("expected length: %d, got %d", len(test.output), len(actual))
+		}
+		for i, tok := range actual {
+			if !reflect.DeepEqual(tok.Term, test.output[i].Term) {
+				t.Errorf("expected term %s (% x) got %s (% x)", test.output[i].Term, test.output[i].Term, tok.Term, tok.Term)
+			}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package id
+
+import (
+	"bytes"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const StemmerName = "stemmer_id"
+
+// IndonesianStemmerFilter implements the stemming algorithm
+// described in "A Study of Stemming Effects on Information
+// Retrieval in Bahasa Indonesia" by Fadillah Z Tala.  It
+// removes particles, possessive pronouns, prefixes and
+// derivational suffixes, but never reduces a word below
+// two syllables.
+type IndonesianStemmerFilter struct {
+}
+
+func NewIndonesianStemmerFilter() *IndonesianStemmerFilter {
+	return &IndonesianStemmerFilter{}
+}
+
+func (s *IndonesianStemmerFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		// if not protected keyword, stem it
+		if !token.KeyWord {
+			runes := bytes.Runes(token.Term)
+			runes = stem(runes)
+			token.Term = analysis.BuildTermFromRunes(runes)
+		}
+	}
+	return input
+}
+
+// flags recording which prefixes were removed, they
+// restrict which suffixes may be removed afterwards
+const (
+	removedKE = 1 << iota
+	removedPENG
+	removedDI
+	removedMENG
+	removedTER
+	removedBER
+	removedPE
+)
+
+type stemmer struct {
+	word         []rune
+	flags        int
+	numSyllables int
+}
+
+func isVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u':
+		return true
+	}
+	return false
+}
+
+func stem(input []rune) []rune {
+	s := stemmer{
+		word: input,
+	}
+	for _, r := range input {
+		if isVowel(r) {
+			s.numSyllables++
+		}
+	}
+
+	if s.numSyllables > 2 {
+		s.removeParticle()
+	}
+	if s.numSyllables > 2 {
+		s.removePossessivePronoun()
+	}
+	s.stemDerivational()
+	return s.word
+}
+
+func (s *stemmer) startsWith(prefix string) bool {
+	p := []rune(prefix)
+	if len(s.word) < len(p) {
+		return false
+	}
+	for i, r := range p {
+		if s.word[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *stemmer) endsWith(suffix string) bool {
+	return analysis.RunesEndsWith(s.word, suffix)
+}
+
+func (s *stemmer) removePrefix(n int, flag int) {
+	s.word = s.word[n:]
+	s.flags |= flag
+	s.numSyllables--
+}
+
+func (s *stemmer) removeSuffixN(n int) {
+	s.word = s.word[:len(s.word)-n]
+	s.numSyllables--
+}
+
+func (s *stemmer) stemDerivational() {
+	oldLength := len(s.word)
+	if s.numSyllables > 2 {
+		s.removeFirstOrderPrefix()
+	}
+	if oldLength != len(s.word) {
+		// a first order prefix was removed
+		oldLength = len(s.word)
+		if s.numSyllables > 2 {
+			s.removeSuffix()
+		}
+		if oldLength != len(s.word) && s.numSyllables > 2 {
+			s.removeSecondOrderPrefix()
+		}
+	} else {
+		if s.numSyllables > 2 {
+			s.removeSecondOrderPrefix()
+		}
+		if s.numSyllables > 2 {
+			s.removeSuffix()
+		}
+	}
+}
+
+func (s *stemmer) removeParticle() {
+	if s.endsWith("kah") || s.endsWith("lah") || s.endsWith("pun") {
+		s.removeSuffixN(3)
+	}
+}
+
+func (s *stemmer) removePossessivePronoun() {
+	if s.endsWith("ku") || s.endsWith("mu") {
+		s.removeSuffixN(2)
+	} else if s.endsWith("nya") {
+		s.removeSuffixN(3)
+	}
+}
+
+func (s *stemmer) removeFirstOrderPrefix() {
+	switch {
+	case s.startsWith("meng"):
+		s.removePrefix(4, removedMENG)
+	case s.startsWith("meny") && len(s.word) > 4 && isVowel(s.word[4]):
+		s.word[3] = 's'
+		s.removePrefix(3, removedMENG)
+	case s.startsWith("men"), s.startsWith("mem"):
+		s.removePrefix(3, removedMENG)
+	case s.startsWith("me"):
+		s.removePrefix(2, removedMENG)
+	case s.startsWith("peng"):
+		s.removePrefix(4, removedPENG)
+	case s.startsWith("peny") && len(s.word) > 4 && isVowel(s.word[4]):
+		s.word[3] = 's'
+		s.removePrefix(3, removedPENG)
+	case s.startsWith("peny"):
+		s.removePrefix(4, removedPENG)
+	case s.startsWith("pen") && len(s.word) > 4 && isVowel(s.word[3]):
+		s.word[2] = 't'
+		s.removePrefix(2, removedPENG)
+	case s.startsWith("pen"), s.startsWith("pem"):
+		s.removePrefix(3, removedPENG)
+	case s.startsWith("di"):
+		s.removePrefix(2, removedDI)
+	case s.startsWith("ter"):
+		s.removePrefix(3, removedTER)
+	case s.startsWith("ke"):
+		s.removePrefix(2, removedKE)
+	}
+}
+
+func (s *stemmer) removeSecondOrderPrefix() {
+	switch {
+	case s.startsWith("ber"):
+		s.removePrefix(3, removedBER)
+	case len(s.word) == 7 && s.startsWith("belajar"):
+		s.removePrefix(3, removedBER)
+	case s.startsWith("be") && len(s.word) > 4 && !isVowel(s.word[2]) && s.word[3] == 'e' && s.word[4] == 'r':
+		s.removePrefix(2, removedBER)
+	case s.startsWith("per"):
+		s.removePrefix(3, removedPE)
+	case len(s.word) == 7 && s.startsWith("pelajar"):
+		s.removePrefix(3, removedPE)
+	case s.startsWith("pe"):
+		s.removePrefix(2, removedPE)
+	}
+}
+
+func (s *stemmer) removeSuffix() {
+	switch {
+	case s.endsWith("kan") && s.flags&(removedKE|removedPENG|removedPE) == 0:
+		s.removeSuffixN(3)
+	case s.endsWith("an") && s.flags&(removedDI|removedMENG|removedTER) == 0:
+		s.removeSuffixN(2)
+	case s.endsWith("i") && !s.endsWith("si") && s.flags&(removedBER|removedKE|removedPENG) == 0:
+		s.removeSuffixN(1)
+	}
+}
+
+func StemmerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewIndonesianStemmerFilter(), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(StemmerName, StemmerFilterConstructor)
+}
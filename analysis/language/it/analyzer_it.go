@@ -10,6 +10,8 @@
 package it
 
 import (
+	"fmt"
+
 	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/registry"
 
@@ -19,7 +21,23 @@ import (
 
 const AnalyzerName = "it"
 
+// stemmers are the choices for the stemmer key of the
+// analyzer config, "none" disables stemming
+var stemmers = map[string]string{
+	"light": LightStemmerName,
+	"none":  "",
+}
+
 func AnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (*analysis.Analyzer, error) {
+	stemmer := "light"
+	stemmerVal, ok := config["stemmer"].(string)
+	if ok {
+		stemmer = stemmerVal
+	}
+	stemmerName, ok := stemmers[stemmer]
+	if !ok {
+		return nil, fmt.Errorf("unknown stemmer '%s' for analyzer %s", stemmer, AnalyzerName)
+	}
 	tokenizer, err := cache.TokenizerNamed(unicode.Name)
 	if err != nil {
 		return nil, err
@@ -36,19 +54,21 @@ func AnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (
 	if err != nil {
 		return nil, err
 	}
-	stemmerItFilter, err := cache.TokenFilterNamed(LightStemmerName)
-	if err != nil {
-		return nil, err
-	}
 	rv := analysis.Analyzer{
 		Tokenizer: tokenizer,
 		TokenFilters: []analysis.TokenFilter{
 			toLowerFilter,
 			elisionFilter,
 			stopItFilter,
-			stemmerItFilter,
 		},
 	}
+	if stemmerName != "" {
+		stemmerItFilter, err := cache.TokenFilterNamed(stemmerName)
+		if err != nil {
+			return nil, err
+		}
+		rv.TokenFilters = append(rv.TokenFilters, stemmerItFilter)
+	}
 	return &rv, nil
 }
 
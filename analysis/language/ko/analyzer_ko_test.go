@@ -0,0 +1,83 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package ko
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestKoreanAnalyzer(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output analysis.TokenStream
+	}{
+		{
+			input: []byte("사람들이 학교에서는 Bleve를 공부합니다"),
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("사람"),
+					Type:     analysis.AlphaNumeric,
+					Position: 1,
+					Start:    0,
+					End:      6,
+				},
+				&analysis.Token{
+					Term:     []byte("학교"),
+					Type:     analysis.AlphaNumeric,
+					Position: 4,
+					Start:    13,
+					End:      19,
+				},
+				&analysis.Token{
+					Term:     []byte("bleve"),
+					Type:     analysis.AlphaNumeric,
+					Position: 7,
+					Start:    29,
+					End:      34,
+				},
+				&analysis.Token{
+					Term:     []byte("공부"),
+					Type:     analysis.AlphaNumeric,
+					Position: 9,
+					Start:    38,
+					End:      44,
+				},
+			},
+		},
+		{
+			input: []byte("大韓民國"),
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("대한민국"),
+					Type:     analysis.Ideographic,
+					Position: 1,
+					Start:    0,
+					End:      12,
+				},
+			},
+		},
+	}
+
+	cache := registry.NewCache()
+	analyzer, err := cache.AnalyzerNamed(AnalyzerName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		actual := analyzer.Analyze(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v", test.output, actual)
+		}
+	}
+}
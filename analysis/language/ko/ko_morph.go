@@ -0,0 +1,384 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package ko
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/tokenizers/unicode"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const TokenizerName = "ko_morph"
+
+// part of speech tags of the morphemes found by the
+// tokenizer, following the Sejong tag set
+const (
+	TagNoun       = "N"
+	TagVerb       = "V"
+	TagParticle   = "J"
+	TagEnding     = "E"
+	TagVerbSuffix = "XSV"
+	TagNounSuffix = "XSN"
+	TagUnknown    = "UNK"
+)
+
+// by default only the content morphemes are kept
+var DefaultStopTags = []string{TagParticle, TagEnding, TagVerbSuffix, TagNounSuffix}
+
+// syllable requirements of a suffix on the
+// preceding syllable
+const (
+	afterAny = iota
+	afterConsonant
+	afterVowel
+	// vowel or ㄹ final, as for the particle 로
+	afterVowelOrRieul
+)
+
+type suffix struct {
+	form  []rune
+	after int
+	// the minimum number of syllables left in the stem
+	minStem int
+	tag     string
+}
+
+func newSuffixes(tag string, after, minStem int, forms ...string) []suffix {
+	rv := make([]suffix, len(forms))
+	for i, form := range forms {
+		rv[i] = suffix{
+			form:    []rune(form),
+			after:   after,
+			minStem: minStem,
+			tag:     tag,
+		}
+	}
+	return rv
+}
+
+type byFormLength []suffix
+
+func (s byFormLength) Len() int           { return len(s) }
+func (s byFormLength) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byFormLength) Less(i, j int) bool { return len(s[i].form) > len(s[j].form) }
+
+// longer suffixes must be tried first
+func sortSuffixes(suffixes []suffix) []suffix {
+	sort.Stable(byFormLength(suffixes))
+	return suffixes
+}
+
+var particles = sortSuffixes(concatSuffixes(
+	newSuffixes(TagParticle, afterConsonant, 1, "이", "은", "을", "과", "이나", "이랑", "이며", "이라도", "으로서", "으로써"),
+	newSuffixes(TagParticle, afterVowel, 1, "가", "는", "를", "와", "나", "랑", "며", "라도"),
+	newSuffixes(TagParticle, afterVowelOrRieul, 1, "로", "로서", "로써"),
+	newSuffixes(TagParticle, afterConsonant, 1, "으로"),
+	// common single syllable particles also end many
+	// words, so they require a longer stem
+	newSuffixes(TagParticle, afterAny, 2, "의", "에", "도", "만"),
+	newSuffixes(TagParticle, afterAny, 1, "에서", "에게", "에게서", "께서", "한테", "한테서", "까지",
+		"부터", "보다", "처럼", "마저", "조차", "밖에", "마다", "께"),
+))
+
+// particles which can be followed by another particle
+var innerParticles = map[string]bool{
+	"에": true, "에서": true, "에게": true, "에게서": true, "께서": true, "께": true,
+	"한테": true, "한테서": true, "까지": true, "부터": true, "로": true, "으로": true,
+	"로서": true, "으로서": true, "로써": true, "으로써": true, "보다": true, "처럼": true,
+	"마다": true, "만": true,
+}
+
+var nounSuffixes = newSuffixes(TagNounSuffix, afterAny, 1, "들")
+
+var verbSuffixes = sortSuffixes(concatSuffixes(
+	newSuffixes(TagVerbSuffix, afterAny, 2, "하다", "합니다", "합니까", "했다", "했습니다", "했어요", "해요",
+		"해서", "하고", "하는", "하여", "한다", "했던", "하면", "하지", "하게", "하기", "함", "할", "한",
+		"되다", "됩니다", "되었다", "됐다", "되는", "되어", "돼서", "되고", "된", "될"),
+))
+
+var endings = sortSuffixes(concatSuffixes(
+	newSuffixes(TagEnding, afterAny, 1, "습니다", "습니까", "었습니다", "았습니다", "였습니다", "겠습니다",
+		"었다", "았다", "였다", "었어요", "았어요", "는다", "어요", "아요", "어서", "아서", "지만",
+		"면서", "는데", "었던", "았던", "겠다", "세요"),
+	newSuffixes(TagEnding, afterConsonant, 1, "으면", "으며", "으세요"),
+))
+
+func concatSuffixes(lists ...[]suffix) []suffix {
+	rv := make([]suffix, 0)
+	for _, list := range lists {
+		rv = append(rv, list...)
+	}
+	return rv
+}
+
+const (
+	hangulBase   = 0xAC00
+	hangulLast   = 0xD7A3
+	hangulFinals = 28
+	hangulRieul  = 8
+	hangulBieup  = 17
+)
+
+func isHangul(r rune) bool {
+	return r >= hangulBase && r <= hangulLast
+}
+
+func finalConsonant(r rune) int {
+	return int(r-hangulBase) % hangulFinals
+}
+
+func (s *suffix) attaches(prev rune) bool {
+	if !isHangul(prev) {
+		// the pronunciation of other scripts is not
+		// known, as in "Bleve를"
+		return true
+	}
+	final := finalConsonant(prev)
+	switch s.after {
+	case afterConsonant:
+		return final != 0
+	case afterVowel:
+		return final == 0
+	case afterVowelOrRieul:
+		return final == 0 || final == hangulRieul
+	}
+	return true
+}
+
+// a morpheme is a part of an eojeol (a space separated
+// word), start and end are rune offsets in the eojeol
+type morpheme struct {
+	term  string
+	start int
+	end   int
+	tag   string
+}
+
+// KoreanMorphTokenizer segments Korean text into
+// morphemes.  Each eojeol found by the unicode tokenizer
+// is split into its stem and the particles, endings and
+// derivational suffixes attached to it, so "학교에서는"
+// becomes "학교", "에서" and "는".  Morphemes with one of
+// the stop tags are dropped, leaving a gap in the
+// positions.  Words in the user dictionary are never
+// split.
+type KoreanMorphTokenizer struct {
+	unicode  *unicode.UnicodeTokenizer
+	stopTags map[string]bool
+	userDict analysis.TokenMap
+}
+
+func NewKoreanMorphTokenizer(stopTags []string, userDict analysis.TokenMap) *KoreanMorphTokenizer {
+	rv := KoreanMorphTokenizer{
+		unicode:  unicode.NewUnicodeTokenizer(),
+		stopTags: make(map[string]bool, len(stopTags)),
+		userDict: userDict,
+	}
+	for _, tag := range stopTags {
+		rv.stopTags[tag] = true
+	}
+	return &rv
+}
+
+func (t *KoreanMorphTokenizer) Tokenize(input []byte) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input))
+
+	position := 1
+	for _, token := range mergeIdeographic(t.unicode.Tokenize(input)) {
+		runes := []rune(string(token.Term))
+		if !isHangul(runes[len(runes)-1]) {
+			token.Position = position
+			rv = append(rv, token)
+			position++
+			continue
+		}
+
+		// byte offsets of each rune, for the token offsets
+		offsets := make([]int, len(runes)+1)
+		for i, r := range runes {
+			offsets[i+1] = offsets[i] + utf8.RuneLen(r)
+		}
+
+		for _, m := range t.analyze(runes) {
+			if !t.stopTags[m.tag] {
+				newtoken := analysis.Token{
+					Term:     []byte(m.term),
+					Position: position,
+					Start:    token.Start + offsets[m.start],
+					End:      token.Start + offsets[m.end],
+					Type:     analysis.AlphaNumeric,
+				}
+				rv = append(rv, &newtoken)
+			}
+			position++
+		}
+	}
+
+	return rv
+}
+
+// mergeIdeographic joins adjacent ideographic tokens, so
+// that Hanja words are kept together
+func mergeIdeographic(input analysis.TokenStream) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input))
+	for _, token := range input {
+		if len(rv) > 0 {
+			last := rv[len(rv)-1]
+			if token.Type == analysis.Ideographic && last.Type == analysis.Ideographic && last.End == token.Start {
+				term := make([]byte, 0, len(last.Term)+len(token.Term))
+				term = append(term, last.Term...)
+				last.Term = append(term, token.Term...)
+				last.End = token.End
+				continue
+			}
+		}
+		rv = append(rv, token)
+	}
+	return rv
+}
+
+func (t *KoreanMorphTokenizer) analyze(word []rune) []morpheme {
+	if t.userDict != nil {
+		if _, ok := t.userDict[string(word)]; ok {
+			return []morpheme{{term: string(word), start: 0, end: len(word), tag: TagNoun}}
+		}
+	}
+
+	// suffixes are found from the end of the eojeol
+	tail := make([]morpheme, 0)
+	stemTag := TagUnknown
+	end := len(word)
+	bieup := false
+
+	if m, ok := matchSuffix(word[:end], verbSuffixes); ok {
+		tail = append(tail, m)
+		end = m.start
+		stemTag = TagNoun
+	} else if m, ok := matchBieupEnding(word[:end]); ok {
+		tail = append(tail, m)
+		end = m.start
+		stemTag = TagVerb
+		bieup = true
+	} else if m, ok := matchSuffix(word[:end], endings); ok {
+		tail = append(tail, m)
+		end = m.start
+		stemTag = TagVerb
+	} else {
+		// up to two particles, as in "에서는"
+		for i := 0; i < 2; i++ {
+			m, ok := matchSuffix(word[:end], particles)
+			if !ok || (i > 0 && !innerParticles[m.term]) {
+				break
+			}
+			tail = append(tail, m)
+			end = m.start
+			stemTag = TagNoun
+		}
+		if m, ok := matchSuffix(word[:end], nounSuffixes); ok {
+			tail = append(tail, m)
+			end = m.start
+			stemTag = TagNoun
+		}
+	}
+
+	stem := morpheme{
+		term:  string(word[:end]),
+		start: 0,
+		end:   end,
+		tag:   stemTag,
+	}
+	if bieup {
+		// the stem syllable without its ㅂ final
+		stem.term = string(word[:end-1]) + string(word[end-1]-hangulBieup)
+	}
+
+	rv := []morpheme{stem}
+	for i := len(tail) - 1; i >= 0; i-- {
+		rv = append(rv, tail[i])
+	}
+	return rv
+}
+
+func matchSuffix(word []rune, suffixes []suffix) (morpheme, bool) {
+	for _, s := range suffixes {
+		start := len(word) - len(s.form)
+		if start < s.minStem {
+			continue
+		}
+		if string(word[start:]) != string(s.form) {
+			continue
+		}
+		if !s.attaches(word[start-1]) {
+			continue
+		}
+		return morpheme{
+			term:  string(s.form),
+			start: start,
+			end:   len(word),
+			tag:   s.tag,
+		}, true
+	}
+	return morpheme{}, false
+}
+
+// matchBieupEnding finds the formal ending ㅂ니다, which
+// is written as the final consonant of the preceding
+// syllable, as in "갑니다"
+func matchBieupEnding(word []rune) (morpheme, bool) {
+	if len(word) < 3 || string(word[len(word)-2:]) != "니다" {
+		return morpheme{}, false
+	}
+	prev := word[len(word)-3]
+	if !isHangul(prev) || finalConsonant(prev) != hangulBieup {
+		return morpheme{}, false
+	}
+	// the ending starts in the preceding syllable, the
+	// stem keeps that syllable in its offsets
+	return morpheme{
+		term:  "니다",
+		start: len(word) - 2,
+		end:   len(word),
+		tag:   TagEnding,
+	}, true
+}
+
+func KoreanMorphTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+	stopTags := DefaultStopTags
+	stopTagsVal, ok := config["stop_tags"].([]interface{})
+	if ok {
+		stopTags = make([]string, 0, len(stopTagsVal))
+		for _, tagVal := range stopTagsVal {
+			tag, ok := tagVal.(string)
+			if !ok {
+				return nil, fmt.Errorf("stop tags must be strings")
+			}
+			stopTags = append(stopTags, tag)
+		}
+	}
+
+	var userDict analysis.TokenMap
+	userDictName, ok := config["user_dictionary"].(string)
+	if ok {
+		var err error
+		userDict, err = cache.TokenMapNamed(userDictName)
+		if err != nil {
+			return nil, fmt.Errorf("error building korean tokenizer: %v", err)
+		}
+	}
+	return NewKoreanMorphTokenizer(stopTags, userDict), nil
+}
+
+func init() {
+	registry.RegisterTokenizer(TokenizerName, KoreanMorphTokenizerConstructor)
+}
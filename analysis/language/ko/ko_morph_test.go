@@ -0,0 +1,129 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package ko
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+func TestKoreanMorphTokenizer(t *testing.T) {
+
+	tests := []struct {
+		input    []byte
+		stopTags []string
+		userDict analysis.TokenMap
+		output   analysis.TokenStream
+	}{
+		// particles only attach to matching syllables, so
+		// the final 이 of 고양이 is kept
+		{
+			input: []byte("고양이가 사과를 먹었다"),
+			output: analysis.TokenStream{
+				{
+					Term:     []byte("고양이"),
+					Position: 1,
+					Start:    0,
+					End:      9,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Term:     []byte("가"),
+					Position: 2,
+					Start:    9,
+					End:      12,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Term:     []byte("사과"),
+					Position: 3,
+					Start:    13,
+					End:      19,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Term:     []byte("를"),
+					Position: 4,
+					Start:    19,
+					End:      22,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Term:     []byte("먹"),
+					Position: 5,
+					Start:    23,
+					End:      26,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Term:     []byte("었다"),
+					Position: 6,
+					Start:    26,
+					End:      32,
+					Type:     analysis.AlphaNumeric,
+				},
+			},
+		},
+		// the ㅂ final of the formal ending is removed from
+		// the stem
+		{
+			input:    []byte("학교에 갑니다"),
+			stopTags: DefaultStopTags,
+			output: analysis.TokenStream{
+				{
+					Term:     []byte("학교"),
+					Position: 1,
+					Start:    0,
+					End:      6,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Term:     []byte("가"),
+					Position: 3,
+					Start:    10,
+					End:      13,
+					Type:     analysis.AlphaNumeric,
+				},
+			},
+		},
+		// user dictionary words are not split
+		{
+			input:    []byte("고양이 포도도"),
+			stopTags: DefaultStopTags,
+			userDict: analysis.TokenMap{"고양이": true},
+			output: analysis.TokenStream{
+				{
+					Term:     []byte("고양이"),
+					Position: 1,
+					Start:    0,
+					End:      9,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Term:     []byte("포도"),
+					Position: 2,
+					Start:    10,
+					End:      16,
+					Type:     analysis.AlphaNumeric,
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		tokenizer := NewKoreanMorphTokenizer(test.stopTags, test.userDict)
+		actual := tokenizer.Tokenize(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v for %s", test.output, actual, string(test.input))
+		}
+	}
+}
@@ -0,0 +1,142 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package ko
+
+import (
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const ReadingFormName = "ko_reading_form"
+
+// hanjaReadings holds the Hangul readings of common
+// Hanja, before the initial sound rule is applied
+var hanjaReadings = map[rune]rune{
+	'一': '일', '二': '이', '三': '삼', '四': '사', '五': '오',
+	'六': '육', '七': '칠', '八': '팔', '九': '구', '十': '십',
+	'百': '백', '千': '천', '萬': '만', '万': '만',
+	'大': '대', '小': '소', '中': '중', '上': '상', '下': '하',
+	'韓': '한', '漢': '한', '民': '민', '國': '국', '国': '국',
+	'人': '인', '學': '학', '学': '학', '校': '교', '生': '생',
+	'日': '일', '月': '월', '火': '화', '水': '수', '木': '목',
+	'金': '금', '土': '토', '年': '년', '時': '시', '間': '간',
+	'分': '분', '左': '좌', '右': '우', '東': '동', '西': '서',
+	'南': '남', '北': '북', '前': '전', '後': '후', '內': '내',
+	'外': '외', '父': '부', '母': '모', '子': '자', '女': '녀',
+	'男': '남', '兄': '형', '弟': '제', '家': '가', '門': '문',
+	'口': '구', '手': '수', '目': '목', '耳': '이', '心': '심',
+	'力': '력', '天': '천', '地': '지', '山': '산', '川': '천',
+	'江': '강', '海': '해', '林': '림', '花': '화', '草': '초',
+	'石': '석', '王': '왕', '長': '장', '白': '백', '靑': '청',
+	'青': '청', '赤': '적', '黑': '흑', '文': '문', '字': '자',
+	'語': '어', '言': '언', '書': '서', '讀': '독', '新': '신',
+	'聞': '문', '社': '사', '會': '회', '政': '정', '治': '치',
+	'經': '경', '濟': '제', '法': '법', '律': '률', '自': '자',
+	'然': '연', '科': '과', '技': '기', '術': '술', '電': '전',
+	'話': '화', '車': '차', '道': '도', '路': '로', '市': '시',
+	'都': '도', '京': '경', '城': '성', '村': '촌', '物': '물',
+	'事': '사', '業': '업', '工': '공', '場': '장', '所': '소',
+	'方': '방', '向': '향', '面': '면', '問': '문', '題': '제',
+	'答': '답', '名': '명', '世': '세', '界': '계', '全': '전',
+	'部': '부', '意': '의', '味': '미', '思': '사', '考': '고',
+	'愛': '애', '情': '정', '感': '감', '美': '미', '高': '고',
+	'低': '저', '多': '다', '少': '소', '古': '고', '今': '금',
+	'正': '정', '直': '직', '公': '공', '共': '공', '動': '동',
+	'開': '개', '出': '출', '入': '입', '來': '래', '去': '거',
+	'行': '행', '走': '주', '見': '견', '食': '식', '住': '주',
+	'衣': '의', '際': '제', '平': '평', '和': '화', '戰': '전',
+	'爭': '쟁', '安': '안', '重': '중', '要': '요', '主': '주',
+	'義': '의', '族': '족', '歷': '력', '史': '사', '化': '화',
+	'敎': '교', '教': '교', '育': '육', '先': '선', '院': '원',
+	'病': '병', '醫': '의', '藥': '약', '信': '신', '用': '용',
+	'銀': '은', '不': '불', '無': '무', '有': '유', '同': '동',
+	'異': '이', '氣': '기', '空': '공', '風': '풍', '雨': '우',
+	'雪': '설', '光': '광', '明': '명', '音': '음', '樂': '악',
+	'歌': '가', '詩': '시', '代': '대',
+}
+
+const (
+	hangulMedials = 21
+
+	initialNieun = 2
+	initialRieul = 5
+	initialIeung = 11
+)
+
+// vowels before which an initial ㄴ or ㄹ becomes ㅇ
+var iotizedVowels = map[int]bool{
+	2:  true, // ㅑ
+	3:  true, // ㅒ
+	6:  true, // ㅕ
+	7:  true, // ㅖ
+	12: true, // ㅛ
+	17: true, // ㅠ
+	20: true, // ㅣ
+}
+
+// initialSoundRule applies the South Korean initial sound
+// rule to the first syllable of a word, so 年 at the
+// start of a word is read 연 rather than 년
+func initialSoundRule(r rune) rune {
+	index := int(r - hangulBase)
+	initial := index / (hangulMedials * hangulFinals)
+	medial := (index / hangulFinals) % hangulMedials
+	final := index % hangulFinals
+	switch {
+	case initial == initialNieun && iotizedVowels[medial]:
+		initial = initialIeung
+	case initial == initialRieul && iotizedVowels[medial]:
+		initial = initialIeung
+	case initial == initialRieul:
+		initial = initialNieun
+	}
+	return rune(hangulBase + (initial*hangulMedials+medial)*hangulFinals + final)
+}
+
+// ReadingFormFilter replaces Hanja with their Hangul
+// readings, so that words written in either script
+// match each other.  Hanja without a known reading are
+// left unchanged.
+type ReadingFormFilter struct {
+}
+
+func NewReadingFormFilter() *ReadingFormFilter {
+	return &ReadingFormFilter{}
+}
+
+func (s *ReadingFormFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		runes := []rune(string(token.Term))
+		changed := false
+		for i, r := range runes {
+			reading, ok := hanjaReadings[r]
+			if !ok {
+				continue
+			}
+			if i == 0 {
+				reading = initialSoundRule(reading)
+			}
+			runes[i] = reading
+			changed = true
+		}
+		if changed {
+			token.Term = []byte(string(runes))
+		}
+	}
+	return input
+}
+
+func ReadingFormFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewReadingFormFilter(), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(ReadingFormName, ReadingFormFilterConstructor)
+}
@@ -0,0 +1,76 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package ko
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+func TestReadingFormFilter(t *testing.T) {
+	tests := []struct {
+		input  analysis.TokenStream
+		output analysis.TokenStream
+	}{
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term: []byte("大韓民國"),
+				},
+				// initial sound rule
+				&analysis.Token{
+					Term: []byte("年代"),
+				},
+				&analysis.Token{
+					Term: []byte("來日"),
+				},
+				&analysis.Token{
+					Term: []byte("靑年"),
+				},
+				&analysis.Token{
+					Term: []byte("서울"),
+				},
+				&analysis.Token{
+					Term: []byte("鬱"),
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term: []byte("대한민국"),
+				},
+				&analysis.Token{
+					Term: []byte("연대"),
+				},
+				&analysis.Token{
+					Term: []byte("내일"),
+				},
+				&analysis.Token{
+					Term: []byte("청년"),
+				},
+				&analysis.Token{
+					Term: []byte("서울"),
+				},
+				&analysis.Token{
+					Term: []byte("鬱"),
+				},
+			},
+		},
+	}
+
+	filter := NewReadingFormFilter()
+	for _, test := range tests {
+		actual := filter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %s, got %s", test.output, actual)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package oc
+
+import (
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const ArticlesName = "articles_oc"
+
+// the elided forms of the articles, pronouns and
+// prepositions of Occitan
+
+var OccitanArticles = []byte(`
+d
+l
+m
+n
+qu
+s
+t
+`)
+
+func ArticlesTokenMapConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenMap, error) {
+	rv := analysis.NewTokenMap()
+	err := rv.LoadBytes(OccitanArticles)
+	return rv, err
+}
+
+func init() {
+	registry.RegisterTokenMap(ArticlesName, ArticlesTokenMapConstructor)
+}
@@ -0,0 +1,126 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pl
+
+import (
+	"bytes"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const StemmerName = "stemmer_pl"
+
+// PolishStemmerFilter is an algorithmic suffix stripping
+// stemmer for Polish.  It removes the common noun,
+// diminutive, adjective, verb, adverb and plural
+// endings in turn, each step only applying to words
+// long enough to keep a meaningful stem.
+type PolishStemmerFilter struct {
+}
+
+func NewPolishStemmerFilter() *PolishStemmerFilter {
+	return &PolishStemmerFilter{}
+}
+
+func (s *PolishStemmerFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		// if not protected keyword, stem it
+		if !token.KeyWord {
+			runes := bytes.Runes(token.Term)
+			runes = stem(runes)
+			token.Term = analysis.BuildTermFromRunes(runes)
+		}
+	}
+	return input
+}
+
+// a suffixRule removes strip runes from words with at
+// least minLength runes ending in one of the suffixes
+type suffixRule struct {
+	minLength int
+	strip     int
+	suffixes  []string
+}
+
+// each step applies at most one of its rules
+var stemmerSteps = [][]suffixRule{
+	// nouns
+	{
+		{minLength: 9, strip: 6, suffixes: []string{"zacjami", "zacjach"}},
+		{minLength: 8, strip: 5, suffixes: []string{"acjami", "acjach"}},
+		{minLength: 7, strip: 4, suffixes: []string{"zacja", "zacją", "zacji"}},
+		{minLength: 6, strip: 4, suffixes: []string{"acja", "acji", "acją", "anie", "enie", "eniu", "aniu"}},
+		{minLength: 6, strip: 2, suffixes: []string{"tyka"}},
+		{minLength: 5, strip: 3, suffixes: []string{"ach", "ami", "nia", "niu", "cia", "ciu"}},
+		{minLength: 5, strip: 2, suffixes: []string{"cji", "cja", "cją"}},
+	},
+	// diminutives
+	{
+		{minLength: 7, strip: 5, suffixes: []string{"eczek", "iczek", "iszek", "aszek", "uszek"}},
+		{minLength: 7, strip: 2, suffixes: []string{"enek", "ejek", "erek"}},
+		{minLength: 6, strip: 2, suffixes: []string{"ek", "ak"}},
+	},
+	// adjectives
+	{
+		{minLength: 7, strip: 4, suffixes: []string{"czny", "czna", "czne"}},
+		{minLength: 6, strip: 3, suffixes: []string{"owy", "owa", "owe", "ych", "ego", "emu", "ymi", "imi"}},
+		{minLength: 5, strip: 2, suffixes: []string{"ej", "ym", "im"}},
+		{minLength: 5, strip: 1, suffixes: []string{"ą"}},
+	},
+	// verbs
+	{
+		{minLength: 6, strip: 3, suffixes: []string{"bym", "esz", "asz", "cie", "eść", "aść", "łem", "amy", "emy"}},
+		{minLength: 5, strip: 2, suffixes: []string{"eć", "ać", "em", "am", "ał", "ił", "ić", "ąc"}},
+		{minLength: 5, strip: 1, suffixes: []string{"aj"}},
+	},
+	// adverbs
+	{
+		{minLength: 6, strip: 2, suffixes: []string{"nie", "wie", "rze"}},
+	},
+	// plurals
+	{
+		{minLength: 6, strip: 2, suffixes: []string{"ów", "om"}},
+	},
+	// general endings
+	{
+		{minLength: 5, strip: 2, suffixes: []string{"ia", "ie"}},
+		{minLength: 5, strip: 1, suffixes: []string{"a", "ą", "e", "ę", "i", "o", "u", "y"}},
+	},
+}
+
+func stem(input []rune) []rune {
+	for _, step := range stemmerSteps {
+		input = applyStep(input, step)
+	}
+	return input
+}
+
+func applyStep(input []rune, step []suffixRule) []rune {
+	for _, rule := range step {
+		if len(input) < rule.minLength {
+			continue
+		}
+		for _, suffix := range rule.suffixes {
+			if analysis.RunesEndsWith(input, suffix) {
+				return input[:len(input)-rule.strip]
+			}
+		}
+	}
+	return input
+}
+
+func PolishStemmerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewPolishStemmerFilter(), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(StemmerName, PolishStemmerFilterConstructor)
+}
@@ -10,6 +10,8 @@
 package pt
 
 import (
+	"fmt"
+
 	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/registry"
 
@@ -19,7 +21,23 @@ import (
 
 const AnalyzerName = "pt"
 
+// stemmers are the choices for the stemmer key of the
+// analyzer config, "none" disables stemming
+var stemmers = map[string]string{
+	"light": LightStemmerName,
+	"none":  "",
+}
+
 func AnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (*analysis.Analyzer, error) {
+	stemmer := "light"
+	stemmerVal, ok := config["stemmer"].(string)
+	if ok {
+		stemmer = stemmerVal
+	}
+	stemmerName, ok := stemmers[stemmer]
+	if !ok {
+		return nil, fmt.Errorf("unknown stemmer '%s' for analyzer %s", stemmer, AnalyzerName)
+	}
 	tokenizer, err := cache.TokenizerNamed(unicode.Name)
 	if err != nil {
 		return nil, err
@@ -32,18 +50,20 @@ func AnalyzerConstructor(config map[string]interface{}, cache *registry.Cache) (
 	if err != nil {
 		return nil, err
 	}
-	stemmerPtFilter, err := cache.TokenFilterNamed(LightStemmerName)
-	if err != nil {
-		return nil, err
-	}
 	rv := analysis.Analyzer{
 		Tokenizer: tokenizer,
 		TokenFilters: []analysis.TokenFilter{
 			toLowerFilter,
 			stopPtFilter,
-			stemmerPtFilter,
 		},
 	}
+	if stemmerName != "" {
+		stemmerPtFilter, err := cache.TokenFilterNamed(stemmerName)
+		if err != nil {
+			return nil, err
+		}
+		rv.TokenFilters = append(rv.TokenFilters, stemmerPtFilter)
+	}
 	return &rv, nil
 }
 
@@ -0,0 +1,73 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package th
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/token_map"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestThaiAnalyzer(t *testing.T) {
+	tests := []struct {
+		tokenMap map[string]interface{}
+		input    []byte
+		output   analysis.TokenStream
+	}{
+		{
+			input: []byte("สวัสดีคุณสมชาย Bleve"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("สวัสดี")},
+				&analysis.Token{Term: []byte("คุณ")},
+				&analysis.Token{Term: []byte("สม")},
+				&analysis.Token{Term: []byte("ชาย")},
+				&analysis.Token{Term: []byte("bleve")},
+			},
+		},
+		// a full dictionary replaces the built in one
+		{
+			tokenMap: map[string]interface{}{
+				"type":   token_map.Name,
+				"tokens": []interface{}{"สวัสดี", "คุณ", "สมชาย"},
+			},
+			input: []byte("สวัสดีคุณสมชาย"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("สวัสดี")},
+				&analysis.Token{Term: []byte("คุณ")},
+				&analysis.Token{Term: []byte("สมชาย")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		cache := registry.NewCache()
+		if test.tokenMap != nil {
+			_, err := cache.DefineTokenMap(DictionaryName, test.tokenMap)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		analyzer, err := cache.AnalyzerNamed(AnalyzerName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := analyzer.Analyze(test.input)
+		terms := make(analysis.TokenStream, len(actual))
+		for i, token := range actual {
+			terms[i] = &analysis.Token{Term: token.Term}
+		}
+		if !reflect.DeepEqual(terms, test.output) {
+			t.Errorf("expected %v, got %v", test.output, terms)
+		}
+	}
+}
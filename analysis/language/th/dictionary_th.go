@@ -0,0 +1,61 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package th
+
+import (
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const DictionaryName = "dictionary_th"
+
+// ThaiDictionary is only a stub of common thai words, far
+// too small to segment real text well.  A full word list,
+// like the thai dictionary of ICU or libthai, one word per
+// line, replaces it when the mapping defines a custom token
+// map named dictionary_th with its filename, which the th
+// analyzer and the dictionary_th tokenizer then use.  A
+// single tokenizer can also add words with the words and
+// dictionary_file options.
+var ThaiDictionary = []byte(`
+การ ความ ที่ ซึ่ง อัน และ หรือ แต่ ก็ จึง เพราะ ถ้า หาก แม้ ว่า ให้ กับ แก่ แด่ ต่อ
+ของ ใน บน ใต้ นอก ระหว่าง จาก ถึง จน โดย เพื่อ ด้วย ตาม ตั้งแต่ สำหรับ เกี่ยวกับ
+เป็น อยู่ คือ มี ได้ ไม่ จะ ได้รับ เคย กำลัง ยัง แล้ว ต้อง ควร อาจ อยาก สามารถ
+นี้ นั้น โน้น นี่ นั่น ไหน อะไร ใคร ทำไม อย่างไร เมื่อไร เท่าไร ที่ไหน
+ผม ฉัน ดิฉัน เรา คุณ ท่าน เขา เธอ มัน พวก พวกเขา พวกเรา ตัวเอง
+คน ผู้ ชาย หญิง เด็ก ผู้ใหญ่ แม่ พ่อ ลูก พี่ น้อง เพื่อน ครอบครัว ครู นักเรียน
+ไป มา กิน ดื่ม นอน ตื่น เดิน วิ่ง นั่ง ยืน พูด ฟัง ดู เห็น อ่าน เขียน เรียน สอน
+ทำ ทำงาน งาน ใช้ ซื้อ ขาย จ่าย เปิด ปิด เข้า ออก ขึ้น ลง รู้ รู้จัก เข้าใจ คิด ชอบ รัก
+ช่วย บอก ถาม ตอบ เล่น หา ค้นหา ส่ง รับ เก็บ เริ่ม จบ เปลี่ยน สร้าง
+ดี เลว มาก น้อย ใหญ่ เล็ก ยาว สั้น สูง ต่ำ ร้อน เย็น หนาว ใหม่ เก่า สวย ง่าย ยาก
+เร็ว ช้า ใกล้ ไกล ถูก แพง สบาย สบายดี สำคัญ
+ทุก บาง หลาย อื่น เดียว กัน เอง อีก เลย ก่อน หลัง แรก สุด
+หนึ่ง สอง สาม สี่ ห้า หก เจ็ด แปด เก้า สิบ ยี่สิบ ร้อย พัน หมื่น แสน ล้าน
+วัน คืน เช้า สาย บ่าย เย็น กลางคืน วันนี้ พรุ่งนี้ เมื่อวาน เวลา ปี เดือน สัปดาห์ ชั่วโมง นาที
+บ้าน ห้อง โรง เรียน โรงเรียน โรงพยาบาล ตลาด ร้าน ร้านอาหาร ถนน เมือง ประเทศ โลก
+รถ รถไฟ เรือ เครื่องบิน ทาง น้ำ ไฟ ข้าว อาหาร ผลไม้ นม ปลา ไก่ หมู
+ภาษา ไทย อังกฤษ จีน ญี่ปุ่น กรุงเทพ ประเทศไทย คนไทย ภาษาไทย
+หนังสือ คำ ประโยค เรื่อง ข่าว ข้อมูล ระบบ โปรแกรม คอมพิวเตอร์ โทรศัพท์ อินเทอร์เน็ต
+ภาพ ภาพยนตร์ เพลง ดนตรี กีฬา ฟุตบอล
+ใจ หัวใจ ตา หู ปาก มือ เท้า หัว ตัว
+เงิน ราคา บาท ธนาคาร บริษัท รัฐบาล นายก
+สี แดง ขาว ดำ เขียว เหลือง ฟ้า
+สวัสดี ขอบคุณ ขอโทษ ครับ ค่ะ คะ นะ จ้ะ ไหม
+`)
+
+func TokenMapConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenMap, error) {
+	rv := analysis.NewTokenMap()
+	err := rv.LoadBytes(ThaiDictionary)
+	return rv, err
+}
+
+func init() {
+	registry.RegisterTokenMap(DictionaryName, TokenMapConstructor)
+}
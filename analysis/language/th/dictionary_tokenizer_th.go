@@ -0,0 +1,210 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package th
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/tokenizers/unicode"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const TokenizerName = "dictionary_th"
+
+// ThaiDictionaryTokenizer segments runs of thai text into
+// words using a dictionary, preferring the segmentation
+// with the fewest characters not covered by a word and
+// then the fewest words.  Characters not covered by any
+// word are kept together as a single token.  The rest of
+// the input is handled by the remaining tokenizer.
+type ThaiDictionaryTokenizer struct {
+	dictionary analysis.TokenMap
+	// longest word in the dictionary, in runes
+	maxWordLength int
+	remaining     analysis.Tokenizer
+}
+
+func NewThaiDictionaryTokenizer(dictionary analysis.TokenMap, remaining analysis.Tokenizer) *ThaiDictionaryTokenizer {
+	rv := ThaiDictionaryTokenizer{
+		dictionary: dictionary,
+		remaining:  remaining,
+	}
+	for word := range dictionary {
+		wordLength := utf8.RuneCountInString(word)
+		if wordLength > rv.maxWordLength {
+			rv.maxWordLength = wordLength
+		}
+	}
+	return &rv
+}
+
+// isThai reports whether r is in the thai block
+func isThai(r rune) bool {
+	return r >= 0x0e00 && r <= 0x0e7f
+}
+
+func (t *ThaiDictionaryTokenizer) Tokenize(input []byte) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0)
+	start := 0
+	for start < len(input) {
+		r, _ := utf8.DecodeRune(input[start:])
+		thai := isThai(r)
+		end := start
+		for end < len(input) {
+			r, size := utf8.DecodeRune(input[end:])
+			if isThai(r) != thai {
+				break
+			}
+			end += size
+		}
+		if thai {
+			rv = t.appendThai(rv, input, start, end)
+		} else {
+			rv = t.appendRemaining(rv, input, start, end)
+		}
+		start = end
+	}
+	return rv
+}
+
+// segmentation cost, compared by unknown characters first
+type cost struct {
+	unknown int
+	words   int
+}
+
+func (c cost) less(o cost) bool {
+	return c.unknown < o.unknown || (c.unknown == o.unknown && c.words < o.words)
+}
+
+func (t *ThaiDictionaryTokenizer) appendThai(rv analysis.TokenStream, input []byte, start, end int) analysis.TokenStream {
+	// byte offsets of every rune, and the end
+	offsets := make([]int, 0, end-start+1)
+	for i := start; i < end; {
+		offsets = append(offsets, i)
+		_, size := utf8.DecodeRune(input[i:end])
+		i += size
+	}
+	offsets = append(offsets, end)
+	n := len(offsets) - 1
+
+	// best[i] is the cheapest segmentation of the first i
+	// runes, from[i] where its last segment starts
+	best := make([]cost, n+1)
+	from := make([]int, n+1)
+	known := make([]bool, n+1)
+	for i := 1; i <= n; i++ {
+		// a single character not covered by a word
+		best[i] = cost{best[i-1].unknown + 1, best[i-1].words + 1}
+		from[i] = i - 1
+		known[i] = false
+		for j := i - 1; j >= 0 && i-j <= t.maxWordLength; j-- {
+			if !t.dictionary[string(input[offsets[j]:offsets[i]])] {
+				continue
+			}
+			c := cost{best[j].unknown, best[j].words + 1}
+			if c.less(best[i]) {
+				best[i] = c
+				from[i] = j
+				known[i] = true
+			}
+		}
+	}
+
+	// walk back to recover the segments, merging
+	// neighbouring unknown characters
+	var segments [][2]int
+	for i := n; i > 0; i = from[i] {
+		j := from[i]
+		if !known[i] && len(segments) > 0 {
+			last := &segments[len(segments)-1]
+			if !known[last[1]] && last[0] == i {
+				last[0] = j
+				continue
+			}
+		}
+		segments = append(segments, [2]int{j, i})
+	}
+
+	for k := len(segments) - 1; k >= 0; k-- {
+		segmentStart := offsets[segments[k][0]]
+		segmentEnd := offsets[segments[k][1]]
+		rv = append(rv, &analysis.Token{
+			Term:     input[segmentStart:segmentEnd],
+			Start:    segmentStart,
+			End:      segmentEnd,
+			Position: len(rv) + 1,
+			Type:     analysis.AlphaNumeric,
+		})
+	}
+	return rv
+}
+
+func (t *ThaiDictionaryTokenizer) appendRemaining(rv analysis.TokenStream, input []byte, start, end int) analysis.TokenStream {
+	lastPos := len(rv)
+	for _, token := range t.remaining.Tokenize(input[start:end]) {
+		token.Position += lastPos
+		token.Start += start
+		token.End += start
+		rv = append(rv, token)
+	}
+	return rv
+}
+
+func ThaiDictionaryTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+	dictionaryName := DictionaryName
+	dictionaryNameVal, ok := config["dictionary_token_map"].(string)
+	if ok {
+		dictionaryName = dictionaryNameVal
+	}
+	builtin, err := cache.TokenMapNamed(dictionaryName)
+	if err != nil {
+		return nil, fmt.Errorf("error building thai tokenizer: %v", err)
+	}
+	// copy, so the user words do not change the cached map
+	dictionary := analysis.NewTokenMap()
+	for word := range builtin {
+		dictionary.AddToken(word)
+	}
+	words, ok := config["words"].([]interface{})
+	if ok {
+		for _, word := range words {
+			wordStr, ok := word.(string)
+			if !ok {
+				return nil, fmt.Errorf("word must be a string")
+			}
+			dictionary.AddToken(wordStr)
+		}
+	}
+	dictionaryFile, ok := config["dictionary_file"].(string)
+	if ok {
+		err := dictionary.LoadFile(dictionaryFile)
+		if err != nil {
+			return nil, fmt.Errorf("error building thai tokenizer: %v", err)
+		}
+	}
+
+	remainingName := unicode.Name
+	remainingNameVal, ok := config["tokenizer"].(string)
+	if ok {
+		remainingName = remainingNameVal
+	}
+	remaining, err := cache.TokenizerNamed(remainingName)
+	if err != nil {
+		return nil, err
+	}
+	return NewThaiDictionaryTokenizer(dictionary, remaining), nil
+}
+
+func init() {
+	registry.RegisterTokenizer(TokenizerName, ThaiDictionaryTokenizerConstructor)
+}
@@ -0,0 +1,74 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package th
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestThaiDictionaryTokenizer(t *testing.T) {
+	tests := []struct {
+		config map[string]interface{}
+		input  []byte
+		output analysis.TokenStream
+	}{
+		{
+			config: map[string]interface{}{},
+			input:  []byte("ฉันกินข้าวที่ร้านอาหาร Bleve"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("ฉัน"), Start: 0, End: 9, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("กิน"), Start: 9, End: 18, Position: 2, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("ข้าว"), Start: 18, End: 30, Position: 3, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("ที่"), Start: 30, End: 39, Position: 4, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("ร้านอาหาร"), Start: 39, End: 66, Position: 5, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("Bleve"), Start: 67, End: 72, Position: 6, Type: analysis.AlphaNumeric},
+			},
+		},
+		// characters not in the dictionary are kept together
+		{
+			config: map[string]interface{}{},
+			input:  []byte("สวัสดีคุณสมชาย"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("สวัสดี"), Start: 0, End: 18, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("คุณ"), Start: 18, End: 27, Position: 2, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("สม"), Start: 27, End: 33, Position: 3, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("ชาย"), Start: 33, End: 42, Position: 4, Type: analysis.AlphaNumeric},
+			},
+		},
+		// user dictionary
+		{
+			config: map[string]interface{}{
+				"words": []interface{}{"สมชาย"},
+			},
+			input: []byte("สวัสดีคุณสมชาย"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("สวัสดี"), Start: 0, End: 18, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("คุณ"), Start: 18, End: 27, Position: 2, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("สมชาย"), Start: 27, End: 42, Position: 3, Type: analysis.AlphaNumeric},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		cache := registry.NewCache()
+		tokenizer, err := ThaiDictionaryTokenizerConstructor(test.config, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := tokenizer.Tokenize(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v", test.output, actual)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package uk
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestUkrainianAnalyzer(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output analysis.TokenStream
+	}{
+		// lowercasing and stemming
+		{
+			input: []byte("України"),
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term: []byte("україн"),
+				},
+			},
+		},
+		// apostrophes
+		{
+			input: []byte("м’ясо"),
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term: []byte("м'яс"),
+				},
+			},
+		},
+		// stop word
+		{
+			input:  []byte("які"),
+			output: analysis.TokenStream{},
+		},
+	}
+
+	cache := registry.NewCache()
+	analyzer, err := cache.AnalyzerNamed(AnalyzerName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		actual := analyzer.Analyze(test.input)
+		if len(actual) != len(test.output) {
+			t.Fatalf("expected length: %d, got %d", len(test.output), len(actual))
+		}
+		for i, tok := range actual {
+			if !reflect.DeepEqual(tok.Term, test.output[i].Term) {
+				t.Errorf("expected term %s (% x) got %s (% x)", test.output[i].Term, test.output[i].Term, tok.Term, tok.Term)
+			}
+		}
+	}
+}
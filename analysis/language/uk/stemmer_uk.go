@@ -0,0 +1,142 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package uk
+
+import (
+	"bytes"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const StemmerName = "stemmer_uk"
+
+// UkrainianStemmerFilter is a suffix stripping stemmer
+// modelled on the snowball russian stemmer.  Endings are
+// only removed from the part of the word after its
+// first vowel.
+type UkrainianStemmerFilter struct {
+}
+
+func NewUkrainianStemmerFilter() *UkrainianStemmerFilter {
+	return &UkrainianStemmerFilter{}
+}
+
+func (s *UkrainianStemmerFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		// if not protected keyword, stem it
+		if !token.KeyWord {
+			runes := bytes.Runes(token.Term)
+			runes = stem(runes)
+			token.Term = analysis.BuildTermFromRunes(runes)
+		}
+	}
+	return input
+}
+
+func isVowel(r rune) bool {
+	switch r {
+	case 'а', 'е', 'и', 'о', 'у', 'ю', 'я', 'і', 'ї', 'є':
+		return true
+	}
+	return false
+}
+
+var perfectiveGerundEndings = []string{"ившись", "ивши", "вшись", "вши", "ив"}
+
+var reflexiveEndings = []string{"ся", "сь", "си"}
+
+var adjectiveEndings = []string{
+	"ими", "ого", "ому", "ова", "ове", "йми", "іми", "ій", "ий", "їй", "єє", "еє",
+	"ім", "ем", "им", "их", "іх", "ою", "ої", "ів",
+}
+
+var verbEndings = []string{
+	"аючи", "яючи", "ючи", "ати", "яти", "ать", "ять", "ала", "яла", "ало", "яло",
+	"али", "яли", "учи", "ячи", "вши", "ши", "ме", "ив", "ав", "ла", "ло", "ли", "в",
+	"у", "ю", "е", "є",
+}
+
+var nounEndings = []string{
+	"ями", "ами", "иям", "ием", "иях", "ові", "еві", "ев", "ов", "еи", "ей", "ой",
+	"ий", "ям", "ем", "ам", "ом", "ах", "ях", "ию", "ью", "ия", "ья", "ею", "єю",
+	"ою", "єм", "ів", "їв", "а", "е", "и", "й", "о", "у", "ы", "ь", "ю", "я", "і",
+	"ї", "є",
+}
+
+func stem(input []rune) []rune {
+	// the region after the first vowel
+	rv := 0
+	for rv < len(input) && !isVowel(input[rv]) {
+		rv++
+	}
+	rv++
+	if rv >= len(input) {
+		return input
+	}
+	word := input
+
+	// step 1
+	if w, ok := removeEnding(word, rv, perfectiveGerundEndings); ok {
+		word = w
+	} else {
+		word, _ = removeEnding(word, rv, reflexiveEndings)
+		if w, ok := removeEnding(word, rv, adjectiveEndings); ok {
+			word = w
+		} else if w, ok := removeEnding(word, rv, verbEndings); ok {
+			word = w
+		} else {
+			word, _ = removeEnding(word, rv, nounEndings)
+		}
+	}
+
+	// step 2
+	word, _ = removeEnding(word, rv, []string{"и", "і"})
+
+	// step 3, derivational
+	word, _ = removeEnding(word, rv+2, []string{"ость", "ост"})
+
+	// step 4
+	if analysis.RunesEndsWith(word, "нн") {
+		word = word[:len(word)-1]
+	} else if w, ok := removeEnding(word, rv, []string{"ейше", "ейш"}); ok {
+		word = w
+		if analysis.RunesEndsWith(word, "нн") {
+			word = word[:len(word)-1]
+		}
+	} else {
+		word, _ = removeEnding(word, rv, []string{"ь"})
+	}
+	return word
+}
+
+// removeEnding removes the longest of the endings found
+// entirely within the region starting at region
+func removeEnding(input []rune, region int, endings []string) ([]rune, bool) {
+	longest := 0
+	for _, ending := range endings {
+		endingLen := len([]rune(ending))
+		if endingLen > longest && len(input)-endingLen >= region && analysis.RunesEndsWith(input, ending) {
+			longest = endingLen
+		}
+	}
+	if longest == 0 {
+		return input, false
+	}
+	return input[:len(input)-longest], true
+}
+
+func UkrainianStemmerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewUkrainianStemmerFilter(), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(StemmerName, UkrainianStemmerFilterConstructor)
+}
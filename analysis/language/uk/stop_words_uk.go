@@ -0,0 +1,144 @@
+package uk
+
+import (
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const StopName = "stop_uk"
+
+// common ukrainian function words
+var UkrainianStopWords = []byte(`# ukrainian stop words
+а
+або
+але
+б
+без
+би
+біля
+був
+була
+були
+було
+бути
+в
+вам
+вас
+весь
+вже
+ви
+від
+він
+вона
+вони
+воно
+все
+всі
+всього
+втім
+де
+деякий
+для
+до
+є
+ж
+же
+з
+за
+зі
+і
+із
+її
+їй
+їм
+їх
+й
+його
+йому
+коли
+крізь
+ледве
+ми
+між
+мій
+мене
+мені
+мною
+моя
+мої
+на
+навіть
+над
+нам
+нас
+наш
+не
+неї
+нема
+ні
+ніж
+них
+ну
+о
+об
+од
+однак
+оце
+по
+при
+про
+проте
+саме
+свій
+себе
+собі
+так
+також
+там
+тебе
+тобі
+то
+тобто
+той
+ти
+тим
+тих
+ті
+тільки
+тут
+у
+увесь
+уже
+усе
+усі
+це
+цей
+ці
+цим
+цих
+цього
+цю
+ця
+чи
+чий
+чого
+чому
+що
+щоб
+щодо
+як
+який
+яка
+які
+якщо
+`)
+
+func TokenMapConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenMap, error) {
+	rv := analysis.NewTokenMap()
+	err := rv.LoadBytes(UkrainianStopWords)
+	return rv, err
+}
+
+func init() {
+	registry.RegisterTokenMap(StopName, TokenMapConstructor)
+}
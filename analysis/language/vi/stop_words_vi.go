@@ -0,0 +1,79 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package vi
+
+import (
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const StopName = "stop_vi"
+
+// common vietnamese function words, all of a single
+// syllable
+var VietnameseStopWords = []byte(`# vietnamese function words
+và
+của
+là
+có
+các
+những
+được
+cho
+với
+trong
+này
+đã
+đang
+sẽ
+không
+một
+để
+khi
+thì
+mà
+nhưng
+cũng
+như
+từ
+đến
+về
+theo
+lại
+nên
+vì
+rằng
+bị
+ở
+ra
+vào
+trên
+dưới
+nếu
+hay
+hoặc
+rất
+cái
+chiếc
+đó
+đây
+ấy
+nào
+`)
+
+func TokenMapConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenMap, error) {
+	rv := analysis.NewTokenMap()
+	err := rv.LoadBytes(VietnameseStopWords)
+	return rv, err
+}
+
+func init() {
+	registry.RegisterTokenMap(StopName, TokenMapConstructor)
+}
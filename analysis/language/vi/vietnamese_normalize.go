@@ -0,0 +1,158 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package vi
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+	"golang.org/x/text/unicode/norm"
+)
+
+const NormalizeName = "normalize_vi"
+
+// VietnameseNormalizeFilter puts text in composed form
+// and moves each tone mark to a single canonical vowel.
+// The same word can be written with the tone on either
+// vowel of "oa", "oe" and "uy" ("hoà" and "hòa"), and
+// in composed or decomposed form, this filter makes all
+// of them the same term.  The traditional placement,
+// "hòa", is used.
+type VietnameseNormalizeFilter struct {
+}
+
+func NewVietnameseNormalizeFilter() *VietnameseNormalizeFilter {
+	return &VietnameseNormalizeFilter{}
+}
+
+func (s *VietnameseNormalizeFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		token.Term = []byte(normalize(string(token.Term)))
+	}
+	return input
+}
+
+func isToneMark(r rune) bool {
+	switch r {
+	// grave, acute, tilde, hook above and dot below
+	case '\u0300', '\u0301', '\u0303', '\u0309', '\u0323':
+		return true
+	}
+	return false
+}
+
+func isQualityMark(r rune) bool {
+	switch r {
+	// circumflex, breve and horn
+	case '\u0302', '\u0306', '\u031B':
+		return true
+	}
+	return false
+}
+
+func isVowel(r rune) bool {
+	switch unicode.ToLower(r) {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+// normalize normalizes every space separated syllable
+func normalize(input string) string {
+	syllables := strings.Split(norm.NFD.String(input), " ")
+	for i, syllable := range syllables {
+		syllables[i] = normalizeSyllable(syllable)
+	}
+	return norm.NFC.String(strings.Join(syllables, " "))
+}
+
+// normalizeSyllable expects a decomposed syllable and
+// returns it decomposed with the tone mark moved
+func normalizeSyllable(syllable string) string {
+	var tone rune
+	runes := make([]rune, 0, len(syllable))
+	for _, r := range syllable {
+		if isToneMark(r) {
+			tone = r
+			continue
+		}
+		runes = append(runes, r)
+	}
+	if tone == 0 {
+		return syllable
+	}
+
+	// the vowels of the syllable, each followed by
+	// any quality marks
+	vowels := make([]int, 0, 3)
+	for i, r := range runes {
+		if isVowel(r) {
+			vowels = append(vowels, i)
+		}
+	}
+	lower := strings.ToLower(string(runes))
+	// u in "qu" and i in "gi" are part of the consonant
+	if len(vowels) > 1 && (strings.HasPrefix(lower, "qu") || strings.HasPrefix(lower, "gi")) && vowels[0] == 1 {
+		vowels = vowels[1:]
+	}
+	if len(vowels) == 0 {
+		// not vietnamese, leave the tone where it was
+		return syllable
+	}
+
+	// the vowel (among the last contiguous ones) which
+	// takes the tone
+	target := -1
+	for _, v := range vowels {
+		if v+1 < len(runes) && isQualityMark(runes[v+1]) {
+			target = v
+		}
+	}
+	if target < 0 {
+		last := vowels[len(vowels)-1]
+		end := last + 1
+		for end < len(runes) && isQualityMark(runes[end]) {
+			end++
+		}
+		hasFinal := end < len(runes)
+		switch {
+		case len(vowels) == 1:
+			target = vowels[0]
+		case hasFinal:
+			target = last
+		case len(vowels) == 2:
+			target = vowels[0]
+		default:
+			target = vowels[1]
+		}
+	}
+
+	// the tone goes after the quality marks of the vowel
+	insert := target + 1
+	for insert < len(runes) && isQualityMark(runes[insert]) {
+		insert++
+	}
+	rv := make([]rune, 0, len(runes)+1)
+	rv = append(rv, runes[:insert]...)
+	rv = append(rv, tone)
+	rv = append(rv, runes[insert:]...)
+	return string(rv)
+}
+
+func NormalizerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return NewVietnameseNormalizeFilter(), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(NormalizeName, NormalizerFilterConstructor)
+}
@@ -0,0 +1,51 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package vi
+
+import (
+	"testing"
+)
+
+func TestVietnameseNormalize(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+	}{
+		// new style tone placement
+		{"hoà", "hòa"},
+		{"thuỷ", "thủy"},
+		{"khoẻ", "khỏe"},
+		// already traditional
+		{"hòa bình", "hòa bình"},
+		// decomposed input
+		{"hòa", "hòa"},
+		{"Việt", "Việt"},
+		// the tone follows the final consonant rule
+		{"hoàn", "hoàn"},
+		{"hòan", "hoàn"},
+		// quality marks take the tone
+		{"ngươì", "người"},
+		// qu and gi are consonants
+		{"quá", "quá"},
+		{"giá", "giá"},
+		{"gì", "gì"},
+		// three vowels
+		{"ngoaì", "ngoài"},
+		// nothing to do
+		{"bleve", "bleve"},
+	}
+
+	for _, test := range tests {
+		actual := normalize(test.input)
+		if actual != test.output {
+			t.Errorf("expected %s (%+q), got %s (%+q) for %s", test.output, test.output, actual, actual, test.input)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package vi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/tokenizers/unicode"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const TokenizerName = "vi_word"
+
+// VietnameseTokenizer segments Vietnamese text into
+// words.  Vietnamese separates syllables rather than
+// words with spaces, so adjacent syllables found in the
+// dictionary are joined into a single token, such as
+// "học sinh".  The longest dictionary word wins.
+type VietnameseTokenizer struct {
+	unicode   *unicode.UnicodeTokenizer
+	words     map[string]bool
+	maxLength int
+}
+
+// NewVietnameseTokenizer builds a tokenizer using the
+// words of the token map, with their syllables joined
+// by _
+func NewVietnameseTokenizer(words analysis.TokenMap) *VietnameseTokenizer {
+	rv := VietnameseTokenizer{
+		unicode: unicode.NewUnicodeTokenizer(),
+		words:   make(map[string]bool, len(words)),
+	}
+	for word := range words {
+		syllables := strings.Split(word, "_")
+		if len(syllables) > rv.maxLength {
+			rv.maxLength = len(syllables)
+		}
+		rv.words[wordKey(syllables)] = true
+	}
+	return &rv
+}
+
+func wordKey(syllables []string) string {
+	return normalize(strings.ToLower(strings.Join(syllables, " ")))
+}
+
+func (t *VietnameseTokenizer) Tokenize(input []byte) analysis.TokenStream {
+	syllables := t.unicode.Tokenize(input)
+	rv := make(analysis.TokenStream, 0, len(syllables))
+
+	position := 1
+	for i := 0; i < len(syllables); {
+		length := t.match(input, syllables[i:])
+		token := syllables[i]
+		if length > 1 {
+			last := syllables[i+length-1]
+			terms := make([][]byte, length)
+			for j := 0; j < length; j++ {
+				terms[j] = syllables[i+j].Term
+			}
+			token = &analysis.Token{
+				Term:  bytes.Join(terms, []byte(" ")),
+				Start: token.Start,
+				End:   last.End,
+				Type:  analysis.AlphaNumeric,
+			}
+		}
+		token.Position = position
+		rv = append(rv, token)
+		position++
+		i += length
+	}
+
+	return rv
+}
+
+// match returns the number of syllables in the longest
+// word starting the input, syllables of a word may only
+// be separated by whitespace
+func (t *VietnameseTokenizer) match(input []byte, syllables analysis.TokenStream) int {
+	maxLength := t.maxLength
+	if maxLength > len(syllables) {
+		maxLength = len(syllables)
+	}
+	for n := 1; n < maxLength; n++ {
+		between := input[syllables[n-1].End:syllables[n].Start]
+		if len(bytes.TrimSpace(between)) > 0 {
+			maxLength = n
+			break
+		}
+	}
+	for length := maxLength; length > 1; length-- {
+		words := make([]string, length)
+		for j := 0; j < length; j++ {
+			words[j] = string(syllables[j].Term)
+		}
+		if t.words[wordKey(words)] {
+			return length
+		}
+	}
+	return 1
+}
+
+func VietnameseTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+	wordsName := WordsName
+	wordsVal, ok := config["words_token_map"].(string)
+	if ok {
+		wordsName = wordsVal
+	}
+	words, err := cache.TokenMapNamed(wordsName)
+	if err != nil {
+		return nil, fmt.Errorf("error building vietnamese tokenizer: %v", err)
+	}
+	return NewVietnameseTokenizer(words), nil
+}
+
+func init() {
+	registry.RegisterTokenizer(TokenizerName, VietnameseTokenizerConstructor)
+}
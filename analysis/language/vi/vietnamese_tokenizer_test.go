@@ -0,0 +1,85 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package vi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+func TestVietnameseTokenizer(t *testing.T) {
+	words := analysis.NewTokenMap()
+	words.AddToken("học_sinh")
+	words.AddToken("hồ_chí_minh")
+	words.AddToken("thành_phố")
+	words.AddToken("thành_phố_hồ_chí_minh")
+
+	tests := []struct {
+		input  []byte
+		output analysis.TokenStream
+	}{
+		{
+			[]byte("Học sinh ở Thành phố Hồ Chí Minh"),
+			analysis.TokenStream{
+				{
+					Term:     []byte("Học sinh"),
+					Position: 1,
+					Start:    0,
+					End:      10,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Term:     []byte("ở"),
+					Position: 2,
+					Start:    11,
+					End:      14,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Term:     []byte("Thành phố Hồ Chí Minh"),
+					Position: 3,
+					Start:    15,
+					End:      42,
+					Type:     analysis.AlphaNumeric,
+				},
+			},
+		},
+		// punctuation ends a word
+		{
+			[]byte("học, sinh"),
+			analysis.TokenStream{
+				{
+					Term:     []byte("học"),
+					Position: 1,
+					Start:    0,
+					End:      5,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Term:     []byte("sinh"),
+					Position: 2,
+					Start:    7,
+					End:      11,
+					Type:     analysis.AlphaNumeric,
+				},
+			},
+		},
+	}
+
+	tokenizer := NewVietnameseTokenizer(words)
+	for _, test := range tests {
+		actual := tokenizer.Tokenize(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v for %s", test.output, actual, string(test.input))
+		}
+	}
+}
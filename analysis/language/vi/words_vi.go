@@ -0,0 +1,51 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package vi
+
+import (
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const WordsName = "words_vi"
+
+// VietnameseWords are common multi-syllable words, the
+// syllables of each word are joined with _
+var VietnameseWords = []byte(`# common vietnamese words of more than one syllable
+việt_nam hà_nội sài_gòn hồ_chí_minh thành_phố đất_nước quốc_gia nhà_nước chính_phủ
+nhân_dân xã_hội kinh_tế chính_trị văn_hóa giáo_dục y_tế khoa_học công_nghệ kỹ_thuật
+lịch_sử địa_lý toán_học văn_học ngôn_ngữ tiếng_việt tiếng_anh học_sinh sinh_viên
+giáo_viên giáo_sư bác_sĩ kỹ_sư công_nhân nông_dân doanh_nghiệp công_ty ngân_hàng
+thị_trường sản_phẩm dịch_vụ khách_hàng nhân_viên giám_đốc quản_lý phát_triển
+nghiên_cứu thông_tin tin_tức báo_chí truyền_hình điện_thoại máy_tính phần_mềm
+phần_cứng mạng_lưới internet_vạn_vật trí_tuệ_nhân_tạo dữ_liệu hệ_thống chương_trình
+ứng_dụng trường_học đại_học bệnh_viện sân_bay nhà_ga bến_xe khách_sạn nhà_hàng
+gia_đình bố_mẹ cha_mẹ anh_em vợ_chồng con_cái bạn_bè hàng_xóm người_dân
+tình_yêu hạnh_phúc sức_khỏe bệnh_tật cuộc_sống thời_gian không_gian thế_giới
+quốc_tế châu_á châu_âu hoa_kỳ trung_quốc nhật_bản hàn_quốc môi_trường khí_hậu
+thời_tiết mùa_xuân mùa_hạ mùa_thu mùa_đông buổi_sáng buổi_chiều buổi_tối hôm_nay
+ngày_mai hôm_qua tuần_trước năm_nay bây_giờ lúc_nào bao_giờ ở_đâu tại_sao như_thế_nào
+vì_vậy tuy_nhiên nhưng_mà bởi_vì cho_nên mặc_dù ngay_cả thật_sự chắc_chắn có_thể
+hòa_bình chiến_tranh độc_lập tự_do pháp_luật quyền_lợi trách_nhiệm nghĩa_vụ
+vấn_đề giải_pháp kết_quả nguyên_nhân mục_tiêu kế_hoạch hoạt_động tổ_chức
+cá_nhân tập_thể cộng_đồng du_lịch thể_thao bóng_đá âm_nhạc nghệ_thuật phim_ảnh
+ẩm_thực món_ăn đồ_uống cà_phê nước_mắm phở_bò bánh_mì xe_máy ô_tô xe_đạp máy_bay
+tàu_hỏa giao_thông đường_phố điện_tử thương_mại điện_thoại_di_động
+`)
+
+func WordsTokenMapConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenMap, error) {
+	rv := analysis.NewTokenMap()
+	err := rv.LoadBytes(VietnameseWords)
+	return rv, err
+}
+
+func init() {
+	registry.RegisterTokenMap(WordsName, WordsTokenMapConstructor)
+}
@@ -0,0 +1,60 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package analysis
+
+import (
+	"sort"
+)
+
+// An OffsetCorrection records that output offsets from
+// Offset onwards must have Diff added to them to find the
+// corresponding input offset.  Diff is cumulative, each
+// correction replaces the previous one.
+type OffsetCorrection struct {
+	Offset int
+	Diff   int
+}
+
+// OffsetCorrections are kept sorted by Offset
+type OffsetCorrections []OffsetCorrection
+
+// Add records a new cumulative diff starting at offset,
+// offsets must be added in increasing order
+func (c OffsetCorrections) Add(offset, diff int) OffsetCorrections {
+	if len(c) > 0 && c[len(c)-1].Offset == offset {
+		c[len(c)-1].Diff = diff
+		return c
+	}
+	return append(c, OffsetCorrection{Offset: offset, Diff: diff})
+}
+
+// Correct maps an output offset back to the input
+func (c OffsetCorrections) Correct(offset int) int {
+	i := sort.Search(len(c), func(i int) bool {
+		return c[i].Offset > offset
+	})
+	if i == 0 {
+		return offset
+	}
+	return offset + c[i-1].Diff
+}
+
+// CorrectOffsets maps the start and end offsets of the
+// tokens back through the corrections of a chain of char
+// filters, the corrections must be given in the order
+// the filters were applied
+func CorrectOffsets(tokens TokenStream, corrections []OffsetCorrections) {
+	for _, token := range tokens {
+		for i := len(corrections) - 1; i >= 0; i-- {
+			token.Start = corrections[i].Correct(token.Start)
+			token.End = corrections[i].Correct(token.End)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOffsetCorrections(t *testing.T) {
+	var c OffsetCorrections
+	c = c.Add(1, 1)
+	c = c.Add(3, 0)
+	c = c.Add(4, -1)
+	c = c.Add(4, -2)
+
+	expected := OffsetCorrections{
+		{Offset: 1, Diff: 1},
+		{Offset: 3, Diff: 0},
+		{Offset: 4, Diff: -2},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Fatalf("expected %v, got %v", expected, c)
+	}
+
+	tests := []struct {
+		offset   int
+		expected int
+	}{
+		{offset: 0, expected: 0},
+		{offset: 1, expected: 2},
+		{offset: 2, expected: 3},
+		{offset: 3, expected: 3},
+		{offset: 4, expected: 2},
+		{offset: 5, expected: 3},
+	}
+	for _, test := range tests {
+		actual := c.Correct(test.offset)
+		if actual != test.expected {
+			t.Errorf("expected offset %d to map to %d, got %d", test.offset, test.expected, actual)
+		}
+	}
+}
+
+func TestCorrectOffsets(t *testing.T) {
+	tokens := TokenStream{
+		&Token{Term: []byte("x"), Start: 0, End: 1},
+		&Token{Term: []byte("y"), Start: 2, End: 3},
+	}
+	corrections := []OffsetCorrections{
+		{{Offset: 1, Diff: 2}},
+		{{Offset: 2, Diff: 1}},
+	}
+	CorrectOffsets(tokens, corrections)
+	expected := TokenStream{
+		&Token{Term: []byte("x"), Start: 0, End: 3},
+		&Token{Term: []byte("y"), Start: 5, End: 6},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("expected %v, got %v", expected, tokens)
+	}
+}
@@ -0,0 +1,67 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package analysis
+
+import (
+	"fmt"
+)
+
+const (
+	CharFilterStage  = "char_filter"
+	TokenizerStage   = "tokenizer"
+	TokenFilterStage = "token_filter"
+)
+
+// AnalysisStage is the output of one step of an analyzer.
+// Char filters produce text, the tokenizer and token
+// filters produce tokens.  The tokens are copies, later
+// stages modifying tokens in place do not change them.
+type AnalysisStage struct {
+	Kind   string      `json:"kind"`
+	Name   string      `json:"name"`
+	Text   string      `json:"text,omitempty"`
+	Tokens TokenStream `json:"tokens,omitempty"`
+}
+
+// componentName describes a char filter, tokenizer or token
+// filter by the name of its type, analyzers do not keep
+// the names they were built with
+func componentName(component interface{}) string {
+	return fmt.Sprintf("%T", component)
+}
+
+func newCharFilterStage(cf CharFilter, output []byte) *AnalysisStage {
+	return &AnalysisStage{
+		Kind: CharFilterStage,
+		Name: componentName(cf),
+		Text: string(output),
+	}
+}
+
+func newTokenStage(kind string, component interface{}, tokens TokenStream) *AnalysisStage {
+	return &AnalysisStage{
+		Kind:   kind,
+		Name:   componentName(component),
+		Tokens: copyTokenStream(tokens),
+	}
+}
+
+func copyTokenStream(tokens TokenStream) TokenStream {
+	rv := make(TokenStream, len(tokens))
+	for i, token := range tokens {
+		tokenCopy := *token
+		tokenCopy.Term = append([]byte(nil), token.Term...)
+		if token.Payload != nil {
+			tokenCopy.Payload = append([]byte(nil), token.Payload...)
+		}
+		rv[i] = &tokenCopy
+	}
+	return rv
+}
@@ -0,0 +1,119 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package analysis
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+type dashCharFilter struct{}
+
+func (f *dashCharFilter) Filter(input []byte) []byte {
+	return bytes.Replace(input, []byte("-"), []byte(" "), -1)
+}
+
+type spaceTokenizer struct{}
+
+func (t *spaceTokenizer) Tokenize(input []byte) TokenStream {
+	rv := make(TokenStream, 0)
+	start := 0
+	for _, field := range bytes.Split(input, []byte(" ")) {
+		if len(field) > 0 {
+			rv = append(rv, &Token{
+				Term:     field,
+				Start:    start,
+				End:      start + len(field),
+				Position: len(rv) + 1,
+			})
+		}
+		start += len(field) + 1
+	}
+	return rv
+}
+
+type upperFilter struct{}
+
+func (f *upperFilter) Filter(input TokenStream) TokenStream {
+	for _, token := range input {
+		token.Term = bytes.ToUpper(token.Term)
+	}
+	return input
+}
+
+type dropShortFilter struct{}
+
+func (f *dropShortFilter) Filter(input TokenStream) TokenStream {
+	rv := make(TokenStream, 0, len(input))
+	for _, token := range input {
+		if len(token.Term) > 1 {
+			rv = append(rv, token)
+		}
+	}
+	return rv
+}
+
+func TestAnalyzeStages(t *testing.T) {
+	analyzer := Analyzer{
+		CharFilters:  []CharFilter{&dashCharFilter{}},
+		Tokenizer:    &spaceTokenizer{},
+		TokenFilters: []TokenFilter{&upperFilter{}, &dropShortFilter{}},
+	}
+
+	tokens, stages := analyzer.AnalyzeStages([]byte("a-bc"))
+
+	expectedTokens := TokenStream{
+		&Token{Term: []byte("BC"), Start: 2, End: 4, Position: 2},
+	}
+	if !reflect.DeepEqual(tokens, expectedTokens) {
+		t.Errorf("expected %v, got %v", expectedTokens, tokens)
+	}
+	if !reflect.DeepEqual(analyzer.Analyze([]byte("a-bc")), expectedTokens) {
+		t.Errorf("expected Analyze to produce the same tokens")
+	}
+
+	expectedStages := []*AnalysisStage{
+		{
+			Kind: CharFilterStage,
+			Name: "*analysis.dashCharFilter",
+			Text: "a bc",
+		},
+		{
+			Kind: TokenizerStage,
+			Name: "*analysis.spaceTokenizer",
+			Tokens: TokenStream{
+				&Token{Term: []byte("a"), Start: 0, End: 1, Position: 1},
+				&Token{Term: []byte("bc"), Start: 2, End: 4, Position: 2},
+			},
+		},
+		{
+			Kind: TokenFilterStage,
+			Name: "*analysis.upperFilter",
+			Tokens: TokenStream{
+				&Token{Term: []byte("A"), Start: 0, End: 1, Position: 1},
+				&Token{Term: []byte("BC"), Start: 2, End: 4, Position: 2},
+			},
+		},
+		{
+			Kind: TokenFilterStage,
+			Name: "*analysis.dropShortFilter",
+			Tokens: TokenStream{
+				&Token{Term: []byte("BC"), Start: 2, End: 4, Position: 2},
+			},
+		},
+	}
+	if !reflect.DeepEqual(stages, expectedStages) {
+		for i, stage := range stages {
+			t.Logf("stage %d: %#v", i, stage)
+		}
+		t.Errorf("stages differ from expected")
+	}
+}
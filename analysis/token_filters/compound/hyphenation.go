@@ -0,0 +1,188 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package compound
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const HyphenationName = "hyphenation_compound"
+
+// HyphenationCompoundFilter decomposes compound words
+// into the parts found between hyphenation points.
+// Parts are only kept if they are in the dictionary,
+// or if no dictionary was given.  A part which is only
+// found in the dictionary after dropping its last
+// letter is kept without it, this handles linking
+// letters such as the German "s" in "Meisterschaftsspiel".
+type HyphenationCompoundFilter struct {
+	hyphenator       *HyphenationTree
+	dict             analysis.TokenMap
+	minWordSize      int
+	minSubWordSize   int
+	maxSubWordSize   int
+	onlyLongestMatch bool
+}
+
+func NewHyphenationCompoundFilter(hyphenator *HyphenationTree, dict analysis.TokenMap, minWordSize, minSubWordSize, maxSubWordSize int, onlyLongestMatch bool) *HyphenationCompoundFilter {
+	return &HyphenationCompoundFilter{
+		hyphenator:       hyphenator,
+		dict:             dict,
+		minWordSize:      minWordSize,
+		minSubWordSize:   minSubWordSize,
+		maxSubWordSize:   maxSubWordSize,
+		onlyLongestMatch: onlyLongestMatch,
+	}
+}
+
+func (f *HyphenationCompoundFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input))
+
+	for _, token := range input {
+		rv = append(rv, token)
+		tokenLen := utf8.RuneCount(token.Term)
+		if tokenLen >= f.minWordSize {
+			rv = append(rv, f.decompose(token)...)
+		}
+	}
+
+	return rv
+}
+
+func (f *HyphenationCompoundFilter) inDict(runes []rune) bool {
+	if f.dict == nil {
+		return true
+	}
+	_, inDict := f.dict[string(runes)]
+	return inDict
+}
+
+func (f *HyphenationCompoundFilter) decompose(token *analysis.Token) []*analysis.Token {
+	runes := bytes.Runes(token.Term)
+	hyphens := f.hyphenator.Hyphenate(runes)
+
+	// byte offsets of each rune, for the token offsets
+	offsets := make([]int, len(runes)+1)
+	for i, r := range runes {
+		offsets[i+1] = offsets[i] + utf8.RuneLen(r)
+	}
+
+	rv := make([]*analysis.Token, 0)
+	for i := 0; i < len(hyphens)-1; i++ {
+		start := hyphens[i]
+		var longestMatchToken *analysis.Token
+		for j := i + 1; j < len(hyphens); j++ {
+			partLength := hyphens[j] - start
+			if partLength > f.maxSubWordSize {
+				break
+			}
+			if partLength < f.minSubWordSize {
+				continue
+			}
+			// the whole word is not a part of itself
+			if start == 0 && hyphens[j] == len(runes) {
+				continue
+			}
+
+			end := hyphens[j]
+			if !f.inDict(runes[start:end]) {
+				if f.dict == nil || partLength-1 < f.minSubWordSize || !f.inDict(runes[start:end-1]) {
+					continue
+				}
+				end--
+			}
+			newtoken := analysis.Token{
+				Term:     []byte(string(runes[start:end])),
+				Position: token.Position,
+				Start:    token.Start + offsets[start],
+				End:      token.Start + offsets[end],
+				Type:     token.Type,
+				KeyWord:  token.KeyWord,
+			}
+			if f.onlyLongestMatch {
+				if longestMatchToken == nil || len(longestMatchToken.Term) < len(newtoken.Term) {
+					longestMatchToken = &newtoken
+				}
+			} else {
+				rv = append(rv, &newtoken)
+			}
+		}
+		if f.onlyLongestMatch && longestMatchToken != nil {
+			rv = append(rv, longestMatchToken)
+		}
+	}
+	return rv
+}
+
+func HyphenationCompoundFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+
+	minWordSize := defaultMinWordSize
+	minSubWordSize := defaultMinSubWordSize
+	maxSubWordSize := defaultMaxSubWordSize
+	onlyLongestMatch := defaultOnlyLongestMatch
+
+	minVal, ok := config["min_word_size"].(float64)
+	if ok {
+		minWordSize = int(minVal)
+	}
+	minSubVal, ok := config["min_subword_size"].(float64)
+	if ok {
+		minSubWordSize = int(minSubVal)
+	}
+	maxSubVal, ok := config["max_subword_size"].(float64)
+	if ok {
+		maxSubWordSize = int(maxSubVal)
+	}
+	onlyVal, ok := config["only_longest_match"].(bool)
+	if ok {
+		onlyLongestMatch = onlyVal
+	}
+
+	hyphenator := NewHyphenationTree()
+	filename, ok := config["hyphenation_patterns_file"].(string)
+	if ok {
+		err := hyphenator.LoadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("error building hyphenation compound words filter: %v", err)
+		}
+	} else {
+		patterns, ok := config["hyphenation_patterns"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("must specify hyphenation_patterns_file or hyphenation_patterns")
+		}
+		for _, pattern := range patterns {
+			patternStr, ok := pattern.(string)
+			if !ok {
+				return nil, fmt.Errorf("hyphenation pattern must be a string")
+			}
+			hyphenator.AddPattern(patternStr)
+		}
+	}
+
+	var dictTokenMap analysis.TokenMap
+	dictTokenMapName, ok := config["dict_token_map"].(string)
+	if ok {
+		var err error
+		dictTokenMap, err = cache.TokenMapNamed(dictTokenMapName)
+		if err != nil {
+			return nil, fmt.Errorf("error building hyphenation compound words filter: %v", err)
+		}
+	}
+	return NewHyphenationCompoundFilter(hyphenator, dictTokenMap, minWordSize, minSubWordSize, maxSubWordSize, onlyLongestMatch), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(HyphenationName, HyphenationCompoundFilterConstructor)
+}
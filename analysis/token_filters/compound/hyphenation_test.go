@@ -0,0 +1,162 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package compound
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/token_map"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestHyphenationTree(t *testing.T) {
+	hyphenator := NewHyphenationTree()
+	err := hyphenator.LoadBytes([]byte(`% sample patterns
+\patterns{
+s1b l1w t1m s1t r1s
+.fu2s
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := hyphenator.Hyphenate([]rune("Fussballweltmeisterschaft"))
+	expected := []int{0, 4, 8, 12, 16, 19, 25}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestHyphenationCompoundFilter(t *testing.T) {
+
+	inputTokenStream := analysis.TokenStream{
+		&analysis.Token{
+			Term:     []byte("fussballweltmeisterschaft"),
+			Start:    0,
+			End:      25,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("meisterschaftsspiel"),
+			Start:    26,
+			End:      45,
+			Position: 2,
+		},
+	}
+
+	expectedTokenStream := analysis.TokenStream{
+		&analysis.Token{
+			Term:     []byte("fussballweltmeisterschaft"),
+			Start:    0,
+			End:      25,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("fussball"),
+			Start:    0,
+			End:      8,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("ball"),
+			Start:    4,
+			End:      8,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("welt"),
+			Start:    8,
+			End:      12,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("weltmeisterschaft"),
+			Start:    8,
+			End:      25,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("meister"),
+			Start:    12,
+			End:      19,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("meisterschaft"),
+			Start:    12,
+			End:      25,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("schaft"),
+			Start:    19,
+			End:      25,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("meisterschaftsspiel"),
+			Start:    26,
+			End:      45,
+			Position: 2,
+		},
+		&analysis.Token{
+			Term:     []byte("meister"),
+			Start:    26,
+			End:      33,
+			Position: 2,
+		},
+		&analysis.Token{
+			Term:     []byte("meisterschaft"),
+			Start:    26,
+			End:      39,
+			Position: 2,
+		},
+		&analysis.Token{
+			Term:     []byte("schaft"),
+			Start:    33,
+			End:      39,
+			Position: 2,
+		},
+		&analysis.Token{
+			Term:     []byte("spiel"),
+			Start:    40,
+			End:      45,
+			Position: 2,
+		},
+	}
+
+	cache := registry.NewCache()
+	dictListConfig := map[string]interface{}{
+		"type":   token_map.Name,
+		"tokens": []interface{}{"fussball", "ball", "welt", "meister", "meisterschaft", "weltmeisterschaft", "schaft", "spiel"},
+	}
+	_, err := cache.DefineTokenMap("dict_test", dictListConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hyphenationConfig := map[string]interface{}{
+		"type":                 HyphenationName,
+		"dict_token_map":       "dict_test",
+		"hyphenation_patterns": []interface{}{"s1b", "l1w", "t1m", "s1t", "r1s", "s1s"},
+		"max_subword_size":     20.0,
+	}
+	hyphenationFilter, err := cache.DefineTokenFilter("hyphenation_test", hyphenationConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ouputTokenStream := hyphenationFilter.Filter(inputTokenStream)
+	if !reflect.DeepEqual(ouputTokenStream, expectedTokenStream) {
+		t.Errorf("expected %#v got %#v", expectedTokenStream, ouputTokenStream)
+	}
+}
@@ -0,0 +1,133 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package compound
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode"
+)
+
+// HyphenationTree finds hyphenation points using
+// Liang's algorithm, as used by TeX.  Patterns are
+// letters interleaved with digits, such as "1ba" or
+// ".ab4c", an odd digit allows a break at that point
+// and an even digit forbids it, the highest digit from
+// all matching patterns wins.
+type HyphenationTree struct {
+	patterns  map[string][]int
+	maxLength int
+}
+
+func NewHyphenationTree() *HyphenationTree {
+	return &HyphenationTree{
+		patterns: make(map[string][]int),
+	}
+}
+
+func (h *HyphenationTree) LoadFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return h.LoadBytes(data)
+}
+
+// LoadBytes reads whitespace separated patterns in the
+// format of TeX hyphenation pattern files, comments
+// starting with % and TeX commands are ignored
+func (h *HyphenationTree) LoadBytes(data []byte) error {
+	bytesReader := bytes.NewReader(data)
+	bufioReader := bufio.NewReader(bytesReader)
+	line, err := bufioReader.ReadString('\n')
+	for err == nil {
+		h.LoadLine(line)
+		line, err = bufioReader.ReadString('\n')
+	}
+	// if the err was EOF we still need to process the last value
+	if err == io.EOF {
+		h.LoadLine(line)
+		return nil
+	}
+	return err
+}
+
+func (h *HyphenationTree) LoadLine(line string) {
+	startComment := strings.Index(line, "%")
+	if startComment >= 0 {
+		line = line[:startComment]
+	}
+
+	for _, pattern := range strings.Fields(line) {
+		if strings.ContainsAny(pattern, "\\{}") {
+			continue
+		}
+		h.AddPattern(pattern)
+	}
+}
+
+func (h *HyphenationTree) AddPattern(pattern string) {
+	letters := make([]rune, 0, len(pattern))
+	values := make([]int, 1, len(pattern)+1)
+	for _, r := range pattern {
+		if r >= '0' && r <= '9' {
+			values[len(values)-1] = int(r - '0')
+		} else {
+			letters = append(letters, unicode.ToLower(r))
+			values = append(values, 0)
+		}
+	}
+	if len(letters) == 0 {
+		return
+	}
+	h.patterns[string(letters)] = values
+	if len(letters) > h.maxLength {
+		h.maxLength = len(letters)
+	}
+}
+
+// Hyphenate returns the rune offsets within word at
+// which it may be split, always including 0 and the
+// length of the word
+func (h *HyphenationTree) Hyphenate(word []rune) []int {
+	dotted := make([]rune, 0, len(word)+2)
+	dotted = append(dotted, '.')
+	for _, r := range word {
+		dotted = append(dotted, unicode.ToLower(r))
+	}
+	dotted = append(dotted, '.')
+
+	points := make([]int, len(dotted)+1)
+	for i := 0; i < len(dotted); i++ {
+		for j := i + 1; j <= len(dotted) && j-i <= h.maxLength; j++ {
+			values, ok := h.patterns[string(dotted[i:j])]
+			if !ok {
+				continue
+			}
+			for k, value := range values {
+				if value > points[i+k] {
+					points[i+k] = value
+				}
+			}
+		}
+	}
+
+	// points[k+1] is the value between word[k-1] and word[k]
+	rv := []int{0}
+	for k := 1; k < len(word); k++ {
+		if points[k+1]%2 == 1 {
+			rv = append(rv, k)
+		}
+	}
+	return append(rv, len(word))
+}
@@ -0,0 +1,197 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package conditional_filter
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "conditional"
+
+// A Predicate decides whether a token should be passed
+// through the inner token filters
+type Predicate func(token *analysis.Token) bool
+
+// LengthPredicate matches tokens with at least min and
+// at most max runes, a value of 0 disables that bound
+func LengthPredicate(min, max int) Predicate {
+	return func(token *analysis.Token) bool {
+		length := utf8.RuneCount(token.Term)
+		if min > 0 && length < min {
+			return false
+		}
+		if max > 0 && length > max {
+			return false
+		}
+		return true
+	}
+}
+
+// ScriptPredicate matches tokens made up entirely of
+// runes from the given unicode script
+func ScriptPredicate(script *unicode.RangeTable) Predicate {
+	return func(token *analysis.Token) bool {
+		if len(token.Term) == 0 {
+			return false
+		}
+		for _, r := range bytes.Runes(token.Term) {
+			if !unicode.Is(script, r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// RegexpPredicate matches tokens whose term matches
+// the regular expression
+func RegexpPredicate(r *regexp.Regexp) Predicate {
+	return func(token *analysis.Token) bool {
+		return r.Match(token.Term)
+	}
+}
+
+// AllPredicate matches tokens matched by every one of
+// the predicates
+func AllPredicate(predicates ...Predicate) Predicate {
+	return func(token *analysis.Token) bool {
+		for _, predicate := range predicates {
+			if !predicate(token) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// NotPredicate inverts a predicate
+func NotPredicate(predicate Predicate) Predicate {
+	return func(token *analysis.Token) bool {
+		return !predicate(token)
+	}
+}
+
+// ConditionalFilter applies a chain of token filters only
+// to the tokens matching a predicate, all other tokens are
+// passed through unchanged.  Consecutive matching tokens
+// are filtered together, so filters looking at more than
+// one token (such as shingles) see the whole run.
+type ConditionalFilter struct {
+	predicate Predicate
+	filters   []analysis.TokenFilter
+}
+
+func NewConditionalFilter(predicate Predicate, filters []analysis.TokenFilter) *ConditionalFilter {
+	return &ConditionalFilter{
+		predicate: predicate,
+		filters:   filters,
+	}
+}
+
+func (f *ConditionalFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input))
+
+	var run analysis.TokenStream
+	for _, token := range input {
+		if f.predicate(token) {
+			run = append(run, token)
+			continue
+		}
+		if len(run) > 0 {
+			rv = append(rv, f.filterRun(run)...)
+			run = nil
+		}
+		rv = append(rv, token)
+	}
+	if len(run) > 0 {
+		rv = append(rv, f.filterRun(run)...)
+	}
+
+	return rv
+}
+
+func (f *ConditionalFilter) filterRun(run analysis.TokenStream) analysis.TokenStream {
+	for _, filter := range f.filters {
+		run = filter.Filter(run)
+	}
+	return run
+}
+
+func ConditionalFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	tokenFilterNames, ok := config["token_filters"].([]interface{})
+	if !ok || len(tokenFilterNames) == 0 {
+		return nil, fmt.Errorf("must specify token_filters")
+	}
+	filters := make([]analysis.TokenFilter, len(tokenFilterNames))
+	for i, tokenFilterName := range tokenFilterNames {
+		name, ok := tokenFilterName.(string)
+		if !ok {
+			return nil, fmt.Errorf("token filter name must be a string")
+		}
+		filter, err := cache.TokenFilterNamed(name)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = filter
+	}
+
+	var predicates []Predicate
+	min := 0
+	max := 0
+	minVal, ok := config["min_length"].(float64)
+	if ok {
+		min = int(minVal)
+	}
+	maxVal, ok := config["max_length"].(float64)
+	if ok {
+		max = int(maxVal)
+	}
+	if min > 0 || max > 0 {
+		predicates = append(predicates, LengthPredicate(min, max))
+	}
+	scriptName, ok := config["script"].(string)
+	if ok {
+		script, ok := unicode.Scripts[scriptName]
+		if !ok {
+			return nil, fmt.Errorf("unknown script '%s'", scriptName)
+		}
+		predicates = append(predicates, ScriptPredicate(script))
+	}
+	regexpStr, ok := config["regexp"].(string)
+	if ok {
+		r, err := regexp.Compile(regexpStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to build conditional filter regexp: %v", err)
+		}
+		predicates = append(predicates, RegexpPredicate(r))
+	}
+	if len(predicates) == 0 {
+		return nil, fmt.Errorf("must specify min_length, max_length, script or regexp")
+	}
+
+	predicate := AllPredicate(predicates...)
+	invert, ok := config["invert"].(bool)
+	if ok && invert {
+		predicate = NotPredicate(predicate)
+	}
+
+	return NewConditionalFilter(predicate, filters), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, ConditionalFilterConstructor)
+}
@@ -0,0 +1,155 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package conditional_filter
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"unicode"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/token_filters/porter"
+	"github.com/blevesearch/bleve/analysis/token_filters/shingle"
+	"github.com/blevesearch/bleve/analysis/token_filters/truncate_token_filter"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestConditionalFilter(t *testing.T) {
+	tests := []struct {
+		predicate Predicate
+		filters   []analysis.TokenFilter
+		input     analysis.TokenStream
+		output    analysis.TokenStream
+	}{
+		// only stem latin words, leaving codes alone
+		{
+			predicate: ScriptPredicate(unicode.Latin),
+			filters: []analysis.TokenFilter{
+				porter.NewPorterStemmer(),
+			},
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("running"), Position: 1},
+				&analysis.Token{Term: []byte("ab12cs"), Position: 2},
+				&analysis.Token{Term: []byte("cats"), Position: 3},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("run"), Position: 1},
+				&analysis.Token{Term: []byte("ab12cs"), Position: 2},
+				&analysis.Token{Term: []byte("cat"), Position: 3},
+			},
+		},
+		// only truncate long tokens
+		{
+			predicate: LengthPredicate(6, 0),
+			filters: []analysis.TokenFilter{
+				truncate_token_filter.NewTruncateTokenFilter(4),
+			},
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("short"), Position: 1},
+				&analysis.Token{Term: []byte("lengthy"), Position: 2},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("short"), Position: 1},
+				&analysis.Token{Term: []byte("leng"), Position: 2},
+			},
+		},
+		// consecutive matching tokens are filtered together
+		{
+			predicate: RegexpPredicate(regexp.MustCompile(`^[a-z]+$`)),
+			filters: []analysis.TokenFilter{
+				shingle.NewShingleFilter(2, 2, false, false, " ", "_"),
+			},
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("quick"), Position: 1, Start: 0, End: 5},
+				&analysis.Token{Term: []byte("brown"), Position: 2, Start: 6, End: 11},
+				&analysis.Token{Term: []byte("42"), Position: 3, Start: 12, End: 14},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("quick brown"), Position: 1, Start: 0, End: 11, Type: analysis.Shingle},
+				&analysis.Token{Term: []byte("42"), Position: 3, Start: 12, End: 14},
+			},
+		},
+		{
+			predicate: NotPredicate(LengthPredicate(0, 3)),
+			filters: []analysis.TokenFilter{
+				truncate_token_filter.NewTruncateTokenFilter(1),
+			},
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("abc")},
+				&analysis.Token{Term: []byte("abcd")},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("abc")},
+				&analysis.Token{Term: []byte("a")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		filter := NewConditionalFilter(test.predicate, test.filters)
+		actual := filter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %s, got %s", test.output, actual)
+		}
+	}
+}
+
+func TestConditionalFilterConstructor(t *testing.T) {
+	cache := registry.NewCache()
+
+	filter, err := ConditionalFilterConstructor(map[string]interface{}{
+		"token_filters": []interface{}{porter.Name},
+		"script":        "Latin",
+		"min_length":    4.0,
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := analysis.TokenStream{
+		&analysis.Token{Term: []byte("cats")},
+		&analysis.Token{Term: []byte("ows")},
+		&analysis.Token{Term: []byte("кошки")},
+	}
+	expected := analysis.TokenStream{
+		&analysis.Token{Term: []byte("cat")},
+		&analysis.Token{Term: []byte("ows")},
+		&analysis.Token{Term: []byte("кошки")},
+	}
+	actual := filter.Filter(input)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+
+	invalid := []map[string]interface{}{
+		// no inner filters
+		{
+			"script": "Latin",
+		},
+		// no predicate
+		{
+			"token_filters": []interface{}{porter.Name},
+		},
+		{
+			"token_filters": []interface{}{porter.Name},
+			"script":        "Klingon",
+		},
+		{
+			"token_filters": []interface{}{porter.Name},
+			"regexp":        "[a-z",
+		},
+	}
+	for _, config := range invalid {
+		_, err := ConditionalFilterConstructor(config, cache)
+		if err == nil {
+			t.Errorf("expected error for config %v", config)
+		}
+	}
+}
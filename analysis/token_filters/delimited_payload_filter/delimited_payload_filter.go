@@ -0,0 +1,148 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package delimited_payload_filter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "delimited_payload"
+
+const DefaultDelimiter = '|'
+
+const (
+	IdentityEncoding = "identity"
+	FloatEncoding    = "float"
+	IntEncoding      = "int"
+)
+
+// A PayloadEncoder converts the text following the
+// delimiter into the payload bytes, returning nil if the
+// text cannot be encoded
+type PayloadEncoder func(payload []byte) []byte
+
+// IdentityPayloadEncoder uses the text as is
+func IdentityPayloadEncoder(payload []byte) []byte {
+	rv := make([]byte, len(payload))
+	copy(rv, payload)
+	return rv
+}
+
+// FloatPayloadEncoder encodes the text as a big endian
+// IEEE 754 float32
+func FloatPayloadEncoder(payload []byte) []byte {
+	f, err := strconv.ParseFloat(string(payload), 32)
+	if err != nil {
+		return nil
+	}
+	rv := make([]byte, 4)
+	binary.BigEndian.PutUint32(rv, math.Float32bits(float32(f)))
+	return rv
+}
+
+// IntPayloadEncoder encodes the text as a big endian
+// int32
+func IntPayloadEncoder(payload []byte) []byte {
+	i, err := strconv.ParseInt(string(payload), 10, 32)
+	if err != nil {
+		return nil
+	}
+	rv := make([]byte, 4)
+	binary.BigEndian.PutUint32(rv, uint32(int32(i)))
+	return rv
+}
+
+// DecodeFloatPayload decodes a payload written by the
+// FloatPayloadEncoder
+func DecodeFloatPayload(payload []byte) (float32, error) {
+	if len(payload) != 4 {
+		return 0, fmt.Errorf("float payload must be 4 bytes, got %d", len(payload))
+	}
+	return math.Float32frombits(binary.BigEndian.Uint32(payload)), nil
+}
+
+// DecodeIntPayload decodes a payload written by the
+// IntPayloadEncoder
+func DecodeIntPayload(payload []byte) (int32, error) {
+	if len(payload) != 4 {
+		return 0, fmt.Errorf("int payload must be 4 bytes, got %d", len(payload))
+	}
+	return int32(binary.BigEndian.Uint32(payload)), nil
+}
+
+var encoders = map[string]PayloadEncoder{
+	IdentityEncoding: IdentityPayloadEncoder,
+	FloatEncoding:    FloatPayloadEncoder,
+	IntEncoding:      IntPayloadEncoder,
+}
+
+// DelimitedPayloadFilter splits tokens of the form
+// "term|payload" at the last delimiter, the token keeps
+// the term and the encoded payload is stored with it.
+// Tokens without a delimiter are left unchanged.  To be
+// stored in the index the field must include term
+// vectors.
+type DelimitedPayloadFilter struct {
+	delimiter []byte
+	encoder   PayloadEncoder
+}
+
+func NewDelimitedPayloadFilter(delimiter rune, encoder PayloadEncoder) *DelimitedPayloadFilter {
+	return &DelimitedPayloadFilter{
+		delimiter: []byte(string(delimiter)),
+		encoder:   encoder,
+	}
+}
+
+func (f *DelimitedPayloadFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		i := bytes.LastIndex(token.Term, f.delimiter)
+		if i < 0 {
+			continue
+		}
+		payload := token.Term[i+len(f.delimiter):]
+		token.Payload = f.encoder(payload)
+		token.Term = token.Term[:i]
+	}
+	return input
+}
+
+func DelimitedPayloadFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	delimiter := DefaultDelimiter
+	delimiterVal, ok := config["delimiter"].(string)
+	if ok {
+		runes := []rune(delimiterVal)
+		if len(runes) != 1 {
+			return nil, fmt.Errorf("delimiter must be a single character")
+		}
+		delimiter = runes[0]
+	}
+	encoding := IdentityEncoding
+	encodingVal, ok := config["encoding"].(string)
+	if ok {
+		encoding = encodingVal
+	}
+	encoder, ok := encoders[encoding]
+	if !ok {
+		return nil, fmt.Errorf("unknown payload encoding '%s'", encoding)
+	}
+	return NewDelimitedPayloadFilter(delimiter, encoder), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, DelimitedPayloadFilterConstructor)
+}
@@ -0,0 +1,117 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package delimited_payload_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestDelimitedPayloadFilter(t *testing.T) {
+
+	tests := []struct {
+		delimiter rune
+		encoder   PayloadEncoder
+		input     analysis.TokenStream
+		output    analysis.TokenStream
+	}{
+		{
+			delimiter: DefaultDelimiter,
+			encoder:   IdentityPayloadEncoder,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("quick|JJ"), Position: 1, Start: 0, End: 8},
+				&analysis.Token{Term: []byte("fox"), Position: 2, Start: 9, End: 12},
+				&analysis.Token{Term: []byte("a|b|NN"), Position: 3, Start: 13, End: 19},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("quick"), Position: 1, Start: 0, End: 8, Payload: []byte("JJ")},
+				&analysis.Token{Term: []byte("fox"), Position: 2, Start: 9, End: 12},
+				&analysis.Token{Term: []byte("a|b"), Position: 3, Start: 13, End: 19, Payload: []byte("NN")},
+			},
+		},
+		{
+			delimiter: '^',
+			encoder:   FloatPayloadEncoder,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("boost^2.5")},
+				&analysis.Token{Term: []byte("bad^x")},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("boost"), Payload: []byte{0x40, 0x20, 0, 0}},
+				&analysis.Token{Term: []byte("bad")},
+			},
+		},
+		{
+			delimiter: DefaultDelimiter,
+			encoder:   IntPayloadEncoder,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("rank|-2")},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("rank"), Payload: []byte{0xff, 0xff, 0xff, 0xfe}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		filter := NewDelimitedPayloadFilter(test.delimiter, test.encoder)
+		actual := filter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %#v, got %#v", test.output, actual)
+		}
+	}
+}
+
+func TestDecodePayloads(t *testing.T) {
+	f, err := DecodeFloatPayload(FloatPayloadEncoder([]byte("2.5")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != 2.5 {
+		t.Errorf("expected 2.5, got %f", f)
+	}
+	i, err := DecodeIntPayload(IntPayloadEncoder([]byte("-7")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != -7 {
+		t.Errorf("expected -7, got %d", i)
+	}
+	_, err = DecodeIntPayload([]byte("x"))
+	if err == nil {
+		t.Errorf("expected error for short payload")
+	}
+}
+
+func TestDelimitedPayloadFilterConstructor(t *testing.T) {
+	cache := registry.NewCache()
+	_, err := DelimitedPayloadFilterConstructor(map[string]interface{}{
+		"delimiter": "^",
+		"encoding":  FloatEncoding,
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DelimitedPayloadFilterConstructor(map[string]interface{}{
+		"delimiter": "||",
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for multi character delimiter")
+	}
+	_, err = DelimitedPayloadFilterConstructor(map[string]interface{}{
+		"encoding": "base64",
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for unknown encoding")
+	}
+}
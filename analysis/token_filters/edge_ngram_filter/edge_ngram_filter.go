@@ -25,17 +25,24 @@ type Side bool
 const BACK Side = true
 const FRONT Side = false
 
+// EdgeNgramFilter replaces each token with the ngrams
+// of minLength to maxLength runes taken from its front,
+// or from its back.  When preserveOriginal is set the
+// original token is kept, at the same position as its
+// ngrams.
 type EdgeNgramFilter struct {
-	back      Side
-	minLength int
-	maxLength int
+	back             Side
+	minLength        int
+	maxLength        int
+	preserveOriginal bool
 }
 
-func NewEdgeNgramFilter(side Side, minLength, maxLength int) *EdgeNgramFilter {
+func NewEdgeNgramFilter(side Side, minLength, maxLength int, preserveOriginal bool) *EdgeNgramFilter {
 	return &EdgeNgramFilter{
-		back:      side,
-		minLength: minLength,
-		maxLength: maxLength,
+		back:             side,
+		minLength:        minLength,
+		maxLength:        maxLength,
+		preserveOriginal: preserveOriginal,
 	}
 }
 
@@ -45,12 +52,18 @@ func (s *EdgeNgramFilter) Filter(input analysis.TokenStream) analysis.TokenStrea
 	for _, token := range input {
 		runeCount := utf8.RuneCount(token.Term)
 		runes := bytes.Runes(token.Term)
+		if s.preserveOriginal {
+			rv = append(rv, token)
+		}
 		if s.back {
 			i := runeCount
 			// index of the starting rune for this token
 			for ngramSize := s.minLength; ngramSize <= s.maxLength; ngramSize++ {
 				// build an ngram of this size starting at i
-				if i-ngramSize > 0 {
+				if i-ngramSize >= 0 {
+					if s.preserveOriginal && ngramSize == runeCount {
+						continue
+					}
 					ngramTerm := buildTermFromRunes(runes[i-ngramSize : i])
 					token := analysis.Token{
 						Position: token.Position,
@@ -68,6 +81,9 @@ func (s *EdgeNgramFilter) Filter(input analysis.TokenStream) analysis.TokenStrea
 			for ngramSize := s.minLength; ngramSize <= s.maxLength; ngramSize++ {
 				// build an ngram of this size starting at i
 				if i+ngramSize <= runeCount {
+					if s.preserveOriginal && ngramSize == runeCount {
+						continue
+					}
 					ngramTerm := buildTermFromRunes(runes[i : i+ngramSize])
 					token := analysis.Token{
 						Position: token.Position,
@@ -101,6 +117,17 @@ func EdgeNgramFilterConstructor(config map[string]interface{}, cache *registry.C
 	if ok && back {
 		side = BACK
 	}
+	sideVal, ok := config["side"].(string)
+	if ok {
+		switch sideVal {
+		case "front":
+			side = FRONT
+		case "back":
+			side = BACK
+		default:
+			return nil, fmt.Errorf("side must be 'front' or 'back'")
+		}
+	}
 	minVal, ok := config["min"].(float64)
 	if !ok {
 		return nil, fmt.Errorf("must specify min")
@@ -112,7 +139,9 @@ func EdgeNgramFilterConstructor(config map[string]interface{}, cache *registry.C
 	}
 	max := int(maxVal)
 
-	return NewEdgeNgramFilter(side, min, max), nil
+	preserveOriginal, _ := config["preserve_original"].(bool)
+
+	return NewEdgeNgramFilter(side, min, max, preserveOriginal), nil
 }
 
 func init() {
@@ -14,16 +14,18 @@ import (
 	"testing"
 
 	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
 )
 
 func TestEdgeNgramFilter(t *testing.T) {
 
 	tests := []struct {
-		side   Side
-		min    int
-		max    int
-		input  analysis.TokenStream
-		output analysis.TokenStream
+		side             Side
+		min              int
+		max              int
+		preserveOriginal bool
+		input            analysis.TokenStream
+		output           analysis.TokenStream
 	}{
 		{
 			side: FRONT,
@@ -130,13 +132,138 @@ func TestEdgeNgramFilter(t *testing.T) {
 				},
 			},
 		},
+		// the whole token is a valid gram from the back
+		{
+			side: BACK,
+			min:  2,
+			max:  5,
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term: []byte("abc"),
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term: []byte("bc"),
+				},
+				&analysis.Token{
+					Term: []byte("abc"),
+				},
+			},
+		},
+		{
+			side:             FRONT,
+			min:              1,
+			max:              3,
+			preserveOriginal: true,
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("abcde"),
+					Position: 1,
+				},
+				&analysis.Token{
+					Term:     []byte("ab"),
+					Position: 2,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("abcde"),
+					Position: 1,
+				},
+				&analysis.Token{
+					Term:     []byte("a"),
+					Position: 1,
+				},
+				&analysis.Token{
+					Term:     []byte("ab"),
+					Position: 1,
+				},
+				&analysis.Token{
+					Term:     []byte("abc"),
+					Position: 1,
+				},
+				&analysis.Token{
+					Term:     []byte("ab"),
+					Position: 2,
+				},
+				&analysis.Token{
+					Term:     []byte("a"),
+					Position: 2,
+				},
+			},
+		},
+		{
+			side:             BACK,
+			min:              1,
+			max:              2,
+			preserveOriginal: true,
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("abc"),
+					Position: 1,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("abc"),
+					Position: 1,
+				},
+				&analysis.Token{
+					Term:     []byte("c"),
+					Position: 1,
+				},
+				&analysis.Token{
+					Term:     []byte("bc"),
+					Position: 1,
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
-		edgeNgramFilter := NewEdgeNgramFilter(test.side, test.min, test.max)
+		edgeNgramFilter := NewEdgeNgramFilter(test.side, test.min, test.max, test.preserveOriginal)
 		actual := edgeNgramFilter.Filter(test.input)
 		if !reflect.DeepEqual(actual, test.output) {
 			t.Errorf("expected %s, got %s", test.output, actual)
 		}
 	}
 }
+
+func TestEdgeNgramFilterConstructor(t *testing.T) {
+	cache := registry.NewCache()
+	filter, err := EdgeNgramFilterConstructor(map[string]interface{}{
+		"side":              "back",
+		"min":               2.0,
+		"max":               2.0,
+		"preserve_original": true,
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := filter.Filter(analysis.TokenStream{
+		&analysis.Token{
+			Term: []byte("abc"),
+		},
+	})
+	expected := analysis.TokenStream{
+		&analysis.Token{
+			Term: []byte("abc"),
+		},
+		&analysis.Token{
+			Term: []byte("bc"),
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+
+	_, err = EdgeNgramFilterConstructor(map[string]interface{}{
+		"side": "middle",
+		"min":  1.0,
+		"max":  2.0,
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for invalid side")
+	}
+}
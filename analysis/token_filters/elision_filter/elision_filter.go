@@ -12,6 +12,7 @@ package elision_filter
 import (
 	"bytes"
 	"fmt"
+	"unicode/utf8"
 
 	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/registry"
@@ -43,23 +44,58 @@ func (s *ElisionFilter) Filter(input analysis.TokenStream) analysis.TokenStream
 			// see if the prefix matches one of the articles
 			_, articleMatch := s.articles[string(prefix)]
 			if articleMatch {
-				token.Term = token.Term[firstApostrophe+1:]
+				_, size := utf8.DecodeRune(token.Term[firstApostrophe:])
+				token.Term = token.Term[firstApostrophe+size:]
 			}
 		}
 	}
 	return input
 }
 
+// ElisionFilterConstructor builds the articles from, in
+// order of preference, a named token map, an inline list
+// of articles, a file of articles or the articles
+// registered for a language such as "fr", which uses the
+// "articles_fr" token map
 func ElisionFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
 	articlesTokenMapName, ok := config["articles_token_map"].(string)
-	if !ok {
-		return nil, fmt.Errorf("must specify articles_token_map")
+	if ok {
+		articlesTokenMap, err := cache.TokenMapNamed(articlesTokenMapName)
+		if err != nil {
+			return nil, fmt.Errorf("error building elision filter: %v", err)
+		}
+		return NewElisionFilter(articlesTokenMap), nil
+	}
+
+	articlesTokenMap := analysis.NewTokenMap()
+	articles, ok := config["articles"].([]interface{})
+	if ok {
+		for _, article := range articles {
+			articleStr, ok := article.(string)
+			if !ok {
+				return nil, fmt.Errorf("article must be a string")
+			}
+			articlesTokenMap.AddToken(articleStr)
+		}
+		return NewElisionFilter(articlesTokenMap), nil
+	}
+	articlesFile, ok := config["articles_file"].(string)
+	if ok {
+		err := articlesTokenMap.LoadFile(articlesFile)
+		if err != nil {
+			return nil, fmt.Errorf("error building elision filter: %v", err)
+		}
+		return NewElisionFilter(articlesTokenMap), nil
 	}
-	articlesTokenMap, err := cache.TokenMapNamed(articlesTokenMapName)
-	if err != nil {
-		return nil, fmt.Errorf("error building elision filter: %v", err)
+	language, ok := config["language"].(string)
+	if ok {
+		languageTokenMap, err := cache.TokenMapNamed("articles_" + language)
+		if err != nil {
+			return nil, fmt.Errorf("error building elision filter, no articles for language '%s': %v", language, err)
+		}
+		return NewElisionFilter(languageTokenMap), nil
 	}
-	return NewElisionFilter(articlesTokenMap), nil
+	return nil, fmt.Errorf("must specify articles_token_map, articles, articles_file or language")
 }
 
 func init() {
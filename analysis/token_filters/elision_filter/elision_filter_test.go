@@ -66,3 +66,65 @@ func TestElisionFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestElisionFilterConstructor(t *testing.T) {
+
+	cache := registry.NewCache()
+	_, err := cache.DefineTokenMap("articles_xx", map[string]interface{}{
+		"type":   token_map.Name,
+		"tokens": []interface{}{"qu"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		config map[string]interface{}
+		input  string
+		output string
+	}{
+		{
+			config: map[string]interface{}{
+				"articles": []interface{}{"l", "d"},
+			},
+			input:  "l'avion",
+			output: "avion",
+		},
+		{
+			config: map[string]interface{}{
+				"articles": []interface{}{"l", "d"},
+			},
+			input:  "qu'il",
+			output: "qu'il",
+		},
+		{
+			config: map[string]interface{}{
+				"language": "xx",
+			},
+			input:  "qu’il",
+			output: "il",
+		},
+	}
+
+	for _, test := range tests {
+		filter, err := ElisionFilterConstructor(test.config, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := filter.Filter(analysis.TokenStream{
+			&analysis.Token{
+				Term: []byte(test.input),
+			},
+		})
+		if string(actual[0].Term) != test.output {
+			t.Errorf("expected %s, got %s", test.output, actual[0].Term)
+		}
+	}
+
+	_, err = ElisionFilterConstructor(map[string]interface{}{
+		"language": "zz",
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for language without articles")
+	}
+}
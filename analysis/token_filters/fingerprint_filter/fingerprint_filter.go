@@ -0,0 +1,111 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package fingerprint_filter
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "fingerprint"
+
+const DefaultSeparator = " "
+const DefaultMaxOutputSize = 255
+
+// FingerprintFilter sorts the terms of the token stream,
+// removes duplicates and joins them into a single token.
+// Differently ordered or repeated versions of the same
+// text therefore share a fingerprint.  If the fingerprint
+// would be longer than maxOutputSize bytes no token is
+// produced.
+type FingerprintFilter struct {
+	separator     []byte
+	maxOutputSize int
+}
+
+func NewFingerprintFilter(separator string, maxOutputSize int) *FingerprintFilter {
+	return &FingerprintFilter{
+		separator:     []byte(separator),
+		maxOutputSize: maxOutputSize,
+	}
+}
+
+func (f *FingerprintFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	if len(input) == 0 {
+		return input
+	}
+
+	terms := make([][]byte, 0, len(input))
+	start := input[0].Start
+	end := input[0].End
+	for _, token := range input {
+		terms = append(terms, token.Term)
+		if token.Start < start {
+			start = token.Start
+		}
+		if token.End > end {
+			end = token.End
+		}
+	}
+	sort.Sort(byteSlices(terms))
+
+	fingerprint := make([]byte, 0, f.maxOutputSize)
+	var last []byte
+	for i, term := range terms {
+		if i > 0 && bytes.Equal(term, last) {
+			continue
+		}
+		if len(fingerprint) > 0 {
+			fingerprint = append(fingerprint, f.separator...)
+		}
+		fingerprint = append(fingerprint, term...)
+		if f.maxOutputSize > 0 && len(fingerprint) > f.maxOutputSize {
+			return analysis.TokenStream{}
+		}
+		last = term
+	}
+
+	return analysis.TokenStream{
+		&analysis.Token{
+			Term:     fingerprint,
+			Position: 1,
+			Start:    start,
+			End:      end,
+			Type:     analysis.AlphaNumeric,
+		},
+	}
+}
+
+type byteSlices [][]byte
+
+func (b byteSlices) Len() int           { return len(b) }
+func (b byteSlices) Less(i, j int) bool { return bytes.Compare(b[i], b[j]) < 0 }
+func (b byteSlices) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func FingerprintFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	separator := DefaultSeparator
+	separatorVal, ok := config["separator"].(string)
+	if ok {
+		separator = separatorVal
+	}
+	maxOutputSize := DefaultMaxOutputSize
+	maxOutputSizeVal, ok := config["max_output_size"].(float64)
+	if ok {
+		maxOutputSize = int(maxOutputSizeVal)
+	}
+	return NewFingerprintFilter(separator, maxOutputSize), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, FingerprintFilterConstructor)
+}
@@ -0,0 +1,62 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package fingerprint_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+func TestFingerprintFilter(t *testing.T) {
+
+	tests := []struct {
+		maxOutputSize int
+		input         analysis.TokenStream
+		output        analysis.TokenStream
+	}{
+		{
+			maxOutputSize: DefaultMaxOutputSize,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("the"), Position: 1, Start: 0, End: 3},
+				&analysis.Token{Term: []byte("quick"), Position: 2, Start: 4, End: 9},
+				&analysis.Token{Term: []byte("brown"), Position: 3, Start: 10, End: 15},
+				&analysis.Token{Term: []byte("the"), Position: 4, Start: 16, End: 19},
+				&analysis.Token{Term: []byte("fox"), Position: 5, Start: 20, End: 23},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("brown fox quick the"), Position: 1, Start: 0, End: 23, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			maxOutputSize: DefaultMaxOutputSize,
+			input:         analysis.TokenStream{},
+			output:        analysis.TokenStream{},
+		},
+		// too long, no fingerprint
+		{
+			maxOutputSize: 8,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("quick"), Position: 1, Start: 0, End: 5},
+				&analysis.Token{Term: []byte("brown"), Position: 2, Start: 6, End: 11},
+			},
+			output: analysis.TokenStream{},
+		},
+	}
+
+	for _, test := range tests {
+		filter := NewFingerprintFilter(DefaultSeparator, test.maxOutputSize)
+		actual := filter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %s, got %s", test.output, actual)
+		}
+	}
+}
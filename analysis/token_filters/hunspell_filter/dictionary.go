@@ -0,0 +1,328 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package hunspell_filter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+var encodings = map[string]encoding.Encoding{
+	"ISO8859-1":        charmap.ISO8859_1,
+	"ISO8859-2":        charmap.ISO8859_2,
+	"ISO8859-3":        charmap.ISO8859_3,
+	"ISO8859-4":        charmap.ISO8859_4,
+	"ISO8859-5":        charmap.ISO8859_5,
+	"ISO8859-6":        charmap.ISO8859_6,
+	"ISO8859-7":        charmap.ISO8859_7,
+	"ISO8859-8":        charmap.ISO8859_8,
+	"ISO8859-9":        charmap.ISO8859_9,
+	"ISO8859-10":       charmap.ISO8859_10,
+	"ISO8859-13":       charmap.ISO8859_13,
+	"ISO8859-14":       charmap.ISO8859_14,
+	"ISO8859-15":       charmap.ISO8859_15,
+	"KOI8-R":           charmap.KOI8R,
+	"KOI8-U":           charmap.KOI8U,
+	"microsoft-cp1250": charmap.Windows1250,
+	"microsoft-cp1251": charmap.Windows1251,
+	"microsoft-cp1252": charmap.Windows1252,
+	"microsoft-cp1253": charmap.Windows1253,
+	"microsoft-cp1254": charmap.Windows1254,
+	"microsoft-cp1255": charmap.Windows1255,
+	"microsoft-cp1256": charmap.Windows1256,
+	"microsoft-cp1257": charmap.Windows1257,
+	"microsoft-cp1258": charmap.Windows1258,
+}
+
+type flagType int
+
+const (
+	flagChar flagType = iota
+	flagLong
+	flagNum
+	flagUTF8
+)
+
+// an affix is a single PFX or SFX rule, strip is removed
+// from the stem and add appended in its place when the
+// condition matches the stem
+type affix struct {
+	flag         string
+	crossProduct bool
+	strip        string
+	add          string
+	condition    *regexp.Regexp
+	// flags of further affixes which may be applied
+	// after this one
+	continuation []string
+}
+
+// Dictionary holds the words and affix rules of a
+// Hunspell dictionary, as read from its .aff and .dic
+// files
+type Dictionary struct {
+	words     map[string][][]string
+	prefixes  map[string][]*affix
+	suffixes  map[string][]*affix
+	flagType  flagType
+	needAffix string
+	encoding  encoding.Encoding
+}
+
+func newDictionary() *Dictionary {
+	return &Dictionary{
+		words:    make(map[string][][]string),
+		prefixes: make(map[string][]*affix),
+		suffixes: make(map[string][]*affix),
+	}
+}
+
+// LoadDictionaryFiles reads the named .aff and .dic files
+func LoadDictionaryFiles(affFilename, dicFilename string) (*Dictionary, error) {
+	aff, err := os.Open(affFilename)
+	if err != nil {
+		return nil, err
+	}
+	defer aff.Close()
+	dic, err := os.Open(dicFilename)
+	if err != nil {
+		return nil, err
+	}
+	defer dic.Close()
+	return LoadDictionary(aff, dic)
+}
+
+// LoadDictionary reads a Hunspell dictionary, the
+// character encoding of both is given by the SET
+// directive of the affix file
+func LoadDictionary(aff, dic io.Reader) (*Dictionary, error) {
+	rv := newDictionary()
+	affData, err := ioutil.ReadAll(aff)
+	if err != nil {
+		return nil, err
+	}
+	// the encoding must be known before the rest of
+	// the affix file can be decoded
+	err = rv.detectEncoding(affData)
+	if err != nil {
+		return nil, err
+	}
+	affData, err = rv.decode(affData)
+	if err != nil {
+		return nil, err
+	}
+	err = rv.loadAffixes(affData)
+	if err != nil {
+		return nil, err
+	}
+
+	dicData, err := ioutil.ReadAll(dic)
+	if err != nil {
+		return nil, err
+	}
+	dicData, err = rv.decode(dicData)
+	if err != nil {
+		return nil, err
+	}
+	err = rv.loadWords(dicData)
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (d *Dictionary) detectEncoding(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "SET" {
+			if fields[1] == "UTF-8" {
+				return nil
+			}
+			enc, ok := encodings[fields[1]]
+			if !ok {
+				return fmt.Errorf("unsupported hunspell encoding '%s'", fields[1])
+			}
+			d.encoding = enc
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+func (d *Dictionary) decode(data []byte) ([]byte, error) {
+	// strip any byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if d.encoding == nil {
+		return data, nil
+	}
+	return d.encoding.NewDecoder().Bytes(data)
+}
+
+func (d *Dictionary) loadAffixes(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "FLAG":
+			switch fields[1] {
+			case "long":
+				d.flagType = flagLong
+			case "num":
+				d.flagType = flagNum
+			case "UTF-8":
+				d.flagType = flagUTF8
+			}
+		case "NEEDAFFIX":
+			d.needAffix = fields[1]
+		case "PFX", "SFX":
+			if len(fields) < 4 {
+				return fmt.Errorf("invalid affix header: %s", scanner.Text())
+			}
+			count, err := strconv.Atoi(fields[3])
+			if err != nil {
+				return fmt.Errorf("invalid affix header: %s", scanner.Text())
+			}
+			err = d.loadAffixRules(scanner, fields[0], fields[1], fields[2] == "Y", count)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func (d *Dictionary) loadAffixRules(scanner *bufio.Scanner, kind, flag string, crossProduct bool, count int) error {
+	for i := 0; i < count; i++ {
+		if !scanner.Scan() {
+			return fmt.Errorf("expected %d rules for affix %s", count, flag)
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != kind || fields[1] != flag {
+			return fmt.Errorf("invalid affix rule: %s", scanner.Text())
+		}
+		a := &affix{
+			flag:         flag,
+			crossProduct: crossProduct,
+		}
+		if fields[2] != "0" {
+			a.strip = fields[2]
+		}
+		add := fields[3]
+		slash := strings.Index(add, "/")
+		if slash >= 0 {
+			a.continuation = d.parseFlags(add[slash+1:])
+			add = add[:slash]
+		}
+		if add != "0" {
+			a.add = add
+		}
+		condition := "."
+		if len(fields) > 4 {
+			condition = fields[4]
+		}
+		var err error
+		if kind == "PFX" {
+			a.condition, err = regexp.Compile("^" + condition)
+			d.prefixes[a.add] = append(d.prefixes[a.add], a)
+		} else {
+			a.condition, err = regexp.Compile(condition + "$")
+			d.suffixes[a.add] = append(d.suffixes[a.add], a)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid affix condition '%s': %v", condition, err)
+		}
+	}
+	return nil
+}
+
+func (d *Dictionary) parseFlags(flags string) []string {
+	rv := make([]string, 0, len(flags))
+	switch d.flagType {
+	case flagLong:
+		for len(flags) > 0 {
+			_, size := utf8.DecodeRuneInString(flags)
+			if len(flags) > size {
+				_, size2 := utf8.DecodeRuneInString(flags[size:])
+				size += size2
+			}
+			rv = append(rv, flags[:size])
+			flags = flags[size:]
+		}
+	case flagNum:
+		for _, flag := range strings.Split(flags, ",") {
+			if flag != "" {
+				rv = append(rv, flag)
+			}
+		}
+	default:
+		// a single byte per flag is only guaranteed for
+		// ascii flags, so always split by rune
+		for _, r := range flags {
+			rv = append(rv, string(r))
+		}
+	}
+	return rv
+}
+
+func (d *Dictionary) loadWords(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	// the first line holds the approximate word count
+	scanner.Scan()
+	for scanner.Scan() {
+		line := scanner.Text()
+		// morphological fields follow a tab or space
+		end := strings.IndexAny(line, "\t ")
+		if end >= 0 {
+			line = line[:end]
+		}
+		if line == "" || strings.HasPrefix(line, "/") {
+			continue
+		}
+		word := line
+		var flags []string
+		slash := strings.Index(line, "/")
+		if slash >= 0 {
+			word = line[:slash]
+			flags = d.parseFlags(line[slash+1:])
+		}
+		d.words[word] = append(d.words[word], flags)
+	}
+	return scanner.Err()
+}
+
+// lookup returns the flag sets of the word, or nil if
+// it is not in the dictionary
+func (d *Dictionary) lookup(word string) [][]string {
+	return d.words[word]
+}
+
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
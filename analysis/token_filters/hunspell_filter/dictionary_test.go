@@ -0,0 +1,58 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package hunspell_filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDictionaryStem(t *testing.T) {
+	dictionary, err := LoadDictionaryFiles("test.aff", "test.dic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"tries":       {"try"},
+		"trying":      {"try"},
+		"driving":     {"drive"},
+		"walks":       {"walk"},
+		"unwalking":   {"walk"},
+		"unhappy":     {"happy"},
+		"helpful":     {"help"},
+		"helpfulness": {"help"},
+		"help":        {"help"},
+		"drives":      {},
+		"table":       {},
+	}
+	for input, expected := range tests {
+		actual := dictionary.Stem(input)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("expected stems of %s to be %v, got %v", input, expected, actual)
+		}
+	}
+}
+
+func TestDictionaryEncoding(t *testing.T) {
+	aff := "SET ISO8859-1\nSFX S Y 1\nSFX S 0 s .\n"
+	// "café/S" in ISO8859-1
+	dic := "1\ncaf\xe9/S\n"
+	dictionary, err := LoadDictionary(strings.NewReader(aff), strings.NewReader(dic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := dictionary.Stem("cafés")
+	expected := []string{"café"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,111 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package hunspell_filter
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "hunspell"
+
+// HunspellFilter replaces each token with its stems
+// from a Hunspell dictionary, all at the position of
+// the original token.  Tokens without any stem are left
+// unchanged.
+type HunspellFilter struct {
+	dictionary  *Dictionary
+	longestOnly bool
+	ignoreCase  bool
+}
+
+func NewHunspellFilter(dictionary *Dictionary, longestOnly, ignoreCase bool) *HunspellFilter {
+	return &HunspellFilter{
+		dictionary:  dictionary,
+		longestOnly: longestOnly,
+		ignoreCase:  ignoreCase,
+	}
+}
+
+func (f *HunspellFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input))
+
+	for _, token := range input {
+		if token.KeyWord {
+			rv = append(rv, token)
+			continue
+		}
+		term := token.Term
+		if f.ignoreCase {
+			term = bytes.ToLower(term)
+		}
+		stems := f.dictionary.Stem(string(term))
+		if len(stems) == 0 {
+			rv = append(rv, token)
+			continue
+		}
+		if f.longestOnly {
+			longest := stems[0]
+			for _, stem := range stems[1:] {
+				if len(stem) > len(longest) {
+					longest = stem
+				}
+			}
+			stems = stems[:1]
+			stems[0] = longest
+		}
+		for _, stem := range stems {
+			newtoken := analysis.Token{
+				Term:     []byte(stem),
+				Position: token.Position,
+				Start:    token.Start,
+				End:      token.End,
+				Type:     token.Type,
+			}
+			rv = append(rv, &newtoken)
+		}
+	}
+
+	return rv
+}
+
+func HunspellFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	affFile, ok := config["aff_file"].(string)
+	if !ok {
+		return nil, fmt.Errorf("must specify aff_file")
+	}
+	dicFile, ok := config["dic_file"].(string)
+	if !ok {
+		return nil, fmt.Errorf("must specify dic_file")
+	}
+	longestOnly := false
+	longestVal, ok := config["longest_only"].(bool)
+	if ok {
+		longestOnly = longestVal
+	}
+	ignoreCase := false
+	ignoreCaseVal, ok := config["ignore_case"].(bool)
+	if ok {
+		ignoreCase = ignoreCaseVal
+	}
+
+	dictionary, err := LoadDictionaryFiles(affFile, dicFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading hunspell dictionary: %v", err)
+	}
+	return NewHunspellFilter(dictionary, longestOnly, ignoreCase), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, HunspellFilterConstructor)
+}
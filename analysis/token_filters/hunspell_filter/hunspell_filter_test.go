@@ -0,0 +1,94 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package hunspell_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestHunspellFilter(t *testing.T) {
+
+	inputTokenStream := analysis.TokenStream{
+		&analysis.Token{
+			Term:     []byte("Tries"),
+			Start:    0,
+			End:      5,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("driving"),
+			Start:    6,
+			End:      13,
+			Position: 2,
+		},
+		&analysis.Token{
+			Term:     []byte("walks"),
+			Start:    14,
+			End:      19,
+			Position: 3,
+			KeyWord:  true,
+		},
+		&analysis.Token{
+			Term:     []byte("tables"),
+			Start:    20,
+			End:      26,
+			Position: 4,
+		},
+	}
+
+	expectedTokenStream := analysis.TokenStream{
+		&analysis.Token{
+			Term:     []byte("try"),
+			Start:    0,
+			End:      5,
+			Position: 1,
+		},
+		&analysis.Token{
+			Term:     []byte("drive"),
+			Start:    6,
+			End:      13,
+			Position: 2,
+		},
+		&analysis.Token{
+			Term:     []byte("walks"),
+			Start:    14,
+			End:      19,
+			Position: 3,
+			KeyWord:  true,
+		},
+		&analysis.Token{
+			Term:     []byte("tables"),
+			Start:    20,
+			End:      26,
+			Position: 4,
+		},
+	}
+
+	cache := registry.NewCache()
+	hunspellConfig := map[string]interface{}{
+		"type":        Name,
+		"aff_file":    "test.aff",
+		"dic_file":    "test.dic",
+		"ignore_case": true,
+	}
+	hunspellFilter, err := cache.DefineTokenFilter("hunspell_test", hunspellConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ouputTokenStream := hunspellFilter.Filter(inputTokenStream)
+	if !reflect.DeepEqual(ouputTokenStream, expectedTokenStream) {
+		t.Errorf("expected %#v got %#v", expectedTokenStream, ouputTokenStream)
+	}
+}
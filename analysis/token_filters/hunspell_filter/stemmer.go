@@ -0,0 +1,113 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package hunspell_filter
+
+import (
+	"unicode/utf8"
+)
+
+// Stem returns the dictionary words from which word can
+// be derived, it handles single prefixes and suffixes,
+// two suffixes when the inner one allows the outer one
+// as continuation, and cross product prefix and suffix
+// combinations
+func (d *Dictionary) Stem(word string) []string {
+	rv := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(stem string) {
+		if !seen[stem] {
+			seen[stem] = true
+			rv = append(rv, stem)
+		}
+	}
+
+	if d.checkStem(word) {
+		add(word)
+	}
+
+	d.eachSuffix(word, func(a *affix, stem string) {
+		if d.checkStem(stem, a.flag) {
+			add(stem)
+		}
+		// twofold suffixes
+		d.eachSuffix(stem, func(b *affix, inner string) {
+			if hasFlag(b.continuation, a.flag) && d.checkStem(inner, b.flag) {
+				add(inner)
+			}
+		})
+		if a.crossProduct {
+			d.eachPrefix(stem, func(p *affix, inner string) {
+				if p.crossProduct && d.checkStem(inner, a.flag, p.flag) {
+					add(inner)
+				}
+			})
+		}
+	})
+
+	d.eachPrefix(word, func(p *affix, stem string) {
+		if d.checkStem(stem, p.flag) {
+			add(stem)
+		}
+	})
+
+	return rv
+}
+
+// checkStem reports whether the word is in the
+// dictionary with all of the flags, without any affix
+// flags the word must not require an affix
+func (d *Dictionary) checkStem(word string, flags ...string) bool {
+	for _, wordFlags := range d.lookup(word) {
+		if len(flags) == 0 && d.needAffix != "" && hasFlag(wordFlags, d.needAffix) {
+			continue
+		}
+		matched := true
+		for _, flag := range flags {
+			if !hasFlag(wordFlags, flag) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// eachSuffix calls f with every suffix rule which could
+// have produced word, and the stem it was applied to
+func (d *Dictionary) eachSuffix(word string, f func(*affix, string)) {
+	for i := len(word); i > 0; {
+		for _, a := range d.suffixes[word[i:]] {
+			stem := word[:i] + a.strip
+			if a.condition.MatchString(stem) {
+				f(a, stem)
+			}
+		}
+		_, size := utf8.DecodeLastRuneInString(word[:i])
+		i -= size
+	}
+}
+
+// eachPrefix calls f with every prefix rule which could
+// have produced word, and the stem it was applied to
+func (d *Dictionary) eachPrefix(word string, f func(*affix, string)) {
+	for i := 0; i < len(word); {
+		for _, a := range d.prefixes[word[:i]] {
+			stem := a.strip + word[i:]
+			if a.condition.MatchString(stem) {
+				f(a, stem)
+			}
+		}
+		_, size := utf8.DecodeRuneInString(word[i:])
+		i += size
+	}
+}
@@ -0,0 +1,111 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build icu full
+
+package icu_folding_filter
+
+// #cgo LDFLAGS: -licuuc -licudata
+// #include "unicode/utypes.h"
+// #include "unicode/unorm2.h"
+import "C"
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "icu_folding"
+
+// IcuFoldingFilter folds case, removes accents and other
+// nonspacing marks, and folds compatibility characters
+// such as full-width latin letters and ligatures.  Terms
+// are decomposed with NFKD, marks are stripped and the
+// result is recomposed with the ICU NFKC_Casefold
+// normalizer.
+type IcuFoldingFilter struct {
+	decompose *C.UNormalizer2
+	casefold  *C.UNormalizer2
+}
+
+func NewIcuFoldingFilter() (*IcuFoldingFilter, error) {
+	var err C.UErrorCode = C.U_ZERO_ERROR
+	decompose := C.unorm2_getNFKDInstance(&err)
+	if err > C.U_ZERO_ERROR {
+		return nil, fmt.Errorf("error opening ICU NFKD normalizer: %d", int(err))
+	}
+	casefold := C.unorm2_getNFKCCasefoldInstance(&err)
+	if err > C.U_ZERO_ERROR {
+		return nil, fmt.Errorf("error opening ICU NFKC_Casefold normalizer: %d", int(err))
+	}
+	return &IcuFoldingFilter{
+		decompose: decompose,
+		casefold:  casefold,
+	}, nil
+}
+
+func (f *IcuFoldingFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		decomposed, ok := normalize(f.decompose, utf16.Encode([]rune(string(token.Term))))
+		if !ok {
+			continue
+		}
+		runes := utf16.Decode(decomposed)
+		unmarked := make([]rune, 0, len(runes))
+		for _, r := range runes {
+			if !unicode.Is(unicode.Mn, r) {
+				unmarked = append(unmarked, r)
+			}
+		}
+		folded, ok := normalize(f.casefold, utf16.Encode(unmarked))
+		if !ok {
+			continue
+		}
+		token.Term = []byte(string(utf16.Decode(folded)))
+	}
+	return input
+}
+
+func normalize(normalizer *C.UNormalizer2, input []uint16) ([]uint16, bool) {
+	if len(input) == 0 {
+		return input, true
+	}
+	src := (*C.UChar)(unsafe.Pointer(&input[0]))
+	output := make([]uint16, len(input)*2)
+	for {
+		var err C.UErrorCode = C.U_ZERO_ERROR
+		n := C.unorm2_normalize(normalizer, src, C.int32_t(len(input)),
+			(*C.UChar)(unsafe.Pointer(&output[0])), C.int32_t(len(output)), &err)
+		if err == C.U_BUFFER_OVERFLOW_ERROR {
+			output = make([]uint16, int(n))
+			continue
+		}
+		if err > C.U_ZERO_ERROR {
+			return nil, false
+		}
+		return output[:int(n)], true
+	}
+}
+
+func IcuFoldingFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	filter, err := NewIcuFoldingFilter()
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, IcuFoldingFilterConstructor)
+}
@@ -0,0 +1,75 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build icu full
+
+package icu_folding_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+func TestIcuFoldingFilter(t *testing.T) {
+
+	tests := []struct {
+		input  analysis.TokenStream
+		output analysis.TokenStream
+	}{
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term: []byte("Résumé"),
+				},
+				&analysis.Token{
+					Term: []byte("ＢＬＥＶＥ"),
+				},
+				&analysis.Token{
+					Term: []byte("Straße"),
+				},
+				&analysis.Token{
+					Term: []byte("ﬁnancial"),
+				},
+				&analysis.Token{
+					Term: []byte("ΆΡΗΣ"),
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term: []byte("resume"),
+				},
+				&analysis.Token{
+					Term: []byte("bleve"),
+				},
+				&analysis.Token{
+					Term: []byte("strasse"),
+				},
+				&analysis.Token{
+					Term: []byte("financial"),
+				},
+				&analysis.Token{
+					Term: []byte("αρησ"),
+				},
+			},
+		},
+	}
+
+	filter, err := NewIcuFoldingFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		actual := filter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v", test.output, actual)
+		}
+	}
+}
@@ -39,16 +39,41 @@ func (f *KeyWordMarkerFilter) Filter(input analysis.TokenStream) analysis.TokenS
 	return input
 }
 
+// KeyWordMarkerFilterConstructor builds the keyword list
+// from a named "keywords_token_map", an inline list of
+// "keywords" or a "keywords_file" with one or more
+// keywords per line.
 func KeyWordMarkerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
 	keywordsTokenMapName, ok := config["keywords_token_map"].(string)
-	if !ok {
-		return nil, fmt.Errorf("must specify keywords_token_map")
+	if ok {
+		keywordsTokenMap, err := cache.TokenMapNamed(keywordsTokenMapName)
+		if err != nil {
+			return nil, fmt.Errorf("error building keyword marker filter: %v", err)
+		}
+		return NewKeyWordMarkerFilter(keywordsTokenMap), nil
 	}
-	keywordsTokenMap, err := cache.TokenMapNamed(keywordsTokenMapName)
-	if err != nil {
-		return nil, fmt.Errorf("error building keyword marker filter: %v", err)
+
+	keywordsTokenMap := analysis.NewTokenMap()
+	keywords, ok := config["keywords"].([]interface{})
+	if ok {
+		for _, keyword := range keywords {
+			keywordStr, ok := keyword.(string)
+			if !ok {
+				return nil, fmt.Errorf("keyword must be a string")
+			}
+			keywordsTokenMap.AddToken(keywordStr)
+		}
+		return NewKeyWordMarkerFilter(keywordsTokenMap), nil
+	}
+	keywordsFile, ok := config["keywords_file"].(string)
+	if ok {
+		err := keywordsTokenMap.LoadFile(keywordsFile)
+		if err != nil {
+			return nil, fmt.Errorf("error building keyword marker filter: %v", err)
+		}
+		return NewKeyWordMarkerFilter(keywordsTokenMap), nil
 	}
-	return NewKeyWordMarkerFilter(keywordsTokenMap), nil
+	return nil, fmt.Errorf("must specify keywords_token_map, keywords or keywords_file")
 }
 
 func init() {
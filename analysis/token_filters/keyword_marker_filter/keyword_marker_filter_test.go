@@ -10,10 +10,14 @@
 package keyword_filter
 
 import (
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
 
 	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/token_map"
+	"github.com/blevesearch/bleve/registry"
 )
 
 func TestKeyWordMarkerFilter(t *testing.T) {
@@ -66,3 +70,70 @@ func TestKeyWordMarkerFilter(t *testing.T) {
 		t.Errorf("expected %#v got %#v", expectedTokenStream[0].KeyWord, ouputTokenStream[0].KeyWord)
 	}
 }
+
+func TestKeyWordMarkerFilterConstructor(t *testing.T) {
+	// write a keywords file for the file based config
+	keywordsFile, err := ioutil.TempFile("", "keywords")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.Remove(keywordsFile.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	_, err = keywordsFile.WriteString("# keywords\nwalk\npark\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = keywordsFile.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := registry.NewCache()
+	_, err = cache.DefineTokenMap("my_keywords", map[string]interface{}{
+		"type":   token_map.Name,
+		"tokens": []interface{}{"walk", "park"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configs := []map[string]interface{}{
+		{
+			"keywords_token_map": "my_keywords",
+		},
+		{
+			"keywords": []interface{}{"walk", "park"},
+		},
+		{
+			"keywords_file": keywordsFile.Name(),
+		},
+	}
+
+	for _, config := range configs {
+		filter, err := KeyWordMarkerFilterConstructor(config, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := analysis.TokenStream{
+			&analysis.Token{
+				Term: []byte("walk"),
+			},
+			&analysis.Token{
+				Term: []byte("the"),
+			},
+		}
+		output := filter.Filter(input)
+		if !output[0].KeyWord || output[1].KeyWord {
+			t.Errorf("expected only 'walk' to be marked as keyword for config %v", config)
+		}
+	}
+
+	_, err = KeyWordMarkerFilterConstructor(map[string]interface{}{}, cache)
+	if err == nil {
+		t.Errorf("expected error when no keywords are specified")
+	}
+}
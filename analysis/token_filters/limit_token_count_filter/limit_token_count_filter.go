@@ -0,0 +1,55 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package limit_token_count_filter
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "limit_token_count"
+
+// LimitTokenCountFilter keeps only the first maxTokenCount
+// tokens of a stream, protecting the index from very
+// large field values
+type LimitTokenCountFilter struct {
+	maxTokenCount int
+}
+
+func NewLimitTokenCountFilter(maxTokenCount int) *LimitTokenCountFilter {
+	return &LimitTokenCountFilter{
+		maxTokenCount: maxTokenCount,
+	}
+}
+
+func (f *LimitTokenCountFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	if len(input) > f.maxTokenCount {
+		return input[:f.maxTokenCount]
+	}
+	return input
+}
+
+func LimitTokenCountFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	maxTokenCountVal, ok := config["max_token_count"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("must specify max_token_count")
+	}
+	maxTokenCount := int(maxTokenCountVal)
+	if maxTokenCount < 1 {
+		return nil, fmt.Errorf("max_token_count must be positive")
+	}
+	return NewLimitTokenCountFilter(maxTokenCount), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, LimitTokenCountFilterConstructor)
+}
@@ -0,0 +1,76 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package limit_token_count_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestLimitTokenCountFilter(t *testing.T) {
+
+	input := analysis.TokenStream{
+		&analysis.Token{Term: []byte("one"), Position: 1},
+		&analysis.Token{Term: []byte("two"), Position: 2},
+		&analysis.Token{Term: []byte("three"), Position: 3},
+	}
+
+	tests := []struct {
+		maxTokenCount int
+		output        analysis.TokenStream
+	}{
+		{
+			maxTokenCount: 2,
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("one"), Position: 1},
+				&analysis.Token{Term: []byte("two"), Position: 2},
+			},
+		},
+		{
+			maxTokenCount: 3,
+			output:        input,
+		},
+		{
+			maxTokenCount: 10,
+			output:        input,
+		},
+	}
+
+	for _, test := range tests {
+		filter := NewLimitTokenCountFilter(test.maxTokenCount)
+		actual := filter.Filter(input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %s, got %s", test.output, actual)
+		}
+	}
+}
+
+func TestLimitTokenCountFilterConstructor(t *testing.T) {
+	cache := registry.NewCache()
+	_, err := LimitTokenCountFilterConstructor(map[string]interface{}{
+		"max_token_count": 5.0,
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LimitTokenCountFilterConstructor(map[string]interface{}{}, cache)
+	if err == nil {
+		t.Errorf("expected error without max_token_count")
+	}
+	_, err = LimitTokenCountFilterConstructor(map[string]interface{}{
+		"max_token_count": 0.0,
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for zero max_token_count")
+	}
+}
@@ -0,0 +1,177 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package minhash_filter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "min_hash"
+
+const DefaultHashCount = 1
+const DefaultBucketCount = 512
+const DefaultHashSetSize = 1
+
+// MinHashFilter replaces the tokens of a field with a
+// fixed number of MinHash signature tokens, fields with
+// similar sets of tokens share many signature tokens, so
+// near duplicates can be found with a simple query.
+//
+// Each of the hashCount hash functions splits the hash
+// space into bucketCount buckets and keeps the
+// hashSetSize smallest hashes seen in every bucket.  With
+// rotation empty buckets take the hashes of the next
+// non-empty bucket, so every field produces the same
+// number of tokens.  Usually the input should be
+// shingled first.
+type MinHashFilter struct {
+	hashCount    int
+	bucketCount  int
+	hashSetSize  int
+	withRotation bool
+}
+
+func NewMinHashFilter(hashCount, bucketCount, hashSetSize int, withRotation bool) *MinHashFilter {
+	return &MinHashFilter{
+		hashCount:    hashCount,
+		bucketCount:  bucketCount,
+		hashSetSize:  hashSetSize,
+		withRotation: withRotation,
+	}
+}
+
+// mix64 is the finalizer of splitmix64, it spreads the
+// bits of the hash for each hash function seed
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+type uint64s []uint64
+
+func (u uint64s) Len() int           { return len(u) }
+func (u uint64s) Less(i, j int) bool { return u[i] < u[j] }
+func (u uint64s) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
+
+// insert adds h to the sorted set of at most size hashes
+func insert(set uint64s, h uint64, size int) uint64s {
+	i := sort.Search(len(set), func(i int) bool { return set[i] >= h })
+	if i < len(set) && set[i] == h {
+		return set
+	}
+	if len(set) >= size && i >= len(set) {
+		return set
+	}
+	set = append(set, 0)
+	copy(set[i+1:], set[i:])
+	set[i] = h
+	if len(set) > size {
+		set = set[:size]
+	}
+	return set
+}
+
+func (f *MinHashFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	if len(input) == 0 {
+		return input
+	}
+
+	// buckets[hash][bucket] is the sorted set of min hashes
+	buckets := make([][]uint64s, f.hashCount)
+	for i := range buckets {
+		buckets[i] = make([]uint64s, f.bucketCount)
+	}
+
+	start := input[0].Start
+	end := input[0].End
+	for _, token := range input {
+		if token.Start < start {
+			start = token.Start
+		}
+		if token.End > end {
+			end = token.End
+		}
+		hasher := fnv.New64a()
+		hasher.Write(token.Term)
+		base := hasher.Sum64()
+		for i := 0; i < f.hashCount; i++ {
+			h := mix64(base + uint64(i)*0x9e3779b97f4a7c15)
+			bucket := int(h % uint64(f.bucketCount))
+			buckets[i][bucket] = insert(buckets[i][bucket], h, f.hashSetSize)
+		}
+	}
+
+	rv := make(analysis.TokenStream, 0, f.hashCount*f.bucketCount*f.hashSetSize)
+	for i := 0; i < f.hashCount; i++ {
+		for bucket := 0; bucket < f.bucketCount; bucket++ {
+			hashes := buckets[i][bucket]
+			if len(hashes) == 0 && f.withRotation {
+				for next := 1; next < f.bucketCount; next++ {
+					hashes = buckets[i][(bucket+next)%f.bucketCount]
+					if len(hashes) > 0 {
+						break
+					}
+				}
+			}
+			for _, h := range hashes {
+				token := analysis.Token{
+					Term:     []byte(fmt.Sprintf("%d_%d_%016x", i, bucket, h)),
+					Position: 1,
+					Start:    start,
+					End:      end,
+					Type:     analysis.AlphaNumeric,
+				}
+				rv = append(rv, &token)
+			}
+		}
+	}
+
+	return rv
+}
+
+func MinHashFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	hashCount := DefaultHashCount
+	hashCountVal, ok := config["hash_count"].(float64)
+	if ok {
+		hashCount = int(hashCountVal)
+	}
+	bucketCount := DefaultBucketCount
+	bucketCountVal, ok := config["bucket_count"].(float64)
+	if ok {
+		bucketCount = int(bucketCountVal)
+	}
+	hashSetSize := DefaultHashSetSize
+	hashSetSizeVal, ok := config["hash_set_size"].(float64)
+	if ok {
+		hashSetSize = int(hashSetSizeVal)
+	}
+	if hashCount < 1 || bucketCount < 1 || hashSetSize < 1 {
+		return nil, fmt.Errorf("hash_count, bucket_count and hash_set_size must be positive")
+	}
+	withRotation := true
+	withRotationVal, ok := config["with_rotation"].(bool)
+	if ok {
+		withRotation = withRotationVal
+	}
+	return NewMinHashFilter(hashCount, bucketCount, hashSetSize, withRotation), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, MinHashFilterConstructor)
+}
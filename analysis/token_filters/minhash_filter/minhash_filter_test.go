@@ -0,0 +1,108 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package minhash_filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func tokensFromWords(text string) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0)
+	for i, word := range strings.Fields(text) {
+		rv = append(rv, &analysis.Token{
+			Term:     []byte(word),
+			Position: i + 1,
+		})
+	}
+	return rv
+}
+
+func signature(ts analysis.TokenStream) map[string]bool {
+	rv := make(map[string]bool, len(ts))
+	for _, token := range ts {
+		rv[string(token.Term)] = true
+	}
+	return rv
+}
+
+func overlap(a, b map[string]bool) int {
+	rv := 0
+	for term := range a {
+		if b[term] {
+			rv++
+		}
+	}
+	return rv
+}
+
+func TestMinHashFilter(t *testing.T) {
+	filter := NewMinHashFilter(2, 16, 1, true)
+
+	original := filter.Filter(tokensFromWords("the quick brown fox jumps over the lazy dog near the river bank"))
+	// every bucket of every hash is filled thanks to rotation
+	if len(original) != 2*16 {
+		t.Fatalf("expected %d tokens, got %d", 2*16, len(original))
+	}
+	for _, token := range original {
+		if token.Position != 1 {
+			t.Errorf("expected all tokens at position 1, got %d", token.Position)
+		}
+	}
+
+	// the signature does not depend on token order or repetition
+	reordered := filter.Filter(tokensFromWords("river bank near the lazy dog the quick brown fox jumps over the fox"))
+	if !reflect.DeepEqual(signature(original), signature(reordered)) {
+		t.Errorf("expected identical signatures for the same set of words")
+	}
+
+	similar := filter.Filter(tokensFromWords("the quick brown fox jumps over the lazy cat near the river bank"))
+	different := filter.Filter(tokensFromWords("lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor"))
+	originalSig := signature(original)
+	if overlap(originalSig, signature(similar)) <= overlap(originalSig, signature(different)) {
+		t.Errorf("expected similar text to share more signature tokens than different text")
+	}
+
+	empty := filter.Filter(analysis.TokenStream{})
+	if len(empty) != 0 {
+		t.Errorf("expected no tokens for empty input, got %d", len(empty))
+	}
+}
+
+func TestMinHashFilterWithoutRotation(t *testing.T) {
+	filter := NewMinHashFilter(1, 64, 2, false)
+	output := filter.Filter(tokensFromWords("one two three"))
+	if len(output) != 3 {
+		t.Errorf("expected one token per distinct word, got %d", len(output))
+	}
+}
+
+func TestMinHashFilterConstructor(t *testing.T) {
+	cache := registry.NewCache()
+	_, err := MinHashFilterConstructor(map[string]interface{}{
+		"hash_count":    3.0,
+		"bucket_count":  8.0,
+		"hash_set_size": 2.0,
+		"with_rotation": false,
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = MinHashFilterConstructor(map[string]interface{}{
+		"bucket_count": 0.0,
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for zero bucket_count")
+	}
+}
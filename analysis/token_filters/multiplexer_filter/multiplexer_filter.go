@@ -0,0 +1,130 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package multiplexer_filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "multiplexer"
+
+// MultiplexerFilter passes each token through several
+// alternate chains of token filters and emits everything
+// they produce at the position of the original token,
+// for example the original, stemmed and phonetic forms.
+// Outputs with the same term are only emitted once.
+type MultiplexerFilter struct {
+	chains           [][]analysis.TokenFilter
+	preserveOriginal bool
+}
+
+func NewMultiplexerFilter(chains [][]analysis.TokenFilter, preserveOriginal bool) *MultiplexerFilter {
+	return &MultiplexerFilter{
+		chains:           chains,
+		preserveOriginal: preserveOriginal,
+	}
+}
+
+func (f *MultiplexerFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input)*(len(f.chains)+1))
+
+	for _, token := range input {
+		seen := make(map[string]bool, len(f.chains)+1)
+		if f.preserveOriginal {
+			seen[string(token.Term)] = true
+			rv = append(rv, token)
+		}
+		for _, chain := range f.chains {
+			// filters may modify the token, so each chain
+			// works on its own copy
+			tokenCopy := *token
+			tokenCopy.Term = make([]byte, len(token.Term))
+			copy(tokenCopy.Term, token.Term)
+			output := analysis.TokenStream{&tokenCopy}
+			for _, filter := range chain {
+				output = filter.Filter(output)
+			}
+			for _, outputToken := range output {
+				if seen[string(outputToken.Term)] {
+					continue
+				}
+				seen[string(outputToken.Term)] = true
+				outputToken.Position = token.Position
+				rv = append(rv, outputToken)
+			}
+		}
+	}
+
+	return rv
+}
+
+// chainFromConfig accepts either a list of filter names or
+// a string with comma separated filter names
+func chainFromConfig(chainVal interface{}, cache *registry.Cache) ([]analysis.TokenFilter, error) {
+	var names []string
+	switch chainVal := chainVal.(type) {
+	case string:
+		for _, name := range strings.Split(chainVal, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+	case []interface{}:
+		for _, nameVal := range chainVal {
+			name, ok := nameVal.(string)
+			if !ok {
+				return nil, fmt.Errorf("token filter name must be a string")
+			}
+			names = append(names, name)
+		}
+	default:
+		return nil, fmt.Errorf("filter chain must be a string or a list of token filter names")
+	}
+
+	rv := make([]analysis.TokenFilter, 0, len(names))
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		filter, err := cache.TokenFilterNamed(name)
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, filter)
+	}
+	return rv, nil
+}
+
+func MultiplexerFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	chainVals, ok := config["filters"].([]interface{})
+	if !ok || len(chainVals) == 0 {
+		return nil, fmt.Errorf("must specify filters")
+	}
+	chains := make([][]analysis.TokenFilter, len(chainVals))
+	for i, chainVal := range chainVals {
+		chain, err := chainFromConfig(chainVal, cache)
+		if err != nil {
+			return nil, fmt.Errorf("error building multiplexer filter: %v", err)
+		}
+		chains[i] = chain
+	}
+	preserveOriginal := true
+	preserveVal, ok := config["preserve_original"].(bool)
+	if ok {
+		preserveOriginal = preserveVal
+	}
+	return NewMultiplexerFilter(chains, preserveOriginal), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, MultiplexerFilterConstructor)
+}
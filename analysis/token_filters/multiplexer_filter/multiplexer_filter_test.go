@@ -0,0 +1,112 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package multiplexer_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/token_filters/lower_case_filter"
+	"github.com/blevesearch/bleve/analysis/token_filters/porter"
+	"github.com/blevesearch/bleve/analysis/token_filters/stop_tokens_filter"
+	"github.com/blevesearch/bleve/analysis/token_filters/truncate_token_filter"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestMultiplexerFilter(t *testing.T) {
+	stopWords := analysis.NewTokenMap()
+	stopWords.AddToken("the")
+
+	tests := []struct {
+		chains           [][]analysis.TokenFilter
+		preserveOriginal bool
+		input            analysis.TokenStream
+		output           analysis.TokenStream
+	}{
+		{
+			chains: [][]analysis.TokenFilter{
+				{porter.NewPorterStemmer()},
+				{truncate_token_filter.NewTruncateTokenFilter(3)},
+			},
+			preserveOriginal: true,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("running"), Position: 1, Start: 0, End: 7},
+				&analysis.Token{Term: []byte("cat"), Position: 2, Start: 8, End: 11},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("running"), Position: 1, Start: 0, End: 7},
+				&analysis.Token{Term: []byte("run"), Position: 1, Start: 0, End: 7},
+				&analysis.Token{Term: []byte("cat"), Position: 2, Start: 8, End: 11},
+			},
+		},
+		// chains may remove tokens, the original is optional
+		{
+			chains: [][]analysis.TokenFilter{
+				{stop_tokens_filter.NewStopTokensFilter(stopWords)},
+				{lower_case_filter.NewLowerCaseFilter(), porter.NewPorterStemmer()},
+			},
+			preserveOriginal: false,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("the"), Position: 1},
+				&analysis.Token{Term: []byte("Cats"), Position: 2},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("the"), Position: 1},
+				&analysis.Token{Term: []byte("Cats"), Position: 2},
+				&analysis.Token{Term: []byte("cat"), Position: 2},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		filter := NewMultiplexerFilter(test.chains, test.preserveOriginal)
+		actual := filter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %s, got %s", test.output, actual)
+		}
+	}
+}
+
+func TestMultiplexerFilterConstructor(t *testing.T) {
+	cache := registry.NewCache()
+	filter, err := MultiplexerFilterConstructor(map[string]interface{}{
+		"filters": []interface{}{
+			porter.Name,
+			"to_lower, stemmer_porter",
+			[]interface{}{lower_case_filter.Name},
+		},
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := filter.Filter(analysis.TokenStream{
+		&analysis.Token{Term: []byte("Cats"), Position: 1},
+	})
+	expected := analysis.TokenStream{
+		&analysis.Token{Term: []byte("Cats"), Position: 1},
+		&analysis.Token{Term: []byte("cat"), Position: 1},
+		&analysis.Token{Term: []byte("cats"), Position: 1},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+
+	_, err = MultiplexerFilterConstructor(map[string]interface{}{}, cache)
+	if err == nil {
+		t.Errorf("expected error without filters")
+	}
+	_, err = MultiplexerFilterConstructor(map[string]interface{}{
+		"filters": []interface{}{"no_such_filter"},
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for unknown filter")
+	}
+}
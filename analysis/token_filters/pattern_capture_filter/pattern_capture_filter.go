@@ -0,0 +1,123 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pattern_capture_filter
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "pattern_capture"
+
+// PatternCaptureFilter emits an additional token for
+// every non-empty capture group matched by any of the
+// patterns, for example the pattern "@(.+)" would add
+// the domain of an email address.  Captured tokens share
+// the position and offsets of the token they came from.
+// Tokens not matched by any pattern are always kept,
+// matched tokens are only kept when preserveOriginal
+// is set.
+type PatternCaptureFilter struct {
+	patterns         []*regexp.Regexp
+	preserveOriginal bool
+}
+
+func NewPatternCaptureFilter(patterns []*regexp.Regexp, preserveOriginal bool) *PatternCaptureFilter {
+	return &PatternCaptureFilter{
+		patterns:         patterns,
+		preserveOriginal: preserveOriginal,
+	}
+}
+
+func (f *PatternCaptureFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input))
+
+	for _, token := range input {
+		captures := f.captures(token.Term)
+		if len(captures) == 0 {
+			rv = append(rv, token)
+			continue
+		}
+		if f.preserveOriginal {
+			rv = append(rv, token)
+		}
+		for _, capture := range captures {
+			if f.preserveOriginal && capture == string(token.Term) {
+				continue
+			}
+			captureToken := analysis.Token{
+				Term:     []byte(capture),
+				Position: token.Position,
+				Start:    token.Start,
+				End:      token.End,
+				Type:     token.Type,
+				KeyWord:  token.KeyWord,
+			}
+			rv = append(rv, &captureToken)
+		}
+	}
+
+	return rv
+}
+
+// captures returns the distinct non-empty capture groups
+// of all matches, in the order they were found
+func (f *PatternCaptureFilter) captures(term []byte) []string {
+	var rv []string
+	seen := make(map[string]bool)
+	for _, pattern := range f.patterns {
+		for _, match := range pattern.FindAllSubmatchIndex(term, -1) {
+			// skip the first pair, it is the whole match
+			for i := 2; i+1 < len(match); i += 2 {
+				if match[i] < 0 || match[i] == match[i+1] {
+					continue
+				}
+				capture := string(term[match[i]:match[i+1]])
+				if !seen[capture] {
+					seen[capture] = true
+					rv = append(rv, capture)
+				}
+			}
+		}
+	}
+	return rv
+}
+
+func PatternCaptureFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	patternVals, ok := config["patterns"].([]interface{})
+	if !ok || len(patternVals) == 0 {
+		return nil, fmt.Errorf("must specify patterns")
+	}
+	patterns := make([]*regexp.Regexp, len(patternVals))
+	for i, patternVal := range patternVals {
+		patternStr, ok := patternVal.(string)
+		if !ok {
+			return nil, fmt.Errorf("pattern must be a string")
+		}
+		pattern, err := regexp.Compile(patternStr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to build pattern capture filter: %v", err)
+		}
+		patterns[i] = pattern
+	}
+	preserveOriginal := true
+	preserveVal, ok := config["preserve_original"].(bool)
+	if ok {
+		preserveOriginal = preserveVal
+	}
+	return NewPatternCaptureFilter(patterns, preserveOriginal), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, PatternCaptureFilterConstructor)
+}
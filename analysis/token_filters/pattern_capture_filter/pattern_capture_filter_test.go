@@ -0,0 +1,116 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package pattern_capture_filter
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestPatternCaptureFilter(t *testing.T) {
+	emailPatterns := []*regexp.Regexp{
+		regexp.MustCompile(`^([^@]+)@(.+)$`),
+		regexp.MustCompile(`([^@.]+)\.`),
+	}
+
+	tests := []struct {
+		patterns         []*regexp.Regexp
+		preserveOriginal bool
+		input            analysis.TokenStream
+		output           analysis.TokenStream
+	}{
+		{
+			patterns:         emailPatterns,
+			preserveOriginal: true,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("mail"), Position: 1, Start: 0, End: 4},
+				&analysis.Token{Term: []byte("marty@blevesearch.com"), Position: 2, Start: 5, End: 26},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("mail"), Position: 1, Start: 0, End: 4},
+				&analysis.Token{Term: []byte("marty@blevesearch.com"), Position: 2, Start: 5, End: 26},
+				&analysis.Token{Term: []byte("marty"), Position: 2, Start: 5, End: 26},
+				&analysis.Token{Term: []byte("blevesearch.com"), Position: 2, Start: 5, End: 26},
+				&analysis.Token{Term: []byte("blevesearch"), Position: 2, Start: 5, End: 26},
+			},
+		},
+		{
+			patterns:         emailPatterns,
+			preserveOriginal: false,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("mail"), Position: 1, Start: 0, End: 4},
+				&analysis.Token{Term: []byte("marty@blevesearch.com"), Position: 2, Start: 5, End: 26},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("mail"), Position: 1, Start: 0, End: 4},
+				&analysis.Token{Term: []byte("marty"), Position: 2, Start: 5, End: 26},
+				&analysis.Token{Term: []byte("blevesearch.com"), Position: 2, Start: 5, End: 26},
+				&analysis.Token{Term: []byte("blevesearch"), Position: 2, Start: 5, End: 26},
+			},
+		},
+		// repeated matches and optional groups
+		{
+			patterns: []*regexp.Regexp{
+				regexp.MustCompile(`(\d+)(x)?`),
+			},
+			preserveOriginal: false,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("a1b22c1"), Position: 1, Start: 0, End: 7},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("1"), Position: 1, Start: 0, End: 7},
+				&analysis.Token{Term: []byte("22"), Position: 1, Start: 0, End: 7},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		filter := NewPatternCaptureFilter(test.patterns, test.preserveOriginal)
+		actual := filter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %s, got %s", test.output, actual)
+		}
+	}
+}
+
+func TestPatternCaptureFilterConstructor(t *testing.T) {
+	cache := registry.NewCache()
+	filter, err := PatternCaptureFilterConstructor(map[string]interface{}{
+		"patterns": []interface{}{`@(.+)`},
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := filter.Filter(analysis.TokenStream{
+		&analysis.Token{Term: []byte("a@b.c")},
+	})
+	expected := analysis.TokenStream{
+		&analysis.Token{Term: []byte("a@b.c")},
+		&analysis.Token{Term: []byte("b.c")},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+
+	_, err = PatternCaptureFilterConstructor(map[string]interface{}{}, cache)
+	if err == nil {
+		t.Errorf("expected error without patterns")
+	}
+	_, err = PatternCaptureFilterConstructor(map[string]interface{}{
+		"patterns": []interface{}{`(`},
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+}
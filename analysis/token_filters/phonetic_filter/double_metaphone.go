@@ -0,0 +1,627 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package phonetic_filter
+
+import (
+	"strings"
+)
+
+// the rules here follow the double metaphone algorithm
+// by Lawrence Philips, as implemented in apache
+// commons-codec
+
+type doubleMetaphoneResult struct {
+	primary   []rune
+	alternate []rune
+	maxLength int
+}
+
+func (r *doubleMetaphoneResult) appendPrimary(s string) {
+	for _, c := range s {
+		if len(r.primary) < r.maxLength {
+			r.primary = append(r.primary, c)
+		}
+	}
+}
+
+func (r *doubleMetaphoneResult) appendAlternate(s string) {
+	for _, c := range s {
+		if len(r.alternate) < r.maxLength {
+			r.alternate = append(r.alternate, c)
+		}
+	}
+}
+
+func (r *doubleMetaphoneResult) append(s string) {
+	r.appendPrimary(s)
+	r.appendAlternate(s)
+}
+
+func (r *doubleMetaphoneResult) append2(primary, alternate string) {
+	r.appendPrimary(primary)
+	r.appendAlternate(alternate)
+}
+
+func (r *doubleMetaphoneResult) complete() bool {
+	return len(r.primary) >= r.maxLength && len(r.alternate) >= r.maxLength
+}
+
+type doubleMetaphone struct {
+	value         []rune
+	slavoGermanic bool
+	result        *doubleMetaphoneResult
+}
+
+func (d *doubleMetaphone) at(i int) rune {
+	if i < 0 || i >= len(d.value) {
+		return 0
+	}
+	return d.value[i]
+}
+
+// contains reports whether the length runes starting
+// at start match any of the criteria
+func (d *doubleMetaphone) contains(start, length int, criteria ...string) bool {
+	if start < 0 || start+length > len(d.value) {
+		return false
+	}
+	target := string(d.value[start : start+length])
+	for _, c := range criteria {
+		if target == c {
+			return true
+		}
+	}
+	return false
+}
+
+func isDoubleMetaphoneVowel(r rune) bool {
+	return r == 'A' || r == 'E' || r == 'I' || r == 'O' || r == 'U' || r == 'Y'
+}
+
+func (d *doubleMetaphone) isVowel(i int) bool {
+	return isDoubleMetaphoneVowel(d.at(i))
+}
+
+// DoubleMetaphone computes the primary and alternate
+// double metaphone keys of the input, each with at
+// most maxLength characters
+func DoubleMetaphone(input string, maxLength int) (string, string) {
+	input = strings.ToUpper(strings.TrimSpace(input))
+	if input == "" {
+		return "", ""
+	}
+	d := &doubleMetaphone{
+		value: []rune(input),
+		result: &doubleMetaphoneResult{
+			maxLength: maxLength,
+		},
+	}
+	d.slavoGermanic = strings.Contains(input, "W") || strings.Contains(input, "K") ||
+		strings.Contains(input, "CZ") || strings.Contains(input, "WITZ")
+
+	index := 0
+	if d.contains(0, 2, "GN", "KN", "PN", "WR", "PS") {
+		index = 1
+	}
+
+	for !d.result.complete() && index < len(d.value) {
+		switch d.value[index] {
+		case 'A', 'E', 'I', 'O', 'U', 'Y':
+			if index == 0 {
+				d.result.append("A")
+			}
+			index++
+		case 'B':
+			d.result.append("P")
+			index = d.skipDouble(index, 'B')
+		case 'Ç':
+			d.result.append("S")
+			index++
+		case 'C':
+			index = d.handleC(index)
+		case 'D':
+			index = d.handleD(index)
+		case 'F':
+			d.result.append("F")
+			index = d.skipDouble(index, 'F')
+		case 'G':
+			index = d.handleG(index)
+		case 'H':
+			index = d.handleH(index)
+		case 'J':
+			index = d.handleJ(index)
+		case 'K':
+			d.result.append("K")
+			index = d.skipDouble(index, 'K')
+		case 'L':
+			index = d.handleL(index)
+		case 'M':
+			d.result.append("M")
+			if d.conditionM0(index) {
+				index += 2
+			} else {
+				index++
+			}
+		case 'N':
+			d.result.append("N")
+			index = d.skipDouble(index, 'N')
+		case 'Ñ':
+			d.result.append("N")
+			index++
+		case 'P':
+			index = d.handleP(index)
+		case 'Q':
+			d.result.append("K")
+			index = d.skipDouble(index, 'Q')
+		case 'R':
+			index = d.handleR(index)
+		case 'S':
+			index = d.handleS(index)
+		case 'T':
+			index = d.handleT(index)
+		case 'V':
+			d.result.append("F")
+			index = d.skipDouble(index, 'V')
+		case 'W':
+			index = d.handleW(index)
+		case 'X':
+			index = d.handleX(index)
+		case 'Z':
+			index = d.handleZ(index)
+		default:
+			index++
+		}
+	}
+
+	return string(d.result.primary), string(d.result.alternate)
+}
+
+func (d *doubleMetaphone) skipDouble(index int, r rune) int {
+	if d.at(index+1) == r {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (d *doubleMetaphone) handleC(index int) int {
+	switch {
+	case d.conditionC0(index):
+		d.result.append("K")
+		index += 2
+	case index == 0 && d.contains(index, 6, "CAESAR"):
+		d.result.append("S")
+		index += 2
+	case d.contains(index, 2, "CH"):
+		index = d.handleCH(index)
+	case d.contains(index, 2, "CZ") && !d.contains(index-2, 4, "WICZ"):
+		// "czerny"
+		d.result.append2("S", "X")
+		index += 2
+	case d.contains(index+1, 3, "CIA"):
+		// "focaccia"
+		d.result.append("X")
+		index += 3
+	case d.contains(index, 2, "CC") && !(index == 1 && d.at(0) == 'M'):
+		// double "cc" but not "mcclelland"
+		return d.handleCC(index)
+	case d.contains(index, 2, "CK", "CG", "CQ"):
+		d.result.append("K")
+		index += 2
+	case d.contains(index, 2, "CI", "CE", "CY"):
+		// italian vs. english
+		if d.contains(index, 3, "CIO", "CIE", "CIA") {
+			d.result.append2("S", "X")
+		} else {
+			d.result.append("S")
+		}
+		index += 2
+	default:
+		d.result.append("K")
+		if d.contains(index+1, 2, " C", " Q", " G") {
+			// "mac caffrey", "mac gregor"
+			index += 3
+		} else if d.contains(index+1, 1, "C", "K", "Q") && !d.contains(index+1, 2, "CE", "CI") {
+			index += 2
+		} else {
+			index++
+		}
+	}
+	return index
+}
+
+func (d *doubleMetaphone) conditionC0(index int) bool {
+	if d.contains(index, 4, "CHIA") {
+		return true
+	} else if index <= 1 {
+		return false
+	} else if d.isVowel(index - 2) {
+		return false
+	} else if !d.contains(index-1, 3, "ACH") {
+		return false
+	}
+	c := d.at(index + 2)
+	return (c != 'I' && c != 'E') || d.contains(index-2, 6, "BACHER", "MACHER")
+}
+
+func (d *doubleMetaphone) handleCC(index int) int {
+	if d.contains(index+2, 1, "I", "E", "H") && !d.contains(index+2, 2, "HU") {
+		// "bellocchio" but not "bacchus"
+		if (index == 1 && d.at(index-1) == 'A') || d.contains(index-1, 5, "UCCEE", "UCCES") {
+			// "accident", "accede", "succeed"
+			d.result.append("KS")
+		} else {
+			// "bacci", "bertucci", other italian
+			d.result.append("X")
+		}
+		return index + 3
+	}
+	// pierce's rule
+	d.result.append("K")
+	return index + 2
+}
+
+func (d *doubleMetaphone) handleCH(index int) int {
+	if index > 0 && d.contains(index, 4, "CHAE") {
+		// "michael"
+		d.result.append2("K", "X")
+	} else if d.conditionCH0(index) || d.conditionCH1(index) {
+		// greek roots or germanic 'ch' for 'kh' sound
+		d.result.append("K")
+	} else if index > 0 {
+		if d.contains(0, 2, "MC") {
+			d.result.append("K")
+		} else {
+			d.result.append2("X", "K")
+		}
+	} else {
+		d.result.append("X")
+	}
+	return index + 2
+}
+
+func (d *doubleMetaphone) conditionCH0(index int) bool {
+	if index != 0 {
+		return false
+	} else if !d.contains(index+1, 5, "HARAC", "HARIS") &&
+		!d.contains(index+1, 3, "HOR", "HYM", "HIA", "HEM") {
+		return false
+	} else if d.contains(0, 5, "CHORE") {
+		return false
+	}
+	return true
+}
+
+func (d *doubleMetaphone) conditionCH1(index int) bool {
+	return d.contains(0, 4, "VAN ", "VON ") || d.contains(0, 3, "SCH") ||
+		d.contains(index-2, 6, "ORCHES", "ARCHIT", "ORCHID") ||
+		d.contains(index+2, 1, "T", "S") ||
+		((d.contains(index-1, 1, "A", "O", "U", "E") || index == 0) &&
+			(d.contains(index+2, 1, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ") || index+1 == len(d.value)-1))
+}
+
+func (d *doubleMetaphone) handleD(index int) int {
+	if d.contains(index, 2, "DG") {
+		if d.contains(index+2, 1, "I", "E", "Y") {
+			// "edge"
+			d.result.append("J")
+			return index + 3
+		}
+		// "edgar"
+		d.result.append("TK")
+		return index + 2
+	} else if d.contains(index, 2, "DT", "DD") {
+		d.result.append("T")
+		return index + 2
+	}
+	d.result.append("T")
+	return index + 1
+}
+
+func (d *doubleMetaphone) handleG(index int) int {
+	switch {
+	case d.at(index+1) == 'H':
+		return d.handleGH(index)
+	case d.at(index+1) == 'N':
+		if index == 1 && d.isVowel(0) && !d.slavoGermanic {
+			d.result.append2("KN", "N")
+		} else if !d.contains(index+2, 2, "EY") && d.at(index+1) != 'Y' && !d.slavoGermanic {
+			d.result.append2("N", "KN")
+		} else {
+			d.result.append("KN")
+		}
+		return index + 2
+	case d.contains(index+1, 2, "LI") && !d.slavoGermanic:
+		d.result.append2("KL", "L")
+		return index + 2
+	case index == 0 && (d.at(index+1) == 'Y' ||
+		d.contains(index+1, 2, "ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")):
+		// -ges-, -gep-, -gel-, -gie- at beginning
+		d.result.append2("K", "J")
+		return index + 2
+	case (d.contains(index+1, 2, "ER") || d.at(index+1) == 'Y') &&
+		!d.contains(0, 6, "DANGER", "RANGER", "MANGER") &&
+		!d.contains(index-1, 1, "E", "I") &&
+		!d.contains(index-1, 3, "RGY", "OGY"):
+		// -ger-, -gy-
+		d.result.append2("K", "J")
+		return index + 2
+	case d.contains(index+1, 1, "E", "I", "Y") || d.contains(index-1, 4, "AGGI", "OGGI"):
+		// italian "biaggi"
+		if d.contains(0, 4, "VAN ", "VON ") || d.contains(0, 3, "SCH") || d.contains(index+1, 2, "ET") {
+			// obvious germanic
+			d.result.append("K")
+		} else if d.contains(index+1, 3, "IER") {
+			d.result.append("J")
+		} else {
+			d.result.append2("J", "K")
+		}
+		return index + 2
+	case d.at(index+1) == 'G':
+		d.result.append("K")
+		return index + 2
+	}
+	d.result.append("K")
+	return index + 1
+}
+
+func (d *doubleMetaphone) handleGH(index int) int {
+	if index > 0 && !d.isVowel(index-1) {
+		d.result.append("K")
+	} else if index == 0 {
+		if d.at(index+2) == 'I' {
+			d.result.append("J")
+		} else {
+			d.result.append("K")
+		}
+	} else if (index > 1 && d.contains(index-2, 1, "B", "H", "D")) ||
+		(index > 2 && d.contains(index-3, 1, "B", "H", "D")) ||
+		(index > 3 && d.contains(index-4, 1, "B", "H")) {
+		// parker's rule, "hugh"
+	} else if index > 2 && d.at(index-1) == 'U' && d.contains(index-3, 1, "C", "G", "L", "R", "T") {
+		// "laugh", "mclaughlin", "cough", "gough", "rough", "tough"
+		d.result.append("F")
+	} else if index > 0 && d.at(index-1) != 'I' {
+		d.result.append("K")
+	}
+	return index + 2
+}
+
+func (d *doubleMetaphone) handleH(index int) int {
+	// only keep if first & before vowel or between 2 vowels
+	if (index == 0 || d.isVowel(index-1)) && d.isVowel(index+1) {
+		d.result.append("H")
+		return index + 2
+	}
+	return index + 1
+}
+
+func (d *doubleMetaphone) handleJ(index int) int {
+	if d.contains(index, 4, "JOSE") || d.contains(0, 4, "SAN ") {
+		// obvious spanish, "jose", "san jacinto"
+		if (index == 0 && (d.at(index+4) == ' ' || len(d.value) == 4)) || d.contains(0, 4, "SAN ") {
+			d.result.append("H")
+		} else {
+			d.result.append2("J", "H")
+		}
+		return index + 1
+	}
+
+	if index == 0 && !d.contains(index, 4, "JOSE") {
+		d.result.append2("J", "A")
+	} else if d.isVowel(index-1) && !d.slavoGermanic && (d.at(index+1) == 'A' || d.at(index+1) == 'O') {
+		d.result.append2("J", "H")
+	} else if index == len(d.value)-1 {
+		d.result.appendPrimary("J")
+	} else if !d.contains(index+1, 1, "L", "T", "K", "S", "N", "M", "B", "Z") &&
+		!d.contains(index-1, 1, "S", "K", "L") {
+		d.result.append("J")
+	}
+	return d.skipDouble(index, 'J')
+}
+
+func (d *doubleMetaphone) handleL(index int) int {
+	if d.at(index+1) == 'L' {
+		if d.conditionL0(index) {
+			d.result.appendPrimary("L")
+		} else {
+			d.result.append("L")
+		}
+		return index + 2
+	}
+	d.result.append("L")
+	return index + 1
+}
+
+func (d *doubleMetaphone) conditionL0(index int) bool {
+	if index == len(d.value)-3 && d.contains(index-1, 4, "ILLO", "ILLA", "ALLE") {
+		return true
+	}
+	return (d.contains(len(d.value)-2, 2, "AS", "OS") || d.contains(len(d.value)-1, 1, "A", "O")) &&
+		d.contains(index-1, 4, "ALLE")
+}
+
+func (d *doubleMetaphone) conditionM0(index int) bool {
+	if d.at(index+1) == 'M' {
+		return true
+	}
+	return d.contains(index-1, 3, "UMB") && (index+1 == len(d.value)-1 || d.contains(index+2, 2, "ER"))
+}
+
+func (d *doubleMetaphone) handleP(index int) int {
+	if d.at(index+1) == 'H' {
+		d.result.append("F")
+		return index + 2
+	}
+	d.result.append("P")
+	if d.contains(index+1, 1, "P", "B") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (d *doubleMetaphone) handleR(index int) int {
+	if index == len(d.value)-1 && !d.slavoGermanic && d.contains(index-2, 2, "IE") &&
+		!d.contains(index-4, 2, "ME", "MA") {
+		d.result.appendAlternate("R")
+	} else {
+		d.result.append("R")
+	}
+	return d.skipDouble(index, 'R')
+}
+
+func (d *doubleMetaphone) handleS(index int) int {
+	switch {
+	case d.contains(index-1, 3, "ISL", "YSL"):
+		// "island", "isle", "carlisle", "carlysle"
+		return index + 1
+	case index == 0 && d.contains(index, 5, "SUGAR"):
+		d.result.append2("X", "S")
+		return index + 1
+	case d.contains(index, 2, "SH"):
+		if d.contains(index+1, 4, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			// germanic
+			d.result.append("S")
+		} else {
+			d.result.append("X")
+		}
+		return index + 2
+	case d.contains(index, 3, "SIO", "SIA") || d.contains(index, 4, "SIAN"):
+		// italian and armenian
+		if d.slavoGermanic {
+			d.result.append("S")
+		} else {
+			d.result.append2("S", "X")
+		}
+		return index + 3
+	case (index == 0 && d.contains(index+1, 1, "M", "N", "L", "W")) || d.contains(index+1, 1, "Z"):
+		// "smith" match "schmidt", "snider" match "schneider"
+		d.result.append2("S", "X")
+		if d.contains(index+1, 1, "Z") {
+			return index + 2
+		}
+		return index + 1
+	case d.contains(index, 2, "SC"):
+		return d.handleSC(index)
+	}
+	if index == len(d.value)-1 && d.contains(index-2, 2, "AI", "OI") {
+		// french "resnais", "artois"
+		d.result.appendAlternate("S")
+	} else {
+		d.result.append("S")
+	}
+	if d.contains(index+1, 1, "S", "Z") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (d *doubleMetaphone) handleSC(index int) int {
+	if d.at(index+2) == 'H' {
+		// schlesinger's rule
+		if d.contains(index+3, 2, "OO", "ER", "EN", "UY", "ED", "EM") {
+			// dutch origin, "school", "schooner"
+			if d.contains(index+3, 2, "ER", "EN") {
+				// "schermerhorn", "schenker"
+				d.result.append2("X", "SK")
+			} else {
+				d.result.append("SK")
+			}
+		} else if index == 0 && !d.isVowel(3) && d.at(3) != 'W' {
+			d.result.append2("X", "S")
+		} else {
+			d.result.append("X")
+		}
+	} else if d.contains(index+2, 1, "I", "E", "Y") {
+		d.result.append("S")
+	} else {
+		d.result.append("SK")
+	}
+	return index + 3
+}
+
+func (d *doubleMetaphone) handleT(index int) int {
+	if d.contains(index, 4, "TION") || d.contains(index, 3, "TIA", "TCH") {
+		d.result.append("X")
+		return index + 3
+	} else if d.contains(index, 2, "TH") || d.contains(index, 3, "TTH") {
+		if d.contains(index+2, 2, "OM", "AM") ||
+			d.contains(0, 4, "VAN ", "VON ") || d.contains(0, 3, "SCH") {
+			// "thomas", "thames" or germanic
+			d.result.append("T")
+		} else {
+			d.result.append2("0", "T")
+		}
+		return index + 2
+	}
+	d.result.append("T")
+	if d.contains(index+1, 1, "T", "D") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (d *doubleMetaphone) handleW(index int) int {
+	if d.contains(index, 2, "WR") {
+		// can also be in the middle of a word
+		d.result.append("R")
+		return index + 2
+	}
+	if index == 0 && (d.isVowel(index+1) || d.contains(index, 2, "WH")) {
+		if d.isVowel(index + 1) {
+			// "wasserman" should match "vasserman"
+			d.result.append2("A", "F")
+		} else {
+			// "uomo" should match "womo"
+			d.result.append("A")
+		}
+		return index + 1
+	} else if (index == len(d.value)-1 && d.isVowel(index-1)) ||
+		d.contains(index-1, 5, "EWSKI", "EWSKY", "OWSKI", "OWSKY") ||
+		d.contains(0, 3, "SCH") {
+		// "arnow" should match "arnoff"
+		d.result.appendAlternate("F")
+		return index + 1
+	} else if d.contains(index, 4, "WICZ", "WITZ") {
+		// polish "filipowicz"
+		d.result.append2("TS", "FX")
+		return index + 4
+	}
+	return index + 1
+}
+
+func (d *doubleMetaphone) handleX(index int) int {
+	if index == 0 {
+		d.result.append("S")
+		return index + 1
+	}
+	if !(index == len(d.value)-1 &&
+		(d.contains(index-3, 3, "IAU", "EAU") || d.contains(index-2, 2, "AU", "OU"))) {
+		// not french, as in "breaux"
+		d.result.append("KS")
+	}
+	if d.contains(index+1, 1, "C", "X") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (d *doubleMetaphone) handleZ(index int) int {
+	if d.at(index+1) == 'H' {
+		// chinese pinyin "zhao"
+		d.result.append("J")
+		return index + 2
+	}
+	if d.contains(index+1, 2, "ZO", "ZI", "ZA") || (d.slavoGermanic && index > 0 && d.at(index-1) != 'T') {
+		d.result.append2("S", "TS")
+	} else {
+		d.result.append("S")
+	}
+	return d.skipDouble(index, 'Z')
+}
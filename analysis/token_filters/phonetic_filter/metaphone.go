@@ -0,0 +1,182 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package phonetic_filter
+
+import (
+	"strings"
+)
+
+// the rules here follow the original metaphone
+// algorithm by Lawrence Philips, as implemented in
+// apache commons-codec
+
+func isMetaphoneVowel(r rune) bool {
+	return r == 'A' || r == 'E' || r == 'I' || r == 'O' || r == 'U'
+}
+
+// Metaphone computes the metaphone key of the input,
+// with at most maxLength characters
+func Metaphone(input string, maxLength int) string {
+	word := make([]rune, 0, len(input))
+	for _, r := range strings.ToUpper(input) {
+		if r >= 'A' && r <= 'Z' {
+			word = append(word, r)
+		}
+	}
+	if len(word) == 0 {
+		return ""
+	}
+
+	at := func(i int) rune {
+		if i < 0 || i >= len(word) {
+			return 0
+		}
+		return word[i]
+	}
+	regionMatch := func(i int, s string) bool {
+		if i < 0 || i+len(s) > len(word) {
+			return false
+		}
+		return string(word[i:i+len(s)]) == s
+	}
+
+	// handle the initial exceptions
+	switch {
+	case regionMatch(0, "AE"):
+		word = word[1:]
+		word[0] = 'E'
+	case regionMatch(0, "GN"), regionMatch(0, "KN"), regionMatch(0, "PN"), regionMatch(0, "WR"):
+		word = word[1:]
+	case word[0] == 'X':
+		word[0] = 'S'
+	case regionMatch(0, "WH"):
+		word = append([]rune{'W'}, word[2:]...)
+	}
+
+	last := len(word) - 1
+	rv := make([]rune, 0, maxLength)
+	for n := 0; n < len(word) && len(rv) < maxLength; n++ {
+		r := word[n]
+
+		// skip duplicate letters except C
+		if r != 'C' && at(n-1) == r {
+			continue
+		}
+
+		switch r {
+		case 'A', 'E', 'I', 'O', 'U':
+			if n == 0 {
+				rv = append(rv, r)
+			}
+		case 'B':
+			if !(n == last && at(n-1) == 'M') {
+				rv = append(rv, 'B')
+			}
+		case 'C':
+			switch {
+			case at(n-1) == 'S' && (at(n+1) == 'E' || at(n+1) == 'I' || at(n+1) == 'Y'):
+				// silent, as in "science"
+			case regionMatch(n, "CIA"):
+				rv = append(rv, 'X')
+			case at(n+1) == 'E' || at(n+1) == 'I' || at(n+1) == 'Y':
+				rv = append(rv, 'S')
+			case at(n-1) == 'S' && at(n+1) == 'H':
+				rv = append(rv, 'K')
+			case at(n+1) == 'H':
+				if n == 0 && len(word) >= 3 && !isMetaphoneVowel(at(2)) {
+					rv = append(rv, 'K')
+				} else {
+					rv = append(rv, 'X')
+				}
+			default:
+				rv = append(rv, 'K')
+			}
+		case 'D':
+			if at(n+1) == 'G' && (at(n+2) == 'E' || at(n+2) == 'I' || at(n+2) == 'Y') {
+				rv = append(rv, 'J')
+				n += 2
+			} else {
+				rv = append(rv, 'T')
+			}
+		case 'G':
+			if n+1 < last && at(n+1) == 'H' && !isMetaphoneVowel(at(n+2)) {
+				// silent, as in "night"
+				break
+			}
+			if n > 0 && (regionMatch(n, "GN") && n+1 == last || regionMatch(n, "GNED") && n+3 == last) {
+				break
+			}
+			hard := at(n-1) == 'G'
+			if (at(n+1) == 'I' || at(n+1) == 'E' || at(n+1) == 'Y') && !hard {
+				rv = append(rv, 'J')
+			} else {
+				rv = append(rv, 'K')
+			}
+		case 'H':
+			if n == last {
+				break
+			}
+			switch at(n - 1) {
+			case 'C', 'S', 'P', 'T', 'G':
+				break
+			default:
+				if isMetaphoneVowel(at(n + 1)) {
+					rv = append(rv, 'H')
+				}
+			}
+		case 'K':
+			if at(n-1) != 'C' {
+				rv = append(rv, 'K')
+			}
+		case 'P':
+			if at(n+1) == 'H' {
+				rv = append(rv, 'F')
+			} else {
+				rv = append(rv, 'P')
+			}
+		case 'Q':
+			rv = append(rv, 'K')
+		case 'S':
+			if regionMatch(n, "SH") || regionMatch(n, "SIO") || regionMatch(n, "SIA") {
+				rv = append(rv, 'X')
+			} else {
+				rv = append(rv, 'S')
+			}
+		case 'T':
+			switch {
+			case regionMatch(n, "TIA"), regionMatch(n, "TIO"):
+				rv = append(rv, 'X')
+			case regionMatch(n, "TCH"):
+				// silent
+			case regionMatch(n, "TH"):
+				rv = append(rv, '0')
+			default:
+				rv = append(rv, 'T')
+			}
+		case 'V':
+			rv = append(rv, 'F')
+		case 'W', 'Y':
+			if n < last && isMetaphoneVowel(at(n+1)) {
+				rv = append(rv, r)
+			}
+		case 'X':
+			rv = append(rv, 'K', 'S')
+		case 'Z':
+			rv = append(rv, 'S')
+		default:
+			// F J L M N R
+			rv = append(rv, r)
+		}
+	}
+	if len(rv) > maxLength {
+		rv = rv[:maxLength]
+	}
+	return string(rv)
+}
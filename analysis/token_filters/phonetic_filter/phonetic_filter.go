@@ -0,0 +1,126 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package phonetic_filter
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "phonetic"
+
+const (
+	SoundexEncoder         = "soundex"
+	MetaphoneEncoder       = "metaphone"
+	DoubleMetaphoneEncoder = "double_metaphone"
+)
+
+const DefaultMaxCodeLength = 4
+
+// an encoder returns the phonetic keys for a term,
+// most encoders produce exactly one key
+type encoder func(term string) []string
+
+func soundexEncoder(term string) []string {
+	return []string{Soundex(term)}
+}
+
+func metaphoneEncoder(term string) []string {
+	return []string{Metaphone(term, DefaultMaxCodeLength)}
+}
+
+func doubleMetaphoneEncoder(term string) []string {
+	primary, alternate := DoubleMetaphone(term, DefaultMaxCodeLength)
+	if alternate == "" || alternate == primary {
+		return []string{primary}
+	}
+	return []string{primary, alternate}
+}
+
+var encoders = map[string]encoder{
+	SoundexEncoder:         soundexEncoder,
+	MetaphoneEncoder:       metaphoneEncoder,
+	DoubleMetaphoneEncoder: doubleMetaphoneEncoder,
+}
+
+// PhoneticFilter replaces each token with its phonetic
+// key.  When replace is false the original token is kept
+// and the keys are added at the same position.  Double
+// metaphone alternates are also added at the same
+// position.
+type PhoneticFilter struct {
+	encoder encoder
+	replace bool
+}
+
+func NewPhoneticFilter(encoderName string, replace bool) (*PhoneticFilter, error) {
+	enc, ok := encoders[encoderName]
+	if !ok {
+		return nil, fmt.Errorf("unknown phonetic encoder '%s'", encoderName)
+	}
+	return &PhoneticFilter{
+		encoder: enc,
+		replace: replace,
+	}, nil
+}
+
+func (f *PhoneticFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input))
+
+	for _, token := range input {
+		if token.KeyWord {
+			rv = append(rv, token)
+			continue
+		}
+		keys := f.encoder(string(token.Term))
+		if len(keys) == 0 || keys[0] == "" {
+			// nothing encodable, such as numbers
+			rv = append(rv, token)
+			continue
+		}
+		if !f.replace {
+			rv = append(rv, token)
+		}
+		for _, key := range keys {
+			if !f.replace && key == string(token.Term) {
+				continue
+			}
+			phoneticToken := analysis.Token{
+				Term:     []byte(key),
+				Position: token.Position,
+				Start:    token.Start,
+				End:      token.End,
+				Type:     token.Type,
+			}
+			rv = append(rv, &phoneticToken)
+		}
+	}
+
+	return rv
+}
+
+func PhoneticFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	encoderName, ok := config["encoder"].(string)
+	if !ok {
+		return nil, fmt.Errorf("must specify encoder")
+	}
+	replace := true
+	replaceVal, ok := config["replace"].(bool)
+	if ok {
+		replace = replaceVal
+	}
+	return NewPhoneticFilter(encoderName, replace)
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, PhoneticFilterConstructor)
+}
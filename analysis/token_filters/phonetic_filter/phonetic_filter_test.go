@@ -0,0 +1,216 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package phonetic_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+func TestSoundex(t *testing.T) {
+	tests := map[string]string{
+		"Robert":   "R163",
+		"Rupert":   "R163",
+		"Ashcraft": "A261",
+		"Tymczak":  "T522",
+		"Pfister":  "P236",
+		"Lee":      "L000",
+		"1234":     "",
+	}
+	for input, expected := range tests {
+		actual := Soundex(input)
+		if actual != expected {
+			t.Errorf("expected soundex of %s to be %s, got %s", input, expected, actual)
+		}
+	}
+}
+
+func TestMetaphone(t *testing.T) {
+	tests := map[string]string{
+		"Thompson": "0MPS",
+		"Knight":   "NT",
+		"Philip":   "FLP",
+		"Xavier":   "SFR",
+		"Michael":  "MXL",
+		"Schmidt":  "SKMT",
+	}
+	for input, expected := range tests {
+		actual := Metaphone(input, DefaultMaxCodeLength)
+		if actual != expected {
+			t.Errorf("expected metaphone of %s to be %s, got %s", input, expected, actual)
+		}
+	}
+}
+
+func TestDoubleMetaphone(t *testing.T) {
+	tests := []struct {
+		input     string
+		primary   string
+		alternate string
+	}{
+		{"Smith", "SM0", "XMT"},
+		{"Schmidt", "XMT", "SMT"},
+		{"Catherine", "K0RN", "KTRN"},
+		{"Michael", "MKL", "MXL"},
+		{"Jose", "HS", "HS"},
+		{"Xavier", "SF", "SFR"},
+		{"Filipowicz", "FLPT", "FLPF"},
+		{"Gough", "KF", "KF"},
+	}
+	for _, test := range tests {
+		primary, alternate := DoubleMetaphone(test.input, DefaultMaxCodeLength)
+		if primary != test.primary || alternate != test.alternate {
+			t.Errorf("expected double metaphone of %s to be %s/%s, got %s/%s", test.input, test.primary, test.alternate, primary, alternate)
+		}
+	}
+}
+
+func TestPhoneticFilter(t *testing.T) {
+
+	tests := []struct {
+		encoder string
+		replace bool
+		input   analysis.TokenStream
+		output  analysis.TokenStream
+	}{
+		{
+			encoder: SoundexEncoder,
+			replace: true,
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("robert"),
+					Position: 1,
+					Start:    0,
+					End:      6,
+				},
+				&analysis.Token{
+					Term:     []byte("42"),
+					Position: 2,
+					Start:    7,
+					End:      9,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("R163"),
+					Position: 1,
+					Start:    0,
+					End:      6,
+				},
+				&analysis.Token{
+					Term:     []byte("42"),
+					Position: 2,
+					Start:    7,
+					End:      9,
+				},
+			},
+		},
+		{
+			encoder: MetaphoneEncoder,
+			replace: false,
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("philip"),
+					Position: 1,
+					Start:    0,
+					End:      6,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("philip"),
+					Position: 1,
+					Start:    0,
+					End:      6,
+				},
+				&analysis.Token{
+					Term:     []byte("FLP"),
+					Position: 1,
+					Start:    0,
+					End:      6,
+				},
+			},
+		},
+		{
+			encoder: DoubleMetaphoneEncoder,
+			replace: true,
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("smith"),
+					Position: 1,
+					Start:    0,
+					End:      5,
+				},
+				&analysis.Token{
+					Term:     []byte("philip"),
+					Position: 2,
+					Start:    6,
+					End:      12,
+				},
+				&analysis.Token{
+					Term:     []byte("robert"),
+					Position: 3,
+					Start:    13,
+					End:      19,
+					KeyWord:  true,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("SM0"),
+					Position: 1,
+					Start:    0,
+					End:      5,
+				},
+				&analysis.Token{
+					Term:     []byte("XMT"),
+					Position: 1,
+					Start:    0,
+					End:      5,
+				},
+				&analysis.Token{
+					Term:     []byte("FLP"),
+					Position: 2,
+					Start:    6,
+					End:      12,
+				},
+				&analysis.Token{
+					Term:     []byte("robert"),
+					Position: 3,
+					Start:    13,
+					End:      19,
+					KeyWord:  true,
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		phoneticFilter, err := NewPhoneticFilter(test.encoder, test.replace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := phoneticFilter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v", test.output, actual)
+		}
+	}
+}
+
+func TestPhoneticFilterUnknownEncoder(t *testing.T) {
+	_, err := PhoneticFilterConstructor(map[string]interface{}{
+		"encoder": "caverphone",
+	}, nil)
+	if err == nil {
+		t.Errorf("expected error for unknown encoder")
+	}
+}
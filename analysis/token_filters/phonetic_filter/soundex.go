@@ -0,0 +1,57 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package phonetic_filter
+
+import (
+	"strings"
+)
+
+const soundexLength = 4
+
+// soundexCodes maps A-Z to their soundex digit,
+// vowels are 0 and H and W are -
+var soundexCodes = []byte("0123012-02245501262301-202")
+
+// Soundex computes the American Soundex code of the input,
+// non-latin letters are ignored
+func Soundex(input string) string {
+	input = strings.ToUpper(input)
+	rv := make([]byte, 0, soundexLength)
+	var last byte
+	for _, r := range input {
+		if r < 'A' || r > 'Z' {
+			continue
+		}
+		code := soundexCodes[r-'A']
+		if len(rv) == 0 {
+			rv = append(rv, byte(r))
+			last = code
+			continue
+		}
+		if code == '-' {
+			// H and W do not separate letters with the same code
+			continue
+		}
+		if code != '0' && code != last {
+			rv = append(rv, code)
+			if len(rv) == soundexLength {
+				break
+			}
+		}
+		last = code
+	}
+	if len(rv) == 0 {
+		return ""
+	}
+	for len(rv) < soundexLength {
+		rv = append(rv, '0')
+	}
+	return string(rv)
+}
@@ -0,0 +1,180 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build icu full
+
+package pinyin_filter
+
+// #cgo LDFLAGS: -licui18n -licuuc -licudata
+// #include <stdlib.h>
+// #include "unicode/utypes.h"
+// #include "unicode/utrans.h"
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "pinyin"
+
+// PinyinFilter adds the pinyin transliteration of tokens
+// containing Chinese characters, so that Chinese text can
+// be found using a latin keyboard.  The full pinyin is
+// added with the syllables joined, for example 中国 gives
+// "zhongguo", and the initials give "zg".  Tone marks are
+// removed unless tones is set.  The transliteration uses
+// the ICU Han-Latin transform.
+type PinyinFilter struct {
+	full             bool
+	initials         bool
+	preserveOriginal bool
+
+	mutex sync.Mutex
+	trans *C.UTransliterator
+}
+
+func NewPinyinFilter(full, initials, preserveOriginal, tones bool) (*PinyinFilter, error) {
+	id := "Han-Latin"
+	if !tones {
+		id += "; Latin-ASCII"
+	}
+	idUTF16 := utf16.Encode([]rune(id))
+	var err C.UErrorCode = C.U_ZERO_ERROR
+	trans := C.utrans_openU((*C.UChar)(unsafe.Pointer(&idUTF16[0])), C.int32_t(len(idUTF16)),
+		C.UTRANS_FORWARD, nil, 0, nil, &err)
+	if err > C.U_ZERO_ERROR {
+		return nil, fmt.Errorf("error opening ICU transliterator '%s': %d", id, int(err))
+	}
+	return &PinyinFilter{
+		full:             full,
+		initials:         initials,
+		preserveOriginal: preserveOriginal,
+		trans:            trans,
+	}, nil
+}
+
+func containsHan(term []byte) bool {
+	for _, r := range string(term) {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *PinyinFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input))
+
+	for _, token := range input {
+		if !containsHan(token.Term) {
+			rv = append(rv, token)
+			continue
+		}
+		syllables, ok := f.transliterate(string(token.Term))
+		if !ok || len(syllables) == 0 {
+			rv = append(rv, token)
+			continue
+		}
+
+		seen := make(map[string]bool, 3)
+		if f.preserveOriginal {
+			seen[string(token.Term)] = true
+			rv = append(rv, token)
+		}
+		var terms []string
+		if f.full {
+			terms = append(terms, strings.Join(syllables, ""))
+		}
+		if f.initials {
+			initials := make([]rune, 0, len(syllables))
+			for _, syllable := range syllables {
+				initials = append(initials, []rune(syllable)[0])
+			}
+			terms = append(terms, string(initials))
+		}
+		for _, term := range terms {
+			if seen[term] {
+				continue
+			}
+			seen[term] = true
+			pinyinToken := analysis.Token{
+				Term:     []byte(term),
+				Position: token.Position,
+				Start:    token.Start,
+				End:      token.End,
+				Type:     token.Type,
+			}
+			rv = append(rv, &pinyinToken)
+		}
+	}
+
+	return rv
+}
+
+// transliterate returns the lower cased syllables of the
+// pinyin for the input
+func (f *PinyinFilter) transliterate(input string) ([]string, bool) {
+	text := utf16.Encode([]rune(input))
+	capacity := len(text)*8 + 16
+	for {
+		buf := make([]uint16, capacity)
+		copy(buf, text)
+		textLength := C.int32_t(len(text))
+		limit := C.int32_t(len(text))
+		var err C.UErrorCode = C.U_ZERO_ERROR
+		f.mutex.Lock()
+		C.utrans_transUChars(f.trans, (*C.UChar)(unsafe.Pointer(&buf[0])), &textLength,
+			C.int32_t(capacity), 0, &limit, &err)
+		f.mutex.Unlock()
+		if err == C.U_BUFFER_OVERFLOW_ERROR && int(textLength) > capacity {
+			capacity = int(textLength)
+			continue
+		}
+		if err > C.U_ZERO_ERROR {
+			return nil, false
+		}
+		output := strings.ToLower(string(utf16.Decode(buf[:int(textLength)])))
+		return strings.Fields(output), true
+	}
+}
+
+func PinyinFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	full := true
+	fullVal, ok := config["full"].(bool)
+	if ok {
+		full = fullVal
+	}
+	initials := true
+	initialsVal, ok := config["initials"].(bool)
+	if ok {
+		initials = initialsVal
+	}
+	if !full && !initials {
+		return nil, fmt.Errorf("at least one of full or initials must be enabled")
+	}
+	preserveOriginal := true
+	preserveVal, ok := config["preserve_original"].(bool)
+	if ok {
+		preserveOriginal = preserveVal
+	}
+	tones, _ := config["tones"].(bool)
+	return NewPinyinFilter(full, initials, preserveOriginal, tones)
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, PinyinFilterConstructor)
+}
@@ -0,0 +1,93 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build icu full
+
+package pinyin_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestPinyinFilter(t *testing.T) {
+
+	tests := []struct {
+		full             bool
+		initials         bool
+		preserveOriginal bool
+		tones            bool
+		input            analysis.TokenStream
+		output           analysis.TokenStream
+	}{
+		{
+			full:             true,
+			initials:         true,
+			preserveOriginal: true,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("中国"), Position: 1, Start: 0, End: 6, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("bleve"), Position: 2, Start: 7, End: 12},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("中国"), Position: 1, Start: 0, End: 6, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("zhongguo"), Position: 1, Start: 0, End: 6, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("zg"), Position: 1, Start: 0, End: 6, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("bleve"), Position: 2, Start: 7, End: 12},
+			},
+		},
+		{
+			full:             true,
+			initials:         false,
+			preserveOriginal: false,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("北京"), Position: 1, Start: 0, End: 6, Type: analysis.Ideographic},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("beijing"), Position: 1, Start: 0, End: 6, Type: analysis.Ideographic},
+			},
+		},
+		{
+			full:             true,
+			initials:         false,
+			preserveOriginal: false,
+			tones:            true,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("中国"), Position: 1, Start: 0, End: 6, Type: analysis.Ideographic},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("zhōngguó"), Position: 1, Start: 0, End: 6, Type: analysis.Ideographic},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		filter, err := NewPinyinFilter(test.full, test.initials, test.preserveOriginal, test.tones)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := filter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %s, got %s", test.output, actual)
+		}
+	}
+}
+
+func TestPinyinFilterConstructor(t *testing.T) {
+	cache := registry.NewCache()
+	_, err := PinyinFilterConstructor(map[string]interface{}{
+		"full":     false,
+		"initials": false,
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error with full and initials disabled")
+	}
+}
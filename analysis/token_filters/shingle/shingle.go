@@ -10,31 +10,49 @@ import (
 
 const Name = "shingle"
 
+// ShingleFilter combines adjacent tokens into shingles of
+// min to max tokens joined by the token separator.  Gaps
+// in the positions, such as those left by removed stop
+// words, are filled with the fill token.  The original
+// unigrams are output when outputOriginal is set, or when
+// outputUnigramsIfNoShingles is set and the input was too
+// short to build any shingles, which is useful for single
+// word autocomplete queries.
 type ShingleFilter struct {
-	min            int
-	max            int
-	outputOriginal bool
-	tokenSeparator string
-	fill           string
-	ring           *ring.Ring
-	itemsInRing    int
+	min                        int
+	max                        int
+	outputOriginal             bool
+	outputUnigramsIfNoShingles bool
+	tokenSeparator             string
+	fill                       string
 }
 
-func NewShingleFilter(min, max int, outputOriginal bool, sep, fill string) *ShingleFilter {
+func NewShingleFilter(min, max int, outputOriginal, outputUnigramsIfNoShingles bool, sep, fill string) *ShingleFilter {
 	return &ShingleFilter{
-		min:            min,
-		max:            max,
-		outputOriginal: outputOriginal,
-		tokenSeparator: sep,
-		fill:           fill,
-		ring:           ring.New(max),
+		min:                        min,
+		max:                        max,
+		outputOriginal:             outputOriginal,
+		outputUnigramsIfNoShingles: outputUnigramsIfNoShingles,
+		tokenSeparator:             sep,
+		fill:                       fill,
 	}
 }
 
+// shingleState is the window of recent tokens, it is kept
+// per call so the filter can be shared
+type shingleState struct {
+	ring        *ring.Ring
+	itemsInRing int
+}
+
 func (s *ShingleFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
 	rv := make(analysis.TokenStream, 0, len(input))
+	state := &shingleState{
+		ring: ring.New(s.max),
+	}
 
 	currentPosition := 0
+	shingles := 0
 	for _, token := range input {
 		if s.outputOriginal {
 			rv = append(rv, token)
@@ -50,35 +68,43 @@ func (s *ShingleFilter) Filter(input analysis.TokenStream) analysis.TokenStream
 				Type:     analysis.AlphaNumeric,
 				Term:     []byte(s.fill),
 			}
-			s.ring.Value = &fillerToken
-			if s.itemsInRing < s.max {
-				s.itemsInRing++
+			state.ring.Value = &fillerToken
+			if state.itemsInRing < s.max {
+				state.itemsInRing++
 			}
-			rv = append(rv, s.shingleCurrentRingState()...)
-			s.ring = s.ring.Next()
+			shingled := s.shingleCurrentRingState(state)
+			shingles += len(shingled)
+			rv = append(rv, shingled...)
+			state.ring = state.ring.Next()
 			offset--
 		}
 		currentPosition = token.Position
 
-		s.ring.Value = token
-		if s.itemsInRing < s.max {
-			s.itemsInRing++
+		state.ring.Value = token
+		if state.itemsInRing < s.max {
+			state.itemsInRing++
 		}
-		rv = append(rv, s.shingleCurrentRingState()...)
-		s.ring = s.ring.Next()
+		shingled := s.shingleCurrentRingState(state)
+		shingles += len(shingled)
+		rv = append(rv, shingled...)
+		state.ring = state.ring.Next()
+
+	}
 
+	if shingles == 0 && !s.outputOriginal && s.outputUnigramsIfNoShingles {
+		return input
 	}
 
 	return rv
 }
 
-func (s *ShingleFilter) shingleCurrentRingState() analysis.TokenStream {
+func (s *ShingleFilter) shingleCurrentRingState(state *shingleState) analysis.TokenStream {
 	rv := make(analysis.TokenStream, 0)
 	for shingleN := s.min; shingleN <= s.max; shingleN++ {
 		// if there are enough items in the ring
 		// to produce a shingle of this size
-		if s.itemsInRing >= shingleN {
-			thisShingleRing := s.ring.Move(-(shingleN - 1))
+		if state.itemsInRing >= shingleN {
+			thisShingleRing := state.ring.Move(-(shingleN - 1))
 			shingledBytes := make([]byte, 0)
 			pos := 0
 			start := -1
@@ -137,6 +163,12 @@ func ShingleFilterConstructor(config map[string]interface{}, cache *registry.Cac
 		outputOriginal = outVal
 	}
 
+	outputUnigramsIfNoShingles := false
+	noShinglesVal, ok := config["output_unigrams_if_no_shingles"].(bool)
+	if ok {
+		outputUnigramsIfNoShingles = noShinglesVal
+	}
+
 	sep := " "
 	sepVal, ok := config["separator"].(string)
 	if ok {
@@ -149,7 +181,7 @@ func ShingleFilterConstructor(config map[string]interface{}, cache *registry.Cac
 		fill = fillVal
 	}
 
-	return NewShingleFilter(min, max, outputOriginal, sep, fill), nil
+	return NewShingleFilter(min, max, outputOriginal, outputUnigramsIfNoShingles, sep, fill), nil
 }
 
 func init() {
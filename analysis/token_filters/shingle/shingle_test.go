@@ -321,10 +321,82 @@ func TestShingleFilter(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		shingleFilter := NewShingleFilter(test.min, test.max, test.outputOriginal, test.separator, test.filler)
+		shingleFilter := NewShingleFilter(test.min, test.max, test.outputOriginal, false, test.separator, test.filler)
 		actual := shingleFilter.Filter(test.input)
 		if !reflect.DeepEqual(actual, test.output) {
 			t.Errorf("expected %s, got %s", test.output, actual)
 		}
 	}
 }
+
+func TestShingleFilterOutputUnigramsIfNoShingles(t *testing.T) {
+
+	tests := []struct {
+		input  analysis.TokenStream
+		output analysis.TokenStream
+	}{
+		// too short for shingles, unigrams are output
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("quick"),
+					Position: 1,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("quick"),
+					Position: 1,
+				},
+			},
+		},
+		// shingles were produced, unigrams are suppressed
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("quick"),
+					Position: 1,
+				},
+				&analysis.Token{
+					Term:     []byte("fox"),
+					Position: 2,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("quick fox"),
+					Type:     analysis.Shingle,
+					Position: 1,
+				},
+			},
+		},
+	}
+
+	shingleFilter := NewShingleFilter(2, 2, false, true, " ", "_")
+	for _, test := range tests {
+		actual := shingleFilter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %s, got %s", test.output, actual)
+		}
+	}
+}
+
+func TestShingleFilterReuse(t *testing.T) {
+	shingleFilter := NewShingleFilter(2, 2, false, false, "-", "_")
+
+	first := shingleFilter.Filter(analysis.TokenStream{
+		&analysis.Token{Term: []byte("the"), Position: 1},
+		&analysis.Token{Term: []byte("end"), Position: 2},
+	})
+	if len(first) != 1 || string(first[0].Term) != "the-end" {
+		t.Errorf("expected single shingle 'the-end', got %s", first)
+	}
+
+	// earlier tokens must not be shingled into a new stream
+	second := shingleFilter.Filter(analysis.TokenStream{
+		&analysis.Token{Term: []byte("start"), Position: 1},
+	})
+	if len(second) != 0 {
+		t.Errorf("expected no shingles, got %s", second)
+	}
+}
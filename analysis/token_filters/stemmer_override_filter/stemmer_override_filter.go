@@ -0,0 +1,181 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package stemmer_override_filter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "stemmer_override"
+
+// StemmerOverrideMap maps terms to the stem they
+// should always be given
+type StemmerOverrideMap map[string]string
+
+func NewStemmerOverrideMap() StemmerOverrideMap {
+	return make(StemmerOverrideMap)
+}
+
+func (m StemmerOverrideMap) LoadFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return m.LoadBytes(data)
+}
+
+func (m StemmerOverrideMap) LoadBytes(data []byte) error {
+	bytesReader := bytes.NewReader(data)
+	bufioReader := bufio.NewReader(bytesReader)
+	line, err := bufioReader.ReadString('\n')
+	for err == nil {
+		err = m.LoadLine(line)
+		if err != nil {
+			return err
+		}
+		line, err = bufioReader.ReadString('\n')
+	}
+	// if the err was EOF we still need to process the last value
+	if err == io.EOF {
+		return m.LoadLine(line)
+	}
+	return err
+}
+
+// LoadLine parses a single override, either in the
+// form "term => stem" or as a tab separated pair
+// "term<TAB>stem".  Blank lines and comments starting
+// with # are ignored.
+func (m StemmerOverrideMap) LoadLine(line string) error {
+	startComment := strings.Index(line, "#")
+	if startComment >= 0 {
+		line = line[:startComment]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	var parts []string
+	if strings.Contains(line, "=>") {
+		parts = strings.SplitN(line, "=>", 2)
+	} else {
+		parts = strings.SplitN(line, "\t", 2)
+	}
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid stemmer override '%s'", line)
+	}
+	term := strings.TrimSpace(parts[0])
+	stem := strings.TrimSpace(parts[1])
+	if term == "" || stem == "" {
+		return fmt.Errorf("invalid stemmer override '%s'", line)
+	}
+	m.AddOverride(term, stem)
+	return nil
+}
+
+func (m StemmerOverrideMap) AddOverride(term, stem string) {
+	m[term] = stem
+}
+
+// StemmerOverrideFilter replaces terms found in the
+// override map with their configured stem and marks
+// them as keywords, so that stemmers later in the
+// chain leave them alone.  It must be placed before
+// the algorithmic stemmer.
+type StemmerOverrideFilter struct {
+	overrides  StemmerOverrideMap
+	ignoreCase bool
+}
+
+func NewStemmerOverrideFilter(overrides StemmerOverrideMap, ignoreCase bool) *StemmerOverrideFilter {
+	if ignoreCase {
+		lowered := make(StemmerOverrideMap, len(overrides))
+		for term, stem := range overrides {
+			lowered[strings.ToLower(term)] = stem
+		}
+		overrides = lowered
+	}
+	return &StemmerOverrideFilter{
+		overrides:  overrides,
+		ignoreCase: ignoreCase,
+	}
+}
+
+func (f *StemmerOverrideFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		if token.KeyWord {
+			continue
+		}
+		term := string(token.Term)
+		if f.ignoreCase {
+			term = strings.ToLower(term)
+		}
+		stem, ok := f.overrides[term]
+		if ok {
+			token.Term = []byte(stem)
+			token.KeyWord = true
+		}
+	}
+	return input
+}
+
+// StemmerOverrideMapFromConfig builds a StemmerOverrideMap
+// from either a "filename" or an inline list of "overrides".
+func StemmerOverrideMapFromConfig(config map[string]interface{}) (StemmerOverrideMap, error) {
+	rv := NewStemmerOverrideMap()
+
+	// first: try to load by filename
+	filename, ok := config["filename"].(string)
+	if ok {
+		err := rv.LoadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return rv, nil
+	}
+	// next: look for an inline list of overrides
+	overrides, ok := config["overrides"].([]interface{})
+	if ok {
+		for _, override := range overrides {
+			overrideStr, ok := override.(string)
+			if !ok {
+				return nil, fmt.Errorf("stemmer override must be a string")
+			}
+			err := rv.LoadLine(overrideStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return rv, nil
+	}
+	return nil, fmt.Errorf("must specify filename or list of overrides")
+}
+
+func StemmerOverrideFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	overrides, err := StemmerOverrideMapFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error building stemmer override filter: %v", err)
+	}
+	ignoreCase, _ := config["ignore_case"].(bool)
+	return NewStemmerOverrideFilter(overrides, ignoreCase), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, StemmerOverrideFilterConstructor)
+}
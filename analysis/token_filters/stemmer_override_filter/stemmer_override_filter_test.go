@@ -0,0 +1,135 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package stemmer_override_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/token_filters/porter"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestStemmerOverrideFilter(t *testing.T) {
+
+	overrides := []interface{}{
+		"# a comment",
+		"mice => mouse",
+		"bleve\tbleve",
+		"Running => run",
+	}
+
+	tests := []struct {
+		ignoreCase bool
+		input      analysis.TokenStream
+		output     analysis.TokenStream
+	}{
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("mice")},
+				&analysis.Token{Term: []byte("bleve")},
+				&analysis.Token{Term: []byte("cats")},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("mouse"), KeyWord: true},
+				&analysis.Token{Term: []byte("bleve"), KeyWord: true},
+				&analysis.Token{Term: []byte("cat")},
+			},
+		},
+		// case is significant by default
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("running")},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("run")},
+			},
+		},
+		{
+			ignoreCase: true,
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("RUNNING")},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("run"), KeyWord: true},
+			},
+		},
+		// existing keywords are left alone
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("mice"), KeyWord: true},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("mice"), KeyWord: true},
+			},
+		},
+	}
+
+	cache := registry.NewCache()
+	for _, test := range tests {
+		config := map[string]interface{}{
+			"type":        Name,
+			"overrides":   overrides,
+			"ignore_case": test.ignoreCase,
+		}
+		overrideFilter, err := StemmerOverrideFilterConstructor(config, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stemmer := porter.NewPorterStemmer()
+		actual := stemmer.Filter(overrideFilter.Filter(test.input))
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %#v, got %#v", test.output, actual)
+		}
+	}
+}
+
+func TestStemmerOverrideMapLoadLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		term  string
+		stem  string
+		isErr bool
+	}{
+		{line: "mice => mouse", term: "mice", stem: "mouse"},
+		{line: "geese\tgoose", term: "geese", stem: "goose"},
+		{line: "# only a comment"},
+		{line: "   "},
+		{line: "mice", isErr: true},
+		{line: "mice =>", isErr: true},
+	}
+
+	for _, test := range tests {
+		m := NewStemmerOverrideMap()
+		err := m.LoadLine(test.line)
+		if (err != nil) != test.isErr {
+			t.Errorf("expected error %t for '%s', got %v", test.isErr, test.line, err)
+			continue
+		}
+		if test.term != "" && m[test.term] != test.stem {
+			t.Errorf("expected '%s' to map to '%s', got '%s'", test.term, test.stem, m[test.term])
+		}
+	}
+}
+
+func TestStemmerOverrideFilterConstructorErrors(t *testing.T) {
+	cache := registry.NewCache()
+	_, err := StemmerOverrideFilterConstructor(map[string]interface{}{}, cache)
+	if err == nil {
+		t.Errorf("expected error without overrides")
+	}
+	_, err = StemmerOverrideFilterConstructor(map[string]interface{}{
+		"overrides": []interface{}{1.0},
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error with non-string override")
+	}
+}
@@ -20,6 +20,7 @@ const Name = "stop_tokens"
 
 type StopTokensFilter struct {
 	stopTokens analysis.TokenMap
+	source     *stopWordsSource
 }
 
 func NewStopTokensFilter(stopTokens analysis.TokenMap) *StopTokensFilter {
@@ -28,11 +29,21 @@ func NewStopTokensFilter(stopTokens analysis.TokenMap) *StopTokensFilter {
 	}
 }
 
+func newStopTokensFilterFromSource(source *stopWordsSource) *StopTokensFilter {
+	return &StopTokensFilter{
+		source: source,
+	}
+}
+
 func (f *StopTokensFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
 	rv := make(analysis.TokenStream, 0, len(input))
 
+	stopTokens := f.stopTokens
+	if f.source != nil {
+		stopTokens = f.source.tokenMap()
+	}
 	for _, token := range input {
-		_, isStopToken := f.stopTokens[string(token.Term)]
+		_, isStopToken := stopTokens[string(token.Term)]
 		if !isStopToken {
 			rv = append(rv, token)
 		}
@@ -42,9 +53,25 @@ func (f *StopTokensFilter) Filter(input analysis.TokenStream) analysis.TokenStre
 }
 
 func StopTokensFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	stopWordsFile, ok := config["stop_words_file"].(string)
+	if ok {
+		source, err := fileSource(stopWordsFile)
+		if err != nil {
+			return nil, fmt.Errorf("error building stop words filter: %v", err)
+		}
+		return newStopTokensFilterFromSource(source), nil
+	}
+	stopWordsReader, ok := config["stop_words_reader"].(string)
+	if ok {
+		source, err := readerSource(stopWordsReader)
+		if err != nil {
+			return nil, fmt.Errorf("error building stop words filter: %v", err)
+		}
+		return newStopTokensFilterFromSource(source), nil
+	}
 	stopTokenMapName, ok := config["stop_token_map"].(string)
 	if !ok {
-		return nil, fmt.Errorf("must specify stop_token_map")
+		return nil, fmt.Errorf("must specify stop_token_map, stop_words_file or stop_words_reader")
 	}
 	stopTokenMap, err := cache.TokenMapNamed(stopTokenMapName)
 	if err != nil {
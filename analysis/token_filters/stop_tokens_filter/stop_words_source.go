@@ -0,0 +1,139 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package stop_tokens_filter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+// StopWordsReader opens a source of stop words, one or
+// more per line in the format accepted by
+// analysis.TokenMap.LoadFile.  If the returned reader is
+// also an io.Closer it is closed after reading.
+type StopWordsReader func() (io.Reader, error)
+
+// stopWordsSource is a stop word list loaded from a file or
+// registered reader, shared by all the filters using it
+// and replaced as a whole when reloaded
+type stopWordsSource struct {
+	open StopWordsReader
+
+	mutex  sync.RWMutex
+	tokens analysis.TokenMap
+}
+
+func (s *stopWordsSource) load() error {
+	r, err := s.open()
+	if err != nil {
+		return err
+	}
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+	tokens := analysis.NewTokenMap()
+	err = tokens.LoadReader(r)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.tokens = tokens
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *stopWordsSource) tokenMap() analysis.TokenMap {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.tokens
+}
+
+var sourcesMutex sync.Mutex
+var readers = make(map[string]StopWordsReader)
+var sources = make(map[string]*stopWordsSource)
+
+// RegisterStopWordsReader makes a source of stop words
+// available to stop filters as stop_words_reader
+func RegisterStopWordsReader(name string, open StopWordsReader) {
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+	_, exists := readers[name]
+	if exists {
+		panic(fmt.Errorf("attempted to register duplicate stop words reader named '%s'", name))
+	}
+	readers[name] = open
+}
+
+func fileStopWordsReader(filename string) StopWordsReader {
+	return func() (io.Reader, error) {
+		return os.Open(filename)
+	}
+}
+
+// sourceFor returns the cached source for key, loading it
+// the first time it is used
+func sourceFor(key string, open StopWordsReader) (*stopWordsSource, error) {
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+	source, ok := sources[key]
+	if ok {
+		return source, nil
+	}
+	source = &stopWordsSource{
+		open: open,
+	}
+	err := source.load()
+	if err != nil {
+		return nil, err
+	}
+	sources[key] = source
+	return source, nil
+}
+
+func fileSource(filename string) (*stopWordsSource, error) {
+	return sourceFor("file:"+filename, fileStopWordsReader(filename))
+}
+
+func readerSource(name string) (*stopWordsSource, error) {
+	sourcesMutex.Lock()
+	open, ok := readers[name]
+	sourcesMutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no stop words reader named '%s' registered", name)
+	}
+	return sourceFor("reader:"+name, open)
+}
+
+// ReloadStopWords reads all the stop word files and
+// readers in use again, filters built from them see the
+// new lists from their next call.  If a source fails to
+// load it keeps its previous list and the first error is
+// returned.
+func ReloadStopWords() error {
+	sourcesMutex.Lock()
+	toReload := make([]*stopWordsSource, 0, len(sources))
+	for _, source := range sources {
+		toReload = append(toReload, source)
+	}
+	sourcesMutex.Unlock()
+
+	var rv error
+	for _, source := range toReload {
+		err := source.load()
+		if err != nil && rv == nil {
+			rv = err
+		}
+	}
+	return rv
+}
@@ -0,0 +1,125 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package stop_tokens_filter
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func terms(tokens analysis.TokenStream) []string {
+	rv := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		rv = append(rv, string(token.Term))
+	}
+	return rv
+}
+
+func walkInThePark() analysis.TokenStream {
+	return analysis.TokenStream{
+		&analysis.Token{Term: []byte("a")},
+		&analysis.Token{Term: []byte("walk")},
+		&analysis.Token{Term: []byte("in")},
+		&analysis.Token{Term: []byte("the")},
+		&analysis.Token{Term: []byte("park")},
+	}
+}
+
+func TestStopWordsFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "stop_words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("# test stop words\na the\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := registry.NewCache()
+	config := map[string]interface{}{
+		"stop_words_file": f.Name(),
+	}
+	filter, err := StopTokensFilterConstructor(config, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := StopTokensFilterConstructor(config, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.(*StopTokensFilter).source != other.(*StopTokensFilter).source {
+		t.Errorf("expected filters for the same file to share the stop words")
+	}
+
+	expected := []string{"walk", "in", "park"}
+	actual := terms(filter.Filter(walkInThePark()))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	err = ioutil.WriteFile(f.Name(), []byte("a in\nthe park\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ReloadStopWords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"walk"}
+	actual = terms(other.Filter(walkInThePark()))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v after reload, got %v", expected, actual)
+	}
+
+	_, err = StopTokensFilterConstructor(map[string]interface{}{
+		"stop_words_file": f.Name() + ".missing",
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for missing stop words file")
+	}
+}
+
+func TestStopWordsReader(t *testing.T) {
+	RegisterStopWordsReader("test_reader", func() (io.Reader, error) {
+		return strings.NewReader("walk\npark\n"), nil
+	})
+
+	cache := registry.NewCache()
+	filter, err := StopTokensFilterConstructor(map[string]interface{}{
+		"stop_words_reader": "test_reader",
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a", "in", "the"}
+	actual := terms(filter.Filter(walkInThePark()))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	_, err = StopTokensFilterConstructor(map[string]interface{}{
+		"stop_words_reader": "not_registered",
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for unregistered stop words reader")
+	}
+}
@@ -0,0 +1,178 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package synonym_filter
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "synonym"
+
+// SynonymFilter replaces sequences of tokens found in
+// its SynonymMap with their synonyms.  The n-th word of
+// a synonym is placed n positions after the first word
+// of the matched input, and the tokens after the match
+// are moved to follow the longest synonym, so multi-word
+// synonyms can still be found with phrase queries.  A
+// phrase going through a shorter synonym and the tokens
+// after it only matches with enough slop.  Synonym tokens
+// use the offsets of the entire matched input.
+type SynonymFilter struct {
+	synonyms *SynonymMap
+}
+
+func NewSynonymFilter(synonyms *SynonymMap) *SynonymFilter {
+	return &SynonymFilter{
+		synonyms: synonyms,
+	}
+}
+
+// SynonymMap returns the synonyms used by this filter,
+// so they can also be applied at query time
+func (f *SynonymFilter) SynonymMap() *SynonymMap {
+	return f.synonyms
+}
+
+func (f *SynonymFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input))
+
+	// the number of positions the tokens are moved by the
+	// synonyms before them
+	shift := 0
+	for i := 0; i < len(input); {
+		matched, outputs := f.longestMatch(input[i:])
+		if outputs == nil {
+			input[i].Position += shift
+			rv = append(rv, input[i])
+			i++
+			continue
+		}
+
+		firstPosition := matched[0].Position + shift
+		span := matched[len(matched)-1].Position - matched[0].Position + 1
+		start := matched[0].Start
+		end := matched[len(matched)-1].End
+
+		length := 0
+		for _, output := range outputs {
+			if sameWords(matched, output) {
+				if span > length {
+					length = span
+				}
+				for _, token := range matched {
+					token.Position += shift
+				}
+				rv = append(rv, matched...)
+				continue
+			}
+			if len(output) > length {
+				length = len(output)
+			}
+			for j, word := range output {
+				token := analysis.Token{
+					Term:     []byte(word),
+					Position: firstPosition + j,
+					Start:    start,
+					End:      end,
+					Type:     analysis.Synonym,
+				}
+				rv = append(rv, &token)
+			}
+		}
+		shift += length - span
+		i += len(matched)
+	}
+
+	return rv
+}
+
+func (f *SynonymFilter) longestMatch(input analysis.TokenStream) (analysis.TokenStream, [][]string) {
+	maxLength := f.synonyms.MaxLength()
+	if maxLength > len(input) {
+		maxLength = len(input)
+	}
+	for length := maxLength; length > 0; length-- {
+		words := make([]string, length)
+		for j := 0; j < length; j++ {
+			words[j] = string(input[j].Term)
+		}
+		outputs := f.synonyms.Lookup(words)
+		if outputs != nil {
+			return input[:length], outputs
+		}
+	}
+	return nil, nil
+}
+
+func sameWords(tokens analysis.TokenStream, words []string) bool {
+	if len(tokens) != len(words) {
+		return false
+	}
+	for i, token := range tokens {
+		if string(token.Term) != words[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SynonymMapFromConfig builds a SynonymMap from either
+// a "filename" or an inline list of "synonyms" rules.
+func SynonymMapFromConfig(config map[string]interface{}) (*SynonymMap, error) {
+	expand := true
+	expandVal, ok := config["expand"].(bool)
+	if ok {
+		expand = expandVal
+	}
+	format, _ := config["format"].(string)
+
+	rv := NewSynonymMap(expand)
+
+	// first: try to load by filename
+	filename, ok := config["filename"].(string)
+	if ok {
+		err := rv.LoadFile(filename, format)
+		if err != nil {
+			return nil, err
+		}
+		return rv, nil
+	}
+	// next: look for an inline list of rules
+	rules, ok := config["synonyms"].([]interface{})
+	if ok {
+		for _, rule := range rules {
+			ruleStr, ok := rule.(string)
+			if !ok {
+				return nil, fmt.Errorf("synonym rule must be a string")
+			}
+			err := rv.LoadBytes([]byte(ruleStr), format)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return rv, nil
+	}
+	return nil, fmt.Errorf("must specify filename or list of synonyms")
+}
+
+func SynonymFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	synonyms, err := SynonymMapFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error building synonym filter: %v", err)
+	}
+	return NewSynonymFilter(synonyms), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, SynonymFilterConstructor)
+}
@@ -0,0 +1,140 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package synonym_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestSynonymFilter(t *testing.T) {
+
+	synonyms := []interface{}{
+		"usa, united states of america",
+		"# a comment",
+		"i pod, i-pod => ipod",
+	}
+
+	tests := []struct {
+		input  analysis.TokenStream
+		output analysis.TokenStream
+	}{
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("visit"), Position: 1, Start: 0, End: 5},
+				&analysis.Token{Term: []byte("usa"), Position: 2, Start: 6, End: 9},
+				&analysis.Token{Term: []byte("today"), Position: 3, Start: 10, End: 15},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("visit"), Position: 1, Start: 0, End: 5},
+				&analysis.Token{Term: []byte("usa"), Position: 2, Start: 6, End: 9},
+				&analysis.Token{Term: []byte("united"), Position: 2, Start: 6, End: 9, Type: analysis.Synonym},
+				&analysis.Token{Term: []byte("states"), Position: 3, Start: 6, End: 9, Type: analysis.Synonym},
+				&analysis.Token{Term: []byte("of"), Position: 4, Start: 6, End: 9, Type: analysis.Synonym},
+				&analysis.Token{Term: []byte("america"), Position: 5, Start: 6, End: 9, Type: analysis.Synonym},
+				&analysis.Token{Term: []byte("today"), Position: 6, Start: 10, End: 15},
+			},
+		},
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("united"), Position: 1, Start: 0, End: 6},
+				&analysis.Token{Term: []byte("states"), Position: 2, Start: 7, End: 13},
+				&analysis.Token{Term: []byte("of"), Position: 3, Start: 14, End: 16},
+				&analysis.Token{Term: []byte("america"), Position: 4, Start: 17, End: 24},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("usa"), Position: 1, Start: 0, End: 24, Type: analysis.Synonym},
+				&analysis.Token{Term: []byte("united"), Position: 1, Start: 0, End: 6},
+				&analysis.Token{Term: []byte("states"), Position: 2, Start: 7, End: 13},
+				&analysis.Token{Term: []byte("of"), Position: 3, Start: 14, End: 16},
+				&analysis.Token{Term: []byte("america"), Position: 4, Start: 17, End: 24},
+			},
+		},
+		// explicit mappings replace the input
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("my"), Position: 1, Start: 0, End: 2},
+				&analysis.Token{Term: []byte("i"), Position: 2, Start: 3, End: 4},
+				&analysis.Token{Term: []byte("pod"), Position: 3, Start: 5, End: 8},
+				&analysis.Token{Term: []byte("rocks"), Position: 4, Start: 9, End: 14},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("my"), Position: 1, Start: 0, End: 2},
+				&analysis.Token{Term: []byte("ipod"), Position: 2, Start: 3, End: 8, Type: analysis.Synonym},
+				&analysis.Token{Term: []byte("rocks"), Position: 3, Start: 9, End: 14},
+			},
+		},
+		// partial matches are left alone
+		{
+			input: analysis.TokenStream{
+				&analysis.Token{Term: []byte("united"), Position: 1, Start: 0, End: 6},
+				&analysis.Token{Term: []byte("states"), Position: 2, Start: 7, End: 13},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("united"), Position: 1, Start: 0, End: 6},
+				&analysis.Token{Term: []byte("states"), Position: 2, Start: 7, End: 13},
+			},
+		},
+	}
+
+	cache := registry.NewCache()
+	synonymFilter, err := SynonymFilterConstructor(map[string]interface{}{
+		"synonyms": synonyms,
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		actual := synonymFilter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %s, got %s", test.output, actual)
+		}
+	}
+}
+
+func TestSynonymMapNoExpand(t *testing.T) {
+	synonyms := NewSynonymMap(false)
+	err := synonyms.LoadBytes([]byte("tv, television, telly\n"), "solr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{{"tv"}}
+	actual := synonyms.Lookup([]string{"telly"})
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestSynonymMapWordNet(t *testing.T) {
+	synonyms := NewSynonymMap(true)
+	err := synonyms.LoadBytes([]byte(`s(100000001,1,'woods',n,1,0).
+s(100000001,2,'wood',n,1,0).
+s(100000001,3,'forest',n,1,0).
+s(100000002,1,'king''s evil',n,1,0).
+s(100000002,2,'scrofula',n,1,0).
+`), "wordnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{{"woods"}, {"wood"}, {"forest"}}
+	actual := synonyms.Lookup([]string{"wood"})
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	expected = [][]string{{"king's", "evil"}, {"scrofula"}}
+	actual = synonyms.Lookup([]string{"scrofula"})
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,203 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package synonym_filter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// A SynonymMap maps sequences of one or more words
+// to the sequences which should be emitted in their
+// place.  An input is only kept if it is also listed
+// among its own outputs.
+type SynonymMap struct {
+	expand    bool
+	synonyms  map[string][][]string
+	maxLength int
+}
+
+// NewSynonymMap creates an empty SynonymMap.  When expand
+// is true, lists of equivalent synonyms map every entry to
+// every other entry, otherwise all entries are replaced by
+// the first one in the list.
+func NewSynonymMap(expand bool) *SynonymMap {
+	return &SynonymMap{
+		expand:   expand,
+		synonyms: make(map[string][][]string),
+	}
+}
+
+func (s *SynonymMap) LoadFile(filename string, format string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return s.LoadBytes(data, format)
+}
+
+// LoadBytes loads synonym rules in either the "solr"
+// (the default) or the "wordnet" prolog format.
+func (s *SynonymMap) LoadBytes(data []byte, format string) error {
+	switch format {
+	case "", "solr":
+		return s.loadSolr(data)
+	case "wordnet":
+		return s.loadWordNet(data)
+	}
+	return fmt.Errorf("unknown synonym format '%s'", format)
+}
+
+func (s *SynonymMap) loadSolr(data []byte) error {
+	bufioReader := bufio.NewReader(bytes.NewReader(data))
+	line, err := bufioReader.ReadString('\n')
+	for err == nil {
+		lerr := s.LoadLine(line)
+		if lerr != nil {
+			return lerr
+		}
+		line, err = bufioReader.ReadString('\n')
+	}
+	// if the err was EOF we still need to process the last value
+	if err == io.EOF {
+		return s.LoadLine(line)
+	}
+	return err
+}
+
+// LoadLine parses a single rule in the solr format,
+// either a comma separated list of equivalent
+// synonyms "usa, united states of america" or
+// an explicit mapping "i-pod, i pod => ipod".
+func (s *SynonymMap) LoadLine(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	sides := strings.Split(line, "=>")
+	switch len(sides) {
+	case 1:
+		words := parseSynonymList(sides[0])
+		if len(words) == 0 {
+			return nil
+		}
+		if s.expand {
+			s.AddEquivalent(words)
+		} else {
+			s.AddMapping(words, words[:1])
+		}
+	case 2:
+		inputs := parseSynonymList(sides[0])
+		outputs := parseSynonymList(sides[1])
+		if len(inputs) == 0 || len(outputs) == 0 {
+			return fmt.Errorf("invalid synonym rule '%s'", line)
+		}
+		s.AddMapping(inputs, outputs)
+	default:
+		return fmt.Errorf("invalid synonym rule '%s'", line)
+	}
+	return nil
+}
+
+// loadWordNet groups the terms of consecutive
+// s(synset_id,w_num,'word',ss_type,sense_number,tag_count).
+// lines sharing a synset id into a list of equivalent
+// synonyms
+func (s *SynonymMap) loadWordNet(data []byte) error {
+	var lastSynset string
+	var words [][]string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "s(") {
+			return fmt.Errorf("invalid wordnet line '%s'", line)
+		}
+		synset := line[2:]
+		comma := strings.Index(synset, ",")
+		quoteStart := strings.Index(line, "'")
+		quoteEnd := strings.LastIndex(line, "'")
+		if comma < 0 || quoteStart < 0 || quoteEnd <= quoteStart {
+			return fmt.Errorf("invalid wordnet line '%s'", line)
+		}
+		synset = synset[:comma]
+		word := strings.Replace(line[quoteStart+1:quoteEnd], "''", "'", -1)
+		if synset != lastSynset && len(words) > 0 {
+			s.addWordNetSynset(words)
+			words = nil
+		}
+		lastSynset = synset
+		words = append(words, strings.Fields(word))
+	}
+	if len(words) > 0 {
+		s.addWordNetSynset(words)
+	}
+	return nil
+}
+
+func (s *SynonymMap) addWordNetSynset(words [][]string) {
+	if s.expand {
+		s.AddEquivalent(words)
+	} else {
+		s.AddMapping(words, words[:1])
+	}
+}
+
+func parseSynonymList(list string) [][]string {
+	rv := make([][]string, 0)
+	for _, entry := range strings.Split(list, ",") {
+		words := strings.Fields(entry)
+		if len(words) > 0 {
+			rv = append(rv, words)
+		}
+	}
+	return rv
+}
+
+// AddEquivalent maps each entry to all of the entries
+func (s *SynonymMap) AddEquivalent(entries [][]string) {
+	s.AddMapping(entries, entries)
+}
+
+// AddMapping maps each of the inputs to all of the outputs
+func (s *SynonymMap) AddMapping(inputs, outputs [][]string) {
+	for _, input := range inputs {
+		key := strings.Join(input, " ")
+	OUTPUTS:
+		for _, output := range outputs {
+			for _, existing := range s.synonyms[key] {
+				if strings.Join(existing, " ") == strings.Join(output, " ") {
+					continue OUTPUTS
+				}
+			}
+			s.synonyms[key] = append(s.synonyms[key], output)
+		}
+		if len(input) > s.maxLength {
+			s.maxLength = len(input)
+		}
+	}
+}
+
+// Lookup returns the outputs for the given input, or
+// nil if the input has no synonyms
+func (s *SynonymMap) Lookup(words []string) [][]string {
+	return s.synonyms[strings.Join(words, " ")]
+}
+
+// MaxLength is the number of words in the longest input
+func (s *SynonymMap) MaxLength() int {
+	return s.maxLength
+}
@@ -53,6 +53,9 @@ func TruncateTokenFilterConstructor(config map[string]interface{}, cache *regist
 		return nil, fmt.Errorf("must specify length")
 	}
 	length := int(lenVal)
+	if length < 1 {
+		return nil, fmt.Errorf("length must be positive")
+	}
 
 	return NewTruncateTokenFilter(length), nil
 }
@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
 )
 
 func TestTruncateTokenFilter(t *testing.T) {
@@ -72,3 +73,13 @@ func TestTruncateTokenFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestTruncateTokenFilterConstructor(t *testing.T) {
+	cache := registry.NewCache()
+	_, err := TruncateTokenFilterConstructor(map[string]interface{}{
+		"length": 0.0,
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for zero length")
+	}
+}
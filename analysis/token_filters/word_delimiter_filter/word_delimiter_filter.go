@@ -0,0 +1,270 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package word_delimiter_filter
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "word_delimiter"
+
+// flags controlling which tokens the filter produces
+const (
+	GenerateWordParts = 1 << iota
+	GenerateNumberParts
+	CatenateWords
+	CatenateNumbers
+	CatenateAll
+	PreserveOriginal
+	SplitOnCaseChange
+	SplitOnNumerics
+	StemEnglishPossessive
+)
+
+const DefaultFlags = GenerateWordParts | GenerateNumberParts | SplitOnCaseChange |
+	SplitOnNumerics | StemEnglishPossessive
+
+var flagNames = map[string]int{
+	"generate_word_parts":     GenerateWordParts,
+	"generate_number_parts":   GenerateNumberParts,
+	"catenate_words":          CatenateWords,
+	"catenate_numbers":        CatenateNumbers,
+	"catenate_all":            CatenateAll,
+	"preserve_original":       PreserveOriginal,
+	"split_on_case_change":    SplitOnCaseChange,
+	"split_on_numerics":       SplitOnNumerics,
+	"stem_english_possessive": StemEnglishPossessive,
+}
+
+// WordDelimiterFilter splits tokens into subwords on
+// intra-word delimiters, case transitions and letter
+// digit transitions, "Wi-Fi" becomes "Wi" and "Fi", and
+// with CatenateWords also "WiFi".  Subwords occupy
+// consecutive positions, catenated tokens and the
+// preserved original are placed at the position of
+// their first subword, and the following tokens are
+// shifted so that phrase queries still line up.
+type WordDelimiterFilter struct {
+	flags int
+}
+
+func NewWordDelimiterFilter(flags int) *WordDelimiterFilter {
+	return &WordDelimiterFilter{
+		flags: flags,
+	}
+}
+
+func (f *WordDelimiterFilter) has(flag int) bool {
+	return f.flags&flag != 0
+}
+
+type subword struct {
+	start   int
+	end     int
+	numeric bool
+}
+
+func (f *WordDelimiterFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0, len(input))
+
+	shift := 0
+	for _, token := range input {
+		token.Position += shift
+		if token.KeyWord {
+			rv = append(rv, token)
+			continue
+		}
+
+		subwords := f.split(token.Term)
+		if len(subwords) == 1 && subwords[0].start == 0 && subwords[0].end == len(token.Term) {
+			// nothing to split
+			rv = append(rv, token)
+			continue
+		}
+
+		if f.has(PreserveOriginal) {
+			rv = append(rv, token)
+		}
+		if len(subwords) == 0 {
+			continue
+		}
+
+		// only use the offsets inside the term when
+		// they are known to match the original input
+		exactOffsets := token.End-token.Start == len(token.Term)
+
+		// parts are given consecutive positions, a subword
+		// which is not output shares the position of the
+		// next part
+		positions := make([]int, len(subwords))
+		parts := 0
+		for i, sw := range subwords {
+			positions[i] = token.Position + parts
+			if f.generates(sw) {
+				parts++
+			}
+		}
+		lastPosition := token.Position
+		if parts > 0 {
+			lastPosition += parts - 1
+		}
+
+		subwordToken := func(start, end int, position int, typ analysis.TokenType) *analysis.Token {
+			term := make([]byte, 0, end-start)
+			for i := range subwords {
+				sw := subwords[i]
+				if sw.start >= start && sw.end <= end {
+					term = append(term, token.Term[sw.start:sw.end]...)
+				}
+			}
+			if position > lastPosition {
+				position = lastPosition
+			}
+			newToken := analysis.Token{
+				Term:     term,
+				Position: position,
+				Start:    token.Start,
+				End:      token.End,
+				Type:     typ,
+			}
+			if exactOffsets {
+				newToken.Start = token.Start + start
+				newToken.End = token.Start + end
+			}
+			return &newToken
+		}
+
+		for i, sw := range subwords {
+			typ := analysis.AlphaNumeric
+			if sw.numeric {
+				typ = analysis.Numeric
+			}
+			if f.generates(sw) {
+				rv = append(rv, subwordToken(sw.start, sw.end, positions[i], typ))
+			}
+
+			// catenations are emitted after the first part they cover
+			if f.has(CatenateAll) {
+				if i == 0 && len(subwords) > 1 {
+					rv = append(rv, subwordToken(sw.start, subwords[len(subwords)-1].end, positions[i], token.Type))
+				}
+			}
+			if (!sw.numeric && f.has(CatenateWords)) || (sw.numeric && f.has(CatenateNumbers)) {
+				if i == 0 || subwords[i-1].numeric != sw.numeric {
+					j := i
+					for j+1 < len(subwords) && subwords[j+1].numeric == sw.numeric {
+						j++
+					}
+					if j > i && (!f.has(CatenateAll) || i != 0 || j != len(subwords)-1) {
+						rv = append(rv, subwordToken(sw.start, subwords[j].end, positions[i], typ))
+					}
+				}
+			}
+		}
+
+		if parts > 1 {
+			shift += parts - 1
+		}
+	}
+
+	return rv
+}
+
+func (f *WordDelimiterFilter) generates(sw subword) bool {
+	if sw.numeric {
+		return f.has(GenerateNumberParts)
+	}
+	return f.has(GenerateWordParts)
+}
+
+// split returns the subwords of the term, delimiters
+// (anything which is not a letter or a digit) are not
+// part of any subword
+func (f *WordDelimiterFilter) split(term []byte) []subword {
+	end := len(term)
+	if f.has(StemEnglishPossessive) && end >= 2 &&
+		(term[end-1] == 's' || term[end-1] == 'S') {
+		r, size := utf8.DecodeLastRune(term[:end-1])
+		if r == '\'' || r == '’' {
+			end -= 1 + size
+		}
+	}
+
+	rv := make([]subword, 0)
+	start := -1
+	var last rune
+	for i := 0; i < end; {
+		r, size := utf8.DecodeRune(term[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				rv = append(rv, subword{start: start, end: i, numeric: unicode.IsDigit(last)})
+				start = -1
+			}
+			i += size
+			continue
+		}
+		if start >= 0 {
+			caseChange := f.has(SplitOnCaseChange) && unicode.IsLower(last) && unicode.IsUpper(r)
+			numericChange := f.has(SplitOnNumerics) && unicode.IsDigit(last) != unicode.IsDigit(r)
+			if caseChange || numericChange {
+				rv = append(rv, subword{start: start, end: i, numeric: unicode.IsDigit(last)})
+				start = i
+			}
+		} else {
+			start = i
+		}
+		last = r
+		i += size
+	}
+	if start >= 0 {
+		rv = append(rv, subword{start: start, end: end, numeric: unicode.IsDigit(last)})
+	}
+
+	// without splitting on numerics a mixed subword is
+	// only numeric if it consists of digits alone
+	if !f.has(SplitOnNumerics) {
+		for i := range rv {
+			rv[i].numeric = isNumeric(term[rv[i].start:rv[i].end])
+		}
+	}
+	return rv
+}
+
+func isNumeric(term []byte) bool {
+	for _, r := range string(term) {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func WordDelimiterFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	flags := DefaultFlags
+	for name, flag := range flagNames {
+		val, ok := config[name].(bool)
+		if ok {
+			if val {
+				flags |= flag
+			} else {
+				flags &^= flag
+			}
+		}
+	}
+	return NewWordDelimiterFilter(flags), nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(Name, WordDelimiterFilterConstructor)
+}
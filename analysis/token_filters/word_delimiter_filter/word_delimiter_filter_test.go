@@ -0,0 +1,216 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package word_delimiter_filter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+func TestWordDelimiterFilter(t *testing.T) {
+
+	tests := []struct {
+		flags  int
+		input  analysis.TokenStream
+		output analysis.TokenStream
+	}{
+		// default splitting, following tokens are shifted
+		{
+			flags: DefaultFlags,
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("Wi-Fi"),
+					Position: 1,
+					Start:    0,
+					End:      5,
+				},
+				&analysis.Token{
+					Term:     []byte("router"),
+					Position: 2,
+					Start:    6,
+					End:      12,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("Wi"),
+					Position: 1,
+					Start:    0,
+					End:      2,
+				},
+				&analysis.Token{
+					Term:     []byte("Fi"),
+					Position: 2,
+					Start:    3,
+					End:      5,
+				},
+				&analysis.Token{
+					Term:     []byte("router"),
+					Position: 3,
+					Start:    6,
+					End:      12,
+				},
+			},
+		},
+		// catenate words
+		{
+			flags: DefaultFlags | CatenateWords,
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("Wi-Fi"),
+					Position: 1,
+					Start:    0,
+					End:      5,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("Wi"),
+					Position: 1,
+					Start:    0,
+					End:      2,
+				},
+				&analysis.Token{
+					Term:     []byte("WiFi"),
+					Position: 1,
+					Start:    0,
+					End:      5,
+				},
+				&analysis.Token{
+					Term:     []byte("Fi"),
+					Position: 2,
+					Start:    3,
+					End:      5,
+				},
+			},
+		},
+		// case changes, numerics and catenations
+		{
+			flags: DefaultFlags | CatenateNumbers | CatenateAll | PreserveOriginal,
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("PowerShot500-10"),
+					Position: 1,
+					Start:    0,
+					End:      15,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("PowerShot500-10"),
+					Position: 1,
+					Start:    0,
+					End:      15,
+				},
+				&analysis.Token{
+					Term:     []byte("Power"),
+					Position: 1,
+					Start:    0,
+					End:      5,
+				},
+				&analysis.Token{
+					Term:     []byte("PowerShot50010"),
+					Position: 1,
+					Start:    0,
+					End:      15,
+				},
+				&analysis.Token{
+					Term:     []byte("Shot"),
+					Position: 2,
+					Start:    5,
+					End:      9,
+				},
+				&analysis.Token{
+					Term:     []byte("500"),
+					Position: 3,
+					Start:    9,
+					End:      12,
+					Type:     analysis.Numeric,
+				},
+				&analysis.Token{
+					Term:     []byte("50010"),
+					Position: 3,
+					Start:    9,
+					End:      15,
+					Type:     analysis.Numeric,
+				},
+				&analysis.Token{
+					Term:     []byte("10"),
+					Position: 4,
+					Start:    13,
+					End:      15,
+					Type:     analysis.Numeric,
+				},
+			},
+		},
+		// possessives, keywords and unsplit tokens
+		{
+			flags: DefaultFlags,
+			input: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("O'Neil's"),
+					Position: 1,
+					Start:    0,
+					End:      8,
+				},
+				&analysis.Token{
+					Term:     []byte("AT&T"),
+					Position: 2,
+					Start:    9,
+					End:      13,
+					KeyWord:  true,
+				},
+				&analysis.Token{
+					Term:     []byte("pub"),
+					Position: 3,
+					Start:    14,
+					End:      17,
+				},
+			},
+			output: analysis.TokenStream{
+				&analysis.Token{
+					Term:     []byte("O"),
+					Position: 1,
+					Start:    0,
+					End:      1,
+				},
+				&analysis.Token{
+					Term:     []byte("Neil"),
+					Position: 2,
+					Start:    2,
+					End:      6,
+				},
+				&analysis.Token{
+					Term:     []byte("AT&T"),
+					Position: 3,
+					Start:    9,
+					End:      13,
+					KeyWord:  true,
+				},
+				&analysis.Token{
+					Term:     []byte("pub"),
+					Position: 4,
+					Start:    14,
+					End:      17,
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		filter := NewWordDelimiterFilter(test.flags)
+		actual := filter.Filter(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v", test.output, actual)
+		}
+	}
+}
@@ -32,8 +32,13 @@ func (t TokenMap) LoadFile(filename string) error {
 }
 
 func (t TokenMap) LoadBytes(data []byte) error {
-	bytesReader := bytes.NewReader(data)
-	bufioReader := bufio.NewReader(bytesReader)
+	return t.LoadReader(bytes.NewReader(data))
+}
+
+// LoadReader adds the tokens read from r, in the same
+// format accepted by LoadFile
+func (t TokenMap) LoadReader(r io.Reader) error {
+	bufioReader := bufio.NewReader(r)
 	line, err := bufioReader.ReadString('\n')
 	for err == nil {
 		t.LoadLine(line)
@@ -0,0 +1,170 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package char_group_tokenizer
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "char_group"
+
+const DefaultMaxTokenLength = 255
+
+// the character classes which can be used in place of
+// single characters
+var classes = map[string]*unicode.RangeTable{
+	"whitespace":  unicode.White_Space,
+	"letter":      unicode.Letter,
+	"digit":       unicode.Digit,
+	"punctuation": unicode.Punct,
+	"symbol":      unicode.Symbol,
+}
+
+// escapes for characters which are awkward to write in
+// the config
+var escapes = map[string]rune{
+	`\n`: '\n',
+	`\r`: '\r',
+	`\t`: '\t',
+	`\f`: '\f',
+	`\v`: '\v',
+	`\\`: '\\',
+}
+
+// CharGroupTokenizer splits the input on any of a set of
+// characters and character classes, without the cost of a
+// regular expression.  Tokens longer than maxTokenLength
+// runes are split at that length.
+type CharGroupTokenizer struct {
+	chars          map[rune]bool
+	classes        []*unicode.RangeTable
+	maxTokenLength int
+}
+
+func NewCharGroupTokenizer(chars []rune, classes []*unicode.RangeTable, maxTokenLength int) *CharGroupTokenizer {
+	rv := CharGroupTokenizer{
+		chars:          make(map[rune]bool, len(chars)),
+		classes:        classes,
+		maxTokenLength: maxTokenLength,
+	}
+	for _, r := range chars {
+		rv.chars[r] = true
+	}
+	return &rv
+}
+
+func (t *CharGroupTokenizer) isSplit(r rune) bool {
+	if t.chars[r] {
+		return true
+	}
+	for _, class := range t.classes {
+		if unicode.Is(class, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *CharGroupTokenizer) Tokenize(input []byte) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0)
+
+	start := -1
+	runes := 0
+	emit := func(end int) {
+		term := input[start:end]
+		token := analysis.Token{
+			Term:     term,
+			Start:    start,
+			End:      end,
+			Position: len(rv) + 1,
+			Type:     detectTokenType(term),
+		}
+		rv = append(rv, &token)
+		start = -1
+		runes = 0
+	}
+	for i := 0; i < len(input); {
+		r, size := utf8.DecodeRune(input[i:])
+		if t.isSplit(r) {
+			if start >= 0 {
+				emit(i)
+			}
+		} else {
+			if start >= 0 && runes == t.maxTokenLength {
+				emit(i)
+			}
+			if start < 0 {
+				start = i
+			}
+			runes++
+		}
+		i += size
+	}
+	if start >= 0 {
+		emit(len(input))
+	}
+	return rv
+}
+
+func detectTokenType(termBytes []byte) analysis.TokenType {
+	_, err := strconv.ParseFloat(string(termBytes), 64)
+	if err == nil {
+		return analysis.Numeric
+	}
+	return analysis.AlphaNumeric
+}
+
+func CharGroupTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+	tokenizeOn, ok := config["tokenize_on_chars"].([]interface{})
+	if !ok || len(tokenizeOn) == 0 {
+		return nil, fmt.Errorf("must specify tokenize_on_chars")
+	}
+	var chars []rune
+	var rangeTables []*unicode.RangeTable
+	for _, item := range tokenizeOn {
+		itemStr, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("tokenize_on_chars entries must be strings")
+		}
+		if class, ok := classes[itemStr]; ok {
+			rangeTables = append(rangeTables, class)
+			continue
+		}
+		if r, ok := escapes[itemStr]; ok {
+			chars = append(chars, r)
+			continue
+		}
+		if utf8.RuneCountInString(itemStr) != 1 {
+			return nil, fmt.Errorf("tokenize_on_chars entry '%s' is neither a single character nor a character class", itemStr)
+		}
+		r, _ := utf8.DecodeRuneInString(itemStr)
+		chars = append(chars, r)
+	}
+
+	maxTokenLength := DefaultMaxTokenLength
+	maxVal, ok := config["max_token_length"].(float64)
+	if ok {
+		maxTokenLength = int(maxVal)
+	}
+	if maxTokenLength < 1 {
+		return nil, fmt.Errorf("max_token_length must be at least 1")
+	}
+	return NewCharGroupTokenizer(chars, rangeTables, maxTokenLength), nil
+}
+
+func init() {
+	registry.RegisterTokenizer(Name, CharGroupTokenizerConstructor)
+}
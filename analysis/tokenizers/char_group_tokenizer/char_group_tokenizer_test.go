@@ -0,0 +1,108 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package char_group_tokenizer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestCharGroupTokenizer(t *testing.T) {
+	tests := []struct {
+		config map[string]interface{}
+		input  []byte
+		output analysis.TokenStream
+	}{
+		{
+			config: map[string]interface{}{
+				"tokenize_on_chars": []interface{}{"whitespace", ",", `\n`},
+			},
+			input: []byte("2015-01-02 GET,/index.html\n200"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("2015-01-02"), Start: 0, End: 10, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("GET"), Start: 11, End: 14, Position: 2, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("/index.html"), Start: 15, End: 26, Position: 3, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("200"), Start: 27, End: 30, Position: 4, Type: analysis.Numeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"tokenize_on_chars": []interface{}{"punctuation", "symbol"},
+			},
+			input: []byte("a+b=ç;;d"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("a"), Start: 0, End: 1, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("b"), Start: 2, End: 3, Position: 2, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("ç"), Start: 4, End: 6, Position: 3, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("d"), Start: 8, End: 9, Position: 4, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"tokenize_on_chars": []interface{}{"-"},
+				"max_token_length":  3.0,
+			},
+			input: []byte("abcdefg-hi"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("abc"), Start: 0, End: 3, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("def"), Start: 3, End: 6, Position: 2, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("g"), Start: 6, End: 7, Position: 3, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("hi"), Start: 8, End: 10, Position: 4, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"tokenize_on_chars": []interface{}{"whitespace"},
+			},
+			input:  []byte("  "),
+			output: analysis.TokenStream{},
+		},
+	}
+
+	cache := registry.NewCache()
+	for _, test := range tests {
+		tokenizer, err := CharGroupTokenizerConstructor(test.config, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := tokenizer.Tokenize(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v for %s", test.output, actual, string(test.input))
+		}
+	}
+}
+
+func TestCharGroupTokenizerInvalidConfig(t *testing.T) {
+	configs := []map[string]interface{}{
+		{},
+		{"tokenize_on_chars": []interface{}{"ab"}},
+		{"tokenize_on_chars": []interface{}{"-"}, "max_token_length": 0.0},
+	}
+
+	cache := registry.NewCache()
+	for _, config := range configs {
+		_, err := CharGroupTokenizerConstructor(config, cache)
+		if err == nil {
+			t.Errorf("expected error for config %v", config)
+		}
+	}
+}
+
+func BenchmarkCharGroupTokenizer(b *testing.B) {
+	tokenizer := NewCharGroupTokenizer([]rune{',', ' '}, nil, DefaultMaxTokenLength)
+	input := []byte("2015-01-02,GET,/index.html,200,1024,Mozilla/5.0 (X11; Linux x86_64)")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokenizer.Tokenize(input)
+	}
+}
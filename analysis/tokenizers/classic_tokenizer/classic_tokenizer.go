@@ -0,0 +1,147 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package classic_tokenizer
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "classic"
+
+const DefaultMaxTokenLength = 255
+
+// the grammar follows the lucene ClassicTokenizer, it
+// was the StandardTokenizer before lucene 3.1
+
+// chinese and japanese characters, each is a token
+const cj = `\x{3100}-\x{312f}\x{3040}-\x{309f}\x{30a0}-\x{30ff}\x{31f0}-\x{31ff}` +
+	`\x{3300}-\x{337f}\x{3400}-\x{4dbf}\x{4e00}-\x{9fff}\x{f900}-\x{faff}\x{ff65}-\x{ff9f}`
+
+const (
+	letter     = `[^\P{L}` + cj + `]`
+	alphaNum   = `(?:` + letter + `|\p{Nd})+`
+	hasDigit   = `(?:` + letter + `|\p{Nd})*\p{Nd}(?:` + letter + `|\p{Nd})*`
+	alpha      = letter + `+`
+	punct      = `[_\-/.,]`
+	apostrophe = alpha + `(?:'` + alpha + `)+`
+	acronym    = letter + `\.(?:` + letter + `\.)+`
+	acronymDep = alphaNum + `\.(?:` + alphaNum + `\.)+`
+	company    = alpha + `[&@]` + alpha
+	email      = alphaNum + `(?:[._\-]` + alphaNum + `)*@` + alphaNum + `(?:[.\-]` + alphaNum + `)+`
+	host       = alphaNum + `(?:\.` + alphaNum + `)+`
+	num        = `(?:` + alphaNum + punct + hasDigit +
+		`|` + hasDigit + punct + alphaNum +
+		`|` + alphaNum + `(?:` + punct + hasDigit + punct + alphaNum + `)+` +
+		`|` + hasDigit + `(?:` + punct + alphaNum + punct + hasDigit + `)+` +
+		`|` + alphaNum + punct + hasDigit + `(?:` + punct + alphaNum + punct + hasDigit + `)+` +
+		`|` + hasDigit + punct + alphaNum + `(?:` + punct + hasDigit + punct + alphaNum + `)+)`
+	cjChar = `[` + cj + `]`
+)
+
+// the rules in order of precedence, when several match
+// the longest token the first one gives its type.
+// Acronyms containing digits, such as "u.s.a2.", are
+// treated as hosts, like lucene does.
+var rules = []struct {
+	pattern   string
+	tokenType analysis.TokenType
+}{
+	{alphaNum, analysis.AlphaNumeric},
+	{apostrophe, analysis.AlphaNumeric},
+	{acronym, analysis.AlphaNumeric},
+	{company, analysis.AlphaNumeric},
+	{email, analysis.Email},
+	{host, analysis.Host},
+	{num, analysis.Numeric},
+	{cjChar, analysis.Ideographic},
+	{acronymDep, analysis.Host},
+}
+
+var classicRegexp *regexp.Regexp
+var ruleRegexps []*regexp.Regexp
+
+func init() {
+	pattern := ""
+	for i, rule := range rules {
+		if i > 0 {
+			pattern += "|"
+		}
+		pattern += "(?:" + rule.pattern + ")"
+		ruleRegexps = append(ruleRegexps, regexp.MustCompile(`^(?:`+rule.pattern+`)$`))
+	}
+	classicRegexp = regexp.MustCompile(pattern)
+	classicRegexp.Longest()
+}
+
+// ClassicTokenizer keeps acronyms, company names such as
+// "AT&T", email addresses and host names as single tokens.
+// Tokens longer than maxTokenLength runes are skipped.
+type ClassicTokenizer struct {
+	maxTokenLength int
+}
+
+func NewClassicTokenizer(maxTokenLength int) *ClassicTokenizer {
+	return &ClassicTokenizer{
+		maxTokenLength: maxTokenLength,
+	}
+}
+
+func (t *ClassicTokenizer) Tokenize(input []byte) analysis.TokenStream {
+	matches := classicRegexp.FindAllIndex(input, -1)
+	rv := make(analysis.TokenStream, 0, len(matches))
+	pos := 1
+	for _, match := range matches {
+		term := input[match[0]:match[1]]
+		if utf8.RuneCount(term) > t.maxTokenLength {
+			pos++
+			continue
+		}
+		token := analysis.Token{
+			Term:     term,
+			Start:    match[0],
+			End:      match[1],
+			Position: pos,
+			Type:     detectTokenType(term),
+		}
+		rv = append(rv, &token)
+		pos++
+	}
+	return rv
+}
+
+func detectTokenType(term []byte) analysis.TokenType {
+	for i, r := range ruleRegexps {
+		if r.Match(term) {
+			return rules[i].tokenType
+		}
+	}
+	return analysis.AlphaNumeric
+}
+
+func ClassicTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+	maxTokenLength := DefaultMaxTokenLength
+	maxVal, ok := config["max_token_length"].(float64)
+	if ok {
+		maxTokenLength = int(maxVal)
+	}
+	if maxTokenLength < 1 {
+		return nil, fmt.Errorf("max_token_length must be at least 1")
+	}
+	return NewClassicTokenizer(maxTokenLength), nil
+}
+
+func init() {
+	registry.RegisterTokenizer(Name, ClassicTokenizerConstructor)
+}
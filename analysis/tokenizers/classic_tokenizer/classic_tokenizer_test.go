@@ -0,0 +1,82 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package classic_tokenizer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+func TestClassicTokenizer(t *testing.T) {
+	tests := []struct {
+		input  []byte
+		output analysis.TokenStream
+	}{
+		{
+			input: []byte("AT&T and I.B.M. at www.example.com"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("AT&T"), Start: 0, End: 4, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("and"), Start: 5, End: 8, Position: 2, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("I.B.M."), Start: 9, End: 15, Position: 3, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("at"), Start: 16, End: 18, Position: 4, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("www.example.com"), Start: 19, End: 34, Position: 5, Type: analysis.Host},
+			},
+		},
+		{
+			input: []byte("mail test.user@example.co.uk O'Reilly's"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("mail"), Start: 0, End: 4, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("test.user@example.co.uk"), Start: 5, End: 28, Position: 2, Type: analysis.Email},
+				&analysis.Token{Term: []byte("O'Reilly's"), Start: 29, End: 39, Position: 3, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			input: []byte("3-4 T1/E2 wi-fi 2015/01/02 U.S.A2."),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("3-4"), Start: 0, End: 3, Position: 1, Type: analysis.Numeric},
+				&analysis.Token{Term: []byte("T1/E2"), Start: 4, End: 9, Position: 2, Type: analysis.Numeric},
+				&analysis.Token{Term: []byte("wi"), Start: 10, End: 12, Position: 3, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("fi"), Start: 13, End: 15, Position: 4, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("2015/01/02"), Start: 16, End: 26, Position: 5, Type: analysis.Numeric},
+				&analysis.Token{Term: []byte("U.S.A2."), Start: 27, End: 34, Position: 6, Type: analysis.Host},
+			},
+		},
+		{
+			input: []byte("中国 Hello"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("中"), Start: 0, End: 3, Position: 1, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("国"), Start: 3, End: 6, Position: 2, Type: analysis.Ideographic},
+				&analysis.Token{Term: []byte("Hello"), Start: 7, End: 12, Position: 3, Type: analysis.AlphaNumeric},
+			},
+		},
+	}
+
+	tokenizer := NewClassicTokenizer(DefaultMaxTokenLength)
+	for _, test := range tests {
+		actual := tokenizer.Tokenize(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v for %s", test.output, actual, string(test.input))
+		}
+	}
+}
+
+func TestClassicTokenizerMaxTokenLength(t *testing.T) {
+	tokenizer := NewClassicTokenizer(4)
+	expected := analysis.TokenStream{
+		&analysis.Token{Term: []byte("a"), Start: 0, End: 1, Position: 1, Type: analysis.AlphaNumeric},
+		&analysis.Token{Term: []byte("word"), Start: 10, End: 14, Position: 3, Type: analysis.AlphaNumeric},
+	}
+	actual := tokenizer.Tokenize([]byte("a toolong word"))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,125 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build icu full
+
+package icu
+
+// #cgo LDFLAGS: -licuuc -licudata
+// #include <stdlib.h>
+// #include "unicode/utypes.h"
+// #include "unicode/ubrk.h"
+import "C"
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "icu"
+
+// UnicodeWordBoundaryTokenizer splits text on the word
+// boundaries found by the ICU break iterator.  Unlike the
+// unicode tokenizer, ICU uses dictionaries to segment
+// scripts without spaces, such as Thai, Lao, Khmer and
+// Burmese, so mixed-script text is handled correctly.
+type UnicodeWordBoundaryTokenizer struct {
+	locale *C.char
+}
+
+func NewUnicodeWordBoundaryTokenizer() *UnicodeWordBoundaryTokenizer {
+	return &UnicodeWordBoundaryTokenizer{}
+}
+
+func NewUnicodeWordBoundaryCustomLocaleTokenizer(locale string) *UnicodeWordBoundaryTokenizer {
+	return &UnicodeWordBoundaryTokenizer{
+		locale: C.CString(locale),
+	}
+}
+
+func (t *UnicodeWordBoundaryTokenizer) Tokenize(input []byte) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0)
+
+	if len(input) < 1 {
+		return rv
+	}
+
+	// ICU works on UTF-16, remember the byte offset of
+	// every code unit so boundaries can be mapped back
+	units := make([]uint16, 0, len(input)+1)
+	offsets := make([]int, 0, len(input)+1)
+	for i := 0; i < len(input); {
+		r, size := utf8.DecodeRune(input[i:])
+		if r >= 0x10000 {
+			r1, r2 := utf16.EncodeRune(r)
+			units = append(units, uint16(r1), uint16(r2))
+			offsets = append(offsets, i, i)
+		} else {
+			units = append(units, uint16(r))
+			offsets = append(offsets, i)
+		}
+		i += size
+	}
+	offsets = append(offsets, len(input))
+
+	var err C.UErrorCode = C.U_ZERO_ERROR
+	bi := C.ubrk_open(C.UBRK_WORD, t.locale, (*C.UChar)(unsafe.Pointer(&units[0])), C.int32_t(len(units)), &err)
+	if err > C.U_ZERO_ERROR {
+		return rv
+	}
+	defer C.ubrk_close(bi)
+
+	position := 1
+	prev := C.ubrk_first(bi)
+	for p := C.ubrk_next(bi); p != C.UBRK_DONE; p = C.ubrk_next(bi) {
+		status := C.ubrk_getRuleStatus(bi)
+		if status >= C.UBRK_WORD_NONE_LIMIT {
+			start := offsets[prev]
+			end := offsets[p]
+			token := analysis.Token{
+				Term:     input[start:end],
+				Start:    start,
+				End:      end,
+				Position: position,
+				Type:     convertType(status),
+			}
+			rv = append(rv, &token)
+			position++
+		}
+		prev = p
+	}
+
+	return rv
+}
+
+func convertType(status C.int32_t) analysis.TokenType {
+	switch {
+	case status < C.UBRK_WORD_NUMBER_LIMIT:
+		return analysis.Numeric
+	case status >= C.UBRK_WORD_KANA && status < C.UBRK_WORD_IDEO_LIMIT:
+		return analysis.Ideographic
+	}
+	return analysis.AlphaNumeric
+}
+
+func UnicodeWordBoundaryTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+	locale, ok := config["locale"].(string)
+	if ok {
+		return NewUnicodeWordBoundaryCustomLocaleTokenizer(locale), nil
+	}
+	return NewUnicodeWordBoundaryTokenizer(), nil
+}
+
+func init() {
+	registry.RegisterTokenizer(Name, UnicodeWordBoundaryTokenizerConstructor)
+}
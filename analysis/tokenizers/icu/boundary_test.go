@@ -0,0 +1,152 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build icu full
+
+package icu
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+)
+
+func TestBoundary(t *testing.T) {
+
+	tests := []struct {
+		input  []byte
+		locale string
+		output analysis.TokenStream
+	}{
+		{
+			[]byte("Hello World"),
+			"en_US",
+			analysis.TokenStream{
+				{
+					Start:    0,
+					End:      5,
+					Term:     []byte("Hello"),
+					Position: 1,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    6,
+					End:      11,
+					Term:     []byte("World"),
+					Position: 2,
+					Type:     analysis.AlphaNumeric,
+				},
+			},
+		},
+		{
+			[]byte("route 66 へ"),
+			"en_US",
+			analysis.TokenStream{
+				{
+					Start:    0,
+					End:      5,
+					Term:     []byte("route"),
+					Position: 1,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    6,
+					End:      8,
+					Term:     []byte("66"),
+					Position: 2,
+					Type:     analysis.Numeric,
+				},
+				{
+					Start:    9,
+					End:      12,
+					Term:     []byte("へ"),
+					Position: 3,
+					Type:     analysis.Ideographic,
+				},
+			},
+		},
+		{
+			[]byte("แยกคำภาษาไทยก็ทำได้นะจ้ะ"),
+			"th_TH",
+			analysis.TokenStream{
+				{
+					Start:    0,
+					End:      9,
+					Term:     []byte("แยก"),
+					Position: 1,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    9,
+					End:      15,
+					Term:     []byte("คำ"),
+					Position: 2,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    15,
+					End:      27,
+					Term:     []byte("ภาษา"),
+					Position: 3,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    27,
+					End:      36,
+					Term:     []byte("ไทย"),
+					Position: 4,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    36,
+					End:      42,
+					Term:     []byte("ก็"),
+					Position: 5,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    42,
+					End:      57,
+					Term:     []byte("ทำได้"),
+					Position: 6,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    57,
+					End:      63,
+					Term:     []byte("นะ"),
+					Position: 7,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    63,
+					End:      72,
+					Term:     []byte("จ้ะ"),
+					Position: 8,
+					Type:     analysis.AlphaNumeric,
+				},
+			},
+		},
+		{
+			[]byte(""),
+			"en_US",
+			analysis.TokenStream{},
+		},
+	}
+
+	for _, test := range tests {
+		tokenizer := NewUnicodeWordBoundaryCustomLocaleTokenizer(test.locale)
+		actual := tokenizer.Tokenize(test.input)
+
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("Expected %v, got %v for %s", test.output, actual, string(test.input))
+		}
+	}
+}
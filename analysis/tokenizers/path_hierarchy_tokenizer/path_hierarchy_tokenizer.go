@@ -0,0 +1,145 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package path_hierarchy_tokenizer
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "path_hierarchy"
+
+const DefaultDelimiter = "/"
+
+// PathHierarchyTokenizer emits every prefix of a path,
+// "/a/b/c" gives "/a", "/a/b" and "/a/b/c", all at the same
+// position.  In reverse mode the suffixes are emitted
+// instead, "/a/b/c", "a/b/c", "b/c" and "c", which suits
+// domain names.  The first skip path elements, or the last
+// in reverse mode, are left out of every token.  When
+// replacement differs from the delimiter, it takes its
+// place in the tokens.
+type PathHierarchyTokenizer struct {
+	delimiter   []byte
+	replacement []byte
+	skip        int
+	reverse     bool
+}
+
+func NewPathHierarchyTokenizer(delimiter, replacement string, skip int, reverse bool) *PathHierarchyTokenizer {
+	return &PathHierarchyTokenizer{
+		delimiter:   []byte(delimiter),
+		replacement: []byte(replacement),
+		skip:        skip,
+		reverse:     reverse,
+	}
+}
+
+func (t *PathHierarchyTokenizer) Tokenize(input []byte) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0)
+	if len(input) == 0 {
+		return rv
+	}
+
+	// the offsets of every delimiter in the input
+	delimiters := make([]int, 0)
+	for i := 0; i < len(input); {
+		next := bytes.Index(input[i:], t.delimiter)
+		if next < 0 {
+			break
+		}
+		delimiters = append(delimiters, i+next)
+		i += next + len(t.delimiter)
+	}
+
+	if t.reverse {
+		end := len(input)
+		if t.skip > 0 {
+			if t.skip > len(delimiters) {
+				return rv
+			}
+			end = delimiters[len(delimiters)-t.skip] + len(t.delimiter)
+		}
+		rv = t.appendToken(rv, input, 0, end)
+		for _, delimiter := range delimiters {
+			start := delimiter + len(t.delimiter)
+			if start >= end {
+				break
+			}
+			rv = t.appendToken(rv, input, start, end)
+		}
+		return rv
+	}
+
+	// path elements start at a delimiter, except the first
+	// when the path does not start with one
+	elementStarts := delimiters
+	if len(delimiters) == 0 || delimiters[0] != 0 {
+		elementStarts = append([]int{0}, delimiters...)
+	}
+	if t.skip >= len(elementStarts) {
+		return rv
+	}
+	start := elementStarts[t.skip]
+	for _, delimiter := range delimiters {
+		if delimiter > start {
+			rv = t.appendToken(rv, input, start, delimiter)
+		}
+	}
+	return t.appendToken(rv, input, start, len(input))
+}
+
+func (t *PathHierarchyTokenizer) appendToken(rv analysis.TokenStream, input []byte, start, end int) analysis.TokenStream {
+	term := input[start:end]
+	if !bytes.Equal(t.delimiter, t.replacement) {
+		term = bytes.Replace(term, t.delimiter, t.replacement, -1)
+	}
+	token := analysis.Token{
+		Term:     term,
+		Start:    start,
+		End:      end,
+		Position: 1,
+		Type:     analysis.AlphaNumeric,
+	}
+	return append(rv, &token)
+}
+
+func PathHierarchyTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+	delimiter := DefaultDelimiter
+	delimiterVal, ok := config["delimiter"].(string)
+	if ok {
+		delimiter = delimiterVal
+	}
+	if delimiter == "" {
+		return nil, fmt.Errorf("delimiter must not be empty")
+	}
+	replacement := delimiter
+	replacementVal, ok := config["replacement"].(string)
+	if ok {
+		replacement = replacementVal
+	}
+	skip := 0
+	skipVal, ok := config["skip"].(float64)
+	if ok {
+		skip = int(skipVal)
+	}
+	if skip < 0 {
+		return nil, fmt.Errorf("skip must not be negative")
+	}
+	reverse, _ := config["reverse"].(bool)
+	return NewPathHierarchyTokenizer(delimiter, replacement, skip, reverse), nil
+}
+
+func init() {
+	registry.RegisterTokenizer(Name, PathHierarchyTokenizerConstructor)
+}
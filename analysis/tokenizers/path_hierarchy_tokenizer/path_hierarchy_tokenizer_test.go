@@ -0,0 +1,106 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package path_hierarchy_tokenizer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestPathHierarchyTokenizer(t *testing.T) {
+	tests := []struct {
+		config map[string]interface{}
+		input  []byte
+		output analysis.TokenStream
+	}{
+		{
+			config: map[string]interface{}{},
+			input:  []byte("/a/b/c"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("/a"), Start: 0, End: 2, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("/a/b"), Start: 0, End: 4, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("/a/b/c"), Start: 0, End: 6, Position: 1, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"skip": 1.0,
+			},
+			input: []byte("a/b/c"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("/b"), Start: 1, End: 3, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("/b/c"), Start: 1, End: 5, Position: 1, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"delimiter":   `\`,
+				"replacement": "/",
+			},
+			input: []byte(`c:\docs\a.txt`),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("c:"), Start: 0, End: 2, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("c:/docs"), Start: 0, End: 7, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("c:/docs/a.txt"), Start: 0, End: 13, Position: 1, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"delimiter": ".",
+				"reverse":   true,
+			},
+			input: []byte("www.example.com"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("www.example.com"), Start: 0, End: 15, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("example.com"), Start: 4, End: 15, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("com"), Start: 12, End: 15, Position: 1, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"reverse": true,
+				"skip":    1.0,
+			},
+			input: []byte("/a/b/c"),
+			output: analysis.TokenStream{
+				&analysis.Token{Term: []byte("/a/b/"), Start: 0, End: 5, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("a/b/"), Start: 1, End: 5, Position: 1, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("b/"), Start: 3, End: 5, Position: 1, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"skip": 5.0,
+			},
+			input:  []byte("/a/b"),
+			output: analysis.TokenStream{},
+		},
+		{
+			config: map[string]interface{}{},
+			input:  []byte(""),
+			output: analysis.TokenStream{},
+		},
+	}
+
+	cache := registry.NewCache()
+	for _, test := range tests {
+		tokenizer, err := PathHierarchyTokenizerConstructor(test.config, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := tokenizer.Tokenize(test.input)
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("expected %v, got %v for %s", test.output, actual, string(test.input))
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"unicode/utf8"
 
 	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/registry"
@@ -23,7 +24,8 @@ const Name = "regexp"
 var IdeographRegexp = regexp.MustCompile(`\p{Han}|\p{Hangul}|\p{Hiragana}|\p{Katakana}`)
 
 type RegexpTokenizer struct {
-	r *regexp.Regexp
+	r              *regexp.Regexp
+	maxTokenLength int
 }
 
 func NewRegexpTokenizer(r *regexp.Regexp) *RegexpTokenizer {
@@ -32,23 +34,70 @@ func NewRegexpTokenizer(r *regexp.Regexp) *RegexpTokenizer {
 	}
 }
 
+// NewRegexpTokenizerMaxTokenLength builds a tokenizer
+// which splits matches longer than maxTokenLength runes
+// into several tokens, a maxTokenLength of 0 means no
+// limit
+func NewRegexpTokenizerMaxTokenLength(r *regexp.Regexp, maxTokenLength int) *RegexpTokenizer {
+	return &RegexpTokenizer{
+		r:              r,
+		maxTokenLength: maxTokenLength,
+	}
+}
+
 func (rt *RegexpTokenizer) Tokenize(input []byte) analysis.TokenStream {
 	matches := rt.r.FindAllIndex(input, -1)
-	rv := make(analysis.TokenStream, len(matches))
-	for i, match := range matches {
-		matchBytes := input[match[0]:match[1]]
-		token := analysis.Token{
-			Term:     matchBytes,
-			Start:    match[0],
-			End:      match[1],
-			Position: i + 1,
-			Type:     detectTokenType(matchBytes),
+	rv := make(analysis.TokenStream, 0, len(matches))
+	for _, match := range matches {
+		start := match[0]
+		for start < match[1] {
+			end := rt.tokenEnd(input, start, match[1])
+			matchBytes := input[start:end]
+			token := analysis.Token{
+				Term:     matchBytes,
+				Start:    start,
+				End:      end,
+				Position: len(rv) + 1,
+				Type:     detectTokenType(matchBytes),
+			}
+			rv = append(rv, &token)
+			start = end
 		}
-		rv[i] = &token
 	}
 	return rv
 }
 
+// tokenEnd returns the end of the token starting at start,
+// at most maxTokenLength runes before the end of the match
+func (rt *RegexpTokenizer) tokenEnd(input []byte, start, end int) int {
+	if rt.maxTokenLength <= 0 || end-start <= rt.maxTokenLength {
+		return end
+	}
+	runes := 0
+	for i := start; i < end; {
+		if runes == rt.maxTokenLength {
+			return i
+		}
+		_, size := utf8.DecodeRune(input[i:end])
+		i += size
+		runes++
+	}
+	return end
+}
+
+// MaxTokenLengthFromConfig reads the optional
+// max_token_length, returning 0 when it is not set
+func MaxTokenLengthFromConfig(config map[string]interface{}) (int, error) {
+	maxVal, ok := config["max_token_length"].(float64)
+	if !ok {
+		return 0, nil
+	}
+	if maxVal < 1 {
+		return 0, fmt.Errorf("max_token_length must be at least 1")
+	}
+	return int(maxVal), nil
+}
+
 func RegexpTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
 	rval, ok := config["regexp"].(string)
 	if !ok {
@@ -58,7 +107,11 @@ func RegexpTokenizerConstructor(config map[string]interface{}, cache *registry.C
 	if err != nil {
 		return nil, fmt.Errorf("unable to build regexp tokenizer: %v", err)
 	}
-	return NewRegexpTokenizer(r), nil
+	maxTokenLength, err := MaxTokenLengthFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegexpTokenizerMaxTokenLength(r, maxTokenLength), nil
 }
 
 func init() {
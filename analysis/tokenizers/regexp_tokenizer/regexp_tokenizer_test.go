@@ -113,3 +113,41 @@ func TestBoundary(t *testing.T) {
 		}
 	}
 }
+
+func TestMaxTokenLength(t *testing.T) {
+
+	wordRegex := regexp.MustCompile(`\w+`)
+
+	tests := []struct {
+		maxTokenLength int
+		input          []byte
+		output         analysis.TokenStream
+	}{
+		{
+			3,
+			[]byte("abcdefgh ij"),
+			analysis.TokenStream{
+				{Start: 0, End: 3, Term: []byte("abc"), Position: 1, Type: analysis.AlphaNumeric},
+				{Start: 3, End: 6, Term: []byte("def"), Position: 2, Type: analysis.AlphaNumeric},
+				{Start: 6, End: 8, Term: []byte("gh"), Position: 3, Type: analysis.AlphaNumeric},
+				{Start: 9, End: 11, Term: []byte("ij"), Position: 4, Type: analysis.AlphaNumeric},
+			},
+		},
+		{
+			0,
+			[]byte("abcdefgh"),
+			analysis.TokenStream{
+				{Start: 0, End: 8, Term: []byte("abcdefgh"), Position: 1, Type: analysis.AlphaNumeric},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		tokenizer := NewRegexpTokenizerMaxTokenLength(wordRegex, test.maxTokenLength)
+		actual := tokenizer.Tokenize(test.input)
+
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("Expected %v, got %v for %s", test.output, actual, string(test.input))
+		}
+	}
+}
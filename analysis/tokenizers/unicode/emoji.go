@@ -0,0 +1,129 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package unicode
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// pictographic approximates the unicode Extended_Pictographic
+// property, which is not available in the unicode package
+var pictographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x00a9, 0x00a9, 1},
+		{0x00ae, 0x00ae, 1},
+		{0x203c, 0x203c, 1},
+		{0x2049, 0x2049, 1},
+		{0x2122, 0x2122, 1},
+		{0x2139, 0x2139, 1},
+		{0x2194, 0x2199, 1},
+		{0x21a9, 0x21aa, 1},
+		{0x231a, 0x231b, 1},
+		{0x2328, 0x2328, 1},
+		{0x23cf, 0x23cf, 1},
+		{0x23e9, 0x23f3, 1},
+		{0x23f8, 0x23fa, 1},
+		{0x24c2, 0x24c2, 1},
+		{0x25aa, 0x25ab, 1},
+		{0x25b6, 0x25b6, 1},
+		{0x25c0, 0x25c0, 1},
+		{0x25fb, 0x25fe, 1},
+		{0x2600, 0x27bf, 1},
+		{0x2934, 0x2935, 1},
+		{0x2b05, 0x2b07, 1},
+		{0x2b1b, 0x2b1c, 1},
+		{0x2b50, 0x2b50, 1},
+		{0x2b55, 0x2b55, 1},
+		{0x3030, 0x3030, 1},
+		{0x303d, 0x303d, 1},
+		{0x3297, 0x3297, 1},
+		{0x3299, 0x3299, 1},
+	},
+	R32: []unicode.Range32{
+		{0x1f000, 0x1f1e5, 1},
+		{0x1f200, 0x1f3fa, 1},
+		{0x1f400, 0x1faff, 1},
+		{0x1fc00, 0x1fffd, 1},
+	},
+}
+
+const (
+	zeroWidthJoiner  = 0x200d
+	combiningKeycap  = 0x20e3
+	variationText    = 0xfe0e
+	variationEmoji   = 0xfe0f
+	regionalFirst    = 0x1f1e6
+	regionalLast     = 0x1f1ff
+	modifierFirst    = 0x1f3fb
+	modifierLast     = 0x1f3ff
+	tagFirst         = 0xe0020
+	tagLast          = 0xe007f
+	keycapNumberSign = '#'
+	keycapAsterisk   = '*'
+)
+
+func isRegionalIndicator(r rune) bool {
+	return r >= regionalFirst && r <= regionalLast
+}
+
+// isEmojiExtender reports whether r continues the emoji
+// before it, such as a variation selector or skin tone
+func isEmojiExtender(r rune) bool {
+	return r == variationText || r == variationEmoji || r == combiningKeycap ||
+		(r >= modifierFirst && r <= modifierLast) ||
+		(r >= tagFirst && r <= tagLast)
+}
+
+// scanEmoji returns the length in bytes of the emoji
+// sequence at the start of input, or 0 if there is none.
+// Flags are pairs of regional indicators, other sequences
+// start with a pictograph and are extended by modifiers
+// and further pictographs joined by a zero width joiner.
+func scanEmoji(input []byte) int {
+	r, size := utf8.DecodeRune(input)
+	n := size
+	switch {
+	case isRegionalIndicator(r):
+		next, nextSize := utf8.DecodeRune(input[n:])
+		if isRegionalIndicator(next) {
+			n += nextSize
+		}
+	case r == keycapNumberSign || r == keycapAsterisk:
+		// only part of an emoji as a keycap
+		next, nextSize := utf8.DecodeRune(input[n:])
+		if next == variationEmoji {
+			next, _ = utf8.DecodeRune(input[n+nextSize:])
+		}
+		if next != combiningKeycap {
+			return 0
+		}
+	case unicode.Is(pictographic, r):
+	default:
+		return 0
+	}
+
+	for n < len(input) {
+		next, nextSize := utf8.DecodeRune(input[n:])
+		if isEmojiExtender(next) {
+			n += nextSize
+			continue
+		}
+		if next == zeroWidthJoiner {
+			joined, joinedSize := utf8.DecodeRune(input[n+nextSize:])
+			if unicode.Is(pictographic, joined) {
+				n += nextSize + joinedSize
+				continue
+			}
+		}
+		break
+	}
+	return n
+}
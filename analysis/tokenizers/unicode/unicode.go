@@ -10,6 +10,8 @@
 package unicode
 
 import (
+	"unicode/utf8"
+
 	"github.com/blevesearch/segment"
 
 	"github.com/blevesearch/bleve/analysis"
@@ -18,13 +20,25 @@ import (
 
 const Name = "unicode"
 
+// UnicodeTokenizer splits the input into words following
+// the unicode word boundary rules.  When emoji is set,
+// emoji and pictographic sequences, which contain no word
+// characters and would otherwise be dropped, are also
+// emitted as tokens of type Emoji.
 type UnicodeTokenizer struct {
+	emoji bool
 }
 
 func NewUnicodeTokenizer() *UnicodeTokenizer {
 	return &UnicodeTokenizer{}
 }
 
+func NewUnicodeEmojiTokenizer() *UnicodeTokenizer {
+	return &UnicodeTokenizer{
+		emoji: true,
+	}
+}
+
 func (rt *UnicodeTokenizer) Tokenize(input []byte) analysis.TokenStream {
 
 	rv := make(analysis.TokenStream, 0)
@@ -32,10 +46,16 @@ func (rt *UnicodeTokenizer) Tokenize(input []byte) analysis.TokenStream {
 	segmenter := segment.NewWordSegmenterDirect(input)
 	start := 0
 	pos := 1
+	// start of the current run of segments without words
+	noneStart := -1
 	for segmenter.Segment() {
 		segmentBytes := segmenter.Bytes()
 		end := start + len(segmentBytes)
 		if segmenter.Type() != segment.None {
+			if noneStart >= 0 {
+				rv, pos = appendEmoji(rv, input, noneStart, start, pos)
+				noneStart = -1
+			}
 			token := analysis.Token{
 				Term:     segmentBytes,
 				Start:    start,
@@ -45,14 +65,47 @@ func (rt *UnicodeTokenizer) Tokenize(input []byte) analysis.TokenStream {
 			}
 			rv = append(rv, &token)
 			pos++
+		} else if rt.emoji && noneStart < 0 {
+			noneStart = start
 		}
 		start = end
 
 	}
+	if noneStart >= 0 {
+		rv, _ = appendEmoji(rv, input, noneStart, start, pos)
+	}
 	return rv
 }
 
+// appendEmoji adds the emoji sequences found in
+// input[start:end] to the token stream
+func appendEmoji(rv analysis.TokenStream, input []byte, start, end, pos int) (analysis.TokenStream, int) {
+	for start < end {
+		n := scanEmoji(input[start:end])
+		if n == 0 {
+			_, size := utf8.DecodeRune(input[start:end])
+			start += size
+			continue
+		}
+		token := analysis.Token{
+			Term:     input[start : start+n],
+			Start:    start,
+			End:      start + n,
+			Position: pos,
+			Type:     analysis.Emoji,
+		}
+		rv = append(rv, &token)
+		pos++
+		start += n
+	}
+	return rv, pos
+}
+
 func UnicodeTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+	emoji, ok := config["emoji"].(bool)
+	if ok && emoji {
+		return NewUnicodeEmojiTokenizer(), nil
+	}
 	return NewUnicodeTokenizer(), nil
 }
 
@@ -158,6 +158,125 @@ When a liquid boils it turns into a gas. The resulting gas takes up far more spa
 Typically, a BLEVE starts with a container of liquid which is held above its normal, atmospheric-pressure boiling temperature. Many substances normally stored as liquids, such as CO2, oxygen, and other similar industrial gases have boiling temperatures, at atmospheric pressure, far below room temperature. In the case of water, a BLEVE could occur if a pressurized chamber of water is heated far beyond the standard 100 °C (212 °F). That container, because the boiling water pressurizes it, is capable of holding liquid water at very high temperatures.
 If the pressurized vessel, containing liquid at high temperature (which may be room temperature, depending on the substance) ruptures, the pressure which prevents the liquid from boiling is lost. If the rupture is catastrophic, where the vessel is immediately incapable of holding any pressure at all, then there suddenly exists a large mass of liquid which is at very high temperature and very low pressure. This causes the entire volume of liquid to instantaneously boil, which in turn causes an extremely rapid expansion. Depending on temperatures, pressures and the substance involved, that expansion may be so rapid that it can be classified as an explosion, fully capable of inflicting severe damage on its surroundings.`)
 
+func TestUnicodeEmoji(t *testing.T) {
+
+	tests := []struct {
+		input  []byte
+		output analysis.TokenStream
+	}{
+		{
+			[]byte("I ❤️ pizza🍕 🇯🇵🇺🇸 👍🏽 👨\u200d👩\u200d👧, 50° #️⃣"),
+			analysis.TokenStream{
+				{
+					Start:    0,
+					End:      1,
+					Term:     []byte("I"),
+					Position: 1,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    2,
+					End:      8,
+					Term:     []byte("❤️"),
+					Position: 2,
+					Type:     analysis.Emoji,
+				},
+				{
+					Start:    9,
+					End:      14,
+					Term:     []byte("pizza"),
+					Position: 3,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    14,
+					End:      18,
+					Term:     []byte("🍕"),
+					Position: 4,
+					Type:     analysis.Emoji,
+				},
+				{
+					Start:    19,
+					End:      27,
+					Term:     []byte("🇯🇵"),
+					Position: 5,
+					Type:     analysis.Emoji,
+				},
+				{
+					Start:    27,
+					End:      35,
+					Term:     []byte("🇺🇸"),
+					Position: 6,
+					Type:     analysis.Emoji,
+				},
+				{
+					Start:    36,
+					End:      44,
+					Term:     []byte("👍🏽"),
+					Position: 7,
+					Type:     analysis.Emoji,
+				},
+				{
+					Start:    45,
+					End:      63,
+					Term:     []byte("👨\u200d👩\u200d👧"),
+					Position: 8,
+					Type:     analysis.Emoji,
+				},
+				{
+					Start:    65,
+					End:      67,
+					Term:     []byte("50"),
+					Position: 9,
+					Type:     analysis.Numeric,
+				},
+				{
+					Start:    70,
+					End:      77,
+					Term:     []byte("#️⃣"),
+					Position: 10,
+					Type:     analysis.Emoji,
+				},
+			},
+		},
+		{
+			[]byte("dots ... and -- dashes"),
+			analysis.TokenStream{
+				{
+					Start:    0,
+					End:      4,
+					Term:     []byte("dots"),
+					Position: 1,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    9,
+					End:      12,
+					Term:     []byte("and"),
+					Position: 2,
+					Type:     analysis.AlphaNumeric,
+				},
+				{
+					Start:    16,
+					End:      22,
+					Term:     []byte("dashes"),
+					Position: 3,
+					Type:     analysis.AlphaNumeric,
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		tokenizer := NewUnicodeEmojiTokenizer()
+		actual := tokenizer.Tokenize(test.input)
+
+		if !reflect.DeepEqual(actual, test.output) {
+			t.Errorf("Expected %v, got %v for %s", test.output, actual, string(test.input))
+		}
+	}
+}
+
 func BenchmarkTokenizeEnglishText(b *testing.B) {
 
 	tokenizer := NewUnicodeTokenizer()
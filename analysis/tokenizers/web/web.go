@@ -0,0 +1,208 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package web
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/tokenizers/unicode"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "web"
+
+const (
+	URLType     = "url"
+	EmailType   = "email"
+	HashtagType = "hashtag"
+	MentionType = "mention"
+)
+
+// the patterns are tried in this order, so that email
+// addresses inside urls and mentions inside email
+// addresses are not matched on their own
+var patterns = []struct {
+	name      string
+	tokenType analysis.TokenType
+	pattern   string
+	// the group holding the token, 0 for the whole match
+	group int
+}{
+	{URLType, analysis.URL, `(?i)(?:\b[a-z][a-z0-9+.\-]*://|\bwww\.)[^\s<>"]*[^\s<>".,;:!?)'\]]`, 0},
+	{EmailType, analysis.Email, `(?i)\b[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}\b`, 0},
+	{MentionType, analysis.Mention, `(?:^|[^\w@#])(@\w+)`, 1},
+	{HashtagType, analysis.Hashtag, `(?:^|[^\w@#&])(#\w+)`, 1},
+}
+
+var DefaultTypes = []string{URLType, EmailType, MentionType, HashtagType}
+
+// WebTokenizer keeps urls, email addresses, @mentions
+// and #hashtags as single tokens tagged with their type,
+// the rest of the input is handled by the remaining
+// tokenizer.  When splitURLs is set, urls are instead
+// split into a Host token followed by the tokens of the
+// path produced by the remaining tokenizer.
+type WebTokenizer struct {
+	pattern    *regexp.Regexp
+	tokenTypes []analysis.TokenType
+	groups     []int
+	splitURLs  bool
+	remaining  analysis.Tokenizer
+}
+
+func NewWebTokenizer(types []string, splitURLs bool, remaining analysis.Tokenizer) (*WebTokenizer, error) {
+	enabled := make(map[string]bool, len(types))
+	for _, name := range types {
+		enabled[name] = true
+	}
+	rv := WebTokenizer{
+		splitURLs: splitURLs,
+		remaining: remaining,
+	}
+	alternatives := make([]string, 0, len(patterns))
+	group := 1
+	for _, p := range patterns {
+		if !enabled[p.name] {
+			continue
+		}
+		delete(enabled, p.name)
+		r, err := regexp.Compile(p.pattern)
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, "("+p.pattern+")")
+		rv.tokenTypes = append(rv.tokenTypes, p.tokenType)
+		rv.groups = append(rv.groups, group+p.group)
+		group += 1 + r.NumSubexp()
+	}
+	for name := range enabled {
+		return nil, fmt.Errorf("unknown web token type '%s'", name)
+	}
+	if len(alternatives) == 0 {
+		return nil, fmt.Errorf("must specify at least one web token type")
+	}
+	var err error
+	rv.pattern, err = regexp.Compile(strings.Join(alternatives, "|"))
+	if err != nil {
+		return nil, err
+	}
+	return &rv, nil
+}
+
+func (t *WebTokenizer) Tokenize(input []byte) analysis.TokenStream {
+	rv := make(analysis.TokenStream, 0)
+	matches := t.pattern.FindAllSubmatchIndex(input, -1)
+	currInput := 0
+	for _, match := range matches {
+		start, end, tokenType := t.matched(match)
+		if start < currInput {
+			continue
+		}
+		rv = t.appendRemaining(rv, input, currInput, start)
+		if tokenType == analysis.URL && t.splitURLs {
+			rv = t.appendURLComponents(rv, input, start, end)
+		} else {
+			rv = append(rv, &analysis.Token{
+				Term:     input[start:end],
+				Start:    start,
+				End:      end,
+				Position: len(rv) + 1,
+				Type:     tokenType,
+			})
+		}
+		currInput = end
+	}
+	return t.appendRemaining(rv, input, currInput, len(input))
+}
+
+// matched returns the location and type of the token
+// for a match
+func (t *WebTokenizer) matched(match []int) (int, int, analysis.TokenType) {
+	for i, group := range t.groups {
+		if match[2*group] >= 0 {
+			return match[2*group], match[2*group+1], t.tokenTypes[i]
+		}
+	}
+	return match[0], match[1], analysis.AlphaNumeric
+}
+
+// appendRemaining adds the tokens of the remaining
+// tokenizer for input[start:end]
+func (t *WebTokenizer) appendRemaining(rv analysis.TokenStream, input []byte, start, end int) analysis.TokenStream {
+	if start >= end {
+		return rv
+	}
+	lastPos := len(rv)
+	for _, token := range t.remaining.Tokenize(input[start:end]) {
+		token.Position += lastPos
+		token.Start += start
+		token.End += start
+		rv = append(rv, token)
+	}
+	return rv
+}
+
+func (t *WebTokenizer) appendURLComponents(rv analysis.TokenStream, input []byte, start, end int) analysis.TokenStream {
+	hostStart := start
+	if i := strings.Index(string(input[start:end]), "://"); i >= 0 {
+		hostStart = start + i + len("://")
+	}
+	hostEnd := hostStart
+	for hostEnd < end && !strings.ContainsRune("/?#", rune(input[hostEnd])) {
+		hostEnd++
+	}
+	if hostEnd > hostStart {
+		rv = append(rv, &analysis.Token{
+			Term:     input[hostStart:hostEnd],
+			Start:    hostStart,
+			End:      hostEnd,
+			Position: len(rv) + 1,
+			Type:     analysis.Host,
+		})
+	}
+	return t.appendRemaining(rv, input, hostEnd, end)
+}
+
+func WebTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+	types := DefaultTypes
+	itypes, ok := config["types"].([]interface{})
+	if ok {
+		types = make([]string, 0, len(itypes))
+		for _, itype := range itypes {
+			typ, ok := itype.(string)
+			if !ok {
+				return nil, fmt.Errorf("web token types must be strings")
+			}
+			types = append(types, typ)
+		}
+	}
+	atypes, ok := config["types"].([]string)
+	if ok {
+		types = atypes
+	}
+	splitURLs, _ := config["split_urls"].(bool)
+
+	remainingName, ok := config["tokenizer"].(string)
+	if !ok {
+		remainingName = unicode.Name
+	}
+	remaining, err := cache.TokenizerNamed(remainingName)
+	if err != nil {
+		return nil, err
+	}
+	return NewWebTokenizer(types, splitURLs, remaining)
+}
+
+func init() {
+	registry.RegisterTokenizer(Name, WebTokenizerConstructor)
+}
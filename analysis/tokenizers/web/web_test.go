@@ -0,0 +1,86 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package web
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/registry"
+)
+
+func TestWebTokenizer(t *testing.T) {
+	tests := []struct {
+		config map[string]interface{}
+		input  []byte
+		result analysis.TokenStream
+	}{
+		{
+			config: map[string]interface{}{},
+			input:  []byte("mail marty@couchbase.com, see https://blevesearch.com/docs?x=1. @bleve #golang"),
+			result: analysis.TokenStream{
+				&analysis.Token{Term: []byte("mail"), Position: 1, Start: 0, End: 4, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("marty@couchbase.com"), Position: 2, Start: 5, End: 24, Type: analysis.Email},
+				&analysis.Token{Term: []byte("see"), Position: 3, Start: 26, End: 29, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("https://blevesearch.com/docs?x=1"), Position: 4, Start: 30, End: 62, Type: analysis.URL},
+				&analysis.Token{Term: []byte("@bleve"), Position: 5, Start: 64, End: 70, Type: analysis.Mention},
+				&analysis.Token{Term: []byte("#golang"), Position: 6, Start: 71, End: 78, Type: analysis.Hashtag},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"split_urls": true,
+			},
+			input: []byte("see https://blevesearch.com/docs?x=1."),
+			result: analysis.TokenStream{
+				&analysis.Token{Term: []byte("see"), Position: 1, Start: 0, End: 3, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("blevesearch.com"), Position: 2, Start: 12, End: 27, Type: analysis.Host},
+				&analysis.Token{Term: []byte("docs"), Position: 3, Start: 28, End: 32, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("x"), Position: 4, Start: 33, End: 34, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("1"), Position: 5, Start: 35, End: 36, Type: analysis.Numeric},
+			},
+		},
+		{
+			config: map[string]interface{}{
+				"types": []interface{}{"url"},
+			},
+			input: []byte("marty@couchbase.com www.blevesearch.com #golang"),
+			result: analysis.TokenStream{
+				&analysis.Token{Term: []byte("marty"), Position: 1, Start: 0, End: 5, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("couchbase.com"), Position: 2, Start: 6, End: 19, Type: analysis.AlphaNumeric},
+				&analysis.Token{Term: []byte("www.blevesearch.com"), Position: 3, Start: 20, End: 39, Type: analysis.URL},
+				&analysis.Token{Term: []byte("golang"), Position: 4, Start: 41, End: 47, Type: analysis.AlphaNumeric},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		cache := registry.NewCache()
+		tokenizer, err := WebTokenizerConstructor(test.config, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := tokenizer.Tokenize(test.input)
+		if !reflect.DeepEqual(actual, test.result) {
+			t.Errorf("expected %v, got %v", test.result, actual)
+		}
+	}
+}
+
+func TestWebTokenizerUnknownType(t *testing.T) {
+	cache := registry.NewCache()
+	_, err := WebTokenizerConstructor(map[string]interface{}{
+		"types": []interface{}{"url", "phone"},
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for unknown token type")
+	}
+}
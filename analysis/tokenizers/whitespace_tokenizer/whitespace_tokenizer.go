@@ -22,7 +22,11 @@ const Name = "whitespace"
 var whitespaceTokenizerRegexp = regexp.MustCompile(`\p{Han}|\p{Hangul}|\p{Hiragana}|\p{Katakana}|[^\p{Z}\p{P}\p{C}\p{Han}\p{Hangul}\p{Hiragana}\p{Katakana}]+`)
 
 func TokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
-	return regexp_tokenizer.NewRegexpTokenizer(whitespaceTokenizerRegexp), nil
+	maxTokenLength, err := regexp_tokenizer.MaxTokenLengthFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return regexp_tokenizer.NewRegexpTokenizerMaxTokenLength(whitespaceTokenizerRegexp, maxTokenLength), nil
 }
 
 func init() {
@@ -15,6 +15,7 @@ import (
 
 	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/analysis/tokenizers/regexp_tokenizer"
+	"github.com/blevesearch/bleve/registry"
 )
 
 func TestBoundary(t *testing.T) {
@@ -138,6 +139,32 @@ When a liquid boils it turns into a gas. The resulting gas takes up far more spa
 Typically, a BLEVE starts with a container of liquid which is held above its normal, atmospheric-pressure boiling temperature. Many substances normally stored as liquids, such as CO2, oxygen, and other similar industrial gases have boiling temperatures, at atmospheric pressure, far below room temperature. In the case of water, a BLEVE could occur if a pressurized chamber of water is heated far beyond the standard 100 °C (212 °F). That container, because the boiling water pressurizes it, is capable of holding liquid water at very high temperatures.
 If the pressurized vessel, containing liquid at high temperature (which may be room temperature, depending on the substance) ruptures, the pressure which prevents the liquid from boiling is lost. If the rupture is catastrophic, where the vessel is immediately incapable of holding any pressure at all, then there suddenly exists a large mass of liquid which is at very high temperature and very low pressure. This causes the entire volume of liquid to instantaneously boil, which in turn causes an extremely rapid expansion. Depending on temperatures, pressures and the substance involved, that expansion may be so rapid that it can be classified as an explosion, fully capable of inflicting severe damage on its surroundings.`)
 
+func TestWhitespaceMaxTokenLength(t *testing.T) {
+	cache := registry.NewCache()
+	tokenizer, err := TokenizerConstructor(map[string]interface{}{
+		"max_token_length": 2.0,
+	}, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := analysis.TokenStream{
+		{Start: 0, End: 4, Term: []byte("éé"), Position: 1, Type: analysis.AlphaNumeric},
+		{Start: 4, End: 6, Term: []byte("é"), Position: 2, Type: analysis.AlphaNumeric},
+		{Start: 7, End: 9, Term: []byte("ab"), Position: 3, Type: analysis.AlphaNumeric},
+	}
+	actual := tokenizer.Tokenize([]byte("ééé ab"))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	_, err = TokenizerConstructor(map[string]interface{}{
+		"max_token_length": 0.0,
+	}, cache)
+	if err == nil {
+		t.Errorf("expected error for max_token_length 0")
+	}
+}
+
 func BenchmarkTokenizeEnglishText(b *testing.B) {
 
 	tokenizer := regexp_tokenizer.NewRegexpTokenizer(whitespaceTokenizerRegexp)
@@ -18,6 +18,15 @@ type CharFilter interface {
 	Filter([]byte) []byte
 }
 
+// An OffsetCorrectingCharFilter is a CharFilter which may
+// change the length of its input.  Along with the output
+// it returns the corrections needed to map offsets in the
+// output back to offsets in the original input.
+type OffsetCorrectingCharFilter interface {
+	CharFilter
+	FilterWithCorrections([]byte) ([]byte, OffsetCorrections)
+}
+
 type TokenType int
 
 const (
@@ -28,6 +37,13 @@ const (
 	Shingle
 	Single
 	Double
+	Synonym
+	Emoji
+	URL
+	Host
+	Email
+	Hashtag
+	Mention
 )
 
 type Token struct {
@@ -37,6 +53,7 @@ type Token struct {
 	Position int       `json:"position"`
 	Type     TokenType `json:"type"`
 	KeyWord  bool      `json:"keyword"`
+	Payload  []byte    `json:"payload,omitempty"`
 }
 
 func (t *Token) String() string {
@@ -60,15 +77,51 @@ type Analyzer struct {
 }
 
 func (a *Analyzer) Analyze(input []byte) TokenStream {
+	return a.analyze(input, nil)
+}
+
+// AnalyzeStages analyzes the input like Analyze, and also
+// returns the output of every char filter, the tokenizer
+// and every token filter, in the order they were applied
+func (a *Analyzer) AnalyzeStages(input []byte) (TokenStream, []*AnalysisStage) {
+	var stages []*AnalysisStage
+	tokens := a.analyze(input, func(stage *AnalysisStage) {
+		stages = append(stages, stage)
+	})
+	return tokens, stages
+}
+
+func (a *Analyzer) analyze(input []byte, record func(*AnalysisStage)) TokenStream {
+	var corrections []OffsetCorrections
 	if a.CharFilters != nil {
 		for _, cf := range a.CharFilters {
-			input = cf.Filter(input)
+			if ocf, ok := cf.(OffsetCorrectingCharFilter); ok {
+				var c OffsetCorrections
+				input, c = ocf.FilterWithCorrections(input)
+				if len(c) > 0 {
+					corrections = append(corrections, c)
+				}
+			} else {
+				input = cf.Filter(input)
+			}
+			if record != nil {
+				record(newCharFilterStage(cf, input))
+			}
 		}
 	}
 	tokens := a.Tokenizer.Tokenize(input)
+	if len(corrections) > 0 {
+		CorrectOffsets(tokens, corrections)
+	}
+	if record != nil {
+		record(newTokenStage(TokenizerStage, a.Tokenizer, tokens))
+	}
 	if a.TokenFilters != nil {
 		for _, tf := range a.TokenFilters {
 			tokens = tf.Filter(tokens)
+			if record != nil {
+				record(newTokenStage(TokenFilterStage, tf, tokens))
+			}
 		}
 	}
 	return tokens
@@ -0,0 +1,334 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/index/store/inmem"
+	"github.com/blevesearch/bleve/registry"
+)
+
+// A backup is a stream of records, each made of a record
+// type byte followed by length prefixed fields:
+//
+//	'm' index meta
+//	'k' key, value  (a key/value pair of the KVStore)
+//	'f' name, data  (a file of the index directory)
+//	'e'             (the end of the backup)
+//
+// The stream starts with backupMagic and the format
+// version.
+var backupMagic = []byte("bleve-backup")
+
+const backupVersion = 1
+
+const (
+	backupRecordMeta byte = 'm'
+	backupRecordKV   byte = 'k'
+	backupRecordFile byte = 'f'
+	backupRecordEnd  byte = 'e'
+)
+
+type backupWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func newBackupWriter(w io.Writer) (*backupWriter, error) {
+	rv := &backupWriter{
+		w:   bufio.NewWriter(w),
+		buf: make([]byte, binary.MaxVarintLen64),
+	}
+	_, err := rv.w.Write(backupMagic)
+	if err != nil {
+		return nil, err
+	}
+	err = rv.writeUvarint(backupVersion)
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (b *backupWriter) writeUvarint(v uint64) error {
+	n := binary.PutUvarint(b.buf, v)
+	_, err := b.w.Write(b.buf[:n])
+	return err
+}
+
+func (b *backupWriter) writeRecord(recordType byte, fields ...[]byte) error {
+	err := b.w.WriteByte(recordType)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		err = b.writeUvarint(uint64(len(field)))
+		if err != nil {
+			return err
+		}
+		_, err = b.w.Write(field)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *backupWriter) writeMeta(meta *indexMeta) error {
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return b.writeRecord(backupRecordMeta, metaBytes)
+}
+
+func (b *backupWriter) WriteKV(key, val []byte) error {
+	return b.writeRecord(backupRecordKV, key, val)
+}
+
+func (b *backupWriter) WriteFile(name string, data []byte) error {
+	return b.writeRecord(backupRecordFile, []byte(name), data)
+}
+
+func (b *backupWriter) Close() error {
+	err := b.writeRecord(backupRecordEnd)
+	if err != nil {
+		return err
+	}
+	return b.w.Flush()
+}
+
+type backupReader struct {
+	r *bufio.Reader
+}
+
+func newBackupReader(r io.Reader) (*backupReader, error) {
+	rv := &backupReader{
+		r: bufio.NewReader(r),
+	}
+	magic := make([]byte, len(backupMagic))
+	_, err := io.ReadFull(rv.r, magic)
+	if err != nil || !bytes.Equal(magic, backupMagic) {
+		return nil, ErrorBackupCorrupt
+	}
+	version, err := binary.ReadUvarint(rv.r)
+	if err != nil || version != backupVersion {
+		return nil, ErrorBackupCorrupt
+	}
+	return rv, nil
+}
+
+func (b *backupReader) readField() ([]byte, error) {
+	length, err := binary.ReadUvarint(b.r)
+	if err != nil {
+		return nil, ErrorBackupCorrupt
+	}
+	rv := make([]byte, length)
+	_, err = io.ReadFull(b.r, rv)
+	if err != nil {
+		return nil, ErrorBackupCorrupt
+	}
+	return rv, nil
+}
+
+// readRecord returns the type and the fields of the next
+// record, a backup which ends without an end record is
+// corrupt
+func (b *backupReader) readRecord() (byte, [][]byte, error) {
+	recordType, err := b.r.ReadByte()
+	if err != nil {
+		return 0, nil, ErrorBackupCorrupt
+	}
+	numFields := 0
+	switch recordType {
+	case backupRecordMeta:
+		numFields = 1
+	case backupRecordKV, backupRecordFile:
+		numFields = 2
+	case backupRecordEnd:
+	default:
+		return 0, nil, ErrorBackupCorrupt
+	}
+	fields := make([][]byte, numFields)
+	for i := range fields {
+		fields[i], err = b.readField()
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	return recordType, fields, nil
+}
+
+func (b *backupReader) readMeta() (*indexMeta, error) {
+	recordType, fields, err := b.readRecord()
+	if err != nil {
+		return nil, err
+	}
+	if recordType != backupRecordMeta {
+		return nil, ErrorBackupCorrupt
+	}
+	var meta indexMeta
+	err = json.Unmarshal(fields[0], &meta)
+	if err != nil {
+		return nil, ErrorBackupCorrupt
+	}
+	return &meta, nil
+}
+
+// the key/value pairs are written in batches of this size
+const restoreBatchSize = 1000
+
+func restoreIndex(r io.Reader, path string) (rv *indexImpl, err error) {
+	br, err := newBackupReader(r)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := br.readMeta()
+	if err != nil {
+		return nil, err
+	}
+	// the backup of an in memory index is restored
+	// into the default storage
+	if meta.Storage == inmem.Name {
+		meta.Storage = Config.DefaultKVStore
+		meta.Config = nil
+	}
+	storeConstructor := registry.KVStoreConstructorByName(meta.Storage)
+	if storeConstructor == nil {
+		return nil, ErrorUnknownStorageType
+	}
+	if registry.IndexTypeConstructorByName(meta.IndexType) == nil {
+		return nil, ErrorUnknownIndexType
+	}
+
+	storeConfig := map[string]interface{}{}
+	for k, v := range meta.Config {
+		storeConfig[k] = v
+	}
+	delete(storeConfig, "path")
+	delete(storeConfig, "create_if_missing")
+	delete(storeConfig, "error_if_exists")
+	meta.Config = storeConfig
+
+	err = meta.Save(path)
+	if err != nil {
+		return nil, err
+	}
+	// a failed restore leaves no partial index behind
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(path)
+		}
+	}()
+
+	kvconfig := map[string]interface{}{
+		"path":              indexStorePath(path),
+		"create_if_missing": true,
+		"error_if_exists":   true,
+	}
+	for k, v := range storeConfig {
+		kvconfig[k] = v
+	}
+	s, err := storeConstructor(kvconfig)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Open()
+	if err != nil {
+		return nil, err
+	}
+	err = restoreRecords(br, s, indexDirectoryPath(path))
+	if cerr := s.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return openIndexUsing(path, nil)
+}
+
+// restoreRecords writes the key/value pairs of the backup
+// to the store and the files to dir
+func restoreRecords(br *backupReader, s store.KVStore, dir string) (err error) {
+	kvwriter, err := s.Writer()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := kvwriter.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	wb := kvwriter.NewBatch()
+	batchSize := 0
+	for {
+		recordType, fields, err := br.readRecord()
+		if err != nil {
+			return err
+		}
+		switch recordType {
+		case backupRecordKV:
+			wb.Set(fields[0], fields[1])
+			batchSize++
+			if batchSize >= restoreBatchSize {
+				err = wb.Execute()
+				if err != nil {
+					return err
+				}
+				wb = kvwriter.NewBatch()
+				batchSize = 0
+			}
+		case backupRecordFile:
+			err = restoreFile(dir, string(fields[0]), fields[1])
+			if err != nil {
+				return err
+			}
+		case backupRecordEnd:
+			return wb.Execute()
+		default:
+			return ErrorBackupCorrupt
+		}
+	}
+}
+
+func restoreFile(dir, name string, data []byte) (err error) {
+	// files are only ever restored into dir
+	if name == "" || name != filepath.Base(name) || name == ".." {
+		return ErrorBackupCorrupt
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	_, err = f.Write(data)
+	if err != nil {
+		return
+	}
+	return f.Sync()
+}
@@ -0,0 +1,225 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"hash/fnv"
+	"runtime"
+	"sync"
+
+	"github.com/blevesearch/bleve/document"
+)
+
+// A BulkIndexer indexes a stream of documents through a
+// pipeline.  The documents are spread over a pool of
+// workers by id, each worker maps its documents and
+// gathers them into batches, and executes a batch while it
+// gathers the next one.  The batches of the workers are
+// executed concurrently, so their analysis and writes
+// overlap as far as the index allows.  Index and Delete
+// block while the queue in front of a worker is full, so a
+// fast producer is held back by the index rather than
+// buffering without bound.  Operations on the same
+// document are applied in the order they were made.  A
+// BulkIndexer is safe for use by several goroutines, once
+// it failed all operations return the first error.
+type BulkIndexer struct {
+	index     Index
+	batchSize int
+
+	// one queue per worker, operations on a document
+	// always go to the same worker
+	queues []chan *bulkOp
+
+	workersDone sync.WaitGroup
+	done        chan struct{}
+
+	closeMutex sync.RWMutex
+	closed     bool
+
+	errMutex sync.Mutex
+	err      error
+	count    uint64
+}
+
+type bulkOp struct {
+	id   string
+	data interface{}
+	// delete operations have no data
+	delete bool
+}
+
+// a bulkBatch is a batch with the number of operations
+// in it, operations on the same document share an entry
+// of the batch
+type bulkBatch struct {
+	batch *Batch
+	ops   uint64
+}
+
+const (
+	defaultBulkIndexerBatchSize = 1000
+	defaultBulkIndexerQueueSize = 1000
+)
+
+// NewBulkIndexer starts a BulkIndexer for the index with
+// the given number of workers, batch size and number of
+// operations queued before Index blocks.  Each worker
+// executes batches of up to batchSize operations.  Zero
+// values pick defaults: a worker per CPU, batches of 1000
+// and a queue of 1000.
+func NewBulkIndexer(i Index, workers, batchSize, queueSize int) *BulkIndexer {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if batchSize <= 0 {
+		batchSize = defaultBulkIndexerBatchSize
+	}
+	if queueSize <= 0 {
+		queueSize = defaultBulkIndexerQueueSize
+	}
+	queueSizePerWorker := queueSize / workers
+	if queueSizePerWorker < 1 {
+		queueSizePerWorker = 1
+	}
+
+	rv := &BulkIndexer{
+		index:     i,
+		batchSize: batchSize,
+		queues:    make([]chan *bulkOp, workers),
+		done:      make(chan struct{}),
+	}
+	for w := range rv.queues {
+		rv.queues[w] = make(chan *bulkOp, queueSizePerWorker)
+		batches := make(chan *bulkBatch, 1)
+		rv.workersDone.Add(1)
+		go rv.gatherBatches(rv.queues[w], batches)
+		go rv.executeBatches(batches)
+	}
+	go func() {
+		rv.workersDone.Wait()
+		close(rv.done)
+	}()
+	return rv
+}
+
+// Index queues data to be indexed as id
+func (b *BulkIndexer) Index(id string, data interface{}) error {
+	return b.queue(&bulkOp{id: id, data: data})
+}
+
+// Delete queues the deletion of id
+func (b *BulkIndexer) Delete(id string) error {
+	return b.queue(&bulkOp{id: id, delete: true})
+}
+
+func (b *BulkIndexer) queue(op *bulkOp) error {
+	b.closeMutex.RLock()
+	defer b.closeMutex.RUnlock()
+
+	if b.closed {
+		return ErrorIndexClosed
+	}
+	err := b.Err()
+	if err != nil {
+		return err
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(op.id))
+	b.queues[h.Sum32()%uint32(len(b.queues))] <- op
+	return nil
+}
+
+// Close waits for all queued operations to be applied, it
+// returns the first error of the BulkIndexer
+func (b *BulkIndexer) Close() error {
+	b.closeMutex.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, queue := range b.queues {
+			close(queue)
+		}
+	}
+	b.closeMutex.Unlock()
+
+	<-b.done
+	return b.Err()
+}
+
+// Err returns the first error of the BulkIndexer
+func (b *BulkIndexer) Err() error {
+	b.errMutex.Lock()
+	defer b.errMutex.Unlock()
+	return b.err
+}
+
+// Count returns the number of operations applied so far
+func (b *BulkIndexer) Count() uint64 {
+	b.errMutex.Lock()
+	defer b.errMutex.Unlock()
+	return b.count
+}
+
+func (b *BulkIndexer) setErr(err error) {
+	b.errMutex.Lock()
+	defer b.errMutex.Unlock()
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// gatherBatches maps the documents of a worker and hands
+// its batches to executeBatches as they fill up
+func (b *BulkIndexer) gatherBatches(queue chan *bulkOp, batches chan *bulkBatch) {
+	defer close(batches)
+	mapping := b.index.Mapping()
+	batch := &bulkBatch{batch: b.index.NewBatch()}
+	for op := range queue {
+		if op.delete {
+			batch.batch.Delete(op.id)
+		} else {
+			doc := document.NewDocument(op.id)
+			err := mapping.mapDocument(doc, op.data)
+			if err != nil {
+				b.setErr(err)
+				continue
+			}
+			batch.batch.internal.Update(doc)
+		}
+		batch.ops++
+		if batch.ops >= uint64(b.batchSize) {
+			batches <- batch
+			batch = &bulkBatch{batch: b.index.NewBatch()}
+		}
+	}
+	if batch.ops > 0 {
+		batches <- batch
+	}
+}
+
+// executeBatches executes the batches of a worker, it
+// stops executing them after an error but still drains
+// them so that the pipeline finishes
+func (b *BulkIndexer) executeBatches(batches chan *bulkBatch) {
+	defer b.workersDone.Done()
+	for batch := range batches {
+		if b.Err() != nil {
+			continue
+		}
+		err := b.index.Batch(batch.batch)
+		if err != nil {
+			b.setErr(err)
+			continue
+		}
+		b.errMutex.Lock()
+		b.count += batch.ops
+		b.errMutex.Unlock()
+	}
+}
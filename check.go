@@ -0,0 +1,41 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"github.com/blevesearch/bleve/index"
+)
+
+// CheckIndex scans the index for corruption and reports
+// the problems found, see index.CheckableIndex.  With
+// repair the problems which can be fixed without losing
+// documents are fixed, the index must not be opened read
+// only then.  Changes wait until the check is done.
+func CheckIndex(i Index, repair bool) (*index.CheckResult, error) {
+	if impl, ok := i.(*indexImpl); ok {
+		impl.mutex.RLock()
+		defer impl.mutex.RUnlock()
+		if !impl.open {
+			return nil, ErrorIndexClosed
+		}
+		if repair && impl.readOnly {
+			return nil, ErrorIndexReadOnly
+		}
+	}
+	idx, _, err := i.Advanced()
+	if err != nil {
+		return nil, err
+	}
+	checkableIndex, ok := idx.(index.CheckableIndex)
+	if !ok {
+		return nil, ErrorCheckUnsupported
+	}
+	return checkableIndex.Check(repair)
+}
@@ -30,6 +30,7 @@ type configuration struct {
 	DefaultKVStore         string
 	DefaultIndexType       string
 	SlowSearchLogThreshold time.Duration
+	TTLSweepInterval       time.Duration
 	analysisQueue          *index.AnalysisQueue
 }
 
@@ -62,6 +63,9 @@ func init() {
 	// default index
 	Config.DefaultIndexType = upside_down.Name
 
+	// how often expired documents are deleted
+	Config.TTLSweepInterval = time.Minute
+
 	bootDuration := time.Since(bootStart)
 	bleveExpVar.Add("bootDuration", int64(bootDuration))
 }
@@ -27,38 +27,59 @@ import (
 
 	// char filters
 	_ "github.com/blevesearch/bleve/analysis/char_filters/html_char_filter"
+	_ "github.com/blevesearch/bleve/analysis/char_filters/pattern_replace_char_filter"
 	_ "github.com/blevesearch/bleve/analysis/char_filters/regexp_char_filter"
 	_ "github.com/blevesearch/bleve/analysis/char_filters/zero_width_non_joiner"
 
 	// analyzers
 	_ "github.com/blevesearch/bleve/analysis/analyzers/custom_analyzer"
+	_ "github.com/blevesearch/bleve/analysis/analyzers/fingerprint_analyzer"
 	_ "github.com/blevesearch/bleve/analysis/analyzers/keyword_analyzer"
+	_ "github.com/blevesearch/bleve/analysis/analyzers/language_router_analyzer"
+	_ "github.com/blevesearch/bleve/analysis/analyzers/normalizer_analyzer"
 	_ "github.com/blevesearch/bleve/analysis/analyzers/simple_analyzer"
 	_ "github.com/blevesearch/bleve/analysis/analyzers/standard_analyzer"
 
 	// token filters
 	_ "github.com/blevesearch/bleve/analysis/token_filters/apostrophe_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/compound"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/conditional_filter"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/delimited_payload_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/edge_ngram_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/elision_filter"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/fingerprint_filter"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/hunspell_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/keyword_marker_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/length_filter"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/limit_token_count_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/lower_case_filter"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/minhash_filter"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/multiplexer_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/ngram_filter"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/pattern_capture_filter"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/phonetic_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/shingle"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/stemmer_override_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/stop_tokens_filter"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/synonym_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/truncate_token_filter"
 	_ "github.com/blevesearch/bleve/analysis/token_filters/unicode_normalize"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/word_delimiter_filter"
 
 	// tokenizers
+	_ "github.com/blevesearch/bleve/analysis/tokenizers/char_group_tokenizer"
+	_ "github.com/blevesearch/bleve/analysis/tokenizers/classic_tokenizer"
 	_ "github.com/blevesearch/bleve/analysis/tokenizers/exception"
+	_ "github.com/blevesearch/bleve/analysis/tokenizers/path_hierarchy_tokenizer"
 	_ "github.com/blevesearch/bleve/analysis/tokenizers/regexp_tokenizer"
 	_ "github.com/blevesearch/bleve/analysis/tokenizers/single_token"
 	_ "github.com/blevesearch/bleve/analysis/tokenizers/unicode"
+	_ "github.com/blevesearch/bleve/analysis/tokenizers/web"
 	_ "github.com/blevesearch/bleve/analysis/tokenizers/whitespace_tokenizer"
 
 	// date time parsers
 	_ "github.com/blevesearch/bleve/analysis/datetime_parsers/datetime_optional"
+	_ "github.com/blevesearch/bleve/analysis/datetime_parsers/epoch"
 	_ "github.com/blevesearch/bleve/analysis/datetime_parsers/flexible_go"
 
 	// languages
@@ -67,9 +88,12 @@ import (
 	_ "github.com/blevesearch/bleve/analysis/language/ca"
 	_ "github.com/blevesearch/bleve/analysis/language/cjk"
 	_ "github.com/blevesearch/bleve/analysis/language/ckb"
+	_ "github.com/blevesearch/bleve/analysis/language/co"
 	_ "github.com/blevesearch/bleve/analysis/language/cs"
+	_ "github.com/blevesearch/bleve/analysis/language/de"
 	_ "github.com/blevesearch/bleve/analysis/language/el"
 	_ "github.com/blevesearch/bleve/analysis/language/en"
+	_ "github.com/blevesearch/bleve/analysis/language/es"
 	_ "github.com/blevesearch/bleve/analysis/language/eu"
 	_ "github.com/blevesearch/bleve/analysis/language/fa"
 	_ "github.com/blevesearch/bleve/analysis/language/fr"
@@ -80,7 +104,13 @@ import (
 	_ "github.com/blevesearch/bleve/analysis/language/id"
 	_ "github.com/blevesearch/bleve/analysis/language/in"
 	_ "github.com/blevesearch/bleve/analysis/language/it"
+	_ "github.com/blevesearch/bleve/analysis/language/ko"
+	_ "github.com/blevesearch/bleve/analysis/language/oc"
+	_ "github.com/blevesearch/bleve/analysis/language/pl"
 	_ "github.com/blevesearch/bleve/analysis/language/pt"
+	_ "github.com/blevesearch/bleve/analysis/language/th"
+	_ "github.com/blevesearch/bleve/analysis/language/uk"
+	_ "github.com/blevesearch/bleve/analysis/language/vi"
 
 	// kv stores
 	_ "github.com/blevesearch/bleve/index/store/boltdb"
@@ -89,6 +119,7 @@ import (
 	_ "github.com/blevesearch/bleve/index/store/inmem"
 
 	// index types
+	_ "github.com/blevesearch/bleve/index/segmented"
 	_ "github.com/blevesearch/bleve/index/upside_down"
 
 	// byte array converters
@@ -12,5 +12,8 @@
 package config
 
 import (
+	_ "github.com/blevesearch/bleve/analysis/token_filters/icu_folding_filter"
+	_ "github.com/blevesearch/bleve/analysis/token_filters/pinyin_filter"
+	_ "github.com/blevesearch/bleve/analysis/tokenizers/icu"
 	_ "github.com/blevesearch/blevex/lang/th"
 )
@@ -12,5 +12,5 @@
 package config
 
 import (
-	_ "github.com/blevesearch/blevex/rocksdb"
+	_ "github.com/blevesearch/bleve/index/store/rocksdb"
 )
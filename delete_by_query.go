@@ -0,0 +1,77 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"github.com/blevesearch/bleve/index"
+)
+
+// the matching documents are deleted in batches of this
+// size
+const deleteByQueryBatchSize = 1000
+
+// deleteByQuery deletes the documents matching q, cancel
+// is checked before each batch.  It returns the number of
+// documents deleted before it finished or was canceled,
+// documents which were already deleted by others when
+// their batch was built are not counted.
+func deleteByQuery(i *indexImpl, q Query, cancel <-chan struct{}) (deleted uint64, err error) {
+	err = matchingIDs(i, q, deleteByQueryBatchSize, cancel, func(ids []string) error {
+		ids, err := i.existingIDs(ids)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		batch := i.NewBatch()
+		for _, id := range ids {
+			batch.Delete(id)
+		}
+		err = i.Batch(batch)
+		if err != nil {
+			return err
+		}
+		deleted += uint64(len(ids))
+		return nil
+	})
+	return deleted, err
+}
+
+// existingIDs returns the ids of the documents the index
+// currently has among ids
+func (i *indexImpl) existingIDs(ids []string) (rv []string, err error) {
+	indexReader, err := i.i.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := indexReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	rv = make([]string, 0, len(ids))
+	for _, id := range ids {
+		var reader index.DocIDReader
+		reader, err = indexReader.DocIDReader(id, id+"\x00")
+		if err != nil {
+			return nil, err
+		}
+		var found string
+		found, err = reader.Next()
+		if cerr := reader.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+		if found == id {
+			rv = append(rv, id)
+		}
+	}
+	return rv, nil
+}
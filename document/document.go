@@ -17,6 +17,10 @@ type Document struct {
 	ID              string  `json:"id"`
 	Fields          []Field `json:"fields"`
 	CompositeFields []*CompositeField
+	// Nested holds the documents the elements of nested
+	// objects are indexed as, they are indexed along with
+	// this one
+	Nested []*Document `json:"nested,omitempty"`
 }
 
 func NewDocument(id string) *Document {
@@ -37,6 +41,11 @@ func (d *Document) AddField(f Field) *Document {
 	return d
 }
 
+func (d *Document) AddNested(nested *Document) *Document {
+	d.Nested = append(d.Nested, nested)
+	return d
+}
+
 func (d *Document) GoString() string {
 	fields := ""
 	for i, field := range d.Fields {
@@ -29,6 +29,7 @@ type DateTimeField struct {
 	arrayPositions []uint64
 	options        IndexingOptions
 	value          numeric_util.PrefixCoded
+	precisionStep  uint
 }
 
 func (n *DateTimeField) Name() string {
@@ -56,7 +57,11 @@ func (n *DateTimeField) Analyze() (int, analysis.TokenFrequencies) {
 	original, err := n.value.Int64()
 	if err == nil {
 
-		shift := DefaultDateTimePrecisionStep
+		precisionStep := n.precisionStep
+		if precisionStep == 0 {
+			precisionStep = DefaultDateTimePrecisionStep
+		}
+		shift := precisionStep
 		for shift < 64 {
 			shiftEncoded, err := numeric_util.NewPrefixCodedInt64(original, shift)
 			if err != nil {
@@ -70,7 +75,7 @@ func (n *DateTimeField) Analyze() (int, analysis.TokenFrequencies) {
 				Type:     analysis.DateTime,
 			}
 			tokens = append(tokens, &token)
-			shift += DefaultDateTimePrecisionStep
+			shift += precisionStep
 		}
 	}
 
@@ -109,6 +114,12 @@ func NewDateTimeField(name string, arrayPositions []uint64, dt time.Time) (*Date
 }
 
 func NewDateTimeFieldWithIndexingOptions(name string, arrayPositions []uint64, dt time.Time, options IndexingOptions) (*DateTimeField, error) {
+	return NewDateTimeFieldWithPrecisionStep(name, arrayPositions, dt, options, DefaultDateTimePrecisionStep)
+}
+
+// NewDateTimeFieldWithPrecisionStep is like
+// NewNumericFieldWithPrecisionStep, for dates
+func NewDateTimeFieldWithPrecisionStep(name string, arrayPositions []uint64, dt time.Time, options IndexingOptions, precisionStep uint) (*DateTimeField, error) {
 	if canRepresent(dt) {
 		dtInt64 := dt.UnixNano()
 		prefixCoded := numeric_util.MustNewPrefixCodedInt64(dtInt64, 0)
@@ -117,6 +128,7 @@ func NewDateTimeFieldWithIndexingOptions(name string, arrayPositions []uint64, d
 			arrayPositions: arrayPositions,
 			value:          prefixCoded,
 			options:        options,
+			precisionStep:  precisionStep,
 		}, nil
 	}
 	return nil, fmt.Errorf("cannot represent %s in this type", dt)
@@ -25,6 +25,7 @@ type NumericField struct {
 	arrayPositions []uint64
 	options        IndexingOptions
 	value          numeric_util.PrefixCoded
+	precisionStep  uint
 }
 
 func (n *NumericField) Name() string {
@@ -52,7 +53,11 @@ func (n *NumericField) Analyze() (int, analysis.TokenFrequencies) {
 	original, err := n.value.Int64()
 	if err == nil {
 
-		shift := DefaultPrecisionStep
+		precisionStep := n.precisionStep
+		if precisionStep == 0 {
+			precisionStep = DefaultPrecisionStep
+		}
+		shift := precisionStep
 		for shift < 64 {
 			shiftEncoded, err := numeric_util.NewPrefixCodedInt64(original, shift)
 			if err != nil {
@@ -66,7 +71,7 @@ func (n *NumericField) Analyze() (int, analysis.TokenFrequencies) {
 				Type:     analysis.Numeric,
 			}
 			tokens = append(tokens, &token)
-			shift += DefaultPrecisionStep
+			shift += precisionStep
 		}
 	}
 
@@ -105,6 +110,15 @@ func NewNumericField(name string, arrayPositions []uint64, number float64) *Nume
 }
 
 func NewNumericFieldWithIndexingOptions(name string, arrayPositions []uint64, number float64, options IndexingOptions) *NumericField {
+	return NewNumericFieldWithPrecisionStep(name, arrayPositions, number, options, DefaultPrecisionStep)
+}
+
+// NewNumericFieldWithPrecisionStep indexes the number along
+// with a term for every precisionStep bits of it, range
+// queries of the field must use the same step.  Smaller
+// steps index more terms, but let range queries visit
+// fewer of them.
+func NewNumericFieldWithPrecisionStep(name string, arrayPositions []uint64, number float64, options IndexingOptions, precisionStep uint) *NumericField {
 	numberInt64 := numeric_util.Float64ToInt64(number)
 	prefixCoded := numeric_util.MustNewPrefixCodedInt64(numberInt64, 0)
 	return &NumericField{
@@ -112,5 +126,6 @@ func NewNumericFieldWithIndexingOptions(name string, arrayPositions []uint64, nu
 		arrayPositions: arrayPositions,
 		value:          prefixCoded,
 		options:        options,
+		precisionStep:  precisionStep,
 	}
 }
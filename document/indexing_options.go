@@ -15,6 +15,18 @@ const (
 	IndexField IndexingOptions = 1 << iota
 	StoreField
 	IncludeTermVectors
+	IncludeDocValues
+	// OmitTermFreqs indexes a frequency of 1 for all terms
+	OmitTermFreqs
+	// OmitTermOffsets keeps only the positions in the term
+	// vectors
+	OmitTermOffsets
+	// IncludeTermPayloads keeps the payloads of the tokens
+	// in the term vectors
+	IncludeTermPayloads
+	// OmitTermPositions keeps neither the positions nor the
+	// offsets in the term vectors, only the terms
+	OmitTermPositions
 )
 
 func (o IndexingOptions) IsIndexed() bool {
@@ -29,6 +41,26 @@ func (o IndexingOptions) IncludeTermVectors() bool {
 	return o&IncludeTermVectors != 0
 }
 
+func (o IndexingOptions) IncludeDocValues() bool {
+	return o&IncludeDocValues != 0
+}
+
+func (o IndexingOptions) OmitTermFreqs() bool {
+	return o&OmitTermFreqs != 0
+}
+
+func (o IndexingOptions) OmitTermOffsets() bool {
+	return o&OmitTermOffsets != 0
+}
+
+func (o IndexingOptions) IncludeTermPayloads() bool {
+	return o&IncludeTermPayloads != 0
+}
+
+func (o IndexingOptions) OmitTermPositions() bool {
+	return o&OmitTermPositions != 0
+}
+
 func (o IndexingOptions) String() string {
 	rv := ""
 	if o.IsIndexed() {
@@ -46,5 +78,35 @@ func (o IndexingOptions) String() string {
 		}
 		rv += "TV"
 	}
+	if o.IncludeDocValues() {
+		if rv != "" {
+			rv += ", "
+		}
+		rv += "DV"
+	}
+	if o.OmitTermFreqs() {
+		if rv != "" {
+			rv += ", "
+		}
+		rv += "NOFREQS"
+	}
+	if o.OmitTermOffsets() {
+		if rv != "" {
+			rv += ", "
+		}
+		rv += "NOOFFSETS"
+	}
+	if o.IncludeTermPayloads() {
+		if rv != "" {
+			rv += ", "
+		}
+		rv += "PAYLOADS"
+	}
+	if o.OmitTermPositions() {
+		if rv != "" {
+			rv += ", "
+		}
+		rv += "NOPOSITIONS"
+	}
 	return rv
 }
@@ -25,6 +25,23 @@ const (
 	ErrorAliasMulti
 	ErrorAliasEmpty
 	ErrorUnknownIndexType
+	ErrorIndexSortUnsupported
+	ErrorBackupCorrupt
+	ErrorPointInTimeExists
+	ErrorUnknownPointInTime
+	ErrorCanceled
+	ErrorSeqConflict
+	ErrorSeqCorrupt
+	ErrorVersionConflict
+	ErrorRefreshUnsupported
+	ErrorIndexReadOnly
+	ErrorReadOnlyUnsupported
+	ErrorCheckUnsupported
+	ErrorIndexUpgradeRequired
+	ErrorAliasResharding
+	ErrorUnstoredFields
+	ErrorInternalKeyReserved
+	ErrorNestedSeparatorInID
 )
 
 // Error represents a more strongly typed bleve error for detecting
@@ -50,4 +67,21 @@ var errorMessages = map[int]string{
 	int(ErrorAliasMulti):                             "cannot perform single index operation on multiple index alias",
 	int(ErrorAliasEmpty):                             "cannot perform operation on empty alias",
 	int(ErrorUnknownIndexType):                       "unknown index type",
+	int(ErrorIndexSortUnsupported):                   "index type does not support sorting",
+	int(ErrorBackupCorrupt):                          "cannot restore index, backup corrupt",
+	int(ErrorPointInTimeExists):                      "point in time already exists",
+	int(ErrorUnknownPointInTime):                     "unknown point in time",
+	int(ErrorCanceled):                               "operation canceled",
+	int(ErrorSeqConflict):                            "document sequence number does not match",
+	int(ErrorSeqCorrupt):                             "document sequence number corrupt",
+	int(ErrorVersionConflict):                        "document version is not greater than the current version",
+	int(ErrorRefreshUnsupported):                     "index type does not support refresh intervals",
+	int(ErrorIndexReadOnly):                          "index is opened read only",
+	int(ErrorReadOnlyUnsupported):                    "index type does not support read only opening",
+	int(ErrorCheckUnsupported):                       "index type does not support checking",
+	int(ErrorIndexUpgradeRequired):                   "cannot open index, its format is outdated, see Upgrade",
+	int(ErrorAliasResharding):                        "alias is already being resharded",
+	int(ErrorUnstoredFields):                         "cannot update by query, the mapping indexes fields it does not store",
+	int(ErrorInternalKeyReserved):                    "internal keys starting with a zero byte are reserved",
+	int(ErrorNestedSeparatorInID):                    "document ids cannot contain a zero byte when the mapping has nested sections",
 }
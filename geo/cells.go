@@ -0,0 +1,141 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package geo
+
+// Shapes are indexed by the geohash cells covering them.
+// The cells of a shape are indexed with a LeafMarker, and
+// each of their prefixes as a term of its own, so that a
+// query finds the shapes in a cell or in a cell around it
+// from the cells covering the query shape.  Each shape has
+// the AllTerm as well.
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+const LeafMarker = "+"
+
+const AllTerm = "*"
+
+// MaxLevel is the length of the smallest cells used, about
+// 38 by 19 meters
+const MaxLevel = 8
+
+// The number of cells a shape or a query is covered with at
+// most, above that the cells are not split further
+const (
+	IndexCells = 256
+	QueryCells = 64
+)
+
+// cellRect returns the area of the geohash cell
+func cellRect(cell string) Rect {
+	rv := Rect{-180, -90, 180, 90}
+	even := true
+	for i := 0; i < len(cell); i++ {
+		bits := indexByte(geohashAlphabet, cell[i])
+		for mask := 16; mask > 0; mask >>= 1 {
+			if even {
+				mid := (rv.MinLon + rv.MaxLon) / 2
+				if bits&mask != 0 {
+					rv.MinLon = mid
+				} else {
+					rv.MaxLon = mid
+				}
+			} else {
+				mid := (rv.MinLat + rv.MaxLat) / 2
+				if bits&mask != 0 {
+					rv.MinLat = mid
+				} else {
+					rv.MaxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return rv
+}
+
+func indexByte(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			return i
+		}
+	}
+	return 0
+}
+
+// Cover returns geohash cells covering the shape, the cells
+// are split until they are MaxLevel long, or until there
+// would be more than max of them.  Cells entirely inside
+// the shape are not split.
+func (s *Shape) Cover(max int) []string {
+	var rv []string
+	partial := []string{""}
+	for level := 1; level <= MaxLevel && len(partial) > 0; level++ {
+		var full, split []string
+		for _, cell := range partial {
+			for i := 0; i < len(geohashAlphabet); i++ {
+				child := cell + geohashAlphabet[i:i+1]
+				rect := cellRect(child)
+				cellShape := &Shape{Polygons: []Polygon{rect.polygon()}}
+				if !cellShape.Intersects(s) {
+					continue
+				}
+				if cellShape.Within(s) {
+					full = append(full, child)
+				} else {
+					split = append(split, child)
+				}
+			}
+		}
+		if level > 1 && len(rv)+len(full)+len(split) > max {
+			break
+		}
+		rv = append(rv, full...)
+		partial = split
+	}
+	return append(rv, partial...)
+}
+
+// IndexTerms returns the terms the shape is indexed with
+func (s *Shape) IndexTerms() []string {
+	seen := map[string]bool{AllTerm: true}
+	rv := []string{AllTerm}
+	for _, cell := range s.Cover(IndexCells) {
+		rv = append(rv, cell+LeafMarker)
+		for i := 1; i <= len(cell); i++ {
+			if !seen[cell[:i]] {
+				seen[cell[:i]] = true
+				rv = append(rv, cell[:i])
+			}
+		}
+	}
+	return rv
+}
+
+// QueryTerms returns the terms of the shapes which may
+// intersect the shape, those in the cells covering it and
+// those in a larger cell around one of them
+func (s *Shape) QueryTerms() []string {
+	seen := make(map[string]bool)
+	var rv []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			rv = append(rv, term)
+		}
+	}
+	for _, cell := range s.Cover(QueryCells) {
+		add(cell)
+		for i := 1; i < len(cell); i++ {
+			add(cell[:i] + LeafMarker)
+		}
+	}
+	return rv
+}
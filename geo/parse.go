@@ -0,0 +1,404 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseShape reads a shape from GeoJSON, either decoded
+// into a map or as text, or from WKT.  Besides the GeoJSON
+// geometries an "envelope" type is understood, with the
+// coordinates of the upper left and lower right corners,
+// and ENVELOPE(minLon, maxLon, maxLat, minLat) in WKT.
+func ParseShape(value interface{}) (*Shape, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return parseGeoJSON(value)
+	case string:
+		text := strings.TrimSpace(value)
+		if strings.HasPrefix(text, "{") {
+			var geoJSON map[string]interface{}
+			err := json.Unmarshal([]byte(text), &geoJSON)
+			if err != nil {
+				return nil, err
+			}
+			return parseGeoJSON(geoJSON)
+		}
+		return parseWKT(text)
+	}
+	return nil, fmt.Errorf("cannot read a shape from %T", value)
+}
+
+func parseGeoJSON(geoJSON map[string]interface{}) (*Shape, error) {
+	typ, _ := geoJSON["type"].(string)
+	rv := &Shape{}
+	if strings.ToLower(typ) == "geometrycollection" {
+		geometries, ok := geoJSON["geometries"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("geometry collection without geometries")
+		}
+		for _, geometry := range geometries {
+			geometry, ok := geometry.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid geometry in geometry collection")
+			}
+			shape, err := parseGeoJSON(geometry)
+			if err != nil {
+				return nil, err
+			}
+			rv.Add(shape)
+		}
+		return rv, nil
+	}
+	coordinates := geoJSON["coordinates"]
+	var err error
+	switch strings.ToLower(typ) {
+	case "point":
+		var p Point
+		p, err = jsonPoint(coordinates)
+		rv.Points = []Point{p}
+	case "multipoint":
+		rv.Points, err = jsonPoints(coordinates)
+	case "linestring":
+		var line []Point
+		line, err = jsonLine(coordinates)
+		rv.Lines = [][]Point{line}
+	case "multilinestring":
+		err = jsonEach(coordinates, func(v interface{}) error {
+			line, err := jsonLine(v)
+			rv.Lines = append(rv.Lines, line)
+			return err
+		})
+	case "polygon":
+		var polygon Polygon
+		polygon, err = jsonPolygon(coordinates)
+		rv.Polygons = []Polygon{polygon}
+	case "multipolygon":
+		err = jsonEach(coordinates, func(v interface{}) error {
+			polygon, err := jsonPolygon(v)
+			rv.Polygons = append(rv.Polygons, polygon)
+			return err
+		})
+	case "envelope":
+		var corners []Point
+		corners, err = jsonPoints(coordinates)
+		if err == nil && len(corners) != 2 {
+			err = fmt.Errorf("an envelope needs 2 corners")
+		}
+		if err == nil {
+			rv.Polygons = []Polygon{envelope(corners[0].Lon, corners[1].Lon, corners[0].Lat, corners[1].Lat)}
+		}
+	default:
+		return nil, fmt.Errorf("unknown shape type '%s'", typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func jsonEach(v interface{}, f func(interface{}) error) error {
+	values, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("invalid coordinates")
+	}
+	for _, value := range values {
+		err := f(value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonPoint(v interface{}) (Point, error) {
+	values, ok := v.([]interface{})
+	if !ok || len(values) < 2 {
+		return Point{}, fmt.Errorf("invalid coordinates")
+	}
+	lon, lonOK := values[0].(float64)
+	lat, latOK := values[1].(float64)
+	if !lonOK || !latOK {
+		return Point{}, fmt.Errorf("invalid coordinates")
+	}
+	return newPoint(lon, lat)
+}
+
+func jsonPoints(v interface{}) ([]Point, error) {
+	var rv []Point
+	err := jsonEach(v, func(v interface{}) error {
+		p, err := jsonPoint(v)
+		rv = append(rv, p)
+		return err
+	})
+	return rv, err
+}
+
+func jsonLine(v interface{}) ([]Point, error) {
+	rv, err := jsonPoints(v)
+	if err == nil && len(rv) < 2 {
+		err = fmt.Errorf("a line needs at least 2 points")
+	}
+	return rv, err
+}
+
+func jsonPolygon(v interface{}) (Polygon, error) {
+	var rv Polygon
+	err := jsonEach(v, func(v interface{}) error {
+		points, err := jsonPoints(v)
+		if err != nil {
+			return err
+		}
+		ring, err := newRing(points)
+		rv = append(rv, ring)
+		return err
+	})
+	if err == nil && len(rv) == 0 {
+		err = fmt.Errorf("a polygon needs a ring")
+	}
+	return rv, err
+}
+
+func newPoint(lon, lat float64) (Point, error) {
+	if lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+		return Point{}, fmt.Errorf("coordinates out of range: %v, %v", lon, lat)
+	}
+	return Point{lon, lat}, nil
+}
+
+// newRing closes the ring if it is not closed
+func newRing(points []Point) ([]Point, error) {
+	if len(points) > 0 && points[0] != points[len(points)-1] {
+		points = append(points, points[0])
+	}
+	if len(points) < 4 {
+		return nil, fmt.Errorf("a ring needs at least 3 points")
+	}
+	return points, nil
+}
+
+func envelope(minLon, maxLon, maxLat, minLat float64) Polygon {
+	return Rect{minLon, minLat, maxLon, maxLat}.polygon()
+}
+
+// wktParser reads WKT one token at a time
+type wktParser struct {
+	tokens []string
+	next   int
+}
+
+func parseWKT(text string) (*Shape, error) {
+	p := &wktParser{
+		tokens: wktTokens(text),
+	}
+	rv, err := p.geometry()
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%s' in WKT", p.tokens[p.next])
+	}
+	return rv, nil
+}
+
+func wktTokens(text string) []string {
+	var rv []string
+	for i := 0; i < len(text); {
+		c := rune(text[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')' || c == ',':
+			rv = append(rv, text[i:i+1])
+			i++
+		default:
+			j := i
+			for j < len(text) && !unicode.IsSpace(rune(text[j])) && strings.IndexByte("(),", text[j]) < 0 {
+				j++
+			}
+			rv = append(rv, text[i:j])
+			i = j
+		}
+	}
+	return rv
+}
+
+func (p *wktParser) peek() string {
+	if p.next < len(p.tokens) {
+		return p.tokens[p.next]
+	}
+	return ""
+}
+
+func (p *wktParser) expect(token string) error {
+	if p.peek() != token {
+		return fmt.Errorf("expected '%s' in WKT, got '%s'", token, p.peek())
+	}
+	p.next++
+	return nil
+}
+
+// list reads a parenthesized, comma separated list
+func (p *wktParser) list(item func() error) error {
+	err := p.expect("(")
+	if err != nil {
+		return err
+	}
+	for {
+		err = item()
+		if err != nil {
+			return err
+		}
+		if p.peek() != "," {
+			break
+		}
+		p.next++
+	}
+	return p.expect(")")
+}
+
+func (p *wktParser) number() (float64, error) {
+	f, err := strconv.ParseFloat(p.peek(), 64)
+	if err != nil {
+		return 0, fmt.Errorf("expected a number in WKT, got '%s'", p.peek())
+	}
+	p.next++
+	return f, nil
+}
+
+// point reads the coordinates of a point, a third one is
+// left out
+func (p *wktParser) point() (Point, error) {
+	lon, err := p.number()
+	if err != nil {
+		return Point{}, err
+	}
+	lat, err := p.number()
+	if err != nil {
+		return Point{}, err
+	}
+	if _, err := strconv.ParseFloat(p.peek(), 64); err == nil {
+		p.next++
+	}
+	return newPoint(lon, lat)
+}
+
+func (p *wktParser) points() ([]Point, error) {
+	var rv []Point
+	err := p.list(func() error {
+		// a multipoint can have its points in parentheses
+		parenthesized := p.peek() == "("
+		if parenthesized {
+			p.next++
+		}
+		point, err := p.point()
+		if err != nil {
+			return err
+		}
+		rv = append(rv, point)
+		if parenthesized {
+			return p.expect(")")
+		}
+		return nil
+	})
+	return rv, err
+}
+
+func (p *wktParser) polygon() (Polygon, error) {
+	var rv Polygon
+	err := p.list(func() error {
+		points, err := p.points()
+		if err != nil {
+			return err
+		}
+		ring, err := newRing(points)
+		rv = append(rv, ring)
+		return err
+	})
+	return rv, err
+}
+
+func (p *wktParser) geometry() (*Shape, error) {
+	typ := strings.ToUpper(p.peek())
+	p.next++
+	rv := &Shape{}
+	if strings.ToUpper(p.peek()) == "EMPTY" {
+		p.next++
+		return rv, nil
+	}
+	var err error
+	switch typ {
+	case "POINT":
+		rv.Points, err = p.points()
+		if err == nil && len(rv.Points) != 1 {
+			err = fmt.Errorf("a point needs 1 coordinate")
+		}
+	case "MULTIPOINT":
+		rv.Points, err = p.points()
+	case "LINESTRING":
+		var line []Point
+		line, err = p.points()
+		if err == nil && len(line) < 2 {
+			err = fmt.Errorf("a line needs at least 2 points")
+		}
+		rv.Lines = [][]Point{line}
+	case "MULTILINESTRING":
+		err = p.list(func() error {
+			line, err := p.points()
+			if err == nil && len(line) < 2 {
+				err = fmt.Errorf("a line needs at least 2 points")
+			}
+			rv.Lines = append(rv.Lines, line)
+			return err
+		})
+	case "POLYGON":
+		var polygon Polygon
+		polygon, err = p.polygon()
+		rv.Polygons = []Polygon{polygon}
+	case "MULTIPOLYGON":
+		err = p.list(func() error {
+			polygon, err := p.polygon()
+			rv.Polygons = append(rv.Polygons, polygon)
+			return err
+		})
+	case "ENVELOPE":
+		var values []float64
+		err = p.list(func() error {
+			f, err := p.number()
+			values = append(values, f)
+			return err
+		})
+		if err == nil && len(values) != 4 {
+			err = fmt.Errorf("an envelope needs 4 numbers")
+		}
+		if err == nil {
+			rv.Polygons = []Polygon{envelope(values[0], values[1], values[2], values[3])}
+		}
+	case "GEOMETRYCOLLECTION":
+		err = p.list(func() error {
+			shape, err := p.geometry()
+			if err == nil {
+				rv.Add(shape)
+			}
+			return err
+		})
+	default:
+		return nil, fmt.Errorf("unknown WKT type '%s'", typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
@@ -0,0 +1,463 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// Package geo holds the shapes of geo_shape fields and the
+// relations between them.  Coordinates are longitude and
+// latitude in degrees, the shapes are treated as planar.
+package geo
+
+import (
+	"math"
+)
+
+// The relations a GeoShapeQuery can ask for between the
+// shape of a document and the shape of the query
+const (
+	Intersects = "intersects"
+	Disjoint   = "disjoint"
+	Within     = "within"
+	Contains   = "contains"
+)
+
+const epsilon = 1e-12
+
+type Point struct {
+	Lon float64
+	Lat float64
+}
+
+// A Polygon is an outer ring followed by its holes, each
+// ring is closed, its last point is its first
+type Polygon [][]Point
+
+// A Shape is any number of points, lines and polygons
+type Shape struct {
+	Points   []Point
+	Lines    [][]Point
+	Polygons []Polygon
+}
+
+// IsEmpty tells whether the shape has no parts
+func (s *Shape) IsEmpty() bool {
+	return len(s.Points) == 0 && len(s.Lines) == 0 && len(s.Polygons) == 0
+}
+
+// Add adds the parts of other to the shape
+func (s *Shape) Add(other *Shape) {
+	s.Points = append(s.Points, other.Points...)
+	s.Lines = append(s.Lines, other.Lines...)
+	s.Polygons = append(s.Polygons, other.Polygons...)
+}
+
+// A Rect is a rectangle on the map, like the cells shapes
+// are indexed in
+type Rect struct {
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
+func (r Rect) polygon() Polygon {
+	return Polygon{{
+		{r.MinLon, r.MinLat},
+		{r.MaxLon, r.MinLat},
+		{r.MaxLon, r.MaxLat},
+		{r.MinLon, r.MaxLat},
+		{r.MinLon, r.MinLat},
+	}}
+}
+
+// Bounds returns the smallest Rect holding the shape
+func (s *Shape) Bounds() Rect {
+	rv := Rect{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	add := func(p Point) {
+		rv.MinLon = math.Min(rv.MinLon, p.Lon)
+		rv.MinLat = math.Min(rv.MinLat, p.Lat)
+		rv.MaxLon = math.Max(rv.MaxLon, p.Lon)
+		rv.MaxLat = math.Max(rv.MaxLat, p.Lat)
+	}
+	for _, p := range s.Points {
+		add(p)
+	}
+	for _, line := range s.Lines {
+		for _, p := range line {
+			add(p)
+		}
+	}
+	for _, polygon := range s.Polygons {
+		if len(polygon) > 0 {
+			for _, p := range polygon[0] {
+				add(p)
+			}
+		}
+	}
+	return rv
+}
+
+func (r Rect) intersects(o Rect) bool {
+	return r.MinLon <= o.MaxLon && o.MinLon <= r.MaxLon && r.MinLat <= o.MaxLat && o.MinLat <= r.MaxLat
+}
+
+// Relate tells whether the relation holds between the
+// shape and other, the shape is within other when all of
+// it is inside other
+func (s *Shape) Relate(relation string, other *Shape) bool {
+	switch relation {
+	case Intersects:
+		return s.Intersects(other)
+	case Disjoint:
+		return !s.Intersects(other)
+	case Within:
+		return s.Within(other)
+	case Contains:
+		return other.Within(s)
+	}
+	return false
+}
+
+// Intersects tells whether the shapes have any point in
+// common
+func (s *Shape) Intersects(other *Shape) bool {
+	if !s.Bounds().intersects(other.Bounds()) {
+		return false
+	}
+	for _, p := range s.Points {
+		if other.containsPoint(p) {
+			return true
+		}
+	}
+	for _, line := range s.Lines {
+		if other.intersectsLine(line) {
+			return true
+		}
+	}
+	for _, polygon := range s.Polygons {
+		if other.intersectsPolygon(polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+// Within tells whether every part of the shape is inside of
+// a part of other
+func (s *Shape) Within(other *Shape) bool {
+	if s.IsEmpty() {
+		return false
+	}
+	for _, p := range s.Points {
+		if !other.containsPoint(p) {
+			return false
+		}
+	}
+	for _, line := range s.Lines {
+		if !other.containsLine(line) {
+			return false
+		}
+	}
+	for _, polygon := range s.Polygons {
+		if !other.containsPolygon(polygon) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Shape) containsPoint(p Point) bool {
+	for _, q := range s.Points {
+		if q == p {
+			return true
+		}
+	}
+	for _, line := range s.Lines {
+		if onLine(line, p) {
+			return true
+		}
+	}
+	for _, polygon := range s.Polygons {
+		if polygon.contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Shape) intersectsLine(line []Point) bool {
+	for _, p := range s.Points {
+		if onLine(line, p) {
+			return true
+		}
+	}
+	for _, other := range s.Lines {
+		if linesIntersect(line, other) {
+			return true
+		}
+	}
+	for _, polygon := range s.Polygons {
+		if polygon.intersectsLine(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Shape) intersectsPolygon(polygon Polygon) bool {
+	for _, p := range s.Points {
+		if polygon.contains(p) {
+			return true
+		}
+	}
+	for _, line := range s.Lines {
+		if polygon.intersectsLine(line) {
+			return true
+		}
+	}
+	for _, other := range s.Polygons {
+		if polygon.intersectsPolygon(other) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Shape) containsLine(line []Point) bool {
+	for _, other := range s.Lines {
+		all := true
+		for _, p := range line {
+			if !onLine(other, p) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	for _, polygon := range s.Polygons {
+		if polygon.containsLine(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Shape) containsPolygon(polygon Polygon) bool {
+	for _, other := range s.Polygons {
+		if other.containsPolygon(polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains tells whether p is inside the polygon or on its
+// boundary
+func (polygon Polygon) contains(p Point) bool {
+	if len(polygon) == 0 {
+		return false
+	}
+	for _, ring := range polygon {
+		if onLine(ring, p) {
+			return true
+		}
+	}
+	if !ringContains(polygon[0], p) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if ringContains(hole, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// interiorContains tells whether p is inside the polygon
+// and not on its boundary
+func (polygon Polygon) interiorContains(p Point) bool {
+	for _, ring := range polygon {
+		if onLine(ring, p) {
+			return false
+		}
+	}
+	return polygon.contains(p)
+}
+
+func (polygon Polygon) intersectsLine(line []Point) bool {
+	for _, p := range line {
+		if polygon.contains(p) {
+			return true
+		}
+	}
+	for _, ring := range polygon {
+		if linesIntersect(ring, line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (polygon Polygon) intersectsPolygon(other Polygon) bool {
+	if len(polygon) == 0 || len(other) == 0 {
+		return false
+	}
+	for _, p := range other[0] {
+		if polygon.contains(p) {
+			return true
+		}
+	}
+	for _, p := range polygon[0] {
+		if other.contains(p) {
+			return true
+		}
+	}
+	for _, ring := range polygon {
+		for _, otherRing := range other {
+			if linesIntersect(ring, otherRing) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsLine tells whether all of the line is inside the
+// polygon, its points and the middle of its segments are
+// inside and none of its segments crosses the boundary
+func (polygon Polygon) containsLine(line []Point) bool {
+	for i, p := range line {
+		if !polygon.contains(p) {
+			return false
+		}
+		if i > 0 {
+			mid := Point{(line[i-1].Lon + p.Lon) / 2, (line[i-1].Lat + p.Lat) / 2}
+			if !polygon.contains(mid) {
+				return false
+			}
+		}
+	}
+	for _, ring := range polygon {
+		if linesCross(ring, line) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsPolygon tells whether all of other is inside the
+// polygon, its outer ring is and no hole of the polygon is
+// inside of it
+func (polygon Polygon) containsPolygon(other Polygon) bool {
+	if len(polygon) == 0 || len(other) == 0 || !polygon.containsLine(other[0]) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		for _, p := range hole {
+			if other.interiorContains(p) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ringContains tells whether p is inside the closed ring,
+// points on the ring may or may not be
+func ringContains(ring []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func orientation(a, b, c Point) float64 {
+	return (b.Lon-a.Lon)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lon-a.Lon)
+}
+
+func sign(f float64) int {
+	if f > epsilon {
+		return 1
+	}
+	if f < -epsilon {
+		return -1
+	}
+	return 0
+}
+
+func onSegment(p, a, b Point) bool {
+	return sign(orientation(a, b, p)) == 0 &&
+		p.Lon >= math.Min(a.Lon, b.Lon)-epsilon && p.Lon <= math.Max(a.Lon, b.Lon)+epsilon &&
+		p.Lat >= math.Min(a.Lat, b.Lat)-epsilon && p.Lat <= math.Max(a.Lat, b.Lat)+epsilon
+}
+
+func onLine(line []Point, p Point) bool {
+	if len(line) == 1 {
+		return line[0] == p
+	}
+	for i := 1; i < len(line); i++ {
+		if onSegment(p, line[i-1], line[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentsIntersect tells whether the segments ab and cd
+// have a point in common
+func segmentsIntersect(a, b, c, d Point) bool {
+	o1 := sign(orientation(a, b, c))
+	o2 := sign(orientation(a, b, d))
+	o3 := sign(orientation(c, d, a))
+	o4 := sign(orientation(c, d, b))
+	if o1 != o2 && o3 != o4 && o1*o2 <= 0 && o3*o4 <= 0 {
+		return true
+	}
+	return onSegment(c, a, b) || onSegment(d, a, b) || onSegment(a, c, d) || onSegment(b, c, d)
+}
+
+// segmentsCross tells whether the segments ab and cd cross
+// each other at a point inside of both
+func segmentsCross(a, b, c, d Point) bool {
+	return sign(orientation(a, b, c))*sign(orientation(a, b, d)) < 0 &&
+		sign(orientation(c, d, a))*sign(orientation(c, d, b)) < 0
+}
+
+func linesIntersect(a, b []Point) bool {
+	if len(a) == 1 {
+		return onLine(b, a[0])
+	}
+	if len(b) == 1 {
+		return onLine(a, b[0])
+	}
+	for i := 1; i < len(a); i++ {
+		for j := 1; j < len(b); j++ {
+			if segmentsIntersect(a[i-1], a[i], b[j-1], b[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func linesCross(a, b []Point) bool {
+	for i := 1; i < len(a); i++ {
+		for j := 1; j < len(b); j++ {
+			if segmentsCross(a[i-1], a[i], b[j-1], b[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package geo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mustParse(t *testing.T, value interface{}) *Shape {
+	shape, err := ParseShape(value)
+	if err != nil {
+		t.Fatalf("error parsing %v: %v", value, err)
+	}
+	return shape
+}
+
+func TestParseShape(t *testing.T) {
+	square := Polygon{{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}}
+	tests := []struct {
+		input    interface{}
+		expected *Shape
+	}{
+		{
+			input:    "POINT (1.5 2)",
+			expected: &Shape{Points: []Point{{1.5, 2}}},
+		},
+		{
+			input:    "MULTIPOINT ((1 2), (3 4))",
+			expected: &Shape{Points: []Point{{1, 2}, {3, 4}}},
+		},
+		{
+			input:    "linestring (0 0, 1 1 5)",
+			expected: &Shape{Lines: [][]Point{{{0, 0}, {1, 1}}}},
+		},
+		{
+			input:    "POLYGON ((0 0, 10 0, 10 10, 0 10))",
+			expected: &Shape{Polygons: []Polygon{square}},
+		},
+		{
+			input:    "ENVELOPE (0, 10, 10, 0)",
+			expected: &Shape{Polygons: []Polygon{square}},
+		},
+		{
+			input:    "GEOMETRYCOLLECTION (POINT (1 2), LINESTRING (0 0, 1 1))",
+			expected: &Shape{Points: []Point{{1, 2}}, Lines: [][]Point{{{0, 0}, {1, 1}}}},
+		},
+		{
+			input: map[string]interface{}{
+				"type":        "Polygon",
+				"coordinates": []interface{}{[]interface{}{[]interface{}{0.0, 0.0}, []interface{}{10.0, 0.0}, []interface{}{10.0, 10.0}, []interface{}{0.0, 10.0}, []interface{}{0.0, 0.0}}},
+			},
+			expected: &Shape{Polygons: []Polygon{square}},
+		},
+		{
+			input:    `{"type": "envelope", "coordinates": [[0, 10], [10, 0]]}`,
+			expected: &Shape{Polygons: []Polygon{square}},
+		},
+		{
+			input:    `{"type": "MultiLineString", "coordinates": [[[0, 0], [1, 1]], [[2, 2], [3, 3]]]}`,
+			expected: &Shape{Lines: [][]Point{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}}},
+		},
+	}
+	for _, test := range tests {
+		actual := mustParse(t, test.input)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("expected %v for %v, got %v", test.expected, test.input, actual)
+		}
+	}
+
+	for _, input := range []interface{}{
+		"POINT (1)",
+		"POLYGON ((0 0, 1 1))",
+		"CIRCLE (0 0, 1)",
+		"POINT (200 0)",
+		"POINT (1 2) extra",
+		map[string]interface{}{"type": "Point", "coordinates": "here"},
+		3.0,
+	} {
+		if _, err := ParseShape(input); err == nil {
+			t.Errorf("expected an error parsing %v", input)
+		}
+	}
+}
+
+func TestRelate(t *testing.T) {
+	square := mustParse(t, "POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0))")
+	holed := mustParse(t, "POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (4 4, 6 4, 6 6, 4 6, 4 4))")
+	tests := []struct {
+		shape    string
+		other    *Shape
+		relation string
+		expected bool
+	}{
+		{"POINT (5 5)", square, Within, true},
+		{"POINT (5 5)", holed, Intersects, false},
+		{"POINT (10 5)", square, Intersects, true},
+		{"POINT (11 5)", square, Disjoint, true},
+		{"LINESTRING (-5 5, 5 5)", square, Intersects, true},
+		{"LINESTRING (-5 5, 5 5)", square, Within, false},
+		{"LINESTRING (1 1, 9 9)", square, Within, true},
+		{"LINESTRING (1 1, 9 9)", holed, Within, false},
+		{"LINESTRING (11 0, 11 10)", square, Intersects, false},
+		{"POLYGON ((2 2, 8 2, 8 8, 2 8, 2 2))", square, Within, true},
+		{"POLYGON ((2 2, 8 2, 8 8, 2 8, 2 2))", holed, Within, false},
+		{"POLYGON ((4.5 4.5, 5.5 4.5, 5.5 5.5, 4.5 5.5, 4.5 4.5))", holed, Intersects, false},
+		{"POLYGON ((-1 -1, 11 -1, 11 11, -1 11, -1 -1))", square, Contains, true},
+		{"POLYGON ((5 5, 15 5, 15 15, 5 15, 5 5))", square, Intersects, true},
+		{"POLYGON ((5 5, 15 5, 15 15, 5 15, 5 5))", square, Within, false},
+		{"POLYGON ((20 20, 30 20, 30 30, 20 30, 20 20))", square, Disjoint, true},
+		{"MULTIPOINT ((1 1), (20 20))", square, Within, false},
+		{"MULTIPOINT ((1 1), (20 20))", square, Intersects, true},
+	}
+	for _, test := range tests {
+		shape := mustParse(t, test.shape)
+		if actual := shape.Relate(test.relation, test.other); actual != test.expected {
+			t.Errorf("expected %s %s to be %t, got %t", test.shape, test.relation, test.expected, actual)
+		}
+	}
+}
+
+func TestCellTerms(t *testing.T) {
+	if rect := cellRect("u"); rect != (Rect{0, 45, 45, 90}) {
+		t.Errorf("expected cell u to be 0,45 to 45,90, got %v", rect)
+	}
+
+	point := mustParse(t, "POINT (-0.1275 51.5072)")
+	cells := point.Cover(IndexCells)
+	if len(cells) != 1 || len(cells[0]) != MaxLevel || cells[0][:4] != "gcpv" {
+		t.Errorf("expected one cell of the most precise level, got %v", cells)
+	}
+
+	shapes := []string{
+		"POINT (-0.1275 51.5072)",
+		"LINESTRING (-10 50, 10 52)",
+		"POLYGON ((-5 49, 5 49, 5 55, -5 55, -5 49))",
+		"POLYGON ((-50 -50, 50 -50, 50 50, -50 50, -50 -50))",
+	}
+	queries := []string{
+		"POINT (-0.1275 51.5072)",
+		"ENVELOPE (-1, 1, 52, 51)",
+		"POLYGON ((-60 -60, 60 -60, 60 60, -60 60, -60 -60))",
+		"ENVELOPE (100, 120, -10, -20)",
+	}
+	for _, s := range shapes {
+		shape := mustParse(t, s)
+		indexed := make(map[string]bool)
+		for _, term := range shape.IndexTerms() {
+			indexed[term] = true
+		}
+		for _, q := range queries {
+			query := mustParse(t, q)
+			found := false
+			for _, term := range query.QueryTerms() {
+				if indexed[term] {
+					found = true
+					break
+				}
+			}
+			if shape.Intersects(query) && !found {
+				t.Errorf("expected the terms of %s to find %s", q, s)
+			}
+		}
+	}
+}
@@ -104,6 +104,7 @@ func AnalyzerText(w http.ResponseWriter, req *http.Request) {
 		Analyzer string              `json:"analyzer"`
 		Text     string              `json:"text"`
 		Mapping  *bleve.IndexMapping `json:"mapping"`
+		Stages   bool                `json:"stages"`
 	}{}
 
 	err = json.Unmarshal(requestBody, &analyzeRequest)
@@ -116,20 +117,28 @@ func AnalyzerText(w http.ResponseWriter, req *http.Request) {
 		mapping = analyzeRequest.Mapping
 	}
 
-	ts, err := mapping.AnalyzeText(analyzeRequest.Analyzer, []byte(analyzeRequest.Text))
+	var ts analysis.TokenStream
+	var stages []*analysis.AnalysisStage
+	if analyzeRequest.Stages {
+		ts, stages, err = mapping.AnalyzeTextStages(analyzeRequest.Analyzer, []byte(analyzeRequest.Text))
+	} else {
+		ts, err = mapping.AnalyzeText(analyzeRequest.Analyzer, []byte(analyzeRequest.Text))
+	}
 	if err != nil {
 		showError(w, req, fmt.Sprintf("error analyzing text: %v", err), 400)
 		return
 	}
 
 	rv := struct {
-		Status      string               `json:"status"`
-		Text        string               `json:"text"`
-		TokenStream analysis.TokenStream `json:"token_stream"`
+		Status      string                    `json:"status"`
+		Text        string                    `json:"text"`
+		TokenStream analysis.TokenStream      `json:"token_stream"`
+		Stages      []*analysis.AnalysisStage `json:"stages,omitempty"`
 	}{
 		Status:      "ok",
 		Text:        analyzeRequest.Text,
 		TokenStream: ts,
+		Stages:      stages,
 	}
 	mustEncode(w, rv)
 }
@@ -10,6 +10,10 @@
 package bleve
 
 import (
+	"io"
+	"time"
+
+	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/document"
 	"github.com/blevesearch/bleve/index"
 	"github.com/blevesearch/bleve/index/store"
@@ -24,6 +28,7 @@ import (
 type Batch struct {
 	index    Index
 	internal *index.Batch
+	versions map[string]uint64
 }
 
 // Index adds the specified index operation to the
@@ -36,6 +41,21 @@ func (b *Batch) Index(id string, data interface{}) error {
 		return err
 	}
 	b.internal.Update(doc)
+	delete(b.versions, id)
+	return nil
+}
+
+// IndexVersion adds an index operation with an external
+// version to the batch.  The operation is skipped unless
+// version is greater than the version of the document,
+// executing the batch then returns a *VersionConflictError
+// naming it.
+func (b *Batch) IndexVersion(id string, data interface{}, version uint64) error {
+	err := b.Index(id, data)
+	if err != nil {
+		return err
+	}
+	b.setVersion(id, version)
 	return nil
 }
 
@@ -44,11 +64,28 @@ func (b *Batch) Index(id string, data interface{}) error {
 // the batch is executed.
 func (b *Batch) Delete(id string) {
 	b.internal.Delete(id)
+	delete(b.versions, id)
+}
+
+// DeleteVersion adds a delete operation with an external
+// version to the batch, see IndexVersion.
+func (b *Batch) DeleteVersion(id string, version uint64) {
+	b.Delete(id)
+	b.setVersion(id, version)
+}
+
+func (b *Batch) setVersion(id string, version uint64) {
+	if b.versions == nil {
+		b.versions = make(map[string]uint64)
+	}
+	b.versions[id] = version
 }
 
 // SetInternal adds the specified set internal
 // operation to the batch. NOTE: the bleve Index is
-// not updated until the batch is executed.
+// not updated until the batch is executed.  Keys starting
+// with a zero byte are reserved, a batch setting one fails
+// with ErrorInternalKeyReserved.
 func (b *Batch) SetInternal(key, val []byte) {
 	b.internal.SetInternal(key, val)
 }
@@ -76,6 +113,7 @@ func (b *Batch) String() string {
 // be re-used in the future.
 func (b *Batch) Reset() {
 	b.internal.Reset()
+	b.versions = nil
 }
 
 // An Index implements all the indexing and searching
@@ -85,14 +123,49 @@ type Index interface {
 	Index(id string, data interface{}) error
 	Delete(id string) error
 
+	// Seq returns the sequence number of a document, which
+	// changes whenever the document is indexed.  IndexIfSeq
+	// and DeleteIfSeq fail with ErrorSeqConflict unless the
+	// document still has the sequence number ifSeq, an
+	// ifSeq of 0 only matches documents which do not exist.
+	Seq(id string) (uint64, error)
+	IndexIfSeq(id string, data interface{}, ifSeq uint64) (uint64, error)
+	DeleteIfSeq(id string, ifSeq uint64) error
+
+	// Version returns the external version of a document,
+	// as given to IndexVersion or DeleteVersion.  They fail
+	// with ErrorVersionConflict unless version is greater
+	// than the version of the document, so that a stream of
+	// changes can be replayed safely.
+	Version(id string) (uint64, error)
+	IndexVersion(id string, data interface{}, version uint64) error
+	DeleteVersion(id string, version uint64) error
+
+	// DeleteByQuery deletes all documents matching q and
+	// returns how many it deleted.  Closing cancel stops
+	// it between batches, a nil cancel never does.
+	DeleteByQuery(q Query, cancel <-chan struct{}) (uint64, error)
+
+	// UpdateByQuery applies the update function of req to
+	// all documents matching its query.  The documents are
+	// rebuilt from their stored fields, so it fails with
+	// ErrorUnstoredFields when the mapping indexes fields
+	// without storing them.
+	UpdateByQuery(req *UpdateByQueryRequest) (*UpdateByQueryResult, error)
+
 	NewBatch() *Batch
 	Batch(b *Batch) error
 
 	Document(id string) (*document.Document, error)
+	// DocCount leaves out the hidden documents of nested
+	// sections, as searches do.
 	DocCount() (uint64, error)
 
 	Search(req *SearchRequest) (*SearchResult, error)
 
+	Analyze(fieldOrAnalyzer string, text []byte) (analysis.TokenStream, error)
+	AnalyzeStages(fieldOrAnalyzer string, text []byte) ([]*analysis.AnalysisStage, error)
+
 	Fields() ([]string, error)
 
 	FieldDict(field string) (index.FieldDict, error)
@@ -103,12 +176,39 @@ type Index interface {
 	DumpDoc(id string) chan interface{}
 	DumpFields() chan interface{}
 
+	ForceMerge(maxSegments int) error
+
+	// Backup writes a consistent copy of the index to w
+	// while updates continue, the mapping and internal
+	// values are part of the copy
+	Backup(w io.Writer) error
+
+	// OpenPointInTime holds on to the current state of the
+	// index under name, until ClosePointInTime is called or
+	// the index is closed.  Searches naming it in their
+	// PointInTime see that state, as far as the readers
+	// of the KVStore are isolated from later writes.
+	OpenPointInTime(name string) error
+	ClosePointInTime(name string) error
+
+	// SetRefreshInterval controls when changes become
+	// visible to searches: immediately with 0, the default,
+	// once per interval, or only on Refresh with
+	// RefreshManual.  Indexes which do not support it fail
+	// with ErrorRefreshUnsupported for anything but 0.
+	SetRefreshInterval(interval time.Duration) error
+	// Refresh makes all changes visible to searches
+	Refresh() error
+
 	Close() error
 
 	Mapping() *IndexMapping
 
 	Stats() *IndexStat
 
+	// SetInternal and DeleteInternal fail with
+	// ErrorInternalKeyReserved for keys starting with a
+	// zero byte, bleve keeps its own values under them.
 	GetInternal(key []byte) ([]byte, error)
 	SetInternal(key, val []byte) error
 	DeleteInternal(key []byte) error
@@ -147,6 +247,48 @@ func Open(path string) (Index, error) {
 	return openIndexUsing(path, nil)
 }
 
+// OpenReadOnly opens the index at the specified path, which
+// must exist, for searching only.  No locks for writing are
+// taken and no background work which writes is started, so
+// that several processes can open the same index as long
+// as none of them changes it.  Changes of an index opened
+// this way return ErrorIndexReadOnly.  Indexes whose kv
+// store cannot be opened read only, like the memory
+// stores, return ErrorReadOnlyUnsupported.
+func OpenReadOnly(path string) (Index, error) {
+	return openIndexUsing(path, map[string]interface{}{
+		"read_only": true,
+	})
+}
+
+// Upgrade brings the data of the index at the specified
+// path, which must exist and must not be open, up to date
+// with the format of this version of bleve.  An index of
+// an older format cannot be opened otherwise, opening it
+// returns ErrorIndexUpgradeRequired.  The data is rewritten
+// in place, see UpgradeCopy to keep the original.  Opening
+// with the "upgrade" runtime config does the same.
+func Upgrade(path string) error {
+	return upgradeIndex(path)
+}
+
+// UpgradeCopy copies the index at the specified path to
+// newPath, which must not already exist, and upgrades the
+// copy, leaving the original as it was.
+func UpgradeCopy(path, newPath string) error {
+	return upgradeIndexCopy(path, newPath)
+}
+
+// Restore creates an index at the specified path, which
+// must not already exist, from a backup written by
+// Index.Backup.  The restored index has the mapping and
+// internal values of the index backed up.  The backup of
+// an in memory index is restored using the default
+// kvstore.
+func Restore(r io.Reader, path string) (Index, error) {
+	return restoreIndex(r, path)
+}
+
 // OpenUsing opens index at the specified path, must exist.
 // The mapping used when it was created will be used for all Index/Search operations.
 // The provided runtimeConfig can override settings
@@ -0,0 +1,45 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package index
+
+import (
+	"github.com/blevesearch/bleve/index/store"
+)
+
+// A BackupWriter receives the contents of an index being
+// backed up.  The key/value pairs are what the index holds
+// in its KVStore, the files what it holds in the directory
+// it was given as a DirectoryIndex.
+type BackupWriter interface {
+	WriteKV(key, val []byte) error
+	WriteFile(name string, data []byte) error
+}
+
+// BackupKVReader writes all key/value pairs visible to
+// kvreader to w
+func BackupKVReader(kvreader store.KVReader, w BackupWriter) (err error) {
+	it := kvreader.Iterator([]byte{})
+	defer func() {
+		if cerr := it.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	key, val, valid := it.Current()
+	for valid {
+		err = w.WriteKV(key, val)
+		if err != nil {
+			return
+		}
+		it.Next()
+		key, val, valid = it.Current()
+	}
+	return
+}
@@ -0,0 +1,70 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package index
+
+import (
+	"fmt"
+)
+
+// The kinds of problems Check finds
+const (
+	// a row or file which cannot be decoded
+	ProblemCorrupt = "corrupt"
+	// data which does not match its checksum
+	ProblemChecksum = "checksum"
+	// a row of a document or term which is not there
+	ProblemDangling = "dangling"
+	// a row which a document or term refers to is missing
+	ProblemMissing = "missing"
+	// a count which does not match what it counts
+	ProblemCount = "count"
+	// a document which is in the index more than once
+	ProblemDuplicate = "duplicate"
+)
+
+// A Problem is an inconsistency found in an index, Repaired
+// tells whether it was fixed
+type Problem struct {
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+	Repaired    bool   `json:"repaired"`
+}
+
+// CheckResult lists the problems found by Check
+type CheckResult struct {
+	Problems []*Problem `json:"problems"`
+}
+
+// Add records a problem
+func (r *CheckResult) Add(kind string, repaired bool, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, &Problem{
+		Kind:        kind,
+		Description: fmt.Sprintf(format, args...),
+		Repaired:    repaired,
+	})
+}
+
+// Unrepaired counts the problems which were not fixed
+func (r *CheckResult) Unrepaired() int {
+	rv := 0
+	for _, p := range r.Problems {
+		if !p.Repaired {
+			rv++
+		}
+	}
+	return rv
+}
+
+// A CheckableIndex scans all of its data for corruption.
+// With repair it fixes the problems it can fix without
+// losing documents, changes are held back while it runs.
+type CheckableIndex interface {
+	Check(repair bool) (*CheckResult, error)
+}
@@ -10,8 +10,8 @@
 package index
 
 import (
-	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/blevesearch/bleve/document"
 )
@@ -35,9 +35,62 @@ type Index interface {
 
 	Reader() (IndexReader, error)
 
-	Stats() json.Marshaler
+	Stats() (*Stats, error)
 
 	Analyze(d *document.Document) *AnalysisResult
+
+	// ForceMerge compacts the index for read-mostly use,
+	// indexes made of segments merge them down to at most
+	// maxSegments
+	ForceMerge(maxSegments int) error
+
+	// Backup writes a consistent copy of the index to w,
+	// updates may continue while it runs
+	Backup(w BackupWriter) error
+}
+
+// A DirectoryIndex keeps data in files of its own next to
+// the KVStore, it is given a directory for them before it
+// is opened.  In memory indexes are not given one.
+type DirectoryIndex interface {
+	SetDirectory(path string)
+}
+
+// A ConfigurableIndex takes settings from the config the
+// index was created or opened with, it is given the config
+// before it is opened.
+type ConfigurableIndex interface {
+	SetConfig(config map[string]interface{}) error
+}
+
+// A ReadOnlyIndex can be opened without writing, and
+// without starting background work which writes, so that
+// several processes can read the same index.  It is set
+// read only before it is opened.
+type ReadOnlyIndex interface {
+	SetReadOnly()
+}
+
+// ErrUpgradeRequired is returned by Open when the data is
+// of an older version of the format of the index, which an
+// UpgradableIndex can bring up to date
+var ErrUpgradeRequired = fmt.Errorf("index data is of an older version, it needs an upgrade")
+
+// An UpgradableIndex can rewrite the data of older versions
+// of its format.  Upgrade is called instead of Open and
+// leaves the index closed, it does nothing when the data is
+// up to date.
+type UpgradableIndex interface {
+	Upgrade() error
+}
+
+// A RefreshableIndex can hold changes back from new
+// readers until it is refreshed, see SetRefreshInterval of
+// the segmented index.  Indexes which are not refreshable
+// make every change visible as soon as it is made.
+type RefreshableIndex interface {
+	SetRefreshInterval(interval time.Duration)
+	Refresh() error
 }
 
 type IndexReader interface {
@@ -50,6 +103,12 @@ type IndexReader interface {
 
 	Document(id string) (*document.Document, error)
 	DocumentFieldTerms(id string) (FieldTerms, error)
+	// DocumentTermVectors returns the terms of a document
+	// in fields, or in all of its fields when fields is
+	// nil, along with the vectors the terms were indexed
+	// with
+	DocumentTermVectors(id string, fields []string) (FieldTermVectors, error)
+	DocValueReader(fields []string) (DocValueReader, error)
 
 	Fields() ([]string, error)
 
@@ -60,14 +119,91 @@ type IndexReader interface {
 	Close() error
 }
 
+// A ReopenableIndexReader can be brought up to date with
+// its index.  Reopen returns a reader of the current state
+// of the index which shares what did not change with this
+// one, or nil when nothing changed since this reader was
+// opened.  This reader stays open either way.
+type ReopenableIndexReader interface {
+	Reopen() (IndexReader, error)
+}
+
+// An Automaton matches terms one byte at a time.  Accept
+// gives the state after a byte, and CanMatch whether a
+// term through the state can still match, so that
+// dictionaries kept as automata themselves can pass over
+// all the terms starting with bytes which cannot.
+type Automaton interface {
+	Start() int
+	IsMatch(state int) bool
+	CanMatch(state int) bool
+	Accept(state int, b byte) int
+}
+
+// An AutomatonIndexReader walks only the terms of a field
+// which an Automaton matches.
+type AutomatonIndexReader interface {
+	FieldDictAutomaton(field string, automaton Automaton) (FieldDict, error)
+}
+
+// A DocSetIndexReader gives the documents with a term as a
+// DocSet.  Readers which keep bitmaps of the documents of
+// their terms combine these sets much faster than
+// searchers can advance TermFieldReaders over each other.
+type DocSetIndexReader interface {
+	TermDocSet(term []byte, field string) (DocSet, error)
+}
+
+// A DocSet is a set of documents of one IndexReader, it
+// can only be combined with other sets of the same reader.
+type DocSet interface {
+	Count() uint64
+	Intersect(other DocSet) DocSet
+	Union(other DocSet) DocSet
+	// DocIDReader returns the ids of the documents of
+	// the set in order
+	DocIDReader() DocIDReader
+}
+
 type FieldTerms map[string][]string
 
+// A TermVector gives where a term occurs in a field of a
+// document, Vectors is empty unless the field was indexed
+// with term vectors.
+type TermVector struct {
+	Term    string
+	Freq    uint64
+	Vectors []*TermFieldVector
+}
+
+// FieldTermVectors holds the term vectors of a document by
+// field, sorted by term
+type FieldTermVectors map[string][]*TermVector
+
+// TermVectorsByTerm sorts term vectors by their term
+type TermVectorsByTerm []*TermVector
+
+func (t TermVectorsByTerm) Len() int           { return len(t) }
+func (t TermVectorsByTerm) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t TermVectorsByTerm) Less(i, j int) bool { return t[i].Term < t[j].Term }
+
+type DocValueVisitor func(field string, term []byte)
+
+// A DocValueReader gives the terms documents were indexed
+// with in a set of fields.  Fields indexed with doc values
+// are read from their columns, for other fields the terms
+// are recovered from the index, which is slower.
+type DocValueReader interface {
+	VisitDocValues(id string, visitor DocValueVisitor) error
+}
+
 type TermFieldVector struct {
 	Field          string
 	ArrayPositions []uint64
 	Pos            uint64
 	Start          uint64
 	End            uint64
+	Payload        []byte
 }
 
 type TermFieldDoc struct {
@@ -101,6 +237,26 @@ type DocIDReader interface {
 	Close() error
 }
 
+// A SortedIndex can keep its documents in the order of a
+// field, it is given the field before it is opened.
+type SortedIndex interface {
+	SetSortField(field string)
+}
+
+// A SortedIndexReader is a reader of a SortedIndex, its
+// SortedDocIDReader returns the ids of all documents in
+// the order of SortField.  Documents without a value for
+// the field come last, ties are broken by id.
+type SortedIndexReader interface {
+	SortField() string
+	SortedDocIDReader() (SortedDocIDReader, error)
+}
+
+type SortedDocIDReader interface {
+	Next() (string, error)
+	Close() error
+}
+
 type Batch struct {
 	IndexOps    map[string]*document.Document
 	InternalOps map[string][]byte
@@ -0,0 +1,65 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"path/filepath"
+
+	"github.com/blevesearch/bleve/index"
+)
+
+// Backup copies the segments of the current snapshot
+// along with the store, both are taken together so that
+// the list of segments in the store matches the snapshot.
+// The snapshot keeps the segment files from being removed
+// while they are copied, the store reader is closed before
+// that so as not to hold up writers of the store.  The
+// segments in the write-ahead log are checkpointed first.
+func (s *Segmented) Backup(w index.BackupWriter) (err error) {
+	err = s.checkpoint()
+	if err != nil {
+		return
+	}
+	s.writeMutex.Lock()
+	snapshot := s.currentSnapshot()
+	kvreader, err := s.store.Reader()
+	s.writeMutex.Unlock()
+	defer func() {
+		if cerr := snapshot.decRef(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	if err != nil {
+		return
+	}
+
+	err = index.BackupKVReader(kvreader, w)
+	if cerr := kvreader.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	if s.path == "" {
+		return
+	}
+	for _, segment := range snapshot.segments {
+		var data []byte
+		data, err = s.segmentFileData(segment.file)
+		if err != nil {
+			return
+		}
+		err = w.WriteFile(filepath.Base(s.segmentPath(segment.file.id)), data)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
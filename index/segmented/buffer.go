@@ -0,0 +1,82 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// rough per entry overheads of an analyzed document, the
+// sizes of the structs and slice headers holding them
+const (
+	docOverhead      = 96
+	storedOverhead   = 80
+	termOverhead     = 88
+	vectorOverhead   = 80
+	docValueOverhead = 56
+)
+
+// SetIndexBufferSize limits the memory held by the
+// analyzed documents of a batch to about size bytes.  Once
+// the buffered documents reach the limit they are flushed
+// to a segment of their own, and the segments of a batch
+// become part of the index together.  With 0, the default,
+// every batch is flushed as a single segment.  A larger
+// buffer means fewer, larger flushes and less merging.
+func (s *Segmented) SetIndexBufferSize(size uint64) {
+	atomic.StoreUint64(&s.indexBufferSize, size)
+}
+
+// IndexBufferSize returns the limit set by
+// SetIndexBufferSize
+func (s *Segmented) IndexBufferSize() uint64 {
+	return atomic.LoadUint64(&s.indexBufferSize)
+}
+
+// size estimates the memory held by the analyzed document
+func (d *segmentDoc) size() uint64 {
+	rv := uint64(docOverhead + len(d.id))
+	for _, field := range d.stored {
+		rv += uint64(storedOverhead + len(field.field) + len(field.value) + 8*len(field.arrayPositions))
+	}
+	for _, term := range d.terms {
+		rv += uint64(termOverhead + len(term.field) + len(term.term))
+		for _, vector := range term.vectors {
+			rv += uint64(vectorOverhead + len(vector.Field) + 8*len(vector.ArrayPositions) + len(vector.Payload))
+		}
+	}
+	for _, docValue := range d.docValues {
+		rv += uint64(docValueOverhead + len(docValue.field))
+		for _, term := range docValue.terms {
+			rv += uint64(16 + len(term))
+		}
+	}
+	return rv
+}
+
+// indexBufferSizeFromConfig reads the "index_buffer_size"
+// of the index config in bytes
+func indexBufferSizeFromConfig(config map[string]interface{}) (uint64, bool, error) {
+	v, ok := config["index_buffer_size"]
+	if !ok {
+		return 0, false, nil
+	}
+	size, ok := v.(float64)
+	if !ok {
+		if i, isInt := v.(int); isInt {
+			size, ok = float64(i), true
+		}
+	}
+	if !ok || size < 0 {
+		return 0, false, fmt.Errorf("index_buffer_size must be a number of bytes")
+	}
+	return uint64(size), true, nil
+}
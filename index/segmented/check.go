@@ -0,0 +1,115 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"fmt"
+	"hash/crc32"
+
+	"github.com/blevesearch/bleve/index"
+)
+
+// Check decodes every segment of the current snapshot and
+// compares it with its checksum, and looks for documents
+// which are live in more than one segment.  Segments never
+// change once written, so there is nothing to repair in
+// place, corrupt ones have to be restored from a backup or
+// a replica.
+func (s *Segmented) Check(repair bool) (*index.CheckResult, error) {
+	if repair && s.readOnly {
+		return nil, ErrReadOnly
+	}
+	snapshot := s.currentSnapshot()
+	defer func() {
+		_ = snapshot.decRef()
+	}()
+
+	rv := &index.CheckResult{}
+	live := make(map[string]uint64)
+	for _, segment := range snapshot.segments {
+		file := segment.file
+		if file.checksum != 0 && crc32.ChecksumIEEE(file.data) != file.checksum {
+			rv.Add(index.ProblemChecksum, false, "segment %d does not match its checksum", file.id)
+		}
+		err := checkSegment(file.segment)
+		if err != nil {
+			rv.Add(index.ProblemCorrupt, false, "segment %d is corrupt: %v", file.id, err)
+			continue
+		}
+		for docNum := 0; docNum < file.numDocs; docNum++ {
+			if !segment.live(docNum) {
+				continue
+			}
+			id := file.docID(docNum)
+			if other, ok := live[id]; ok {
+				rv.Add(index.ProblemDuplicate, false, "document %q is in segments %d and %d", id, other, file.id)
+			}
+			live[id] = file.id
+		}
+	}
+	return rv, nil
+}
+
+// checkSegment decodes all documents, doc values and
+// postings of the segment.  Offsets out of the bounds of
+// the data end up in a panic, which is turned into an
+// error.
+func checkSegment(seg *segment) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	prev := ""
+	for docNum := 0; docNum < seg.numDocs; docNum++ {
+		doc, err := seg.document(docNum)
+		if err != nil {
+			return err
+		}
+		if docNum > 0 && doc.id <= prev {
+			return fmt.Errorf("document %q is out of order", doc.id)
+		}
+		prev = doc.id
+		for f := range seg.columns {
+			_, _, err = seg.docValues(docNum, seg.fields[f])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, field := range seg.fields {
+		terms, err := seg.terms(field, nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		entry, err := terms.next()
+		for entry != nil {
+			postings := seg.postings(entry)
+			count := uint64(0)
+			p, perr := postings.next()
+			for p != nil {
+				count++
+				p, perr = postings.next()
+			}
+			if perr != nil {
+				return perr
+			}
+			if count != entry.count {
+				return fmt.Errorf("term %q of field %s has %d postings, expected %d", entry.term, field, count, entry.count)
+			}
+			entry, err = terms.next()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
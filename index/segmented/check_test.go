@@ -0,0 +1,101 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+)
+
+func TestCheck(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err := os.MkdirAll("test", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join("test", "index")
+	analysisQueue := index.NewAnalysisQueue(1)
+	defer analysisQueue.Close()
+	idx := openTestIndex(t, analysisQueue, dir)
+	for i := 0; i < 3; i++ {
+		doc := document.NewDocument(strconv.Itoa(i))
+		doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{}, []byte("test"), document.IndexField|document.StoreField|document.IncludeDocValues))
+		err = idx.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the checksums are kept in the manifest
+	idx = openTestIndex(t, analysisQueue, dir)
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	result, err := idx.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Problems) != 0 {
+		t.Fatalf("expected no problems, got %v", result.Problems)
+	}
+	for _, segment := range idx.root.segments {
+		if segment.file.checksum == 0 {
+			t.Errorf("expected checksum of segment %d", segment.file.id)
+		}
+	}
+
+	idx.root.segments[0].file.checksum++
+	result, err = idx.Check(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Problems) != 1 || result.Problems[0].Kind != index.ProblemChecksum || result.Problems[0].Repaired {
+		t.Errorf("expected an unrepaired checksum problem, got %v", result.Problems)
+	}
+	idx.root.segments[0].file.checksum--
+
+	// a corrupt term dictionary
+	seg := idx.root.segments[0].file.segment
+	data := make([]byte, len(seg.data))
+	copy(data, seg.data)
+	corrupt, err := loadSegment(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkSegment(corrupt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := len(segmentMagic); i < corrupt.docTable; i++ {
+		data[i] = 0xff
+	}
+	err = checkSegment(corrupt)
+	if err == nil {
+		t.Errorf("expected corrupt segment")
+	}
+}
@@ -0,0 +1,148 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"github.com/blevesearch/bleve/index"
+)
+
+// fieldDict is the dictionary of a field in a segment, the
+// entries of its terms in order along with an fst of the
+// terms to the offsets of their entries.  The dictionaries
+// of segments before the fifth version are converted when
+// the segment is loaded.
+type fieldDict struct {
+	numTerms uint64
+	entries  []byte
+	fst      *fst
+}
+
+// encodeFieldDict encodes the entries of a dictionary,
+// which have to be in term order
+func encodeFieldDict(entries []*dictEntry) (entryData []byte, fstData []byte) {
+	e := encoder{}
+	b := newFSTBuilder()
+	for _, entry := range entries {
+		b.add([]byte(entry.term), e.offset())
+		e.uvarint(entry.offset)
+		e.uvarint(entry.count)
+		e.uvarint(entry.skip)
+		e.uvarint(entry.bitmap)
+	}
+	return e.buf.Bytes(), b.finish()
+}
+
+func (s *segment) loadFieldDict(numTerms uint64, entries, fstData []byte) (*fieldDict, error) {
+	f, err := loadFST(fstData)
+	if err != nil {
+		return nil, err
+	}
+	rv := fieldDict{
+		numTerms: numTerms,
+		entries:  entries,
+		fst:      f,
+	}
+	// the entries are checked once, the fst as it is read
+	offset := uint64(0)
+	for i := uint64(0); i < numTerms; i++ {
+		_, offset, err = s.decodeEntry(&rv, "", offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if offset != uint64(len(entries)) {
+		return nil, ErrCorruptSegment
+	}
+	return &rv, nil
+}
+
+// decodeEntry decodes the entry at offset, it returns the
+// offset of the entry after it
+func (s *segment) decodeEntry(dict *fieldDict, term string, offset uint64) (*dictEntry, uint64, error) {
+	if offset >= uint64(len(dict.entries)) {
+		return nil, 0, ErrCorruptSegment
+	}
+	d := decoder{data: dict.entries, pos: int(offset)}
+	rv := dictEntry{
+		term:   term,
+		offset: d.uvarint(),
+		count:  d.uvarint(),
+		skip:   d.uvarint(),
+		bitmap: d.uvarint(),
+	}
+	end := uint64(s.docTable)
+	if d.err != nil || rv.offset >= end ||
+		(rv.skip > 0 && (rv.count < 2*skipInterval || rv.skip+16*rv.numSkips() > end)) ||
+		(rv.bitmap > 0 && rv.bitmap+8*((uint64(s.numDocs)+63)/64) > end) {
+		return nil, 0, ErrCorruptSegment
+	}
+	return &rv, uint64(d.pos), nil
+}
+
+func (s *segment) fieldDict(field string) *fieldDict {
+	f, ok := s.fieldIndexes[field]
+	if !ok {
+		return nil
+	}
+	return s.dicts[f]
+}
+
+// dictEntry returns the entry of the term, or nil when the
+// field has no such term
+func (s *segment) dictEntry(field, term string) (*dictEntry, error) {
+	dict := s.fieldDict(field)
+	if dict == nil {
+		return nil, nil
+	}
+	offset, ok, err := dict.fst.get([]byte(term))
+	if err != nil || !ok {
+		return nil, err
+	}
+	rv, _, err := s.decodeEntry(dict, term, offset)
+	return rv, err
+}
+
+// termIterator walks the entries of the terms of a field
+type termIterator struct {
+	segment *segment
+	dict    *fieldDict
+	terms   *fstIterator
+}
+
+// terms walks the terms of the field from start up to end
+// when they are not nil, only those which the automaton
+// matches when there is one
+func (s *segment) terms(field string, start, end []byte, automaton index.Automaton) (*termIterator, error) {
+	rv := termIterator{
+		segment: s,
+		dict:    s.fieldDict(field),
+	}
+	if rv.dict != nil {
+		var err error
+		rv.terms, err = rv.dict.fst.iterator(start, end, automaton)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &rv, nil
+}
+
+// next returns the next entry, or nil at the end
+func (i *termIterator) next() (*dictEntry, error) {
+	if i.terms == nil {
+		return nil, nil
+	}
+	term, offset, ok, err := i.terms.next()
+	if err != nil || !ok {
+		return nil, err
+	}
+	rv, _, err := i.segment.decodeEntry(i.dict, string(term), offset)
+	return rv, err
+}
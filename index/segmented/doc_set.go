@@ -0,0 +1,160 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"github.com/blevesearch/bleve/index"
+	"github.com/willf/bitset"
+)
+
+// SegmentedDocSet holds a bitmap of the live documents of
+// the set for each segment of the snapshot of a reader,
+// nil for the segments without any
+type SegmentedDocSet struct {
+	snapshot *indexSnapshot
+	docs     []*bitset.BitSet
+}
+
+func (i *IndexReader) TermDocSet(term []byte, field string) (index.DocSet, error) {
+	rv := SegmentedDocSet{
+		snapshot: i.snapshot,
+		docs:     make([]*bitset.BitSet, len(i.snapshot.segments)),
+	}
+	for s, segment := range i.snapshot.segments {
+		entry, err := segment.file.dictEntry(field, string(term))
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		docs, err := segment.file.documents(entry)
+		if err != nil {
+			return nil, err
+		}
+		docs.InPlaceDifference(segment.deleted)
+		rv.docs[s] = docs
+	}
+	return &rv, nil
+}
+
+func (d *SegmentedDocSet) Count() uint64 {
+	var rv uint64
+	for _, docs := range d.docs {
+		if docs != nil {
+			rv += uint64(docs.Count())
+		}
+	}
+	return rv
+}
+
+func (d *SegmentedDocSet) combine(other index.DocSet, op func(a, b *bitset.BitSet) *bitset.BitSet) index.DocSet {
+	o, ok := other.(*SegmentedDocSet)
+	if !ok || o.snapshot != d.snapshot {
+		panic("cannot combine document sets of different readers")
+	}
+	rv := SegmentedDocSet{
+		snapshot: d.snapshot,
+		docs:     make([]*bitset.BitSet, len(d.docs)),
+	}
+	for s := range d.docs {
+		rv.docs[s] = op(d.docs[s], o.docs[s])
+	}
+	return &rv
+}
+
+func (d *SegmentedDocSet) Intersect(other index.DocSet) index.DocSet {
+	return d.combine(other, func(a, b *bitset.BitSet) *bitset.BitSet {
+		if a == nil || b == nil {
+			return nil
+		}
+		return a.Intersection(b)
+	})
+}
+
+func (d *SegmentedDocSet) Union(other index.DocSet) index.DocSet {
+	return d.combine(other, func(a, b *bitset.BitSet) *bitset.BitSet {
+		if a == nil {
+			return b
+		}
+		if b == nil {
+			return a
+		}
+		return a.Union(b)
+	})
+}
+
+func (d *SegmentedDocSet) DocIDReader() index.DocIDReader {
+	rv := SegmentedDocSetReader{
+		cursors: make([]*docSetCursor, 0, len(d.docs)),
+	}
+	for s, docs := range d.docs {
+		if docs == nil {
+			continue
+		}
+		cursor := docSetCursor{
+			segment: d.snapshot.segments[s].file,
+			docs:    docs,
+		}
+		cursor.seek(0)
+		rv.cursors = append(rv.cursors, &cursor)
+	}
+	return &rv
+}
+
+// docSetCursor walks the documents of a set in one segment
+type docSetCursor struct {
+	segment *segmentFile
+	docs    *bitset.BitSet
+	docNum  uint
+	currID  string
+	valid   bool
+}
+
+func (c *docSetCursor) seek(docNum uint) {
+	c.docNum, c.valid = c.docs.NextSet(docNum)
+	if c.valid {
+		c.currID = c.segment.docID(int(c.docNum))
+	}
+}
+
+// SegmentedDocSetReader merges the documents of a set in
+// all segments, in document id order
+type SegmentedDocSetReader struct {
+	cursors []*docSetCursor
+}
+
+func (r *SegmentedDocSetReader) Next() (string, error) {
+	var min *docSetCursor
+	for _, cursor := range r.cursors {
+		if cursor.valid && (min == nil || cursor.currID < min.currID) {
+			min = cursor
+		}
+	}
+	if min == nil {
+		return "", nil
+	}
+	rv := min.currID
+	min.seek(min.docNum + 1)
+	return rv, nil
+}
+
+func (r *SegmentedDocSetReader) Advance(docID string) (string, error) {
+	for _, cursor := range r.cursors {
+		if cursor.valid && cursor.currID < docID {
+			cursor.seek(uint(cursor.segment.searchDocNum(docID)))
+		}
+	}
+	return r.Next()
+}
+
+func (r *SegmentedDocSetReader) Close() error {
+	return nil
+}
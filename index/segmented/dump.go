@@ -0,0 +1,144 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"fmt"
+)
+
+// SegmentDumpRow describes a segment of the index
+type SegmentDumpRow struct {
+	ID      uint64
+	Docs    int
+	Deleted uint
+}
+
+func (r *SegmentDumpRow) String() string {
+	return fmt.Sprintf("Segment: %d Docs: %d Deleted: %d", r.ID, r.Docs, r.Deleted)
+}
+
+// FieldDumpRow describes a field of the index
+type FieldDumpRow struct {
+	Index uint16
+	Name  string
+}
+
+func (r *FieldDumpRow) String() string {
+	return fmt.Sprintf("Field: %d Name: %s", r.Index, r.Name)
+}
+
+// StoredDumpRow is a stored field of a document
+type StoredDumpRow struct {
+	Doc            string
+	Field          string
+	ArrayPositions []uint64
+	Type           byte
+	Value          []byte
+}
+
+func (r *StoredDumpRow) String() string {
+	return fmt.Sprintf("Document: %s Field: %s ArrayPositions: %v Type: %s Value: %s", r.Doc, r.Field, r.ArrayPositions, string(r.Type), r.Value)
+}
+
+// TermDumpRow is a term a document was indexed with
+type TermDumpRow struct {
+	Doc   string
+	Field string
+	Term  string
+}
+
+func (r *TermDumpRow) String() string {
+	return fmt.Sprintf("Document: %s Field: %s Term: `%s`", r.Doc, r.Field, r.Term)
+}
+
+func dumpDoc(segment *segmentSnapshot, docNum int, rv chan interface{}) {
+	doc, err := segment.file.document(docNum)
+	if err != nil {
+		rv <- err
+		return
+	}
+	for _, sf := range doc.stored {
+		rv <- &StoredDumpRow{
+			Doc:            doc.id,
+			Field:          sf.field,
+			ArrayPositions: sf.arrayPositions,
+			Type:           sf.typ,
+			Value:          sf.value,
+		}
+	}
+	for _, dt := range doc.terms {
+		rv <- &TermDumpRow{
+			Doc:   doc.id,
+			Field: dt.field,
+			Term:  dt.term,
+		}
+	}
+}
+
+// DumpAll emits the fields, then every segment followed
+// by its live documents
+func (s *Segmented) DumpAll() chan interface{} {
+	rv := make(chan interface{})
+	go func() {
+		defer close(rv)
+		s.dumpFields(rv)
+		snapshot := s.currentSnapshot()
+		defer func() {
+			_ = snapshot.decRef()
+		}()
+		for _, segment := range snapshot.segments {
+			rv <- &SegmentDumpRow{
+				ID:      segment.file.id,
+				Docs:    segment.file.numDocs,
+				Deleted: segment.deleted.Count(),
+			}
+			for docNum := 0; docNum < segment.file.numDocs; docNum++ {
+				if segment.live(docNum) {
+					dumpDoc(segment, docNum, rv)
+				}
+			}
+		}
+	}()
+	return rv
+}
+
+func (s *Segmented) DumpFields() chan interface{} {
+	rv := make(chan interface{})
+	go func() {
+		defer close(rv)
+		s.dumpFields(rv)
+	}()
+	return rv
+}
+
+func (s *Segmented) dumpFields(rv chan interface{}) {
+	for i, name := range s.fieldNames() {
+		rv <- &FieldDumpRow{
+			Index: uint16(i),
+			Name:  name,
+		}
+	}
+}
+
+func (s *Segmented) DumpDoc(id string) chan interface{} {
+	rv := make(chan interface{})
+	go func() {
+		defer close(rv)
+		snapshot := s.currentSnapshot()
+		defer func() {
+			_ = snapshot.decRef()
+		}()
+		segment, docNum, ok := snapshot.lookup(id)
+		if ok {
+			dumpDoc(segment, docNum, rv)
+		}
+	}()
+	return rv
+}
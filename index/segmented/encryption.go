@@ -0,0 +1,65 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"encoding/binary"
+
+	"github.com/blevesearch/bleve/index/store/encrypted"
+)
+
+// SetKeyProvider makes the index encrypt its segment
+// files with keys of provider, it must be called before
+// Open.  Files written before are still read, and are
+// encrypted once they are merged.  Segments kept in the
+// store are left to the store, which is encrypted when it
+// is wrapped by the encrypted store.  Backups and the
+// segments shipped to followers are encrypted as well, so
+// replicas need a provider with the same keys.
+func (s *Segmented) SetKeyProvider(provider encrypted.KeyProvider) {
+	s.cipher = encrypted.NewCipher(provider)
+}
+
+// the id of the segment is authenticated with its data,
+// so that files cannot be swapped unnoticed
+func segmentAdditionalData(id uint64) []byte {
+	rv := make([]byte, 8)
+	binary.BigEndian.PutUint64(rv, id)
+	return rv
+}
+
+func (s *Segmented) encryptSegment(id uint64, data []byte) ([]byte, error) {
+	if s.cipher == nil || s.path == "" {
+		return data, nil
+	}
+	return s.cipher.Encrypt(data, segmentAdditionalData(id))
+}
+
+// segmentEncrypted tells the files of encrypted segments
+// from plain ones, which start with the segment magic
+func segmentEncrypted(data []byte) bool {
+	return len(data) > 0 && data[0] != segmentMagic[0]
+}
+
+func (s *Segmented) decryptSegment(id uint64, data []byte) ([]byte, error) {
+	if s.cipher == nil {
+		return nil, ErrEncryptedSegment
+	}
+	return s.cipher.Decrypt(data, segmentAdditionalData(id))
+}
+
+// segmentFileData is the data of a segment as it leaves
+// the index for backups and followers
+func (s *Segmented) segmentFileData(file *segmentFile) ([]byte, error) {
+	if s.cipher == nil {
+		return file.data, nil
+	}
+	return s.cipher.Encrypt(file.data, segmentAdditionalData(file.id))
+}
@@ -0,0 +1,172 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/store/boltdb"
+	"github.com/blevesearch/bleve/index/store/encrypted"
+)
+
+func TestEncryptedSegments(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err := os.MkdirAll("test", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := encrypted.NewStaticKeyProvider(map[string][]byte{
+		"1": bytes.Repeat([]byte{1}, 32),
+	}, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted.RegisterKeyProvider("segmented_test", provider)
+	defer encrypted.RegisterKeyProvider("segmented_test", nil)
+
+	dir := filepath.Join("test", "index")
+	analysisQueue := index.NewAnalysisQueue(1)
+	defer analysisQueue.Close()
+	open := func(config map[string]interface{}) (*Segmented, error) {
+		idx := NewSegmented(boltdb.New(filepath.Join("test", "store"), "bleve"), analysisQueue)
+		idx.SetDirectory(dir)
+		err := idx.SetConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return idx, idx.Open()
+	}
+	update := func(idx *Segmented, id, text string) {
+		doc := document.NewDocument(id)
+		doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{}, []byte(text), document.IndexField|document.StoreField))
+		err := idx.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	segmentFiles := func() map[string][]byte {
+		names, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rv := make(map[string][]byte, len(names))
+		for _, name := range names {
+			rv[name], err = ioutil.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rv
+	}
+
+	// a segment written before the index had a key provider
+	// stays readable
+	idx, err := open(map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update(idx, "a", "plaintextsecret")
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := map[string]interface{}{
+		"key_provider": "segmented_test",
+	}
+	idx, err = open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update(idx, "b", "ciphertextsecret")
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := segmentFiles()
+	if len(files) != 2 {
+		t.Fatalf("expected 2 segment files, got %d", len(files))
+	}
+	plain := 0
+	for name, data := range files {
+		if bytes.Contains(data, []byte("ciphertextsecret")) {
+			t.Errorf("expected %s to be encrypted", name)
+		}
+		if !segmentEncrypted(data) {
+			plain++
+		}
+	}
+	if plain != 1 {
+		t.Errorf("expected 1 plain segment file, got %d", plain)
+	}
+
+	// the encrypted segment cannot be read without the key
+	idx, err = open(map[string]interface{}{})
+	if err != ErrEncryptedSegment {
+		t.Fatalf("expected %v, got %v", ErrEncryptedSegment, err)
+	}
+	err = idx.store.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err = open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b"} {
+		doc, err := r.Document(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc == nil {
+			t.Errorf("expected document %s", id)
+		}
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// merging encrypts the plain segment
+	err = idx.ForceMerge(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range segmentFiles() {
+		if !segmentEncrypted(data) {
+			t.Errorf("expected %s to be encrypted", name)
+		}
+		if bytes.Contains(data, []byte("secret")) {
+			t.Errorf("expected %s to hold no plain text", name)
+		}
+	}
+}
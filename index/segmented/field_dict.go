@@ -0,0 +1,108 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"github.com/blevesearch/bleve/index"
+)
+
+// dictCursor walks the dictionary of a field in one
+// segment
+type dictCursor struct {
+	segment *segmentSnapshot
+	terms   *termIterator
+	curr    *dictEntry
+}
+
+func (c *dictCursor) next() (err error) {
+	c.curr, err = c.terms.next()
+	return
+}
+
+// SegmentedFieldDict merges the terms of a field in all
+// segments, counting only live documents
+type SegmentedFieldDict struct {
+	cursors []*dictCursor
+}
+
+func newSegmentedFieldDict(indexReader *IndexReader, field string, startTerm, endTerm []byte, automaton index.Automaton) (*SegmentedFieldDict, error) {
+	rv := SegmentedFieldDict{}
+	for _, segment := range indexReader.snapshot.segments {
+		terms, err := segment.file.terms(field, startTerm, endTerm, automaton)
+		if err != nil {
+			return nil, err
+		}
+		cursor := dictCursor{
+			segment: segment,
+			terms:   terms,
+		}
+		err = cursor.next()
+		if err != nil {
+			return nil, err
+		}
+		if cursor.curr != nil {
+			rv.cursors = append(rv.cursors, &cursor)
+		}
+	}
+	return &rv, nil
+}
+
+func (r *SegmentedFieldDict) Next() (*index.DictEntry, error) {
+	for {
+		var term string
+		found := false
+		for _, cursor := range r.cursors {
+			if cursor.curr != nil && (!found || cursor.curr.term < term) {
+				term = cursor.curr.term
+				found = true
+			}
+		}
+		if !found {
+			return nil, nil
+		}
+		rv := index.DictEntry{
+			Term: term,
+		}
+		for _, cursor := range r.cursors {
+			if cursor.curr != nil && cursor.curr.term == term {
+				count, err := cursor.segment.termCount(cursor.curr)
+				if err != nil {
+					return nil, err
+				}
+				rv.Count += count
+				err = cursor.next()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		// terms only found in deleted documents are skipped
+		if rv.Count > 0 {
+			return &rv, nil
+		}
+	}
+}
+
+func (r *SegmentedFieldDict) Close() error {
+	return nil
+}
+
+func incrementBytes(in []byte) []byte {
+	rv := make([]byte, len(in))
+	copy(rv, in)
+	for i := len(rv) - 1; i >= 0; i-- {
+		rv[i] = rv[i] + 1
+		if rv[i] != 0 {
+			// didn't overflow, so stop
+			break
+		}
+	}
+	return rv
+}
@@ -0,0 +1,316 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/blevesearch/bleve/index"
+)
+
+// An fst maps the terms of a dictionary to numbers, the
+// offsets of their entries.  It is a minimal acyclic
+// automaton over the bytes of the terms, the number of a
+// term is the sum of the outputs of the transitions on its
+// path.  Terms sharing a suffix share the nodes for it, so
+// that the automaton takes a fraction of the size of the
+// terms.  The nodes are encoded children first, each as
+//
+//	flags:       1 when a term ends at the node
+//	transitions: their number followed by the byte, the
+//	             output and the address of the node each
+//	             leads to
+//
+// followed by the fixed size address of the root.
+type fst struct {
+	data []byte
+	root uint64
+}
+
+func loadFST(data []byte) (*fst, error) {
+	if len(data) < 8 {
+		return nil, ErrCorruptSegment
+	}
+	rv := fst{
+		data: data[:len(data)-8],
+		root: binary.LittleEndian.Uint64(data[len(data)-8:]),
+	}
+	if rv.root >= uint64(len(rv.data)) {
+		return nil, ErrCorruptSegment
+	}
+	return &rv, nil
+}
+
+type fstArc struct {
+	label  byte
+	output uint64
+	target uint64
+}
+
+type fstNode struct {
+	final bool
+	arcs  []fstArc
+}
+
+// node decodes the node at addr, the nodes it leads to
+// come before it so that a corrupt fst cannot loop
+func (f *fst) node(addr uint64) (*fstNode, error) {
+	d := decoder{data: f.data, pos: int(addr)}
+	rv := fstNode{
+		final: d.byte() == 1,
+	}
+	numArcs := d.uvarint()
+	if numArcs > 256 {
+		return nil, ErrCorruptSegment
+	}
+	rv.arcs = make([]fstArc, 0, numArcs)
+	for i := uint64(0); i < numArcs && d.err == nil; i++ {
+		arc := fstArc{
+			label:  d.byte(),
+			output: d.uvarint(),
+			target: d.uvarint(),
+		}
+		if arc.target >= addr {
+			return nil, ErrCorruptSegment
+		}
+		rv.arcs = append(rv.arcs, arc)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &rv, nil
+}
+
+// get returns the number of the term, ok is false when the
+// term is not in the fst
+func (f *fst) get(term []byte) (v uint64, ok bool, err error) {
+	node, err := f.node(f.root)
+	for i := 0; err == nil && i < len(term); i++ {
+		var arc *fstArc
+		for a := range node.arcs {
+			if node.arcs[a].label == term[i] {
+				arc = &node.arcs[a]
+				break
+			}
+		}
+		if arc == nil {
+			return 0, false, nil
+		}
+		v += arc.output
+		node, err = f.node(arc.target)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return v, node.final, nil
+}
+
+type fstFrame struct {
+	node    *fstNode
+	next    int
+	output  uint64
+	state   int
+	visited bool
+	// the term up to the node is the start of the range
+	atStart bool
+}
+
+// fstIterator walks the terms of an fst in order, from
+// start on up to end when they are not nil.  With an
+// automaton it only follows the transitions the automaton
+// can still match on.
+type fstIterator struct {
+	f         *fst
+	automaton index.Automaton
+	start     []byte
+	end       []byte
+	stack     []*fstFrame
+	term      []byte
+}
+
+func (f *fst) iterator(start, end []byte, automaton index.Automaton) (*fstIterator, error) {
+	root, err := f.node(f.root)
+	if err != nil {
+		return nil, err
+	}
+	frame := fstFrame{
+		node:    root,
+		atStart: true,
+	}
+	if automaton != nil {
+		frame.state = automaton.Start()
+		if !automaton.CanMatch(frame.state) {
+			return &fstIterator{}, nil
+		}
+	}
+	return &fstIterator{
+		f:         f,
+		automaton: automaton,
+		start:     start,
+		end:       end,
+		stack:     []*fstFrame{&frame},
+	}, nil
+}
+
+// next returns the next term and its number, the term is
+// only valid until the next call.  ok is false at the end.
+func (i *fstIterator) next() (term []byte, v uint64, ok bool, err error) {
+	for len(i.stack) > 0 {
+		top := i.stack[len(i.stack)-1]
+		depth := len(i.term)
+		if !top.visited {
+			top.visited = true
+			if top.node.final && (!top.atStart || depth >= len(i.start)) &&
+				(i.automaton == nil || i.automaton.IsMatch(top.state)) {
+				if i.end != nil && bytes.Compare(i.term, i.end) > 0 {
+					i.stack = nil
+					return nil, 0, false, nil
+				}
+				return i.term, top.output, true, nil
+			}
+		}
+		if top.next >= len(top.node.arcs) {
+			i.stack = i.stack[:len(i.stack)-1]
+			if depth > 0 {
+				i.term = i.term[:depth-1]
+			}
+			continue
+		}
+		arc := top.node.arcs[top.next]
+		top.next++
+		atStart := false
+		if top.atStart && depth < len(i.start) {
+			if arc.label < i.start[depth] {
+				continue
+			}
+			atStart = arc.label == i.start[depth]
+		}
+		term := append(i.term, arc.label)
+		if i.end != nil && bytes.Compare(term, i.end) > 0 {
+			// all terms from here on are past the end
+			i.stack = nil
+			return nil, 0, false, nil
+		}
+		var state int
+		if i.automaton != nil {
+			state = i.automaton.Accept(top.state, arc.label)
+			if !i.automaton.CanMatch(state) {
+				continue
+			}
+		}
+		node, err := i.f.node(arc.target)
+		if err != nil {
+			i.stack = nil
+			return nil, 0, false, err
+		}
+		i.term = term
+		i.stack = append(i.stack, &fstFrame{
+			node:    node,
+			output:  top.output + arc.output,
+			state:   state,
+			atStart: atStart,
+		})
+	}
+	return nil, 0, false, nil
+}
+
+type fstBuilderArc struct {
+	label  byte
+	output uint64
+	target uint64
+}
+
+type fstBuilderNode struct {
+	final bool
+	arcs  []fstBuilderArc
+}
+
+// fstBuilder builds an fst from terms added in order with
+// numbers which do not decrease.  The nodes of the path of
+// the last term are kept until a term leaves the path,
+// then they are encoded unless an equal node already is.
+type fstBuilder struct {
+	e          encoder
+	registry   map[string]uint64
+	unfinished []*fstBuilderNode
+	last       []byte
+}
+
+func newFSTBuilder() *fstBuilder {
+	return &fstBuilder{
+		registry:   make(map[string]uint64),
+		unfinished: []*fstBuilderNode{&fstBuilderNode{}},
+	}
+}
+
+func (b *fstBuilder) add(term []byte, v uint64) {
+	prefix := 0
+	for prefix < len(term) && prefix < len(b.last) && term[prefix] == b.last[prefix] {
+		prefix++
+	}
+	b.freeze(prefix)
+	for i := 0; i < prefix; i++ {
+		node := b.unfinished[i]
+		v -= node.arcs[len(node.arcs)-1].output
+	}
+	for i := prefix; i < len(term); i++ {
+		node := b.unfinished[i]
+		node.arcs = append(node.arcs, fstBuilderArc{
+			label:  term[i],
+			output: v,
+		})
+		v = 0
+		b.unfinished = append(b.unfinished, &fstBuilderNode{})
+	}
+	b.unfinished[len(term)].final = true
+	b.last = append(b.last[:0], term...)
+}
+
+// freeze encodes the nodes of the path of the last term
+// past depth
+func (b *fstBuilder) freeze(depth int) {
+	for i := len(b.unfinished) - 1; i > depth; i-- {
+		parent := b.unfinished[i-1]
+		parent.arcs[len(parent.arcs)-1].target = b.compile(b.unfinished[i])
+	}
+	b.unfinished = b.unfinished[:depth+1]
+}
+
+func (b *fstBuilder) compile(node *fstBuilderNode) uint64 {
+	e := encoder{}
+	if node.final {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+	e.uvarint(uint64(len(node.arcs)))
+	for _, arc := range node.arcs {
+		e.buf.WriteByte(arc.label)
+		e.uvarint(arc.output)
+		e.uvarint(arc.target)
+	}
+	key := e.buf.String()
+	if addr, ok := b.registry[key]; ok {
+		return addr
+	}
+	addr := b.e.offset()
+	b.e.buf.WriteString(key)
+	b.registry[key] = addr
+	return addr
+}
+
+// finish returns the encoded fst
+func (b *fstBuilder) finish() []byte {
+	b.freeze(0)
+	root := b.compile(b.unfinished[0])
+	b.e.fixed(root)
+	return b.e.buf.Bytes()
+}
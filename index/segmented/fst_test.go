@@ -0,0 +1,133 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// prefixAutomaton matches the terms starting with a prefix,
+// its states are the number of bytes of the prefix seen
+// and -1 once a byte differs
+type prefixAutomaton string
+
+func (a prefixAutomaton) Start() int {
+	return 0
+}
+
+func (a prefixAutomaton) IsMatch(state int) bool {
+	return state == len(a)
+}
+
+func (a prefixAutomaton) CanMatch(state int) bool {
+	return state >= 0
+}
+
+func (a prefixAutomaton) Accept(state int, b byte) int {
+	if state < 0 || state == len(a) {
+		return state
+	}
+	if a[state] != b {
+		return -1
+	}
+	return state + 1
+}
+
+func fstTerms(t *testing.T, f *fst, start, end []byte, automaton prefixAutomaton) []string {
+	var iter *fstIterator
+	var err error
+	if automaton != "" {
+		iter, err = f.iterator(start, end, automaton)
+	} else {
+		iter, err = f.iterator(start, end, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv := make([]string, 0)
+	term, _, ok, err := iter.next()
+	for ok {
+		rv = append(rv, string(term))
+		term, _, ok, err = iter.next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rv
+}
+
+func TestFST(t *testing.T) {
+	terms := []string{""}
+	for _, prefix := range []string{"a", "ab", "b", "cat", "dog"} {
+		for _, suffix := range []string{"", "s", "ing", "ed", "ings"} {
+			for i := 0; i < 3; i++ {
+				terms = append(terms, fmt.Sprintf("%s%d%s", prefix, i, suffix))
+			}
+		}
+	}
+	sort.Strings(terms)
+	b := newFSTBuilder()
+	for i, term := range terms {
+		b.add([]byte(term), uint64(i*10))
+	}
+	data := b.finish()
+	if len(data) >= len(strings.Join(terms, "")) {
+		t.Errorf("expected the fst to be smaller than the terms, got %d bytes", len(data))
+	}
+	f, err := loadFST(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, term := range terms {
+		v, ok, err := f.get([]byte(term))
+		if err != nil || !ok || v != uint64(i*10) {
+			t.Errorf("expected %d for %q, got %d %t %v", i*10, term, v, ok, err)
+		}
+	}
+	for _, term := range []string{"a", "a0in", "cat3", "z", "a0ingss"} {
+		_, ok, err := f.get([]byte(term))
+		if err != nil || ok {
+			t.Errorf("expected no %q, got %t %v", term, ok, err)
+		}
+	}
+
+	if got := fstTerms(t, f, nil, nil, ""); !reflect.DeepEqual(got, terms) {
+		t.Errorf("expected all terms, got %v", got)
+	}
+	expected := make([]string, 0)
+	prefixed := make([]string, 0)
+	for _, term := range terms {
+		if term >= "ab1" && term <= "b0s" {
+			expected = append(expected, term)
+		}
+		if strings.HasPrefix(term, "cat1") {
+			prefixed = append(prefixed, term)
+		}
+	}
+	if got := fstTerms(t, f, []byte("ab1"), []byte("b0s"), ""); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v in the range, got %v", expected, got)
+	}
+	if got := fstTerms(t, f, []byte("ab0z"), []byte("ab0z"), ""); len(got) != 0 {
+		t.Errorf("expected no terms in the range, got %v", got)
+	}
+	if got := fstTerms(t, f, nil, nil, "cat1"); !reflect.DeepEqual(got, prefixed) {
+		t.Errorf("expected %v with the prefix, got %v", prefixed, got)
+	}
+
+	_, err = loadFST(data[:4])
+	if err == nil {
+		t.Errorf("expected error for a truncated fst")
+	}
+}
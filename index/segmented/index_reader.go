@@ -0,0 +1,187 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/store"
+)
+
+type IndexReader struct {
+	index       *Segmented
+	snapshot    *indexSnapshot
+	kvreader    store.KVReader
+	internalGen uint64
+}
+
+// Reopen returns a reader of the snapshot now visible,
+// or nil when neither the snapshot nor the internal values
+// changed.  Segments which are unchanged in the new
+// snapshot keep the counts computed for them.  The store
+// reader is not shared, a reader of a store kept open for
+// long might hold back its writes.
+func (i *IndexReader) Reopen() (index.IndexReader, error) {
+	internalGen := atomic.LoadUint64(&i.index.internalGen)
+	snapshot := i.index.visibleSnapshot()
+	if snapshot == i.snapshot && internalGen == i.internalGen {
+		return nil, snapshot.decRef()
+	}
+	return i.index.newReader(snapshot, internalGen)
+}
+
+func (i *IndexReader) TermFieldReader(term []byte, fieldName string) (index.TermFieldReader, error) {
+	return newSegmentedTermFieldReader(i, string(term), fieldName)
+}
+
+func (i *IndexReader) FieldDict(fieldName string) (index.FieldDict, error) {
+	return i.FieldDictRange(fieldName, nil, nil)
+}
+
+func (i *IndexReader) FieldDictRange(fieldName string, startTerm []byte, endTerm []byte) (index.FieldDict, error) {
+	return newSegmentedFieldDict(i, fieldName, startTerm, endTerm, nil)
+}
+
+func (i *IndexReader) FieldDictAutomaton(fieldName string, automaton index.Automaton) (index.FieldDict, error) {
+	return newSegmentedFieldDict(i, fieldName, nil, nil, automaton)
+}
+
+func (i *IndexReader) FieldDictPrefix(fieldName string, termPrefix []byte) (index.FieldDict, error) {
+	return i.FieldDictRange(fieldName, termPrefix, incrementBytes(termPrefix))
+}
+
+func (i *IndexReader) DocIDReader(start, end string) (index.DocIDReader, error) {
+	return newSegmentedDocIDReader(i, start, end), nil
+}
+
+func (i *IndexReader) SortField() string {
+	return i.index.sortField
+}
+
+func (i *IndexReader) SortedDocIDReader() (index.SortedDocIDReader, error) {
+	return newSegmentedSortedDocIDReader(i)
+}
+
+func (i *IndexReader) Document(id string) (*document.Document, error) {
+	segment, docNum, ok := i.snapshot.lookup(id)
+	if !ok {
+		return nil, nil
+	}
+	stored, err := segment.file.document(docNum)
+	if err != nil {
+		return nil, err
+	}
+	doc := document.NewDocument(id)
+	for _, sf := range stored.stored {
+		field := decodeFieldType(sf.typ, sf.field, sf.arrayPositions, sf.value)
+		if field != nil {
+			doc.AddField(field)
+		}
+	}
+	return doc, nil
+}
+
+func (i *IndexReader) DocumentFieldTerms(id string) (index.FieldTerms, error) {
+	rv := make(index.FieldTerms)
+	segment, docNum, ok := i.snapshot.lookup(id)
+	if !ok {
+		return rv, nil
+	}
+	doc, err := segment.file.document(docNum)
+	if err != nil {
+		return nil, err
+	}
+	for _, dt := range doc.terms {
+		rv[dt.field] = append(rv[dt.field], dt.term)
+	}
+	return rv, nil
+}
+
+func (i *IndexReader) DocumentTermVectors(id string, fields []string) (index.FieldTermVectors, error) {
+	rv := make(index.FieldTermVectors)
+	segment, docNum, ok := i.snapshot.lookup(id)
+	if !ok {
+		return rv, nil
+	}
+	doc, err := segment.file.document(docNum)
+	if err != nil {
+		return nil, err
+	}
+	var wanted map[string]bool
+	if fields != nil {
+		wanted = make(map[string]bool, len(fields))
+		for _, field := range fields {
+			wanted[field] = true
+		}
+	}
+	for _, dt := range doc.terms {
+		if wanted != nil && !wanted[dt.field] {
+			continue
+		}
+		entry, err := segment.file.dictEntry(dt.field, dt.term)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		postings := segment.file.postings(entry)
+		postings.skipTo(docNum)
+		p, err := postings.next()
+		for p != nil && p.docNum < docNum {
+			p, err = postings.next()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p == nil || p.docNum != docNum {
+			continue
+		}
+		rv[dt.field] = append(rv[dt.field], &index.TermVector{
+			Term:    dt.term,
+			Freq:    p.freq,
+			Vectors: p.vectors,
+		})
+	}
+	for _, vectors := range rv {
+		sort.Sort(index.TermVectorsByTerm(vectors))
+	}
+	return rv, nil
+}
+
+func (i *IndexReader) DocValueReader(fields []string) (index.DocValueReader, error) {
+	return &SegmentedDocValueReader{
+		snapshot: i.snapshot,
+		fields:   fields,
+	}, nil
+}
+
+func (i *IndexReader) Fields() ([]string, error) {
+	return i.index.fieldNames(), nil
+}
+
+func (i *IndexReader) GetInternal(key []byte) ([]byte, error) {
+	return i.kvreader.Get(internalKey(key))
+}
+
+func (i *IndexReader) DocCount() uint64 {
+	return i.snapshot.docCount()
+}
+
+func (i *IndexReader) Close() error {
+	err := i.snapshot.decRef()
+	if cerr := i.kvreader.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,344 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/willf/bitset"
+)
+
+func (s *Segmented) notifyMerger() {
+	select {
+	case s.mergeNotify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Segmented) mergeLoop() {
+	defer s.mergeDone.Done()
+	for {
+		select {
+		case <-s.closeCh:
+			return
+		case <-s.mergeNotify:
+		}
+		for {
+			merged, err := s.mergeOnce(s.mergeCandidates)
+			if err != nil {
+				atomic.AddUint64(&s.stats.errors, 1)
+				break
+			}
+			if !merged {
+				break
+			}
+			select {
+			case <-s.closeCh:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// mergeCandidates asks the merge policy which segments of
+// the snapshot to merge, or nil when there is nothing to
+// merge.  Segments only in the write-ahead log are left
+// alone until a checkpoint writes them.
+func (s *Segmented) mergeCandidates(snapshot *indexSnapshot) []*segmentSnapshot {
+	infos := make([]*SegmentInfo, 0, len(snapshot.segments))
+	segments := make(map[uint64]*segmentSnapshot, len(snapshot.segments))
+	for _, segment := range snapshot.segments {
+		if atomic.LoadInt32(&segment.file.wal) == 1 {
+			continue
+		}
+		infos = append(infos, &SegmentInfo{
+			ID:      segment.file.id,
+			Size:    uint64(len(segment.file.data)),
+			NumDocs: uint64(segment.file.numDocs),
+			Deleted: uint64(segment.deleted.Count()),
+		})
+		segments[segment.file.id] = segment
+	}
+	var rv []*segmentSnapshot
+	for _, info := range s.mergePolicy.Merge(infos) {
+		if segment, ok := segments[info.ID]; ok {
+			rv = append(rv, segment)
+			delete(segments, info.ID)
+		}
+	}
+	return rv
+}
+
+type segmentsByLiveCount []*segmentSnapshot
+
+func (s segmentsByLiveCount) Len() int           { return len(s) }
+func (s segmentsByLiveCount) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s segmentsByLiveCount) Less(i, j int) bool { return s[i].liveCount() < s[j].liveCount() }
+
+// forceMergeCandidates merges the smallest segments until
+// at most maxSegments are left, and then rewrites the
+// segments with deletions one at a time
+func forceMergeCandidates(snapshot *indexSnapshot, maxSegments int) []*segmentSnapshot {
+	if len(snapshot.segments) > maxSegments {
+		rv := make([]*segmentSnapshot, len(snapshot.segments))
+		copy(rv, snapshot.segments)
+		sort.Sort(segmentsByLiveCount(rv))
+		return rv[:len(rv)-maxSegments+1]
+	}
+	for _, segment := range snapshot.segments {
+		if !segment.deleted.None() {
+			return []*segmentSnapshot{segment}
+		}
+	}
+	return nil
+}
+
+// ForceMerge merges the segments down to at most
+// maxSegments and drops all deleted documents, the store
+// is compacted afterwards when it supports it
+func (s *Segmented) ForceMerge(maxSegments int) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if maxSegments < 1 {
+		return fmt.Errorf("cannot merge to fewer than 1 segment")
+	}
+	candidates := func(snapshot *indexSnapshot) []*segmentSnapshot {
+		return forceMergeCandidates(snapshot, maxSegments)
+	}
+	for {
+		merged, err := s.mergeOnce(candidates)
+		if err != nil {
+			atomic.AddUint64(&s.stats.errors, 1)
+			return err
+		}
+		if !merged {
+			break
+		}
+	}
+	if compactor, ok := s.store.(store.KVCompactor); ok {
+		return compactor.Compact()
+	}
+	return nil
+}
+
+// mergeOnce merges one set of segments picked by
+// candidates, it returns false when there was nothing to
+// merge
+func (s *Segmented) mergeOnce(candidates func(*indexSnapshot) []*segmentSnapshot) (bool, error) {
+	// only one merge at a time, merges of the same segment
+	// would duplicate its documents
+	s.mergeMutex.Lock()
+	defer s.mergeMutex.Unlock()
+
+	snapshot := s.currentSnapshot()
+	defer func() {
+		_ = snapshot.decRef()
+	}()
+
+	// a replica has the segments of its primary
+	if s.isReplica() {
+		return false, nil
+	}
+
+	sources := candidates(snapshot)
+	if len(sources) == 0 || (len(sources) == 1 && sources[0].deleted.None()) {
+		// merging a single segment only pays off if it
+		// drops deletions
+		return false, nil
+	}
+
+	mergeStart := time.Now()
+	merger := newSegmentMerger(sources, s.sortField, s.storedCodec)
+	var file *segmentFile
+	var err error
+	if merger.numDocs() > 0 {
+		file, err = s.writeMergedSegment(s.newSegmentID(), merger)
+		if err != nil {
+			return false, err
+		}
+	}
+	err = s.introduceMerge(sources, file)
+	if err != nil {
+		return false, err
+	}
+	atomic.AddUint64(&s.stats.merges, 1)
+	atomic.AddUint64(&s.stats.mergeTime, uint64(time.Since(mergeStart)))
+	return true, nil
+}
+
+// writeMergedSegment streams the merged segment into its
+// file.  Segments kept in the store and encrypted segments
+// are sealed as a whole, they are built in memory first.
+func (s *Segmented) writeMergedSegment(id uint64, merger *segmentMerger) (*segmentFile, error) {
+	if s.path == "" || s.cipher != nil {
+		e := encoder{}
+		err := merger.write(&e)
+		if err != nil {
+			return nil, err
+		}
+		return s.storeSegment(id, e.buf.Bytes(), s.mergeLimiter)
+	}
+
+	path := s.segmentPath(id)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w := &throttledWriter{
+		s:       s,
+		w:       f,
+		limiter: s.mergeLimiter,
+		crc:     crc32.NewIEEE(),
+	}
+	err = merger.write(&encoder{w: w})
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	rv, err := s.openSegmentFile(id)
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	rv.checksum = w.crc.Sum32()
+	return rv, nil
+}
+
+// throttledWriter writes a segment file at the pace of its
+// limiter, keeping the checksum of the data
+type throttledWriter struct {
+	s       *Segmented
+	w       io.Writer
+	limiter *rateLimiter
+	crc     hash.Hash32
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := written + throttleChunkSize
+		if end > len(p) {
+			end = len(p)
+		}
+		t.s.throttle(t.limiter, end-written)
+		n, err := t.w.Write(p[written:end])
+		_, _ = t.crc.Write(p[written : written+n])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// introduceMerge replaces the merged segments with the
+// result of the merge.  Documents deleted from the sources
+// while the merge ran are deleted from the result as well.
+func (s *Segmented) introduceMerge(sources []*segmentSnapshot, file *segmentFile) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	var deleted *bitset.BitSet
+	if file != nil {
+		deleted = bitset.New(uint(file.numDocs))
+	}
+	deleteFromMerged := func(segment *segmentSnapshot, docNum int) {
+		if file == nil {
+			return
+		}
+		if n, ok := file.docNum(segment.file.docID(docNum)); ok {
+			deleted.Set(uint(n))
+		}
+	}
+
+	merging := make(map[*segmentFile]*segmentSnapshot, len(sources))
+	for _, source := range sources {
+		merging[source.file] = source
+	}
+
+	root := s.root
+	snapshot := &indexSnapshot{
+		segments: make([]*segmentSnapshot, 0, len(root.segments)),
+		refs:     1,
+	}
+	removed := make([]*segmentFile, 0, len(sources))
+	for _, segment := range root.segments {
+		source, ok := merging[segment.file]
+		if !ok {
+			segment.file.addRef()
+			snapshot.segments = append(snapshot.segments, segment)
+			continue
+		}
+		delete(merging, segment.file)
+		removed = append(removed, segment.file)
+		for i, ok := segment.deleted.NextSet(0); ok; i, ok = segment.deleted.NextSet(i + 1) {
+			if !source.deleted.Test(i) {
+				deleteFromMerged(segment, int(i))
+			}
+		}
+	}
+	// sources which are gone from the root had all of their
+	// documents deleted
+	for _, source := range merging {
+		for docNum := 0; docNum < source.file.numDocs; docNum++ {
+			if source.live(docNum) {
+				deleteFromMerged(source, docNum)
+			}
+		}
+	}
+	// a result with all of its documents deleted is dropped
+	var unused *segmentFile
+	if file != nil {
+		if deleted.Count() == uint(file.numDocs) {
+			unused = file
+			removed = append(removed, file)
+		} else {
+			snapshot.segments = append(snapshot.segments, &segmentSnapshot{
+				file:    file,
+				deleted: deleted,
+			})
+		}
+	}
+
+	err := s.persist(snapshot, nil, removed)
+	if err != nil {
+		if file != nil {
+			file.markObsolete()
+		}
+		_ = snapshot.decRef()
+		if unused != nil {
+			_ = unused.decRef()
+		}
+		return err
+	}
+	err = s.swapRoot(snapshot, removed)
+	if unused != nil {
+		if derr := unused.decRef(); err == nil {
+			err = derr
+		}
+	}
+	return err
+}
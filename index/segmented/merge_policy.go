@@ -0,0 +1,213 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SegmentInfo describes a segment to a MergePolicy, Size
+// is the encoded size of the segment in bytes
+type SegmentInfo struct {
+	ID      uint64
+	Size    uint64
+	NumDocs uint64
+	Deleted uint64
+}
+
+// LiveSize estimates the size of the live documents of
+// the segment
+func (s *SegmentInfo) LiveSize() uint64 {
+	if s.NumDocs == 0 {
+		return 0
+	}
+	return uint64(float64(s.Size) * float64(s.NumDocs-s.Deleted) / float64(s.NumDocs))
+}
+
+// A MergePolicy picks segments of the index to merge into
+// one, it is asked again after every merge until it
+// returns no segments.
+type MergePolicy interface {
+	Merge(segments []*SegmentInfo) []*SegmentInfo
+}
+
+// TieredMergePolicy merges segments of about the same size,
+// allowing SegmentsPerTier segments in each tier of sizes
+// before merging MaxMergeAtOnce of them into one of the
+// next tier.  Segments are not merged larger than
+// MaxSegmentSize, but any segment with more than
+// DeletesPctAllowed percent of its documents deleted is
+// rewritten.  Segments smaller than FloorSegmentSize are
+// treated as if they were that size, so that the smallest
+// segments are merged eagerly.
+type TieredMergePolicy struct {
+	MaxSegmentSize    uint64  `json:"max_segment_size"`
+	SegmentsPerTier   int     `json:"segments_per_tier"`
+	MaxMergeAtOnce    int     `json:"max_merge_at_once"`
+	FloorSegmentSize  uint64  `json:"floor_segment_size"`
+	DeletesPctAllowed float64 `json:"deletes_pct_allowed"`
+}
+
+func NewTieredMergePolicy() *TieredMergePolicy {
+	return &TieredMergePolicy{
+		MaxSegmentSize:    5 << 30,
+		SegmentsPerTier:   10,
+		MaxMergeAtOnce:    10,
+		FloorSegmentSize:  2 << 20,
+		DeletesPctAllowed: 33,
+	}
+}
+
+// newTieredMergePolicyFromConfig builds a tiered policy from
+// the "merge_policy" section of the index config, keys
+// which are missing keep their default
+func newTieredMergePolicyFromConfig(config map[string]interface{}) (*TieredMergePolicy, error) {
+	rv := NewTieredMergePolicy()
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(configBytes, rv)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merge policy: %v", err)
+	}
+	if rv.SegmentsPerTier < 2 || rv.MaxMergeAtOnce < 2 {
+		return nil, fmt.Errorf("merge policy must allow at least 2 segments per tier and merge")
+	}
+	if rv.DeletesPctAllowed < 0 || rv.DeletesPctAllowed > 100 {
+		return nil, fmt.Errorf("merge policy deletes_pct_allowed must be between 0 and 100")
+	}
+	return rv, nil
+}
+
+type segmentInfosBySize []*SegmentInfo
+
+func (s segmentInfosBySize) Len() int      { return len(s) }
+func (s segmentInfosBySize) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s segmentInfosBySize) Less(i, j int) bool {
+	if s[i].LiveSize() == s[j].LiveSize() {
+		return s[i].ID < s[j].ID
+	}
+	return s[i].LiveSize() > s[j].LiveSize()
+}
+
+func (p *TieredMergePolicy) floorSize(size uint64) uint64 {
+	if size < p.FloorSegmentSize {
+		return p.FloorSegmentSize
+	}
+	return size
+}
+
+// allowedSegments returns how many segments the index may
+// have for its total size
+func (p *TieredMergePolicy) allowedSegments(segments []*SegmentInfo) int {
+	var total uint64
+	minSize := uint64(math.MaxUint64)
+	for _, segment := range segments {
+		size := segment.LiveSize()
+		total += size
+		if size < minSize {
+			minSize = size
+		}
+	}
+	levelSize := math.Max(float64(p.floorSize(minSize)), 1)
+	bytesLeft := float64(total)
+	allowed := 0
+	for {
+		levelCount := bytesLeft / levelSize
+		if levelCount < float64(p.SegmentsPerTier) {
+			allowed += int(math.Ceil(levelCount))
+			break
+		}
+		allowed += p.SegmentsPerTier
+		bytesLeft -= float64(p.SegmentsPerTier) * levelSize
+		levelSize *= float64(p.MaxMergeAtOnce)
+	}
+	if allowed < p.SegmentsPerTier {
+		allowed = p.SegmentsPerTier
+	}
+	return allowed
+}
+
+func (p *TieredMergePolicy) Merge(segments []*SegmentInfo) []*SegmentInfo {
+	// segments close to the maximum size are only rewritten
+	// to drop their deletions
+	eligible := make([]*SegmentInfo, 0, len(segments))
+	for _, segment := range segments {
+		if segment.LiveSize() <= p.MaxSegmentSize/2 {
+			eligible = append(eligible, segment)
+		}
+	}
+	sort.Sort(segmentInfosBySize(eligible))
+
+	if len(segments) > p.allowedSegments(segments) && len(eligible) > 1 {
+		// score every run of segments of similar size, lower
+		// is better: favour merges of equally sized segments,
+		// small results and reclaiming deletions
+		var best []*SegmentInfo
+		bestScore := math.Inf(1)
+		for start := 0; start < len(eligible)-1; start++ {
+			var size, floorSize, largest, numDocs, deleted uint64
+			candidate := make([]*SegmentInfo, 0, p.MaxMergeAtOnce)
+			for i := start; i < len(eligible) && len(candidate) < p.MaxMergeAtOnce; i++ {
+				segment := eligible[i]
+				if size+segment.LiveSize() > p.MaxSegmentSize {
+					continue
+				}
+				candidate = append(candidate, segment)
+				size += segment.LiveSize()
+				floorSize += p.floorSize(segment.LiveSize())
+				if p.floorSize(segment.LiveSize()) > largest {
+					largest = p.floorSize(segment.LiveSize())
+				}
+				numDocs += segment.NumDocs
+				deleted += segment.Deleted
+			}
+			if len(candidate) < 2 {
+				continue
+			}
+			skew := float64(largest) / float64(floorSize)
+			liveRatio := 1.0
+			if numDocs > 0 {
+				liveRatio = float64(numDocs-deleted) / float64(numDocs)
+			}
+			score := skew * math.Pow(float64(size)+1, 0.05) * liveRatio * liveRatio
+			if score < bestScore {
+				best = candidate
+				bestScore = score
+			}
+		}
+		if best != nil {
+			return best
+		}
+	}
+
+	// otherwise rewrite the segment with the most deletions,
+	// if it has too many
+	var worst *SegmentInfo
+	worstPct := p.DeletesPctAllowed
+	for _, segment := range segments {
+		if segment.NumDocs == 0 {
+			continue
+		}
+		pct := 100 * float64(segment.Deleted) / float64(segment.NumDocs)
+		if pct > worstPct {
+			worst = segment
+			worstPct = pct
+		}
+	}
+	if worst != nil {
+		return []*SegmentInfo{worst}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mergeIDs(segments []*SegmentInfo) []uint64 {
+	var rv []uint64
+	for _, segment := range segments {
+		rv = append(rv, segment.ID)
+	}
+	return rv
+}
+
+func TestTieredMergePolicy(t *testing.T) {
+	policy := &TieredMergePolicy{
+		MaxSegmentSize:    1000,
+		SegmentsPerTier:   2,
+		MaxMergeAtOnce:    2,
+		FloorSegmentSize:  10,
+		DeletesPctAllowed: 20,
+	}
+
+	tests := []struct {
+		segments []*SegmentInfo
+		expected []uint64
+	}{
+		// few enough segments
+		{
+			segments: []*SegmentInfo{
+				&SegmentInfo{ID: 1, Size: 100, NumDocs: 10},
+				&SegmentInfo{ID: 2, Size: 10, NumDocs: 1},
+			},
+		},
+		// too many segments for their size, the best
+		// merge is of similar small segments
+		{
+			segments: []*SegmentInfo{
+				&SegmentInfo{ID: 1, Size: 40, NumDocs: 4},
+				&SegmentInfo{ID: 2, Size: 10, NumDocs: 1},
+				&SegmentInfo{ID: 3, Size: 10, NumDocs: 1},
+				&SegmentInfo{ID: 4, Size: 10, NumDocs: 1},
+				&SegmentInfo{ID: 5, Size: 10, NumDocs: 1},
+				&SegmentInfo{ID: 6, Size: 10, NumDocs: 1},
+			},
+			expected: []uint64{2, 3},
+		},
+		// segments too large to merge are left alone
+		{
+			segments: []*SegmentInfo{
+				&SegmentInfo{ID: 1, Size: 900, NumDocs: 10},
+				&SegmentInfo{ID: 2, Size: 800, NumDocs: 10},
+				&SegmentInfo{ID: 3, Size: 10, NumDocs: 1},
+			},
+		},
+		// unless they have too many deletions
+		{
+			segments: []*SegmentInfo{
+				&SegmentInfo{ID: 1, Size: 900, NumDocs: 10, Deleted: 1},
+				&SegmentInfo{ID: 2, Size: 800, NumDocs: 10, Deleted: 3},
+			},
+			expected: []uint64{2},
+		},
+	}
+	for i, test := range tests {
+		merge := mergeIDs(policy.Merge(test.segments))
+		if !reflect.DeepEqual(merge, test.expected) {
+			t.Errorf("expected merge %v, got %v for %d", test.expected, merge, i)
+		}
+	}
+}
+
+func TestTieredMergePolicyConfig(t *testing.T) {
+	policy, err := newTieredMergePolicyFromConfig(map[string]interface{}{
+		"segments_per_tier":   4.0,
+		"deletes_pct_allowed": 10.0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NewTieredMergePolicy()
+	expected.SegmentsPerTier = 4
+	expected.DeletesPctAllowed = 10
+	if !reflect.DeepEqual(policy, expected) {
+		t.Errorf("expected %v, got %v", expected, policy)
+	}
+
+	_, err = newTieredMergePolicyFromConfig(map[string]interface{}{
+		"max_merge_at_once": 1.0,
+	})
+	if err == nil {
+		t.Errorf("expected error for max_merge_at_once of 1")
+	}
+}
@@ -0,0 +1,506 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// mergeFlushSize is how much of a merged segment is
+// buffered before it is written out
+var mergeFlushSize = 1 << 20
+
+// mergedDoc is a live document of the sources of a merge,
+// in the order of the merged segment
+type mergedDoc struct {
+	source int32
+	docNum int32
+}
+
+// A segmentMerger writes the live documents of segments
+// as one segment without decoding them all at once.  The
+// documents, stored fields and doc values are copied one
+// document at a time, and the term dictionaries and
+// postings of the sources are walked together, term by
+// term, with the numbers of the documents remapped.  Only
+// the order of the documents, the offset tables and term
+// dictionaries of the segment, the sort keys of a sorted
+// segment and the postings of one term at a time are kept
+// in memory.
+type segmentMerger struct {
+	sources   []*segmentSnapshot
+	sortField string
+	codec     *storedCodec
+
+	order []mergedDoc
+	// docMaps[i][docNum] is the number of the document
+	// docNum of source i in the merged segment, -1 when it
+	// is deleted
+	docMaps      [][]int32
+	fields       []string
+	fieldIndexes map[string]int
+}
+
+// newSegmentMerger orders the live documents of the
+// sources by id, as segments keep them
+func newSegmentMerger(sources []*segmentSnapshot, sortField string, codec *storedCodec) *segmentMerger {
+	if codec == nil {
+		codec = noneCodec
+	}
+	rv := &segmentMerger{
+		sources:      sources,
+		sortField:    sortField,
+		codec:        codec,
+		docMaps:      make([][]int32, len(sources)),
+		fieldIndexes: make(map[string]int),
+	}
+	h := make(mergeDocHeap, 0, len(sources))
+	numDocs := 0
+	for i, source := range sources {
+		rv.docMaps[i] = make([]int32, source.file.numDocs)
+		for docNum := range rv.docMaps[i] {
+			rv.docMaps[i][docNum] = -1
+		}
+		cursor := &mergeDocCursor{source: i, segment: source, docNum: -1}
+		if cursor.advance() {
+			h = append(h, cursor)
+		}
+		numDocs += int(source.liveCount())
+	}
+	heap.Init(&h)
+	rv.order = make([]mergedDoc, 0, numDocs)
+	for h.Len() > 0 {
+		cursor := h[0]
+		rv.docMaps[cursor.source][cursor.docNum] = int32(len(rv.order))
+		rv.order = append(rv.order, mergedDoc{
+			source: int32(cursor.source),
+			docNum: int32(cursor.docNum),
+		})
+		if cursor.advance() {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+
+	for _, source := range sources {
+		for _, field := range source.file.fields {
+			rv.fieldIndex(field)
+		}
+	}
+	if sortField != "" {
+		rv.fieldIndex(sortField)
+	}
+	return rv
+}
+
+func (m *segmentMerger) fieldIndex(name string) int {
+	i, ok := m.fieldIndexes[name]
+	if !ok {
+		i = len(m.fields)
+		m.fields = append(m.fields, name)
+		m.fieldIndexes[name] = i
+	}
+	return i
+}
+
+func (m *segmentMerger) numDocs() int {
+	return len(m.order)
+}
+
+// mergeDocCursor walks the live documents of a source in id
+// order
+type mergeDocCursor struct {
+	source  int
+	segment *segmentSnapshot
+	docNum  int
+	id      string
+}
+
+func (c *mergeDocCursor) advance() bool {
+	for c.docNum++; c.docNum < c.segment.file.numDocs; c.docNum++ {
+		if c.segment.live(c.docNum) {
+			c.id = c.segment.file.docID(c.docNum)
+			return true
+		}
+	}
+	return false
+}
+
+type mergeDocHeap []*mergeDocCursor
+
+func (h mergeDocHeap) Len() int { return len(h) }
+func (h mergeDocHeap) Less(i, j int) bool {
+	if h[i].id != h[j].id {
+		return h[i].id < h[j].id
+	}
+	return h[i].source < h[j].source
+}
+func (h mergeDocHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeDocHeap) Push(x interface{}) { *h = append(*h, x.(*mergeDocCursor)) }
+func (h *mergeDocHeap) Pop() interface{} {
+	old := *h
+	rv := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return rv
+}
+
+// write encodes the merged segment in the layout of
+// buildSegment, flushing e as it goes
+func (m *segmentMerger) write(e *encoder) error {
+	numDocs := m.numDocs()
+	e.buf.WriteString(segmentMagic)
+
+	fieldsOffset := e.offset()
+	e.uvarint(uint64(len(m.fields)))
+	for _, field := range m.fields {
+		e.bytes([]byte(field))
+	}
+
+	// the (field, term) pairs of the documents
+	docOffsets := make([]uint64, numDocs)
+	for docNum, md := range m.order {
+		doc, err := m.sources[md.source].file.document(int(md.docNum))
+		if err != nil {
+			return err
+		}
+		docOffsets[docNum] = e.offset()
+		e.bytes([]byte(doc.id))
+		e.uvarint(uint64(len(doc.terms)))
+		for _, dt := range doc.terms {
+			e.uvarint(uint64(m.fieldIndexes[dt.field]))
+			e.bytes([]byte(dt.term))
+		}
+		if err := e.flush(mergeFlushSize); err != nil {
+			return err
+		}
+	}
+
+	blockOffsets := make([]uint64, 0, (numDocs+storedBlockSize-1)/storedBlockSize)
+	for start := 0; start < numDocs; start += storedBlockSize {
+		block := encoder{}
+		for docNum := start; docNum < numDocs && docNum < start+storedBlockSize; docNum++ {
+			md := m.order[docNum]
+			stored, err := m.storedFields(md)
+			if err != nil {
+				return err
+			}
+			docStored := encoder{}
+			encodeStoredFields(&docStored, m.fieldIndexes, stored)
+			block.bytes(docStored.buf.Bytes())
+		}
+		blockOffsets = append(blockOffsets, e.offset())
+		e.bytes(m.codec.encode(block.buf.Bytes()))
+		if err := e.flush(mergeFlushSize); err != nil {
+			return err
+		}
+	}
+
+	dictData := make([][2][]byte, len(m.fields))
+	dictTerms := make([]int, len(m.fields))
+	pe := postingsEncoder{fieldIndexes: m.fieldIndexes}
+	for f, field := range m.fields {
+		entries, err := m.writePostings(e, &pe, field)
+		if err != nil {
+			return err
+		}
+		entryData, fstData := encodeFieldDict(entries)
+		dictData[f] = [2][]byte{entryData, fstData}
+		dictTerms[f] = len(entries)
+	}
+
+	columnFields := make([]int, 0)
+	for f, field := range m.fields {
+		for _, source := range m.sources {
+			if sf, ok := source.file.fieldIndexes[field]; ok {
+				if _, ok := source.file.columns[sf]; ok {
+					columnFields = append(columnFields, f)
+					break
+				}
+			}
+		}
+	}
+	columnOffsets := make([]uint64, len(columnFields))
+	for i, f := range columnFields {
+		var err error
+		columnOffsets[i], err = m.writeColumn(e, m.fields[f])
+		if err != nil {
+			return err
+		}
+	}
+	columnsOffset := e.offset()
+	e.uvarint(uint64(len(columnFields)))
+	for i, f := range columnFields {
+		e.uvarint(uint64(f))
+		e.uvarint(columnOffsets[i])
+	}
+
+	sortOffset := uint64(0)
+	if m.sortField != "" {
+		entries := make(sortEntries, numDocs)
+		for docNum, md := range m.order {
+			entry := &sortEntry{
+				docNum: docNum,
+				id:     m.sources[md.source].file.docID(int(md.docNum)),
+			}
+			var err error
+			entry.key, entry.hasKey, err = m.sortKey(md)
+			if err != nil {
+				return err
+			}
+			entries[docNum] = entry
+		}
+		sort.Sort(entries)
+		sortOffset = e.offset()
+		e.uvarint(uint64(m.fieldIndexes[m.sortField]))
+		for _, entry := range entries {
+			e.uvarint(uint64(entry.docNum))
+			if entry.hasKey {
+				e.buf.WriteByte(1)
+			} else {
+				e.buf.WriteByte(0)
+			}
+			e.bytes([]byte(entry.key))
+			if err := e.flush(mergeFlushSize); err != nil {
+				return err
+			}
+		}
+	}
+
+	dictOffset := e.offset()
+	for f := range m.fields {
+		e.uvarint(uint64(dictTerms[f]))
+		e.bytes(dictData[f][0])
+		e.bytes(dictData[f][1])
+	}
+
+	blockTableOffset := e.offset()
+	for _, offset := range blockOffsets {
+		e.fixed(offset)
+	}
+
+	docTableOffset := e.offset()
+	for _, offset := range docOffsets {
+		e.fixed(offset)
+		if err := e.flush(mergeFlushSize); err != nil {
+			return err
+		}
+	}
+
+	e.fixed(fieldsOffset)
+	e.fixed(dictOffset)
+	e.fixed(docTableOffset)
+	e.fixed(columnsOffset)
+	e.fixed(sortOffset)
+	e.fixed(blockTableOffset)
+	e.fixed(uint64(m.codec.id))
+	e.fixed(uint64(numDocs))
+	return e.flush(0)
+}
+
+func (m *segmentMerger) storedFields(md mergedDoc) ([]*storedField, error) {
+	source := m.sources[md.source].file
+	if source.storedTable == 0 {
+		// the first version keeps them with the document
+		doc, err := source.document(int(md.docNum))
+		if err != nil {
+			return nil, err
+		}
+		return doc.stored, nil
+	}
+	return source.storedFields(int(md.docNum))
+}
+
+// mergeTermCursor walks the terms of a field in a source
+type mergeTermCursor struct {
+	source int
+	terms  *termIterator
+	entry  *dictEntry
+}
+
+// mergePostingsCursor walks the postings of a term in a source,
+// with the documents numbered as in the merged segment
+type mergePostingsCursor struct {
+	postings *postingsIterator
+	docMap   []int32
+	posting  *posting
+}
+
+func (c *mergePostingsCursor) advance() error {
+	for {
+		p, err := c.postings.next()
+		if err != nil || p == nil {
+			c.posting = nil
+			return err
+		}
+		if docNum := c.docMap[p.docNum]; docNum >= 0 {
+			p.docNum = int(docNum)
+			c.posting = p
+			return nil
+		}
+	}
+}
+
+// writePostings writes the postings of the terms of the
+// field in term order, it returns their dictionary
+// entries.  Terms only in deleted documents are left out.
+func (m *segmentMerger) writePostings(e *encoder, pe *postingsEncoder, field string) ([]*dictEntry, error) {
+	cursors := make([]*mergeTermCursor, 0, len(m.sources))
+	for i, source := range m.sources {
+		terms, err := source.file.terms(field, nil, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		cursor := &mergeTermCursor{source: i, terms: terms}
+		cursor.entry, err = terms.next()
+		if err != nil {
+			return nil, err
+		}
+		if cursor.entry != nil {
+			cursors = append(cursors, cursor)
+		}
+	}
+
+	var rv []*dictEntry
+	postings := make([]*mergePostingsCursor, 0, len(cursors))
+	for len(cursors) > 0 {
+		term := cursors[0].entry.term
+		for _, cursor := range cursors[1:] {
+			if cursor.entry.term < term {
+				term = cursor.entry.term
+			}
+		}
+
+		postings = postings[:0]
+		remaining := cursors[:0]
+		for _, cursor := range cursors {
+			if cursor.entry.term == term {
+				pc := &mergePostingsCursor{
+					postings: m.sources[cursor.source].file.postings(cursor.entry),
+					docMap:   m.docMaps[cursor.source],
+				}
+				if err := pc.advance(); err != nil {
+					return nil, err
+				}
+				if pc.posting != nil {
+					postings = append(postings, pc)
+				}
+				var err error
+				cursor.entry, err = cursor.terms.next()
+				if err != nil {
+					return nil, err
+				}
+			}
+			if cursor.entry != nil {
+				remaining = append(remaining, cursor)
+			}
+		}
+		cursors = remaining
+
+		pe.reset()
+		for len(postings) > 0 {
+			next := 0
+			for i, pc := range postings[1:] {
+				if pc.posting.docNum < postings[next].posting.docNum {
+					next = i + 1
+				}
+			}
+			pe.add(postings[next].posting)
+			if err := postings[next].advance(); err != nil {
+				return nil, err
+			}
+			if postings[next].posting == nil {
+				postings = append(postings[:next], postings[next+1:]...)
+			}
+		}
+		if len(pe.docNums) > 0 {
+			rv = append(rv, pe.write(e, term, m.numDocs()))
+			if err := e.flush(mergeFlushSize); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rv, nil
+}
+
+// docValues returns the doc values of the field of a
+// document, documents without any have none in the merged
+// segment
+func (m *segmentMerger) docValues(md mergedDoc, field string) ([]string, bool, error) {
+	terms, _, err := m.sources[md.source].file.docValues(int(md.docNum), field)
+	return terms, len(terms) > 0, err
+}
+
+// writeColumn writes the doc values of the field, the
+// offsets of the values of every document and then the
+// values, it returns the offset of the column
+func (m *segmentMerger) writeColumn(e *encoder, field string) (uint64, error) {
+	rv := e.offset()
+	valuesOffset := rv + uint64(8*m.numDocs())
+	size := encoder{}
+	for _, md := range m.order {
+		terms, ok, err := m.docValues(md, field)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			e.fixed(0)
+			continue
+		}
+		e.fixed(valuesOffset)
+		size.buf.Reset()
+		encodeDocValues(&size, terms)
+		valuesOffset += uint64(size.buf.Len())
+		if err := e.flush(mergeFlushSize); err != nil {
+			return 0, err
+		}
+	}
+	for _, md := range m.order {
+		terms, ok, err := m.docValues(md, field)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			encodeDocValues(e, terms)
+		}
+		if err := e.flush(mergeFlushSize); err != nil {
+			return 0, err
+		}
+	}
+	return rv, nil
+}
+
+// sortKey returns the key of a document of a source like
+// segmentDoc.sortKey does
+func (m *segmentMerger) sortKey(md mergedDoc) (key string, ok bool, err error) {
+	terms, hasDocValues, err := m.docValues(md, m.sortField)
+	if err != nil {
+		return "", false, err
+	}
+	if hasDocValues {
+		for _, term := range terms {
+			if !ok || term < key {
+				key, ok = term, true
+			}
+		}
+		return key, ok, nil
+	}
+	doc, err := m.sources[md.source].file.document(int(md.docNum))
+	if err != nil {
+		return "", false, err
+	}
+	for _, dt := range doc.terms {
+		if dt.field == m.sortField && (!ok || dt.term < key) {
+			key, ok = dt.term, true
+		}
+	}
+	return key, ok, nil
+}
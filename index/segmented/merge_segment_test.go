@@ -0,0 +1,215 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/index"
+	"github.com/willf/bitset"
+)
+
+type testPosting struct {
+	id      string
+	freq    uint64
+	norm    float32
+	vectors []*index.TermFieldVector
+}
+
+type testSegmentContents struct {
+	ids       []string
+	stored    map[string][]*storedField
+	docValues map[string][]string
+	postings  map[string][]testPosting
+	// whether each term got a skip table and a bitmap
+	tables map[string][2]bool
+	sorted []string
+}
+
+func testContents(t *testing.T, s *segment) *testSegmentContents {
+	rv := &testSegmentContents{
+		stored:    make(map[string][]*storedField),
+		docValues: make(map[string][]string),
+		postings:  make(map[string][]testPosting),
+		tables:    make(map[string][2]bool),
+	}
+	for docNum := 0; docNum < s.numDocs; docNum++ {
+		id := s.docID(docNum)
+		rv.ids = append(rv.ids, id)
+		stored, err := s.storedFields(docNum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rv.stored[id] = stored
+		for _, field := range s.fields {
+			terms, ok, err := s.docValues(docNum, field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok {
+				rv.docValues[id+"/"+field] = terms
+			}
+		}
+	}
+	for _, field := range s.fields {
+		terms, err := s.terms(field, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, err := terms.next()
+		for entry != nil {
+			key := field + "/" + entry.term
+			rv.tables[key] = [2]bool{entry.skip > 0, entry.bitmap > 0}
+			postings := s.postings(entry)
+			p, perr := postings.next()
+			for p != nil {
+				rv.postings[key] = append(rv.postings[key], testPosting{
+					id:      s.docID(p.docNum),
+					freq:    p.freq,
+					norm:    p.norm,
+					vectors: p.vectors,
+				})
+				p, perr = postings.next()
+			}
+			if perr != nil {
+				t.Fatal(perr)
+			}
+			if uint64(len(rv.postings[key])) != entry.count {
+				t.Errorf("expected %d postings of %s, got %d", entry.count, key, len(rv.postings[key]))
+			}
+			entry, err = terms.next()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sorted := s.sorted()
+	entry, err := sorted.next()
+	for entry != nil {
+		rv.sorted = append(rv.sorted, s.docID(entry.docNum))
+		entry, err = sorted.next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rv
+}
+
+func TestSegmentMerger(t *testing.T) {
+	// enough documents for skip tables and bitmaps
+	numDocs := 900
+	docs := make([]*segmentDoc, numDocs)
+	for i := range docs {
+		id := fmt.Sprintf("doc%04d", i)
+		doc := &segmentDoc{
+			id: id,
+			stored: []*storedField{
+				&storedField{field: "name", typ: 't', value: []byte(id)},
+			},
+			terms: []*docTerm{
+				&docTerm{field: "name", term: id, freq: 1, norm: 1.0},
+				&docTerm{
+					field: "all",
+					term:  "common",
+					freq:  uint64(i%3 + 1),
+					norm:  0.5,
+					vectors: []*index.TermFieldVector{
+						&index.TermFieldVector{Field: "body", Pos: 1, Start: 0, End: 6, Payload: []byte{byte(i)}},
+					},
+				},
+				&docTerm{field: "group", term: fmt.Sprintf("g%d", i%7), freq: 1, norm: 1.0},
+			},
+		}
+		if i%2 == 0 {
+			doc.docValues = []*docValue{
+				&docValue{field: "group", terms: []string{fmt.Sprintf("g%d", i%7)}},
+			}
+		}
+		if i%100 == 0 {
+			// only in documents which get deleted
+			doc.terms = append(doc.terms, &docTerm{field: "rare", term: "gone", freq: 1, norm: 1.0})
+		}
+		docs[i] = doc
+	}
+
+	// streamed merges flush after every write
+	defer func(size int) {
+		mergeFlushSize = size
+	}(mergeFlushSize)
+	mergeFlushSize = 1
+
+	for _, sortField := range []string{"", "group"} {
+		// the documents spread over three segments, every
+		// fifth one deleted
+		sources := make([]*segmentSnapshot, 3)
+		var live []*segmentDoc
+		for i := range sources {
+			var sourceDocs []*segmentDoc
+			for j := i; j < numDocs; j += len(sources) {
+				sourceDocs = append(sourceDocs, docs[j])
+			}
+			s, err := loadSegment(buildSegment(sourceDocs, sortField, snappyCodec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			deleted := bitset.New(uint(s.numDocs))
+			for docNum, doc := range sourceDocs {
+				var n int
+				_, _ = fmt.Sscanf(doc.id, "doc%d", &n)
+				if n%5 == 0 {
+					deleted.Set(uint(docNum))
+				} else {
+					live = append(live, doc)
+				}
+			}
+			sources[i] = &segmentSnapshot{
+				file:    &segmentFile{segment: s},
+				deleted: deleted,
+			}
+		}
+
+		memory := encoder{}
+		err := newSegmentMerger(sources, sortField, snappyCodec).write(&memory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var streamed bytes.Buffer
+		err = newSegmentMerger(sources, sortField, snappyCodec).write(&encoder{w: &streamed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(memory.buf.Bytes(), streamed.Bytes()) {
+			t.Fatalf("expected streamed merge to match the merge in memory")
+		}
+
+		merged, err := loadSegment(streamed.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := loadSegment(buildSegment(live, sortField, snappyCodec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		actualContents := testContents(t, merged)
+		expectedContents := testContents(t, expected)
+		if !reflect.DeepEqual(actualContents, expectedContents) {
+			t.Errorf("expected merged segment sorted by %q to hold the live documents", sortField)
+		}
+		if _, ok := actualContents.postings["rare/gone"]; ok {
+			t.Errorf("expected terms of deleted documents to be dropped")
+		}
+		if !actualContents.tables["all/common"][0] || !actualContents.tables["all/common"][1] {
+			t.Errorf("expected a skip table and a bitmap for all/common")
+		}
+	}
+}
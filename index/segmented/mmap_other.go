@@ -0,0 +1,31 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package segmented
+
+import (
+	"io"
+	"os"
+)
+
+// without mmap the segment is read into memory
+func mmap(f *os.File, size int) ([]byte, error) {
+	rv := make([]byte, size)
+	_, err := io.ReadFull(f, rv)
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,281 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Object storage keeps the segments of an index, which
+// never change once written, and its latest commit point
+// as objects, such as those of an S3 bucket.  A primary
+// index ships to the objects with an ObjectFollower.  Any
+// number of indexes, on as many nodes, can then be opened
+// from the objects as replicas, their segment directory
+// caching the segments they fetched.
+
+var ErrObjectNotFound = fmt.Errorf("object not found")
+
+// An ObjectStore keeps objects by name, Put replaces an
+// object as a whole, and Get of a missing object returns
+// ErrObjectNotFound
+type ObjectStore interface {
+	Get(name string) ([]byte, error)
+	Put(name string, data []byte) error
+	Delete(name string) error
+	// List returns the names starting with prefix, sorted
+	List(prefix string) ([]string, error)
+}
+
+const segmentObjectPrefix = "segments/"
+const commitObjectName = "commit"
+
+func segmentObjectName(id uint64) string {
+	return fmt.Sprintf("%s%016x%s", segmentObjectPrefix, id, segmentFileSuffix)
+}
+
+func parseSegmentObjectName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, segmentObjectPrefix) || !strings.HasSuffix(name, segmentFileSuffix) {
+		return 0, false
+	}
+	hex := name[len(segmentObjectPrefix) : len(name)-len(segmentFileSuffix)]
+	id, err := strconv.ParseUint(hex, 16, 64)
+	return id, err == nil
+}
+
+type objectFollower struct {
+	objects ObjectStore
+	// segments of the latest commit point, and of the
+	// one before it
+	current  map[uint64]bool
+	previous map[uint64]bool
+}
+
+// NewObjectFollower returns a Follower keeping what it
+// receives in objects.  Replicas may still be fetching
+// the segments of the commit point before the latest, so
+// a segment is only deleted once neither of the last two
+// commit points lists it.  Segments left over from earlier
+// runs of the primary count as those of the commit point
+// before the first.
+func NewObjectFollower(objects ObjectStore) Follower {
+	return &objectFollower{
+		objects: objects,
+	}
+}
+
+func (f *objectFollower) AddSegment(id uint64, data []byte) error {
+	return f.objects.Put(segmentObjectName(id), data)
+}
+
+func (f *objectFollower) Commit(point *CommitPoint) error {
+	if f.current == nil {
+		names, err := f.objects.List(segmentObjectPrefix)
+		if err != nil {
+			return err
+		}
+		f.current = make(map[uint64]bool, len(names))
+		for _, name := range names {
+			if id, ok := parseSegmentObjectName(name); ok {
+				f.current[id] = true
+			}
+		}
+	}
+
+	data, err := json.Marshal(point)
+	if err != nil {
+		return err
+	}
+	err = f.objects.Put(commitObjectName, data)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[uint64]bool, len(point.Segments))
+	for _, cs := range point.Segments {
+		listed[cs.ID] = true
+	}
+	for id := range f.previous {
+		if !f.current[id] && !listed[id] {
+			err = f.objects.Delete(segmentObjectName(id))
+			if err != nil && err != ErrObjectNotFound {
+				return err
+			}
+		}
+	}
+	f.previous = f.current
+	f.current = listed
+	return nil
+}
+
+// SetObjectStore makes the index a replica of the index
+// shipping to objects, it must be called before Open.
+// Open pulls the latest commit point, and Pull the ones
+// after it.
+func (s *Segmented) SetObjectStore(objects ObjectStore) {
+	s.objects = objects
+}
+
+// Pull makes the latest commit point in the object store
+// the state of the index, fetching the segments which are
+// not cached yet
+func (s *Segmented) Pull() error {
+	if s.objects == nil {
+		return fmt.Errorf("no object store to pull from")
+	}
+	s.pullMutex.Lock()
+	defer s.pullMutex.Unlock()
+
+	data, err := s.objects.Get(commitObjectName)
+	if err == ErrObjectNotFound {
+		// nothing was shipped yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.pulled != nil && string(data) == string(s.pulled) {
+		return nil
+	}
+	var point CommitPoint
+	err = json.Unmarshal(data, &point)
+	if err != nil {
+		return fmt.Errorf("error decoding commit point: %v", err)
+	}
+
+	cached := make(map[uint64]bool)
+	snapshot := s.currentSnapshot()
+	for _, segment := range snapshot.segments {
+		cached[segment.file.id] = true
+	}
+	err = snapshot.decRef()
+	if err != nil {
+		return err
+	}
+	for _, cs := range point.Segments {
+		if cached[cs.ID] {
+			continue
+		}
+		var segment []byte
+		segment, err = s.objects.Get(segmentObjectName(cs.ID))
+		if err != nil {
+			return fmt.Errorf("error fetching segment %d: %v", cs.ID, err)
+		}
+		err = s.AddSegment(cs.ID, segment)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.Commit(&point)
+	if err != nil {
+		return err
+	}
+	s.pulled = data
+	return nil
+}
+
+type directoryObjectStore struct {
+	path string
+}
+
+// NewDirectoryObjectStore returns an ObjectStore keeping
+// the objects as files in path, such as a directory
+// shared over the network
+func NewDirectoryObjectStore(path string) ObjectStore {
+	return &directoryObjectStore{
+		path: path,
+	}
+}
+
+func (d *directoryObjectStore) objectPath(name string) string {
+	return filepath.Join(d.path, filepath.FromSlash(name))
+}
+
+func (d *directoryObjectStore) Get(name string) ([]byte, error) {
+	rv, err := ioutil.ReadFile(d.objectPath(name))
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	return rv, err
+}
+
+// Put writes a temporary file and renames it, so that an
+// object is never seen half written
+func (d *directoryObjectStore) Put(name string, data []byte) (err error) {
+	path := d.objectPath(name)
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (d *directoryObjectStore) Delete(name string) error {
+	err := os.Remove(d.objectPath(name))
+	if os.IsNotExist(err) {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+func (d *directoryObjectStore) List(prefix string) ([]string, error) {
+	rv := make([]string, 0)
+	err := filepath.Walk(d.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == d.path {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.path, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			rv = append(rv, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(rv)
+	return rv, nil
+}
@@ -0,0 +1,331 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/index"
+)
+
+// postingsCursor walks the live postings of a term in one
+// segment
+type postingsCursor struct {
+	segment  *segmentSnapshot
+	entry    *dictEntry
+	postings *postingsIterator
+	curr     *posting
+	currID   string
+	// number of the document last returned, -1 before
+	// the first
+	returned int
+}
+
+func (c *postingsCursor) next() error {
+	return c.seek(0)
+}
+
+// seek moves to the first live posting of a document
+// numbered docNum or more, whole blocks of postings are
+// skipped and the ids of the others skipped are not looked
+// up
+func (c *postingsCursor) seek(docNum int) error {
+	c.postings.skipTo(docNum)
+	for {
+		p, err := c.postings.next()
+		if err != nil || p == nil {
+			c.curr = nil
+			return err
+		}
+		if p.docNum >= docNum && c.segment.live(p.docNum) {
+			c.curr = p
+			c.currID = c.segment.file.docID(p.docNum)
+			return nil
+		}
+	}
+}
+
+// SegmentedTermFieldReader merges the postings of a term
+// in all segments, in document id order
+type SegmentedTermFieldReader struct {
+	term    string
+	count   uint64
+	cursors []*postingsCursor
+}
+
+func newSegmentedTermFieldReader(indexReader *IndexReader, term, field string) (*SegmentedTermFieldReader, error) {
+	rv := SegmentedTermFieldReader{
+		term: term,
+	}
+	for _, segment := range indexReader.snapshot.segments {
+		entry, err := segment.file.dictEntry(field, term)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+		count, err := segment.termCount(entry)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			continue
+		}
+		rv.count += count
+		cursor := postingsCursor{
+			segment:  segment,
+			entry:    entry,
+			postings: segment.file.postings(entry),
+			returned: -1,
+		}
+		err = cursor.next()
+		if err != nil {
+			return nil, err
+		}
+		rv.cursors = append(rv.cursors, &cursor)
+	}
+	return &rv, nil
+}
+
+func (r *SegmentedTermFieldReader) Count() uint64 {
+	return r.count
+}
+
+func (r *SegmentedTermFieldReader) Next() (*index.TermFieldDoc, error) {
+	var min *postingsCursor
+	for _, cursor := range r.cursors {
+		if cursor.curr != nil && (min == nil || cursor.currID < min.currID) {
+			min = cursor
+		}
+	}
+	if min == nil {
+		return nil, nil
+	}
+	rv := index.TermFieldDoc{
+		Term:    r.term,
+		ID:      min.currID,
+		Freq:    min.curr.freq,
+		Norm:    float64(min.curr.norm),
+		Vectors: min.curr.vectors,
+	}
+	min.returned = min.curr.docNum
+	err := min.next()
+	if err != nil {
+		return nil, err
+	}
+	return &rv, nil
+}
+
+// Advance returns the first document from docID on, like
+// the readers of the other indexes it goes back to one
+// returned before when docID asks for it
+func (r *SegmentedTermFieldReader) Advance(docID string) (*index.TermFieldDoc, error) {
+	for _, cursor := range r.cursors {
+		docNum := cursor.segment.file.searchDocNum(docID)
+		if cursor.returned >= docNum {
+			cursor.postings = cursor.segment.file.postings(cursor.entry)
+			cursor.returned = -1
+		} else if cursor.curr == nil || cursor.curr.docNum >= docNum {
+			continue
+		}
+		err := cursor.seek(docNum)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.Next()
+}
+
+func (r *SegmentedTermFieldReader) Close() error {
+	return nil
+}
+
+// docIDCursor walks the live documents of one segment
+// within the range of a DocIDReader
+type docIDCursor struct {
+	segment *segmentSnapshot
+	docNum  int
+	currID  string
+	valid   bool
+}
+
+func (c *docIDCursor) seek(docNum int, end string) {
+	for c.docNum = docNum; c.docNum < c.segment.file.numDocs; c.docNum++ {
+		if !c.segment.live(c.docNum) {
+			continue
+		}
+		c.currID = c.segment.file.docID(c.docNum)
+		c.valid = end == "" || c.currID <= end
+		return
+	}
+	c.valid = false
+}
+
+type SegmentedDocIDReader struct {
+	end     string
+	cursors []*docIDCursor
+}
+
+func newSegmentedDocIDReader(indexReader *IndexReader, start, end string) *SegmentedDocIDReader {
+	rv := SegmentedDocIDReader{
+		end:     end,
+		cursors: make([]*docIDCursor, len(indexReader.snapshot.segments)),
+	}
+	for i, segment := range indexReader.snapshot.segments {
+		rv.cursors[i] = &docIDCursor{
+			segment: segment,
+		}
+		rv.cursors[i].seek(segment.file.searchDocNum(start), end)
+	}
+	return &rv
+}
+
+func (r *SegmentedDocIDReader) Next() (string, error) {
+	var min *docIDCursor
+	for _, cursor := range r.cursors {
+		if cursor.valid && (min == nil || cursor.currID < min.currID) {
+			min = cursor
+		}
+	}
+	if min == nil {
+		return "", nil
+	}
+	rv := min.currID
+	min.seek(min.docNum+1, r.end)
+	return rv, nil
+}
+
+func (r *SegmentedDocIDReader) Advance(docID string) (string, error) {
+	for _, cursor := range r.cursors {
+		if cursor.valid && cursor.currID < docID {
+			cursor.seek(cursor.segment.file.searchDocNum(docID), r.end)
+		}
+	}
+	return r.Next()
+}
+
+func (r *SegmentedDocIDReader) Close() error {
+	return nil
+}
+
+// sortCursor walks the live documents of one segment in
+// the order of the sort field
+type sortCursor struct {
+	segment *segmentSnapshot
+	sorted  *sortIterator
+	curr    *sortEntry
+}
+
+func (c *sortCursor) next() error {
+	for {
+		entry, err := c.sorted.next()
+		if err != nil || entry == nil {
+			c.curr = nil
+			return err
+		}
+		if c.segment.live(entry.docNum) {
+			entry.id = c.segment.file.docID(entry.docNum)
+			c.curr = entry
+			return nil
+		}
+	}
+}
+
+// SegmentedSortedDocIDReader merges the sorted documents
+// of all segments
+type SegmentedSortedDocIDReader struct {
+	cursors []*sortCursor
+}
+
+func newSegmentedSortedDocIDReader(indexReader *IndexReader) (*SegmentedSortedDocIDReader, error) {
+	sortField := indexReader.index.sortField
+	if sortField == "" {
+		return nil, fmt.Errorf("index is not sorted")
+	}
+	rv := SegmentedSortedDocIDReader{
+		cursors: make([]*sortCursor, len(indexReader.snapshot.segments)),
+	}
+	for i, segment := range indexReader.snapshot.segments {
+		if segment.file.sortField != sortField {
+			return nil, fmt.Errorf("segment %d is not sorted by %s", segment.file.id, sortField)
+		}
+		rv.cursors[i] = &sortCursor{
+			segment: segment,
+			sorted:  segment.file.sorted(),
+		}
+		err := rv.cursors[i].next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &rv, nil
+}
+
+func (r *SegmentedSortedDocIDReader) Next() (string, error) {
+	var min *sortCursor
+	for _, cursor := range r.cursors {
+		if cursor.curr != nil && (min == nil || sortEntryLess(cursor.curr, min.curr)) {
+			min = cursor
+		}
+	}
+	if min == nil {
+		return "", nil
+	}
+	rv := min.curr.id
+	err := min.next()
+	if err != nil {
+		return "", err
+	}
+	return rv, nil
+}
+
+func (r *SegmentedSortedDocIDReader) Close() error {
+	return nil
+}
+
+type SegmentedDocValueReader struct {
+	snapshot *indexSnapshot
+	fields   []string
+}
+
+func (r *SegmentedDocValueReader) VisitDocValues(id string, visitor index.DocValueVisitor) error {
+	segment, docNum, ok := r.snapshot.lookup(id)
+	if !ok {
+		return nil
+	}
+	var doc *segmentDoc
+	for _, field := range r.fields {
+		terms, ok, err := segment.file.docValues(docNum, field)
+		if err != nil {
+			return err
+		}
+		if ok {
+			for _, term := range terms {
+				visitor(field, []byte(term))
+			}
+			continue
+		}
+
+		// fields without doc values use the terms of the document
+		if doc == nil {
+			doc, err = segment.file.document(docNum)
+			if err != nil {
+				return err
+			}
+		}
+		for _, dt := range doc.terms {
+			if dt.field == field {
+				visitor(field, []byte(dt.term))
+			}
+		}
+	}
+	return nil
+}
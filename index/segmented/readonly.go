@@ -0,0 +1,28 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"fmt"
+)
+
+// ErrReadOnly is returned by changes of an index opened
+// read only
+var ErrReadOnly = fmt.Errorf("cannot update an index opened read only")
+
+// SetReadOnly makes the index open without writing to its
+// store or its segment files and without merging in the
+// background, so that other processes can read the same
+// files.  The store must be opened read only as well, see
+// the "read_only" config of the stores.  It must be called
+// before Open.
+func (s *Segmented) SetReadOnly() {
+	s.readOnly = true
+}
@@ -0,0 +1,119 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// SetRefreshInterval controls when changes become visible
+// to new readers.  With 0, the default, every change is
+// visible as soon as it is made.  With a positive interval
+// the changes made since the last refresh become visible
+// once per interval, and with a negative one only on
+// Refresh.  Changes are durable either way, and the
+// internal values are always read as they are.
+func (s *Segmented) SetRefreshInterval(interval time.Duration) {
+	atomic.StoreInt64(&s.refreshInterval, int64(interval))
+	if interval == 0 {
+		s.refresh()
+	}
+	select {
+	case s.refreshNotify <- struct{}{}:
+	default:
+	}
+}
+
+// Refresh makes all changes made so far visible to new
+// readers
+func (s *Segmented) Refresh() error {
+	s.refresh()
+	return nil
+}
+
+func (s *Segmented) refreshImmediately() bool {
+	return atomic.LoadInt64(&s.refreshInterval) == 0
+}
+
+// refresh replaces the visible snapshot with the root
+func (s *Segmented) refresh() {
+	s.m.Lock()
+	if s.visible == s.root {
+		s.m.Unlock()
+		return
+	}
+	prev := s.visible
+	s.root.addRef()
+	s.visible = s.root
+	s.m.Unlock()
+	if err := prev.decRef(); err != nil {
+		atomic.AddUint64(&s.stats.errors, 1)
+	}
+	atomic.AddUint64(&s.stats.refreshes, 1)
+}
+
+// visibleSnapshot is the snapshot new readers see
+func (s *Segmented) visibleSnapshot() *indexSnapshot {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	s.visible.addRef()
+	return s.visible
+}
+
+func (s *Segmented) refreshLoop() {
+	defer s.refreshDone.Done()
+	for {
+		var timer *time.Timer
+		var tick <-chan time.Time
+		interval := time.Duration(atomic.LoadInt64(&s.refreshInterval))
+		if interval > 0 {
+			timer = time.NewTimer(interval)
+			tick = timer.C
+		}
+		select {
+		case <-s.closeCh:
+		case <-s.refreshNotify:
+		case <-tick:
+			s.refresh()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-s.closeCh:
+			return
+		default:
+		}
+	}
+}
+
+// refreshIntervalFromConfig reads the "refresh_interval"
+// of the index config, a duration such as "1s", or "-1"
+// to only refresh on request
+func refreshIntervalFromConfig(config map[string]interface{}) (time.Duration, bool, error) {
+	v, ok := config["refresh_interval"]
+	if !ok {
+		return 0, false, nil
+	}
+	str, ok := v.(string)
+	if !ok {
+		return 0, false, fmt.Errorf("refresh_interval must be a duration")
+	}
+	if str == "-1" {
+		return -1, true, nil
+	}
+	interval, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid refresh_interval: %v", err)
+	}
+	return interval, true, nil
+}
@@ -0,0 +1,475 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/willf/bitset"
+)
+
+// Replication ships the segments of a primary index to
+// followers, so that they can serve searches without
+// analyzing and indexing the documents again.  Segments
+// are immutable, so a follower is sent each segment once
+// and then a commit point for every change of the
+// primary, which lists the segments of the primary along
+// with their deletions.  A follower which falls behind
+// only gets the latest commit point.
+
+var ErrReplica = fmt.Errorf("cannot update a replica, it only changes by replication")
+var ErrReplicaNotEmpty = fmt.Errorf("only an empty index can become a replica")
+
+// CommitSegment is a segment of a commit point
+type CommitSegment struct {
+	ID      uint64         `json:"id"`
+	Deleted *bitset.BitSet `json:"deleted"`
+}
+
+// CommitPoint is the state of a primary index, Internal
+// holds all of its internal values
+type CommitPoint struct {
+	Segments  []*CommitSegment  `json:"segments"`
+	Fields    []string          `json:"fields"`
+	SortField string            `json:"sort_field,omitempty"`
+	Internal  map[string][]byte `json:"internal"`
+}
+
+// A Follower receives the changes of a primary index.
+// AddSegment is called for each segment before the first
+// commit point listing it.  A Segmented index is a
+// Follower, it becomes a replica of the primary when it
+// is first given a segment or commit point.
+type Follower interface {
+	AddSegment(id uint64, data []byte) error
+	Commit(point *CommitPoint) error
+}
+
+type follower struct {
+	Follower
+	// the segments the follower was sent
+	sent    map[uint64]bool
+	notify  chan struct{}
+	closeCh chan struct{}
+}
+
+// AddFollower starts shipping the current state of the
+// index to f, and every change after that.  A follower
+// returning an error is removed.
+func (s *Segmented) AddFollower(f Follower) {
+	s.followersMutex.Lock()
+	defer s.followersMutex.Unlock()
+
+	if _, exists := s.followers[f]; exists {
+		return
+	}
+	fl := &follower{
+		Follower: f,
+		sent:     make(map[uint64]bool),
+		notify:   make(chan struct{}, 1),
+		closeCh:  make(chan struct{}),
+	}
+	fl.notify <- struct{}{}
+	s.followers[f] = fl
+	s.followersDone.Add(1)
+	go s.shipLoop(fl)
+}
+
+// RemoveFollower stops shipping changes to f
+func (s *Segmented) RemoveFollower(f Follower) {
+	s.followersMutex.Lock()
+	defer s.followersMutex.Unlock()
+
+	if fl, exists := s.followers[f]; exists {
+		close(fl.closeCh)
+		delete(s.followers, f)
+	}
+}
+
+func (s *Segmented) notifyFollowers() {
+	s.followersMutex.Lock()
+	defer s.followersMutex.Unlock()
+
+	for _, fl := range s.followers {
+		select {
+		case fl.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Segmented) shipLoop(fl *follower) {
+	defer s.followersDone.Done()
+	for {
+		select {
+		case <-s.closeCh:
+			return
+		case <-fl.closeCh:
+			return
+		case <-fl.notify:
+		}
+		err := s.ship(fl)
+		if err != nil {
+			atomic.AddUint64(&s.stats.errors, 1)
+			s.RemoveFollower(fl.Follower)
+			return
+		}
+	}
+}
+
+// ship sends the current snapshot to the follower
+func (s *Segmented) ship(fl *follower) (err error) {
+	snapshot := s.currentSnapshot()
+	defer func() {
+		if cerr := snapshot.decRef(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	point := &CommitPoint{
+		Segments:  make([]*CommitSegment, len(snapshot.segments)),
+		Fields:    s.fieldNames(),
+		SortField: s.sortField,
+	}
+	sent := make(map[uint64]bool, len(snapshot.segments))
+	for i, segment := range snapshot.segments {
+		if !fl.sent[segment.file.id] {
+			var data []byte
+			data, err = s.segmentFileData(segment.file)
+			if err != nil {
+				return
+			}
+			err = fl.AddSegment(segment.file.id, data)
+			if err != nil {
+				return
+			}
+		}
+		sent[segment.file.id] = true
+		point.Segments[i] = &CommitSegment{
+			ID:      segment.file.id,
+			Deleted: segment.deleted,
+		}
+	}
+	fl.sent = sent
+
+	point.Internal, err = s.internalValues()
+	if err != nil {
+		return
+	}
+	return fl.Commit(point)
+}
+
+func (s *Segmented) internalValues() (rv map[string][]byte, err error) {
+	var kvreader store.KVReader
+	kvreader, err = s.store.Reader()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := kvreader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	rv = make(map[string][]byte)
+	keyPrefix := []byte{internalPrefix}
+	it := kvreader.Iterator(keyPrefix)
+	defer func() {
+		if cerr := it.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	key, val, valid := it.Current()
+	for valid && bytes.HasPrefix(key, keyPrefix) {
+		rv[string(key[1:])] = copyBytes(val)
+		it.Next()
+		key, val, valid = it.Current()
+	}
+	return
+}
+
+func (s *Segmented) isReplica() bool {
+	return atomic.LoadInt32(&s.replica) == 1
+}
+
+// becomeReplica must be called with the writeMutex held
+func (s *Segmented) becomeReplica() error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if s.isReplica() {
+		return nil
+	}
+	s.m.RLock()
+	empty := len(s.root.segments) == 0
+	s.m.RUnlock()
+	if !empty {
+		return ErrReplicaNotEmpty
+	}
+	atomic.StoreInt32(&s.replica, 1)
+	return nil
+}
+
+// AddSegment stores a segment of the primary, it is not
+// part of the index until a commit point lists it
+func (s *Segmented) AddSegment(id uint64, data []byte) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	err := s.becomeReplica()
+	if err != nil {
+		return err
+	}
+	if _, exists := s.replicaSegments[id]; exists {
+		return nil
+	}
+	for _, segment := range s.root.segments {
+		if segment.file.id == id {
+			return nil
+		}
+	}
+	if segmentEncrypted(data) {
+		var err error
+		data, err = s.decryptSegment(id, data)
+		if err != nil {
+			return err
+		}
+	}
+	file, err := s.storeSegment(id, data, nil)
+	if err != nil {
+		return err
+	}
+	s.replicaSegments[id] = file
+	return nil
+}
+
+// Commit makes the commit point of the primary the state
+// of the index.  Added segments which the commit point
+// does not list are dropped.
+func (s *Segmented) Commit(point *CommitPoint) (err error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	err = s.becomeReplica()
+	if err != nil {
+		return
+	}
+
+	root := s.root
+	files := make(map[uint64]*segmentFile, len(root.segments))
+	for _, segment := range root.segments {
+		files[segment.file.id] = segment.file
+	}
+	for _, cs := range point.Segments {
+		_, inRoot := files[cs.ID]
+		_, added := s.replicaSegments[cs.ID]
+		if !inRoot && !added {
+			return fmt.Errorf("segment %d of the commit point was not added", cs.ID)
+		}
+	}
+
+	for i, field := range point.Fields {
+		s.fieldCache.AddExisting(field, uint16(i))
+	}
+	s.sortField = point.SortField
+
+	snapshot := &indexSnapshot{
+		segments: make([]*segmentSnapshot, 0, len(point.Segments)),
+		refs:     1,
+	}
+	for _, cs := range point.Segments {
+		file, ok := files[cs.ID]
+		if ok {
+			file.addRef()
+			delete(files, cs.ID)
+		} else {
+			file = s.replicaSegments[cs.ID]
+			delete(s.replicaSegments, cs.ID)
+		}
+		deleted := cs.Deleted
+		if deleted == nil {
+			deleted = bitset.New(uint(file.numDocs))
+		}
+		snapshot.segments = append(snapshot.segments, &segmentSnapshot{
+			file:    file,
+			deleted: deleted,
+		})
+		for {
+			next := atomic.LoadUint64(&s.nextSegmentID)
+			if next >= cs.ID || atomic.CompareAndSwapUint64(&s.nextSegmentID, next, cs.ID) {
+				break
+			}
+		}
+	}
+	removed := make([]*segmentFile, 0, len(files)+len(s.replicaSegments))
+	for _, file := range files {
+		removed = append(removed, file)
+	}
+	unused := make([]*segmentFile, 0, len(s.replicaSegments))
+	for id, file := range s.replicaSegments {
+		unused = append(unused, file)
+		removed = append(removed, file)
+		delete(s.replicaSegments, id)
+	}
+	defer func() {
+		for _, file := range unused {
+			if derr := file.decRef(); err == nil && derr != nil {
+				err = derr
+			}
+		}
+	}()
+
+	internalOps := make(map[string][]byte, len(point.Internal))
+	var existing map[string][]byte
+	existing, err = s.internalValues()
+	if err != nil {
+		_ = snapshot.decRef()
+		return
+	}
+	for key := range existing {
+		internalOps[key] = nil
+	}
+	for key, val := range point.Internal {
+		internalOps[key] = val
+	}
+
+	err = s.persist(snapshot, internalOps, removed)
+	if err != nil {
+		_ = snapshot.decRef()
+		return
+	}
+	atomic.AddUint64(&s.internalGen, 1)
+	return s.swapRoot(snapshot, removed)
+}
+
+// a replication stream is a sequence of records, a record
+// type byte followed by length prefixed fields
+const (
+	streamRecordSegment byte = 's' // id, data
+	streamRecordCommit  byte = 'c' // commit point as JSON
+)
+
+type streamFollower struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+// NewStreamFollower returns a Follower writing what it
+// receives to w, such as a network connection to a
+// follower reading it with ReplayStream
+func NewStreamFollower(w io.Writer) Follower {
+	return &streamFollower{
+		w:   bufio.NewWriter(w),
+		buf: make([]byte, binary.MaxVarintLen64),
+	}
+}
+
+func (f *streamFollower) writeField(field []byte) error {
+	n := binary.PutUvarint(f.buf, uint64(len(field)))
+	_, err := f.w.Write(f.buf[:n])
+	if err != nil {
+		return err
+	}
+	_, err = f.w.Write(field)
+	return err
+}
+
+func (f *streamFollower) AddSegment(id uint64, data []byte) error {
+	err := f.w.WriteByte(streamRecordSegment)
+	if err != nil {
+		return err
+	}
+	n := binary.PutUvarint(f.buf, id)
+	_, err = f.w.Write(f.buf[:n])
+	if err != nil {
+		return err
+	}
+	return f.writeField(data)
+}
+
+func (f *streamFollower) Commit(point *CommitPoint) error {
+	pointBytes, err := json.Marshal(point)
+	if err != nil {
+		return err
+	}
+	err = f.w.WriteByte(streamRecordCommit)
+	if err != nil {
+		return err
+	}
+	err = f.writeField(pointBytes)
+	if err != nil {
+		return err
+	}
+	return f.w.Flush()
+}
+
+func readStreamField(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	rv := make([]byte, length)
+	_, err = io.ReadFull(r, rv)
+	return rv, err
+}
+
+// ReplayStream passes the segments and commit points
+// written by a stream follower on to f, until the end of
+// the stream
+func ReplayStream(r io.Reader, f Follower) error {
+	br := bufio.NewReader(r)
+	for {
+		recordType, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch recordType {
+		case streamRecordSegment:
+			id, err := binary.ReadUvarint(br)
+			if err != nil {
+				return err
+			}
+			data, err := readStreamField(br)
+			if err != nil {
+				return err
+			}
+			err = f.AddSegment(id, data)
+			if err != nil {
+				return err
+			}
+		case streamRecordCommit:
+			pointBytes, err := readStreamField(br)
+			if err != nil {
+				return err
+			}
+			var point CommitPoint
+			err = json.Unmarshal(pointBytes, &point)
+			if err != nil {
+				return err
+			}
+			err = f.Commit(&point)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown replication record type %q", recordType)
+		}
+	}
+}
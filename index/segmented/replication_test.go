@@ -0,0 +1,190 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/store/boltdb"
+	"github.com/blevesearch/bleve/index/store/gtreap"
+)
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func docCountIs(idx *Segmented, count uint64) func() bool {
+	return func() bool {
+		n, _ := idx.DocCount()
+		return n == count
+	}
+}
+
+func TestReplication(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err := os.MkdirAll("test", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analysisQueue := index.NewAnalysisQueue(1)
+	primary := openTestIndex(t, analysisQueue, filepath.Join("test", "index"))
+	for _, id := range []string{"a", "b"} {
+		doc := document.NewDocument(id)
+		doc.AddField(document.NewTextField("name", []uint64{}, []byte("test")))
+		err := primary.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = primary.AddSegment(1, nil)
+	if err != ErrReplicaNotEmpty {
+		t.Errorf("expected ErrReplicaNotEmpty, got %v", err)
+	}
+
+	// one replica follows directly, with its segments in a
+	// directory, the other one over a stream with its
+	// segments in the store
+	openReplica := func() *Segmented {
+		replica := NewSegmented(boltdb.New(filepath.Join("test", "replica-store"), "bleve"), analysisQueue)
+		replica.SetDirectory(filepath.Join("test", "replica"))
+		err := replica.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return replica
+	}
+	replica := openReplica()
+	kvstore, err := gtreap.StoreConstructor(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamReplica := NewSegmented(kvstore, analysisQueue)
+	err = streamReplica.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, pw := io.Pipe()
+	replayed := make(chan error, 1)
+	go func() {
+		replayed <- ReplayStream(pr, streamReplica)
+	}()
+
+	primary.AddFollower(replica)
+	primary.AddFollower(NewStreamFollower(pw))
+	waitFor(t, "replicas to get both documents", func() bool {
+		return docCountIs(replica, 2)() && docCountIs(streamReplica, 2)()
+	})
+
+	err = primary.Delete("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = primary.SetInternal([]byte("k"), []byte("v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = primary.ForceMerge(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*Segmented{replica, streamReplica} {
+		waitFor(t, "replica to follow the merge", func() bool {
+			snapshot := r.currentSnapshot()
+			defer func() {
+				_ = snapshot.decRef()
+			}()
+			return len(snapshot.segments) == 1 && snapshot.docCount() == 1
+		})
+		reader, err := r.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := reader.Document("b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc == nil {
+			t.Errorf("expected document b on the replica")
+		}
+		// an open bolt reader would block the commits of the
+		// replica which remap the store
+		err = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, "replica to get the internal value", func() bool {
+			reader, err := r.Reader()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = reader.Close()
+			}()
+			val, err := reader.GetInternal([]byte("k"))
+			return err == nil && string(val) == "v"
+		})
+	}
+
+	err = replica.Delete("b")
+	if err != ErrReplica {
+		t.Errorf("expected ErrReplica, got %v", err)
+	}
+
+	err = primary.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = <-replayed
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = streamReplica.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a replica stays one when it is opened again
+	err = replica.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica = openReplica()
+	defer func() {
+		err := replica.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if !replica.isReplica() || !docCountIs(replica, 1)() {
+		t.Errorf("expected a replica with 1 document after reopening")
+	}
+}
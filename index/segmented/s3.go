@@ -0,0 +1,314 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config locates a bucket of an S3 compatible object
+// storage, buckets are addressed by path so that services
+// other than S3 work as well
+type S3Config struct {
+	// such as "https://s3.amazonaws.com"
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is put in front of the object names, so that
+	// several indexes can share a bucket
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+type s3ObjectStore struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3ObjectStore returns an ObjectStore keeping the
+// objects in a bucket, requests are signed with AWS
+// signature version 4
+func NewS3ObjectStore(config S3Config) (ObjectStore, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %v", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("endpoint must be an absolute URL")
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("must specify bucket")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	client := config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &s3ObjectStore{
+		config:   config,
+		endpoint: endpoint,
+		client:   client,
+		now:      time.Now,
+	}, nil
+}
+
+func (s *s3ObjectStore) objectURL(name string, query url.Values) *url.URL {
+	rv := *s.endpoint
+	rv.Path = strings.TrimSuffix(rv.Path, "/") + "/" + s.config.Bucket
+	if name != "" {
+		rv.Path += "/" + s.config.Prefix + name
+	}
+	// the path is sent as it is signed
+	rv.RawPath = s3Escape(rv.Path, false)
+	rv.RawQuery = query.Encode()
+	return &rv
+}
+
+func (s *s3ObjectStore) do(method string, u *url.URL, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	s.sign(req, body)
+	return s.client.Do(req)
+}
+
+// s3Error is the error document of a failed request
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	var e s3Error
+	if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+		return fmt.Errorf("object storage error %s: %s", e.Code, e.Message)
+	}
+	return fmt.Errorf("object storage error: %s", resp.Status)
+}
+
+func (s *s3ObjectStore) Get(name string) ([]byte, error) {
+	resp, err := s.do("GET", s.objectURL(name, nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (s *s3ObjectStore) Put(name string, data []byte) error {
+	resp, err := s.do("PUT", s.objectURL(name, nil), data)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// Delete does not tell missing objects apart, S3 deletes
+// them successfully
+func (s *s3ObjectStore) Delete(name string) error {
+	resp, err := s.do("DELETE", s.objectURL(name, nil), nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrObjectNotFound
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return responseError(resp)
+	}
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3ObjectStore) List(prefix string) ([]string, error) {
+	rv := make([]string, 0)
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.config.Prefix+prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do("GET", s.objectURL("", query), nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		if resp.StatusCode != http.StatusOK {
+			err = responseError(resp)
+		} else {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		if cerr := resp.Body.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			rv = append(rv, strings.TrimPrefix(object.Key, s.config.Prefix))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Strings(rv)
+	return rv, nil
+}
+
+const s3TimeFormat = "20060102T150405Z"
+
+// sign adds the headers of AWS signature version 4 to the
+// request, signing the host, range and x-amz-* headers
+func (s *s3ObjectStore) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	date := now.Format(s3TimeFormat)
+	payloadHash := sha256Hex(body)
+	req.Header.Set("x-amz-date", date)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for key, values := range req.Header {
+		key = strings.ToLower(key)
+		if key == "range" || strings.HasPrefix(key, "x-amz-") {
+			headers[key] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders bytes.Buffer
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format("20060102"), s.config.Region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		date,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent encodes all but the unreserved
+// characters, and the slashes of paths
+func s3Escape(s string, escapeSlash bool) string {
+	var rv bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !escapeSlash) {
+			rv.WriteByte(c)
+		} else {
+			fmt.Fprintf(&rv, "%%%02X", c)
+		}
+	}
+	return rv.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,974 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/blevesearch/bleve/index"
+	"github.com/willf/bitset"
+)
+
+// A segment is an immutable index of a set of documents,
+// encoded into a single byte slice which is usually a
+// memory mapped file.  The layout is:
+//
+//	magic
+//	fields:    the names of the fields used in the segment
+//	documents: in id order, the id and the (field, term)
+//	           pairs of each document
+//	stored:    the stored fields of blocks of documents,
+//	           each block compressed by the codec
+//	postings:  for each field and term, the documents with
+//	           the term along with frequency, norm and vectors,
+//	           long lists are followed by a skip table and
+//	           terms in many of the documents by a bitmap of
+//	           their documents
+//	columns:   for each field with doc values, fixed size
+//	           offsets of the terms of every document followed
+//	           by the terms, and a directory of the columns
+//	sort:      when the segment is sorted, the sort field and
+//	           the documents in the order of the field
+//	dict:      for each field, the number of its terms, their
+//	           entries in term order with the offset of their
+//	           postings, the document count and the offsets of
+//	           the skip table and of the bitmap (0 without
+//	           one), and an fst of the terms to the offsets of
+//	           their entries
+//	block table: fixed size offsets of the stored blocks
+//	doc table: fixed size offsets of the documents
+//	footer:    fixed size offsets of the fields, dict, doc
+//	           table, column directory, sort table (0 when
+//	           unsorted) and block table, the codec, followed
+//	           by the number of documents
+//
+// Only the fields are decoded when a segment is loaded, the
+// dictionaries, postings and documents are read from the
+// underlying bytes on demand.  Segments of the first
+// version keep the stored fields uncompressed with the
+// documents and have no block table, segments of the
+// second version have no bitmaps and those of the third
+// no skip tables.  Up to the fourth version the terms are
+// listed with their entries, these dictionaries are
+// converted and kept in memory.
+const segmentMagic = "bsg5"
+const segmentMagicV4 = "bsg4"
+const segmentMagicV3 = "bsg3"
+const segmentMagicV2 = "bsg2"
+const segmentMagicV1 = "bsg1"
+
+const footerSize = 8 * 8
+const footerSizeV1 = 6 * 8
+
+// A term gets a bitmap when it is in at least one in
+// bitmapDensity documents of a segment, the bitmap then
+// takes at most bitmapDensity/8 bytes per posting.  Small
+// segments get none, their postings are read in no time.
+const bitmapDensity = 16
+const bitmapMinDocs = 256
+
+// The skip table of a postings list has an entry for every
+// skipInterval postings, with the number of the document
+// before them and their offset, as fixed size values.
+// Lists shorter than two intervals have none.
+const skipInterval = 64
+
+var ErrCorruptSegment = fmt.Errorf("segment is corrupt")
+var ErrEncryptedSegment = fmt.Errorf("segment is encrypted, the index needs a key provider")
+
+type storedField struct {
+	field          string
+	typ            byte
+	arrayPositions []uint64
+	value          []byte
+}
+
+type docTerm struct {
+	field   string
+	term    string
+	freq    uint64
+	norm    float32
+	vectors []*index.TermFieldVector
+}
+
+type docValue struct {
+	field string
+	terms []string
+}
+
+// segmentDoc is an analyzed document, in the form needed
+// to build a segment.  It travels back from the analysis
+// queue as the only row of the analysis result.
+type segmentDoc struct {
+	id        string
+	stored    []*storedField
+	terms     []*docTerm
+	docValues []*docValue
+}
+
+func (d *segmentDoc) Key() []byte {
+	return []byte(d.id)
+}
+
+func (d *segmentDoc) Value() []byte {
+	return nil
+}
+
+// sortKey returns the smallest value of the field in the
+// document, taken from its doc values when it has them
+func (d *segmentDoc) sortKey(field string) (key string, ok bool) {
+	for _, dv := range d.docValues {
+		if dv.field == field {
+			for _, term := range dv.terms {
+				if !ok || term < key {
+					key, ok = term, true
+				}
+			}
+			return
+		}
+	}
+	for _, dt := range d.terms {
+		if dt.field == field && (!ok || dt.term < key) {
+			key, ok = dt.term, true
+		}
+	}
+	return
+}
+
+type docsByID []*segmentDoc
+
+func (d docsByID) Len() int           { return len(d) }
+func (d docsByID) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d docsByID) Less(i, j int) bool { return d[i].id < d[j].id }
+
+// An encoder builds a segment in its buffer, or when w is
+// set streams it to w as the buffer is flushed
+type encoder struct {
+	buf     bytes.Buffer
+	scratch [binary.MaxVarintLen64]byte
+	w       io.Writer
+	flushed uint64
+	err     error
+}
+
+// flush moves the buffer to w once it holds at least min
+// bytes, it returns the first error of w
+func (e *encoder) flush(min int) error {
+	if e.w == nil || e.err != nil || e.buf.Len() < min {
+		return e.err
+	}
+	n, err := e.w.Write(e.buf.Bytes())
+	e.flushed += uint64(n)
+	e.buf.Reset()
+	e.err = err
+	return err
+}
+
+func (e *encoder) uvarint(v uint64) {
+	n := binary.PutUvarint(e.scratch[:], v)
+	e.buf.Write(e.scratch[:n])
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.buf.Write(b)
+}
+
+func (e *encoder) fixed(v uint64) {
+	binary.LittleEndian.PutUint64(e.scratch[:8], v)
+	e.buf.Write(e.scratch[:8])
+}
+
+func (e *encoder) offset() uint64 {
+	return e.flushed + uint64(e.buf.Len())
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.err = ErrCorruptSegment
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	l := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if l > uint64(len(d.data)-d.pos) {
+		d.err = ErrCorruptSegment
+		return nil
+	}
+	rv := d.data[d.pos : d.pos+int(l)]
+	d.pos += int(l)
+	return rv
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.pos >= len(d.data) {
+		d.err = ErrCorruptSegment
+		return 0
+	}
+	rv := d.data[d.pos]
+	d.pos++
+	return rv
+}
+
+func (d *decoder) uint64s() []uint64 {
+	n := d.uvarint()
+	if d.err != nil || n > uint64(len(d.data)-d.pos) {
+		d.err = ErrCorruptSegment
+		return nil
+	}
+	rv := make([]uint64, n)
+	for i := range rv {
+		rv[i] = d.uvarint()
+	}
+	return rv
+}
+
+// copyBytes makes a copy of b, which is safe to use once
+// the segment is released
+func copyBytes(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	rv := make([]byte, len(b))
+	copy(rv, b)
+	return rv
+}
+
+type posting struct {
+	docNum  int
+	freq    uint64
+	norm    float32
+	vectors []*index.TermFieldVector
+}
+
+// sortEntry is the position of a document in the order of
+// the sort field, documents without a value come last
+type sortEntry struct {
+	docNum int
+	id     string
+	key    string
+	hasKey bool
+}
+
+type sortEntries []*sortEntry
+
+func (s sortEntries) Len() int      { return len(s) }
+func (s sortEntries) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s sortEntries) Less(i, j int) bool {
+	return sortEntryLess(s[i], s[j])
+}
+
+func sortEntryLess(a, b *sortEntry) bool {
+	if a.hasKey != b.hasKey {
+		return a.hasKey
+	}
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.id < b.id
+}
+
+// buildSegment encodes the documents into a new segment,
+// the documents are sorted by id in the process.  When
+// sortField is not empty the segment also records the
+// order of the documents by that field.  The stored fields
+// are compressed with codec, nil stores them uncompressed.
+func buildSegment(docs []*segmentDoc, sortField string, codec *storedCodec) []byte {
+	if codec == nil {
+		codec = noneCodec
+	}
+	sort.Sort(docsByID(docs))
+
+	fields := make([]string, 0)
+	fieldIndexes := make(map[string]int)
+	fieldIndex := func(name string) int {
+		i, ok := fieldIndexes[name]
+		if !ok {
+			i = len(fields)
+			fields = append(fields, name)
+			fieldIndexes[name] = i
+		}
+		return i
+	}
+	postings := make([]map[string][]*posting, 0)
+	for docNum, doc := range docs {
+		for _, sf := range doc.stored {
+			fieldIndex(sf.field)
+		}
+		for _, dv := range doc.docValues {
+			fieldIndex(dv.field)
+		}
+		if sortField != "" {
+			fieldIndex(sortField)
+		}
+		for _, dt := range doc.terms {
+			f := fieldIndex(dt.field)
+			for _, v := range dt.vectors {
+				fieldIndex(v.Field)
+			}
+			for len(postings) <= f {
+				postings = append(postings, make(map[string][]*posting))
+			}
+			postings[f][dt.term] = append(postings[f][dt.term], &posting{
+				docNum:  docNum,
+				freq:    dt.freq,
+				norm:    dt.norm,
+				vectors: dt.vectors,
+			})
+		}
+	}
+	for len(postings) < len(fields) {
+		postings = append(postings, make(map[string][]*posting))
+	}
+
+	e := encoder{}
+	e.buf.WriteString(segmentMagic)
+
+	fieldsOffset := e.offset()
+	e.uvarint(uint64(len(fields)))
+	for _, field := range fields {
+		e.bytes([]byte(field))
+	}
+
+	docOffsets := make([]uint64, len(docs))
+	for docNum, doc := range docs {
+		docOffsets[docNum] = e.offset()
+		e.bytes([]byte(doc.id))
+		e.uvarint(uint64(len(doc.terms)))
+		for _, dt := range doc.terms {
+			e.uvarint(uint64(fieldIndexes[dt.field]))
+			e.bytes([]byte(dt.term))
+		}
+	}
+
+	blockOffsets := make([]uint64, 0, (len(docs)+storedBlockSize-1)/storedBlockSize)
+	for start := 0; start < len(docs); start += storedBlockSize {
+		block := encoder{}
+		for docNum := start; docNum < len(docs) && docNum < start+storedBlockSize; docNum++ {
+			stored := encoder{}
+			encodeStoredFields(&stored, fieldIndexes, docs[docNum].stored)
+			block.bytes(stored.buf.Bytes())
+		}
+		blockOffsets = append(blockOffsets, e.offset())
+		e.bytes(codec.encode(block.buf.Bytes()))
+	}
+
+	dicts := make([][]*dictEntry, len(fields))
+	pe := postingsEncoder{fieldIndexes: fieldIndexes}
+	for f := range fields {
+		terms := make([]string, 0, len(postings[f]))
+		for term := range postings[f] {
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+		for _, term := range terms {
+			pe.reset()
+			for _, p := range postings[f][term] {
+				pe.add(p)
+			}
+			dicts[f] = append(dicts[f], pe.write(&e, term, len(docs)))
+		}
+	}
+
+	columns := make(map[int][]*docValue)
+	columnFields := make([]int, 0)
+	for docNum, doc := range docs {
+		for _, dv := range doc.docValues {
+			f := fieldIndexes[dv.field]
+			if columns[f] == nil {
+				columns[f] = make([]*docValue, len(docs))
+				columnFields = append(columnFields, f)
+			}
+			columns[f][docNum] = dv
+		}
+	}
+	sort.Ints(columnFields)
+	columnOffsets := make([]uint64, len(columnFields))
+	for i, f := range columnFields {
+		valueOffsets := make([]uint64, len(docs))
+		valuesStart := e.offset() + uint64(8*len(docs))
+		values := encoder{}
+		for docNum, dv := range columns[f] {
+			if dv == nil {
+				continue
+			}
+			valueOffsets[docNum] = valuesStart + values.offset()
+			encodeDocValues(&values, dv.terms)
+		}
+		columnOffsets[i] = e.offset()
+		for _, offset := range valueOffsets {
+			e.fixed(offset)
+		}
+		e.buf.Write(values.buf.Bytes())
+	}
+	columnsOffset := e.offset()
+	e.uvarint(uint64(len(columnFields)))
+	for i, f := range columnFields {
+		e.uvarint(uint64(f))
+		e.uvarint(columnOffsets[i])
+	}
+
+	sortOffset := uint64(0)
+	if sortField != "" {
+		entries := make(sortEntries, len(docs))
+		for docNum, doc := range docs {
+			entries[docNum] = &sortEntry{
+				docNum: docNum,
+				id:     doc.id,
+			}
+			entries[docNum].key, entries[docNum].hasKey = doc.sortKey(sortField)
+		}
+		sort.Sort(entries)
+		sortOffset = e.offset()
+		e.uvarint(uint64(fieldIndexes[sortField]))
+		for _, entry := range entries {
+			e.uvarint(uint64(entry.docNum))
+			if entry.hasKey {
+				e.buf.WriteByte(1)
+			} else {
+				e.buf.WriteByte(0)
+			}
+			e.bytes([]byte(entry.key))
+		}
+	}
+
+	dictOffset := e.offset()
+	for f := range fields {
+		entryData, fstData := encodeFieldDict(dicts[f])
+		e.uvarint(uint64(len(dicts[f])))
+		e.bytes(entryData)
+		e.bytes(fstData)
+	}
+
+	blockTableOffset := e.offset()
+	for _, offset := range blockOffsets {
+		e.fixed(offset)
+	}
+
+	docTableOffset := e.offset()
+	for _, offset := range docOffsets {
+		e.fixed(offset)
+	}
+
+	e.fixed(fieldsOffset)
+	e.fixed(dictOffset)
+	e.fixed(docTableOffset)
+	e.fixed(columnsOffset)
+	e.fixed(sortOffset)
+	e.fixed(blockTableOffset)
+	e.fixed(uint64(codec.id))
+	e.fixed(uint64(len(docs)))
+	return e.buf.Bytes()
+}
+
+// postingsEncoder encodes the postings of a term, added in
+// document order, so that they can be written after their
+// count
+type postingsEncoder struct {
+	e            encoder
+	fieldIndexes map[string]int
+	docNums      []int
+	// the skip table, with offsets relative to the first
+	// posting
+	skips      []uint64
+	lastDocNum int
+}
+
+func (pe *postingsEncoder) reset() {
+	pe.e.buf.Reset()
+	pe.docNums = pe.docNums[:0]
+	pe.skips = pe.skips[:0]
+	pe.lastDocNum = 0
+}
+
+func (pe *postingsEncoder) add(p *posting) {
+	if n := len(pe.docNums); n > 0 && n%skipInterval == 0 {
+		pe.skips = append(pe.skips, uint64(pe.lastDocNum), pe.e.offset())
+	}
+	e := &pe.e
+	e.uvarint(uint64(p.docNum - pe.lastDocNum))
+	pe.lastDocNum = p.docNum
+	pe.docNums = append(pe.docNums, p.docNum)
+	e.uvarint(p.freq)
+	e.uvarint(uint64(math.Float32bits(p.norm)))
+	e.uvarint(uint64(len(p.vectors)))
+	for _, v := range p.vectors {
+		e.uvarint(uint64(pe.fieldIndexes[v.Field]))
+		e.uvarint(v.Pos)
+		e.uvarint(v.Start)
+		e.uvarint(v.End)
+		e.uvarint(uint64(len(v.ArrayPositions)))
+		for _, ap := range v.ArrayPositions {
+			e.uvarint(ap)
+		}
+		e.bytes(v.Payload)
+	}
+}
+
+// write writes the postings as those of term in a segment
+// of numDocs documents, followed by their skip table and
+// bitmap when they get them
+func (pe *postingsEncoder) write(e *encoder, term string, numDocs int) *dictEntry {
+	rv := &dictEntry{
+		term:   term,
+		offset: e.offset(),
+		count:  uint64(len(pe.docNums)),
+	}
+	e.uvarint(rv.count)
+	start := e.offset()
+	e.buf.Write(pe.e.buf.Bytes())
+	if len(pe.docNums) >= 2*skipInterval {
+		rv.skip = e.offset()
+		for i, v := range pe.skips {
+			if i%2 == 1 {
+				v += start
+			}
+			e.fixed(v)
+		}
+	}
+	if numDocs >= bitmapMinDocs && len(pe.docNums)*bitmapDensity >= numDocs {
+		bitmap := bitset.New(uint(numDocs))
+		for _, docNum := range pe.docNums {
+			bitmap.Set(uint(docNum))
+		}
+		rv.bitmap = e.offset()
+		for _, word := range bitmap.Bytes() {
+			e.fixed(word)
+		}
+	}
+	return rv
+}
+
+func encodeDocValues(e *encoder, terms []string) {
+	e.uvarint(uint64(len(terms)))
+	for _, term := range terms {
+		e.bytes([]byte(term))
+	}
+}
+
+type dictEntry struct {
+	term   string
+	offset uint64
+	count  uint64
+	// offsets of the skip table and of the bitmap of the
+	// documents, 0 when the term has none
+	skip   uint64
+	bitmap uint64
+}
+
+func (e *dictEntry) numSkips() uint64 {
+	if e.skip == 0 {
+		return 0
+	}
+	return (e.count - 1) / skipInterval
+}
+
+type segment struct {
+	data         []byte
+	fields       []string
+	fieldIndexes map[string]int
+	dicts        []*fieldDict
+	// size of the dictionaries converted from segments of
+	// older versions, which are kept in memory
+	dictMemory uint64
+	docTable   int
+	numDocs    int
+	// offsets of the doc value columns by field
+	columns map[int]int
+	// the sort field and the offset of its documents,
+	// sortField is empty when the segment is unsorted
+	sortField  string
+	sortOffset int
+	// offset of the block table, 0 in segments of the
+	// first version
+	storedTable int
+	codec       *storedCodec
+	storedCache storedBlockCache
+}
+
+func loadSegment(data []byte) (*segment, error) {
+	var version int
+	switch {
+	case hasMagic(data, segmentMagic):
+		version = 5
+	case hasMagic(data, segmentMagicV4):
+		version = 4
+	case hasMagic(data, segmentMagicV3):
+		version = 3
+	case hasMagic(data, segmentMagicV2):
+		version = 2
+	case hasMagic(data, segmentMagicV1):
+		version = 1
+	default:
+		return nil, ErrCorruptSegment
+	}
+	size := footerSize
+	if version == 1 {
+		size = footerSizeV1
+	}
+	if len(data) < len(segmentMagic)+size {
+		return nil, ErrCorruptSegment
+	}
+	footer := data[len(data)-size:]
+	fieldsOffset := binary.LittleEndian.Uint64(footer[0:])
+	dictOffset := binary.LittleEndian.Uint64(footer[8:])
+	docTableOffset := binary.LittleEndian.Uint64(footer[16:])
+	columnsOffset := binary.LittleEndian.Uint64(footer[24:])
+	sortOffset := binary.LittleEndian.Uint64(footer[32:])
+	numDocs := binary.LittleEndian.Uint64(footer[size-8:])
+	end := uint64(len(data) - size)
+	if fieldsOffset > end || dictOffset > end || docTableOffset > end ||
+		columnsOffset > end || sortOffset > end || numDocs > (end-docTableOffset)/8 {
+		return nil, ErrCorruptSegment
+	}
+
+	rv := segment{
+		data:         data,
+		fieldIndexes: make(map[string]int),
+		docTable:     int(docTableOffset),
+		numDocs:      int(numDocs),
+		columns:      make(map[int]int),
+	}
+	if version > 1 {
+		storedTable := binary.LittleEndian.Uint64(footer[40:])
+		numBlocks := (numDocs + storedBlockSize - 1) / storedBlockSize
+		rv.codec = storedCodecByID(byte(binary.LittleEndian.Uint64(footer[48:])))
+		if rv.codec == nil || storedTable > docTableOffset || numBlocks > (docTableOffset-storedTable)/8 {
+			return nil, ErrCorruptSegment
+		}
+		rv.storedTable = int(storedTable)
+	}
+	d := decoder{data: data[:end], pos: int(fieldsOffset)}
+	numFields := d.uvarint()
+	for i := uint64(0); i < numFields && d.err == nil; i++ {
+		field := string(d.bytes())
+		rv.fieldIndexes[field] = len(rv.fields)
+		rv.fields = append(rv.fields, field)
+	}
+	d.pos = int(dictOffset)
+	rv.dicts = make([]*fieldDict, len(rv.fields))
+	for f := range rv.fields {
+		numTerms := d.uvarint()
+		var entryData, fstData []byte
+		if version > 4 {
+			entryData = d.bytes()
+			fstData = d.bytes()
+		} else {
+			entries := make([]*dictEntry, 0)
+			for i := uint64(0); i < numTerms && d.err == nil; i++ {
+				entry := dictEntry{
+					term: string(d.bytes()),
+				}
+				entry.offset = d.uvarint()
+				entry.count = d.uvarint()
+				if version > 3 {
+					entry.skip = d.uvarint()
+				}
+				if version > 2 {
+					entry.bitmap = d.uvarint()
+				}
+				entries = append(entries, &entry)
+			}
+			entryData, fstData = encodeFieldDict(entries)
+			rv.dictMemory += uint64(len(entryData) + len(fstData))
+		}
+		if d.err != nil {
+			break
+		}
+		dict, err := rv.loadFieldDict(numTerms, entryData, fstData)
+		if err != nil {
+			return nil, err
+		}
+		rv.dicts[f] = dict
+	}
+	d.pos = int(columnsOffset)
+	numColumns := d.uvarint()
+	for i := uint64(0); i < numColumns && d.err == nil; i++ {
+		f := d.uvarint()
+		offset := d.uvarint()
+		if f >= uint64(len(rv.fields)) || offset+8*numDocs > columnsOffset {
+			d.err = ErrCorruptSegment
+			break
+		}
+		rv.columns[int(f)] = int(offset)
+	}
+	if sortOffset > 0 && d.err == nil {
+		d.pos = int(sortOffset)
+		rv.sortField = rv.fieldName(d.uvarint())
+		rv.sortOffset = d.pos
+		if rv.sortField == "" {
+			d.err = ErrCorruptSegment
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &rv, nil
+}
+
+func hasMagic(data []byte, magic string) bool {
+	return len(data) >= len(magic) && string(data[:len(magic)]) == magic
+}
+
+func (s *segment) docDecoder(docNum int) *decoder {
+	offset := binary.LittleEndian.Uint64(s.data[s.docTable+8*docNum:])
+	return &decoder{data: s.data[:s.docTable], pos: int(offset)}
+}
+
+func (s *segment) docID(docNum int) string {
+	return string(s.docDecoder(docNum).bytes())
+}
+
+// searchDocNum returns the number of the first document
+// with an id not less than id
+func (s *segment) searchDocNum(id string) int {
+	return sort.Search(s.numDocs, func(i int) bool {
+		return s.docID(i) >= id
+	})
+}
+
+func (s *segment) docNum(id string) (int, bool) {
+	n := s.searchDocNum(id)
+	if n < s.numDocs && s.docID(n) == id {
+		return n, true
+	}
+	return 0, false
+}
+
+// document decodes the stored fields and the (field, term)
+// pairs of a document, freq, norm and vectors of the terms
+// are not filled in
+func (s *segment) document(docNum int) (*segmentDoc, error) {
+	d := s.docDecoder(docNum)
+	rv := segmentDoc{
+		id: string(d.bytes()),
+	}
+	if s.storedTable == 0 {
+		rv.stored = s.decodeStoredFields(d)
+	} else {
+		stored, err := s.storedFields(docNum)
+		if err != nil {
+			return nil, err
+		}
+		rv.stored = stored
+	}
+	numTerms := d.uvarint()
+	for i := uint64(0); i < numTerms && d.err == nil; i++ {
+		dt := docTerm{
+			field: s.fieldName(d.uvarint()),
+			term:  string(d.bytes()),
+		}
+		rv.terms = append(rv.terms, &dt)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &rv, nil
+}
+
+// docValues returns the doc values of a field of the
+// document, ok is false when the document has none
+func (s *segment) docValues(docNum int, field string) (terms []string, ok bool, err error) {
+	f, ok := s.fieldIndexes[field]
+	if !ok {
+		return nil, false, nil
+	}
+	column, ok := s.columns[f]
+	if !ok {
+		return nil, false, nil
+	}
+	offset := binary.LittleEndian.Uint64(s.data[column+8*docNum:])
+	if offset == 0 {
+		return nil, false, nil
+	}
+	d := decoder{data: s.data[:s.docTable], pos: int(offset)}
+	numTerms := d.uvarint()
+	for i := uint64(0); i < numTerms && d.err == nil; i++ {
+		terms = append(terms, string(d.bytes()))
+	}
+	return terms, true, d.err
+}
+
+// sortIterator walks the documents of a sorted segment in
+// the order of the sort field
+type sortIterator struct {
+	d         decoder
+	numDocs   int
+	remaining int
+}
+
+func (s *segment) sorted() *sortIterator {
+	if s.sortField == "" {
+		return &sortIterator{}
+	}
+	return &sortIterator{
+		d:         decoder{data: s.data[:s.docTable], pos: s.sortOffset},
+		numDocs:   s.numDocs,
+		remaining: s.numDocs,
+	}
+}
+
+// next returns the next entry, or nil at the end
+func (i *sortIterator) next() (*sortEntry, error) {
+	if i.remaining <= 0 {
+		return nil, nil
+	}
+	i.remaining--
+	rv := sortEntry{
+		docNum: int(i.d.uvarint()),
+		hasKey: i.d.byte() == 1,
+		key:    string(i.d.bytes()),
+	}
+	if i.d.err == nil && rv.docNum >= i.numDocs {
+		i.d.err = ErrCorruptSegment
+	}
+	if i.d.err != nil {
+		return nil, i.d.err
+	}
+	return &rv, nil
+}
+
+func (s *segment) fieldName(i uint64) string {
+	if i < uint64(len(s.fields)) {
+		return s.fields[i]
+	}
+	return ""
+}
+
+// documents returns the set of documents with the term of
+// the dictionary entry, it is read from the bitmap of the
+// term when there is one
+func (s *segment) documents(entry *dictEntry) (*bitset.BitSet, error) {
+	if entry.bitmap > 0 {
+		words := make([]uint64, (s.numDocs+63)/64)
+		for i := range words {
+			words[i] = binary.LittleEndian.Uint64(s.data[int(entry.bitmap)+8*i:])
+		}
+		return bitset.From(words), nil
+	}
+	rv := bitset.New(uint(s.numDocs))
+	postings := s.postings(entry)
+	p, err := postings.next()
+	for p != nil {
+		rv.Set(uint(p.docNum))
+		p, err = postings.next()
+	}
+	return rv, err
+}
+
+func (s *segment) postings(entry *dictEntry) *postingsIterator {
+	rv := postingsIterator{
+		segment: s,
+		entry:   entry,
+		d:       decoder{data: s.data[:s.docTable], pos: int(entry.offset)},
+	}
+	rv.remaining = rv.d.uvarint()
+	return &rv
+}
+
+type postingsIterator struct {
+	segment   *segment
+	entry     *dictEntry
+	d         decoder
+	remaining uint64
+	docNum    int
+}
+
+// skipTo jumps over the blocks of postings before the
+// first one of a document numbered docNum or more, the
+// postings are never moved back
+func (p *postingsIterator) skipTo(docNum int) {
+	numSkips := int(p.entry.numSkips())
+	if numSkips == 0 || docNum <= p.docNum || p.remaining == 0 {
+		return
+	}
+	skip := func(i int) (lastDocNum int, offset int) {
+		pos := int(p.entry.skip) + 16*i
+		return int(binary.LittleEndian.Uint64(p.segment.data[pos:])),
+			int(binary.LittleEndian.Uint64(p.segment.data[pos+8:]))
+	}
+	// the last block with all of the documents before it
+	// numbered lower than docNum
+	i := sort.Search(numSkips, func(i int) bool {
+		lastDocNum, _ := skip(i)
+		return lastDocNum >= docNum
+	}) - 1
+	if i < 0 {
+		return
+	}
+	read := p.entry.count - p.remaining
+	start := uint64(i+1) * skipInterval
+	if start <= read {
+		return
+	}
+	lastDocNum, offset := skip(i)
+	p.d.pos = offset
+	p.docNum = lastDocNum
+	p.remaining = p.entry.count - start
+}
+
+// next returns the next posting of the term, or nil at
+// the end of the list
+func (p *postingsIterator) next() (*posting, error) {
+	if p.remaining == 0 {
+		return nil, nil
+	}
+	p.remaining--
+	p.docNum += int(p.d.uvarint())
+	rv := posting{
+		docNum: p.docNum,
+		freq:   p.d.uvarint(),
+		norm:   math.Float32frombits(uint32(p.d.uvarint())),
+	}
+	numVectors := p.d.uvarint()
+	if numVectors > 0 && p.d.err == nil {
+		rv.vectors = make([]*index.TermFieldVector, 0, numVectors)
+	}
+	for i := uint64(0); i < numVectors && p.d.err == nil; i++ {
+		v := index.TermFieldVector{
+			Field: p.segment.fieldName(p.d.uvarint()),
+		}
+		v.Pos = p.d.uvarint()
+		v.Start = p.d.uvarint()
+		v.End = p.d.uvarint()
+		v.ArrayPositions = p.d.uint64s()
+		v.Payload = copyBytes(p.d.bytes())
+		rv.vectors = append(rv.vectors, &v)
+	}
+	if p.d.err != nil || rv.docNum >= p.segment.numDocs {
+		p.remaining = 0
+		return nil, ErrCorruptSegment
+	}
+	return &rv, nil
+}
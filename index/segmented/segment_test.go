@@ -0,0 +1,417 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/index"
+)
+
+func testDictEntry(t *testing.T, s *segment, field, term string) *dictEntry {
+	rv, err := s.dictEntry(field, term)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rv
+}
+
+func TestSegmentRoundTrip(t *testing.T) {
+	docs := []*segmentDoc{
+		&segmentDoc{
+			id: "b",
+			stored: []*storedField{
+				&storedField{
+					field:          "name",
+					typ:            't',
+					arrayPositions: []uint64{1},
+					value:          []byte("rice"),
+				},
+			},
+			terms: []*docTerm{
+				&docTerm{
+					field: "name",
+					term:  "rice",
+					freq:  1,
+					norm:  1.0,
+					vectors: []*index.TermFieldVector{
+						&index.TermFieldVector{
+							Field:          "name",
+							ArrayPositions: []uint64{1},
+							Pos:            1,
+							Start:          0,
+							End:            4,
+							Payload:        []byte("p"),
+						},
+					},
+				},
+			},
+			docValues: []*docValue{
+				&docValue{
+					field: "name",
+					terms: []string{"rice"},
+				},
+			},
+		},
+		&segmentDoc{
+			id: "a",
+			terms: []*docTerm{
+				&docTerm{
+					field: "name",
+					term:  "beans",
+					freq:  2,
+					norm:  0.5,
+				},
+				&docTerm{
+					field: "desc",
+					term:  "rice",
+					freq:  1,
+					norm:  1.0,
+				},
+			},
+		},
+	}
+
+	s, err := loadSegment(buildSegment(docs, "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.numDocs != 2 {
+		t.Fatalf("expected 2 docs, got %d", s.numDocs)
+	}
+	if s.docID(0) != "a" || s.docID(1) != "b" {
+		t.Errorf("expected docs in id order, got %s %s", s.docID(0), s.docID(1))
+	}
+	docNum, ok := s.docNum("b")
+	if !ok || docNum != 1 {
+		t.Errorf("expected to find b as doc 1, got %d %t", docNum, ok)
+	}
+	_, ok = s.docNum("c")
+	if ok {
+		t.Errorf("expected not to find c")
+	}
+
+	doc, err := s.document(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc.stored, docs[1].stored) {
+		t.Errorf("expected stored fields %v, got %v", docs[1].stored, doc.stored)
+	}
+
+	dv, ok, err := s.docValues(1, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !reflect.DeepEqual(dv, []string{"rice"}) {
+		t.Errorf("expected name doc values rice, got %v %t", dv, ok)
+	}
+	_, ok, err = s.docValues(0, "name")
+	if ok || err != nil {
+		t.Errorf("expected no name doc values for a, got %t %v", ok, err)
+	}
+	_, ok, err = s.docValues(1, "desc")
+	if ok || err != nil {
+		t.Errorf("expected no desc doc values for b, got %t %v", ok, err)
+	}
+
+	var terms []string
+	iter, err := s.terms("name", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := iter.next()
+	for err == nil && entry != nil {
+		terms = append(terms, entry.term)
+		entry, err = iter.next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(terms, []string{"beans", "rice"}) {
+		t.Errorf("expected name terms beans and rice, got %v", terms)
+	}
+
+	postings := s.postings(testDictEntry(t, s, "name", "rice"))
+	p, err := postings.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &posting{
+		docNum:  1,
+		freq:    1,
+		norm:    1.0,
+		vectors: docs[1].terms[0].vectors,
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("expected posting %v, got %v", expected, p)
+	}
+	p, err = postings.next()
+	if p != nil || err != nil {
+		t.Errorf("expected end of postings, got %v %v", p, err)
+	}
+
+	if testDictEntry(t, s, "desc", "beans") != nil {
+		t.Errorf("expected no entry for beans in desc")
+	}
+}
+
+func TestSegmentSorted(t *testing.T) {
+	docs := []*segmentDoc{
+		&segmentDoc{
+			id: "a",
+			terms: []*docTerm{
+				&docTerm{field: "name", term: "steve"},
+			},
+		},
+		&segmentDoc{
+			id: "b",
+		},
+		&segmentDoc{
+			id: "c",
+			terms: []*docTerm{
+				&docTerm{field: "name", term: "zed"},
+			},
+			docValues: []*docValue{
+				&docValue{field: "name", terms: []string{"alice", "zed"}},
+			},
+		},
+		&segmentDoc{
+			id: "d",
+			terms: []*docTerm{
+				&docTerm{field: "name", term: "steve"},
+			},
+		},
+	}
+
+	s, err := loadSegment(buildSegment(docs, "name", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.sortField != "name" {
+		t.Errorf("expected segment sorted by name, got %q", s.sortField)
+	}
+	var ids []string
+	sorted := s.sorted()
+	entry, err := sorted.next()
+	for err == nil && entry != nil {
+		ids = append(ids, s.docID(entry.docNum))
+		entry, err = sorted.next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"c", "a", "d", "b"}) {
+		t.Errorf("expected documents in name order, got %v", ids)
+	}
+
+	// lookups by id are unaffected
+	docNum, ok := s.docNum("c")
+	if !ok || docNum != 2 {
+		t.Errorf("expected to find c as doc 2, got %d %t", docNum, ok)
+	}
+}
+
+func TestSegmentCorrupt(t *testing.T) {
+	data := buildSegment([]*segmentDoc{
+		&segmentDoc{
+			id: "a",
+		},
+	}, "", nil)
+	_, err := loadSegment(data[:len(data)-1])
+	if err != ErrCorruptSegment {
+		t.Errorf("expected corrupt segment error, got %v", err)
+	}
+	_, err = loadSegment([]byte("nope"))
+	if err != ErrCorruptSegment {
+		t.Errorf("expected corrupt segment error, got %v", err)
+	}
+}
+
+func TestSegmentStoredCodecs(t *testing.T) {
+	docs := make([]*segmentDoc, 0, 40)
+	for i := 0; i < 40; i++ {
+		docs = append(docs, &segmentDoc{
+			id: fmt.Sprintf("%03d", i),
+			stored: []*storedField{
+				&storedField{
+					field:          "body",
+					typ:            't',
+					arrayPositions: []uint64{},
+					value:          bytes.Repeat([]byte(fmt.Sprintf(`{"doc":%d,"text":"rice and beans"}`, i)), 10),
+				},
+			},
+		})
+	}
+
+	sizes := make(map[string]int)
+	for _, codec := range storedCodecs {
+		data := buildSegment(docs, "", codec)
+		sizes[codec.name] = len(data)
+		s, err := loadSegment(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.codec != codec {
+			t.Errorf("expected codec %s, got %s", codec.name, s.codec.name)
+		}
+		// out of order, across blocks
+		for _, docNum := range []int{39, 0, 17, 16, 15} {
+			doc, err := s.document(docNum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(doc.stored, docs[docNum].stored) {
+				t.Errorf("%s: expected stored fields of doc %d, got %v", codec.name, docNum, doc.stored)
+			}
+		}
+	}
+	if sizes["snappy"] >= sizes["none"] || sizes["deflate"] >= sizes["none"] {
+		t.Errorf("expected compressed segments to be smaller, got %v", sizes)
+	}
+	_, err := storedCodecFromConfig(map[string]interface{}{"stored_codec": "lzma"})
+	if err == nil {
+		t.Errorf("expected error for an unknown codec")
+	}
+
+	// segments of the first version keep the stored fields
+	// with the documents
+	e := encoder{}
+	e.buf.WriteString(segmentMagicV1)
+	fieldsOffset := e.offset()
+	e.uvarint(1)
+	e.bytes([]byte("body"))
+	docOffset := e.offset()
+	e.bytes([]byte("a"))
+	encodeStoredFields(&e, map[string]int{"body": 0}, docs[0].stored)
+	e.uvarint(0)
+	columnsOffset := e.offset()
+	e.uvarint(0)
+	dictOffset := e.offset()
+	e.uvarint(0)
+	docTableOffset := e.offset()
+	e.fixed(docOffset)
+	for _, v := range []uint64{fieldsOffset, dictOffset, docTableOffset, columnsOffset, 0, 1} {
+		e.fixed(v)
+	}
+	s, err := loadSegment(e.buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := s.document(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.id != "a" || !reflect.DeepEqual(doc.stored, docs[0].stored) {
+		t.Errorf("expected the stored fields of a, got %v", doc)
+	}
+}
+
+func TestSegmentBitmaps(t *testing.T) {
+	docs := make([]*segmentDoc, 0, 300)
+	for i := 0; i < 300; i++ {
+		doc := segmentDoc{
+			id: fmt.Sprintf("%03d", i),
+			terms: []*docTerm{
+				&docTerm{field: "type", term: "log", freq: 1, norm: 1.0},
+			},
+		}
+		if i%100 == 0 {
+			doc.terms = append(doc.terms, &docTerm{field: "type", term: "rare", freq: 1, norm: 1.0})
+		}
+		docs = append(docs, &doc)
+	}
+	s, err := loadSegment(buildSegment(docs, "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for term, expected := range map[string][]uint{
+		"log":  nil,
+		"rare": []uint{0, 100, 200},
+	} {
+		entry := testDictEntry(t, s, "type", term)
+		if entry == nil {
+			t.Fatalf("expected dictionary entry for %s", term)
+		}
+		if (entry.bitmap > 0) != (expected == nil) {
+			t.Errorf("expected %s to have a bitmap: %t", term, expected == nil)
+		}
+		docNums, err := s.documents(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected == nil {
+			if docNums.Count() != 300 {
+				t.Errorf("expected 300 documents with %s, got %d", term, docNums.Count())
+			}
+			continue
+		}
+		got := make([]uint, 0)
+		for i, ok := docNums.NextSet(0); ok; i, ok = docNums.NextSet(i + 1) {
+			got = append(got, i)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected documents %v with %s, got %v", expected, term, got)
+		}
+	}
+}
+
+func TestSegmentSkipTables(t *testing.T) {
+	docs := make([]*segmentDoc, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		doc := segmentDoc{
+			id: fmt.Sprintf("%04d", i),
+		}
+		if i%3 == 0 {
+			doc.terms = append(doc.terms, &docTerm{field: "body", term: "common", freq: uint64(i), norm: 1.0})
+		}
+		if i%100 == 0 {
+			doc.terms = append(doc.terms, &docTerm{field: "body", term: "rare", freq: uint64(i), norm: 1.0})
+		}
+		docs = append(docs, &doc)
+	}
+	s, err := loadSegment(buildSegment(docs, "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testDictEntry(t, s, "body", "common").skip == 0 {
+		t.Errorf("expected a skip table for common")
+	}
+	if testDictEntry(t, s, "body", "rare").skip > 0 {
+		t.Errorf("expected no skip table for rare")
+	}
+
+	// skipping ahead finds the same postings as reading
+	// through all of them
+	postings := s.postings(testDictEntry(t, s, "body", "common"))
+	for _, target := range []int{0, 100, 202, 500, 999, 1000} {
+		postings.skipTo(target)
+		p, err := postings.next()
+		for p != nil && p.docNum < target {
+			p, err = postings.next()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := (target + 2) / 3 * 3
+		if expected >= 1000 {
+			if p != nil {
+				t.Errorf("expected no posting from %d, got %d", target, p.docNum)
+			}
+			continue
+		}
+		if p == nil || p.docNum != expected || p.freq != uint64(expected) {
+			t.Errorf("expected posting of %d from %d, got %v", expected, target, p)
+		}
+	}
+}
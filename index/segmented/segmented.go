@@ -0,0 +1,1216 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/index/store/encrypted"
+	"github.com/blevesearch/bleve/registry"
+	"github.com/willf/bitset"
+)
+
+// The segmented index turns every batch of mutations into
+// a new immutable segment, and merges small segments into
+// larger ones in the background.  Deleting or replacing a
+// document only marks it deleted in the segment holding
+// it, so unlike upside_down an update does not rewrite any
+// existing rows.  When the index is given a directory the
+// segments are memory mapped files in it, otherwise they
+// are kept in the KVStore.  The KVStore also holds the
+// internal values and the list of segments.
+const Name = "segmented"
+
+const segmentFileSuffix = ".seg"
+
+var manifestKey = []byte{'s'}
+
+const internalPrefix = 'i'
+const segmentPrefix = 'g'
+
+var UnsafeBatchUseDetected = fmt.Errorf("bleve.Batch is NOT thread-safe, modification after execution detected")
+
+type manifestSegment struct {
+	ID       uint64         `json:"id"`
+	Deleted  *bitset.BitSet `json:"deleted"`
+	Checksum uint32         `json:"checksum,omitempty"`
+}
+
+type manifest struct {
+	NextSegmentID uint64             `json:"next_segment_id"`
+	Segments      []*manifestSegment `json:"segments"`
+	Fields        []string           `json:"fields"`
+	SortField     string             `json:"sort_field,omitempty"`
+	Replica       bool               `json:"replica,omitempty"`
+}
+
+type Segmented struct {
+	path          string
+	store         store.KVStore
+	fieldCache    *index.FieldCache
+	analysisQueue *index.AnalysisQueue
+	stats         *indexStat
+	nextSegmentID uint64
+	sortField     string
+	mergePolicy   MergePolicy
+	mergeLimiter  *rateLimiter
+	flushLimiter  *rateLimiter
+	storedCodec   *storedCodec
+	cipher        *encrypted.Cipher
+	readOnly      bool
+
+	// bytes of analyzed documents buffered before they are
+	// flushed, 0 flushes whole batches
+	indexBufferSize uint64
+
+	// the write-ahead log, when one is configured for an
+	// index with a directory
+	walConfig       *walConfig
+	wal             *writeAheadLog
+	checkpointMutex sync.Mutex
+
+	// serializes changes of the root snapshot
+	writeMutex sync.Mutex
+	// serializes merges
+	mergeMutex sync.Mutex
+
+	m sync.RWMutex
+	// fields protected by m
+	root *indexSnapshot
+	// visible is the root as of the last refresh
+	visible *indexSnapshot
+
+	mergeNotify chan struct{}
+	closeCh     chan struct{}
+	mergeDone   sync.WaitGroup
+
+	refreshInterval int64
+	refreshNotify   chan struct{}
+	refreshDone     sync.WaitGroup
+
+	// counts the changes of internal values, so that
+	// readers can tell whether they are current
+	internalGen uint64
+
+	// replica is set once the index follows a primary
+	replica         int32
+	replicaSegments map[uint64]*segmentFile
+
+	followersMutex sync.Mutex
+	followers      map[Follower]*follower
+	followersDone  sync.WaitGroup
+
+	// the object store the index pulls from, and the last
+	// commit point pulled
+	objects   ObjectStore
+	pullMutex sync.Mutex
+	pulled    []byte
+}
+
+func NewSegmented(s store.KVStore, analysisQueue *index.AnalysisQueue) *Segmented {
+	root := &indexSnapshot{refs: 2}
+	return &Segmented{
+		store:           s,
+		fieldCache:      index.NewFieldCache(),
+		analysisQueue:   analysisQueue,
+		stats:           &indexStat{},
+		mergePolicy:     NewTieredMergePolicy(),
+		root:            root,
+		visible:         root,
+		mergeNotify:     make(chan struct{}, 1),
+		refreshNotify:   make(chan struct{}, 1),
+		closeCh:         make(chan struct{}),
+		replicaSegments: make(map[uint64]*segmentFile),
+		followers:       make(map[Follower]*follower),
+	}
+}
+
+// SetDirectory makes the index keep its segments as
+// files in path, it must be called before Open
+func (s *Segmented) SetDirectory(path string) {
+	s.path = path
+}
+
+// SetMergePolicy replaces the default TieredMergePolicy,
+// it must be called before Open
+func (s *Segmented) SetMergePolicy(policy MergePolicy) {
+	s.mergePolicy = policy
+}
+
+// SetConfig applies the "merge_policy" section of the
+// index config, which configures a TieredMergePolicy, the
+// "merge_rate_limit" and "flush_rate_limit" in bytes per
+// second of segment writes, the "stored_codec", the
+// "refresh_interval", the "index_buffer_size", the
+// "key_provider" encrypting the segment files and the
+// write-ahead log, see walFromConfig
+func (s *Segmented) SetConfig(config map[string]interface{}) (err error) {
+	s.mergeLimiter, err = rateLimitFromConfig(config, "merge_rate_limit")
+	if err != nil {
+		return
+	}
+	s.flushLimiter, err = rateLimitFromConfig(config, "flush_rate_limit")
+	if err != nil {
+		return
+	}
+	codec, err := storedCodecFromConfig(config)
+	if err != nil {
+		return
+	}
+	if codec != nil {
+		s.storedCodec = codec
+	}
+	interval, ok, err := refreshIntervalFromConfig(config)
+	if err != nil {
+		return
+	}
+	if ok {
+		s.SetRefreshInterval(interval)
+	}
+	bufferSize, ok, err := indexBufferSizeFromConfig(config)
+	if err != nil {
+		return
+	}
+	if ok {
+		s.SetIndexBufferSize(bufferSize)
+	}
+	provider, err := encrypted.KeyProviderFromConfig(config)
+	if err != nil {
+		return
+	}
+	if provider != nil {
+		s.SetKeyProvider(provider)
+	}
+	wal, err := walFromConfig(config)
+	if err != nil {
+		return
+	}
+	if wal != nil {
+		s.walConfig = wal
+	}
+	mergeConfig, ok := config["merge_policy"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	policy, err := newTieredMergePolicyFromConfig(mergeConfig)
+	if err != nil {
+		return err
+	}
+	s.mergePolicy = policy
+	return nil
+}
+
+// SetRateLimits limits the bytes per second written by
+// merges and flushes, 0 means unlimited.  Merges waiting
+// on the limit let the disk serve queries, flushes
+// waiting on it hold back the batch which flushes.
+func (s *Segmented) SetRateLimits(mergeBytesPerSec, flushBytesPerSec float64) {
+	s.mergeLimiter = newRateLimiter(mergeBytesPerSec)
+	s.flushLimiter = newRateLimiter(flushBytesPerSec)
+}
+
+// SetStoredCodec picks how the stored fields of new
+// segments are compressed, "none", the default, "snappy"
+// or "deflate".  Existing segments keep their codec until
+// they are merged.
+func (s *Segmented) SetStoredCodec(name string) error {
+	codec, err := storedCodecFromConfig(map[string]interface{}{
+		"stored_codec": name,
+	})
+	if err != nil {
+		return err
+	}
+	s.storedCodec = codec
+	return nil
+}
+
+// SetSortField makes the segments record the order of
+// their documents by field, it must be called before
+// Open.  An existing index keeps the sort field it was
+// created with.
+func (s *Segmented) SetSortField(field string) {
+	s.sortField = field
+}
+
+func (s *Segmented) Open() error {
+	err := s.open()
+	if err != nil {
+		return err
+	}
+	if s.walConfig != nil && s.path != "" {
+		err = s.openWAL()
+		if err != nil {
+			return err
+		}
+	}
+	if s.objects == nil || s.readOnly {
+		return nil
+	}
+	return s.Pull()
+}
+
+func (s *Segmented) open() (err error) {
+	err = s.store.Open()
+	if err != nil {
+		return
+	}
+	if s.path != "" && !s.readOnly {
+		err = os.MkdirAll(s.path, 0700)
+		if err != nil {
+			return
+		}
+	}
+
+	// an index opened read only is loaded with a reader
+	var kvreader store.KVReader
+	var kvwriter store.KVWriter
+	if s.readOnly {
+		kvreader, err = s.store.Reader()
+	} else {
+		kvwriter, err = s.store.Writer()
+		kvreader = kvwriter
+	}
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := kvreader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	var m manifest
+	var value []byte
+	value, err = kvreader.Get(manifestKey)
+	if err != nil {
+		return
+	}
+	if value != nil {
+		err = json.Unmarshal(value, &m)
+		if err != nil {
+			return
+		}
+	}
+	for i, field := range m.Fields {
+		s.fieldCache.AddExisting(field, uint16(i))
+	}
+	s.nextSegmentID = m.NextSegmentID
+	if value != nil {
+		s.sortField = m.SortField
+	}
+	if m.Replica {
+		s.replica = 1
+	}
+
+	root := &indexSnapshot{refs: 1}
+	for _, ms := range m.Segments {
+		var file *segmentFile
+		file, err = s.loadSegmentFile(kvreader, kvwriter, ms.ID)
+		if err != nil {
+			_ = root.decRef()
+			return
+		}
+		if file.checksum == 0 {
+			file.checksum = ms.Checksum
+		}
+		deleted := ms.Deleted
+		if deleted == nil {
+			deleted = bitset.New(uint(file.numDocs))
+		}
+		root.segments = append(root.segments, &segmentSnapshot{
+			file:    file,
+			deleted: deleted,
+		})
+	}
+	if !s.readOnly {
+		err = s.removeUnusedSegments(kvwriter, root)
+		if err != nil {
+			_ = root.decRef()
+			return
+		}
+	}
+
+	root.addRef()
+	s.m.Lock()
+	s.root = root
+	s.visible = root
+	s.m.Unlock()
+
+	if s.readOnly {
+		return
+	}
+
+	s.mergeDone.Add(1)
+	go s.mergeLoop()
+	s.notifyMerger()
+	s.refreshDone.Add(1)
+	go s.refreshLoop()
+	return
+}
+
+func (s *Segmented) Close() (err error) {
+	if s.wal != nil {
+		err = s.checkpoint()
+		if cerr := s.wal.close(); err == nil {
+			err = cerr
+		}
+	}
+	close(s.closeCh)
+	s.mergeDone.Wait()
+	s.followersDone.Wait()
+	s.refreshDone.Wait()
+
+	s.writeMutex.Lock()
+	for id, file := range s.replicaSegments {
+		// segments added but never committed
+		file.markObsolete()
+		_ = file.decRef()
+		delete(s.replicaSegments, id)
+	}
+	s.writeMutex.Unlock()
+
+	s.m.Lock()
+	if rerr := s.root.decRef(); err == nil {
+		err = rerr
+	}
+	if verr := s.visible.decRef(); err == nil {
+		err = verr
+	}
+	s.root = &indexSnapshot{refs: 2}
+	s.visible = s.root
+	s.m.Unlock()
+
+	if cerr := s.store.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	return
+}
+
+func (s *Segmented) DocCount() (uint64, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return s.visible.docCount(), nil
+}
+
+func segmentKey(id uint64) []byte {
+	rv := make([]byte, 9)
+	rv[0] = segmentPrefix
+	binary.BigEndian.PutUint64(rv[1:], id)
+	return rv
+}
+
+func internalKey(key []byte) []byte {
+	rv := make([]byte, len(key)+1)
+	rv[0] = internalPrefix
+	copy(rv[1:], key)
+	return rv
+}
+
+func (s *Segmented) segmentPath(id uint64) string {
+	return filepath.Join(s.path, fmt.Sprintf("%016x%s", id, segmentFileSuffix))
+}
+
+func (s *Segmented) newSegmentID() uint64 {
+	return atomic.AddUint64(&s.nextSegmentID, 1)
+}
+
+// storeSegment persists the data of a new segment and
+// loads it, the segment is not part of the index until
+// it is introduced in a snapshot.  The writes are paced by
+// limiter.  The checksum of the data is kept in the
+// manifest for Check.
+func (s *Segmented) storeSegment(id uint64, data []byte, limiter *rateLimiter) (*segmentFile, error) {
+	file, err := s.writeSegment(id, data, limiter)
+	if err != nil {
+		return nil, err
+	}
+	file.checksum = crc32.ChecksumIEEE(data)
+	return file, nil
+}
+
+func (s *Segmented) writeSegment(id uint64, data []byte, limiter *rateLimiter) (*segmentFile, error) {
+	if s.path == "" {
+		s.throttle(limiter, len(data))
+		kvwriter, err := s.store.Writer()
+		if err != nil {
+			return nil, err
+		}
+		err = kvwriter.Set(segmentKey(id), data)
+		if cerr := kvwriter.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s.newSegmentFile(id, data, "", nil)
+	}
+
+	err := s.writeSegmentData(id, data, limiter, os.O_EXCL)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := s.openSegmentFile(id)
+	if err != nil {
+		_ = os.Remove(s.segmentPath(id))
+	}
+	return rv, err
+}
+
+// writeSegmentData writes the file of a segment and syncs
+// it, flag is added to the flags the file is opened with
+func (s *Segmented) writeSegmentData(id uint64, data []byte, limiter *rateLimiter, flag int) error {
+	data, err := s.encryptSegment(id, data)
+	if err != nil {
+		return err
+	}
+	path := s.segmentPath(id)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|flag, 0600)
+	if err != nil {
+		return err
+	}
+	for written := 0; written < len(data) && err == nil; written += throttleChunkSize {
+		end := written + throttleChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		s.throttle(limiter, end-written)
+		_, err = f.Write(data[written:end])
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+// loadSegmentFile loads a segment listed in the manifest,
+// kvwriter is nil when the index is opened read only
+func (s *Segmented) loadSegmentFile(kvreader store.KVReader, kvwriter store.KVWriter, id uint64) (*segmentFile, error) {
+	data, err := kvreader.Get(segmentKey(id))
+	if err != nil {
+		return nil, err
+	}
+	if s.path != "" && data == nil {
+		return s.openSegmentFile(id)
+	}
+	if s.path != "" && kvwriter != nil {
+		// the segment was kept in the store by an index
+		// without a directory, as when the backup of an in
+		// memory index is restored, it is moved to the
+		// directory
+		file, err := s.storeSegment(id, data, nil)
+		if err != nil {
+			return nil, err
+		}
+		err = kvwriter.Delete(segmentKey(id))
+		if err != nil {
+			file.markObsolete()
+			_ = file.decRef()
+			return nil, err
+		}
+		return file, nil
+	}
+	if data == nil {
+		return nil, fmt.Errorf("segment %d is missing", id)
+	}
+	if !kvreader.BytesSafeAfterClose() {
+		data = copyBytes(data)
+	}
+	return s.newSegmentFile(id, data, "", nil)
+}
+
+func (s *Segmented) openSegmentFile(id uint64) (*segmentFile, error) {
+	path := s.segmentPath(id)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() == 0 || int64(int(fi.Size())) != fi.Size() {
+		return nil, ErrCorruptSegment
+	}
+	data, err := mmap(f, int(fi.Size()))
+	if err != nil {
+		return nil, err
+	}
+	if segmentEncrypted(data) {
+		// encrypted segments are decrypted into memory
+		decrypted, err := s.decryptSegment(id, data)
+		if uerr := munmap(data); err == nil {
+			err = uerr
+		}
+		if err != nil {
+			return nil, err
+		}
+		return s.newSegmentFile(id, decrypted, path, nil)
+	}
+	rv, err := s.newSegmentFile(id, data, path, data)
+	if err != nil {
+		_ = munmap(data)
+	}
+	return rv, err
+}
+
+func (s *Segmented) newSegmentFile(id uint64, data []byte, path string, mapped []byte) (*segmentFile, error) {
+	seg, err := loadSegment(data)
+	if err != nil {
+		return nil, fmt.Errorf("error loading segment %d: %v", id, err)
+	}
+	return &segmentFile{
+		segment: seg,
+		id:      id,
+		path:    path,
+		mapped:  mapped,
+		refs:    1,
+	}, nil
+}
+
+// removeUnusedSegments cleans up segments which were
+// stored but never became part of the index, because the
+// process stopped before they were introduced
+func (s *Segmented) removeUnusedSegments(kvwriter store.KVWriter, root *indexSnapshot) (err error) {
+	used := make(map[uint64]bool, len(root.segments))
+	for _, segment := range root.segments {
+		used[segment.file.id] = true
+	}
+
+	if s.path != "" {
+		var names []string
+		names, err = filepath.Glob(filepath.Join(s.path, "*"+segmentFileSuffix))
+		if err != nil {
+			return
+		}
+		for _, name := range names {
+			var id uint64
+			_, serr := fmt.Sscanf(strings.TrimSuffix(filepath.Base(name), segmentFileSuffix), "%x", &id)
+			if serr == nil && !used[id] {
+				err = os.Remove(name)
+				if err != nil {
+					return
+				}
+			}
+		}
+		return
+	}
+
+	unused := make([][]byte, 0)
+	it := kvwriter.Iterator([]byte{segmentPrefix})
+	key, _, valid := it.Current()
+	for valid && len(key) > 0 && key[0] == segmentPrefix {
+		if len(key) != 9 || !used[binary.BigEndian.Uint64(key[1:])] {
+			unused = append(unused, copyBytes(key))
+		}
+		it.Next()
+		key, _, valid = it.Current()
+	}
+	err = it.Close()
+	if err != nil {
+		return
+	}
+	for _, key := range unused {
+		err = kvwriter.Delete(key)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *Segmented) fieldNames() []string {
+	rv := make([]string, 0)
+	for i := 0; ; i++ {
+		name := s.fieldCache.FieldIndexed(uint16(i))
+		if name == "" {
+			return rv
+		}
+		rv = append(rv, name)
+	}
+}
+
+// persist writes the list of segments of the snapshot to
+// the store, along with the internal ops of a batch.  The
+// data of removed segments kept in the store is deleted in
+// the same write.
+func (s *Segmented) persist(snapshot *indexSnapshot, internalOps map[string][]byte, removed []*segmentFile) (err error) {
+	if s.readOnly {
+		// only the write-ahead log is applied read only
+		return nil
+	}
+	m := manifest{
+		NextSegmentID: atomic.LoadUint64(&s.nextSegmentID),
+		Segments:      make([]*manifestSegment, 0, len(snapshot.segments)),
+		Fields:        s.fieldNames(),
+		SortField:     s.sortField,
+		Replica:       s.isReplica(),
+	}
+	for _, segment := range snapshot.segments {
+		if atomic.LoadInt32(&segment.file.wal) == 1 {
+			// the log has it until the next checkpoint
+			continue
+		}
+		m.Segments = append(m.Segments, &manifestSegment{
+			ID:       segment.file.id,
+			Deleted:  segment.deleted,
+			Checksum: segment.file.checksum,
+		})
+	}
+	var value []byte
+	value, err = json.Marshal(&m)
+	if err != nil {
+		return
+	}
+
+	var kvwriter store.KVWriter
+	kvwriter, err = s.store.Writer()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := kvwriter.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	wb := kvwriter.NewBatch()
+	wb.Set(manifestKey, value)
+	for key, val := range internalOps {
+		if val == nil {
+			wb.Delete(internalKey([]byte(key)))
+		} else {
+			wb.Set(internalKey([]byte(key)), val)
+		}
+	}
+	if s.path == "" {
+		for _, file := range removed {
+			wb.Delete(segmentKey(file.id))
+		}
+	}
+	return wb.Execute()
+}
+
+// swapRoot makes snapshot the current state of the index,
+// segments which are no longer used are released along
+// with the previous root
+func (s *Segmented) swapRoot(snapshot *indexSnapshot, removed []*segmentFile) error {
+	// read only the write-ahead log is applied in memory,
+	// the files stay
+	for _, file := range removed {
+		if !s.readOnly {
+			file.markObsolete()
+		}
+	}
+	s.m.Lock()
+	prev := s.root
+	s.root = snapshot
+	s.m.Unlock()
+	s.notifyFollowers()
+	if s.refreshImmediately() {
+		s.refresh()
+	}
+	return prev.decRef()
+}
+
+func (s *Segmented) currentSnapshot() *indexSnapshot {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	s.root.addRef()
+	return s.root
+}
+
+func (s *Segmented) Update(doc *document.Document) error {
+	batch := index.NewBatch()
+	batch.Update(doc)
+	return s.Batch(batch)
+}
+
+func (s *Segmented) Delete(id string) error {
+	batch := index.NewBatch()
+	batch.Delete(id)
+	return s.Batch(batch)
+}
+
+func (s *Segmented) Batch(batch *index.Batch) (err error) {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if s.isReplica() {
+		return ErrReplica
+	}
+	analysisStart := time.Now()
+	resultChan := make(chan *index.AnalysisResult)
+
+	var numUpdates uint64
+	for _, doc := range batch.IndexOps {
+		if doc != nil {
+			numUpdates++
+		}
+	}
+
+	var detectedUnsafeMutex sync.RWMutex
+	detectedUnsafe := false
+
+	go func() {
+		sofar := uint64(0)
+		for _, doc := range batch.IndexOps {
+			if doc != nil {
+				sofar++
+				if sofar > numUpdates {
+					detectedUnsafeMutex.Lock()
+					detectedUnsafe = true
+					detectedUnsafeMutex.Unlock()
+					return
+				}
+				aw := index.NewAnalysisWork(s, doc, resultChan)
+				// put the work on the queue
+				s.analysisQueue.Queue(aw)
+			}
+		}
+	}()
+
+	// the documents are flushed as the buffer fills up, any
+	// segments of the batch which do not make it into the
+	// index are dropped
+	var files []*segmentFile
+	defer func() {
+		if err != nil {
+			for _, file := range files {
+				file.markObsolete()
+				_ = file.decRef()
+			}
+		}
+	}()
+	var flushTime time.Duration
+	flush := func(docs []*segmentDoc) error {
+		flushStart := time.Now()
+		var file *segmentFile
+		var err error
+		if s.wal != nil {
+			file, err = s.newWALSegment(s.newSegmentID(), buildSegment(docs, s.sortField, s.storedCodec))
+		} else {
+			file, err = s.storeSegment(s.newSegmentID(), buildSegment(docs, s.sortField, s.storedCodec), s.flushLimiter)
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+		atomic.AddUint64(&s.stats.flushes, 1)
+		flushTime += time.Since(flushStart)
+		return nil
+	}
+
+	bufferSize := s.IndexBufferSize()
+	docs := make([]*segmentDoc, 0, numUpdates)
+	var buffered uint64
+	// wait for the result
+	var itemsDeQueued uint64
+	for itemsDeQueued < numUpdates {
+		result := <-resultChan
+		doc := result.Rows[0].(*segmentDoc)
+		docs = append(docs, doc)
+		itemsDeQueued++
+		if bufferSize == 0 || err != nil {
+			continue
+		}
+		buffered += doc.size()
+		if buffered >= bufferSize {
+			err = flush(docs)
+			docs = make([]*segmentDoc, 0, numUpdates-itemsDeQueued)
+			buffered = 0
+		}
+	}
+	close(resultChan)
+
+	detectedUnsafeMutex.RLock()
+	defer detectedUnsafeMutex.RUnlock()
+	if detectedUnsafe {
+		err = UnsafeBatchUseDetected
+		return
+	}
+	if err != nil {
+		atomic.AddUint64(&s.stats.errors, 1)
+		return
+	}
+
+	atomic.AddUint64(&s.stats.analysisTime, uint64(time.Since(analysisStart)-flushTime))
+
+	indexStart := time.Now()
+	if len(docs) > 0 {
+		err = flush(docs)
+		if err != nil {
+			atomic.AddUint64(&s.stats.errors, 1)
+			return
+		}
+	}
+
+	// the batch is pending on the log until it is part of
+	// the index, a checkpoint waits for it
+	done := func() {}
+	if s.wal != nil {
+		var wait func() error
+		wait, done, err = s.appendWAL(batch, files)
+		if err == nil {
+			err = wait()
+			if err != nil {
+				done()
+			}
+		}
+		if err != nil {
+			atomic.AddUint64(&s.stats.errors, 1)
+			return
+		}
+	}
+
+	var docsDeleted uint64
+	docsDeleted, err = s.introduceBatch(batch, files)
+	done()
+	// the snapshot of the batch took the segments over
+	files = nil
+	atomic.AddUint64(&s.stats.indexTime, uint64(time.Since(indexStart)+flushTime))
+	if err == nil {
+		atomic.AddUint64(&s.stats.updates, numUpdates)
+		atomic.AddUint64(&s.stats.deletes, docsDeleted)
+		atomic.AddUint64(&s.stats.batches, 1)
+		s.notifyMerger()
+	} else {
+		atomic.AddUint64(&s.stats.errors, 1)
+	}
+	if err == nil && s.wal != nil && s.wal.needsCheckpoint() {
+		err = s.checkpoint()
+	}
+	return
+}
+
+// introduceBatch makes the segments of a batch part of the
+// index, earlier versions of the documents of the batch
+// are marked deleted in the segments holding them
+func (s *Segmented) introduceBatch(batch *index.Batch, files []*segmentFile) (docsDeleted uint64, err error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	root := s.root
+	snapshot := &indexSnapshot{
+		segments: make([]*segmentSnapshot, 0, len(root.segments)+len(files)),
+		refs:     1,
+	}
+	removed := make([]*segmentFile, 0)
+	for _, segment := range root.segments {
+		deleted := segment.deleted
+		for docID, doc := range batch.IndexOps {
+			docNum, ok := segment.file.docNum(docID)
+			if !ok || !segment.live(docNum) {
+				continue
+			}
+			if deleted == segment.deleted {
+				deleted = deleted.Clone()
+			}
+			deleted.Set(uint(docNum))
+			if doc == nil {
+				docsDeleted++
+			}
+		}
+		if deleted.Count() == uint(segment.file.numDocs) {
+			removed = append(removed, segment.file)
+			continue
+		}
+		segment.file.addRef()
+		if deleted == segment.deleted {
+			// unchanged, the new snapshot shares the counts
+			// of the segment
+			snapshot.segments = append(snapshot.segments, segment)
+			continue
+		}
+		snapshot.segments = append(snapshot.segments, &segmentSnapshot{
+			file:    segment.file,
+			deleted: deleted,
+		})
+	}
+	for _, file := range files {
+		snapshot.segments = append(snapshot.segments, &segmentSnapshot{
+			file:    file,
+			deleted: bitset.New(uint(file.numDocs)),
+		})
+	}
+
+	if s.wal == nil || len(batch.InternalOps) > 0 || !walSegments(removed) {
+		err = s.persist(snapshot, batch.InternalOps, removed)
+		if err != nil {
+			for _, file := range files {
+				file.markObsolete()
+			}
+			_ = snapshot.decRef()
+			return
+		}
+	}
+	if len(batch.InternalOps) > 0 {
+		atomic.AddUint64(&s.internalGen, 1)
+	}
+	err = s.swapRoot(snapshot, removed)
+	return
+}
+
+// walSegments tells whether all of files are only in the
+// write-ahead log, then the list of segments need not be
+// written for a batch, until the next checkpoint the log
+// has the deletions
+func walSegments(files []*segmentFile) bool {
+	for _, file := range files {
+		if atomic.LoadInt32(&file.wal) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Segmented) SetInternal(key, val []byte) (err error) {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if s.isReplica() {
+		return ErrReplica
+	}
+	var writer store.KVWriter
+	writer, err = s.store.Writer()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := writer.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		if err == nil {
+			atomic.AddUint64(&s.internalGen, 1)
+			s.notifyFollowers()
+		}
+	}()
+	return writer.Set(internalKey(key), val)
+}
+
+func (s *Segmented) DeleteInternal(key []byte) (err error) {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if s.isReplica() {
+		return ErrReplica
+	}
+	var writer store.KVWriter
+	writer, err = s.store.Writer()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := writer.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		if err == nil {
+			atomic.AddUint64(&s.internalGen, 1)
+			s.notifyFollowers()
+		}
+	}()
+	return writer.Delete(internalKey(key))
+}
+
+func (s *Segmented) Reader() (index.IndexReader, error) {
+	internalGen := atomic.LoadUint64(&s.internalGen)
+	return s.newReader(s.visibleSnapshot(), internalGen)
+}
+
+// newReader takes over the reference to snapshot, the
+// internal values it reads are at least as recent as
+// internalGen
+func (s *Segmented) newReader(snapshot *indexSnapshot, internalGen uint64) (index.IndexReader, error) {
+	kvr, err := s.store.Reader()
+	if err != nil {
+		_ = snapshot.decRef()
+		return nil, fmt.Errorf("error opening store reader: %v", err)
+	}
+	return &IndexReader{
+		index:       s,
+		snapshot:    snapshot,
+		kvreader:    kvr,
+		internalGen: internalGen,
+	}, nil
+}
+
+func (s *Segmented) Analyze(d *document.Document) *index.AnalysisResult {
+	doc := &segmentDoc{
+		id:        d.ID,
+		stored:    make([]*storedField, 0),
+		terms:     make([]*docTerm, 0),
+		docValues: make([]*docValue, 0),
+	}
+
+	// track the doc values of each field, a field may occur
+	// more than once in the document
+	docValues := make(map[string]map[string]bool)
+
+	for _, field := range d.Fields {
+		s.fieldCache.FieldNamed(field.Name(), true)
+
+		if field.Options().IsIndexed() {
+			fieldLength, tokenFreqs := field.Analyze()
+
+			// see if any of the composite fields need this
+			for _, compositeField := range d.CompositeFields {
+				compositeField.Compose(field.Name(), fieldLength, tokenFreqs)
+			}
+
+			doc.terms = s.indexField(doc.terms, field, fieldLength, tokenFreqs)
+
+			if field.Options().IncludeDocValues() {
+				terms, ok := docValues[field.Name()]
+				if !ok {
+					terms = make(map[string]bool)
+					docValues[field.Name()] = terms
+				}
+				for _, tf := range tokenFreqs {
+					terms[string(tf.Term)] = true
+				}
+			}
+		}
+
+		if field.Options().IsStored() {
+			doc.stored = append(doc.stored, &storedField{
+				field:          field.Name(),
+				typ:            encodeFieldType(field),
+				arrayPositions: field.ArrayPositions(),
+				value:          field.Value(),
+			})
+		}
+	}
+
+	// now index the composite fields
+	for _, compositeField := range d.CompositeFields {
+		s.fieldCache.FieldNamed(compositeField.Name(), true)
+		if compositeField.Options().IsIndexed() {
+			fieldLength, tokenFreqs := compositeField.Analyze()
+			doc.terms = s.indexField(doc.terms, compositeField, fieldLength, tokenFreqs)
+		}
+	}
+
+	for field, termSet := range docValues {
+		dv := docValue{
+			field: field,
+			terms: make([]string, 0, len(termSet)),
+		}
+		for term := range termSet {
+			dv.terms = append(dv.terms, term)
+		}
+		sort.Strings(dv.terms)
+		doc.docValues = append(doc.docValues, &dv)
+	}
+
+	return &index.AnalysisResult{
+		DocID: d.ID,
+		Rows:  []index.IndexRow{doc},
+	}
+}
+
+func (s *Segmented) indexField(terms []*docTerm, field document.Field, fieldLength int, tokenFreqs analysis.TokenFrequencies) []*docTerm {
+	fieldNorm := float32(1.0 / math.Sqrt(float64(fieldLength)))
+
+	options := field.Options()
+	for _, tf := range tokenFreqs {
+		dt := docTerm{
+			field: field.Name(),
+			term:  string(tf.Term),
+			freq:  uint64(len(tf.Locations)),
+			norm:  fieldNorm,
+		}
+		if options.OmitTermFreqs() {
+			dt.freq = 1
+		}
+		if options.IncludeTermVectors() {
+			dt.vectors = make([]*index.TermFieldVector, len(tf.Locations))
+			for i, l := range tf.Locations {
+				fieldName := field.Name()
+				if l.Field != "" {
+					fieldName = l.Field
+					s.fieldCache.FieldNamed(fieldName, true)
+				}
+				dt.vectors[i] = &index.TermFieldVector{
+					Field:          fieldName,
+					ArrayPositions: l.ArrayPositions,
+				}
+				if !options.OmitTermPositions() {
+					dt.vectors[i].Pos = uint64(l.Position)
+				}
+				if !options.OmitTermOffsets() && !options.OmitTermPositions() {
+					dt.vectors[i].Start, dt.vectors[i].End = uint64(l.Start), uint64(l.End)
+				}
+				if options.IncludeTermPayloads() {
+					dt.vectors[i].Payload = l.Payload
+				}
+			}
+		}
+		terms = append(terms, &dt)
+	}
+	return terms
+}
+
+func encodeFieldType(f document.Field) byte {
+	fieldType := byte('x')
+	switch f.(type) {
+	case *document.TextField:
+		fieldType = 't'
+	case *document.NumericField:
+		fieldType = 'n'
+	case *document.DateTimeField:
+		fieldType = 'd'
+	case *document.CompositeField:
+		fieldType = 'c'
+	}
+	return fieldType
+}
+
+func decodeFieldType(typ byte, name string, pos []uint64, value []byte) document.Field {
+	switch typ {
+	case 't':
+		return document.NewTextField(name, pos, value)
+	case 'n':
+		return document.NewNumericFieldFromBytes(name, pos, value)
+	case 'd':
+		return document.NewDateTimeFieldFromBytes(name, pos, value)
+	}
+	return nil
+}
+
+func IndexTypeConstructor(store store.KVStore, analysisQueue *index.AnalysisQueue) (index.Index, error) {
+	return NewSegmented(store, analysisQueue), nil
+}
+
+func init() {
+	registry.RegisterIndexType(Name, IndexTypeConstructor)
+}
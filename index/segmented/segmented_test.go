@@ -0,0 +1,863 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/tokenizers/regexp_tokenizer"
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/store/boltdb"
+	"github.com/blevesearch/bleve/index/store/gtreap"
+)
+
+var testAnalyzer = &analysis.Analyzer{
+	Tokenizer: regexp_tokenizer.NewRegexpTokenizer(regexp.MustCompile(`\w+`)),
+}
+
+func openTestIndex(t *testing.T, analysisQueue *index.AnalysisQueue, dir string) *Segmented {
+	s := boltdb.New(filepath.Join("test", "store"), "bleve")
+	idx := NewSegmented(s, analysisQueue)
+	if dir != "" {
+		idx.SetDirectory(dir)
+	}
+	err := idx.Open()
+	if err != nil {
+		t.Fatalf("error opening index: %v", err)
+	}
+	return idx
+}
+
+func termCount(t *testing.T, r index.IndexReader, term, field string) (uint64, []string) {
+	reader, err := r.TermFieldReader([]byte(term), field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	ids := make([]string, 0)
+	match, err := reader.Next()
+	for err == nil && match != nil {
+		ids = append(ids, match.ID)
+		match, err = reader.Next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader.Count(), ids
+}
+
+func testIndexCRUD(t *testing.T, dir string) {
+	err := os.MkdirAll("test", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := openTestIndex(t, analysisQueue, dir)
+
+	doc := document.NewDocument("1")
+	doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{}, []byte("test"), document.IndexField|document.StoreField))
+	err = idx.Update(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := index.NewBatch()
+	doc = document.NewDocument("3")
+	doc.AddField(document.NewTextField("name", []uint64{}, []byte("test")))
+	batch.Update(doc)
+	doc = document.NewDocument("2")
+	doc.AddField(document.NewTextFieldWithAnalyzer("name", []uint64{}, []byte("test rice"), testAnalyzer))
+	batch.Update(doc)
+	batch.SetInternal([]byte("key"), []byte("value"))
+	err = idx.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replace 1 and delete 3
+	doc = document.NewDocument("1")
+	doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{}, []byte("rice"), document.IndexField|document.StoreField|document.IncludeDocValues))
+	err = idx.Update(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Delete("3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(idx *Segmented) {
+		docCount, err := idx.DocCount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if docCount != 2 {
+			t.Errorf("expected 2 docs, got %d", docCount)
+		}
+
+		r, err := idx.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+
+		count, ids := termCount(t, r, "test", "name")
+		if count != 1 || !reflect.DeepEqual(ids, []string{"2"}) {
+			t.Errorf("expected test in 2, got %d %v", count, ids)
+		}
+		count, ids = termCount(t, r, "rice", "name")
+		if count != 2 || !reflect.DeepEqual(ids, []string{"1", "2"}) {
+			t.Errorf("expected rice in 1 and 2, got %d %v", count, ids)
+		}
+
+		stored, err := r.Document("1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored == nil || len(stored.Fields) != 1 || string(stored.Fields[0].Value()) != "rice" {
+			t.Errorf("expected stored field rice, got %v", stored)
+		}
+		stored, err = r.Document("3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored != nil {
+			t.Errorf("expected deleted document to be gone, got %v", stored)
+		}
+
+		dict, err := r.FieldDict("name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries := make([]index.DictEntry, 0)
+		entry, err := dict.Next()
+		for err == nil && entry != nil {
+			entries = append(entries, *entry)
+			entry, err = dict.Next()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedEntries := []index.DictEntry{
+			index.DictEntry{Term: "rice", Count: 2},
+			index.DictEntry{Term: "test", Count: 1},
+		}
+		if !reflect.DeepEqual(entries, expectedEntries) {
+			t.Errorf("expected dictionary %v, got %v", expectedEntries, entries)
+		}
+
+		docIDReader, err := r.DocIDReader("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = make([]string, 0)
+		id, err := docIDReader.Next()
+		for err == nil && id != "" {
+			ids = append(ids, id)
+			id, err = docIDReader.Next()
+		}
+		if !reflect.DeepEqual(ids, []string{"1", "2"}) {
+			t.Errorf("expected doc ids 1 and 2, got %v", ids)
+		}
+
+		val, err := r.GetInternal([]byte("key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != "value" {
+			t.Errorf("expected internal value, got %s", val)
+		}
+
+		fieldTerms, err := r.DocumentFieldTerms("2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(fieldTerms["name"])
+		expectedFieldTerms := index.FieldTerms{"name": []string{"rice", "test"}}
+		if !reflect.DeepEqual(fieldTerms, expectedFieldTerms) {
+			t.Errorf("expected field terms %v, got %v", expectedFieldTerms, fieldTerms)
+		}
+
+		termVectors, err := r.DocumentTermVectors("2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vectors := termVectors["name"]
+		// 2 was indexed without vectors
+		if len(termVectors) != 1 || len(vectors) != 2 ||
+			vectors[0].Term != "rice" || vectors[0].Freq != 1 || len(vectors[0].Vectors) != 0 ||
+			vectors[1].Term != "test" || vectors[1].Freq != 1 {
+			t.Errorf("expected the terms rice and test, got %v", termVectors)
+		}
+
+		// 1 has doc values, 2 falls back to its terms
+		dvReader, err := r.DocValueReader([]string{"name"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, expected := range map[string][]string{"1": {"rice"}, "2": {"rice", "test"}, "3": nil} {
+			var terms []string
+			err = dvReader.VisitDocValues(id, func(field string, term []byte) {
+				terms = append(terms, string(term))
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(terms)
+			if !reflect.DeepEqual(terms, expected) {
+				t.Errorf("expected doc values %v for %s, got %v", expected, id, terms)
+			}
+		}
+	}
+
+	check(idx)
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx = openTestIndex(t, analysisQueue, dir)
+	check(idx)
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexCRUD(t *testing.T) {
+	testIndexCRUD(t, "")
+}
+
+func TestIndexCRUDDirectory(t *testing.T) {
+	testIndexCRUD(t, filepath.Join("test", "index"))
+}
+
+func TestIndexMerge(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err := os.MkdirAll("test", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// all the segments are below the floor size, so at most
+	// segments_per_tier of them are kept
+	dir := filepath.Join("test", "index")
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := NewSegmented(boltdb.New(filepath.Join("test", "store"), "bleve"), analysisQueue)
+	idx.SetDirectory(dir)
+	err = idx.SetConfig(map[string]interface{}{
+		"merge_policy": map[string]interface{}{
+			"segments_per_tier": 2.0,
+			"max_merge_at_once": 2.0,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	segmentsPerTier := 2
+	for i := 0; i < 20; i++ {
+		doc := document.NewDocument(strconv.Itoa(i))
+		doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{}, []byte("test"), document.IndexField|document.IncludeDocValues))
+		err := idx.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%3 == 0 {
+			err = idx.Delete(strconv.Itoa(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// wait for the merger to catch up
+	segments := 0
+	for i := 0; i < 100; i++ {
+		idx.m.RLock()
+		segments = len(idx.root.segments)
+		idx.m.RUnlock()
+		if segments <= segmentsPerTier {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if segments > segmentsPerTier {
+		t.Errorf("expected at most %d segments, got %d", segmentsPerTier, segments)
+	}
+
+	r, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, ids := termCount(t, r, "test", "name")
+	if count != 13 || len(ids) != 13 {
+		t.Errorf("expected 13 matches, got %d %v", count, ids)
+	}
+
+	// doc values survive the merges
+	dvReader, err := r.DocValueReader([]string{"name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		var terms []string
+		err = dvReader.VisitDocValues(id, func(field string, term []byte) {
+			terms = append(terms, string(term))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(terms, []string{"test"}) {
+			t.Errorf("expected doc values test for %s, got %v", id, terms)
+		}
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// merged segments are removed
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentFileSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != segments {
+		t.Errorf("expected %d segment files, got %d", segments, len(files))
+	}
+}
+
+func TestIndexForceMerge(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err := os.MkdirAll("test", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := openTestIndex(t, analysisQueue, filepath.Join("test", "index"))
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		doc := document.NewDocument(strconv.Itoa(i))
+		doc.AddField(document.NewTextField("name", []uint64{}, []byte("test")))
+		err := idx.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = idx.Delete("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = idx.ForceMerge(0)
+	if err == nil {
+		t.Errorf("expected error merging to 0 segments")
+	}
+	err = idx.ForceMerge(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := idx.currentSnapshot()
+	defer func() {
+		err := snapshot.decRef()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if len(snapshot.segments) != 1 {
+		t.Fatalf("expected 1 segment, got %d", len(snapshot.segments))
+	}
+	if snapshot.segments[0].file.numDocs != 4 || !snapshot.segments[0].deleted.None() {
+		t.Errorf("expected 4 documents without deletions, got %d with %d deleted", snapshot.segments[0].file.numDocs, snapshot.segments[0].deleted.Count())
+	}
+}
+
+func TestIndexStats(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err := os.MkdirAll("test", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := openTestIndex(t, analysisQueue, filepath.Join("test", "index"))
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	batch := index.NewBatch()
+	for i := 0; i < 2; i++ {
+		doc := document.NewDocument(strconv.Itoa(i))
+		doc.AddField(document.NewTextField("name", []uint64{}, []byte("test"+strconv.Itoa(i))))
+		batch.Update(doc)
+	}
+	err = idx.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := document.NewDocument("2")
+	doc.AddField(document.NewTextField("name", []uint64{}, []byte("test2")))
+	err = idx.Update(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Delete("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := idx.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Segments) != 2 {
+		t.Fatalf("expected 2 segments, got %d", len(stats.Segments))
+	}
+	if stats.DocCount != 2 || stats.DeletedDocs != 1 {
+		t.Errorf("expected 2 documents and 1 deleted, got %d and %d", stats.DocCount, stats.DeletedDocs)
+	}
+	if stats.Updates != 3 || stats.Deletes != 1 || stats.Flushes != 2 {
+		t.Errorf("expected 3 updates, 1 delete and 2 flushes, got %d, %d and %d", stats.Updates, stats.Deletes, stats.Flushes)
+	}
+	if stats.Terms < 3 {
+		t.Errorf("expected at least 3 terms, got %d", stats.Terms)
+	}
+	var size uint64
+	for _, segment := range stats.Segments {
+		if !segment.Mapped {
+			t.Errorf("expected segment %d to be mapped", segment.ID)
+		}
+		size += segment.Size
+	}
+	if size != stats.Size || size == 0 {
+		t.Errorf("expected size %d to be the sum of the segment sizes %d", stats.Size, size)
+	}
+
+	err = idx.ForceMerge(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err = idx.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Segments) != 1 || stats.DeletedDocs != 0 {
+		t.Errorf("expected 1 segment without deletions, got %d with %d deleted", len(stats.Segments), stats.DeletedDocs)
+	}
+	if stats.Merges != 1 {
+		t.Errorf("expected 1 merge, got %d", stats.Merges)
+	}
+}
+
+type testBackupWriter struct {
+	kvs       map[string][]byte
+	files     map[string][]byte
+	writeFile func()
+}
+
+func (w *testBackupWriter) WriteKV(key, val []byte) error {
+	w.kvs[string(key)] = copyBytes(val)
+	return nil
+}
+
+func (w *testBackupWriter) WriteFile(name string, data []byte) error {
+	if w.writeFile != nil {
+		w.writeFile()
+		w.writeFile = nil
+	}
+	w.files[name] = copyBytes(data)
+	return nil
+}
+
+func TestIndexBackup(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err := os.MkdirAll("test", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := openTestIndex(t, analysisQueue, filepath.Join("test", "index"))
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		doc := document.NewDocument(strconv.Itoa(i))
+		doc.AddField(document.NewTextField("name", []uint64{}, []byte("test")))
+		err := idx.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// merging while the backup runs removes the segment
+	// files, the backup still gets the segments it started
+	// with
+	w := &testBackupWriter{
+		kvs:   make(map[string][]byte),
+		files: make(map[string][]byte),
+		writeFile: func() {
+			err := idx.ForceMerge(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+	err = idx.Backup(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m manifest
+	err = json.Unmarshal(w.kvs[string(manifestKey)], &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Segments) != 2 || len(w.files) != 2 {
+		t.Fatalf("expected 2 segments and 2 files, got %d and %d", len(m.Segments), len(w.files))
+	}
+	for _, ms := range m.Segments {
+		data, ok := w.files[filepath.Base(idx.segmentPath(ms.ID))]
+		if !ok {
+			t.Fatalf("expected file for segment %d", ms.ID)
+		}
+		seg, err := loadSegment(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seg.numDocs != 1 {
+			t.Errorf("expected 1 document in segment %d, got %d", ms.ID, seg.numDocs)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	var unlimited *rateLimiter
+	if delay := unlimited.wait(1<<30, nil); delay != 0 {
+		t.Errorf("expected no wait without a limit, got %v", delay)
+	}
+
+	limiter := newRateLimiter(1000)
+	if delay := limiter.wait(100, nil); delay != 0 {
+		t.Errorf("expected the first wait not to block, got %v", delay)
+	}
+	start := time.Now()
+	limiter.wait(100, nil)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected to wait about 100ms, waited %v", elapsed)
+	}
+
+	cancel := make(chan struct{})
+	close(cancel)
+	start = time.Now()
+	limiter.wait(1000000, nil)
+	limiter.wait(1, cancel)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected a canceled wait to return, waited %v", elapsed)
+	}
+
+	idx := NewSegmented(nil, nil)
+	err := idx.SetConfig(map[string]interface{}{
+		"merge_rate_limit": "fast",
+	})
+	if err == nil {
+		t.Errorf("expected error for an invalid merge rate limit")
+	}
+	err = idx.SetConfig(map[string]interface{}{
+		"merge_rate_limit": 1000.0,
+	})
+	if err != nil || idx.mergeLimiter == nil || idx.flushLimiter != nil {
+		t.Errorf("expected only a merge limiter, got %v", err)
+	}
+}
+
+func TestIndexReaderReopen(t *testing.T) {
+	// the readers are kept open while the index changes,
+	// which needs a store with isolated readers
+	s, err := gtreap.StoreConstructor(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := NewSegmented(s, analysisQueue)
+	err = idx.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	idx.SetRefreshInterval(-1)
+	for _, id := range []string{"1", "2"} {
+		doc := document.NewDocument(id)
+		doc.AddField(document.NewTextField("name", []uint64{}, []byte("test")))
+		err = idx.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = idx.Delete("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := r.(*IndexReader)
+	defer func() {
+		err := reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	reopened, err := reader.Reopen()
+	if err != nil || reopened != nil {
+		t.Fatalf("expected no new reader without changes, got %v, %v", reopened, err)
+	}
+
+	// changes are not seen before a refresh
+	doc := document.NewDocument("3")
+	doc.AddField(document.NewTextField("name", []uint64{}, []byte("test")))
+	err = idx.Update(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened, err = reader.Reopen()
+	if err != nil || reopened != nil {
+		t.Fatalf("expected no new reader before a refresh, got %v, %v", reopened, err)
+	}
+	err = idx.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = reader.Reopen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil {
+		t.Fatalf("expected a new reader after a refresh")
+	}
+	newReader := r.(*IndexReader)
+	if newReader.DocCount() != 2 {
+		t.Errorf("expected 2 documents, got %d", newReader.DocCount())
+	}
+	shared := 0
+	for _, segment := range newReader.snapshot.segments {
+		for _, old := range reader.snapshot.segments {
+			if segment == old {
+				shared++
+			}
+		}
+	}
+	if shared != len(reader.snapshot.segments) {
+		t.Errorf("expected the %d unchanged segments to be shared, got %d", len(reader.snapshot.segments), shared)
+	}
+	for i := 0; i < 2; i++ {
+		count, _ := termCount(t, newReader, "test", "name")
+		if count != 2 {
+			t.Errorf("expected 2 documents with the term, got %d", count)
+		}
+	}
+	err = newReader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// changes of internal values need a new reader as well
+	err = idx.SetInternal([]byte("k"), []byte("v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = reader.Reopen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil {
+		t.Fatalf("expected a new reader after an internal change")
+	}
+	val, err := r.GetInternal([]byte("k"))
+	if err != nil || string(val) != "v" {
+		t.Errorf("expected internal value v, got %q, %v", val, err)
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexBufferSize(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err := os.MkdirAll("test", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := openTestIndex(t, analysisQueue, filepath.Join("test", "index"))
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = idx.SetConfig(map[string]interface{}{"index_buffer_size": "1mb"})
+	if err == nil {
+		t.Errorf("expected error for index_buffer_size which is not a number")
+	}
+
+	batch := func(prefix string) {
+		b := index.NewBatch()
+		for i := 0; i < 10; i++ {
+			doc := document.NewDocument(strconv.Itoa(i))
+			doc.AddField(document.NewTextField("name", []uint64{}, []byte(prefix+strconv.Itoa(i))))
+			b.Update(doc)
+		}
+		err := idx.Batch(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		bufferSize uint64
+		flushes    uint64
+	}{
+		// unlimited, the batch is one segment
+		{bufferSize: 0, flushes: 1},
+		// every document fills the buffer
+		{bufferSize: 1, flushes: 10},
+		{bufferSize: 1 << 20, flushes: 1},
+	}
+	for i, test := range tests {
+		err = idx.SetConfig(map[string]interface{}{"index_buffer_size": float64(test.bufferSize)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if idx.IndexBufferSize() != test.bufferSize {
+			t.Errorf("%d: expected buffer size %d, got %d", i, test.bufferSize, idx.IndexBufferSize())
+		}
+		before, err := idx.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefix := "test" + strconv.Itoa(i)
+		batch(prefix)
+		after, err := idx.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.Flushes-before.Flushes != test.flushes {
+			t.Errorf("%d: expected %d flushes, got %d", i, test.flushes, after.Flushes-before.Flushes)
+		}
+		if after.Batches-before.Batches != 1 {
+			t.Errorf("%d: expected 1 batch, got %d", i, after.Batches-before.Batches)
+		}
+
+		// the segments of the batch replaced all earlier
+		// versions of the documents together
+		reader, err := idx.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := reader.DocCount()
+		if count != 10 {
+			t.Errorf("%d: expected 10 documents, got %d", i, count)
+		}
+		n, ids := termCount(t, reader, prefix+"3", "name")
+		if n != 1 || len(ids) != 1 || ids[0] != "3" {
+			t.Errorf("%d: expected document 3, got %v", i, ids)
+		}
+		err = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/willf/bitset"
+)
+
+// segmentFile is a loaded segment along with what backs
+// it, it is released once no snapshot refers to it
+type segmentFile struct {
+	*segment
+	id uint64
+	// path of the file, empty for segments kept in the store
+	path     string
+	mapped   []byte
+	refs     int32
+	obsolete int32
+	// crc32 of the data, 0 for segments stored before
+	// checksums were kept
+	checksum uint32
+	// wal is 1 while the segment is only in memory and in
+	// the write-ahead log, it has no file yet
+	wal int32
+}
+
+func (f *segmentFile) addRef() {
+	atomic.AddInt32(&f.refs, 1)
+}
+
+func (f *segmentFile) decRef() (err error) {
+	if atomic.AddInt32(&f.refs, -1) > 0 {
+		return nil
+	}
+	if f.mapped != nil {
+		err = munmap(f.mapped)
+		f.mapped = nil
+	}
+	if f.path != "" && atomic.LoadInt32(&f.obsolete) == 1 && atomic.LoadInt32(&f.wal) == 0 {
+		if rerr := os.Remove(f.path); err == nil {
+			err = rerr
+		}
+	}
+	return
+}
+
+// markObsolete removes the file of the segment once it is
+// released, it is called when the segment is dropped from
+// the index
+func (f *segmentFile) markObsolete() {
+	atomic.StoreInt32(&f.obsolete, 1)
+}
+
+// segmentSnapshot is a segment together with the set of
+// its documents which were deleted or replaced by newer
+// segments.  Snapshots are never modified, a new deletion
+// set is made whenever one changes.
+//
+// The counts of live documents are derived from the
+// deletions, they are kept with the segment snapshot so
+// that all index snapshots and readers sharing it only
+// compute them once.
+type segmentSnapshot struct {
+	file    *segmentFile
+	deleted *bitset.BitSet
+
+	liveOnce sync.Once
+	liveDocs uint64
+
+	termCountsMutex sync.Mutex
+	// by the offset of the postings of the term
+	termCounts map[uint64]uint64
+}
+
+func (s *segmentSnapshot) live(docNum int) bool {
+	return !s.deleted.Test(uint(docNum))
+}
+
+func (s *segmentSnapshot) liveCount() uint64 {
+	s.liveOnce.Do(func() {
+		s.liveDocs = uint64(s.file.numDocs) - uint64(s.deleted.Count())
+	})
+	return s.liveDocs
+}
+
+// termCount returns the number of live documents with the
+// term in the dictionary entry
+func (s *segmentSnapshot) termCount(entry *dictEntry) (uint64, error) {
+	if s.deleted.None() {
+		return entry.count, nil
+	}
+	s.termCountsMutex.Lock()
+	rv, ok := s.termCounts[entry.offset]
+	s.termCountsMutex.Unlock()
+	if ok {
+		return rv, nil
+	}
+
+	postings := s.file.postings(entry)
+	p, err := postings.next()
+	for p != nil {
+		if s.live(p.docNum) {
+			rv++
+		}
+		p, err = postings.next()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	s.termCountsMutex.Lock()
+	if s.termCounts == nil {
+		s.termCounts = make(map[uint64]uint64)
+	}
+	s.termCounts[entry.offset] = rv
+	s.termCountsMutex.Unlock()
+	return rv, nil
+}
+
+// indexSnapshot is the state of the index at one point in
+// time, readers hold on to a snapshot so that they are not
+// affected by later updates and merges
+type indexSnapshot struct {
+	segments []*segmentSnapshot
+	refs     int32
+}
+
+func (s *indexSnapshot) addRef() {
+	atomic.AddInt32(&s.refs, 1)
+}
+
+func (s *indexSnapshot) decRef() (err error) {
+	if atomic.AddInt32(&s.refs, -1) > 0 {
+		return nil
+	}
+	for _, segment := range s.segments {
+		if serr := segment.file.decRef(); err == nil {
+			err = serr
+		}
+	}
+	return
+}
+
+func (s *indexSnapshot) docCount() uint64 {
+	var rv uint64
+	for _, segment := range s.segments {
+		rv += segment.liveCount()
+	}
+	return rv
+}
+
+// lookup finds the segment with the live document of the
+// id, a document is live in at most one segment
+func (s *indexSnapshot) lookup(id string) (*segmentSnapshot, int, bool) {
+	for _, segment := range s.segments {
+		docNum, ok := segment.file.docNum(id)
+		if ok && segment.live(docNum) {
+			return segment, docNum, true
+		}
+	}
+	return nil, 0, false
+}
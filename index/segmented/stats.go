@@ -0,0 +1,83 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/index"
+)
+
+type indexStat struct {
+	updates, deletes, batches, flushes, merges, refreshes, errors uint64
+	analysisTime, indexTime, mergeTime, throttleTime              uint64
+	walSyncs, checkpoints                                         uint64
+}
+
+func (i *indexStat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.counters())
+}
+
+// counters returns the stats counted as the index is used
+func (i *indexStat) counters() *index.Stats {
+	return &index.Stats{
+		Updates:      atomic.LoadUint64(&i.updates),
+		Deletes:      atomic.LoadUint64(&i.deletes),
+		Batches:      atomic.LoadUint64(&i.batches),
+		Flushes:      atomic.LoadUint64(&i.flushes),
+		Merges:       atomic.LoadUint64(&i.merges),
+		Refreshes:    atomic.LoadUint64(&i.refreshes),
+		Errors:       atomic.LoadUint64(&i.errors),
+		AnalysisTime: time.Duration(atomic.LoadUint64(&i.analysisTime)),
+		IndexTime:    time.Duration(atomic.LoadUint64(&i.indexTime)),
+		MergeTime:    time.Duration(atomic.LoadUint64(&i.mergeTime)),
+		ThrottleTime: time.Duration(atomic.LoadUint64(&i.throttleTime)),
+		WALSyncs:     atomic.LoadUint64(&i.walSyncs),
+		Checkpoints:  atomic.LoadUint64(&i.checkpoints),
+	}
+}
+
+// Stats adds the state of the current segments to the
+// counters.  Segments in memory count in full towards the
+// memory in use, memory mapped ones only with the
+// dictionaries converted from older versions.
+func (s *Segmented) Stats() (*index.Stats, error) {
+	snapshot := s.currentSnapshot()
+	defer func() {
+		_ = snapshot.decRef()
+	}()
+
+	rv := s.stats.counters()
+	rv.Segments = make([]*index.SegmentStats, len(snapshot.segments))
+	for i, segment := range snapshot.segments {
+		segmentStats := index.SegmentStats{
+			ID:          segment.file.id,
+			Size:        uint64(len(segment.file.data)),
+			DocCount:    segment.liveCount(),
+			DeletedDocs: uint64(segment.deleted.Count()),
+			Mapped:      segment.file.mapped != nil,
+		}
+		for _, dict := range segment.file.dicts {
+			segmentStats.Terms += dict.numTerms
+		}
+		rv.MemoryInUse += segment.file.dictMemory
+		if !segmentStats.Mapped {
+			rv.MemoryInUse += segmentStats.Size
+		}
+		rv.DocCount += segmentStats.DocCount
+		rv.DeletedDocs += segmentStats.DeletedDocs
+		rv.Terms += segmentStats.Terms
+		rv.Size += segmentStats.Size
+		rv.Segments[i] = &segmentStats
+	}
+	return rv, nil
+}
@@ -0,0 +1,204 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang/snappy"
+)
+
+// storedBlockSize is the number of documents whose stored
+// fields are compressed together
+const storedBlockSize = 16
+
+// A storedCodec compresses the blocks of stored fields of
+// a segment, its id is recorded in the segment so that
+// segments written with different codecs can be read.
+type storedCodec struct {
+	id     byte
+	name   string
+	encode func(src []byte) []byte
+	decode func(src []byte) ([]byte, error)
+}
+
+var noneCodec = &storedCodec{
+	id:   0,
+	name: "none",
+	encode: func(src []byte) []byte {
+		return src
+	},
+	decode: func(src []byte) ([]byte, error) {
+		return src, nil
+	},
+}
+
+var snappyCodec = &storedCodec{
+	id:   1,
+	name: "snappy",
+	encode: func(src []byte) []byte {
+		return snappy.Encode(nil, src)
+	},
+	decode: func(src []byte) ([]byte, error) {
+		return snappy.Decode(nil, src)
+	},
+}
+
+// deflateCodec trades speed for smaller stored fields than
+// snappy
+var deflateCodec = &storedCodec{
+	id:   2,
+	name: "deflate",
+	encode: func(src []byte) []byte {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		_, _ = w.Write(src)
+		_ = w.Close()
+		return buf.Bytes()
+	},
+	decode: func(src []byte) ([]byte, error) {
+		r := flate.NewReader(bytes.NewReader(src))
+		defer func() {
+			_ = r.Close()
+		}()
+		return ioutil.ReadAll(r)
+	},
+}
+
+var storedCodecs = []*storedCodec{noneCodec, snappyCodec, deflateCodec}
+
+func storedCodecByID(id byte) *storedCodec {
+	for _, codec := range storedCodecs {
+		if codec.id == id {
+			return codec
+		}
+	}
+	return nil
+}
+
+// storedCodecFromConfig reads the "stored_codec" of the
+// index config, one of "none", "snappy" or "deflate"
+func storedCodecFromConfig(config map[string]interface{}) (*storedCodec, error) {
+	v, ok := config["stored_codec"]
+	if !ok {
+		return nil, nil
+	}
+	name, _ := v.(string)
+	for _, codec := range storedCodecs {
+		if codec.name == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown stored_codec %v", v)
+}
+
+// storedBlockCache keeps the last decompressed block of a
+// segment, documents are mostly read in order by merges
+// and by the hits of a search
+type storedBlockCache struct {
+	mutex sync.Mutex
+	block int
+	data  []byte
+}
+
+func (c *storedBlockCache) get(block int) []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.data != nil && c.block == block {
+		return c.data
+	}
+	return nil
+}
+
+func (c *storedBlockCache) put(block int, data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.block = block
+	c.data = data
+}
+
+func encodeStoredFields(e *encoder, fieldIndexes map[string]int, stored []*storedField) {
+	e.uvarint(uint64(len(stored)))
+	for _, sf := range stored {
+		e.uvarint(uint64(fieldIndexes[sf.field]))
+		e.buf.WriteByte(sf.typ)
+		e.uvarint(uint64(len(sf.arrayPositions)))
+		for _, ap := range sf.arrayPositions {
+			e.uvarint(ap)
+		}
+		e.bytes(sf.value)
+	}
+}
+
+func (s *segment) decodeStoredFields(d *decoder) []*storedField {
+	var rv []*storedField
+	numStored := d.uvarint()
+	for i := uint64(0); i < numStored && d.err == nil; i++ {
+		sf := storedField{
+			field: s.fieldName(d.uvarint()),
+			typ:   d.byte(),
+		}
+		sf.arrayPositions = d.uint64s()
+		sf.value = copyBytes(d.bytes())
+		rv = append(rv, &sf)
+	}
+	return rv
+}
+
+// storedBlock returns the decompressed stored fields of a
+// block of documents
+func (s *segment) storedBlock(block int) ([]byte, error) {
+	if data := s.storedCache.get(block); data != nil {
+		return data, nil
+	}
+	if block >= (s.numDocs+storedBlockSize-1)/storedBlockSize {
+		return nil, ErrCorruptSegment
+	}
+	offset := binary.LittleEndian.Uint64(s.data[s.storedTable+8*block:])
+	d := decoder{data: s.data[:s.docTable], pos: int(offset)}
+	compressed := d.bytes()
+	if d.err != nil {
+		return nil, d.err
+	}
+	data, err := s.codec.decode(compressed)
+	if err != nil {
+		return nil, ErrCorruptSegment
+	}
+	s.storedCache.put(block, data)
+	return data, nil
+}
+
+// storedFields decodes the stored fields of a document
+// from its block, the stored fields of the documents of a
+// block follow each other
+func (s *segment) storedFields(docNum int) ([]*storedField, error) {
+	data, err := s.storedBlock(docNum / storedBlockSize)
+	if err != nil {
+		return nil, err
+	}
+	d := decoder{data: data}
+	for i := 0; i < docNum%storedBlockSize && d.err == nil; i++ {
+		d.bytes()
+	}
+	docData := decoder{data: d.bytes()}
+	if d.err != nil {
+		return nil, d.err
+	}
+	rv := s.decodeStoredFields(&docData)
+	if docData.err != nil {
+		return nil, docData.err
+	}
+	return rv, nil
+}
@@ -0,0 +1,96 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// throttleChunkSize is how much of a segment is written
+// between waits of a rateLimiter
+const throttleChunkSize = 64 << 10
+
+// A rateLimiter paces I/O to a number of bytes per
+// second.  Each wait reserves its bytes after those of
+// the previous waits, so concurrent merges share the
+// rate.  A nil rateLimiter does not limit.
+type rateLimiter struct {
+	bytesPerSec float64
+
+	mutex sync.Mutex
+	next  time.Time
+}
+
+func newRateLimiter(bytesPerSec float64) *rateLimiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		bytesPerSec: bytesPerSec,
+	}
+}
+
+// wait blocks until n more bytes may be done, or until
+// cancel is closed
+func (r *rateLimiter) wait(n int, cancel <-chan struct{}) time.Duration {
+	if r == nil || n <= 0 {
+		return 0
+	}
+	r.mutex.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	delay := r.next.Sub(now)
+	r.next = r.next.Add(time.Duration(float64(n) / r.bytesPerSec * float64(time.Second)))
+	r.mutex.Unlock()
+
+	if delay <= 0 {
+		return 0
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-cancel:
+	}
+	return delay
+}
+
+// rateLimitFromConfig reads a rate in bytes per second
+// from the index config, 0 means unlimited
+func rateLimitFromConfig(config map[string]interface{}, key string) (*rateLimiter, error) {
+	v, ok := config[key]
+	if !ok {
+		return nil, nil
+	}
+	rate, ok := v.(float64)
+	if !ok {
+		if i, isInt := v.(int); isInt {
+			rate, ok = float64(i), true
+		}
+	}
+	if !ok || rate < 0 {
+		return nil, fmt.Errorf("%s must be a number of bytes per second", key)
+	}
+	return newRateLimiter(rate), nil
+}
+
+// throttle waits on limiter before n bytes are written,
+// a close of the index stops the wait
+func (s *Segmented) throttle(limiter *rateLimiter, n int) {
+	delay := limiter.wait(n, s.closeCh)
+	if delay > 0 {
+		atomic.AddUint64(&s.stats.throttleTime, uint64(delay))
+	}
+}
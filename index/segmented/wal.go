@@ -0,0 +1,556 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+)
+
+// With a write-ahead log the segments of a batch are not
+// written as files, the batch is appended to the log and
+// its segments are kept in memory until a checkpoint
+// writes them out.  Appending to the log is far cheaper
+// than writing a segment and the list of segments for
+// every batch, and concurrent batches share the syncs of
+// the log, a group commit.  When the index is opened the
+// batches in the log are applied again.
+const walFileSuffix = ".wal"
+
+const defaultWALCheckpointSize = 64 << 20
+
+// SetWriteAheadLog makes the index log its batches, it
+// must be called before Open and only applies to an index
+// with a directory.  With a syncInterval of 0 a batch
+// returns once the log is synced to disk, with a positive
+// interval the log is synced once per interval, so that a
+// crash loses at most the batches of the last interval,
+// and with a negative one it is left to the operating
+// system.  Once the segments held in memory reach about
+// checkpointSize bytes they are written out and the log
+// is started over, 0 picks a default.
+func (s *Segmented) SetWriteAheadLog(syncInterval time.Duration, checkpointSize uint64) {
+	if checkpointSize == 0 {
+		checkpointSize = defaultWALCheckpointSize
+	}
+	s.walConfig = &walConfig{
+		syncInterval:   syncInterval,
+		checkpointSize: checkpointSize,
+	}
+}
+
+type walConfig struct {
+	syncInterval   time.Duration
+	checkpointSize uint64
+}
+
+// walFromConfig reads the "wal" of the index config, which
+// enables the log, along with the "wal_sync_interval", a
+// duration or "-1", and the "wal_checkpoint_size" in bytes
+func walFromConfig(config map[string]interface{}) (*walConfig, error) {
+	enabled, ok := config["wal"].(bool)
+	if !ok || !enabled {
+		return nil, nil
+	}
+	rv := &walConfig{
+		checkpointSize: defaultWALCheckpointSize,
+	}
+	if v, ok := config["wal_sync_interval"]; ok {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("wal_sync_interval must be a duration")
+		}
+		if str == "-1" {
+			rv.syncInterval = -1
+		} else {
+			interval, err := time.ParseDuration(str)
+			if err != nil {
+				return nil, fmt.Errorf("invalid wal_sync_interval: %v", err)
+			}
+			rv.syncInterval = interval
+		}
+	}
+	if v, ok := config["wal_checkpoint_size"]; ok {
+		size, ok := v.(float64)
+		if !ok {
+			if i, isInt := v.(int); isInt {
+				size, ok = float64(i), true
+			}
+		}
+		if !ok || size <= 0 {
+			return nil, fmt.Errorf("wal_checkpoint_size must be a number of bytes")
+		}
+		rv.checkpointSize = uint64(size)
+	}
+	return rv, nil
+}
+
+// writeAheadLog appends to one file at a time, each
+// checkpoint starts the next one.  The generation of a
+// file is in its name.
+type writeAheadLog struct {
+	dir    string
+	config *walConfig
+	stats  *indexStat
+
+	m sync.Mutex
+	// fields protected by m
+	gen     uint64
+	f       *os.File
+	written int64
+	// bytes appended since the last checkpoint
+	size uint64
+	// the batches appended to a generation which are not
+	// part of the index yet
+	pending map[uint64]*sync.WaitGroup
+
+	// serializes syncs, a sync covers everything written
+	// before it started
+	syncMutex sync.Mutex
+	syncedGen uint64
+	synced    int64
+
+	closeCh chan struct{}
+	done    sync.WaitGroup
+}
+
+func walPath(dir string, gen uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%016x%s", gen, walFileSuffix))
+}
+
+// walGenerations lists the generations of the log files
+// in dir, oldest first
+func walGenerations(dir string) ([]uint64, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+walFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	rv := make([]uint64, 0, len(names))
+	for _, name := range names {
+		var gen uint64
+		_, err := fmt.Sscanf(strings.TrimSuffix(filepath.Base(name), walFileSuffix), "%x", &gen)
+		if err == nil {
+			rv = append(rv, gen)
+		}
+	}
+	sort.Sort(uint64s(rv))
+	return rv, nil
+}
+
+type uint64s []uint64
+
+func (u uint64s) Len() int           { return len(u) }
+func (u uint64s) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
+func (u uint64s) Less(i, j int) bool { return u[i] < u[j] }
+
+func openWriteAheadLog(dir string, gen uint64, config *walConfig, stats *indexStat) (*writeAheadLog, error) {
+	rv := &writeAheadLog{
+		dir:     dir,
+		config:  config,
+		stats:   stats,
+		pending: make(map[uint64]*sync.WaitGroup),
+		closeCh: make(chan struct{}),
+	}
+	err := rv.start(gen)
+	if err != nil {
+		return nil, err
+	}
+	if config.syncInterval > 0 {
+		rv.done.Add(1)
+		go rv.syncLoop()
+	}
+	return rv, nil
+}
+
+// start begins the file of generation gen, m is held
+func (w *writeAheadLog) start(gen uint64) error {
+	f, err := os.OpenFile(walPath(w.dir, gen), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w.gen = gen
+	w.f = f
+	w.written = 0
+	w.size = 0
+	w.pending[gen] = &sync.WaitGroup{}
+	return nil
+}
+
+// append writes a record to the log, the batch is pending
+// until done is called.  wait returns once the record is
+// as durable as the sync interval asks for.
+func (w *writeAheadLog) append(record []byte) (wait func() error, done func(), err error) {
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header, uint32(len(record)))
+	binary.LittleEndian.PutUint32(header[4:], crc32.ChecksumIEEE(record))
+
+	w.m.Lock()
+	_, err = w.f.Write(header)
+	if err == nil {
+		_, err = w.f.Write(record)
+	}
+	if err != nil {
+		w.m.Unlock()
+		return nil, nil, err
+	}
+	gen := w.gen
+	w.written += int64(len(header) + len(record))
+	end := w.written
+	w.size += uint64(len(record))
+	pending := w.pending[gen]
+	pending.Add(1)
+	w.m.Unlock()
+
+	wait = func() error {
+		if w.config.syncInterval != 0 {
+			return nil
+		}
+		return w.sync(gen, end)
+	}
+	return wait, pending.Done, nil
+}
+
+// sync makes sure the log is on disk up to end of
+// generation gen.  Whoever syncs first syncs the records
+// of all batches waiting behind it.
+func (w *writeAheadLog) sync(gen uint64, end int64) error {
+	w.syncMutex.Lock()
+	defer w.syncMutex.Unlock()
+	if w.syncedGen > gen || (w.syncedGen == gen && w.synced >= end) {
+		return nil
+	}
+	w.m.Lock()
+	f, written := w.f, w.written
+	w.m.Unlock()
+	err := f.Sync()
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&w.stats.walSyncs, 1)
+	w.syncedGen, w.synced = gen, written
+	return nil
+}
+
+func (w *writeAheadLog) syncLoop() {
+	defer w.done.Done()
+	ticker := time.NewTicker(w.config.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.closeCh:
+			return
+		case <-ticker.C:
+			w.m.Lock()
+			gen, written := w.gen, w.written
+			w.m.Unlock()
+			if err := w.sync(gen, written); err != nil {
+				atomic.AddUint64(&w.stats.errors, 1)
+			}
+		}
+	}
+}
+
+// needsCheckpoint tells whether the log grew past the
+// checkpoint size
+func (w *writeAheadLog) needsCheckpoint() bool {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.size >= w.config.checkpointSize
+}
+
+// rotate syncs the current file and begins the next one,
+// it returns the generation of the current file and the
+// batches still pending on it
+func (w *writeAheadLog) rotate() (uint64, *sync.WaitGroup, error) {
+	w.syncMutex.Lock()
+	defer w.syncMutex.Unlock()
+	w.m.Lock()
+	defer w.m.Unlock()
+	err := w.f.Sync()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	gen := w.gen
+	pending := w.pending[gen]
+	delete(w.pending, gen)
+	err = w.start(gen + 1)
+	if err != nil {
+		return 0, nil, err
+	}
+	w.syncedGen, w.synced = gen+1, 0
+	return gen, pending, nil
+}
+
+// remove deletes the files of the generations up to gen
+func (w *writeAheadLog) remove(gen uint64) error {
+	gens, err := walGenerations(w.dir)
+	if err != nil {
+		return err
+	}
+	for _, g := range gens {
+		if g > gen {
+			break
+		}
+		err = os.Remove(walPath(w.dir, g))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *writeAheadLog) close() error {
+	close(w.closeCh)
+	w.done.Wait()
+	w.m.Lock()
+	defer w.m.Unlock()
+	err := w.f.Sync()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// walRecord encodes the segments and the operations of a
+// batch, the segments are encrypted like their files would
+// be
+func (s *Segmented) walRecord(batch *index.Batch, files []*segmentFile) ([]byte, error) {
+	var e encoder
+	e.uvarint(uint64(len(files)))
+	for _, file := range files {
+		data, err := s.encryptSegment(file.id, file.data)
+		if err != nil {
+			return nil, err
+		}
+		e.uvarint(file.id)
+		e.bytes(data)
+	}
+	ids := make([]string, 0, len(batch.IndexOps))
+	for id := range batch.IndexOps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	e.uvarint(uint64(len(ids)))
+	for _, id := range ids {
+		e.bytes([]byte(id))
+		if batch.IndexOps[id] == nil {
+			e.buf.WriteByte(0)
+		} else {
+			e.buf.WriteByte(1)
+		}
+	}
+	return e.buf.Bytes(), nil
+}
+
+// appendWAL appends the record of a batch to the log
+func (s *Segmented) appendWAL(batch *index.Batch, files []*segmentFile) (func() error, func(), error) {
+	record, err := s.walRecord(batch, files)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.wal.append(record)
+}
+
+// newWALSegment keeps the data of a batch in memory, its
+// file is only written by the next checkpoint
+func (s *Segmented) newWALSegment(id uint64, data []byte) (*segmentFile, error) {
+	file, err := s.newSegmentFile(id, data, s.segmentPath(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	file.checksum = crc32.ChecksumIEEE(data)
+	file.wal = 1
+	return file, nil
+}
+
+// openWAL applies the log files left in the directory and
+// starts the next one, the replayed batches are written
+// out by a checkpoint right away.  Read only the batches
+// are only applied in memory.
+func (s *Segmented) openWAL() error {
+	gens, err := walGenerations(s.path)
+	if err != nil {
+		return err
+	}
+	if !s.readOnly {
+		next := uint64(1)
+		if len(gens) > 0 {
+			next = gens[len(gens)-1] + 1
+		}
+		s.wal, err = openWriteAheadLog(s.path, next, s.walConfig, s.stats)
+		if err != nil {
+			return err
+		}
+	}
+	replayed, err := s.replayWAL(gens)
+	if err != nil {
+		return err
+	}
+	if replayed > 0 {
+		return s.checkpoint()
+	}
+	if s.wal != nil && len(gens) > 0 {
+		return s.wal.remove(gens[len(gens)-1])
+	}
+	return nil
+}
+
+// replayWAL applies the batches of the log files again.  A
+// record cut short by a crash ends the replay of its file.
+// The segments get new ids, those in the log may have been
+// used by segments written since.  It returns the number
+// of batches applied.
+func (s *Segmented) replayWAL(gens []uint64) (int, error) {
+	rv := 0
+	for _, gen := range gens {
+		data, err := ioutil.ReadFile(walPath(s.path, gen))
+		if err != nil {
+			return rv, err
+		}
+		for len(data) >= 8 {
+			length := int(binary.LittleEndian.Uint32(data))
+			if length > len(data)-8 || crc32.ChecksumIEEE(data[8:8+length]) != binary.LittleEndian.Uint32(data[4:]) {
+				break
+			}
+			err = s.replayRecord(data[8 : 8+length])
+			if err != nil {
+				return rv, err
+			}
+			rv++
+			data = data[8+length:]
+		}
+	}
+	return rv, nil
+}
+
+func (s *Segmented) replayRecord(record []byte) (err error) {
+	d := decoder{data: record}
+	var files []*segmentFile
+	defer func() {
+		if err != nil {
+			for _, file := range files {
+				_ = file.decRef()
+			}
+		}
+	}()
+	numFiles := d.uvarint()
+	for i := uint64(0); i < numFiles && d.err == nil; i++ {
+		id := d.uvarint()
+		data := d.bytes()
+		if d.err != nil {
+			break
+		}
+		if segmentEncrypted(data) {
+			data, err = s.decryptSegment(id, data)
+		} else {
+			data = copyBytes(data)
+		}
+		if err != nil {
+			return
+		}
+		var file *segmentFile
+		file, err = s.newWALSegment(s.newSegmentID(), data)
+		if err != nil {
+			return
+		}
+		files = append(files, file)
+	}
+	batch := index.NewBatch()
+	numOps := d.uvarint()
+	for i := uint64(0); i < numOps && d.err == nil; i++ {
+		id := string(d.bytes())
+		if d.byte() == 0 {
+			batch.Delete(id)
+		} else {
+			batch.Update(document.NewDocument(id))
+		}
+	}
+	if d.err != nil {
+		return d.err
+	}
+	for _, file := range files {
+		for _, field := range file.fields {
+			s.fieldCache.FieldNamed(field, true)
+		}
+	}
+	_, err = s.introduceBatch(batch, files)
+	files = nil
+	return
+}
+
+// checkpoint writes the files of the segments held in
+// memory, records them in the list of segments and drops
+// the log up to there
+func (s *Segmented) checkpoint() (err error) {
+	if s.wal == nil || s.readOnly {
+		return nil
+	}
+	s.checkpointMutex.Lock()
+	defer s.checkpointMutex.Unlock()
+
+	gen, pending, err := s.wal.rotate()
+	if err != nil {
+		return err
+	}
+	pending.Wait()
+
+	// merges would replace the segments being written
+	s.mergeMutex.Lock()
+	defer s.mergeMutex.Unlock()
+	snapshot := s.currentSnapshot()
+	defer func() {
+		if cerr := snapshot.decRef(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	written := make([]*segmentFile, 0)
+	for _, segment := range snapshot.segments {
+		if atomic.LoadInt32(&segment.file.wal) == 0 {
+			continue
+		}
+		err = s.writeSegmentData(segment.file.id, segment.file.data, s.flushLimiter, os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+		written = append(written, segment.file)
+	}
+
+	s.writeMutex.Lock()
+	for _, file := range written {
+		atomic.StoreInt32(&file.wal, 0)
+	}
+	err = s.persist(s.root, nil, nil)
+	if err != nil {
+		for _, file := range written {
+			atomic.StoreInt32(&file.wal, 1)
+		}
+	}
+	s.writeMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&s.stats.checkpoints, 1)
+	return s.wal.remove(gen)
+}
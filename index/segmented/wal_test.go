@@ -0,0 +1,205 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package segmented
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/store/boltdb"
+)
+
+func TestWriteAheadLog(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err := os.MkdirAll("test", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join("test", "index")
+	analysisQueue := index.NewAnalysisQueue(1)
+	defer analysisQueue.Close()
+	open := func(config map[string]interface{}) *Segmented {
+		idx := NewSegmented(boltdb.New(filepath.Join("test", "store"), "bleve"), analysisQueue)
+		idx.SetDirectory(dir)
+		err := idx.SetConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = idx.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return idx
+	}
+	// crash closes the index without a checkpoint, as if
+	// the process had died
+	crash := func(idx *Segmented) {
+		err := idx.wal.close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx.wal = nil
+		err = idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := func(pattern string) int {
+		names, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(names)
+	}
+	newDoc := func(id string) *document.Document {
+		doc := document.NewDocument(id)
+		doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{}, []byte("marty"), document.IndexField|document.StoreField))
+		return doc
+	}
+	checkDocs := func(idx *Segmented, expected uint64, missing ...string) {
+		count, err := idx.DocCount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != expected {
+			t.Errorf("expected %d documents, got %d", expected, count)
+		}
+		r, err := idx.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := r.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		n, _ := termCount(t, r, "marty", "name")
+		if n != expected {
+			t.Errorf("expected %d documents with marty, got %d", expected, n)
+		}
+		for _, id := range missing {
+			doc, err := r.Document(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc != nil {
+				t.Errorf("expected document %s to be deleted", id)
+			}
+		}
+	}
+
+	config := map[string]interface{}{
+		"wal": true,
+	}
+	idx := open(config)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			err := idx.Update(newDoc(id))
+			if err != nil {
+				t.Error(err)
+			}
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+	err = idx.Delete("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := idx.stats.counters()
+	if stats.WALSyncs == 0 || stats.WALSyncs > stats.Batches {
+		t.Errorf("expected at most one sync per batch, got %d syncs for %d batches", stats.WALSyncs, stats.Batches)
+	}
+	if files("*.seg") != 0 {
+		t.Errorf("expected the segments to be in the log only")
+	}
+	checkDocs(idx, 19)
+	crash(idx)
+
+	// the batches are applied again and checkpointed
+	idx = open(config)
+	checkDocs(idx, 19, "a")
+	if files("*.seg") == 0 {
+		t.Errorf("expected the checkpoint to write segment files")
+	}
+	if n := files("*" + walFileSuffix); n != 1 {
+		t.Errorf("expected the checkpoint to drop the old log, got %d log files", n)
+	}
+	if stats := idx.stats.counters(); stats.Checkpoints != 1 {
+		t.Errorf("expected 1 checkpoint, got %d", stats.Checkpoints)
+	}
+
+	// a segment deleted before the checkpoint never gets a
+	// file
+	err = idx.Update(newDoc("z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Delete("z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the log is checkpointed once it is big enough
+	config["wal_sync_interval"] = "-1"
+	config["wal_checkpoint_size"] = 1.0
+	idx = open(config)
+	err = idx.Update(newDoc("y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := idx.stats.counters(); stats.Checkpoints != 1 || stats.WALSyncs != 0 {
+		t.Errorf("expected 1 checkpoint and no syncs, got %d and %d", stats.Checkpoints, stats.WALSyncs)
+	}
+	crash(idx)
+	idx = open(config)
+	checkDocs(idx, 20, "a", "z")
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteAheadLogConfig(t *testing.T) {
+	tests := []struct {
+		config map[string]interface{}
+		err    bool
+	}{
+		{config: map[string]interface{}{"wal_sync_interval": "1s"}},
+		{config: map[string]interface{}{"wal": true, "wal_sync_interval": "1s", "wal_checkpoint_size": 1024.0}},
+		{config: map[string]interface{}{"wal": true, "wal_sync_interval": "-1"}},
+		{config: map[string]interface{}{"wal": true, "wal_sync_interval": "often"}, err: true},
+		{config: map[string]interface{}{"wal": true, "wal_sync_interval": 1}, err: true},
+		{config: map[string]interface{}{"wal": true, "wal_checkpoint_size": -1.0}, err: true},
+	}
+	for _, test := range tests {
+		_, err := walFromConfig(test.config)
+		if (err != nil) != test.err {
+			t.Errorf("config %v: expected error %t, got %v", test.config, test.err, err)
+		}
+	}
+}
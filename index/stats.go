@@ -0,0 +1,55 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package index
+
+import (
+	"time"
+)
+
+// Stats is a point in time view of the state of an index,
+// anything an index type cannot tell is left zero.  Terms
+// counts the entries of the term dictionaries of all
+// fields, MemoryInUse the bytes the index holds in memory
+// besides what its KVStore holds.  ThrottleTime is the time
+// writes waited on I/O rate limits.
+type Stats struct {
+	DocCount    uint64          `json:"doc_count"`
+	DeletedDocs uint64          `json:"deleted_docs"`
+	Terms       uint64          `json:"terms"`
+	Size        uint64          `json:"size"`
+	MemoryInUse uint64          `json:"memory_in_use"`
+	Segments    []*SegmentStats `json:"segments,omitempty"`
+
+	Updates      uint64        `json:"updates"`
+	Deletes      uint64        `json:"deletes"`
+	Batches      uint64        `json:"batches"`
+	Flushes      uint64        `json:"flushes"`
+	Merges       uint64        `json:"merges"`
+	Refreshes    uint64        `json:"refreshes"`
+	Errors       uint64        `json:"errors"`
+	AnalysisTime time.Duration `json:"analysis_time"`
+	IndexTime    time.Duration `json:"index_time"`
+	MergeTime    time.Duration `json:"merge_time"`
+	ThrottleTime time.Duration `json:"throttle_time"`
+	WALSyncs     uint64        `json:"wal_syncs,omitempty"`
+	Checkpoints  uint64        `json:"checkpoints,omitempty"`
+}
+
+// SegmentStats describes one segment of an index made of
+// segments, Mapped tells whether the segment is a memory
+// mapped file rather than held in memory
+type SegmentStats struct {
+	ID          uint64 `json:"id"`
+	Size        uint64 `json:"size"`
+	DocCount    uint64 `json:"doc_count"`
+	DeletedDocs uint64 `json:"deleted_docs"`
+	Terms       uint64 `json:"terms"`
+	Mapped      bool   `json:"mapped"`
+}
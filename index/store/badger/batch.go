@@ -0,0 +1,75 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build badger full
+
+package badger
+
+import (
+	"github.com/blevesearch/bleve/index/store"
+)
+
+type op struct {
+	k []byte
+	v []byte
+}
+
+// Batch collects the operations and writes them in a
+// single transaction on Execute, a batch too large for one
+// transaction fails with badger.ErrTxnTooBig
+type Batch struct {
+	w     *Writer
+	merge *store.EmulatedMerge
+	ops   []*op
+}
+
+func (b *Batch) Set(key, val []byte) {
+	b.ops = append(b.ops, &op{key, val})
+}
+
+func (b *Batch) Delete(key []byte) {
+	b.ops = append(b.ops, &op{key, nil})
+}
+
+func (b *Batch) Merge(key, val []byte) {
+	b.merge.Merge(key, val)
+}
+
+func (b *Batch) Execute() error {
+
+	// first process merges
+	merges, err := b.merge.ExecuteDeferred(b.w)
+	if err != nil {
+		return err
+	}
+
+	txn := b.w.store.db.NewTransaction(true)
+	defer txn.Discard()
+	for _, op := range b.ops {
+		if op.v != nil {
+			err = txn.Set(op.k, op.v)
+		} else {
+			err = txn.Delete(op.k)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, op := range merges {
+		err = txn.Set(op.K, op.V)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func (b *Batch) Close() error {
+	return nil
+}
@@ -0,0 +1,95 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build badger full
+
+package badger
+
+import (
+	"github.com/dgraph-io/badger"
+)
+
+type Iterator struct {
+	txn      *badger.Txn
+	ownsTxn  bool
+	iterator *badger.Iterator
+	err      error
+	copyk    []byte
+	copyv    []byte
+}
+
+func newIterator(txn *badger.Txn, ownsTxn bool) *Iterator {
+	return &Iterator{
+		txn:      txn,
+		ownsTxn:  ownsTxn,
+		iterator: txn.NewIterator(badger.DefaultIteratorOptions),
+	}
+}
+
+func (bi *Iterator) SeekFirst() {
+	bi.copyk = nil
+	bi.copyv = nil
+	bi.iterator.Rewind()
+}
+
+func (bi *Iterator) Seek(key []byte) {
+	bi.copyk = nil
+	bi.copyv = nil
+	bi.iterator.Seek(key)
+}
+
+func (bi *Iterator) Next() {
+	bi.copyk = nil
+	bi.copyv = nil
+	bi.iterator.Next()
+}
+
+func (bi *Iterator) Current() ([]byte, []byte, bool) {
+	if bi.Valid() {
+		return bi.Key(), bi.Value(), true
+	}
+	return nil, nil, false
+}
+
+func (bi *Iterator) Key() []byte {
+	if bi.copyk == nil {
+		bi.copyk = bi.iterator.Item().KeyCopy(nil)
+	}
+	return bi.copyk
+}
+
+// Value returns nil if the value could not be read from
+// the value log, the error is returned by Close
+func (bi *Iterator) Value() []byte {
+	if bi.copyv == nil {
+		v, err := bi.iterator.Item().ValueCopy(nil)
+		if err != nil {
+			if bi.err == nil {
+				bi.err = err
+			}
+			return nil
+		}
+		bi.copyv = v
+	}
+	return bi.copyv
+}
+
+func (bi *Iterator) Valid() bool {
+	return bi.iterator.Valid()
+}
+
+func (bi *Iterator) Close() error {
+	bi.copyk = nil
+	bi.copyv = nil
+	bi.iterator.Close()
+	if bi.ownsTxn {
+		bi.txn.Discard()
+	}
+	return bi.err
+}
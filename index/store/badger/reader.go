@@ -0,0 +1,50 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build badger full
+
+package badger
+
+import (
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/dgraph-io/badger"
+)
+
+// Reader reads from a read-only transaction, which sees
+// the database as of its start
+type Reader struct {
+	store *Store
+	txn   *badger.Txn
+}
+
+func newReader(store *Store) (*Reader, error) {
+	return &Reader{
+		store: store,
+		txn:   store.db.NewTransaction(false),
+	}, nil
+}
+
+func (r *Reader) BytesSafeAfterClose() bool {
+	return true
+}
+
+func (r *Reader) Get(key []byte) ([]byte, error) {
+	return get(r.txn, key)
+}
+
+func (r *Reader) Iterator(key []byte) store.KVIterator {
+	rv := newIterator(r.txn, false)
+	rv.Seek(key)
+	return rv
+}
+
+func (r *Reader) Close() error {
+	r.txn.Discard()
+	return nil
+}
@@ -0,0 +1,166 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build badger full
+
+package badger
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/registry"
+	"github.com/dgraph-io/badger"
+)
+
+const Name = "badger"
+
+// Store keeps the index in a Badger database.  Badger has
+// no merge operator of its own which works within a
+// transaction, so merges are emulated on Execute.
+type Store struct {
+	path   string
+	opts   badger.Options
+	db     *badger.DB
+	writer sync.Mutex
+	mo     store.MergeOperator
+}
+
+func New(path string, config map[string]interface{}) (*Store, error) {
+	rv := Store{
+		path: path,
+		// request fsync on write for safety
+		opts: badger.DefaultOptions(path).WithSyncWrites(true).WithLogger(nil),
+	}
+
+	opts, err := applyConfig(rv.opts, config)
+	if err != nil {
+		return nil, err
+	}
+	rv.opts = opts
+
+	return &rv, nil
+}
+
+func (bs *Store) Open() error {
+	var err error
+	bs.db, err = badger.Open(bs.opts)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (bs *Store) SetMergeOperator(mo store.MergeOperator) {
+	bs.mo = mo
+}
+
+func (bs *Store) get(key []byte) ([]byte, error) {
+	var rv []byte
+	err := bs.db.View(func(txn *badger.Txn) (err error) {
+		rv, err = get(txn, key)
+		return
+	})
+	return rv, err
+}
+
+// get copies the value out, Badger only lends it for the
+// life of the transaction
+func get(txn *badger.Txn, key []byte) ([]byte, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (bs *Store) setlocked(key, val []byte) error {
+	return bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, val)
+	})
+}
+
+func (bs *Store) deletelocked(key []byte) error {
+	return bs.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+func (bs *Store) Close() error {
+	return bs.db.Close()
+}
+
+// Compact compacts the tree down to one level, and then
+// rewrites the value log files until none of them has
+// enough garbage left to pay off
+func (bs *Store) Compact() error {
+	err := bs.db.Flatten(1)
+	if err != nil {
+		return err
+	}
+	for {
+		err = bs.db.RunValueLogGC(0.5)
+		if err == badger.ErrNoRewrite {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (bs *Store) Reader() (store.KVReader, error) {
+	return newReader(bs)
+}
+
+func (bs *Store) Writer() (store.KVWriter, error) {
+	return newWriter(bs)
+}
+
+func StoreConstructor(config map[string]interface{}) (store.KVStore, error) {
+	path, ok := config["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("must specify path")
+	}
+	return New(path, config)
+}
+
+func init() {
+	registry.RegisterKVStore(Name, StoreConstructor)
+}
+
+func applyConfig(o badger.Options, config map[string]interface{}) (
+	badger.Options, error) {
+
+	ro, ok := config["read_only"].(bool)
+	if ok {
+		o = o.WithReadOnly(ro)
+	}
+
+	sw, ok := config["sync_writes"].(bool)
+	if ok {
+		o = o.WithSyncWrites(sw)
+	}
+
+	vlfs, ok := config["value_log_file_size"].(float64)
+	if ok {
+		o = o.WithValueLogFileSize(int64(vlfs))
+	}
+
+	nvk, ok := config["num_versions_to_keep"].(float64)
+	if ok {
+		o = o.WithNumVersionsToKeep(int(nvk))
+	}
+
+	return o, nil
+}
@@ -21,11 +21,12 @@ import (
 const Name = "boltdb"
 
 type Store struct {
-	path   string
-	bucket string
-	db     *bolt.DB
-	writer sync.Mutex
-	mo     store.MergeOperator
+	path     string
+	bucket   string
+	readOnly bool
+	db       *bolt.DB
+	writer   sync.Mutex
+	mo       store.MergeOperator
 }
 
 func New(path string, bucket string) *Store {
@@ -39,11 +40,28 @@ func New(path string, bucket string) *Store {
 func (bs *Store) Open() error {
 
 	var err error
-	bs.db, err = bolt.Open(bs.path, 0600, nil)
+	bs.db, err = bolt.Open(bs.path, 0600, &bolt.Options{
+		ReadOnly: bs.readOnly,
+	})
 	if err != nil {
 		return err
 	}
 
+	if bs.readOnly {
+		// other processes may read the file as well, it
+		// must have the bucket already
+		err = bs.db.View(func(tx *bolt.Tx) error {
+			if tx.Bucket([]byte(bs.bucket)) == nil {
+				return fmt.Errorf("bucket %s does not exist", bs.bucket)
+			}
+			return nil
+		})
+		if err != nil {
+			_ = bs.db.Close()
+		}
+		return err
+	}
+
 	err = bs.db.Update(func(tx *bolt.Tx) error {
 		_, err := tx.CreateBucketIfNotExists([]byte(bs.bucket))
 
@@ -104,7 +122,12 @@ func StoreConstructor(config map[string]interface{}) (store.KVStore, error) {
 		bucket = "bleve"
 	}
 
-	return New(path, bucket), nil
+	rv := New(path, bucket)
+	ro, ok := config["read_only"].(bool)
+	if ok {
+		rv.readOnly = ro
+	}
+	return rv, nil
 }
 
 func init() {
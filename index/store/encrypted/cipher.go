@@ -0,0 +1,200 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// Package encrypted provides a KVStore which encrypts the
+// values of another KVStore with AES-GCM, and the Cipher
+// doing so for other data of an index, such as the files
+// of the segmented index.
+package encrypted
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var ErrUnknownKey = fmt.Errorf("encryption key not found")
+var ErrCorrupt = fmt.Errorf("encrypted data is corrupt")
+
+// A KeyProvider hands out the AES keys, of 16, 24 or 32
+// bytes.  New data is encrypted with the current key, and
+// the id of the key is kept with the data so that it can
+// be decrypted after the current key changed.
+type KeyProvider interface {
+	CurrentKey() (id string, key []byte, err error)
+	Key(id string) ([]byte, error)
+}
+
+type staticKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider returns a KeyProvider with a fixed
+// set of keys, current is the id of the key to encrypt
+// new data with
+func NewStaticKeyProvider(keys map[string][]byte, current string) (KeyProvider, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, ErrUnknownKey
+	}
+	return &staticKeyProvider{
+		current: current,
+		keys:    keys,
+	}, nil
+}
+
+func (p *staticKeyProvider) CurrentKey() (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *staticKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+var keyProvidersMutex sync.RWMutex
+var keyProviders = make(map[string]KeyProvider)
+
+// RegisterKeyProvider makes a KeyProvider available to
+// the config of indexes by name
+func RegisterKeyProvider(name string, provider KeyProvider) {
+	keyProvidersMutex.Lock()
+	defer keyProvidersMutex.Unlock()
+	keyProviders[name] = provider
+}
+
+func KeyProviderByName(name string) KeyProvider {
+	keyProvidersMutex.RLock()
+	defer keyProvidersMutex.RUnlock()
+	return keyProviders[name]
+}
+
+// KeyProviderFromConfig looks up the KeyProvider named by
+// the "key_provider" of the config, it returns nil if
+// there is none
+func KeyProviderFromConfig(config map[string]interface{}) (KeyProvider, error) {
+	v, ok := config["key_provider"]
+	if !ok {
+		return nil, nil
+	}
+	name, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("key_provider must be the name of a key provider")
+	}
+	provider := KeyProviderByName(name)
+	if provider == nil {
+		return nil, fmt.Errorf("unknown key_provider: %s", name)
+	}
+	return provider, nil
+}
+
+const formatVersion = 1
+
+// Cipher encrypts with AES-GCM.  The encrypted form is a
+// version byte, the length and id of the key, the nonce
+// and the sealed data.  The additional data is
+// authenticated along with the data without being part of
+// the encrypted form, it binds the data to where it is
+// kept, such as its key in a store.
+type Cipher struct {
+	provider KeyProvider
+
+	m     sync.RWMutex
+	aeads map[string]cipher.AEAD
+}
+
+func NewCipher(provider KeyProvider) *Cipher {
+	return &Cipher{
+		provider: provider,
+		aeads:    make(map[string]cipher.AEAD),
+	}
+}
+
+func (c *Cipher) aead(id string, key []byte) (cipher.AEAD, error) {
+	c.m.RLock()
+	rv, ok := c.aeads[id]
+	c.m.RUnlock()
+	if ok {
+		return rv, nil
+	}
+	var err error
+	if key == nil {
+		key, err = c.provider.Key(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	rv, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.m.Lock()
+	c.aeads[id] = rv
+	c.m.Unlock()
+	return rv, nil
+}
+
+func (c *Cipher) Encrypt(data, additionalData []byte) ([]byte, error) {
+	id, key, err := c.provider.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("key id is longer than 255 bytes")
+	}
+	aead, err := c.aead(id, key)
+	if err != nil {
+		return nil, err
+	}
+	header := 2 + len(id)
+	rv := make([]byte, header+aead.NonceSize(), header+aead.NonceSize()+len(data)+aead.Overhead())
+	rv[0] = formatVersion
+	rv[1] = byte(len(id))
+	copy(rv[2:], id)
+	nonce := rv[header:]
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(rv, nonce, data, additionalData), nil
+}
+
+func (c *Cipher) Decrypt(data, additionalData []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != formatVersion || len(data) < 2+int(data[1]) {
+		return nil, ErrCorrupt
+	}
+	header := 2 + int(data[1])
+	aead, err := c.aead(string(data[2:header]), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < header+aead.NonceSize()+aead.Overhead() {
+		return nil, ErrCorrupt
+	}
+	nonce := data[header : header+aead.NonceSize()]
+	rv, err := aead.Open(nil, nonce, data[header+aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	if rv == nil {
+		// an empty value is still there
+		rv = []byte{}
+	}
+	return rv, nil
+}
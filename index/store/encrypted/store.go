@@ -0,0 +1,351 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package encrypted
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/registry"
+)
+
+const Name = "encrypted"
+
+func init() {
+	registry.RegisterKVStore(Name, StoreConstructor)
+}
+
+// StoreConstructor wraps the store named by
+// "kvStoreName_actual", with the KeyProvider registered
+// under the name of "key_provider"
+func StoreConstructor(config map[string]interface{}) (store.KVStore, error) {
+	name, ok := config["kvStoreName_actual"].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("encrypted: missing kvStoreName_actual,"+
+			" config: %#v", config)
+	}
+
+	if name == Name {
+		return nil, fmt.Errorf("encrypted: circular kvStoreName_actual")
+	}
+
+	ctr := registry.KVStoreConstructorByName(name)
+	if ctr == nil {
+		return nil, fmt.Errorf("encrypted: no kv store constructor,"+
+			" kvStoreName_actual: %s", name)
+	}
+
+	provider, err := KeyProviderFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		return nil, fmt.Errorf("encrypted: missing key_provider")
+	}
+
+	kvs, err := ctr(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(kvs, provider), nil
+}
+
+// Store encrypts the values of another store, the keys
+// are kept as they are so that the store can still iterate
+// them in order.  Each value is authenticated along with
+// its key, so values cannot be moved to other keys
+// unnoticed.  The index rows of upside_down have the terms
+// in their keys, while the segmented index keeps only ids
+// and internal keys there, with its segment files
+// encrypted by the same key provider.
+type Store struct {
+	o      store.KVStore
+	cipher *Cipher
+}
+
+func New(o store.KVStore, provider KeyProvider) *Store {
+	return &Store{
+		o:      o,
+		cipher: NewCipher(provider),
+	}
+}
+
+type Reader struct {
+	s *Store
+	o store.KVReader
+}
+
+type Writer struct {
+	s *Store
+	o store.KVWriter
+}
+
+type Iterator struct {
+	s   *Store
+	o   store.KVIterator
+	val []byte
+	err error
+}
+
+type Batch struct {
+	s   *Store
+	o   store.KVBatch
+	err error
+}
+
+func (s *Store) Open() error {
+	return s.o.Open()
+}
+
+func (s *Store) Close() error {
+	return s.o.Close()
+}
+
+// SetMergeOperator wraps the operator, so that the store
+// merges encrypted values
+func (s *Store) SetMergeOperator(mo store.MergeOperator) {
+	s.o.SetMergeOperator(&mergeOperator{s: s, mo: mo})
+}
+
+func (s *Store) Reader() (store.KVReader, error) {
+	o, err := s.o.Reader()
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{s: s, o: o}, nil
+}
+
+func (s *Store) Writer() (store.KVWriter, error) {
+	o, err := s.o.Writer()
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{s: s, o: o}, nil
+}
+
+// Compact compacts the underlying store, if it supports
+// compaction
+func (s *Store) Compact() error {
+	if compactor, ok := s.o.(store.KVCompactor); ok {
+		return compactor.Compact()
+	}
+	return nil
+}
+
+func (s *Store) Actual() store.KVStore {
+	return s.o
+}
+
+func (s *Store) decrypt(key, val []byte) ([]byte, error) {
+	if val == nil {
+		return nil, nil
+	}
+	return s.cipher.Decrypt(val, key)
+}
+
+// the decrypted values are copies
+func (r *Reader) BytesSafeAfterClose() bool {
+	return true
+}
+
+func (r *Reader) Get(key []byte) ([]byte, error) {
+	val, err := r.o.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return r.s.decrypt(key, val)
+}
+
+func (r *Reader) Iterator(key []byte) store.KVIterator {
+	return &Iterator{s: r.s, o: r.o.Iterator(key)}
+}
+
+func (r *Reader) Close() error {
+	return r.o.Close()
+}
+
+func (w *Writer) BytesSafeAfterClose() bool {
+	return true
+}
+
+func (w *Writer) Get(key []byte) ([]byte, error) {
+	val, err := w.o.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return w.s.decrypt(key, val)
+}
+
+func (w *Writer) Iterator(key []byte) store.KVIterator {
+	return &Iterator{s: w.s, o: w.o.Iterator(key)}
+}
+
+func (w *Writer) Close() error {
+	return w.o.Close()
+}
+
+func (w *Writer) Set(key, val []byte) error {
+	encrypted, err := w.s.cipher.Encrypt(val, key)
+	if err != nil {
+		return err
+	}
+	return w.o.Set(key, encrypted)
+}
+
+func (w *Writer) Delete(key []byte) error {
+	return w.o.Delete(key)
+}
+
+func (w *Writer) NewBatch() store.KVBatch {
+	return &Batch{s: w.s, o: w.o.NewBatch()}
+}
+
+func (i *Iterator) SeekFirst() {
+	i.val = nil
+	i.o.SeekFirst()
+}
+
+func (i *Iterator) Seek(key []byte) {
+	i.val = nil
+	i.o.Seek(key)
+}
+
+func (i *Iterator) Next() {
+	i.val = nil
+	i.o.Next()
+}
+
+func (i *Iterator) Current() ([]byte, []byte, bool) {
+	if i.Valid() {
+		return i.Key(), i.Value(), true
+	}
+	return nil, nil, false
+}
+
+func (i *Iterator) Key() []byte {
+	return i.o.Key()
+}
+
+// Value returns nil for values which do not decrypt, the
+// error is returned by Close
+func (i *Iterator) Value() []byte {
+	if i.val == nil {
+		val, err := i.s.decrypt(i.o.Key(), i.o.Value())
+		if err != nil {
+			if i.err == nil {
+				i.err = err
+			}
+			return nil
+		}
+		i.val = val
+	}
+	return i.val
+}
+
+func (i *Iterator) Valid() bool {
+	return i.o.Valid()
+}
+
+func (i *Iterator) Close() error {
+	err := i.o.Close()
+	if i.err != nil {
+		return i.err
+	}
+	return err
+}
+
+// the operations of a batch only fail on Execute, so the
+// first error encrypting a value is kept until then
+
+func (b *Batch) Set(key, val []byte) {
+	encrypted, err := b.s.cipher.Encrypt(val, key)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return
+	}
+	b.o.Set(key, encrypted)
+}
+
+func (b *Batch) Delete(key []byte) {
+	b.o.Delete(key)
+}
+
+func (b *Batch) Merge(key, val []byte) {
+	encrypted, err := b.s.cipher.Encrypt(val, key)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return
+	}
+	b.o.Merge(key, encrypted)
+}
+
+func (b *Batch) Execute() error {
+	if b.err != nil {
+		return b.err
+	}
+	return b.o.Execute()
+}
+
+func (b *Batch) Close() error {
+	return b.o.Close()
+}
+
+// mergeOperator decrypts the values before merging them,
+// and encrypts the result
+type mergeOperator struct {
+	s  *Store
+	mo store.MergeOperator
+}
+
+func (m *mergeOperator) FullMerge(key, existingValue []byte, operands [][]byte) ([]byte, bool) {
+	existing, err := m.s.decrypt(key, existingValue)
+	if err != nil {
+		return nil, false
+	}
+	decrypted := make([][]byte, len(operands))
+	for i, operand := range operands {
+		decrypted[i], err = m.s.decrypt(key, operand)
+		if err != nil {
+			return nil, false
+		}
+	}
+	merged, ok := m.mo.FullMerge(key, existing, decrypted)
+	if !ok {
+		return nil, false
+	}
+	rv, err := m.s.cipher.Encrypt(merged, key)
+	return rv, err == nil
+}
+
+func (m *mergeOperator) PartialMerge(key, leftOperand, rightOperand []byte) ([]byte, bool) {
+	left, err := m.s.decrypt(key, leftOperand)
+	if err != nil {
+		return nil, false
+	}
+	right, err := m.s.decrypt(key, rightOperand)
+	if err != nil {
+		return nil, false
+	}
+	merged, ok := m.mo.PartialMerge(key, left, right)
+	if !ok {
+		return nil, false
+	}
+	rv, err := m.s.cipher.Encrypt(merged, key)
+	return rv, err == nil
+}
+
+func (m *mergeOperator) Name() string {
+	return m.mo.Name()
+}
@@ -0,0 +1,492 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package encrypted
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/index/store/gtreap"
+)
+
+func testKeyProvider(t *testing.T, current string) KeyProvider {
+	provider, err := NewStaticKeyProvider(map[string][]byte{
+		"1": bytes.Repeat([]byte{1}, 16),
+		"2": bytes.Repeat([]byte{2}, 32),
+	}, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestEncryptedStore(t *testing.T) {
+	_, err := StoreConstructor(map[string]interface{}{
+		"kvStoreName_actual": "gtreap",
+	})
+	if err == nil {
+		t.Errorf("expected err without key_provider")
+	}
+	_, err = StoreConstructor(map[string]interface{}{
+		"kvStoreName_actual": "gtreap",
+		"key_provider":       "unknown",
+	})
+	if err == nil {
+		t.Errorf("expected err for unknown key_provider")
+	}
+
+	RegisterKeyProvider("test", testKeyProvider(t, "1"))
+	s, err := StoreConstructor(map[string]interface{}{
+		"kvStoreName_actual": "gtreap",
+		"key_provider":       "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CommonTestKVStore(t, s)
+}
+
+func TestReaderIsolation(t *testing.T) {
+	actual, err := gtreap.StoreConstructor(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	CommonTestReaderIsolation(t, New(actual, testKeyProvider(t, "1")))
+}
+
+func TestEncryption(t *testing.T) {
+	actual, err := gtreap.StoreConstructor(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(actual, testKeyProvider(t, "1"))
+	writer, err := s.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Set([]byte("a"), []byte("secret-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Set([]byte("empty"), []byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the actual store only has the encrypted form
+	reader, err := actual.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := reader.Get([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw == nil || bytes.Contains(raw, []byte("secret")) {
+		t.Errorf("expected the value to be encrypted, got %q", raw)
+	}
+	err = reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a value moved to another key does not decrypt
+	writer, err = actual.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Set([]byte("b"), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// with the next key current, the values encrypted with
+	// the first one are still read
+	s = New(actual, testKeyProvider(t, "2"))
+	writer, err = s.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Set([]byte("c"), []byte("secret-c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sreader, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]string{"a": "secret-a", "c": "secret-c"} {
+		val, err := sreader.Get([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != expected {
+			t.Errorf("expected %s, got %s", expected, val)
+		}
+	}
+	val, err := sreader.Get([]byte("empty"))
+	if err != nil || val == nil || len(val) != 0 {
+		t.Errorf("expected empty value, got %v, %v", val, err)
+	}
+	_, err = sreader.Get([]byte("b"))
+	if err != ErrCorrupt {
+		t.Errorf("expected ErrCorrupt for the moved value, got %v", err)
+	}
+	it := sreader.Iterator([]byte("b"))
+	if it.Value() != nil {
+		t.Errorf("expected no value for the moved value")
+	}
+	err = it.Close()
+	if err != ErrCorrupt {
+		t.Errorf("expected ErrCorrupt closing the iterator, got %v", err)
+	}
+	err = sreader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without the first key, its values cannot be read
+	provider, err := NewStaticKeyProvider(map[string][]byte{
+		"2": bytes.Repeat([]byte{2}, 32),
+	}, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sreader, err = New(actual, provider).Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sreader.Get([]byte("a"))
+	if err != ErrUnknownKey {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+	err = sreader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// counter adds up the operands as 64 bit counts
+type counter struct{}
+
+func (c *counter) FullMerge(key, existingValue []byte, operands [][]byte) ([]byte, bool) {
+	var count uint64
+	if existingValue != nil {
+		count = binary.LittleEndian.Uint64(existingValue)
+	}
+	for _, operand := range operands {
+		count += binary.LittleEndian.Uint64(operand)
+	}
+	rv := make([]byte, 8)
+	binary.LittleEndian.PutUint64(rv, count)
+	return rv, true
+}
+
+func (c *counter) PartialMerge(key, leftOperand, rightOperand []byte) ([]byte, bool) {
+	return c.FullMerge(key, leftOperand, [][]byte{rightOperand})
+}
+
+func (c *counter) Name() string {
+	return "counter"
+}
+
+func TestMerge(t *testing.T) {
+	actual, err := gtreap.StoreConstructor(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(actual, testKeyProvider(t, "1"))
+	s.SetMergeOperator(&counter{})
+	err = s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	one := make([]byte, 8)
+	binary.LittleEndian.PutUint64(one, 1)
+	for i := 0; i < 2; i++ {
+		writer, err := s.Writer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := writer.NewBatch()
+		batch.Merge([]byte("a"), one)
+		batch.Merge([]byte("a"), one)
+		err = batch.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = batch.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	val, err := reader.Get([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := make([]byte, 8)
+	binary.LittleEndian.PutUint64(expected, 4)
+	if !reflect.DeepEqual(val, expected) {
+		t.Errorf("expected count 4, got %v", val)
+	}
+}
+
+func CommonTestKVStore(t *testing.T, s store.KVStore) {
+	writer, err := s.Writer()
+	if err != nil {
+		t.Error(err)
+	}
+	err = writer.Set([]byte("a"), []byte("val-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Set([]byte("z"), []byte("val-z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Delete([]byte("z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := writer.NewBatch()
+	batch.Set([]byte("b"), []byte("val-b"))
+	batch.Set([]byte("c"), []byte("val-c"))
+	batch.Set([]byte("d"), []byte("val-d"))
+	batch.Set([]byte("e"), []byte("val-e"))
+	batch.Set([]byte("f"), []byte("val-f"))
+	batch.Set([]byte("g"), []byte("val-g"))
+	batch.Set([]byte("h"), []byte("val-h"))
+	batch.Set([]byte("i"), []byte("val-i"))
+	batch.Set([]byte("j"), []byte("val-j"))
+
+	err = batch.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Error(err)
+	}
+	defer func() {
+		err := reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	it := reader.Iterator([]byte("b"))
+	key, val, valid := it.Current()
+	if !valid {
+		t.Fatalf("valid false, expected true")
+	}
+	if string(key) != "b" {
+		t.Fatalf("expected key b, got %s", key)
+	}
+	if string(val) != "val-b" {
+		t.Fatalf("expected value val-b, got %s", val)
+	}
+
+	it.Next()
+	key, val, valid = it.Current()
+	if !valid {
+		t.Fatalf("valid false, expected true")
+	}
+	if string(key) != "c" {
+		t.Fatalf("expected key c, got %s", key)
+	}
+	if string(val) != "val-c" {
+		t.Fatalf("expected value val-c, got %s", val)
+	}
+
+	it.Seek([]byte("i"))
+	key, val, valid = it.Current()
+	if !valid {
+		t.Fatalf("valid false, expected true")
+	}
+	if string(key) != "i" {
+		t.Fatalf("expected key i, got %s", key)
+	}
+	if string(val) != "val-i" {
+		t.Fatalf("expected value val-i, got %s", val)
+	}
+
+	err = it.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func CommonTestReaderIsolation(t *testing.T, s store.KVStore) {
+	// insert a kv pair
+	writer, err := s.Writer()
+	if err != nil {
+		t.Error(err)
+	}
+	err = writer.Set([]byte("a"), []byte("val-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create an isolated reader
+	reader, err := s.Reader()
+	if err != nil {
+		t.Error(err)
+	}
+	defer func() {
+		err := reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// verify that we see the value already inserted
+	val, err := reader.Get([]byte("a"))
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(val, []byte("val-a")) {
+		t.Errorf("expected val-a, got nil")
+	}
+
+	// verify that an iterator sees it
+	count := 0
+	it := reader.Iterator([]byte{0})
+	defer func() {
+		err := it.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for it.Valid() {
+		it.Next()
+		count++
+	}
+	if count != 1 {
+		t.Errorf("expected iterator to see 1, saw %d", count)
+	}
+
+	// add something after the reader was created
+	writer, err = s.Writer()
+	if err != nil {
+		t.Error(err)
+	}
+	err = writer.Set([]byte("b"), []byte("val-b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ensure that a newer reader sees it
+	newReader, err := s.Reader()
+	if err != nil {
+		t.Error(err)
+	}
+	defer func() {
+		err := newReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	val, err = newReader.Get([]byte("b"))
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(val, []byte("val-b")) {
+		t.Errorf("expected val-b, got nil")
+	}
+
+	// ensure that the director iterator sees it
+	count = 0
+	it2 := newReader.Iterator([]byte{0})
+	defer func() {
+		err := it2.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for it2.Valid() {
+		it2.Next()
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected iterator to see 2, saw %d", count)
+	}
+
+	// but that the isolated reader does not
+	val, err = reader.Get([]byte("b"))
+	if err != nil {
+		t.Error(err)
+	}
+	if val != nil {
+		t.Errorf("expected nil, got %v", val)
+	}
+
+	// and ensure that the iterator on the isolated reader also does not
+	count = 0
+	it3 := reader.Iterator([]byte{0})
+	defer func() {
+		err := it3.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for it3.Valid() {
+		it3.Next()
+		count++
+	}
+	if count != 1 {
+		t.Errorf("expected iterator to see 1, saw %d", count)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 const Name = "goleveldb"
@@ -103,6 +104,12 @@ func (ldbs *Store) Close() error {
 	return ldbs.db.Close()
 }
 
+// Compact compacts the whole key space, reclaiming the
+// space of deleted keys
+func (ldbs *Store) Compact() error {
+	return ldbs.db.CompactRange(util.Range{})
+}
+
 func (ldbs *Store) iterator(key []byte) store.KVIterator {
 	rv := newIterator(ldbs)
 	rv.Seek(key)
@@ -47,6 +47,51 @@ func TestLevelDBStore(t *testing.T) {
 	CommonTestKVStore(t, s)
 }
 
+func TestLevelDBStoreCompact(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	s, err := New("test", leveldbTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var _ store.KVCompactor = s
+	err = s.set([]byte("a"), []byte("val-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.delete([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := s.get([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != nil {
+		t.Errorf("expected deleted key to stay deleted, got %s", val)
+	}
+}
+
 func TestLevelDBStoreIterator(t *testing.T) {
 	defer func() {
 		err := os.RemoveAll("test")
@@ -34,6 +34,9 @@ func init() {
 const MAX_CONCURRENT_WRITERS = 1
 
 func StoreConstructor(config map[string]interface{}) (store.KVStore, error) {
+	if ro, _ := config["read_only"].(bool); ro {
+		return nil, store.ErrReadOnlyUnsupported
+	}
 	s := &Store{
 		availableWriters: make(chan bool, MAX_CONCURRENT_WRITERS),
 		t:                gtreap.NewTreap(itemCompare),
@@ -98,6 +98,9 @@ func (i *Store) Writer() (store.KVWriter, error) {
 }
 
 func StoreConstructor(config map[string]interface{}) (store.KVStore, error) {
+	if ro, _ := config["read_only"].(bool); ro {
+		return nil, store.ErrReadOnlyUnsupported
+	}
 	return New()
 }
 
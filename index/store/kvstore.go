@@ -9,6 +9,15 @@
 
 package store
 
+import (
+	"fmt"
+)
+
+// ErrReadOnlyUnsupported is returned by the constructors of
+// the stores which cannot be opened read only when their
+// config asks for it
+var ErrReadOnlyUnsupported = fmt.Errorf("kv store does not support read only opening")
+
 type KVBatch interface {
 	Set(key, val []byte)
 	Delete(key []byte)
@@ -52,6 +61,11 @@ type KVReader interface {
 	Close() error
 }
 
+// A KVCompactor can reclaim the space of deleted keys
+type KVCompactor interface {
+	Compact() error
+}
+
 type RangeIterable interface {
 	// iterates keys >= start and < end
 	RangeIterator(start, end []byte) KVIterator
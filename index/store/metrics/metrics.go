@@ -159,6 +159,15 @@ func (s *Store) Writer() (store.KVWriter, error) {
 	return &Writer{s: s, o: o}, nil
 }
 
+// Compact compacts the underlying store, if it supports
+// compaction
+func (s *Store) Compact() error {
+	if compactor, ok := s.o.(store.KVCompactor); ok {
+		return compactor.Compact()
+	}
+	return nil
+}
+
 func (s *Store) Actual() store.KVStore {
 	return s.o
 }
@@ -176,6 +176,9 @@ func (w *Writer) Iterator(key []byte) store.KVIterator {
 }
 
 func StoreConstructor(config map[string]interface{}) (store.KVStore, error) {
+	if ro, _ := config["read_only"].(bool); ro {
+		return nil, store.ErrReadOnlyUnsupported
+	}
 	return New()
 }
 
@@ -0,0 +1,53 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build pebble full
+
+package pebble
+
+import (
+	"github.com/cockroachdb/pebble"
+)
+
+type Batch struct {
+	batch *pebble.Batch
+	err   error
+}
+
+// the operations of a batch are only written on Execute,
+// so the first error is kept until then
+
+func (b *Batch) Set(key, val []byte) {
+	if b.err == nil {
+		b.err = b.batch.Set(key, val, nil)
+	}
+}
+
+func (b *Batch) Delete(key []byte) {
+	if b.err == nil {
+		b.err = b.batch.Delete(key, nil)
+	}
+}
+
+func (b *Batch) Merge(key, val []byte) {
+	if b.err == nil {
+		b.err = b.batch.Merge(key, val, nil)
+	}
+}
+
+func (b *Batch) Execute() error {
+	if b.err != nil {
+		return b.err
+	}
+	return b.batch.Commit(pebble.Sync)
+}
+
+func (b *Batch) Close() error {
+	return b.batch.Close()
+}
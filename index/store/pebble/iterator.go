@@ -0,0 +1,94 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build pebble full
+
+package pebble
+
+import (
+	"github.com/cockroachdb/pebble"
+)
+
+type Iterator struct {
+	iterator *pebble.Iterator
+	err      error
+	valid    bool
+	copyk    []byte
+	copyv    []byte
+}
+
+func newIterator(r pebble.Reader, opts *pebble.IterOptions) *Iterator {
+	iter, err := r.NewIter(opts)
+	return &Iterator{
+		iterator: iter,
+		err:      err,
+	}
+}
+
+func (pi *Iterator) SeekFirst() {
+	pi.copyk = nil
+	pi.copyv = nil
+	if pi.err == nil {
+		pi.valid = pi.iterator.First()
+	}
+}
+
+func (pi *Iterator) Seek(key []byte) {
+	pi.copyk = nil
+	pi.copyv = nil
+	if pi.err == nil {
+		pi.valid = pi.iterator.SeekGE(key)
+	}
+}
+
+func (pi *Iterator) Next() {
+	pi.copyk = nil
+	pi.copyv = nil
+	if pi.valid {
+		pi.valid = pi.iterator.Next()
+	}
+}
+
+func (pi *Iterator) Current() ([]byte, []byte, bool) {
+	if pi.Valid() {
+		return pi.Key(), pi.Value(), true
+	}
+	return nil, nil, false
+}
+
+func (pi *Iterator) Key() []byte {
+	if pi.copyk == nil {
+		k := pi.iterator.Key()
+		pi.copyk = make([]byte, len(k))
+		copy(pi.copyk, k)
+	}
+	return pi.copyk
+}
+
+func (pi *Iterator) Value() []byte {
+	if pi.copyv == nil {
+		v := pi.iterator.Value()
+		pi.copyv = make([]byte, len(v))
+		copy(pi.copyv, v)
+	}
+	return pi.copyv
+}
+
+func (pi *Iterator) Valid() bool {
+	return pi.valid
+}
+
+func (pi *Iterator) Close() error {
+	pi.copyk = nil
+	pi.copyv = nil
+	if pi.err != nil {
+		return pi.err
+	}
+	return pi.iterator.Close()
+}
@@ -0,0 +1,82 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build pebble full
+
+package pebble
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/cockroachdb/pebble"
+)
+
+// newMerger adapts a bleve MergeOperator to Pebble, which
+// collects the operands of a key and asks for a single
+// value once it has seen all it is going to merge
+func newMerger(mo store.MergeOperator) *pebble.Merger {
+	return &pebble.Merger{
+		Name: mo.Name(),
+		Merge: func(key, value []byte) (pebble.ValueMerger, error) {
+			return &valueMerger{
+				mo:       mo,
+				key:      append([]byte(nil), key...),
+				operands: [][]byte{append([]byte(nil), value...)},
+			}, nil
+		},
+	}
+}
+
+type valueMerger struct {
+	mo  store.MergeOperator
+	key []byte
+	// oldest first
+	operands [][]byte
+}
+
+func (m *valueMerger) MergeNewer(value []byte) error {
+	m.operands = append(m.operands, append([]byte(nil), value...))
+	return nil
+}
+
+func (m *valueMerger) MergeOlder(value []byte) error {
+	m.operands = append([][]byte{append([]byte(nil), value...)}, m.operands...)
+	return nil
+}
+
+// Finish merges the operands.  Pebble hands the value set
+// before the operands to MergeOlder like any operand, so
+// when the merge includes it the oldest operand is taken
+// as the existing value.  Pebble tells the same of merges
+// at the bottom of the tree which have no value set, the
+// oldest operand then stands in for it, which works for
+// operators whose operands are values as well, such as
+// the dictionary counts of upside_down.  Otherwise the
+// operands are only partially merged, the result is
+// merged again later.
+func (m *valueMerger) Finish(includesBase bool) ([]byte, io.Closer, error) {
+	if includesBase {
+		rv, ok := m.mo.FullMerge(m.key, m.operands[0], m.operands[1:])
+		if !ok {
+			return nil, nil, fmt.Errorf("merge operator returned failure")
+		}
+		return rv, nil, nil
+	}
+	rv := m.operands[0]
+	for _, operand := range m.operands[1:] {
+		var ok bool
+		rv, ok = m.mo.PartialMerge(m.key, rv, operand)
+		if !ok {
+			return nil, nil, fmt.Errorf("merge operator cannot partially merge")
+		}
+	}
+	return rv, nil, nil
+}
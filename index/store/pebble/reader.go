@@ -0,0 +1,58 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build pebble full
+
+package pebble
+
+import (
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/cockroachdb/pebble"
+)
+
+type Reader struct {
+	store    *Store
+	snapshot *pebble.Snapshot
+}
+
+func newReader(store *Store) (*Reader, error) {
+	return &Reader{
+		store:    store,
+		snapshot: store.db.NewSnapshot(),
+	}, nil
+}
+
+func (r *Reader) BytesSafeAfterClose() bool {
+	return true
+}
+
+func (r *Reader) Get(key []byte) ([]byte, error) {
+	return get(r.snapshot, key)
+}
+
+func (r *Reader) Iterator(key []byte) store.KVIterator {
+	rv := newIterator(r.snapshot, nil)
+	rv.Seek(key)
+	return rv
+}
+
+// RangeIterator bounds the iterator, so that Pebble can
+// skip the files past end
+func (r *Reader) RangeIterator(start, end []byte) store.KVIterator {
+	rv := newIterator(r.snapshot, &pebble.IterOptions{
+		LowerBound: start,
+		UpperBound: end,
+	})
+	rv.Seek(start)
+	return rv
+}
+
+func (r *Reader) Close() error {
+	return r.snapshot.Close()
+}
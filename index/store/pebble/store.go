@@ -0,0 +1,176 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build pebble full
+
+package pebble
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/registry"
+	"github.com/cockroachdb/pebble"
+)
+
+const Name = "pebble"
+
+// Store keeps the index in a Pebble database.  Merges are
+// handed to Pebble's own merge operator, so they are
+// resolved on reads and compactions instead of on write.
+type Store struct {
+	path   string
+	opts   *pebble.Options
+	db     *pebble.DB
+	writer sync.Mutex
+	mo     store.MergeOperator
+}
+
+func New(path string, config map[string]interface{}) (*Store, error) {
+	rv := Store{
+		path: path,
+		opts: &pebble.Options{},
+	}
+
+	_, err := applyConfig(rv.opts, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rv, nil
+}
+
+func (ps *Store) Open() error {
+	if ps.mo != nil {
+		ps.opts.Merger = newMerger(ps.mo)
+	}
+	var err error
+	ps.db, err = pebble.Open(ps.path, ps.opts)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetMergeOperator must be called before Open, the name of
+// the operator is recorded in the database and has to be
+// the same whenever it is opened
+func (ps *Store) SetMergeOperator(mo store.MergeOperator) {
+	ps.mo = mo
+}
+
+func (ps *Store) get(key []byte) ([]byte, error) {
+	return get(ps.db, key)
+}
+
+// get copies the value out, Pebble only lends it until
+// the closer is called
+func get(r pebble.Reader, key []byte) ([]byte, error) {
+	v, closer, err := r.Get(key)
+	if err == pebble.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rv := make([]byte, len(v))
+	copy(rv, v)
+	return rv, closer.Close()
+}
+
+func (ps *Store) setlocked(key, val []byte) error {
+	return ps.db.Set(key, val, pebble.Sync)
+}
+
+func (ps *Store) deletelocked(key []byte) error {
+	return ps.db.Delete(key, pebble.Sync)
+}
+
+func (ps *Store) Close() error {
+	return ps.db.Close()
+}
+
+// Compact compacts the whole key space, reclaiming the
+// space of deleted keys
+func (ps *Store) Compact() error {
+	iter, err := ps.db.NewIter(nil)
+	if err != nil {
+		return err
+	}
+	var first, last []byte
+	if iter.First() {
+		first = append([]byte(nil), iter.Key()...)
+	}
+	if iter.Last() {
+		last = append([]byte(nil), iter.Key()...)
+	}
+	err = iter.Close()
+	if err != nil || first == nil {
+		return err
+	}
+	// the end of a compaction is exclusive
+	return ps.db.Compact(first, append(last, 0), true)
+}
+
+func (ps *Store) Reader() (store.KVReader, error) {
+	return newReader(ps)
+}
+
+func (ps *Store) Writer() (store.KVWriter, error) {
+	return newWriter(ps)
+}
+
+func StoreConstructor(config map[string]interface{}) (store.KVStore, error) {
+	path, ok := config["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("must specify path")
+	}
+	return New(path, config)
+}
+
+func init() {
+	registry.RegisterKVStore(Name, StoreConstructor)
+}
+
+func applyConfig(o *pebble.Options, config map[string]interface{}) (
+	*pebble.Options, error) {
+
+	ro, ok := config["read_only"].(bool)
+	if ok {
+		o.ReadOnly = ro
+	}
+
+	cim, ok := config["create_if_missing"].(bool)
+	if ok {
+		o.ErrorIfNotExists = !cim
+	}
+
+	eie, ok := config["error_if_exists"].(bool)
+	if ok {
+		o.ErrorIfExists = eie
+	}
+
+	mts, ok := config["memtable_size"].(float64)
+	if ok {
+		o.MemTableSize = uint64(mts)
+	}
+
+	mof, ok := config["max_open_files"].(float64)
+	if ok {
+		o.MaxOpenFiles = int(mof)
+	}
+
+	cs, ok := config["cache_size"].(float64)
+	if ok {
+		o.Cache = pebble.NewCache(int64(cs))
+	}
+
+	return o, nil
+}
@@ -0,0 +1,174 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build rocksdb full
+
+package rocksdb
+
+import (
+	"fmt"
+
+	"github.com/tecbot/gorocksdb"
+)
+
+var compactionStyles = map[string]gorocksdb.CompactionStyle{
+	"level":     gorocksdb.LevelCompactionStyle,
+	"universal": gorocksdb.UniversalCompactionStyle,
+	"fifo":      gorocksdb.FIFOCompactionStyle,
+}
+
+var compressionTypes = map[string]gorocksdb.CompressionType{
+	"none":   gorocksdb.NoCompression,
+	"snappy": gorocksdb.SnappyCompression,
+	"zlib":   gorocksdb.ZLibCompression,
+	"bz2":    gorocksdb.Bz2Compression,
+	"lz4":    gorocksdb.LZ4Compression,
+	"lz4hc":  gorocksdb.LZ4HCCompression,
+}
+
+// applyConfig passes the config through to the options of
+// RocksDB.  The block based table options, the block
+// cache, bloom filters and block size, are only set when
+// one of them is configured, otherwise RocksDB keeps its
+// defaults.
+func (rs *Store) applyConfig(config map[string]interface{}) error {
+	o := rs.opts
+
+	ro, ok := config["read_only"].(bool)
+	if ok {
+		rs.readOnly = ro
+	}
+
+	s, ok := config["sync"].(bool)
+	if ok {
+		rs.sync = s
+	}
+
+	cim, ok := config["create_if_missing"].(bool)
+	if ok {
+		o.SetCreateIfMissing(cim)
+	}
+
+	eie, ok := config["error_if_exists"].(bool)
+	if ok {
+		o.SetErrorIfExists(eie)
+	}
+
+	pc, ok := config["paranoid_checks"].(bool)
+	if ok {
+		o.SetParanoidChecks(pc)
+	}
+
+	ip, ok := config["increase_parallelism"].(float64)
+	if ok {
+		o.IncreaseParallelism(int(ip))
+	}
+
+	olsc, ok := config["optimize_level_style_compaction"].(float64)
+	if ok {
+		o.OptimizeLevelStyleCompaction(uint64(olsc))
+	}
+
+	wbs, ok := config["write_buffer_size"].(float64)
+	if ok {
+		o.SetWriteBufferSize(int(wbs))
+	}
+
+	mwbn, ok := config["max_write_buffer_number"].(float64)
+	if ok {
+		o.SetMaxWriteBufferNumber(int(mwbn))
+	}
+
+	mof, ok := config["max_open_files"].(float64)
+	if ok {
+		o.SetMaxOpenFiles(int(mof))
+	}
+
+	mbc, ok := config["max_background_compactions"].(float64)
+	if ok {
+		o.SetMaxBackgroundCompactions(int(mbc))
+	}
+
+	mbf, ok := config["max_background_flushes"].(float64)
+	if ok {
+		o.SetMaxBackgroundFlushes(int(mbf))
+	}
+
+	nl, ok := config["num_levels"].(float64)
+	if ok {
+		o.SetNumLevels(int(nl))
+	}
+
+	l0, ok := config["level0_file_num_compaction_trigger"].(float64)
+	if ok {
+		o.SetLevel0FileNumCompactionTrigger(int(l0))
+	}
+
+	tfsb, ok := config["target_file_size_base"].(float64)
+	if ok {
+		o.SetTargetFileSizeBase(uint64(tfsb))
+	}
+
+	mbflb, ok := config["max_bytes_for_level_base"].(float64)
+	if ok {
+		o.SetMaxBytesForLevelBase(uint64(mbflb))
+	}
+
+	if v, ok := config["compaction_style"]; ok {
+		name, _ := v.(string)
+		style, ok := compactionStyles[name]
+		if !ok {
+			return fmt.Errorf("unknown compaction_style: %v", v)
+		}
+		o.SetCompactionStyle(style)
+	}
+
+	if v, ok := config["compression"]; ok {
+		name, _ := v.(string)
+		compression, ok := compressionTypes[name]
+		if !ok {
+			return fmt.Errorf("unknown compression: %v", v)
+		}
+		o.SetCompression(compression)
+	}
+
+	var bbto *gorocksdb.BlockBasedTableOptions
+	tableOptions := func() *gorocksdb.BlockBasedTableOptions {
+		if bbto == nil {
+			bbto = gorocksdb.NewDefaultBlockBasedTableOptions()
+		}
+		return bbto
+	}
+
+	bs, ok := config["block_size"].(float64)
+	if ok {
+		tableOptions().SetBlockSize(int(bs))
+	}
+
+	bcs, ok := config["block_cache_size"].(float64)
+	if ok {
+		tableOptions().SetBlockCache(gorocksdb.NewLRUCache(uint64(bcs)))
+	}
+
+	bfbpk, ok := config["bloom_filter_bits_per_key"].(float64)
+	if ok {
+		tableOptions().SetFilterPolicy(gorocksdb.NewBloomFilter(int(bfbpk)))
+	}
+
+	ciafb, ok := config["cache_index_and_filter_blocks"].(bool)
+	if ok {
+		tableOptions().SetCacheIndexAndFilterBlocks(ciafb)
+	}
+
+	if bbto != nil {
+		o.SetBlockBasedTableFactory(bbto)
+	}
+
+	return nil
+}
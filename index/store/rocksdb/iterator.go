@@ -0,0 +1,97 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build rocksdb full
+
+package rocksdb
+
+import (
+	"github.com/tecbot/gorocksdb"
+)
+
+type Iterator struct {
+	options  *gorocksdb.ReadOptions
+	iterator *gorocksdb.Iterator
+	copyk    []byte
+	copyv    []byte
+}
+
+// newIterator reads from the snapshot, or the current
+// state when it is nil, and stops before end when it is
+// not nil
+func newIterator(store *Store, snapshot *gorocksdb.Snapshot, end []byte) *Iterator {
+	options := defaultReadOptions()
+	if snapshot != nil {
+		options.SetSnapshot(snapshot)
+	}
+	if end != nil {
+		options.SetIterateUpperBound(end)
+	}
+	return &Iterator{
+		options:  options,
+		iterator: store.db.NewIterator(options),
+	}
+}
+
+func (ri *Iterator) SeekFirst() {
+	ri.copyk = nil
+	ri.copyv = nil
+	ri.iterator.SeekToFirst()
+}
+
+func (ri *Iterator) Seek(key []byte) {
+	ri.copyk = nil
+	ri.copyv = nil
+	ri.iterator.Seek(key)
+}
+
+func (ri *Iterator) Next() {
+	ri.copyk = nil
+	ri.copyv = nil
+	ri.iterator.Next()
+}
+
+func (ri *Iterator) Current() ([]byte, []byte, bool) {
+	if ri.Valid() {
+		return ri.Key(), ri.Value(), true
+	}
+	return nil, nil, false
+}
+
+func (ri *Iterator) Key() []byte {
+	if ri.copyk == nil {
+		k := ri.iterator.Key()
+		ri.copyk = make([]byte, k.Size())
+		copy(ri.copyk, k.Data())
+		k.Free()
+	}
+	return ri.copyk
+}
+
+func (ri *Iterator) Value() []byte {
+	if ri.copyv == nil {
+		v := ri.iterator.Value()
+		ri.copyv = make([]byte, v.Size())
+		copy(ri.copyv, v.Data())
+		v.Free()
+	}
+	return ri.copyv
+}
+
+func (ri *Iterator) Valid() bool {
+	return ri.iterator.Valid()
+}
+
+func (ri *Iterator) Close() error {
+	ri.copyk = nil
+	ri.copyv = nil
+	ri.iterator.Close()
+	ri.options.Destroy()
+	return nil
+}
@@ -0,0 +1,155 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build rocksdb full
+
+package rocksdb
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/registry"
+	"github.com/tecbot/gorocksdb"
+)
+
+const Name = "rocksdb"
+
+// Store keeps the index in a RocksDB database.  The merge
+// operators of bleve have the interface of RocksDB's, so
+// they are handed to RocksDB directly.
+type Store struct {
+	path   string
+	opts   *gorocksdb.Options
+	sync   bool
+	db     *gorocksdb.DB
+	writer sync.Mutex
+	mo     store.MergeOperator
+	// open the database read-only
+	readOnly bool
+}
+
+func New(path string, config map[string]interface{}) (*Store, error) {
+	rv := Store{
+		path: path,
+		opts: gorocksdb.NewDefaultOptions(),
+		// request fsync on write for safety
+		sync: true,
+	}
+
+	err := rv.applyConfig(config)
+	if err != nil {
+		rv.opts.Destroy()
+		return nil, err
+	}
+
+	return &rv, nil
+}
+
+func (rs *Store) Open() error {
+	if rs.mo != nil {
+		rs.opts.SetMergeOperator(rs.mo)
+	}
+	var err error
+	if rs.readOnly {
+		rs.db, err = gorocksdb.OpenDbForReadOnly(rs.opts, rs.path, false)
+	} else {
+		rs.db, err = gorocksdb.OpenDb(rs.opts, rs.path)
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetMergeOperator must be called before Open
+func (rs *Store) SetMergeOperator(mo store.MergeOperator) {
+	rs.mo = mo
+}
+
+func (rs *Store) get(key []byte) ([]byte, error) {
+	options := defaultReadOptions()
+	defer options.Destroy()
+	return rs.db.GetBytes(options, key)
+}
+
+func (rs *Store) getWithSnapshot(key []byte, snapshot *gorocksdb.Snapshot) ([]byte, error) {
+	options := defaultReadOptions()
+	defer options.Destroy()
+	options.SetSnapshot(snapshot)
+	return rs.db.GetBytes(options, key)
+}
+
+func (rs *Store) setlocked(key, val []byte) error {
+	options := rs.writeOptions()
+	defer options.Destroy()
+	return rs.db.Put(options, key, val)
+}
+
+func (rs *Store) deletelocked(key []byte) error {
+	options := rs.writeOptions()
+	defer options.Destroy()
+	return rs.db.Delete(options, key)
+}
+
+func (rs *Store) write(batch *gorocksdb.WriteBatch) error {
+	options := rs.writeOptions()
+	defer options.Destroy()
+	return rs.db.Write(options, batch)
+}
+
+func (rs *Store) writeOptions() *gorocksdb.WriteOptions {
+	wo := gorocksdb.NewDefaultWriteOptions()
+	wo.SetSync(rs.sync)
+	return wo
+}
+
+func (rs *Store) Close() error {
+	rs.db.Close()
+	rs.opts.Destroy()
+	return nil
+}
+
+// Compact compacts the whole key space, reclaiming the
+// space of deleted keys
+func (rs *Store) Compact() error {
+	rs.db.CompactRange(gorocksdb.Range{})
+	return nil
+}
+
+func (rs *Store) iterator(key []byte) store.KVIterator {
+	rv := newIterator(rs, nil, nil)
+	rv.Seek(key)
+	return rv
+}
+
+func (rs *Store) Reader() (store.KVReader, error) {
+	return newReader(rs)
+}
+
+func (rs *Store) Writer() (store.KVWriter, error) {
+	return newWriter(rs)
+}
+
+func StoreConstructor(config map[string]interface{}) (store.KVStore, error) {
+	path, ok := config["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("must specify path")
+	}
+	return New(path, config)
+}
+
+func init() {
+	registry.RegisterKVStore(Name, StoreConstructor)
+}
+
+func defaultReadOptions() *gorocksdb.ReadOptions {
+	return gorocksdb.NewDefaultReadOptions()
+}
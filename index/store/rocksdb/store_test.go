@@ -0,0 +1,606 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build rocksdb full
+
+package rocksdb
+
+import (
+	"encoding/binary"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/index/store"
+)
+
+var rocksdbTestOptions = map[string]interface{}{
+	"create_if_missing": true,
+}
+
+func TestRocksDBConfig(t *testing.T) {
+	tests := []struct {
+		config map[string]interface{}
+		valid  bool
+	}{
+		{
+			config: map[string]interface{}{
+				"compaction_style":          "universal",
+				"compression":               "lz4",
+				"block_cache_size":          float64(8 << 20),
+				"bloom_filter_bits_per_key": float64(10),
+			},
+			valid: true,
+		},
+		{
+			config: map[string]interface{}{"compaction_style": "tiered"},
+		},
+		{
+			config: map[string]interface{}{"compression": 1.0},
+		},
+	}
+	for i, test := range tests {
+		s, err := New("test", test.config)
+		if (err == nil) != test.valid {
+			t.Errorf("%d: expected valid %t, got error %v", i, test.valid, err)
+		}
+		if err == nil {
+			s.opts.Destroy()
+		}
+	}
+}
+
+func TestRocksDBStore(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	s, err := New("test", rocksdbTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	CommonTestKVStore(t, s)
+}
+
+func TestRocksDBStoreCompact(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	s, err := New("test", rocksdbTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var _ store.KVCompactor = s
+	writer, err := s.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Set([]byte("a"), []byte("val-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Delete([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := s.get([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != nil {
+		t.Errorf("expected deleted key to stay deleted, got %s", val)
+	}
+}
+
+func TestRocksDBStoreIterator(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	s, err := New("test", rocksdbTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	CommonTestKVStoreIterator(t, s)
+}
+
+func TestReaderIsolation(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	s, err := New("test", rocksdbTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	CommonTestReaderIsolation(t, s)
+}
+
+// counter adds up the operands as 64 bit counts
+type counter struct{}
+
+func (c *counter) FullMerge(key, existingValue []byte, operands [][]byte) ([]byte, bool) {
+	var count uint64
+	if existingValue != nil {
+		count = binary.LittleEndian.Uint64(existingValue)
+	}
+	for _, operand := range operands {
+		count += binary.LittleEndian.Uint64(operand)
+	}
+	rv := make([]byte, 8)
+	binary.LittleEndian.PutUint64(rv, count)
+	return rv, true
+}
+
+func (c *counter) PartialMerge(key, leftOperand, rightOperand []byte) ([]byte, bool) {
+	return c.FullMerge(key, leftOperand, [][]byte{rightOperand})
+}
+
+func (c *counter) Name() string {
+	return "counter"
+}
+
+func TestMergeOperator(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	s, err := New("test", rocksdbTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetMergeOperator(&counter{})
+	err = s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	one := make([]byte, 8)
+	binary.LittleEndian.PutUint64(one, 1)
+	ten := make([]byte, 8)
+	binary.LittleEndian.PutUint64(ten, 10)
+	merge := func(key string, n int) {
+		writer, err := s.Writer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := writer.NewBatch()
+		for i := 0; i < n; i++ {
+			batch.Merge([]byte(key), one)
+		}
+		err = batch.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = batch.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(key string) uint64 {
+		reader, err := s.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := reader.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		val, err := reader.Get([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(val) != 8 {
+			t.Fatalf("expected count of %s, got %v", key, val)
+		}
+		return binary.LittleEndian.Uint64(val)
+	}
+
+	merge("a", 3)
+	merge("a", 2)
+	if n := count("a"); n != 5 {
+		t.Errorf("expected 5, got %d", n)
+	}
+
+	writer, err := s.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Set([]byte("b"), ten)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	merge("b", 2)
+	if n := count("b"); n != 12 {
+		t.Errorf("expected 12, got %d", n)
+	}
+
+	err = s.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count("a"); n != 5 {
+		t.Errorf("expected 5 after compaction, got %d", n)
+	}
+	if n := count("b"); n != 12 {
+		t.Errorf("expected 12 after compaction, got %d", n)
+	}
+}
+
+func CommonTestKVStore(t *testing.T, s store.KVStore) {
+
+	writer, err := s.Writer()
+	if err != nil {
+		t.Error(err)
+	}
+	err = writer.Set([]byte("a"), []byte("val-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Set([]byte("z"), []byte("val-z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Delete([]byte("z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := writer.NewBatch()
+	batch.Set([]byte("b"), []byte("val-b"))
+	batch.Set([]byte("c"), []byte("val-c"))
+	batch.Set([]byte("d"), []byte("val-d"))
+	batch.Set([]byte("e"), []byte("val-e"))
+	batch.Set([]byte("f"), []byte("val-f"))
+	batch.Set([]byte("g"), []byte("val-g"))
+	batch.Set([]byte("h"), []byte("val-h"))
+	batch.Set([]byte("i"), []byte("val-i"))
+	batch.Set([]byte("j"), []byte("val-j"))
+
+	err = batch.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Error(err)
+	}
+	defer func() {
+		err := reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	it := reader.Iterator([]byte("b"))
+	key, val, valid := it.Current()
+	if !valid {
+		t.Fatalf("valid false, expected true")
+	}
+	if string(key) != "b" {
+		t.Fatalf("expected key b, got %s", key)
+	}
+	if string(val) != "val-b" {
+		t.Fatalf("expected value val-b, got %s", val)
+	}
+
+	it.Next()
+	key, val, valid = it.Current()
+	if !valid {
+		t.Fatalf("valid false, expected true")
+	}
+	if string(key) != "c" {
+		t.Fatalf("expected key c, got %s", key)
+	}
+	if string(val) != "val-c" {
+		t.Fatalf("expected value val-c, got %s", val)
+	}
+
+	it.Seek([]byte("i"))
+	key, val, valid = it.Current()
+	if !valid {
+		t.Fatalf("valid false, expected true")
+	}
+	if string(key) != "i" {
+		t.Fatalf("expected key i, got %s", key)
+	}
+	if string(val) != "val-i" {
+		t.Fatalf("expected value val-i, got %s", val)
+	}
+
+	err = it.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func CommonTestReaderIsolation(t *testing.T, s store.KVStore) {
+	// insert a kv pair
+	writer, err := s.Writer()
+	if err != nil {
+		t.Error(err)
+	}
+	err = writer.Set([]byte("a"), []byte("val-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create an isolated reader
+	reader, err := s.Reader()
+	if err != nil {
+		t.Error(err)
+	}
+	defer func() {
+		err := reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// verify that we see the value already inserted
+	val, err := reader.Get([]byte("a"))
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(val, []byte("val-a")) {
+		t.Errorf("expected val-a, got nil")
+	}
+
+	// verify that an iterator sees it
+	count := 0
+	it := reader.Iterator([]byte{0})
+	defer func() {
+		err := it.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for it.Valid() {
+		it.Next()
+		count++
+	}
+	if count != 1 {
+		t.Errorf("expected iterator to see 1, saw %d", count)
+	}
+
+	// add something after the reader was created
+	writer, err = s.Writer()
+	if err != nil {
+		t.Error(err)
+	}
+	err = writer.Set([]byte("b"), []byte("val-b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ensure that a newer reader sees it
+	newReader, err := s.Reader()
+	if err != nil {
+		t.Error(err)
+	}
+	defer func() {
+		err := newReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	val, err = newReader.Get([]byte("b"))
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(val, []byte("val-b")) {
+		t.Errorf("expected val-b, got nil")
+	}
+
+	// ensure that the director iterator sees it
+	count = 0
+	it = newReader.Iterator([]byte{0})
+	defer func() {
+		err := it.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for it.Valid() {
+		it.Next()
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected iterator to see 2, saw %d", count)
+	}
+
+	// but that the isolated reader does not
+	val, err = reader.Get([]byte("b"))
+	if err != nil {
+		t.Error(err)
+	}
+	if val != nil {
+		t.Errorf("expected nil, got %v", val)
+	}
+
+	// and ensure that the iterator on the isolated reader also does not
+	count = 0
+	it = reader.Iterator([]byte{0})
+	defer func() {
+		err := it.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for it.Valid() {
+		it.Next()
+		count++
+	}
+	if count != 1 {
+		t.Errorf("expected iterator to see 1, saw %d", count)
+	}
+
+}
+
+func CommonTestKVStoreIterator(t *testing.T, s store.KVStore) {
+
+	writer, err := s.Writer()
+	if err != nil {
+		t.Error(err)
+	}
+
+	data := []struct {
+		k []byte
+		v []byte
+	}{
+		{[]byte("t\x09\x00paint\xff/sponsor/gold/thumbtack/"), []byte("a")},
+		{[]byte("t\x09\x00party\xff/sponsor/gold/thumbtack/"), []byte("a")},
+		{[]byte("t\x09\x00personal\xff/sponsor/gold/thumbtack/"), []byte("a")},
+		{[]byte("t\x09\x00plan\xff/sponsor/gold/thumbtack/"), []byte("a")},
+	}
+
+	batch := writer.NewBatch()
+	for _, d := range data {
+		batch.Set(d.k, d.v)
+	}
+
+	err = batch.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := s.Reader()
+	if err != nil {
+		t.Error(err)
+	}
+	defer func() {
+		err := reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	it := reader.Iterator([]byte("a"))
+	keys := make([][]byte, 0, len(data))
+	key, _, valid := it.Current()
+	for valid {
+		keys = append(keys, key)
+		it.Next()
+		key, _, valid = it.Current()
+	}
+
+	if len(keys) != len(data) {
+		t.Errorf("expected same number of keys, got %d != %d", len(keys), len(data))
+	}
+	for i, dk := range data {
+		if !reflect.DeepEqual(dk.k, keys[i]) {
+			t.Errorf("expected key %s got %s", dk.k, keys[i])
+		}
+
+	}
+
+	err = it.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/blevesearch/bleve/index/store"
+)
+
+// Counter is a merge operator adding up the operands as
+// little endian 64 bit counts, see CommonTestMergeOperator
+type Counter struct{}
+
+func (c *Counter) FullMerge(key, existingValue []byte, operands [][]byte) ([]byte, bool) {
+	var count uint64
+	if existingValue != nil {
+		count = binary.LittleEndian.Uint64(existingValue)
+	}
+	for _, operand := range operands {
+		count += binary.LittleEndian.Uint64(operand)
+	}
+	rv := make([]byte, 8)
+	binary.LittleEndian.PutUint64(rv, count)
+	return rv, true
+}
+
+func (c *Counter) PartialMerge(key, leftOperand, rightOperand []byte) ([]byte, bool) {
+	return c.FullMerge(key, leftOperand, [][]byte{rightOperand})
+}
+
+func (c *Counter) Name() string {
+	return "counter"
+}
+
+// CommonTestMergeOperator checks the merges of a store
+// opened with a Counter as its merge operator, before and
+// after compacting stores which can be
+func CommonTestMergeOperator(t *testing.T, s store.KVStore) {
+	one := make([]byte, 8)
+	binary.LittleEndian.PutUint64(one, 1)
+	ten := make([]byte, 8)
+	binary.LittleEndian.PutUint64(ten, 10)
+	merge := func(key string, n int) {
+		writer, err := s.Writer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := writer.NewBatch()
+		for i := 0; i < n; i++ {
+			batch.Merge([]byte(key), one)
+		}
+		err = batch.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = batch.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(key string) uint64 {
+		reader, err := s.Reader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := reader.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		val, err := reader.Get([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(val) != 8 {
+			t.Fatalf("expected count of %s, got %v", key, val)
+		}
+		return binary.LittleEndian.Uint64(val)
+	}
+
+	merge("a", 3)
+	merge("a", 2)
+	if n := count("a"); n != 5 {
+		t.Errorf("expected 5, got %d", n)
+	}
+
+	writer, err := s.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Set([]byte("b"), ten)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	merge("b", 2)
+	if n := count("b"); n != 12 {
+		t.Errorf("expected 12, got %d", n)
+	}
+
+	if compactor, ok := s.(store.KVCompactor); ok {
+		err = compactor.Compact()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := count("a"); n != 5 {
+		t.Errorf("expected 5 after compaction, got %d", n)
+	}
+	if n := count("b"); n != 12 {
+		t.Errorf("expected 12 after compaction, got %d", n)
+	}
+}
@@ -24,6 +24,10 @@ func (udc *UpsideDownCouch) Analyze(d *document.Document) *index.AnalysisResult
 	backIndexTermEntries := make([]*BackIndexTermEntry, 0)
 	backIndexStoredEntries := make([]*BackIndexStoreEntry, 0)
 
+	// track the doc values of each field, a field may occur
+	// more than once in the document
+	docValues := make(map[uint16]map[string]bool)
+
 	for _, field := range d.Fields {
 		fieldIndex, newFieldRow := udc.fieldIndexOrNewRow(field.Name())
 		if newFieldRow != nil {
@@ -43,6 +47,17 @@ func (udc *UpsideDownCouch) Analyze(d *document.Document) *index.AnalysisResult
 			indexRows, indexBackIndexTermEntries := udc.indexField(d.ID, field, fieldIndex, fieldLength, tokenFreqs)
 			rv.Rows = append(rv.Rows, indexRows...)
 			backIndexTermEntries = append(backIndexTermEntries, indexBackIndexTermEntries...)
+
+			if field.Options().IncludeDocValues() {
+				terms, ok := docValues[fieldIndex]
+				if !ok {
+					terms = make(map[string]bool)
+					docValues[fieldIndex] = terms
+				}
+				for _, tf := range tokenFreqs {
+					terms[string(tf.Term)] = true
+				}
+			}
 		}
 
 		if field.Options().IsStored() {
@@ -68,6 +83,10 @@ func (udc *UpsideDownCouch) Analyze(d *document.Document) *index.AnalysisResult
 		}
 	}
 
+	for fieldIndex, terms := range docValues {
+		rv.Rows = append(rv.Rows, NewDocValueRow(fieldIndex, d.ID, docValueTerms(terms)))
+	}
+
 	// build the back index row
 	backIndexRow := NewBackIndexRow(d.ID, backIndexTermEntries, backIndexStoredEntries)
 	rv.Rows = append(rv.Rows, backIndexRow)
@@ -0,0 +1,82 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build badger
+
+package upside_down
+
+import (
+	"os"
+	"testing"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/index/store/badger"
+)
+
+var badgerTestOptions = map[string]interface{}{
+	"sync_writes": false,
+}
+
+func CreateBadger() (store.KVStore, error) {
+	return badger.New("test", badgerTestOptions)
+}
+
+func DestroyBadger() error {
+	return os.RemoveAll("test")
+}
+
+func BenchmarkBadgerIndexing1Workers(b *testing.B) {
+	CommonBenchmarkIndex(b, CreateBadger, DestroyBadger, 1)
+}
+
+func BenchmarkBadgerIndexing2Workers(b *testing.B) {
+	CommonBenchmarkIndex(b, CreateBadger, DestroyBadger, 2)
+}
+
+func BenchmarkBadgerIndexing4Workers(b *testing.B) {
+	CommonBenchmarkIndex(b, CreateBadger, DestroyBadger, 4)
+}
+
+// batches
+
+func BenchmarkBadgerIndexing1Workers10Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreateBadger, DestroyBadger, 1, 10)
+}
+
+func BenchmarkBadgerIndexing2Workers10Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreateBadger, DestroyBadger, 2, 10)
+}
+
+func BenchmarkBadgerIndexing4Workers10Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreateBadger, DestroyBadger, 4, 10)
+}
+
+func BenchmarkBadgerIndexing1Workers100Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreateBadger, DestroyBadger, 1, 100)
+}
+
+func BenchmarkBadgerIndexing2Workers100Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreateBadger, DestroyBadger, 2, 100)
+}
+
+func BenchmarkBadgerIndexing4Workers100Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreateBadger, DestroyBadger, 4, 100)
+}
+
+func BenchmarkBadgerIndexing1Workers1000Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreateBadger, DestroyBadger, 1, 1000)
+}
+
+func BenchmarkBadgerIndexing2Workers1000Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreateBadger, DestroyBadger, 2, 1000)
+}
+
+func BenchmarkBadgerIndexing4Workers1000Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreateBadger, DestroyBadger, 4, 1000)
+}
@@ -16,7 +16,7 @@ import (
 	"testing"
 
 	"github.com/blevesearch/bleve/index/store"
-	"github.com/blevesearch/blevex/rocksdb"
+	"github.com/blevesearch/bleve/index/store/rocksdb"
 )
 
 var rocksdbTestOptions = map[string]interface{}{
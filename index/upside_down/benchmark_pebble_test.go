@@ -0,0 +1,82 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build pebble
+
+package upside_down
+
+import (
+	"os"
+	"testing"
+
+	"github.com/blevesearch/bleve/index/store"
+	"github.com/blevesearch/bleve/index/store/pebble"
+)
+
+var pebbleTestOptions = map[string]interface{}{
+	"create_if_missing": true,
+}
+
+func CreatePebble() (store.KVStore, error) {
+	return pebble.New("test", pebbleTestOptions)
+}
+
+func DestroyPebble() error {
+	return os.RemoveAll("test")
+}
+
+func BenchmarkPebbleIndexing1Workers(b *testing.B) {
+	CommonBenchmarkIndex(b, CreatePebble, DestroyPebble, 1)
+}
+
+func BenchmarkPebbleIndexing2Workers(b *testing.B) {
+	CommonBenchmarkIndex(b, CreatePebble, DestroyPebble, 2)
+}
+
+func BenchmarkPebbleIndexing4Workers(b *testing.B) {
+	CommonBenchmarkIndex(b, CreatePebble, DestroyPebble, 4)
+}
+
+// batches
+
+func BenchmarkPebbleIndexing1Workers10Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreatePebble, DestroyPebble, 1, 10)
+}
+
+func BenchmarkPebbleIndexing2Workers10Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreatePebble, DestroyPebble, 2, 10)
+}
+
+func BenchmarkPebbleIndexing4Workers10Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreatePebble, DestroyPebble, 4, 10)
+}
+
+func BenchmarkPebbleIndexing1Workers100Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreatePebble, DestroyPebble, 1, 100)
+}
+
+func BenchmarkPebbleIndexing2Workers100Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreatePebble, DestroyPebble, 2, 100)
+}
+
+func BenchmarkPebbleIndexing4Workers100Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreatePebble, DestroyPebble, 4, 100)
+}
+
+func BenchmarkPebbleIndexing1Workers1000Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreatePebble, DestroyPebble, 1, 1000)
+}
+
+func BenchmarkPebbleIndexing2Workers1000Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreatePebble, DestroyPebble, 2, 1000)
+}
+
+func BenchmarkPebbleIndexing4Workers1000Batch(b *testing.B) {
+	CommonBenchmarkIndexBatch(b, CreatePebble, DestroyPebble, 4, 1000)
+}
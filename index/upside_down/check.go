@@ -0,0 +1,291 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package upside_down
+
+import (
+	"bytes"
+
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/store"
+)
+
+// checker keeps the state of a scan of all the rows, which
+// come in key order: back index, doc value, dictionary,
+// field, internal, stored, term frequency and version rows
+type checker struct {
+	kvreader store.KVReader
+	batch    store.KVBatch
+	result   *index.CheckResult
+
+	docs   uint64
+	fields map[uint16]bool
+	// the counts of the dictionary rows, and of the term
+	// frequency rows of each dictionary row key
+	dictCounts map[string]uint64
+	termCounts map[string]uint64
+
+	// the keys listed by the back index of the last
+	// document looked up, nil when it has none or when it
+	// cannot be decoded
+	backIndexDoc     []byte
+	backIndexKeys    map[string]bool
+	backIndexCorrupt bool
+}
+
+// Check scans all rows of the index.  Rows which cannot be
+// decoded are reported, they are not repaired as that
+// would lose parts of documents.  Term frequency, stored
+// and doc value rows of documents which do not list them
+// in their back index are deleted, as are the entries of
+// back index rows for rows which are missing, dictionary
+// counts are set to the number of term frequency rows.
+// With repair the index is not changed by others while it
+// is checked.
+func (udc *UpsideDownCouch) Check(repair bool) (rv *index.CheckResult, err error) {
+	c := &checker{
+		result:     &index.CheckResult{},
+		fields:     make(map[uint16]bool),
+		dictCounts: make(map[string]uint64),
+		termCounts: make(map[string]uint64),
+	}
+	var kvwriter store.KVWriter
+	if repair {
+		kvwriter, err = udc.store.Writer()
+		c.kvreader = kvwriter
+	} else {
+		c.kvreader, err = udc.store.Reader()
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := c.kvreader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	if kvwriter != nil {
+		c.batch = kvwriter.NewBatch()
+		defer func() {
+			if cerr := c.batch.Close(); err == nil && cerr != nil {
+				err = cerr
+			}
+		}()
+	}
+
+	err = c.scan()
+	if err != nil {
+		return nil, err
+	}
+	c.checkDictionary()
+	if c.batch != nil {
+		err = c.batch.Execute()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	udc.m.Lock()
+	if udc.docCount != c.docs {
+		c.result.Add(index.ProblemCount, repair, "doc count is %d, found %d documents", udc.docCount, c.docs)
+		if repair {
+			udc.docCount = c.docs
+		}
+	}
+	udc.m.Unlock()
+	return c.result, nil
+}
+
+func (c *checker) repair() bool {
+	return c.batch != nil
+}
+
+func (c *checker) scan() (err error) {
+	it := c.kvreader.Iterator([]byte{})
+	defer func() {
+		if cerr := it.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	key, val, valid := it.Current()
+	for valid {
+		row, perr := ParseFromKeyValue(key, val)
+		if perr != nil {
+			c.result.Add(index.ProblemCorrupt, false, "row %q cannot be decoded: %v", key, perr)
+		} else {
+			switch row := row.(type) {
+			case *BackIndexRow:
+				err = c.checkBackIndex(row)
+			case *DocValueRow:
+				err = c.checkDocValue(row)
+			case *DictionaryRow:
+				c.dictCounts[string(row.Key())] = row.count
+			case *FieldRow:
+				c.fields[row.index] = true
+			case *StoredRow:
+				if !c.fields[row.field] {
+					c.result.Add(index.ProblemMissing, false, "stored row %q has unknown field %d", key, row.field)
+				}
+				_, err = c.checkListed(row.doc, row.Key(), "stored")
+			case *TermFrequencyRow:
+				if !c.fields[row.field] {
+					c.result.Add(index.ProblemMissing, false, "term frequency row %q has unknown field %d", key, row.field)
+				}
+				var listed bool
+				listed, err = c.checkListed(row.doc, row.Key(), "term frequency")
+				if listed {
+					c.termCounts[string(row.DictionaryRowKey())]++
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+		it.Next()
+		key, val, valid = it.Current()
+	}
+	return
+}
+
+// checkBackIndex looks up the rows the back index lists,
+// the entries of missing ones are dropped on repair
+func (c *checker) checkBackIndex(row *BackIndexRow) error {
+	c.docs++
+	termEntries := make([]*BackIndexTermEntry, 0, len(row.termEntries))
+	for i, key := range row.AllTermKeys() {
+		val, err := c.kvreader.Get(key)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			c.result.Add(index.ProblemMissing, c.repair(), "term frequency row %q of document %q is missing", key, row.doc)
+			continue
+		}
+		termEntries = append(termEntries, row.termEntries[i])
+	}
+	storedEntries := make([]*BackIndexStoreEntry, 0, len(row.storedEntries))
+	for i, key := range row.AllStoredKeys() {
+		val, err := c.kvreader.Get(key)
+		if err != nil {
+			return err
+		}
+		if val == nil {
+			c.result.Add(index.ProblemMissing, c.repair(), "stored row %q of document %q is missing", key, row.doc)
+			continue
+		}
+		storedEntries = append(storedEntries, row.storedEntries[i])
+	}
+	if c.repair() && (len(termEntries) < len(row.termEntries) || len(storedEntries) < len(row.storedEntries)) {
+		repaired := NewBackIndexRow(string(row.doc), termEntries, storedEntries)
+		c.batch.Set(repaired.Key(), repaired.Value())
+	}
+	return nil
+}
+
+// checkListed tells whether the back index of doc lists
+// the row with key, the row is deleted on repair when it
+// does not
+func (c *checker) checkListed(doc, key []byte, kind string) (bool, error) {
+	err := c.lookupBackIndex(doc)
+	if err != nil {
+		return false, err
+	}
+	if c.backIndexCorrupt || c.backIndexKeys[string(key)] {
+		return true, nil
+	}
+	c.result.Add(index.ProblemDangling, c.repair(), "%s row %q is not in the back index of document %q", kind, key, doc)
+	if c.repair() {
+		c.batch.Delete(key)
+	}
+	return false, nil
+}
+
+// checkDocValue deletes a doc value row on repair when its
+// document does not exist, back indexes do not list them
+func (c *checker) checkDocValue(row *DocValueRow) error {
+	err := c.lookupBackIndex(row.doc)
+	if err != nil || c.backIndexCorrupt || c.backIndexKeys != nil {
+		return err
+	}
+	c.result.Add(index.ProblemDangling, c.repair(), "doc value row %q belongs to no document", row.Key())
+	if c.repair() {
+		c.batch.Delete(row.Key())
+	}
+	return nil
+}
+
+// lookupBackIndex loads the keys listed by the back index
+// of doc, the last one is kept as rows of the same
+// document mostly come together
+func (c *checker) lookupBackIndex(doc []byte) error {
+	if c.backIndexDoc != nil && bytes.Equal(doc, c.backIndexDoc) {
+		return nil
+	}
+	c.backIndexDoc = append(c.backIndexDoc[:0], doc...)
+	c.backIndexKeys = nil
+	c.backIndexCorrupt = false
+	key := NewBackIndexRow(string(doc), nil, nil).Key()
+	val, err := c.kvreader.Get(key)
+	if err != nil || val == nil {
+		return err
+	}
+	backIndex, err := NewBackIndexRowKV(key, val)
+	if err != nil {
+		// reported when the scan reaches it, the rows of the
+		// document are left alone
+		c.backIndexCorrupt = true
+		return nil
+	}
+	c.backIndexKeys = make(map[string]bool)
+	for _, k := range backIndex.AllTermKeys() {
+		c.backIndexKeys[string(k)] = true
+	}
+	for _, k := range backIndex.AllStoredKeys() {
+		c.backIndexKeys[string(k)] = true
+	}
+	return nil
+}
+
+// checkDictionary compares the dictionary counts with the
+// term frequency rows found
+func (c *checker) checkDictionary() {
+	for key, count := range c.termCounts {
+		dictCount, ok := c.dictCounts[key]
+		if !ok {
+			c.result.Add(index.ProblemMissing, c.repair(), "dictionary row %q is missing", key)
+		} else if dictCount != count {
+			c.result.Add(index.ProblemCount, c.repair(), "dictionary row %q counts %d, found %d", key, dictCount, count)
+		} else {
+			continue
+		}
+		if c.repair() {
+			c.setDictionaryCount(key, count)
+		}
+	}
+	for key, dictCount := range c.dictCounts {
+		if _, ok := c.termCounts[key]; ok || dictCount == 0 {
+			// deleted terms are kept with a count of 0
+			continue
+		}
+		c.result.Add(index.ProblemDangling, c.repair(), "dictionary row %q counts %d, found no term frequency rows", key, dictCount)
+		if c.repair() {
+			c.setDictionaryCount(key, 0)
+		}
+	}
+}
+
+func (c *checker) setDictionaryCount(key string, count uint64) {
+	row, err := NewDictionaryRowK([]byte(key))
+	if err != nil {
+		return
+	}
+	row.count = count
+	c.batch.Set(row.Key(), row.Value())
+}
@@ -0,0 +1,143 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package upside_down
+
+import (
+	"os"
+	"testing"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/store/boltdb"
+)
+
+func countProblems(result *index.CheckResult) map[string]int {
+	rv := make(map[string]int)
+	for _, problem := range result.Problems {
+		rv[problem.Kind]++
+	}
+	return rv
+}
+
+func TestCheck(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	store := boltdb.New("test", "bleve")
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := NewUpsideDownCouch(store, analysisQueue)
+	err := idx.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for _, id := range []string{"a", "b"} {
+		doc := document.NewDocument(id)
+		doc.AddField(document.NewTextFieldWithAnalyzer("name", []uint64{}, []byte("test "+id), testAnalyzer))
+		err = idx.Update(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := idx.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Problems) != 0 {
+		t.Fatalf("expected no problems, got %v", result.Problems)
+	}
+
+	// break the index behind its back
+	field, _ := idx.fieldCache.FieldNamed("name", false)
+	kvwriter, err := store.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a term of a which is in its back index
+	err = kvwriter.Delete(NewTermFrequencyRow([]byte("a"), field, "a", 0, 0).Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rows of a document which does not exist
+	err = kvwriter.Set(NewTermFrequencyRow([]byte("test"), field, "x", 1, 1).Key(), NewTermFrequencyRow([]byte("test"), field, "x", 1, 1).Value())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := NewStoredRow("x", field, []uint64{}, 't', []byte("test"))
+	err = kvwriter.Set(stored.Key(), stored.Value())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a wrong count
+	dict := NewDictionaryRow([]byte("test"), field, 5)
+	err = kvwriter.Set(dict.Key(), dict.Value())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a row which cannot be decoded
+	err = kvwriter.Set([]byte("bz"), []byte{0xff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = kvwriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = idx.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the missing term frequency row, the term frequency
+	// and stored rows of x along with the dictionary row of
+	// the missing term, the count of test and the corrupt
+	// row
+	expected := map[string]int{
+		index.ProblemMissing:  1,
+		index.ProblemDangling: 3,
+		index.ProblemCount:    1,
+		index.ProblemCorrupt:  1,
+	}
+	problems := countProblems(result)
+	for kind, count := range expected {
+		if problems[kind] != count {
+			t.Errorf("expected %d %s problems, got %d", count, kind, problems[kind])
+		}
+	}
+	if result.Unrepaired() != len(result.Problems) {
+		t.Errorf("expected nothing to be repaired")
+	}
+
+	result, err = idx.Check(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Problems) != 6 || result.Unrepaired() != 1 {
+		t.Errorf("expected all but the corrupt row to be repaired, got %v", result.Problems)
+	}
+
+	result, err = idx.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Problems) != 1 || result.Problems[0].Kind != index.ProblemCorrupt {
+		t.Errorf("expected only the corrupt row to be left, got %v", result.Problems)
+	}
+}
@@ -147,6 +147,23 @@ func (udc *UpsideDownCouch) DumpDoc(id string) chan interface{} {
 		storedRowPrefix := NewStoredRow(id, 0, []uint64{}, 'x', []byte{}).ScanPrefixForDoc()
 		udc.dumpPrefix(kvreader, rv, storedRowPrefix)
 
+		// then the doc values
+		for _, key := range back.AllDocValueKeys() {
+			val, err := kvreader.Get(key)
+			if err != nil {
+				rv <- err
+				return
+			}
+			if val != nil {
+				row, err := NewDocValueRowKV(key, val)
+				if err != nil {
+					rv <- err
+					return
+				}
+				rv <- row
+			}
+		}
+
 		// now walk term keys in order and add them as well
 		if len(keys) > 0 {
 			it := kvreader.Iterator(keys[0])
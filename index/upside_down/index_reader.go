@@ -11,6 +11,7 @@ package upside_down
 
 import (
 	"bytes"
+	"sort"
 
 	"github.com/blevesearch/bleve/document"
 	"github.com/blevesearch/bleve/index"
@@ -118,6 +119,55 @@ func (i *IndexReader) DocumentFieldTerms(id string) (index.FieldTerms, error) {
 	return rv, nil
 }
 
+func (i *IndexReader) DocumentTermVectors(id string, fields []string) (index.FieldTermVectors, error) {
+	back, err := i.index.backIndexRowForDoc(i.kvreader, id)
+	if err != nil {
+		return nil, err
+	}
+	rv := make(index.FieldTermVectors)
+	if back == nil {
+		return rv, nil
+	}
+	var wanted map[string]bool
+	if fields != nil {
+		wanted = make(map[string]bool, len(fields))
+		for _, field := range fields {
+			wanted[field] = true
+		}
+	}
+	for _, entry := range back.termEntries {
+		fieldName := i.index.fieldCache.FieldIndexed(uint16(*entry.Field))
+		if wanted != nil && !wanted[fieldName] {
+			continue
+		}
+		key := NewTermFrequencyRow([]byte(*entry.Term), uint16(*entry.Field), id, 0, 0).Key()
+		val, err := i.kvreader.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if val == nil {
+			continue
+		}
+		row, err := NewTermFrequencyRowKV(key, val)
+		if err != nil {
+			return nil, err
+		}
+		rv[fieldName] = append(rv[fieldName], &index.TermVector{
+			Term:    *entry.Term,
+			Freq:    row.freq,
+			Vectors: i.index.termFieldVectorsFromTermVectors(row.vectors),
+		})
+	}
+	for _, vectors := range rv {
+		sort.Sort(index.TermVectorsByTerm(vectors))
+	}
+	return rv, nil
+}
+
+func (i *IndexReader) DocValueReader(fields []string) (index.DocValueReader, error) {
+	return newUpsideDownCouchDocValueReader(i, fields), nil
+}
+
 func (i *IndexReader) Fields() (fields []string, err error) {
 	fields = make([]string, 0)
 	it := i.kvreader.Iterator([]byte{'f'})
@@ -194,3 +194,61 @@ func (r *UpsideDownCouchDocIDReader) Advance(docID string) (string, error) {
 func (r *UpsideDownCouchDocIDReader) Close() error {
 	return r.iterator.Close()
 }
+
+type UpsideDownCouchDocValueReader struct {
+	indexReader  *IndexReader
+	fields       []string
+	fieldIndexes []uint16
+}
+
+func newUpsideDownCouchDocValueReader(indexReader *IndexReader, fields []string) *UpsideDownCouchDocValueReader {
+	rv := UpsideDownCouchDocValueReader{
+		indexReader: indexReader,
+	}
+	for _, field := range fields {
+		fieldIndex, fieldExists := indexReader.index.fieldCache.FieldNamed(field, false)
+		if fieldExists {
+			rv.fields = append(rv.fields, field)
+			rv.fieldIndexes = append(rv.fieldIndexes, fieldIndex)
+		}
+	}
+	return &rv
+}
+
+func (r *UpsideDownCouchDocValueReader) VisitDocValues(id string, visitor index.DocValueVisitor) error {
+	var backIndexRow *BackIndexRow
+	for i, field := range r.fields {
+		key := NewDocValueRow(r.fieldIndexes[i], id, nil).Key()
+		val, err := r.indexReader.kvreader.Get(key)
+		if err != nil {
+			return err
+		}
+		if val != nil {
+			dvr, err := NewDocValueRowKV(key, val)
+			if err != nil {
+				return err
+			}
+			for _, term := range dvr.terms {
+				visitor(field, term)
+			}
+			continue
+		}
+
+		// fields without doc values use the back index
+		if backIndexRow == nil {
+			backIndexRow, err = r.indexReader.index.backIndexRowForDoc(r.indexReader.kvreader, id)
+			if err != nil {
+				return err
+			}
+			if backIndexRow == nil {
+				return nil
+			}
+		}
+		for _, entry := range backIndexRow.termEntries {
+			if uint16(entry.GetField()) == r.fieldIndexes[i] {
+				visitor(field, []byte(entry.GetTerm()))
+			}
+		}
+	}
+	return nil
+}
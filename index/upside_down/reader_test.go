@@ -296,6 +296,82 @@ func TestIndexDocIdReader(t *testing.T) {
 	}
 }
 
+func TestIndexDocValueReader(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	s := boltdb.New("test", "bleve")
+	s.SetMergeOperator(&mergeOperator)
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := NewUpsideDownCouch(s, analysisQueue)
+	err := idx.Open()
+	if err != nil {
+		t.Errorf("error opening index: %v", err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	doc := document.NewDocument("1")
+	doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{0}, []byte("test"), document.IndexField|document.IncludeDocValues))
+	doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{1}, []byte("rice"), document.IndexField|document.IncludeDocValues))
+	doc.AddField(document.NewTextField("desc", []uint64{}, []byte("eat")))
+	err = idx.Update(doc)
+	if err != nil {
+		t.Errorf("Error updating index: %v", err)
+	}
+
+	indexReader, err := idx.Reader()
+	if err != nil {
+		t.Error(err)
+	}
+	defer func() {
+		err := indexReader.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	reader, err := indexReader.DocValueReader([]string{"name", "desc", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// name comes from the doc value row, desc from the back index
+	expected := index.FieldTerms{
+		"name": []string{"rice", "test"},
+		"desc": []string{"eat"},
+	}
+	fieldTerms := make(index.FieldTerms)
+	visitor := func(field string, term []byte) {
+		fieldTerms[field] = append(fieldTerms[field], string(term))
+	}
+	err = reader.VisitDocValues("1", visitor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fieldTerms, expected) {
+		t.Errorf("expected %v, got %v", expected, fieldTerms)
+	}
+
+	// missing documents have no doc values
+	fieldTerms = make(index.FieldTerms)
+	err = reader.VisitDocValues("2", visitor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fieldTerms) != 0 {
+		t.Errorf("expected no doc values, got %v", fieldTerms)
+	}
+}
+
 func TestCrashBadBackIndexRow(t *testing.T) {
 	br, err := NewBackIndexRowKV([]byte{byte('b'), byte('a'), ByteSeparator}, []byte{})
 	if err != nil {
@@ -45,6 +45,8 @@ func ParseFromKeyValue(key, value []byte) (UpsideDownCouchRow, error) {
 			return NewStoredRowKV(key, value)
 		case 'i':
 			return NewInternalRowKV(key, value)
+		case 'c':
+			return NewDocValueRowKV(key, value)
 		}
 		return nil, fmt.Errorf("Unknown field type '%s'", string(key[0]))
 	}
@@ -264,10 +266,11 @@ type TermVector struct {
 	pos            uint64
 	start          uint64
 	end            uint64
+	payload        []byte
 }
 
 func (tv *TermVector) String() string {
-	return fmt.Sprintf("Field: %d Pos: %d Start: %d End %d ArrayPositions: %#v", tv.field, tv.pos, tv.start, tv.end, tv.arrayPositions)
+	return fmt.Sprintf("Field: %d Pos: %d Start: %d End %d ArrayPositions: %#v Payload: %v", tv.field, tv.pos, tv.start, tv.end, tv.arrayPositions, tv.payload)
 }
 
 type TermFrequencyRow struct {
@@ -323,6 +326,7 @@ func (tfr *TermFrequencyRow) Value() []byte {
 	bufLen := binary.MaxVarintLen64 + binary.MaxVarintLen64
 	for _, vector := range tfr.vectors {
 		bufLen += (binary.MaxVarintLen64 * 4) + (1+len(vector.arrayPositions))*binary.MaxVarintLen64
+		bufLen += binary.MaxVarintLen64 + len(vector.payload)
 	}
 	buf := make([]byte, bufLen)
 
@@ -341,6 +345,8 @@ func (tfr *TermFrequencyRow) Value() []byte {
 		for _, arrayPosition := range vector.arrayPositions {
 			used += binary.PutUvarint(buf[used:used+binary.MaxVarintLen64], arrayPosition)
 		}
+		used += binary.PutUvarint(buf[used:used+binary.MaxVarintLen64], uint64(len(vector.payload)))
+		used += copy(buf[used:], vector.payload)
 	}
 	return buf[0:used]
 }
@@ -394,6 +400,13 @@ func NewTermFrequencyRowK(key []byte) (*TermFrequencyRow, error) {
 }
 
 func (tfr *TermFrequencyRow) parseV(value []byte) error {
+	return tfr.parseVersionedV(value, Version)
+}
+
+// parseVersionedV parses the value as encoded by the given
+// version of the index, term vectors have payloads since
+// version 6
+func (tfr *TermFrequencyRow) parseVersionedV(value []byte, version uint8) error {
 	currOffset := 0
 	bytesRead := 0
 	tfr.freq, bytesRead = binary.Uvarint(value[currOffset:])
@@ -458,6 +471,24 @@ func (tfr *TermFrequencyRow) parseV(value []byte) error {
 			}
 		}
 
+		var payloadLen uint64
+		if version >= 6 {
+			payloadLen, bytesRead = binary.Uvarint(value[currOffset:])
+			if bytesRead <= 0 {
+				return fmt.Errorf("invalid term frequency value, vector contains no payload length")
+			}
+			currOffset += bytesRead
+		}
+
+		if payloadLen > 0 {
+			if uint64(len(value[currOffset:])) < payloadLen {
+				return fmt.Errorf("invalid term frequency value, vector payload too short")
+			}
+			tv.payload = make([]byte, payloadLen)
+			copy(tv.payload, value[currOffset:currOffset+int(payloadLen)])
+			currOffset += int(payloadLen)
+		}
+
 		tfr.vectors = append(tfr.vectors, &tv)
 		// try to read next record (may not exist)
 		field, bytesRead = binary.Uvarint(value[currOffset:])
@@ -513,6 +544,24 @@ func (br *BackIndexRow) AllStoredKeys() [][]byte {
 	return rv
 }
 
+// AllDocValueKeys returns the keys of the doc value rows
+// the document may have, one for every field it has terms in
+func (br *BackIndexRow) AllDocValueKeys() [][]byte {
+	if br == nil {
+		return nil
+	}
+	rv := make([][]byte, 0)
+	seen := make(map[uint32]bool)
+	for _, termEntry := range br.termEntries {
+		if !seen[termEntry.GetField()] {
+			seen[termEntry.GetField()] = true
+			docValueRow := NewDocValueRow(uint16(termEntry.GetField()), string(br.doc), nil)
+			rv = append(rv, docValueRow.Key())
+		}
+	}
+	return rv
+}
+
 func (br *BackIndexRow) Key() []byte {
 	buf := make([]byte, len(br.doc)+1)
 	buf[0] = 'b'
@@ -666,3 +715,76 @@ func NewStoredRowKV(key, value []byte) (*StoredRow, error) {
 	rv.value = value[1:]
 	return rv, nil
 }
+
+// DOC VALUES
+
+// A DocValueRow holds the terms of one field of a
+// document, the rows of a field are kept together so
+// that reading a field does not touch the other rows
+// of the documents
+type DocValueRow struct {
+	field uint16
+	doc   []byte
+	terms [][]byte
+}
+
+func (dv *DocValueRow) Key() []byte {
+	buf := make([]byte, 1+2+len(dv.doc))
+	buf[0] = 'c'
+	binary.LittleEndian.PutUint16(buf[1:3], dv.field)
+	copy(buf[3:], dv.doc)
+	return buf
+}
+
+func (dv *DocValueRow) Value() []byte {
+	size := 0
+	for _, term := range dv.terms {
+		size += binary.MaxVarintLen64 + len(term)
+	}
+	buf := make([]byte, size)
+	used := 0
+	for _, term := range dv.terms {
+		used += binary.PutUvarint(buf[used:], uint64(len(term)))
+		used += copy(buf[used:], term)
+	}
+	return buf[0:used]
+}
+
+func (dv *DocValueRow) String() string {
+	return fmt.Sprintf("Field: %d Document: %s Doc Values: %q", dv.field, dv.doc, dv.terms)
+}
+
+func NewDocValueRow(field uint16, doc string, terms [][]byte) *DocValueRow {
+	return &DocValueRow{
+		field: field,
+		doc:   []byte(doc),
+		terms: terms,
+	}
+}
+
+func NewDocValueRowK(key []byte) (*DocValueRow, error) {
+	if len(key) < 4 {
+		return nil, fmt.Errorf("invalid doc value key length %d", len(key))
+	}
+	return &DocValueRow{
+		field: binary.LittleEndian.Uint16(key[1:3]),
+		doc:   key[3:],
+	}, nil
+}
+
+func NewDocValueRowKV(key, value []byte) (*DocValueRow, error) {
+	rv, err := NewDocValueRowK(key)
+	if err != nil {
+		return nil, err
+	}
+	rv.terms = make([][]byte, 0)
+	for len(value) > 0 {
+		termLen, n := binary.Uvarint(value)
+		if n <= 0 || termLen > uint64(len(value)-n) {
+			return nil, fmt.Errorf("invalid doc value for field %d document %s", rv.field, rv.doc)
+		}
+		rv.terms = append(rv.terms, value[n:n+int(termLen)])
+		value = value[n+int(termLen):]
+	}
+	return rv, nil
+}
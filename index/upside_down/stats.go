@@ -10,8 +10,13 @@
 package upside_down
 
 import (
+	"bytes"
 	"encoding/json"
 	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/store"
 )
 
 type indexStat struct {
@@ -20,12 +25,66 @@ type indexStat struct {
 }
 
 func (i *indexStat) MarshalJSON() ([]byte, error) {
-	m := map[string]interface{}{}
-	m["updates"] = atomic.LoadUint64(&i.updates)
-	m["deletes"] = atomic.LoadUint64(&i.deletes)
-	m["batches"] = atomic.LoadUint64(&i.batches)
-	m["errors"] = atomic.LoadUint64(&i.errors)
-	m["analysis_time"] = atomic.LoadUint64(&i.analysisTime)
-	m["index_time"] = atomic.LoadUint64(&i.indexTime)
-	return json.Marshal(m)
+	return json.Marshal(i.counters())
+}
+
+// counters returns the stats counted as the index is used
+func (i *indexStat) counters() *index.Stats {
+	return &index.Stats{
+		Updates:      atomic.LoadUint64(&i.updates),
+		Deletes:      atomic.LoadUint64(&i.deletes),
+		Batches:      atomic.LoadUint64(&i.batches),
+		Errors:       atomic.LoadUint64(&i.errors),
+		AnalysisTime: time.Duration(atomic.LoadUint64(&i.analysisTime)),
+		IndexTime:    time.Duration(atomic.LoadUint64(&i.indexTime)),
+	}
+}
+
+// Stats adds the document count and the number of
+// dictionary rows to the counters, the rows are counted
+// from an isolated reader
+func (udc *UpsideDownCouch) Stats() (rv *index.Stats, err error) {
+	rv = udc.stats.counters()
+	rv.DocCount, err = udc.DocCount()
+	if err != nil {
+		return nil, err
+	}
+
+	kvreader, err := udc.store.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := kvreader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	rv.Terms, err = countTerms(kvreader)
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func countTerms(kvreader store.KVReader) (count uint64, err error) {
+	keyPrefix := []byte{'d'}
+	it := kvreader.Iterator(keyPrefix)
+	defer func() {
+		if cerr := it.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	key, _, valid := it.Current()
+	for valid {
+		if !bytes.HasPrefix(key, keyPrefix) {
+			break
+		}
+		count++
+		it.Next()
+		key, _, valid = it.Current()
+	}
+
+	return
 }
@@ -0,0 +1,133 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package upside_down
+
+import (
+	"bytes"
+
+	"github.com/blevesearch/bleve/index/store"
+)
+
+// An upgrade rewrites the rows of an index of one version
+// into the encoding of the next version, the changes are
+// added to wb
+type upgrade func(kvreader store.KVReader, wb store.KVBatch) error
+
+// upgrades are indexed by the version they upgrade from
+var upgrades = map[uint8]upgrade{
+	5: upgradeTermVectorPayloads,
+}
+
+// canUpgrade tells whether there are upgrades from version
+// to the current version
+func canUpgrade(version uint8) bool {
+	for ; version < Version; version++ {
+		if upgrades[version] == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Upgrade rewrites an index of an older version.  All
+// upgrades are applied in a single batch along with the new
+// version row, so that an index whose upgrade was
+// interrupted is left as it was.  The batch holds the
+// rewritten rows, for large indexes the upgrade can take
+// a lot of memory.
+func (udc *UpsideDownCouch) Upgrade() (err error) {
+	udc.store.SetMergeOperator(&mergeOperator)
+	err = udc.store.Open()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := udc.store.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	var kvwriter store.KVWriter
+	kvwriter, err = udc.store.Writer()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := kvwriter.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	var value []byte
+	value, err = kvwriter.Get(VersionKey)
+	if err != nil || value == nil {
+		// a new index is initialized by Open
+		return
+	}
+	var vr *VersionRow
+	vr, err = NewVersionRowKV(VersionKey, value)
+	if err != nil {
+		return
+	}
+	if vr.version == Version {
+		return
+	}
+	if vr.version > Version || !canUpgrade(vr.version) {
+		return IncompatibleVersion
+	}
+
+	wb := kvwriter.NewBatch()
+	defer func() {
+		if cerr := wb.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	for version := vr.version; version < Version; version++ {
+		err = upgrades[version](kvwriter, wb)
+		if err != nil {
+			return
+		}
+	}
+	versionRow := NewVersionRow(Version)
+	wb.Set(versionRow.Key(), versionRow.Value())
+	return wb.Execute()
+}
+
+// upgradeTermVectorPayloads adds the payload length of
+// version 6 to the term vectors of term frequency rows
+func upgradeTermVectorPayloads(kvreader store.KVReader, wb store.KVBatch) (err error) {
+	prefix := []byte{'t'}
+	it := kvreader.Iterator(prefix)
+	defer func() {
+		if cerr := it.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	key, val, valid := it.Current()
+	for valid && bytes.HasPrefix(key, prefix) {
+		var tfr *TermFrequencyRow
+		tfr, err = NewTermFrequencyRowK(key)
+		if err != nil {
+			return
+		}
+		err = tfr.parseVersionedV(val, 5)
+		if err != nil {
+			return
+		}
+		// rows without vectors are encoded the same way
+		if len(tfr.vectors) > 0 {
+			wb.Set(tfr.Key(), tfr.Value())
+		}
+		it.Next()
+		key, val, valid = it.Current()
+	}
+	return
+}
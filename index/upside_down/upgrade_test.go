@@ -0,0 +1,163 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package upside_down
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/store/boltdb"
+)
+
+// valueV5 encodes a term frequency row as version 5 did,
+// without payloads
+func valueV5(tfr *TermFrequencyRow) []byte {
+	buf := make([]byte, binary.MaxVarintLen64*(2+len(tfr.vectors)*5))
+	used := binary.PutUvarint(buf, tfr.freq)
+	used += binary.PutUvarint(buf[used:], uint64(math.Float32bits(tfr.norm)))
+	for _, vector := range tfr.vectors {
+		used += binary.PutUvarint(buf[used:], uint64(vector.field))
+		used += binary.PutUvarint(buf[used:], vector.pos)
+		used += binary.PutUvarint(buf[used:], vector.start)
+		used += binary.PutUvarint(buf[used:], vector.end)
+		used += binary.PutUvarint(buf[used:], uint64(len(vector.arrayPositions)))
+	}
+	return buf[:used]
+}
+
+func TestUpgrade(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := NewUpsideDownCouch(boltdb.New("test", "bleve"), analysisQueue)
+	err := idx.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := document.NewDocument("a")
+	doc.AddField(document.NewTextFieldCustom("name", []uint64{}, []byte("test upgrade"), document.IndexField|document.IncludeTermVectors, testAnalyzer))
+	err = idx.Update(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// turn the index into one of version 5
+	store := boltdb.New("test", "bleve")
+	err = store.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kvwriter, err := store.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string][]byte)
+	it := kvwriter.Iterator([]byte{'t'})
+	key, val, valid := it.Current()
+	for valid && bytes.HasPrefix(key, []byte{'t'}) {
+		tfr, err := NewTermFrequencyRowKV(key, val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows[string(key)] = valueV5(tfr)
+		it.Next()
+		key, val, valid = it.Current()
+	}
+	err = it.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 term frequency rows, got %d", len(rows))
+	}
+	for key, val := range rows {
+		err = kvwriter.Set([]byte(key), val)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = kvwriter.Set(VersionKey, NewVersionRow(5).Value())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = kvwriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx = NewUpsideDownCouch(boltdb.New("test", "bleve"), analysisQueue)
+	err = idx.Open()
+	if err != index.ErrUpgradeRequired {
+		t.Fatalf("expected %v, got %v", index.ErrUpgradeRequired, err)
+	}
+	err = idx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx = NewUpsideDownCouch(boltdb.New("test", "bleve"), analysisQueue)
+	err = idx.Upgrade()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	reader, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	tfr, err := reader.TermFieldReader([]byte("upgrade"), "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	match, err := tfr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match == nil || len(match.Vectors) != 1 || match.Vectors[0].Pos != 2 || match.Vectors[0].Start != 5 {
+		t.Errorf("expected the term vector to be kept, got %v", match)
+	}
+	err = tfr.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
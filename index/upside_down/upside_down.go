@@ -11,9 +11,9 @@ package upside_down
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -33,7 +33,7 @@ var VersionKey = []byte{'v'}
 
 var UnsafeBatchUseDetected = fmt.Errorf("bleve.Batch is NOT thread-safe, modification after execution detected")
 
-const Version uint8 = 5
+const Version uint8 = 6
 
 var IncompatibleVersion = fmt.Errorf("incompatible version, %d is supported", Version)
 
@@ -44,6 +44,7 @@ type UpsideDownCouch struct {
 	fieldCache    *index.FieldCache
 	analysisQueue *index.AnalysisQueue
 	stats         *indexStat
+	readOnly      bool
 
 	m sync.RWMutex
 	// fields protected by m
@@ -109,6 +110,10 @@ func (udc *UpsideDownCouch) loadSchema(kvreader store.KVReader) (err error) {
 	if err != nil {
 		return
 	}
+	if vr.version < Version && canUpgrade(vr.version) {
+		err = index.ErrUpgradeRequired
+		return
+	}
 	if vr.version != Version {
 		err = IncompatibleVersion
 		return
@@ -163,6 +168,15 @@ func (udc *UpsideDownCouch) DocCount() (uint64, error) {
 	return udc.docCount, nil
 }
 
+// SetReadOnly makes Open load the index with a reader of
+// the store instead of a writer, so that other processes
+// can read the same store.  The store must be opened read
+// only as well, its writers refuse the changes of the
+// index.  It must be called before Open.
+func (udc *UpsideDownCouch) SetReadOnly() {
+	udc.readOnly = true
+}
+
 func (udc *UpsideDownCouch) Open() (err error) {
 	// install the merge operator
 	udc.store.SetMergeOperator(&mergeOperator)
@@ -173,39 +187,49 @@ func (udc *UpsideDownCouch) Open() (err error) {
 		return
 	}
 
-	// start a writer for the open process
+	// start a writer for the open process, or a reader if
+	// the index is opened read only
+	var kvreader store.KVReader
 	var kvwriter store.KVWriter
-	kvwriter, err = udc.store.Writer()
+	if udc.readOnly {
+		kvreader, err = udc.store.Reader()
+	} else {
+		kvwriter, err = udc.store.Writer()
+		kvreader = kvwriter
+	}
 	if err != nil {
 		return
 	}
 	defer func() {
-		if cerr := kvwriter.Close(); err == nil && cerr != nil {
+		if cerr := kvreader.Close(); err == nil && cerr != nil {
 			err = cerr
 		}
 	}()
 
 	var value []byte
-	value, err = kvwriter.Get(VersionKey)
+	value, err = kvreader.Get(VersionKey)
 	if err != nil {
 		return
 	}
 
 	// init new index OR load schema
 	if value == nil {
+		if kvwriter == nil {
+			return fmt.Errorf("cannot open a new index read only")
+		}
 		err = udc.init(kvwriter)
 		if err != nil {
 			return
 		}
 	} else {
-		err = udc.loadSchema(kvwriter)
+		err = udc.loadSchema(kvreader)
 		if err != nil {
 			return
 		}
 	}
 	// set doc count
 	udc.m.Lock()
-	udc.docCount, err = udc.countDocs(kvwriter)
+	udc.docCount, err = udc.countDocs(kvreader)
 	udc.m.Unlock()
 	return
 }
@@ -333,6 +357,11 @@ func (udc *UpsideDownCouch) mergeOldAndNew(backIndexRow *BackIndexRow, rows []in
 		existingStoredKeys[string(key)] = true
 	}
 
+	existingDocValueKeys := make(map[string]bool)
+	for _, key := range backIndexRow.AllDocValueKeys() {
+		existingDocValueKeys[string(key)] = true
+	}
+
 	for _, row := range rows {
 		switch row := row.(type) {
 		case *TermFrequencyRow:
@@ -351,6 +380,9 @@ func (udc *UpsideDownCouch) mergeOldAndNew(backIndexRow *BackIndexRow, rows []in
 			} else {
 				addRows = append(addRows, row)
 			}
+		case *DocValueRow:
+			delete(existingDocValueKeys, string(row.Key()))
+			updateRows = append(updateRows, row)
 		default:
 			updateRows = append(updateRows, row)
 		}
@@ -373,6 +405,14 @@ func (udc *UpsideDownCouch) mergeOldAndNew(backIndexRow *BackIndexRow, rows []in
 		}
 	}
 
+	// as well as the doc values of fields no longer in the document
+	for existingDocValueKey := range existingDocValueKeys {
+		docValueRow, err := NewDocValueRowK([]byte(existingDocValueKey))
+		if err == nil {
+			deleteRows = append(deleteRows, docValueRow)
+		}
+	}
+
 	return addRows, updateRows, deleteRows
 }
 
@@ -411,14 +451,19 @@ func (udc *UpsideDownCouch) indexField(docID string, field document.Field, field
 	backIndexTermEntries := make([]*BackIndexTermEntry, 0)
 	fieldNorm := float32(1.0 / math.Sqrt(float64(fieldLength)))
 
+	options := field.Options()
 	for _, tf := range tokenFreqs {
+		freq := uint64(frequencyFromTokenFreq(tf))
+		if options.OmitTermFreqs() {
+			freq = 1
+		}
 		var termFreqRow *TermFrequencyRow
-		if field.Options().IncludeTermVectors() {
-			tv, newFieldRows := udc.termVectorsFromTokenFreq(fieldIndex, tf)
+		if options.IncludeTermVectors() {
+			tv, newFieldRows := udc.termVectorsFromTokenFreq(fieldIndex, tf, options)
 			rows = append(rows, newFieldRows...)
-			termFreqRow = NewTermFrequencyRowWithTermVectors(tf.Term, fieldIndex, docID, uint64(frequencyFromTokenFreq(tf)), fieldNorm, tv)
+			termFreqRow = NewTermFrequencyRowWithTermVectors(tf.Term, fieldIndex, docID, freq, fieldNorm, tv)
 		} else {
-			termFreqRow = NewTermFrequencyRow(tf.Term, fieldIndex, docID, uint64(frequencyFromTokenFreq(tf)), fieldNorm)
+			termFreqRow = NewTermFrequencyRow(tf.Term, fieldIndex, docID, freq, fieldNorm)
 		}
 
 		// record the back index entry
@@ -485,6 +530,12 @@ func (udc *UpsideDownCouch) deleteSingle(id string, backIndexRow *BackIndexRow,
 		sf := NewStoredRow(id, uint16(*se.Field), se.ArrayPositions, 'x', nil)
 		deleteRows = append(deleteRows, sf)
 	}
+	for _, key := range backIndexRow.AllDocValueKeys() {
+		dvr, err := NewDocValueRowK(key)
+		if err == nil {
+			deleteRows = append(deleteRows, dvr)
+		}
+	}
 
 	// also delete the back entry itself
 	deleteRows = append(deleteRows, backIndexRow)
@@ -537,11 +588,26 @@ func decodeFieldType(typ byte, name string, pos []uint64, value []byte) document
 	return nil
 }
 
+// docValueTerms returns the terms of a field in order, to
+// be kept as its doc values
+func docValueTerms(termSet map[string]bool) [][]byte {
+	terms := make([]string, 0, len(termSet))
+	for term := range termSet {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	rv := make([][]byte, len(terms))
+	for i, term := range terms {
+		rv[i] = []byte(term)
+	}
+	return rv
+}
+
 func frequencyFromTokenFreq(tf *analysis.TokenFreq) int {
 	return len(tf.Locations)
 }
 
-func (udc *UpsideDownCouch) termVectorsFromTokenFreq(field uint16, tf *analysis.TokenFreq) ([]*TermVector, []index.IndexRow) {
+func (udc *UpsideDownCouch) termVectorsFromTokenFreq(field uint16, tf *analysis.TokenFreq, options document.IndexingOptions) ([]*TermVector, []index.IndexRow) {
 	rv := make([]*TermVector, len(tf.Locations))
 	newFieldRows := make([]index.IndexRow, 0)
 
@@ -558,9 +624,15 @@ func (udc *UpsideDownCouch) termVectorsFromTokenFreq(field uint16, tf *analysis.
 		tv := TermVector{
 			field:          fieldIndex,
 			arrayPositions: l.ArrayPositions,
-			pos:            uint64(l.Position),
-			start:          uint64(l.Start),
-			end:            uint64(l.End),
+		}
+		if !options.OmitTermPositions() {
+			tv.pos = uint64(l.Position)
+		}
+		if !options.OmitTermOffsets() && !options.OmitTermPositions() {
+			tv.start, tv.end = uint64(l.Start), uint64(l.End)
+		}
+		if options.IncludeTermPayloads() {
+			tv.payload = l.Payload
 		}
 		rv[i] = &tv
 	}
@@ -579,6 +651,7 @@ func (udc *UpsideDownCouch) termFieldVectorsFromTermVectors(in []*TermVector) []
 			Pos:            tv.pos,
 			Start:          tv.start,
 			End:            tv.end,
+			Payload:        tv.payload,
 		}
 		rv[i] = &tfv
 	}
@@ -752,8 +825,28 @@ func (udc *UpsideDownCouch) Reader() (index.IndexReader, error) {
 	}, nil
 }
 
-func (udc *UpsideDownCouch) Stats() json.Marshaler {
-	return udc.stats
+// ForceMerge compacts the KVStore when it supports it,
+// upside_down has no segments so maxSegments is ignored
+func (udc *UpsideDownCouch) ForceMerge(maxSegments int) error {
+	if compactor, ok := udc.store.(store.KVCompactor); ok {
+		return compactor.Compact()
+	}
+	return nil
+}
+
+// Backup copies the rows seen by an isolated reader, so
+// the backup reflects the index when it started
+func (udc *UpsideDownCouch) Backup(w index.BackupWriter) (err error) {
+	kvreader, err := udc.store.Reader()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := kvreader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	return index.BackupKVReader(kvreader, w)
 }
 
 func (udc *UpsideDownCouch) fieldIndexOrNewRow(name string) (uint16, *FieldRow) {
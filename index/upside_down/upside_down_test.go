@@ -1175,6 +1175,83 @@ func TestIndexDocumentFieldTerms(t *testing.T) {
 	}
 }
 
+func TestIndexDocumentTermVectors(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	store := boltdb.New("test", "bleve")
+	store.SetMergeOperator(&mergeOperator)
+	analysisQueue := index.NewAnalysisQueue(1)
+	idx := NewUpsideDownCouch(store, analysisQueue)
+	err := idx.Open()
+	if err != nil {
+		t.Errorf("error opening index: %v", err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	doc := document.NewDocument("1")
+	doc.AddField(document.NewTextFieldWithIndexingOptions("name", []uint64{}, []byte("test"), document.IndexField|document.StoreField|document.IncludeTermVectors))
+	doc.AddField(document.NewTextFieldWithIndexingOptions("title", []uint64{}, []byte("mister"), document.IndexField|document.StoreField))
+	err = idx.Update(doc)
+	if err != nil {
+		t.Errorf("Error updating index: %v", err)
+	}
+
+	indexReader, err := idx.Reader()
+	if err != nil {
+		t.Error(err)
+	}
+	defer func() {
+		err := indexReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	termVectors, err := indexReader.DocumentTermVectors("1", []string{"name", "title"})
+	if err != nil {
+		t.Error(err)
+	}
+	expectedTermVectors := index.FieldTermVectors{
+		"name": []*index.TermVector{
+			&index.TermVector{
+				Term: "test",
+				Freq: 1,
+				Vectors: []*index.TermFieldVector{
+					&index.TermFieldVector{Field: "name", Pos: 1, Start: 0, End: 4},
+				},
+			},
+		},
+		"title": []*index.TermVector{
+			&index.TermVector{
+				Term:    "mister",
+				Freq:    1,
+				Vectors: []*index.TermFieldVector{},
+			},
+		},
+	}
+	if !reflect.DeepEqual(termVectors, expectedTermVectors) {
+		t.Errorf("expected term vectors: %v, got: %v", expectedTermVectors, termVectors)
+	}
+
+	termVectors, err = indexReader.DocumentTermVectors("1", []string{"title"})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(termVectors) != 1 || termVectors["title"] == nil {
+		t.Errorf("expected only the term vectors of title, got %v", termVectors)
+	}
+}
+
 func BenchmarkBatch(b *testing.B) {
 
 	cache := registry.NewCache()
@@ -19,6 +19,14 @@ package bleve
 // valid operation is Search.  In this case the
 // search will be performed across all the
 // underlying indexes and the results merged.
+// 3.  When a RoutingFunc is set, Index, Delete, Batch
+// and Document also work across more than one index,
+// each document is written to the index chosen by the
+// routing.  The indexes are expected to share one
+// mapping.
+// Each index can have a filter query, searches through
+// the alias only see its documents matching the filter,
+// so that one index can be shared by several aliases.
 // Calls to Add/Remove/Swap the underlying indexes
 // are atomic, so you can safely change the
 // underlying Index objects while other components
@@ -29,4 +37,11 @@ type IndexAlias interface {
 	Add(i ...Index)
 	Remove(i ...Index)
 	Swap(in, out []Index)
+
+	SetRouting(routing RoutingFunc)
+	SetFilter(i Index, filter Query)
+
+	// Reshard moves the documents into shards routed by
+	// routing, while the alias stays in use
+	Reshard(shards []Index, routing RoutingFunc, opts *ReindexOptions) (*ReindexResult, error)
 }
@@ -10,10 +10,13 @@
 package bleve
 
 import (
+	"io"
+	"math"
 	"sort"
 	"sync"
 	"time"
 
+	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/document"
 	"github.com/blevesearch/bleve/index"
 	"github.com/blevesearch/bleve/index/store"
@@ -22,8 +25,12 @@ import (
 
 type indexAliasImpl struct {
 	indexes []Index
-	mutex   sync.RWMutex
-	open    bool
+	routing RoutingFunc
+	filters map[Index]Query
+	// the shards being filled by Reshard
+	resharding Index
+	mutex      sync.RWMutex
+	open       bool
 }
 
 // NewIndexAlias creates a new IndexAlias over the provided
@@ -44,7 +51,7 @@ func (i *indexAliasImpl) isAliasToSingleIndex() error {
 	return nil
 }
 
-func (i *indexAliasImpl) Index(id string, data interface{}) error {
+func (i *indexAliasImpl) Index(id string, data interface{}) (err error) {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
 
@@ -52,7 +59,20 @@ func (i *indexAliasImpl) Index(id string, data interface{}) error {
 		return ErrorIndexClosed
 	}
 
-	err := i.isAliasToSingleIndex()
+	// writes during a reshard go to the new shards as well
+	if i.resharding != nil {
+		defer func() {
+			if err == nil {
+				err = i.resharding.Index(id, data)
+			}
+		}()
+	}
+
+	if i.routing != nil && len(i.indexes) > 0 {
+		return i.routedIndex(id, data)
+	}
+
+	err = i.isAliasToSingleIndex()
 	if err != nil {
 		return err
 	}
@@ -60,7 +80,7 @@ func (i *indexAliasImpl) Index(id string, data interface{}) error {
 	return i.indexes[0].Index(id, data)
 }
 
-func (i *indexAliasImpl) Delete(id string) error {
+func (i *indexAliasImpl) Delete(id string) (err error) {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
 
@@ -68,7 +88,20 @@ func (i *indexAliasImpl) Delete(id string) error {
 		return ErrorIndexClosed
 	}
 
-	err := i.isAliasToSingleIndex()
+	// writes during a reshard go to the new shards as well
+	if i.resharding != nil {
+		defer func() {
+			if err == nil {
+				err = i.resharding.Delete(id)
+			}
+		}()
+	}
+
+	if i.routing != nil && len(i.indexes) > 0 {
+		return i.routedDelete(id)
+	}
+
+	err = i.isAliasToSingleIndex()
 	if err != nil {
 		return err
 	}
@@ -76,7 +109,7 @@ func (i *indexAliasImpl) Delete(id string) error {
 	return i.indexes[0].Delete(id)
 }
 
-func (i *indexAliasImpl) Batch(b *Batch) error {
+func (i *indexAliasImpl) Batch(b *Batch) (err error) {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
 
@@ -84,7 +117,20 @@ func (i *indexAliasImpl) Batch(b *Batch) error {
 		return ErrorIndexClosed
 	}
 
-	err := i.isAliasToSingleIndex()
+	// writes during a reshard go to the new shards as well
+	if i.resharding != nil {
+		defer func() {
+			if err == nil {
+				err = i.resharding.Batch(b)
+			}
+		}()
+	}
+
+	if i.routing != nil && len(i.indexes) > 0 {
+		return i.routedBatch(b)
+	}
+
+	err = i.isAliasToSingleIndex()
 	if err != nil {
 		return err
 	}
@@ -100,6 +146,10 @@ func (i *indexAliasImpl) Document(id string) (*document.Document, error) {
 		return nil, ErrorIndexClosed
 	}
 
+	if i.routing != nil && len(i.indexes) > 0 {
+		return i.routedDocument(id)
+	}
+
 	err := i.isAliasToSingleIndex()
 	if err != nil {
 		return nil, err
@@ -141,12 +191,14 @@ func (i *indexAliasImpl) Search(req *SearchRequest) (*SearchResult, error) {
 		return nil, ErrorAliasEmpty
 	}
 
+	indexes := i.searchIndexes()
+
 	// short circuit the simple case
-	if len(i.indexes) == 1 {
-		return i.indexes[0].Search(req)
+	if len(indexes) == 1 {
+		return indexes[0].Search(req)
 	}
 
-	return MultiSearch(req, i.indexes...)
+	return MultiSearch(req, indexes...)
 }
 
 func (i *indexAliasImpl) Fields() ([]string, error) {
@@ -299,6 +351,38 @@ func (i *indexAliasImpl) Close() error {
 	return nil
 }
 
+func (i *indexAliasImpl) Analyze(fieldOrAnalyzer string, text []byte) (analysis.TokenStream, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return nil, ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.indexes[0].Analyze(fieldOrAnalyzer, text)
+}
+
+func (i *indexAliasImpl) AnalyzeStages(fieldOrAnalyzer string, text []byte) ([]*analysis.AnalysisStage, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return nil, ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.indexes[0].AnalyzeStages(fieldOrAnalyzer, text)
+}
+
 func (i *indexAliasImpl) Mapping() *IndexMapping {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
@@ -307,6 +391,11 @@ func (i *indexAliasImpl) Mapping() *IndexMapping {
 		return nil
 	}
 
+	// the indexes of a routed alias share the mapping
+	if i.routing != nil && len(i.indexes) > 0 {
+		return i.indexes[0].Mapping()
+	}
+
 	err := i.isAliasToSingleIndex()
 	if err != nil {
 		return nil
@@ -379,6 +468,205 @@ func (i *indexAliasImpl) DeleteInternal(key []byte) error {
 	return i.indexes[0].DeleteInternal(key)
 }
 
+func (i *indexAliasImpl) ForceMerge(maxSegments int) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return err
+	}
+
+	return i.indexes[0].ForceMerge(maxSegments)
+}
+
+func (i *indexAliasImpl) Seq(id string) (uint64, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return 0, ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	return i.indexes[0].Seq(id)
+}
+
+func (i *indexAliasImpl) IndexIfSeq(id string, data interface{}, ifSeq uint64) (uint64, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return 0, ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	return i.indexes[0].IndexIfSeq(id, data, ifSeq)
+}
+
+func (i *indexAliasImpl) DeleteIfSeq(id string, ifSeq uint64) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return err
+	}
+
+	return i.indexes[0].DeleteIfSeq(id, ifSeq)
+}
+
+func (i *indexAliasImpl) Version(id string) (uint64, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return 0, ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	return i.indexes[0].Version(id)
+}
+
+func (i *indexAliasImpl) IndexVersion(id string, data interface{}, version uint64) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return err
+	}
+
+	return i.indexes[0].IndexVersion(id, data, version)
+}
+
+func (i *indexAliasImpl) DeleteVersion(id string, version uint64) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return err
+	}
+
+	return i.indexes[0].DeleteVersion(id, version)
+}
+
+func (i *indexAliasImpl) DeleteByQuery(q Query, cancel <-chan struct{}) (uint64, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return 0, ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	return i.indexes[0].DeleteByQuery(q, cancel)
+}
+
+func (i *indexAliasImpl) UpdateByQuery(req *UpdateByQueryRequest) (*UpdateByQueryResult, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return nil, ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.indexes[0].UpdateByQuery(req)
+}
+
+func (i *indexAliasImpl) Backup(w io.Writer) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	err := i.isAliasToSingleIndex()
+	if err != nil {
+		return err
+	}
+
+	return i.indexes[0].Backup(w)
+}
+
+// OpenPointInTime opens the point in time on all indexes
+// of the alias
+func (i *indexAliasImpl) OpenPointInTime(name string) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	for j, index := range i.indexes {
+		err := index.OpenPointInTime(name)
+		if err != nil {
+			for _, opened := range i.indexes[:j] {
+				_ = opened.ClosePointInTime(name)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *indexAliasImpl) ClosePointInTime(name string) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	var err error
+	for _, index := range i.indexes {
+		if cerr := index.ClosePointInTime(name); err == nil && cerr != nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
 func (i *indexAliasImpl) Advanced() (index.Index, store.KVStore, error) {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
@@ -406,6 +694,7 @@ func (i *indexAliasImpl) removeSingle(index Index) {
 	for pos, in := range i.indexes {
 		if in == index {
 			i.indexes = append(i.indexes[:pos], i.indexes[pos+1:]...)
+			delete(i.filters, index)
 			break
 		}
 	}
@@ -443,17 +732,45 @@ func (i *indexAliasImpl) Swap(in, out []Index) {
 // could be slower in remote usages.
 func createChildSearchRequest(req *SearchRequest) *SearchRequest {
 	rv := SearchRequest{
-		Query:     req.Query,
-		Size:      req.Size + req.From,
-		From:      0,
-		Highlight: req.Highlight,
-		Fields:    req.Fields,
-		Facets:    req.Facets,
-		Explain:   req.Explain,
+		Query:       req.Query,
+		Size:        req.Size + req.From,
+		From:        0,
+		Highlight:   req.Highlight,
+		Fields:      req.Fields,
+		Facets:      childFacetsRequest(req.Facets),
+		Explain:     req.Explain,
+		Sort:        req.Sort,
+		PointInTime: req.PointInTime,
 	}
 	return &rv
 }
 
+// childFacetsRequest asks the children for all their rare
+// terms, a term rare in several indexes is only found to be
+// among the rarest, or too frequent, once their counts are
+// added up.  Fixup trims the merged terms to size.
+func childFacetsRequest(facets FacetsRequest) FacetsRequest {
+	var rv FacetsRequest
+	for name, fr := range facets {
+		if fr.RareTermsMax <= 0 {
+			continue
+		}
+		if rv == nil {
+			rv = make(FacetsRequest, len(facets))
+			for name, fr := range facets {
+				rv[name] = fr
+			}
+		}
+		untrimmed := *fr
+		untrimmed.Size = math.MaxInt32
+		rv[name] = &untrimmed
+	}
+	if rv == nil {
+		return facets
+	}
+	return rv
+}
+
 // MultiSearch executes a SearchRequest across multiple
 // Index objects, then merges the results.
 func MultiSearch(req *SearchRequest, indexes ...Index) (*SearchResult, error) {
@@ -517,8 +834,12 @@ func MultiSearch(req *SearchRequest, indexes ...Index) (*SearchResult, error) {
 	// merge just concatenated all the hits
 	// now lets clean it up
 
-	// first sort it by score
-	sort.Sort(sr.Hits)
+	// first sort it by score, or by field
+	if req.Sort != "" {
+		sort.Sort(search.DocumentMatchesBySortKey(sr.Hits))
+	} else {
+		sort.Sort(sr.Hits)
+	}
 
 	// now skip over the correct From
 	if req.From > 0 && len(sr.Hits) > req.From {
@@ -553,6 +874,10 @@ func (i *indexAliasImpl) NewBatch() *Batch {
 		return nil
 	}
 
+	if i.routing != nil && len(i.indexes) > 0 {
+		return i.routedNewBatch()
+	}
+
 	err := i.isAliasToSingleIndex()
 	if err != nil {
 		return nil
@@ -574,3 +899,37 @@ func (f *indexAliasImplFieldDict) Close() error {
 	defer f.index.mutex.RUnlock()
 	return f.fieldDict.Close()
 }
+
+func (i *indexAliasImpl) SetRefreshInterval(interval time.Duration) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	for _, index := range i.indexes {
+		err := index.SetRefreshInterval(interval)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *indexAliasImpl) Refresh() error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	var err error
+	for _, index := range i.indexes {
+		if rerr := index.Refresh(); err == nil && rerr != nil {
+			err = rerr
+		}
+	}
+	return err
+}
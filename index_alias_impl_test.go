@@ -2,10 +2,12 @@ package bleve
 
 import (
 	"fmt"
+	"io"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/document"
 	"github.com/blevesearch/bleve/index"
 	"github.com/blevesearch/bleve/index/store"
@@ -678,6 +680,353 @@ func TestMultiSearchSecondPage(t *testing.T) {
 
 }
 
+func TestMultiSearchRareTerms(t *testing.T) {
+	shards := [][]string{
+		{"go", "go", "rust", "java"},
+		{"go", "rust", "lisp"},
+	}
+	var indexes []Index
+	for n, tags := range shards {
+		index, err := New("", NewIndexMapping())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := index.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		for i, tag := range tags {
+			err = index.Index(fmt.Sprintf("doc%d-%d", n, i), map[string]interface{}{"tag": tag})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		indexes = append(indexes, index)
+	}
+
+	// go is rare in each index but not in both, and java
+	// and lisp are rarer than rust though each index alone
+	// would return rust among its two rarest
+	req := NewSearchRequest(NewMatchAllQuery())
+	req.AddFacet("tags", NewRareTermsFacetRequest("tag", 2, 2))
+	res, err := MultiSearch(req, indexes...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := res.Facets["tags"]
+	expected := search.TermFacets{
+		&search.TermFacet{Term: "java", Count: 1},
+		&search.TermFacet{Term: "lisp", Count: 1},
+	}
+	if !reflect.DeepEqual(tags.RareTerms, expected) {
+		t.Errorf("expected %v, got %v", expected, tags.RareTerms)
+	}
+	if tags.Total != 7 || tags.Other != 5 {
+		t.Errorf("expected 7 terms with 5 other, got %d with %d other", tags.Total, tags.Other)
+	}
+	if req.Facets["tags"].Size != 2 {
+		t.Errorf("expected the request to keep its facet size, got %d", req.Facets["tags"].Size)
+	}
+}
+
+func TestIndexAliasRouting(t *testing.T) {
+	var indexes []Index
+	for n := 0; n < 3; n++ {
+		index, err := New("", NewIndexMapping())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := index.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		indexes = append(indexes, index)
+	}
+	alias := NewIndexAlias(indexes...)
+	alias.SetRouting(RouteByID())
+
+	for n := 0; n < 10; n++ {
+		err := alias.Index(fmt.Sprintf("doc%d", n), map[string]interface{}{"name": "marty"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	batch := alias.NewBatch()
+	for n := 10; n < 20; n++ {
+		err := batch.Index(fmt.Sprintf("doc%d", n), map[string]interface{}{"name": "marty"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	batch.Delete("doc0")
+	err := alias.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = alias.Delete("doc1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := alias.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 18 {
+		t.Errorf("expected 18 documents, got %d", count)
+	}
+	for n, index := range indexes {
+		count, err := index.DocCount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count == 0 || count == 18 {
+			t.Errorf("expected the documents to be spread, index %d has %d", n, count)
+		}
+	}
+	doc, err := alias.Document("doc5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc == nil {
+		t.Errorf("expected to find doc5")
+	}
+	doc, err = alias.Document("doc1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc != nil {
+		t.Errorf("expected doc1 to be deleted")
+	}
+	res, err := alias.Search(NewSearchRequest(NewMatchQuery("marty")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 18 {
+		t.Errorf("expected 18 hits, got %d", res.Total)
+	}
+}
+
+func TestIndexAliasRouteByField(t *testing.T) {
+	shared, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := shared.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	other, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := other.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	alias := NewIndexAlias(shared, other)
+	alias.SetRouting(RouteByField("tenant"))
+	for n := 0; n < 10; n++ {
+		tenant := "acme"
+		if n%2 == 1 {
+			tenant = "globex"
+		}
+		err = alias.Index(fmt.Sprintf("doc%d", n), map[string]interface{}{
+			"tenant": tenant,
+			"name":   "marty",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, index := range []Index{shared, other} {
+		count, err := index.DocCount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 && count != 5 && count != 10 {
+			t.Errorf("expected the tenants to be kept together, got %d documents", count)
+		}
+	}
+
+	// moving a document to another tenant leaves no copy behind
+	err = alias.Index("doc0", map[string]interface{}{"tenant": "globex", "name": "marty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := alias.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 10 {
+		t.Errorf("expected 10 documents, got %d", count)
+	}
+
+	// a filtered alias only sees one tenant
+	tenantAlias := NewIndexAlias(shared, other)
+	tenantAlias.SetFilter(shared, NewTermQuery("acme").SetField("tenant"))
+	tenantAlias.SetFilter(other, NewTermQuery("acme").SetField("tenant"))
+	res, err := tenantAlias.Search(NewSearchRequest(NewMatchQuery("marty")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 4 {
+		t.Errorf("expected 4 hits for tenant acme, got %d", res.Total)
+	}
+	tenantAlias.SetFilter(other, nil)
+	tenantAlias.Remove(shared)
+	res, err = tenantAlias.Search(NewSearchRequest(NewMatchQuery("marty")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err = other.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != count {
+		t.Errorf("expected %d hits without filter, got %d", count, res.Total)
+	}
+}
+
+func TestIndexAliasReshard(t *testing.T) {
+	source, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := source.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	for n := 0; n < 20; n++ {
+		err = source.Index(fmt.Sprintf("doc%02d", n), map[string]interface{}{"name": "marty"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var shards []Index
+	for n := 0; n < 3; n++ {
+		shard, err := New("", NewIndexMapping())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := shard.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		shards = append(shards, shard)
+	}
+
+	alias := NewIndexAlias(source)
+	var progress []uint64
+	res, err := alias.Reshard(shards, RouteByID(), &ReindexOptions{
+		BatchSize: 5,
+		Progress: func(res *ReindexResult) {
+			progress = append(progress, res.Processed)
+			// the alias takes writes during the reshard
+			if len(progress) == 1 {
+				err := alias.Index("added", map[string]interface{}{"name": "marty"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = alias.Delete("doc03")
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = alias.Delete("doc15")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// doc15 was deleted before it was copied
+	if res.Indexed != 19 || res.Batches != 4 {
+		t.Errorf("expected 19 documents copied in 4 batches, got %+v", res)
+	}
+	if !reflect.DeepEqual(progress, []uint64{5, 10, 15, 19}) {
+		t.Errorf("expected progress [5 10 15 19], got %v", progress)
+	}
+
+	count, err := alias.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 19 {
+		t.Errorf("expected 19 documents, got %d", count)
+	}
+	for n, shard := range shards {
+		count, err := shard.DocCount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count == 0 || count == 19 {
+			t.Errorf("expected the documents to be spread, shard %d has %d", n, count)
+		}
+	}
+	for _, id := range []string{"doc03", "doc15"} {
+		doc, err := alias.Document(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc != nil {
+			t.Errorf("expected %s to stay deleted", id)
+		}
+	}
+	doc, err := alias.Document("added")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc == nil {
+		t.Errorf("expected the document added during the reshard")
+	}
+
+	// and back into one index
+	merged, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := merged.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	_, err = alias.Reshard([]Index{merged}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err = merged.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 19 {
+		t.Errorf("expected 19 documents merged, got %d", count)
+	}
+	res2, err := alias.Search(NewSearchRequest(NewMatchQuery("marty")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res2.Total != 19 {
+		t.Errorf("expected 19 hits, got %d", res2.Total)
+	}
+}
+
 // stubIndex is an Index impl for which all operations
 // return the configured error value, unless the
 // corresponding operation result value has been
@@ -757,10 +1106,74 @@ func (i *stubIndex) DumpFields() chan interface{} {
 	return nil
 }
 
+func (i *stubIndex) ForceMerge(maxSegments int) error {
+	return i.err
+}
+
+func (i *stubIndex) Seq(id string) (uint64, error) {
+	return 0, i.err
+}
+
+func (i *stubIndex) IndexIfSeq(id string, data interface{}, ifSeq uint64) (uint64, error) {
+	return 0, i.err
+}
+
+func (i *stubIndex) DeleteIfSeq(id string, ifSeq uint64) error {
+	return i.err
+}
+
+func (i *stubIndex) Version(id string) (uint64, error) {
+	return 0, i.err
+}
+
+func (i *stubIndex) IndexVersion(id string, data interface{}, version uint64) error {
+	return i.err
+}
+
+func (i *stubIndex) DeleteVersion(id string, version uint64) error {
+	return i.err
+}
+
+func (i *stubIndex) DeleteByQuery(q Query, cancel <-chan struct{}) (uint64, error) {
+	return 0, i.err
+}
+
+func (i *stubIndex) UpdateByQuery(req *UpdateByQueryRequest) (*UpdateByQueryResult, error) {
+	return nil, i.err
+}
+
+func (i *stubIndex) Backup(w io.Writer) error {
+	return i.err
+}
+
+func (i *stubIndex) OpenPointInTime(name string) error {
+	return i.err
+}
+
+func (i *stubIndex) ClosePointInTime(name string) error {
+	return i.err
+}
+
+func (i *stubIndex) SetRefreshInterval(interval time.Duration) error {
+	return i.err
+}
+
+func (i *stubIndex) Refresh() error {
+	return i.err
+}
+
 func (i *stubIndex) Close() error {
 	return i.err
 }
 
+func (i *stubIndex) Analyze(fieldOrAnalyzer string, text []byte) (analysis.TokenStream, error) {
+	return nil, i.err
+}
+
+func (i *stubIndex) AnalyzeStages(fieldOrAnalyzer string, text []byte) ([]*analysis.AnalysisStage, error) {
+	return nil, i.err
+}
+
 func (i *stubIndex) Mapping() *IndexMapping {
 	return nil
 }
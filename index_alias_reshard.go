@@ -0,0 +1,91 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"time"
+)
+
+// Reshard moves the documents of the alias into shards,
+// routed by routing, and then makes the shards the indexes
+// of the alias.  With one index and more shards this splits
+// an index, with fewer shards it merges them.  The alias
+// stays usable meanwhile: searches see the old indexes
+// until the end, writes through Index, Delete and Batch go
+// to the old indexes and the shards.  The documents are
+// copied as by Reindex, opts.Resume does not apply.  The
+// old indexes are left open for the caller to close.
+func (i *indexAliasImpl) Reshard(shards []Index, routing RoutingFunc, opts *ReindexOptions) (*ReindexResult, error) {
+	if len(shards) < 1 {
+		return nil, ErrorAliasEmpty
+	}
+	target := NewIndexAlias(shards...)
+	target.SetRouting(routing)
+
+	i.mutex.Lock()
+	if !i.open {
+		i.mutex.Unlock()
+		return nil, ErrorIndexClosed
+	}
+	if i.resharding != nil {
+		i.mutex.Unlock()
+		return nil, ErrorAliasResharding
+	}
+	i.resharding = target
+	sources := make([]Index, len(i.indexes))
+	copy(sources, i.indexes)
+	i.mutex.Unlock()
+
+	start := time.Now()
+	rv := &ReindexResult{}
+	for _, source := range sources {
+		var sourceOpts ReindexOptions
+		if opts != nil {
+			sourceOpts = *opts
+		}
+		sourceOpts.Resume = false
+		if opts != nil && opts.Progress != nil {
+			done := *rv
+			sourceOpts.Progress = func(res *ReindexResult) {
+				total := done
+				total.add(res)
+				total.Took = time.Since(start)
+				opts.Progress(&total)
+			}
+		}
+		res, err := reindex(source, target, &sourceOpts, nil, &i.mutex)
+		if err != nil {
+			i.mutex.Lock()
+			i.resharding = nil
+			i.mutex.Unlock()
+			return nil, err
+		}
+		rv.add(res)
+	}
+
+	i.mutex.Lock()
+	for _, source := range sources {
+		delete(i.filters, source)
+	}
+	i.indexes = shards
+	i.routing = routing
+	i.resharding = nil
+	i.mutex.Unlock()
+
+	rv.Took = time.Since(start)
+	return rv, nil
+}
+
+func (r *ReindexResult) add(other *ReindexResult) {
+	r.Processed += other.Processed
+	r.Indexed += other.Indexed
+	r.Batches += other.Batches
+	r.LastID = other.LastID
+}
@@ -0,0 +1,247 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"hash/fnv"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+)
+
+// A RoutingFunc picks which of the n indexes of an alias
+// holds the document id.  doc is the document as mapped by
+// the first index of the alias, or nil when only the id is
+// known, for deletes and lookups.  A negative result means
+// the document can be in any of the indexes.
+type RoutingFunc func(id string, doc *document.Document, n int) int
+
+func routingHash(b []byte, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write(b)
+	return int(h.Sum32() % uint32(n))
+}
+
+// RouteByID spreads documents over the indexes of an alias
+// by a hash of their id.
+func RouteByID() RoutingFunc {
+	return func(id string, doc *document.Document, n int) int {
+		return routingHash([]byte(id), n)
+	}
+}
+
+// RouteByField puts documents with the same value of field
+// into the same index, for example all documents of one
+// tenant.  Documents without the field are routed by their
+// id.  As the id alone does not tell where a document is,
+// deletes and lookups go to all indexes, and indexing a
+// document deletes it from the other indexes.
+func RouteByField(field string) RoutingFunc {
+	return func(id string, doc *document.Document, n int) int {
+		if doc == nil {
+			return -1
+		}
+		for _, f := range doc.Fields {
+			if f.Name() == field {
+				return routingHash(f.Value(), n)
+			}
+		}
+		return routingHash([]byte(id), n)
+	}
+}
+
+// route returns the position of the index for id, or -1
+func (i *indexAliasImpl) route(id string, doc *document.Document) int {
+	pos := i.routing(id, doc, len(i.indexes))
+	if pos >= len(i.indexes) {
+		return -1
+	}
+	return pos
+}
+
+func (i *indexAliasImpl) routedIndex(id string, data interface{}) error {
+	doc := document.NewDocument(id)
+	err := i.indexes[0].Mapping().mapDocument(doc, data)
+	if err != nil {
+		return err
+	}
+	pos := i.route(id, doc)
+	if pos < 0 {
+		return ErrorAliasMulti
+	}
+	err = i.indexes[pos].Index(id, data)
+	if err != nil {
+		return err
+	}
+	if i.route(id, nil) < 0 {
+		// the document may have been routed elsewhere before
+		for other, in := range i.indexes {
+			if other != pos {
+				err = in.Delete(id)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (i *indexAliasImpl) routedDelete(id string) error {
+	if pos := i.route(id, nil); pos >= 0 {
+		return i.indexes[pos].Delete(id)
+	}
+	for _, in := range i.indexes {
+		err := in.Delete(id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *indexAliasImpl) routedDocument(id string) (*document.Document, error) {
+	if pos := i.route(id, nil); pos >= 0 {
+		return i.indexes[pos].Document(id)
+	}
+	for _, in := range i.indexes {
+		doc, err := in.Document(id)
+		if doc != nil || err != nil {
+			return doc, err
+		}
+	}
+	return nil, nil
+}
+
+// routedBatch splits b into one batch per index, internal
+// operations go to all of them
+func (i *indexAliasImpl) routedBatch(b *Batch) error {
+	batches := make([]*Batch, len(i.indexes))
+	for pos, in := range i.indexes {
+		batches[pos] = in.NewBatch()
+		for key, val := range b.internal.InternalOps {
+			batches[pos].internal.InternalOps[key] = val
+		}
+	}
+	for id, doc := range b.internal.IndexOps {
+		pos := i.route(id, doc)
+		if pos < 0 && doc != nil {
+			return ErrorAliasMulti
+		}
+		for other, batch := range batches {
+			switch {
+			case other == pos || (pos < 0 && doc == nil):
+				if doc != nil {
+					batch.internal.Update(doc)
+				} else {
+					batch.internal.Delete(id)
+				}
+				if version, ok := b.versions[id]; ok {
+					batch.setVersion(id, version)
+				}
+			case i.route(id, nil) < 0:
+				batch.internal.Delete(id)
+			}
+		}
+	}
+	// skipped operations of one index do not keep the
+	// batches of the others from being executed
+	var conflicts *VersionConflictError
+	for pos, batch := range batches {
+		if batch.Size() == 0 {
+			continue
+		}
+		err := i.indexes[pos].Batch(batch)
+		if verr, ok := err.(*VersionConflictError); ok {
+			if conflicts == nil {
+				conflicts = &VersionConflictError{Versions: make(map[string]uint64)}
+			}
+			for id, version := range verr.Versions {
+				conflicts.Versions[id] = version
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if conflicts != nil {
+		return conflicts
+	}
+	return nil
+}
+
+func (i *indexAliasImpl) routedNewBatch() *Batch {
+	return &Batch{
+		index:    i,
+		internal: index.NewBatch(),
+	}
+}
+
+// aliasMember can be embedded, which Index itself cannot
+// as the field name would collide with its Index method
+type aliasMember Index
+
+// A filteredIndex is a member of an alias with a filter,
+// its searches only match documents matching the filter.
+type filteredIndex struct {
+	aliasMember
+	filter Query
+}
+
+func (f *filteredIndex) Search(req *SearchRequest) (*SearchResult, error) {
+	filtered := *req
+	filtered.Query = NewConjunctionQuery([]Query{req.Query, f.filter})
+	sr, err := f.aliasMember.Search(&filtered)
+	if err != nil {
+		return nil, err
+	}
+	sr.Request = req
+	return sr, nil
+}
+
+func (i *indexAliasImpl) searchIndexes() []Index {
+	if len(i.filters) == 0 {
+		return i.indexes
+	}
+	rv := make([]Index, len(i.indexes))
+	for pos, in := range i.indexes {
+		if filter, ok := i.filters[in]; ok {
+			rv[pos] = &filteredIndex{
+				aliasMember: in,
+				filter:      filter,
+			}
+		} else {
+			rv[pos] = in
+		}
+	}
+	return rv
+}
+
+func (i *indexAliasImpl) SetRouting(routing RoutingFunc) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.routing = routing
+}
+
+func (i *indexAliasImpl) SetFilter(in Index, filter Query) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if filter == nil {
+		delete(i.filters, in)
+		return
+	}
+	if i.filters == nil {
+		i.filters = make(map[Index]Query)
+	}
+	i.filters[in] = filter
+}
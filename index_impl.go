@@ -12,11 +12,13 @@ package bleve
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/document"
 	"github.com/blevesearch/bleve/index"
 	"github.com/blevesearch/bleve/index/store"
@@ -25,6 +27,7 @@ import (
 	"github.com/blevesearch/bleve/search"
 	"github.com/blevesearch/bleve/search/collectors"
 	"github.com/blevesearch/bleve/search/facets"
+	"github.com/blevesearch/bleve/search/searchers"
 )
 
 type indexImpl struct {
@@ -36,9 +39,34 @@ type indexImpl struct {
 	mutex sync.RWMutex
 	open  bool
 	stats *IndexStat
+
+	// set when opened with the "read_only" runtime config
+	readOnly bool
+
+	pointsInTimeMutex sync.Mutex
+	pointsInTime      map[string]*pointInTime
+
+	// held by the writes checking sequence numbers or
+	// versions and shared by the others, see executeBatch
+	writeMutex sync.RWMutex
+
+	// guards the last sequence number given out and the end
+	// of the reservation, see nextSeqs
+	seqMutex    sync.Mutex
+	seq         uint64
+	seqReserved uint64
+
+	ttlStop     chan struct{}
+	ttlStopOnce sync.Once
+	ttlDone     sync.WaitGroup
+
+	// the average lengths of the fields scored with BM25,
+	// dropped by every batch
+	fieldLengths fieldLengthCache
 }
 
 const storePath = "store"
+const indexPath = "index"
 
 var mappingInternalKey = []byte("_mapping")
 
@@ -46,6 +74,24 @@ func indexStorePath(path string) string {
 	return path + string(os.PathSeparator) + storePath
 }
 
+func indexDirectoryPath(path string) string {
+	return path + string(os.PathSeparator) + indexPath
+}
+
+// setIndexSortField passes the sort field of the mapping
+// on to the index, which must support sorting
+func setIndexSortField(i index.Index, mapping *IndexMapping) error {
+	if mapping.SortField == "" {
+		return nil
+	}
+	sortedIndex, ok := i.(index.SortedIndex)
+	if !ok {
+		return ErrorIndexSortUnsupported
+	}
+	sortedIndex.SetSortField(mapping.SortField)
+	return nil
+}
+
 func newMemIndex(indexType string, mapping *IndexMapping) (*indexImpl, error) {
 	rv := indexImpl{
 		path:  "",
@@ -75,11 +121,15 @@ func newMemIndex(indexType string, mapping *IndexMapping) (*indexImpl, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = setIndexSortField(rv.i, mapping)
+	if err != nil {
+		return nil, err
+	}
 	err = rv.i.Open()
 	if err != nil {
 		return nil, err
 	}
-	rv.stats.indexStat = rv.i.Stats()
+	rv.stats.i = rv.i
 
 	// now persist the mapping
 	mappingBytes, err := json.Marshal(mapping)
@@ -95,6 +145,7 @@ func newMemIndex(indexType string, mapping *IndexMapping) (*indexImpl, error) {
 	rv.mutex.Lock()
 	defer rv.mutex.Unlock()
 	rv.open = true
+	rv.startTTLSweeper()
 	return &rv, nil
 }
 
@@ -148,11 +199,24 @@ func newIndexUsing(path string, mapping *IndexMapping, indexType string, kvstore
 	if err != nil {
 		return nil, err
 	}
+	if directoryIndex, ok := rv.i.(index.DirectoryIndex); ok {
+		directoryIndex.SetDirectory(indexDirectoryPath(path))
+	}
+	if configurableIndex, ok := rv.i.(index.ConfigurableIndex); ok {
+		err = configurableIndex.SetConfig(kvconfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = setIndexSortField(rv.i, mapping)
+	if err != nil {
+		return nil, err
+	}
 	err = rv.i.Open()
 	if err != nil {
 		return nil, err
 	}
-	rv.stats.indexStat = rv.i.Stats()
+	rv.stats.i = rv.i
 
 	// now persist the mapping
 	mappingBytes, err := json.Marshal(mapping)
@@ -168,6 +232,7 @@ func newIndexUsing(path string, mapping *IndexMapping, indexType string, kvstore
 	rv.mutex.Lock()
 	defer rv.mutex.Unlock()
 	rv.open = true
+	rv.startTTLSweeper()
 	return &rv, nil
 }
 
@@ -202,6 +267,9 @@ func openIndexUsing(path string, runtimeConfig map[string]interface{}) (rv *inde
 
 	// now open the store
 	rv.s, err = storeConstructor(storeConfig)
+	if err == store.ErrReadOnlyUnsupported {
+		return nil, ErrorReadOnlyUnsupported
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -216,11 +284,40 @@ func openIndexUsing(path string, runtimeConfig map[string]interface{}) (rv *inde
 	if err != nil {
 		return nil, err
 	}
+	if directoryIndex, ok := rv.i.(index.DirectoryIndex); ok {
+		directoryIndex.SetDirectory(indexDirectoryPath(path))
+	}
+	if configurableIndex, ok := rv.i.(index.ConfigurableIndex); ok {
+		err = configurableIndex.SetConfig(storeConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if readOnly, _ := storeConfig["read_only"].(bool); readOnly {
+		readOnlyIndex, ok := rv.i.(index.ReadOnlyIndex)
+		if !ok {
+			return nil, ErrorReadOnlyUnsupported
+		}
+		readOnlyIndex.SetReadOnly()
+		rv.readOnly = true
+	}
+	if upgrade, _ := storeConfig["upgrade"].(bool); upgrade && !rv.readOnly {
+		if upgradableIndex, ok := rv.i.(index.UpgradableIndex); ok {
+			err = upgradableIndex.Upgrade()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
 	err = rv.i.Open()
+	if err == index.ErrUpgradeRequired {
+		_ = rv.i.Close()
+		return nil, ErrorIndexUpgradeRequired
+	}
 	if err != nil {
 		return nil, err
 	}
-	rv.stats.indexStat = rv.i.Stats()
+	rv.stats.i = rv.i
 
 	// now load the mapping
 	indexReader, err := rv.i.Reader()
@@ -238,6 +335,12 @@ func openIndexUsing(path string, runtimeConfig map[string]interface{}) (rv *inde
 		return nil, err
 	}
 
+	rv.seq, err = readSeq(indexReader, seqInternalKey)
+	if err != nil {
+		return nil, err
+	}
+	rv.seqReserved = rv.seq
+
 	var im IndexMapping
 	err = json.Unmarshal(mappingBytes, &im)
 	if err != nil {
@@ -258,6 +361,9 @@ func openIndexUsing(path string, runtimeConfig map[string]interface{}) (rv *inde
 	}
 
 	rv.m = &im
+	if !rv.readOnly {
+		rv.startTTLSweeper()
+	}
 	return rv, err
 }
 
@@ -273,6 +379,38 @@ func (i *indexImpl) Mapping() *IndexMapping {
 	return i.m
 }
 
+// Analyze returns the tokens produced by analyzing the
+// text.  fieldOrAnalyzer may name a field, in which case
+// the analyzer the mapping uses for that field is applied,
+// or it may name an analyzer directly.  Fields mapped with
+// an explicit analyzer take precedence over analyzers of
+// the same name.
+func (i *indexImpl) Analyze(fieldOrAnalyzer string, text []byte) (analysis.TokenStream, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return nil, ErrorIndexClosed
+	}
+
+	return i.m.analyze(fieldOrAnalyzer, text)
+}
+
+// AnalyzeStages resolves fieldOrAnalyzer like Analyze and
+// returns the output of every char filter, the tokenizer
+// and every token filter of the analyzer, to show where
+// a token was changed or removed.
+func (i *indexImpl) AnalyzeStages(fieldOrAnalyzer string, text []byte) ([]*analysis.AnalysisStage, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return nil, ErrorIndexClosed
+	}
+
+	return i.m.analyzeStages(fieldOrAnalyzer, text)
+}
+
 // Index the object with the specified identifier.
 // The IndexMapping for this index will determine
 // how the object is indexed.
@@ -289,11 +427,10 @@ func (i *indexImpl) Index(id string, data interface{}) error {
 	if err != nil {
 		return err
 	}
-	err = i.i.Update(doc)
-	if err != nil {
-		return err
-	}
-	return nil
+	batch := index.NewBatch()
+	batch.Update(doc)
+	_, err = i.executeBatch(batch, nil, nil)
+	return err
 }
 
 // Delete entries for the specified identifier from
@@ -306,11 +443,10 @@ func (i *indexImpl) Delete(id string) error {
 		return ErrorIndexClosed
 	}
 
-	err := i.i.Delete(id)
-	if err != nil {
-		return err
-	}
-	return nil
+	batch := index.NewBatch()
+	batch.Delete(id)
+	_, err := i.executeBatch(batch, nil, nil)
+	return err
 }
 
 // Batch executes multiple Index and Delete
@@ -325,13 +461,16 @@ func (i *indexImpl) Batch(b *Batch) error {
 		return ErrorIndexClosed
 	}
 
-	return i.i.Batch(b.internal)
+	_, err := i.executeBatch(b.internal, nil, b.versions)
+	return err
 }
 
 // Document is used to find the values of all the
 // stored fields for a document in the index.  These
 // stored fields are put back into a Document object
 // and returned.
+// Document returns the stored fields of the document id,
+// along with those of its nested documents in Nested.
 func (i *indexImpl) Document(id string) (doc *document.Document, err error) {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
@@ -353,12 +492,18 @@ func (i *indexImpl) Document(id string) (doc *document.Document, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if doc != nil && i.m.hasNestedMappings() {
+		err = addNestedDocuments(indexReader, doc)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return doc, nil
 }
 
 // DocCount returns the number of documents in the
-// index.
-func (i *indexImpl) DocCount() (uint64, error) {
+// index, the documents of nested sections are left out.
+func (i *indexImpl) DocCount() (count uint64, err error) {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
 
@@ -366,7 +511,61 @@ func (i *indexImpl) DocCount() (uint64, error) {
 		return 0, ErrorIndexClosed
 	}
 
-	return i.i.DocCount()
+	if !i.m.hasNestedMappings() {
+		return i.i.DocCount()
+	}
+	indexReader, err := i.i.Reader()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := indexReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	count = indexReader.DocCount()
+	nested, err := nestedDocCount(indexReader)
+	if err != nil {
+		return 0, err
+	}
+	if nested > count {
+		return 0, nil
+	}
+	return count - nested, nil
+}
+
+// searchReader wraps the reader of a search with the
+// runtime fields, aliases and similarities of the mapping.
+// The reader was opened after the generation of the field
+// lengths.
+func (i *indexImpl) searchReader(indexReader index.IndexReader, generation uint64) index.IndexReader {
+	if len(i.m.RuntimeFields) > 0 {
+		indexReader = newRuntimeIndexReader(indexReader, i.m)
+	}
+	if aliases := i.m.fieldAliases(); len(aliases) > 0 {
+		indexReader = newAliasIndexReader(indexReader, aliases)
+	}
+	if i.m.hasSimilarities() {
+		indexReader = newSimilarityIndexReader(indexReader, i.m, &i.fieldLengths, generation)
+	}
+	return indexReader
+}
+
+// querySearcher returns the searcher of q on a reader from
+// searchReader, it leaves out nested documents
+func (i *indexImpl) querySearcher(indexReader index.IndexReader, q Query, explain bool) (search.Searcher, error) {
+	searcher, err := q.Searcher(indexReader, i.m, explain)
+	if err != nil {
+		return nil, err
+	}
+	if i.m.hasNestedMappings() {
+		// nested documents are only found through their
+		// document
+		searcher = searchers.NewIDFilterSearcher(searcher, func(id string) bool {
+			return !isNestedDocID(id)
+		})
+	}
+	return searcher, nil
 }
 
 // Search executes a search request operation.
@@ -381,20 +580,47 @@ func (i *indexImpl) Search(req *SearchRequest) (sr *SearchResult, err error) {
 		return nil, ErrorIndexClosed
 	}
 
-	collector := collectors.NewTopScorerSkipCollector(req.Size, req.From)
+	var indexReader index.IndexReader
+	var generation uint64
+	if req.PointInTime != "" {
+		// search the reader held by the point in time
+		pit, err := i.pointInTime(req.PointInTime)
+		if err != nil {
+			return nil, err
+		}
+		pit.mutex.Lock()
+		defer pit.mutex.Unlock()
+		if pit.reader == nil {
+			return nil, ErrorUnknownPointInTime
+		}
+		indexReader = pit.reader
+		generation = pit.generation
+	} else {
+		// open a reader for this search
+		generation = i.fieldLengths.current()
+		indexReader, err = i.i.Reader()
+		if err != nil {
+			return nil, fmt.Errorf("error opening index reader %v", err)
+		}
+		defer func() {
+			if cerr := indexReader.Close(); err == nil && cerr != nil {
+				err = cerr
+			}
+		}()
+	}
 
-	// open a reader for this search
-	indexReader, err := i.i.Reader()
-	if err != nil {
-		return nil, fmt.Errorf("error opening index reader %v", err)
+	indexReader = i.searchReader(indexReader, generation)
+
+	var collector search.Collector
+	if req.Sort != "" {
+		// an alias of the sort field of the index is sorted
+		// like the field itself
+		collector = collectors.NewTopFieldCollector(req.Size, req.From, i.m.resolveAlias(req.Sort), indexReader)
+	} else {
+		collector = collectors.NewTopScorerSkipCollector(req.Size, req.From)
 	}
-	defer func() {
-		if cerr := indexReader.Close(); err == nil && cerr != nil {
-			err = cerr
-		}
-	}()
 
-	searcher, err := req.Query.Searcher(indexReader, i.m, req.Explain)
+	searcher, err := i.querySearcher(indexReader, req.Query, req.Explain)
 	if err != nil {
 		return nil, err
 	}
@@ -423,6 +649,14 @@ func (i *indexImpl) Search(req *SearchRequest) (sr *SearchResult, err error) {
 					facetBuilder.AddRange(dr.Name, dr.Start, dr.End)
 				}
 				facetsBuilder.Add(facetName, facetBuilder)
+			} else if facetRequest.DateHistogram {
+				// build date histogram facet
+				facetBuilder := facets.NewDateHistogramFacetBuilder(facetRequest.Field, facetRequest.Size)
+				facetsBuilder.Add(facetName, facetBuilder)
+			} else if facetRequest.RareTermsMax > 0 {
+				// build rare terms facet
+				facetBuilder := facets.NewRareTermsFacetBuilder(facetRequest.Field, facetRequest.Size, facetRequest.RareTermsMax)
+				facetsBuilder.Add(facetName, facetBuilder)
 			} else {
 				// build terms facet
 				facetBuilder := facets.NewTermsFacetBuilder(facetRequest.Field, facetRequest.Size)
@@ -662,6 +896,72 @@ func (i *indexImpl) DumpFields() chan interface{} {
 	return i.i.DumpFields()
 }
 
+// ForceMerge compacts the index, reclaiming the space of
+// deleted documents.  Index types made of segments merge
+// them down to at most maxSegments, others compact their
+// KVStore if it supports compaction.  It is intended for
+// indexes which are mostly read from then on.
+func (i *indexImpl) ForceMerge(maxSegments int) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+	if i.readOnly {
+		return ErrorIndexReadOnly
+	}
+	return i.i.ForceMerge(maxSegments)
+}
+
+func (i *indexImpl) DeleteByQuery(q Query, cancel <-chan struct{}) (uint64, error) {
+	i.mutex.RLock()
+	open := i.open
+	i.mutex.RUnlock()
+
+	if !open {
+		return 0, ErrorIndexClosed
+	}
+	return deleteByQuery(i, q, cancel)
+}
+
+func (i *indexImpl) UpdateByQuery(req *UpdateByQueryRequest) (*UpdateByQueryResult, error) {
+	i.mutex.RLock()
+	open := i.open
+	i.mutex.RUnlock()
+
+	if !open {
+		return nil, ErrorIndexClosed
+	}
+	if i.m.hasUnstoredFields() {
+		return nil, ErrorUnstoredFields
+	}
+	return updateByQuery(i, req)
+}
+
+func (i *indexImpl) Backup(w io.Writer) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	bw, err := newBackupWriter(w)
+	if err != nil {
+		return err
+	}
+	err = bw.writeMeta(i.meta)
+	if err != nil {
+		return err
+	}
+	err = i.i.Backup(bw)
+	if err != nil {
+		return err
+	}
+	return bw.Close()
+}
+
 // DumpDoc writes all rows in the index associated
 // with the specified identifier to a channel.
 // INTERNAL: do not rely on this function, it is
@@ -677,11 +977,21 @@ func (i *indexImpl) DumpDoc(id string) chan interface{} {
 }
 
 func (i *indexImpl) Close() error {
+	// the sweeper takes the lock, it is stopped first
+	i.stopTTLSweeper()
+
 	i.mutex.Lock()
 	defer i.mutex.Unlock()
 
 	i.open = false
-	return i.i.Close()
+	err := i.closePointsInTime()
+	if serr := i.releaseSeqs(); err == nil && serr != nil {
+		err = serr
+	}
+	if cerr := i.i.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	return err
 }
 
 func (i *indexImpl) Stats() *IndexStat {
@@ -720,6 +1030,12 @@ func (i *indexImpl) SetInternal(key, val []byte) error {
 	if !i.open {
 		return ErrorIndexClosed
 	}
+	if i.readOnly {
+		return ErrorIndexReadOnly
+	}
+	if isReservedInternalKey(key) {
+		return ErrorInternalKeyReserved
+	}
 
 	return i.i.SetInternal(key, val)
 }
@@ -731,6 +1047,12 @@ func (i *indexImpl) DeleteInternal(key []byte) error {
 	if !i.open {
 		return ErrorIndexClosed
 	}
+	if i.readOnly {
+		return ErrorIndexReadOnly
+	}
+	if isReservedInternalKey(key) {
+		return ErrorInternalKeyReserved
+	}
 
 	return i.i.DeleteInternal(key)
 }
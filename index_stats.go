@@ -12,18 +12,41 @@ package bleve
 import (
 	"encoding/json"
 	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/index"
 )
 
 type IndexStat struct {
-	indexStat  json.Marshaler
+	i          index.Index
 	searches   uint64
 	searchTime uint64
 }
 
+// Index returns the stats of the underlying index, such as
+// its segments and the merge and flush counters
+func (is *IndexStat) Index() (*index.Stats, error) {
+	return is.i.Stats()
+}
+
+// Searches returns the number of searches run
+func (is *IndexStat) Searches() uint64 {
+	return atomic.LoadUint64(&is.searches)
+}
+
+// SearchTime returns the total time spent searching
+func (is *IndexStat) SearchTime() time.Duration {
+	return time.Duration(atomic.LoadUint64(&is.searchTime))
+}
+
 func (is *IndexStat) MarshalJSON() ([]byte, error) {
+	indexStats, err := is.Index()
+	if err != nil {
+		return nil, err
+	}
 	m := map[string]interface{}{}
-	m["index"] = is.indexStat
-	m["searches"] = atomic.LoadUint64(&is.searches)
+	m["index"] = indexStats
+	m["searches"] = is.Searches()
 	m["search_time"] = atomic.LoadUint64(&is.searchTime)
 	return json.Marshal(m)
 }
@@ -10,17 +10,39 @@
 package bleve
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/analyzers/custom_analyzer"
 	"github.com/blevesearch/bleve/analysis/analyzers/keyword_analyzer"
+	"github.com/blevesearch/bleve/analysis/analyzers/normalizer_analyzer"
+	"github.com/blevesearch/bleve/analysis/datetime_parsers/datetime_optional"
+	"github.com/blevesearch/bleve/analysis/datetime_parsers/epoch"
+	"github.com/blevesearch/bleve/analysis/datetime_parsers/flexible_go"
+	"github.com/blevesearch/bleve/analysis/token_filters/delimited_payload_filter"
+	"github.com/blevesearch/bleve/analysis/token_filters/edge_ngram_filter"
+	"github.com/blevesearch/bleve/analysis/token_filters/lower_case_filter"
+	"github.com/blevesearch/bleve/analysis/tokenizers/regexp_tokenizer"
+	"github.com/blevesearch/bleve/analysis/tokenizers/unicode"
+	"github.com/blevesearch/bleve/geo"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/index/segmented"
+	"github.com/blevesearch/bleve/index/store/gtreap"
+	"github.com/blevesearch/bleve/index/upside_down"
+	"github.com/blevesearch/bleve/search/scorers"
 )
 
 func TestCrud(t *testing.T) {
@@ -559,6 +581,13 @@ func TestDict(t *testing.T) {
 	if stats == nil {
 		t.Errorf("expected IndexStat, got nil")
 	}
+	indexStats, err := stats.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexStats.Terms == 0 {
+		t.Errorf("expected terms in the index stats")
+	}
 
 	err = dict.Close()
 	if err != nil {
@@ -1038,3 +1067,3585 @@ func TestTermVectorArrayPositions(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestMatchQuerySynonyms(t *testing.T) {
+	mapping := NewIndexMapping()
+	err := mapping.AddCustomTokenFilter("test_synonyms", map[string]interface{}{
+		"type": "synonym",
+		"synonyms": []interface{}{
+			"tv, television",
+			"nyc, new york city",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := New("", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	err = index.Index("a", map[string]interface{}{"desc": "i love new york city"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Index("b", map[string]interface{}{"desc": "television is fun"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query Query
+		hits  []string
+	}{
+		{
+			query: &matchQuery{Match: "nyc", FieldVal: "desc", BoostVal: 1, Synonyms: "test_synonyms"},
+			hits:  []string{"a"},
+		},
+		{
+			query: &matchQuery{Match: "tv", FieldVal: "desc", BoostVal: 1, Synonyms: "test_synonyms"},
+			hits:  []string{"b"},
+		},
+		{
+			query: &matchQuery{Match: "tv", FieldVal: "desc", BoostVal: 1},
+			hits:  []string{},
+		},
+		{
+			query: &matchPhraseQuery{MatchPhrase: "love nyc", FieldVal: "desc", BoostVal: 1, Synonyms: "test_synonyms"},
+			hits:  []string{"a"},
+		},
+		{
+			query: &matchPhraseQuery{MatchPhrase: "tv is fun", FieldVal: "desc", BoostVal: 1, Synonyms: "test_synonyms"},
+			hits:  []string{"b"},
+		},
+	}
+
+	for _, test := range tests {
+		res, err := index.Search(NewSearchRequest(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hits := make([]string, len(res.Hits))
+		for i, hit := range res.Hits {
+			hits[i] = hit.ID
+		}
+		if !reflect.DeepEqual(hits, test.hits) {
+			t.Errorf("expected hits %v, got %v", test.hits, hits)
+		}
+	}
+}
+
+func TestIndexTimeSynonymPhrases(t *testing.T) {
+	mapping := NewIndexMapping()
+	err := mapping.AddCustomTokenFilter("test_synonyms", map[string]interface{}{
+		"type":     "synonym",
+		"synonyms": []interface{}{"usa, united states of america"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mapping.AddCustomAnalyzer("with_synonyms", map[string]interface{}{
+		"type":          custom_analyzer.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": []interface{}{lower_case_filter.Name, "test_synonyms"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	descMapping := NewTextFieldMapping()
+	descMapping.Analyzer = "with_synonyms"
+	mapping.DefaultMapping.AddFieldMappingsAt("desc", descMapping)
+	index, err := New("", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	err = index.Index("a", map[string]interface{}{"desc": "visit usa today"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		phrase string
+		hits   uint64
+	}{
+		{phrase: "visit usa today", hits: 1},
+		{phrase: "united states of america today", hits: 1},
+		{phrase: "visit united states", hits: 1},
+		{phrase: "states today", hits: 0},
+		{phrase: "today visit", hits: 0},
+	}
+	for _, test := range tests {
+		res, err := index.Search(NewSearchRequest(NewMatchPhraseQuery(test.phrase).SetField("desc")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != test.hits {
+			t.Errorf("phrase %q: expected %d hits, got %d", test.phrase, test.hits, res.Total)
+		}
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	mapping := NewIndexMapping()
+	err := mapping.AddCustomTokenizer("non_space", map[string]interface{}{
+		"type":   regexp_tokenizer.Name,
+		"regexp": `\S+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mapping.AddCustomAnalyzer("payloads", map[string]interface{}{
+		"type":          custom_analyzer.Name,
+		"tokenizer":     "non_space",
+		"token_filters": []interface{}{delimited_payload_filter.Name},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping.DefaultAnalyzer = "payloads"
+	descMapping := NewTextFieldMapping()
+	descMapping.IncludePayloads = true
+	mapping.DefaultMapping.AddFieldMappingsAt("desc", descMapping)
+	mapping.DefaultMapping.AddFieldMappingsAt("other", NewTextFieldMapping())
+
+	index, err := New("", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	err = index.Index("a", map[string]interface{}{
+		"desc":  "the|DT quick|JJ fox|NN",
+		"other": "the|DT quick|JJ fox|NN",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := NewTermQuery("quick").SetField("desc")
+	res, err := index.Search(NewSearchRequest(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Hits) != 1 {
+		t.Fatalf("expected 1 hit, got %d", len(res.Hits))
+	}
+	locations := res.Hits[0].Locations["desc"]["quick"]
+	if len(locations) != 1 {
+		t.Fatalf("expected 1 location, got %d", len(locations))
+	}
+	if string(locations[0].Payload) != "JJ" {
+		t.Errorf("expected payload 'JJ', got '%s'", locations[0].Payload)
+	}
+
+	// payloads are only kept for the fields asking for them
+	query = NewTermQuery("quick").SetField("other")
+	res, err = index.Search(NewSearchRequest(query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Hits) != 1 {
+		t.Fatalf("expected 1 hit, got %d", len(res.Hits))
+	}
+	locations = res.Hits[0].Locations["other"]["quick"]
+	if len(locations) != 1 || locations[0].Payload != nil {
+		t.Errorf("expected a location without payload, got %v", locations)
+	}
+}
+
+func TestDocumentTermVectors(t *testing.T) {
+	mapping := NewIndexMapping()
+	err := mapping.AddCustomTokenizer("non_space", map[string]interface{}{
+		"type":   regexp_tokenizer.Name,
+		"regexp": `\S+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mapping.AddCustomAnalyzer("payloads", map[string]interface{}{
+		"type":          custom_analyzer.Name,
+		"tokenizer":     "non_space",
+		"token_filters": []interface{}{delimited_payload_filter.Name},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	descMapping := NewTextFieldMapping()
+	descMapping.Analyzer = "payloads"
+	descMapping.IncludePayloads = true
+	mapping.DefaultMapping.AddFieldMappingsAt("desc", descMapping)
+
+	for _, indexType := range []string{upside_down.Name, segmented.Name} {
+		func() {
+			defer func() {
+				err := os.RemoveAll("testidx")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			idx, err := NewUsing("testidx", mapping, indexType, gtreap.Name, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				err := idx.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			err = idx.Index("a", map[string]interface{}{
+				"desc": "quick|JJ fox|NN quick|RB",
+				"name": "marty",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			i, _, err := idx.Advanced()
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader, err := i.Reader()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				err := reader.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+			termVectors, err := reader.DocumentTermVectors("a", []string{"desc"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := []*index.TermVector{
+				&index.TermVector{
+					Term: "fox",
+					Freq: 1,
+					Vectors: []*index.TermFieldVector{
+						&index.TermFieldVector{Field: "desc", Pos: 2, Start: 9, End: 15, Payload: []byte("NN")},
+					},
+				},
+				&index.TermVector{
+					Term: "quick",
+					Freq: 2,
+					Vectors: []*index.TermFieldVector{
+						&index.TermFieldVector{Field: "desc", Pos: 1, Start: 0, End: 8, Payload: []byte("JJ")},
+						&index.TermFieldVector{Field: "desc", Pos: 3, Start: 16, End: 24, Payload: []byte("RB")},
+					},
+				},
+			}
+			vectors := termVectors["desc"]
+			if len(termVectors) != 1 || len(vectors) != len(expected) {
+				t.Fatalf("%s: expected the term vectors of desc, got %v", indexType, termVectors)
+			}
+			for n, tv := range vectors {
+				if tv.Term != expected[n].Term || tv.Freq != expected[n].Freq || len(tv.Vectors) != len(expected[n].Vectors) {
+					t.Errorf("%s: expected %v, got %v", indexType, expected[n], tv)
+					continue
+				}
+				for v, vector := range tv.Vectors {
+					e := expected[n].Vectors[v]
+					if vector.Field != e.Field || vector.Pos != e.Pos || vector.Start != e.Start || vector.End != e.End || !bytes.Equal(vector.Payload, e.Payload) {
+						t.Errorf("%s: expected vector %+v, got %+v", indexType, e, vector)
+					}
+				}
+			}
+		}()
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	f := NewTextFieldMapping()
+	f.Analyzer = keyword_analyzer.Name
+
+	m := NewIndexMapping()
+	m.DefaultMapping = NewDocumentMapping()
+	m.DefaultMapping.AddFieldMappingsAt("code", f)
+
+	index, err := New("", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	tests := []struct {
+		fieldOrAnalyzer string
+		terms           []string
+	}{
+		// field with an explicit analyzer
+		{
+			fieldOrAnalyzer: "code",
+			terms:           []string{"Quick Brown-Fox"},
+		},
+		// analyzer by name
+		{
+			fieldOrAnalyzer: "simple",
+			terms:           []string{"quick", "brown", "fox"},
+		},
+		// unmapped field uses the default analyzer
+		{
+			fieldOrAnalyzer: "description",
+			terms:           []string{"quick", "brown", "fox"},
+		},
+	}
+
+	for _, test := range tests {
+		tokens, err := index.Analyze(test.fieldOrAnalyzer, []byte("Quick Brown-Fox"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		terms := make([]string, len(tokens))
+		for i, token := range tokens {
+			terms[i] = string(token.Term)
+		}
+		if !reflect.DeepEqual(terms, test.terms) {
+			t.Errorf("expected %v for %s, got %v", test.terms, test.fieldOrAnalyzer, terms)
+		}
+	}
+
+	tokens, err := index.Analyze("code", []byte("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].Start != 0 || tokens[0].End != 3 || tokens[0].Position != 1 {
+		t.Errorf("expected a single token with offsets and position, got %v", tokens)
+	}
+
+	err = index.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = index.Analyze("code", []byte("abc"))
+	if err != ErrorIndexClosed {
+		t.Errorf("expected closed index error, got %v", err)
+	}
+}
+
+func TestAnalyzeStages(t *testing.T) {
+	index, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	stages, err := index.AnalyzeStages("standard", []byte("The running dogs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"The", "running", "dogs"},
+		{"the", "running", "dogs"},
+		{"running", "dogs"},
+	}
+	if len(stages) != len(expected) {
+		t.Fatalf("expected %d stages, got %d", len(expected), len(stages))
+	}
+	for i, stage := range stages {
+		terms := make([]string, len(stage.Tokens))
+		for j, token := range stage.Tokens {
+			terms[j] = string(token.Term)
+		}
+		if !reflect.DeepEqual(terms, expected[i]) {
+			t.Errorf("expected %v after stage %d %s, got %v", expected[i], i, stage.Name, terms)
+		}
+	}
+	if stages[0].Kind != analysis.TokenizerStage || stages[1].Kind != analysis.TokenFilterStage {
+		t.Errorf("expected tokenizer stage followed by token filter stages")
+	}
+}
+
+func TestNormalizer(t *testing.T) {
+	m := NewIndexMapping()
+	err := m.AddCustomAnalyzer("lowercase_normalizer", map[string]interface{}{
+		"type":          normalizer_analyzer.Name,
+		"token_filters": []interface{}{"to_lower"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := NewTextFieldMapping()
+	f.Analyzer = "lowercase_normalizer"
+	m.DefaultMapping = NewDocumentMapping()
+	m.DefaultMapping.AddFieldMappingsAt("sku", f)
+
+	index, err := New("", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	err = index.Index("a", map[string]interface{}{"sku": "ABC-123 Blue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query Query
+		hits  int
+	}{
+		// match queries are normalized with the field analyzer
+		{
+			query: NewMatchQuery("abc-123 BLUE").SetField("sku"),
+			hits:  1,
+		},
+		{
+			query: NewTermQuery("abc-123 blue").SetField("sku"),
+			hits:  1,
+		},
+		// the value is not split into words
+		{
+			query: NewTermQuery("blue").SetField("sku"),
+			hits:  0,
+		},
+	}
+
+	for _, test := range tests {
+		res, err := index.Search(NewSearchRequest(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Hits) != test.hits {
+			t.Errorf("expected %d hits, got %d", test.hits, len(res.Hits))
+		}
+	}
+
+	err = m.AddCustomAnalyzer("bad_normalizer", map[string]interface{}{
+		"type":      normalizer_analyzer.Name,
+		"tokenizer": "unicode",
+	})
+	if err == nil {
+		t.Errorf("expected error for normalizer with a tokenizer")
+	}
+}
+
+func TestSegmentedIndexType(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	index, err := NewUsing("testidx", NewIndexMapping(), segmented.Name, Config.DefaultKVStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := index.NewBatch()
+	err = batch.Index("a", map[string]interface{}{"name": "marty", "desc": "gophercon india"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = batch.Index("b", map[string]interface{}{"name": "steve", "desc": "gophercon"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Delete("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(index Index) {
+		count, err := index.DocCount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("expected 1 document, got %d", count)
+		}
+		res, err := index.Search(NewSearchRequest(NewMatchQuery("gophercon").SetField("desc")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Hits) != 1 || res.Hits[0].ID != "a" {
+			t.Errorf("expected a to match, got %v", res.Hits)
+		}
+	}
+	check(index)
+	err = index.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the segments are kept next to the store
+	_, err = os.Stat(indexDirectoryPath("testidx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, err = Open("testidx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(index)
+	err = index.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSortedIndex(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	mapping := NewIndexMapping()
+	mapping.SortField = "name"
+	index, err := NewUsing("testidx", mapping, segmented.Name, Config.DefaultKVStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs := []map[string]interface{}{
+		{"_id": "a", "name": "steve", "desc": "gophercon"},
+		{"_id": "b", "name": "marty", "desc": "gophercon india"},
+		{"_id": "c", "name": "alice"},
+		{"_id": "d", "desc": "gophercon"},
+	}
+	// index in two batches, so there is more than one segment
+	for _, part := range [][]map[string]interface{}{docs[:2], docs[2:]} {
+		batch := index.NewBatch()
+		for _, doc := range part {
+			err = batch.Index(doc["_id"].(string), doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = index.Batch(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = index.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err = Open("testidx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if index.Mapping().SortField != "name" {
+		t.Errorf("expected sort field name after reopen, got %q", index.Mapping().SortField)
+	}
+
+	tests := []struct {
+		query    Query
+		size     int
+		from     int
+		expected []string
+		total    uint64
+	}{
+		{
+			query:    NewMatchAllQuery(),
+			size:     2,
+			expected: []string{"c", "b"},
+			total:    4,
+		},
+		{
+			query:    NewMatchAllQuery(),
+			size:     10,
+			from:     2,
+			expected: []string{"a", "d"},
+			total:    4,
+		},
+		{
+			query:    NewMatchQuery("gophercon").SetField("desc"),
+			size:     10,
+			expected: []string{"b", "a", "d"},
+			total:    3,
+		},
+	}
+	for _, test := range tests {
+		req := NewSearchRequestOptions(test.query, test.size, test.from, false)
+		req.Sort = "name"
+		res, err := index.Search(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(res.Hits))
+		for i, hit := range res.Hits {
+			ids[i] = hit.ID
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("expected %v, got %v", test.expected, ids)
+		}
+		if res.Total != test.total {
+			t.Errorf("expected %d total hits, got %d", test.total, res.Total)
+		}
+	}
+
+	// upside_down keeps documents in id order only
+	_, err = NewUsing("testidx2", mapping, upside_down.Name, Config.DefaultKVStore, nil)
+	defer func() {
+		err := os.RemoveAll("testidx2")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err != ErrorIndexSortUnsupported {
+		t.Errorf("expected sort unsupported error, got %v", err)
+	}
+}
+
+func TestSortedIndexSearchReader(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	mapping := NewIndexMapping()
+	mapping.SortField = "name"
+	descMapping := NewTextFieldMapping()
+	descMapping.Similarity = scorers.BM25SimilarityName
+	mapping.DefaultMapping.AddFieldMappingsAt("desc", descMapping)
+	mapping.DefaultMapping.AddFieldMappingsAt("name", NewTextFieldMapping())
+	mapping.DefaultMapping.AddFieldMappingsAt("who", NewAliasFieldMapping("name"))
+	mapping.DefaultMapping.AddFieldMappingsAt("about", NewAliasFieldMapping("desc"))
+	RegisterRuntimeFunction("test_first_word", func(sources map[string][]interface{}) []interface{} {
+		if len(sources["desc"]) == 0 {
+			return nil
+		}
+		return []interface{}{strings.Fields(sources["desc"][0].(string))[0]}
+	})
+	mapping.AddRuntimeField("first_word", NewRuntimeFieldMapping("text", "test_first_word", "desc"))
+	idx, err := NewUsing("testidx", mapping, segmented.Name, Config.DefaultKVStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	docs := map[string]map[string]interface{}{
+		"a": {"name": "steve", "desc": "gophercon"},
+		"b": {"name": "marty", "desc": "gophercon india"},
+		"c": {"name": "alice"},
+	}
+	for id, doc := range docs {
+		err = idx.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the readers searches wrap the index reader in keep
+	// the order of the index
+	impl := idx.(*indexImpl)
+	indexReader, err := impl.i.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := indexReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	sortedReader, ok := impl.searchReader(indexReader, impl.fieldLengths.current()).(index.SortedIndexReader)
+	if !ok || sortedReader.SortField() != "name" {
+		t.Fatalf("expected the search reader to be sorted by name")
+	}
+	idReader, err := sortedReader.SortedDocIDReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	id, err := idReader.Next()
+	for err == nil && id != "" {
+		ids = append(ids, id)
+		id, err = idReader.Next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idReader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"c", "b", "a"}) {
+		t.Errorf("expected [c b a], got %v", ids)
+	}
+
+	docSetReader, ok := impl.searchReader(indexReader, impl.fieldLengths.current()).(index.DocSetIndexReader)
+	if !ok {
+		t.Fatalf("expected the search reader to keep the doc sets of the index")
+	}
+	docSet, err := docSetReader.TermDocSet([]byte("india"), "about")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docSet == nil || docSet.Count() != 1 {
+		t.Errorf("expected the doc set of india in desc through its alias, got %v", docSet)
+	}
+
+	// runtime fields are not in the doc sets nor the
+	// automata of the index
+	docSet, err = docSetReader.TermDocSet([]byte("gophercon"), "first_word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docSet != nil {
+		t.Errorf("expected no doc set for a runtime field, got %d docs", docSet.Count())
+	}
+	tests := []struct {
+		query Query
+		total uint64
+	}{
+		{query: NewTermQuery("gophercon").SetField("first_word"), total: 2},
+		{query: NewRegexpQuery("go.*con").SetField("first_word"), total: 2},
+		{query: NewFuzzyQuery("gophercom").SetField("first_word"), total: 2},
+		{query: NewRegexpQuery("ind.*").SetField("about"), total: 1},
+	}
+	for _, test := range tests {
+		res, err := idx.Search(NewSearchRequest(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != test.total {
+			t.Errorf("query %v: expected %d hits, got %d", test.query, test.total, res.Total)
+		}
+	}
+
+	for _, field := range []string{"name", "who"} {
+		req := NewSearchRequest(NewMatchAllQuery())
+		req.Sort = field
+		res, err := idx.Search(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = ids[:0]
+		for _, hit := range res.Hits {
+			ids = append(ids, hit.ID)
+		}
+		if !reflect.DeepEqual(ids, []string{"c", "b", "a"}) {
+			t.Errorf("expected [c b a] sorted by %s, got %v", field, ids)
+		}
+	}
+}
+
+func TestBackup(t *testing.T) {
+	for _, indexType := range []string{upside_down.Name, segmented.Name} {
+		func() {
+			defer func() {
+				err := os.RemoveAll("testidx")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			index, err := NewUsing("testidx", NewIndexMapping(), indexType, Config.DefaultKVStore, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				err := index.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+			err = index.Index("a", map[string]interface{}{"name": "marty"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			err = index.Backup(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			backup := buf.Bytes()
+			if !bytes.HasPrefix(backup, backupMagic) || backup[len(backup)-1] != backupRecordEnd {
+				t.Errorf("%s: expected a complete backup, got %q", indexType, backup)
+			}
+			if !bytes.Contains(backup, mappingInternalKey) {
+				t.Errorf("%s: expected the mapping in the backup", indexType)
+			}
+			if indexType == segmented.Name && !bytes.Contains(backup, []byte(".seg")) {
+				t.Errorf("%s: expected the segment files in the backup", indexType)
+			}
+		}()
+	}
+}
+
+func TestRestore(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	mapping := NewIndexMapping()
+	mapping.DefaultAnalyzer = "keyword"
+	backups := make(map[string][]byte)
+	for _, indexType := range []string{upside_down.Name, segmented.Name} {
+		for _, path := range []string{"", "testidx"} {
+			index, err := NewUsing(path, mapping, indexType, Config.DefaultKVStore, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = index.Index("a", map[string]interface{}{"name": "marty chang"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = index.SetInternal([]byte("k"), []byte("v"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			err = index.Backup(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			backups[indexType+":"+path] = buf.Bytes()
+			err = index.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.RemoveAll("testidx")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for name, backup := range backups {
+		index, err := Restore(bytes.NewReader(backup), "testidx")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		count, err := index.DocCount()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("%s: expected 1 document, got %d", name, count)
+		}
+		if index.Mapping().DefaultAnalyzer != "keyword" {
+			t.Errorf("%s: expected the mapping to be restored", name)
+		}
+		res, err := index.Search(NewSearchRequest(NewTermQuery("marty chang").SetField("name")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Hits) != 1 || res.Hits[0].ID != "a" {
+			t.Errorf("%s: expected a to match, got %v", name, res.Hits)
+		}
+		val, err := index.GetInternal([]byte("k"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != "v" {
+			t.Errorf("%s: expected internal value v, got %q", name, val)
+		}
+		err = index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a truncated backup is not restored
+		_, err = Restore(bytes.NewReader(backup[:len(backup)-1]), "testidx")
+		if err != ErrorBackupCorrupt {
+			t.Errorf("%s: expected ErrorBackupCorrupt, got %v", name, err)
+		}
+		if _, err := os.Stat("testidx"); !os.IsNotExist(err) {
+			t.Errorf("%s: expected no index after a failed restore", name)
+		}
+	}
+}
+
+func TestPointInTime(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// points in time need a store with isolated readers
+	for _, indexType := range []string{upside_down.Name, segmented.Name} {
+		index, err := NewUsing("testidx", NewIndexMapping(), indexType, gtreap.Name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []string{"a", "b", "c"} {
+			err = index.Index(id, map[string]interface{}{"name": id})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = index.OpenPointInTime("p")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = index.OpenPointInTime("p")
+		if err != ErrorPointInTimeExists {
+			t.Errorf("%s: expected ErrorPointInTimeExists, got %v", indexType, err)
+		}
+
+		err = index.Delete("b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []string{"d", "e"} {
+			err = index.Index(id, map[string]interface{}{"name": id})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// pages of the point in time see the documents as
+		// they were when it was opened
+		ids := make([]string, 0)
+		for from := 0; from < 4; from += 2 {
+			req := NewSearchRequestOptions(NewMatchAllQuery(), 2, from, false)
+			req.PointInTime = "p"
+			res, err := index.Search(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Total != 3 {
+				t.Errorf("%s: expected 3 total hits, got %d", indexType, res.Total)
+			}
+			for _, hit := range res.Hits {
+				ids = append(ids, hit.ID)
+			}
+		}
+		sort.Strings(ids)
+		if !reflect.DeepEqual(ids, []string{"a", "b", "c"}) {
+			t.Errorf("%s: expected [a b c], got %v", indexType, ids)
+		}
+
+		res, err := index.Search(NewSearchRequest(NewMatchAllQuery()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != 4 {
+			t.Errorf("%s: expected 4 total hits, got %d", indexType, res.Total)
+		}
+
+		err = index.ClosePointInTime("p")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := NewSearchRequest(NewMatchAllQuery())
+		req.PointInTime = "p"
+		_, err = index.Search(req)
+		if err != ErrorUnknownPointInTime {
+			t.Errorf("%s: expected ErrorUnknownPointInTime, got %v", indexType, err)
+		}
+
+		// points in time left open are closed with the index
+		err = index.OpenPointInTime("q")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUpdateByQuery(t *testing.T) {
+	index, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		err = index.Index(strconv.Itoa(i), map[string]interface{}{
+			"name": "marty",
+			"age":  float64(i),
+			"address": map[string]interface{}{
+				"city": "bangalore",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = index.Index("x", map[string]interface{}{"name": "steve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []uint64
+	res, err := index.UpdateByQuery(&UpdateByQueryRequest{
+		Query:     NewMatchQuery("marty").SetField("name"),
+		Update:    PatchFields(map[string]interface{}{"name": "martin", "address.country": "india"}),
+		BatchSize: 2,
+		Progress: func(res *UpdateByQueryResult) {
+			progress = append(progress, res.Updated)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 5 || res.Processed != 5 || res.Updated != 5 || res.Batches != 3 {
+		t.Errorf("expected 5 documents updated in 3 batches, got %+v", res)
+	}
+	if !reflect.DeepEqual(progress, []uint64{2, 4, 5}) {
+		t.Errorf("expected progress [2 4 5], got %v", progress)
+	}
+
+	doc, err := index.Document("3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := DocumentFields(index.Mapping(), doc)
+	expectedFields := map[string]interface{}{
+		"name": "martin",
+		"age":  float64(3),
+		"address": map[string]interface{}{
+			"city":    "bangalore",
+			"country": "india",
+		},
+	}
+	if !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("expected %v, got %v", expectedFields, fields)
+	}
+
+	count := func(q Query) uint64 {
+		res, err := index.Search(NewSearchRequest(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Total
+	}
+	if n := count(NewMatchQuery("martin").SetField("name")); n != 5 {
+		t.Errorf("expected 5 documents named martin, got %d", n)
+	}
+	if n := count(NewMatchQuery("india").SetField("address.country")); n != 5 {
+		t.Errorf("expected 5 documents in india, got %d", n)
+	}
+
+	// an update function may leave documents as they are
+	res, err = index.UpdateByQuery(&UpdateByQueryRequest{
+		Query: NewMatchAllQuery(),
+		Update: func(id string, fields map[string]interface{}) (interface{}, error) {
+			if id != "x" {
+				return nil, nil
+			}
+			fields["name"] = "steven"
+			return fields, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Processed != 6 || res.Updated != 1 {
+		t.Errorf("expected 1 of 6 documents updated, got %+v", res)
+	}
+}
+
+func TestUpdateByQueryUnstoredFields(t *testing.T) {
+	mapping := NewIndexMapping()
+	unstored := NewTextFieldMapping()
+	unstored.Store = false
+	mapping.DefaultMapping.AddFieldMappingsAt("name", unstored)
+	idx, err := New("", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	_, err = idx.UpdateByQuery(&UpdateByQueryRequest{
+		Query:  NewMatchAllQuery(),
+		Update: PatchFields(map[string]interface{}{"age": 1}),
+	})
+	if err != ErrorUnstoredFields {
+		t.Errorf("expected ErrorUnstoredFields, got %v", err)
+	}
+
+	// fields only copied into are not lost
+	mapping = NewIndexMapping()
+	name := NewTextFieldMapping()
+	name.CopyTo = []string{"all_names"}
+	mapping.DefaultMapping.AddFieldMappingsAt("name", name)
+	mapping.DefaultMapping.AddFieldMappingsAt("all_names", unstored)
+	if mapping.hasUnstoredFields() {
+		t.Errorf("expected copy_to targets to be left out")
+	}
+}
+
+func TestUpdateByQuerySubFields(t *testing.T) {
+	mapping := NewIndexMapping()
+	rawMapping := NewTextFieldMapping()
+	rawMapping.Analyzer = keyword_analyzer.Name
+	titleMapping := NewTextFieldMapping()
+	titleMapping.Fields = map[string]*FieldMapping{"raw": rawMapping}
+	titleMapping.CopyTo = []string{"all_titles"}
+	mapping.DefaultMapping.AddFieldMappingsAt("title", titleMapping)
+	mapping.DefaultMapping.AddFieldMappingsAt("all_titles", NewTextFieldMapping())
+	idx, err := New("", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = idx.Index("a", map[string]interface{}{"title": "hello world", "n": float64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = idx.UpdateByQuery(&UpdateByQueryRequest{
+		Query:  NewMatchAllQuery(),
+		Update: PatchFields(map[string]interface{}{"n": float64(2)}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := idx.Document("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := DocumentFields(idx.Mapping(), doc)
+	expectedFields := map[string]interface{}{"title": "hello world", "n": float64(2)}
+	if !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("expected %v, got %v", expectedFields, fields)
+	}
+	for _, q := range []Query{
+		NewMatchQuery("hello").SetField("title"),
+		NewTermQuery("hello world").SetField("title.raw"),
+		NewMatchQuery("hello").SetField("all_titles"),
+	} {
+		res, err := idx.Search(NewSearchRequest(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != 1 {
+			t.Errorf("query %v: expected 1 hit after the update, got %d", q, res.Total)
+		}
+	}
+}
+
+func TestReindex(t *testing.T) {
+	src, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := src.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	names := []string{"marty chang", "steve yen", "dustin lee", "ravi kumar", "mark hall"}
+	for i, name := range names {
+		err = src.Index(strconv.Itoa(i), map[string]interface{}{
+			"name": name,
+			"age":  float64(i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the new mapping keeps names whole
+	nameMapping := NewTextFieldMapping()
+	nameMapping.Analyzer = "keyword"
+	docMapping := NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("name", nameMapping)
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping = docMapping
+	dst, err := New("", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dst.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var progress []uint64
+	res, err := Reindex(src, dst, &ReindexOptions{
+		Transform: func(id string, fields map[string]interface{}) (interface{}, error) {
+			if id == "2" {
+				return nil, nil
+			}
+			fields["source"] = "reindex"
+			return fields, nil
+		},
+		BatchSize: 2,
+		Progress: func(res *ReindexResult) {
+			progress = append(progress, res.Processed)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Processed != 5 || res.Indexed != 4 || res.Batches != 3 || res.LastID != "4" {
+		t.Errorf("expected 4 of 5 documents indexed in 3 batches, got %+v", res)
+	}
+	if !reflect.DeepEqual(progress, []uint64{2, 4, 5}) {
+		t.Errorf("expected progress [2 4 5], got %v", progress)
+	}
+	count, err := dst.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("expected 4 documents, got %d", count)
+	}
+	doc, err := dst.Document("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc != nil {
+		t.Errorf("expected document 2 to be left out")
+	}
+
+	search := func(q Query) uint64 {
+		res, err := dst.Search(NewSearchRequest(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Total
+	}
+	if n := search(NewTermQuery("steve yen").SetField("name")); n != 1 {
+		t.Errorf("expected 1 document named steve yen, got %d", n)
+	}
+	if n := search(NewTermQuery("steve").SetField("name")); n != 0 {
+		t.Errorf("expected no document for the term steve, got %d", n)
+	}
+	if n := search(NewMatchQuery("reindex").SetField("source")); n != 4 {
+		t.Errorf("expected 4 copied documents, got %d", n)
+	}
+
+	val, err := dst.GetInternal(reindexInternalKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != nil {
+		t.Errorf("expected the last id to be removed, got %s", val)
+	}
+
+	// a resumed reindex starts after the last id copied
+	resumed, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := resumed.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = resumed.SetInternal(reindexInternalKey, []byte("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = Reindex(src, resumed, &ReindexOptions{Resume: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Processed != 2 || res.Indexed != 2 || res.Batches != 1 {
+		t.Errorf("expected 2 documents indexed in 1 batch, got %+v", res)
+	}
+	doc, err = resumed.Document("3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc == nil {
+		t.Errorf("expected document 3 to be copied")
+	}
+}
+
+func TestDeleteByQuery(t *testing.T) {
+	index, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	batch := index.NewBatch()
+	for i := 0; i < 2500; i++ {
+		name := "marty"
+		if i%2 == 1 {
+			name = "steve"
+		}
+		err = batch.Index(strconv.Itoa(i), map[string]interface{}{"name": name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = index.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel := make(chan struct{})
+	close(cancel)
+	deleted, err := index.DeleteByQuery(NewMatchQuery("marty").SetField("name"), cancel)
+	if err != ErrorCanceled || deleted != 0 {
+		t.Errorf("expected a canceled delete, got %d deleted and %v", deleted, err)
+	}
+
+	deleted, err = index.DeleteByQuery(NewMatchQuery("marty").SetField("name"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1250 {
+		t.Errorf("expected 1250 documents deleted, got %d", deleted)
+	}
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1250 {
+		t.Errorf("expected 1250 documents left, got %d", count)
+	}
+	res, err := index.Search(NewSearchRequest(NewMatchQuery("marty").SetField("name")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 0 {
+		t.Errorf("expected no documents named marty, got %d", res.Total)
+	}
+
+	// documents deleted before their batch are not counted
+	ids, err := index.(*indexImpl).existingIDs([]string{"0", "1", "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"1"}) {
+		t.Errorf("expected only 1 to exist, got %v", ids)
+	}
+	deleted, err = index.DeleteByQuery(NewMatchAllQuery(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1250 {
+		t.Errorf("expected 1250 documents deleted, got %d", deleted)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	sweepInterval := Config.TTLSweepInterval
+	Config.TTLSweepInterval = 10 * time.Millisecond
+	defer func() {
+		Config.TTLSweepInterval = sweepInterval
+	}()
+
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	mapping := NewIndexMapping()
+	mapping.TTLField = "expires"
+	index, err := NewUsing("testidx", mapping, Config.DefaultIndexType, gtreap.Name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// the mem store does not isolate readers from the sweep
+	memIndex, err := New("", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memIndex.(*indexImpl).ttlStop != nil {
+		t.Errorf("expected no sweeper for a memory only index")
+	}
+	err = memIndex.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	docs := map[string]interface{}{
+		"expired": map[string]interface{}{"name": "a", "expires": now.Add(-time.Hour)},
+		"later":   map[string]interface{}{"name": "b", "expires": now.Add(time.Hour)},
+		"never":   map[string]interface{}{"name": "c"},
+	}
+	for id, doc := range docs {
+		err = index.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		doc, err := index.Document("expired")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the expired document to be deleted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 documents left, got %d", count)
+	}
+}
+
+func TestSeq(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	index, err := New("testidx", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeq := func(id string, expected uint64) {
+		seq, err := index.Seq(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != expected {
+			t.Errorf("expected %s to have seq %d, got %d", id, expected, seq)
+		}
+	}
+
+	err = index.Index("a", map[string]interface{}{"name": "marty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeq("a", 1)
+	checkSeq("b", 0)
+
+	_, err = index.IndexIfSeq("a", map[string]interface{}{"name": "steve"}, 0)
+	if err != ErrorSeqConflict {
+		t.Errorf("expected ErrorSeqConflict creating an existing document, got %v", err)
+	}
+	seq, err := index.IndexIfSeq("a", map[string]interface{}{"name": "steve"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 2 {
+		t.Errorf("expected seq 2, got %d", seq)
+	}
+	_, err = index.IndexIfSeq("a", map[string]interface{}{"name": "marty"}, 1)
+	if err != ErrorSeqConflict {
+		t.Errorf("expected ErrorSeqConflict with a stale seq, got %v", err)
+	}
+	seq, err = index.IndexIfSeq("b", map[string]interface{}{"name": "marty"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 3 {
+		t.Errorf("expected seq 3, got %d", seq)
+	}
+
+	err = index.DeleteIfSeq("b", 2)
+	if err != ErrorSeqConflict {
+		t.Errorf("expected ErrorSeqConflict deleting with a stale seq, got %v", err)
+	}
+	err = index.DeleteIfSeq("b", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeq("b", 0)
+	doc, err := index.Document("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc != nil {
+		t.Errorf("expected b to be deleted")
+	}
+
+	// sequence numbers keep increasing after reopening
+	err = index.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err = Open("testidx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	batch := index.NewBatch()
+	err = batch.Index("c", map[string]interface{}{"name": "marty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSeq("a", 2)
+	checkSeq("c", 4)
+}
+
+func TestSeqConcurrentWrites(t *testing.T) {
+	idx, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for d := 0; d < 20; d++ {
+				err := idx.Index(fmt.Sprintf("%d-%d", w, d), map[string]interface{}{"name": "marty"})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	seen := make(map[uint64]bool)
+	for w := 0; w < 8; w++ {
+		for d := 0; d < 20; d++ {
+			seq, err := idx.Seq(fmt.Sprintf("%d-%d", w, d))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seq == 0 || seq > 160 || seen[seq] {
+				t.Errorf("expected a new sequence number up to 160, got %d", seq)
+			}
+			seen[seq] = true
+		}
+	}
+}
+
+func TestInternalKeyReserved(t *testing.T) {
+	idx, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	err = idx.Index("a", map[string]interface{}{"name": "marty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.SetInternal(docSeqInternalKey("a"), encodeSeq(7))
+	if err != ErrorInternalKeyReserved {
+		t.Errorf("expected ErrorInternalKeyReserved, got %v", err)
+	}
+	err = idx.DeleteInternal(docSeqInternalKey("a"))
+	if err != ErrorInternalKeyReserved {
+		t.Errorf("expected ErrorInternalKeyReserved, got %v", err)
+	}
+	batch := idx.NewBatch()
+	batch.SetInternal(seqInternalKey, nil)
+	err = idx.Batch(batch)
+	if err != ErrorInternalKeyReserved {
+		t.Errorf("expected ErrorInternalKeyReserved, got %v", err)
+	}
+
+	// user keys do not touch sequence numbers
+	err = idx.SetInternal([]byte("_seq:a"), []byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := idx.Seq("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 1 {
+		t.Errorf("expected sequence number 1, got %d", seq)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	index, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	checkName := func(expected string) {
+		doc, err := index.Document("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := ""
+		if doc != nil {
+			name, _ = DocumentFields(index.Mapping(), doc)["name"].(string)
+		}
+		if name != expected {
+			t.Errorf("expected name %q, got %q", expected, name)
+		}
+	}
+
+	err = index.IndexVersion("a", map[string]interface{}{"name": "marty"}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []uint64{4, 5} {
+		err = index.IndexVersion("a", map[string]interface{}{"name": "steve"}, version)
+		if err != ErrorVersionConflict {
+			t.Errorf("expected ErrorVersionConflict for version %d, got %v", version, err)
+		}
+	}
+	checkName("marty")
+
+	err = index.DeleteVersion("a", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkName("")
+
+	// replaying an older write does not bring back a
+	// deleted document
+	batch := index.NewBatch()
+	err = batch.IndexVersion("a", map[string]interface{}{"name": "steve"}, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Batch(batch)
+	if verr, ok := err.(*VersionConflictError); !ok || !reflect.DeepEqual(verr.Versions, map[string]uint64{"a": 7}) {
+		t.Errorf("expected a version conflict for a, got %v", err)
+	}
+	checkName("")
+	version, err := index.Version("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 7 {
+		t.Errorf("expected version 7, got %d", version)
+	}
+
+	batch.Reset()
+	err = batch.IndexVersion("a", map[string]interface{}{"name": "steve"}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkName("steve")
+
+	// a stale operation is skipped, the others of its batch
+	// are executed
+	batch.Reset()
+	err = batch.IndexVersion("a", map[string]interface{}{"name": "marty"}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = batch.IndexVersion("b", map[string]interface{}{"name": "marty"}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = batch.Index("c", map[string]interface{}{"name": "marty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Batch(batch)
+	if verr, ok := err.(*VersionConflictError); !ok || !reflect.DeepEqual(verr.Versions, map[string]uint64{"a": 8}) {
+		t.Errorf("expected a version conflict for a, got %v", err)
+	}
+	checkName("steve")
+	for _, id := range []string{"b", "c"} {
+		doc, err := index.Document(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc == nil {
+			t.Errorf("expected %s to be indexed", id)
+		}
+	}
+	version, err = index.Version("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 {
+		t.Errorf("expected version 3, got %d", version)
+	}
+}
+
+func TestBulkIndexer(t *testing.T) {
+	index, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	bulk := NewBulkIndexer(index, 4, 100, 10)
+	wg := sync.WaitGroup{}
+	for p := 0; p < 3; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := p; i < 1500; i += 3 {
+				id := strconv.Itoa(i)
+				err := bulk.Index(id, map[string]interface{}{"name": "marty"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if i%5 == 0 {
+					// a later update of the same document wins
+					err = bulk.Index(id, map[string]interface{}{"name": "steve"})
+					if err != nil {
+						t.Error(err)
+						return
+					}
+				}
+				if i%10 == 0 {
+					err = bulk.Delete(id)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+	err = bulk.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bulk.Count() != 1500+300+150 {
+		t.Errorf("expected 1950 operations, got %d", bulk.Count())
+	}
+	err = bulk.Index("x", map[string]interface{}{"name": "marty"})
+	if err != ErrorIndexClosed {
+		t.Errorf("expected ErrorIndexClosed after close, got %v", err)
+	}
+
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1350 {
+		t.Errorf("expected 1350 documents, got %d", count)
+	}
+	res, err := index.Search(NewSearchRequest(NewMatchQuery("steve").SetField("name")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 150 {
+		t.Errorf("expected 150 documents updated, got %d", res.Total)
+	}
+}
+
+func TestRefreshInterval(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	index, err := NewUsing("testidx", NewIndexMapping(), segmented.Name, gtreap.Name, map[string]interface{}{
+		"refresh_interval": "-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	searchCount := func() uint64 {
+		res, err := index.Search(NewSearchRequest(NewMatchAllQuery()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Total
+	}
+
+	err = index.Index("a", map[string]interface{}{"name": "marty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := searchCount(); count != 0 {
+		t.Errorf("expected no documents before a refresh, got %d", count)
+	}
+	err = index.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := searchCount(); count != 1 {
+		t.Errorf("expected 1 document after a refresh, got %d", count)
+	}
+
+	err = index.SetRefreshInterval(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Index("b", map[string]interface{}{"name": "steve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for searchCount() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2 documents after the refresh interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	err = index.SetRefreshInterval(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Delete("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := searchCount(); count != 1 {
+		t.Errorf("expected the delete to be visible immediately, got %d documents", count)
+	}
+
+	other, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := other.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = other.SetRefreshInterval(RefreshManual)
+	if err != ErrorRefreshUnsupported {
+		t.Errorf("expected ErrorRefreshUnsupported, got %v", err)
+	}
+	err = other.Refresh()
+	if err != nil {
+		t.Errorf("expected refresh to succeed, got %v", err)
+	}
+}
+
+func TestNumericRangePrecisionStep(t *testing.T) {
+	numberMapping := NewNumericFieldMapping()
+	numberMapping.PrecisionStep = 8
+	dateMapping := NewDateTimeFieldMapping()
+	dateMapping.PrecisionStep = 16
+	docMapping := NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("fine", NewNumericFieldMapping())
+	docMapping.AddFieldMappingsAt("coarse", numberMapping)
+	docMapping.AddFieldMappingsAt("when", dateMapping)
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping = docMapping
+
+	index, err := New("", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := index.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	batch := index.NewBatch()
+	for i := 0; i < 1000; i++ {
+		err = batch.Index(strconv.Itoa(i), map[string]interface{}{
+			"fine":   float64(i * 1000),
+			"coarse": float64(i * 1000),
+			"when":   start.Add(time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = index.Batch(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	searchCount := func(q Query) uint64 {
+		res, err := index.Search(NewSearchRequest(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Total
+	}
+	min, max := float64(1500), float64(750000)
+	for _, field := range []string{"fine", "coarse"} {
+		q := NewNumericRangeQuery(&min, &max)
+		q.SetField(field)
+		if count := searchCount(q); count != 748 {
+			t.Errorf("expected 748 hits for %s, got %d", field, count)
+		}
+	}
+	from := start.Add(10 * time.Hour).Format(time.RFC3339)
+	to := start.Add(500 * time.Hour).Format(time.RFC3339)
+	q := NewDateRangeQuery(&from, &to)
+	q.SetField("when")
+	if count := searchCount(q); count != 490 {
+		t.Errorf("expected 490 hits for the date range, got %d", count)
+	}
+
+	numberMapping.PrecisionStep = 65
+	err = mapping.validate()
+	if err == nil {
+		t.Errorf("expected a precision step over 64 to be invalid")
+	}
+}
+
+func TestFieldIndexOptions(t *testing.T) {
+	tagMapping := NewTextFieldMapping()
+	tagMapping.IndexOptions = IndexDocs
+	titleMapping := NewTextFieldMapping()
+	titleMapping.IndexOptions = IndexPositions
+	docMapping := NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("tag", tagMapping)
+	docMapping.AddFieldMappingsAt("title", titleMapping)
+	docMapping.AddFieldMappingsAt("body", NewTextFieldMapping())
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping = docMapping
+
+	for _, indexType := range []string{upside_down.Name, segmented.Name} {
+		func() {
+			defer func() {
+				err := os.RemoveAll("testidx")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			idx, err := NewUsing("testidx", mapping, indexType, gtreap.Name, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				err := idx.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			err = idx.Index("a", map[string]interface{}{
+				"tag":   "golang golang golang",
+				"title": "quick brown fox",
+				"body":  "quick brown fox",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			termFieldDoc := func(term, field string) *index.TermFieldDoc {
+				i, _, err := idx.Advanced()
+				if err != nil {
+					t.Fatal(err)
+				}
+				reader, err := i.Reader()
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() {
+					err := reader.Close()
+					if err != nil {
+						t.Fatal(err)
+					}
+				}()
+				tfr, err := reader.TermFieldReader([]byte(term), field)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() {
+					err := tfr.Close()
+					if err != nil {
+						t.Fatal(err)
+					}
+				}()
+				tfd, err := tfr.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tfd == nil {
+					t.Fatalf("%s: expected %s in %s", indexType, term, field)
+				}
+				return tfd
+			}
+
+			tag := termFieldDoc("golang", "tag")
+			if tag.Freq != 1 || len(tag.Vectors) != 0 {
+				t.Errorf("%s: expected only the document for tag, got freq %d and %d vectors", indexType, tag.Freq, len(tag.Vectors))
+			}
+			title := termFieldDoc("brown", "title")
+			if len(title.Vectors) != 1 || title.Vectors[0].Pos != 2 || title.Vectors[0].Start != 0 || title.Vectors[0].End != 0 {
+				t.Errorf("%s: expected positions without offsets for title, got %+v", indexType, title.Vectors)
+			}
+			body := termFieldDoc("brown", "body")
+			if len(body.Vectors) != 1 || body.Vectors[0].Pos != 2 || body.Vectors[0].Start != 6 || body.Vectors[0].End != 11 {
+				t.Errorf("%s: expected positions and offsets for body, got %+v", indexType, body.Vectors)
+			}
+
+			// positions are enough for phrases
+			res, err := idx.Search(NewSearchRequest(NewMatchPhraseQuery("quick brown").SetField("title")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Total != 1 {
+				t.Errorf("%s: expected a phrase match on title, got %d", indexType, res.Total)
+			}
+		}()
+	}
+
+	tagMapping.IndexOptions = "everything"
+	err := mapping.validate()
+	if err == nil {
+		t.Errorf("expected unknown index options to be invalid")
+	}
+}
+
+func TestFieldTermVector(t *testing.T) {
+	mapping := NewIndexMapping()
+	for field, termVector := range map[string]string{
+		"tag":     TermVectorNo,
+		"summary": TermVectorYes,
+		"title":   TermVectorWithPositions,
+		"body":    TermVectorWithPositionsOffsets,
+	} {
+		fieldMapping := NewTextFieldMapping()
+		fieldMapping.TermVector = termVector
+		mapping.DefaultMapping.AddFieldMappingsAt(field, fieldMapping)
+	}
+
+	for _, indexType := range []string{upside_down.Name, segmented.Name} {
+		func() {
+			defer func() {
+				err := os.RemoveAll("testidx")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			idx, err := NewUsing("testidx", mapping, indexType, gtreap.Name, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				err := idx.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			text := "quick brown fox"
+			err = idx.Index("a", map[string]interface{}{
+				"tag":     text,
+				"summary": text,
+				"title":   text,
+				"body":    text,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			i, _, err := idx.Advanced()
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader, err := i.Reader()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				err := reader.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+			vectors := func(field string) []*index.TermFieldVector {
+				tfr, err := reader.TermFieldReader([]byte("brown"), field)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() {
+					err := tfr.Close()
+					if err != nil {
+						t.Fatal(err)
+					}
+				}()
+				tfd, err := tfr.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tfd == nil {
+					t.Fatalf("%s: expected brown in %s", indexType, field)
+				}
+				return tfd.Vectors
+			}
+
+			if tag := vectors("tag"); len(tag) != 0 {
+				t.Errorf("%s: expected no vectors for tag, got %+v", indexType, tag)
+			}
+			if summary := vectors("summary"); len(summary) != 1 || summary[0].Pos != 0 || summary[0].End != 0 {
+				t.Errorf("%s: expected the term only for summary, got %+v", indexType, summary)
+			}
+			if title := vectors("title"); len(title) != 1 || title[0].Pos != 2 || title[0].End != 0 {
+				t.Errorf("%s: expected positions without offsets for title, got %+v", indexType, title)
+			}
+			if body := vectors("body"); len(body) != 1 || body[0].Pos != 2 || body[0].Start != 6 || body[0].End != 11 {
+				t.Errorf("%s: expected positions and offsets for body, got %+v", indexType, body)
+			}
+		}()
+	}
+
+	fieldMapping := mapping.DefaultMapping.Properties["title"].Fields[0]
+	fieldMapping.TermVector = "with_everything"
+	if err := mapping.validate(); err == nil {
+		t.Errorf("expected an unknown term vector to be invalid")
+	}
+	fieldMapping.TermVector = TermVectorYes
+	fieldMapping.IndexOptions = IndexOffsets
+	if err := mapping.validate(); err == nil {
+		t.Errorf("expected a term vector with index options offsets to be invalid")
+	}
+}
+
+func TestMultiFields(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	mapping := NewIndexMapping()
+	err := mapping.AddCustomTokenFilter("prefixes", map[string]interface{}{
+		"type": edge_ngram_filter.Name,
+		"min":  1.0,
+		"max":  5.0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mapping.AddCustomAnalyzer("autocomplete", map[string]interface{}{
+		"type":          custom_analyzer.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": []interface{}{lower_case_filter.Name, "prefixes"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawMapping := NewTextFieldMapping()
+	rawMapping.Analyzer = keyword_analyzer.Name
+	rawMapping.Store = false
+	autocompleteMapping := NewTextFieldMapping()
+	autocompleteMapping.Analyzer = "autocomplete"
+	autocompleteMapping.Store = false
+	titleMapping := NewTextFieldMapping()
+	titleMapping.Fields = map[string]*FieldMapping{
+		"raw":          rawMapping,
+		"autocomplete": autocompleteMapping,
+	}
+	mapping.DefaultMapping.AddFieldMappingsAt("title", titleMapping)
+
+	idx, err := New("testidx", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = idx.Index("a", map[string]interface{}{"title": "Quick Brown Fox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Index("b", map[string]interface{}{"title": "Quick"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query Query
+		hits  []string
+	}{
+		{query: NewMatchQuery("quick").SetField("title"), hits: []string{"a", "b"}},
+		{query: NewMatchQuery("Quick Brown Fox").SetField("title.raw"), hits: []string{"a"}},
+		{query: NewTermQuery("quick").SetField("title.raw"), hits: nil},
+		{query: NewTermQuery("bro").SetField("title.autocomplete"), hits: []string{"a"}},
+		{query: NewMatchQuery("brown"), hits: []string{"a"}},
+	}
+	for _, test := range tests {
+		res, err := idx.Search(NewSearchRequest(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hits []string
+		for _, hit := range res.Hits {
+			hits = append(hits, hit.ID)
+		}
+		sort.Strings(hits)
+		if !reflect.DeepEqual(hits, test.hits) {
+			t.Errorf("query %v: expected hits %v, got %v", test.query, test.hits, hits)
+		}
+	}
+
+	// _all has the value once, not once per sub-field
+	doc, err := idx.Document("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := 0
+	for _, f := range doc.Fields {
+		if strings.HasPrefix(f.Name(), "title") {
+			stored++
+		}
+	}
+	if stored != 1 {
+		t.Errorf("expected only title to be stored, got %d fields", stored)
+	}
+	res, err := idx.Search(NewSearchRequest(NewTermQuery("b").SetField("_all")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 0 {
+		t.Errorf("expected the prefixes not to be in _all, got %d hits", res.Total)
+	}
+}
+
+func TestNestedQuery(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	commentsMapping := NewDocumentMapping()
+	commentsMapping.Nested = true
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping.AddSubDocumentMapping("comments", commentsMapping)
+
+	idx, err := New("testidx", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = idx.Index("a", map[string]interface{}{
+		"title": "first",
+		"comments": []interface{}{
+			map[string]interface{}{"author": "alice", "text": "great"},
+			map[string]interface{}{"author": "bob", "text": "awful"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Index("b", map[string]interface{}{
+		"title": "second",
+		"comments": []interface{}{
+			map[string]interface{}{"author": "alice", "text": "awful"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := idx.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 documents, got %d", count)
+	}
+	err = idx.Index("c"+nestedSeparator+"comments", map[string]interface{}{"title": "third"})
+	if err != ErrorNestedSeparatorInID {
+		t.Errorf("expected ErrorNestedSeparatorInID, got %v", err)
+	}
+
+	aliceAwful := func() Query {
+		return NewNestedQuery("comments", NewConjunctionQuery([]Query{
+			NewTermQuery("alice").SetField("comments.author"),
+			NewTermQuery("awful").SetField("comments.text"),
+		}))
+	}
+	search := func(query Query) []string {
+		res, err := idx.Search(NewSearchRequest(query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hits []string
+		for _, hit := range res.Hits {
+			hits = append(hits, hit.ID)
+		}
+		sort.Strings(hits)
+		return hits
+	}
+	tests := []struct {
+		query Query
+		hits  []string
+	}{
+		{query: aliceAwful(), hits: []string{"b"}},
+		{query: NewNestedQuery("comments", NewTermQuery("alice").SetField("comments.author")), hits: []string{"a", "b"}},
+		{query: NewConjunctionQuery([]Query{aliceAwful(), NewTermQuery("second").SetField("title")}), hits: []string{"b"}},
+		{query: NewTermQuery("alice").SetField("comments.author"), hits: nil},
+		{query: NewMatchAllQuery(), hits: []string{"a", "b"}},
+	}
+	for _, test := range tests {
+		hits := search(test.query)
+		if !reflect.DeepEqual(hits, test.hits) {
+			t.Errorf("query %v: expected hits %v, got %v", test.query, test.hits, hits)
+		}
+	}
+
+	// fewer comments drop the nested documents of the others
+	err = idx.Index("a", map[string]interface{}{
+		"title": "first",
+		"comments": []interface{}{
+			map[string]interface{}{"author": "carol", "text": "awful"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits := search(NewNestedQuery("comments", NewTermQuery("bob").SetField("comments.author")))
+	if hits != nil {
+		t.Errorf("expected no hits for a removed comment, got %v", hits)
+	}
+	hits = search(NewNestedQuery("comments", NewTermQuery("awful").SetField("comments.text")))
+	if !reflect.DeepEqual(hits, []string{"a", "b"}) {
+		t.Errorf("expected hits [a b], got %v", hits)
+	}
+
+	query, err := ParseQuery([]byte(`{"nested":"comments","query":{"term":"carol","field":"comments.author"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits = search(query)
+	if !reflect.DeepEqual(hits, []string{"a"}) {
+		t.Errorf("expected hits [a], got %v", hits)
+	}
+
+	// updates and reindexing keep the nested sections
+	_, err = idx.UpdateByQuery(&UpdateByQueryRequest{
+		Query:  NewMatchAllQuery(),
+		Update: PatchFields(map[string]interface{}{"title": "updated"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits = search(aliceAwful())
+	if !reflect.DeepEqual(hits, []string{"b"}) {
+		t.Errorf("expected hits [b] after the update, got %v", hits)
+	}
+	doc, err := idx.Document("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFields := map[string]interface{}{
+		"title": "updated",
+		"comments": []interface{}{
+			map[string]interface{}{"author": "alice", "text": "awful"},
+		},
+	}
+	if fields := DocumentFields(idx.Mapping(), doc); !reflect.DeepEqual(fields, expectedFields) {
+		t.Errorf("expected %v, got %v", expectedFields, fields)
+	}
+
+	dst, err := New("", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dst.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	res, err := Reindex(idx, dst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Indexed != 2 {
+		t.Errorf("expected 2 documents reindexed, got %d", res.Indexed)
+	}
+	sr, err := dst.Search(NewSearchRequest(aliceAwful()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sr.Total != 1 || sr.Hits[0].ID != "b" {
+		t.Errorf("expected the reindexed b to match, got %v", sr.Hits)
+	}
+}
+
+func TestFlattenedField(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	labelsMapping := NewFlattenedFieldMapping()
+	labelsMapping.Store = true
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping.AddFieldMappingsAt("labels", labelsMapping)
+
+	idx, err := New("testidx", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = idx.Index("a", map[string]interface{}{
+		"labels": map[string]interface{}{
+			"env":  "Prod",
+			"team": map[string]interface{}{"name": "search", "size": 4.0},
+			"tags": []interface{}{"blue", "green"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Index("b", map[string]interface{}{
+		"labels": map[string]interface{}{
+			"env":  "staging",
+			"name": "search",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query Query
+		hits  []string
+	}{
+		{query: NewTermQuery("search").SetField("labels"), hits: []string{"a", "b"}},
+		{query: NewTermQuery("search").SetField("labels.team.name"), hits: []string{"a"}},
+		{query: NewTermQuery("search").SetField("labels.name"), hits: []string{"b"}},
+		{query: NewMatchQuery("Prod").SetField("labels.env"), hits: []string{"a"}},
+		{query: NewTermQuery("prod").SetField("labels.env"), hits: nil},
+		{query: NewTermQuery("4").SetField("labels.team.size"), hits: []string{"a"}},
+		{query: NewTermQuery("green").SetField("labels.tags"), hits: []string{"a"}},
+		{query: NewPrefixQuery("stag").SetField("labels.env"), hits: []string{"b"}},
+		{query: NewMatchQuery("search"), hits: nil},
+	}
+	for _, test := range tests {
+		res, err := idx.Search(NewSearchRequest(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hits []string
+		for _, hit := range res.Hits {
+			hits = append(hits, hit.ID)
+		}
+		sort.Strings(hits)
+		if !reflect.DeepEqual(hits, test.hits) {
+			t.Errorf("query %v: expected hits %v, got %v", test.query, test.hits, hits)
+		}
+	}
+
+	doc, err := idx.Document("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Fields) != 1 || string(doc.Fields[0].Value()) != `{"env":"staging","name":"search"}` {
+		t.Errorf("expected the object stored as JSON, got %v", doc.Fields)
+	}
+}
+
+func TestRuntimeFields(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	RegisterRuntimeFunction("test_full_name", func(sources map[string][]interface{}) []interface{} {
+		if len(sources["first"]) == 0 || len(sources["last"]) == 0 {
+			return nil
+		}
+		return []interface{}{sources["first"][0].(string) + " " + sources["last"][0].(string)}
+	})
+	RegisterRuntimeFunction("test_total", func(sources map[string][]interface{}) []interface{} {
+		if len(sources["price"]) == 0 || len(sources["qty"]) == 0 {
+			return nil
+		}
+		return []interface{}{sources["price"][0].(float64) * sources["qty"][0].(float64)}
+	})
+
+	mapping := NewIndexMapping()
+	qtyMapping := NewNumericFieldMapping()
+	qtyMapping.Store = false
+	qtyMapping.DocValues = true
+	mapping.DefaultMapping.AddFieldMappingsAt("qty", qtyMapping)
+	mapping.AddRuntimeField("full_name", NewRuntimeFieldMapping("text", "test_full_name", "first", "last"))
+	mapping.AddRuntimeField("total", NewRuntimeFieldMapping("number", "test_total", "price", "qty"))
+
+	idx, err := New("testidx", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	docs := map[string]map[string]interface{}{
+		"a": {"first": "Ada", "last": "Lovelace", "price": 10.0, "qty": 3.0},
+		"b": {"first": "Alan", "last": "Turing", "price": 2.0, "qty": 5.0},
+		"c": {"first": "Grace", "last": "Hopper", "price": 50.0, "qty": 1.0},
+		"d": {"first": "Ada", "last": "Lovelace"},
+	}
+	for id, doc := range docs {
+		err = idx.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	min, max := 12.0, 40.0
+	tests := []struct {
+		query Query
+		hits  []string
+	}{
+		{query: NewTermQuery("Ada Lovelace").SetField("full_name"), hits: []string{"a", "d"}},
+		{query: NewPrefixQuery("Gr").SetField("full_name"), hits: []string{"c"}},
+		{query: NewNumericRangeQuery(&min, &max).SetField("total"), hits: []string{"a"}},
+		{query: NewNumericRangeQuery(&min, nil).SetField("total"), hits: []string{"a", "c"}},
+	}
+	for _, test := range tests {
+		res, err := idx.Search(NewSearchRequest(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hits []string
+		for _, hit := range res.Hits {
+			hits = append(hits, hit.ID)
+		}
+		sort.Strings(hits)
+		if !reflect.DeepEqual(hits, test.hits) {
+			t.Errorf("query %v: expected hits %v, got %v", test.query, test.hits, hits)
+		}
+	}
+
+	// qty only has doc values
+	req := NewSearchRequest(NewMatchAllQuery())
+	req.Sort = "total"
+	req.AddFacet("names", NewFacetRequest("full_name", 10))
+	res, err := idx.Search(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hits []string
+	for _, hit := range res.Hits {
+		hits = append(hits, hit.ID)
+	}
+	if !reflect.DeepEqual(hits[:3], []string{"b", "a", "c"}) {
+		t.Errorf("expected hits sorted by total [b a c ...], got %v", hits)
+	}
+	names := res.Facets["names"]
+	if names == nil || len(names.Terms) != 3 || names.Terms[0].Term != "Ada Lovelace" || names.Terms[0].Count != 2 {
+		t.Errorf("expected 3 names with Ada Lovelace twice, got %v", names)
+	}
+
+	mapping.AddRuntimeField("missing", NewRuntimeFieldMapping("text", "test_missing", "first"))
+	err = mapping.validate()
+	if err == nil {
+		t.Errorf("expected an error for an unregistered runtime function")
+	}
+	delete(mapping.RuntimeFields, "missing")
+}
+
+func TestDateFormats(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	mapping := NewIndexMapping()
+	err := mapping.AddCustomDateTimeParser("us_date", map[string]interface{}{
+		"type":    flexible_go.Name,
+		"layouts": []interface{}{"01/02/2006"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	whenMapping := NewDateTimeFieldMapping()
+	whenMapping.DateFormats = []string{datetime_optional.Name, "us_date", epoch.MillisName}
+	mapping.DefaultMapping.AddFieldMappingsAt("when", whenMapping)
+
+	idx, err := New("testidx", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	docs := map[string]interface{}{
+		"a": "2014-08-03",
+		"b": "08/04/2014",
+		"c": 1407196800000.0,
+		"d": "1407283200000",
+	}
+	for id, when := range docs {
+		err = idx.Index(id, map[string]interface{}{"when": when})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a date no format parses is left out
+	err = idx.Index("e", map[string]interface{}{"when": "last week"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the endpoints are parsed with the formats of the field
+	start, end := "08/04/2014", "1407283200001"
+	res, err := idx.Search(NewSearchRequest(NewDateRangeQuery(&start, &end).SetField("when")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hits []string
+	for _, hit := range res.Hits {
+		hits = append(hits, hit.ID)
+	}
+	sort.Strings(hits)
+	if !reflect.DeepEqual(hits, []string{"b", "c", "d"}) {
+		t.Errorf("expected hits [b c d], got %v", hits)
+	}
+}
+
+func TestGeoShape(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping.AddFieldMappingsAt("area", NewGeoShapeFieldMapping())
+
+	idx, err := New("testidx", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	docs := map[string]interface{}{
+		"park": "POLYGON ((0 0, 4 0, 4 4, 0 4, 0 0))",
+		"road": map[string]interface{}{
+			"type":        "LineString",
+			"coordinates": []interface{}{[]interface{}{-2.0, 2.0}, []interface{}{10.0, 2.0}},
+		},
+		"cafe": map[string]interface{}{
+			"type":        "Point",
+			"coordinates": []interface{}{1.0, 1.0},
+		},
+		"farm": "POLYGON ((20 20, 30 20, 30 30, 20 30, 20 20))",
+	}
+	for id, area := range docs {
+		err = idx.Index(id, map[string]interface{}{"area": area})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = idx.Index("lost", map[string]interface{}{"area": "POLYGON ((0 0"})
+	if err == nil {
+		t.Errorf("expected a malformed shape to fail")
+	}
+
+	tests := []struct {
+		shape    interface{}
+		relation string
+		expected []string
+	}{
+		{"POLYGON ((-1 -1, 5 -1, 5 5, -1 5, -1 -1))", "", []string{"cafe", "park", "road"}},
+		{"POLYGON ((-1 -1, 5 -1, 5 5, -1 5, -1 -1))", geo.Within, []string{"cafe", "park"}},
+		{"POINT (3 3)", geo.Contains, []string{"park"}},
+		{map[string]interface{}{
+			"type":        "envelope",
+			"coordinates": []interface{}{[]interface{}{-5.0, 10.0}, []interface{}{15.0, -5.0}},
+		}, geo.Disjoint, []string{"farm"}},
+	}
+	for _, test := range tests {
+		q := NewGeoShapeQuery(test.shape, test.relation).SetField("area")
+		res, err := idx.Search(NewSearchRequest(q))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hits []string
+		for _, hit := range res.Hits {
+			hits = append(hits, hit.ID)
+		}
+		sort.Strings(hits)
+		if !reflect.DeepEqual(hits, test.expected) {
+			t.Errorf("expected %v hits %v for %v, got %v", test.relation, test.expected, test.shape, hits)
+		}
+	}
+
+	q, err := ParseQuery([]byte(`{"geo_shape":"POINT (1 1)","relation":"contains","field":"area"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := idx.Search(NewSearchRequest(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 2 {
+		t.Errorf("expected 2 shapes containing the point, got %d", res.Total)
+	}
+	err = NewGeoShapeQuery("POINT (1 1)", "overlaps").Validate()
+	if err == nil {
+		t.Errorf("expected an unknown relation to be invalid")
+	}
+}
+
+func TestIPField(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping.AddFieldMappingsAt("addr", NewIPFieldMapping())
+
+	idx, err := New("testidx", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	docs := map[string]interface{}{
+		"a": "10.0.0.1",
+		"b": "10.1.255.255",
+		"c": "192.168.1.20",
+		"d": "2001:db8::1",
+		"e": "2001:db9::1",
+		"f": []interface{}{"127.0.0.1", "::1"},
+	}
+	for id, addr := range docs {
+		err = idx.Index(id, map[string]interface{}{"addr": addr})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = idx.Index("g", map[string]interface{}{"addr": "10.0.0.256"})
+	if err == nil {
+		t.Errorf("expected an invalid address to fail")
+	}
+
+	tests := []struct {
+		cidr     string
+		expected []string
+	}{
+		{"10.0.0.0/8", []string{"a", "b"}},
+		{"10.0.0.0/16", []string{"a"}},
+		{"192.168.0.0/16", []string{"c"}},
+		{"0.0.0.0/0", []string{"a", "b", "c", "f"}},
+		{"2001:db8::/32", []string{"d"}},
+		{"::1", []string{"f"}},
+		{"10.1.255.255", []string{"b"}},
+	}
+	for _, test := range tests {
+		res, err := idx.Search(NewSearchRequest(NewIPRangeQuery(test.cidr).SetField("addr")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hits []string
+		for _, hit := range res.Hits {
+			hits = append(hits, hit.ID)
+		}
+		sort.Strings(hits)
+		if !reflect.DeepEqual(hits, test.expected) {
+			t.Errorf("expected hits %v for %s, got %v", test.expected, test.cidr, hits)
+		}
+	}
+
+	q, err := ParseQuery([]byte(`{"cidr":"2001:db8::/31","field":"addr"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := idx.Search(NewSearchRequest(q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 2 {
+		t.Errorf("expected 2 addresses in the block, got %d", res.Total)
+	}
+	err = NewIPRangeQuery("10.0.0.0/33").Validate()
+	if err == nil {
+		t.Errorf("expected an invalid block to be invalid")
+	}
+
+	doc, err := idx.Document("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range doc.Fields {
+		if field.Name() == "addr" && string(field.Value()) != "192.168.1.20" {
+			t.Errorf("expected the address to be stored as it is, got %q", field.Value())
+		}
+	}
+}
+
+func TestFieldAliases(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	nameMapping := NewTextFieldMapping()
+	nameMapping.Analyzer = "keyword"
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping.AddFieldMappingsAt("name", nameMapping)
+	mapping.DefaultMapping.AddFieldMappingsAt("price", NewNumericFieldMapping())
+	mapping.DefaultMapping.AddFieldMappingsAt("title", NewAliasFieldMapping("name"))
+	mapping.DefaultMapping.AddFieldMappingsAt("cost", NewAliasFieldMapping("price"))
+
+	idx, err := New("testidx", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	docs := map[string]map[string]interface{}{
+		"a": {"name": "Blue Pen", "price": 3.0},
+		"b": {"name": "Red Pen", "price": 1.0},
+		"c": {"name": "Blue Pen", "price": 7.0},
+	}
+	for id, doc := range docs {
+		err = idx.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	min := 2.0
+	tests := []struct {
+		query Query
+		hits  []string
+	}{
+		// the match query analyzes with the analyzer of name
+		{query: NewMatchQuery("Blue Pen").SetField("title"), hits: []string{"a", "c"}},
+		{query: NewPrefixQuery("Re").SetField("title"), hits: []string{"b"}},
+		{query: NewNumericRangeQuery(&min, nil).SetField("cost"), hits: []string{"a", "c"}},
+	}
+	for _, test := range tests {
+		res, err := idx.Search(NewSearchRequest(test.query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hits []string
+		for _, hit := range res.Hits {
+			hits = append(hits, hit.ID)
+		}
+		sort.Strings(hits)
+		if !reflect.DeepEqual(hits, test.hits) {
+			t.Errorf("query %v: expected hits %v, got %v", test.query, test.hits, hits)
+		}
+	}
+
+	req := NewSearchRequest(NewMatchAllQuery())
+	req.Sort = "cost"
+	req.AddFacet("titles", NewFacetRequest("title", 10))
+	res, err := idx.Search(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hits []string
+	for _, hit := range res.Hits {
+		hits = append(hits, hit.ID)
+	}
+	if !reflect.DeepEqual(hits, []string{"b", "a", "c"}) {
+		t.Errorf("expected hits sorted by cost [b a c], got %v", hits)
+	}
+	titles := res.Facets["titles"]
+	if titles == nil || titles.Field != "title" || len(titles.Terms) != 2 || titles.Terms[0].Term != "Blue Pen" || titles.Terms[0].Count != 2 {
+		t.Errorf("expected 2 titles with Blue Pen twice, got %v", titles)
+	}
+
+	mapping.DefaultMapping.AddFieldMappingsAt("label", NewAliasFieldMapping("title"))
+	err = mapping.validate()
+	if err == nil {
+		t.Errorf("expected an alias of an alias to be invalid")
+	}
+	mapping.DefaultMapping.Properties["label"].Fields[0].Path = "missing"
+	err = mapping.validate()
+	if err == nil {
+		t.Errorf("expected an alias of an unmapped field to be invalid")
+	}
+}
+
+func TestFieldSimilarity(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	mapping := NewIndexMapping()
+	tagsMapping := NewTextFieldMapping()
+	tagsMapping.Similarity = scorers.BooleanSimilarityName
+	mapping.DefaultMapping.AddFieldMappingsAt("tags", tagsMapping)
+	bodyMapping := NewTextFieldMapping()
+	bodyMapping.Similarity = scorers.BM25SimilarityName
+	mapping.DefaultMapping.AddFieldMappingsAt("body", bodyMapping)
+	mapping.DefaultMapping.AddFieldMappingsAt("title", NewTextFieldMapping())
+
+	idx, err := New("testidx", mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := idx.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	docs := map[string]map[string]interface{}{
+		"a": {"tags": "go", "body": "go go go search", "title": "go go go search"},
+		"b": {"tags": "go go", "body": "go", "title": "go"},
+		"c": {"tags": "rust", "body": "rust search", "title": "rust search"},
+	}
+	for id, doc := range docs {
+		err = idx.Index(id, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	termSearch := func(field string) *SearchResult {
+		res, err := idx.Search(NewSearchRequest(NewTermQuery("go").SetField(field)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Hits) != 2 {
+			t.Fatalf("expected 2 hits in %s, got %d", field, len(res.Hits))
+		}
+		return res
+	}
+
+	// every matching tag counts the same
+	res := termSearch("tags")
+	if res.Hits[0].Score != res.Hits[1].Score {
+		t.Errorf("expected equal scores for tags, got %f and %f", res.Hits[0].Score, res.Hits[1].Score)
+	}
+
+	// bm25 saturates the frequency slower than the classic
+	// norm shrinks it
+	res = termSearch("body")
+	if res.Hits[0].ID != "a" {
+		t.Errorf("expected a first in body, got %s", res.Hits[0].ID)
+	}
+	res = termSearch("title")
+	if res.Hits[0].ID != "b" {
+		t.Errorf("expected b first in title, got %s", res.Hits[0].ID)
+	}
+
+	// bm25 normalizes by the average length of the field,
+	// which is found once until the index changes
+	impl := idx.(*indexImpl)
+	generation := impl.fieldLengths.current()
+	indexReader, err := impl.i.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bm25, ok := newSimilarityIndexReader(indexReader, mapping, &impl.fieldLengths, generation).Similarity("body").(*scorers.BM25Similarity)
+	if !ok || math.Abs(bm25.AvgFieldLength-7.0/3.0) > 0.01 {
+		t.Errorf("expected an average body length of 7/3, got %v", bm25)
+	}
+	err = indexReader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length, ok := impl.fieldLengths.get(generation, "body"); !ok || length != bm25.AvgFieldLength {
+		t.Errorf("expected the average body length to be cached, got %f", length)
+	}
+	err = idx.Index("d", map[string]interface{}{"body": "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := impl.fieldLengths.get(impl.fieldLengths.current(), "body"); ok {
+		t.Errorf("expected the average body length to be dropped by a change")
+	}
+
+	bodyMapping.Similarity = "bm52"
+	errs, ok := mapping.Validate().(MappingErrors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected one mapping error, got %v", mapping.Validate())
+	}
+	if errs[0].Option != "similarity" || len(errs[0].Suggestions) != 1 || errs[0].Suggestions[0] != scorers.BM25SimilarityName {
+		t.Errorf("expected an unknown similarity suggesting bm25, got %v", errs[0])
+	}
+}
+
+func TestConjunctionDocSets(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// the segmented index answers with DocSets, the
+	// results have to be those of the default index
+	segmentedIndex, err := NewUsing("testidx", NewIndexMapping(), segmented.Name, gtreap.Name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultIndex, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexes := []Index{segmentedIndex, defaultIndex}
+	defer func() {
+		for _, index := range indexes {
+			err := index.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}()
+
+	for _, index := range indexes {
+		batch := index.NewBatch()
+		for i := 0; i < 600; i++ {
+			doc := map[string]interface{}{
+				"type":  "log",
+				"level": "info",
+			}
+			if i%3 == 0 {
+				doc["type"] = "metric"
+			}
+			if i%7 == 0 {
+				doc["level"] = "error"
+			} else if i%5 == 0 {
+				doc["level"] = "warn"
+			}
+			err = batch.Index(fmt.Sprintf("%03d", i), doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = index.Batch(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 600; i += 13 {
+			err = index.Delete(fmt.Sprintf("%03d", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	term := func(term, field string) Query {
+		return NewTermQuery(term).SetField(field)
+	}
+	queries := []Query{
+		NewConjunctionQuery([]Query{term("log", "type"), term("error", "level")}),
+		NewConjunctionQuery([]Query{
+			term("log", "type"),
+			NewDisjunctionQuery([]Query{term("error", "level"), term("warn", "level")}),
+		}),
+		NewConjunctionQuery([]Query{term("metric", "type"), NewMatchQuery("warn").SetField("level")}),
+		NewConjunctionQuery([]Query{term("log", "type"), term("missing", "level")}),
+	}
+	for i, q := range queries {
+		var expected []string
+		for _, index := range indexes {
+			req := NewSearchRequest(q)
+			req.Size = 1000
+			res, err := index.Search(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0, len(res.Hits))
+			for _, hit := range res.Hits {
+				ids = append(ids, hit.ID)
+			}
+			sort.Strings(ids)
+			if expected == nil {
+				expected = ids
+			} else if !reflect.DeepEqual(ids, expected) {
+				t.Errorf("query %d: expected %v, got %v", i, expected, ids)
+			}
+		}
+		if i < 3 && len(expected) == 0 {
+			t.Errorf("query %d: expected hits", i)
+		}
+	}
+}
+
+func TestTermExpansions(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// the segmented index walks its dictionaries with
+	// automata, the terms have to be those the default
+	// index finds
+	segmentedIndex, err := NewUsing("testidx", NewIndexMapping(), segmented.Name, gtreap.Name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultIndex, err := New("", NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexes := []Index{segmentedIndex, defaultIndex}
+	defer func() {
+		for _, index := range indexes {
+			err := index.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}()
+
+	words := []string{"water", "waters", "watter", "wafer", "later", "wander",
+		"cater", "catalog", "concat", "ware", "waterfall", "über", "uber"}
+	for _, index := range indexes {
+		for i, word := range words {
+			err = index.Index(strconv.Itoa(i), map[string]interface{}{"name": word})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	prefixed := NewFuzzyQuery("water")
+	prefixed.SetPrefix(2)
+	prefixed.SetFuzziness(2)
+	queries := []Query{
+		NewFuzzyQuery("water").SetFuzziness(1),
+		NewFuzzyQuery("water").SetFuzziness(2),
+		prefixed,
+		NewFuzzyQuery("uber"),
+		NewRegexpQuery("wat.*"),
+		NewRegexpQuery("[cl]at.*r"),
+		NewRegexpQuery("w.*r$"),
+		NewPrefixQuery("wa"),
+	}
+	for i, q := range queries {
+		var expected []string
+		for _, index := range indexes {
+			req := NewSearchRequest(q)
+			req.Size = len(words)
+			res, err := index.Search(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0, len(res.Hits))
+			for _, hit := range res.Hits {
+				ids = append(ids, hit.ID)
+			}
+			sort.Strings(ids)
+			if expected == nil {
+				expected = ids
+			} else if !reflect.DeepEqual(ids, expected) {
+				t.Errorf("query %d: expected %v, got %v", i, expected, ids)
+			}
+		}
+		if len(expected) == 0 {
+			t.Errorf("query %d: expected hits", i)
+		}
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	for _, indexType := range []string{upside_down.Name, segmented.Name} {
+		func() {
+			defer func() {
+				err := os.RemoveAll("testidx")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			index, err := NewUsing("testidx", NewIndexMapping(), indexType, Config.DefaultKVStore, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = index.Index("a", map[string]interface{}{"name": "marty"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = index.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// several readers share the index
+			readers := make([]Index, 2)
+			for i := range readers {
+				readers[i], err = OpenReadOnly("testidx")
+				if err != nil {
+					t.Fatalf("%s: %v", indexType, err)
+				}
+			}
+			for _, reader := range readers {
+				res, err := reader.Search(NewSearchRequest(NewTermQuery("marty")))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Total != 1 {
+					t.Errorf("%s: expected 1 hit, got %d", indexType, res.Total)
+				}
+				err = reader.Index("b", map[string]interface{}{"name": "steve"})
+				if err != ErrorIndexReadOnly {
+					t.Errorf("%s: expected read only error, got %v", indexType, err)
+				}
+				err = reader.SetInternal([]byte("k"), []byte("v"))
+				if err != ErrorIndexReadOnly {
+					t.Errorf("%s: expected read only error, got %v", indexType, err)
+				}
+			}
+			for _, reader := range readers {
+				err = reader.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			names, err := filepath.Glob(filepath.Join("testidx", "index", "*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if indexType == segmented.Name && len(names) != 1 {
+				t.Errorf("%s: expected the segment to be kept, got %v", indexType, names)
+			}
+		}()
+	}
+
+	// the memory stores cannot be shared with other readers
+	defer func() {
+		err := os.RemoveAll("testidx")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	index, err := NewUsing("testidx", NewIndexMapping(), upside_down.Name, gtreap.Name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenReadOnly("testidx")
+	if err != ErrorReadOnlyUnsupported {
+		t.Errorf("expected read only unsupported for %s, got %v", gtreap.Name, err)
+	}
+}
+
+func TestCheckIndex(t *testing.T) {
+	for _, indexType := range []string{upside_down.Name, segmented.Name} {
+		func() {
+			defer func() {
+				err := os.RemoveAll("testidx")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			index, err := NewUsing("testidx", NewIndexMapping(), indexType, Config.DefaultKVStore, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = index.Index("a", map[string]interface{}{"name": "marty"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := CheckIndex(index, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Problems) != 0 {
+				t.Errorf("%s: expected no problems, got %v", indexType, result.Problems)
+			}
+			err = index.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			index, err = OpenReadOnly("testidx")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				err := index.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}()
+			result, err = CheckIndex(index, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Problems) != 0 {
+				t.Errorf("%s: expected no problems, got %v", indexType, result.Problems)
+			}
+			_, err = CheckIndex(index, true)
+			if err != ErrorIndexReadOnly {
+				t.Errorf("%s: expected read only error, got %v", indexType, err)
+			}
+		}()
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	defer func() {
+		for _, path := range []string{"testidx", "testidx2"} {
+			err := os.RemoveAll(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}()
+
+	// without term vectors the rows are the same in version
+	// 5, only the version row marks the index as outdated
+	fm := NewTextFieldMapping()
+	fm.IncludeTermVectors = false
+	fm.IncludeInAll = false
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping.AddFieldMappingsAt("name", fm)
+	index, err := NewUsing("testidx", mapping, upside_down.Name, Config.DefaultKVStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Index("a", map[string]interface{}{"name": "marty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, kvstore, err := index.Advanced()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kvwriter, err := kvstore.Writer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = kvwriter.Set(upside_down.VersionKey, upside_down.NewVersionRow(5).Value())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = kvwriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = index.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Open("testidx")
+	if err != ErrorIndexUpgradeRequired {
+		t.Fatalf("expected upgrade required, got %v", err)
+	}
+
+	search := func(path string) {
+		index, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			err := index.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}()
+		res, err := index.Search(NewSearchRequest(NewTermQuery("marty").SetField("name")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != 1 {
+			t.Errorf("%s: expected 1 hit, got %d", path, res.Total)
+		}
+	}
+
+	// the copy is upgraded, the original left as it was
+	err = UpgradeCopy("testidx", "testidx2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	search("testidx2")
+	_, err = Open("testidx")
+	if err != ErrorIndexUpgradeRequired {
+		t.Fatalf("expected upgrade required, got %v", err)
+	}
+	err = UpgradeCopy("testidx", "testidx2")
+	if err != ErrorIndexPathExists {
+		t.Errorf("expected path exists, got %v", err)
+	}
+
+	err = Upgrade("testidx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	search("testidx")
+}
@@ -0,0 +1,348 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The kinds of MappingChange
+const (
+	MappingAdded   = "added"
+	MappingRemoved = "removed"
+	MappingChanged = "changed"
+)
+
+// A MappingChange is a difference between two mappings.
+// Path is the section of the mapping the change is in, like
+// "types.post", empty for the options of the index mapping
+// itself.  Field names the field or sub-section within, and
+// Option the option which changed.  Reindex tells whether
+// the documents indexed with the old mapping have to be
+// indexed again to be what the new mapping would index, new
+// fields and search time options like runtime fields and
+// aliases do not need it.
+type MappingChange struct {
+	Kind    string      `json:"kind"`
+	Path    string      `json:"path"`
+	Field   string      `json:"field,omitempty"`
+	Option  string      `json:"option,omitempty"`
+	Old     interface{} `json:"old,omitempty"`
+	New     interface{} `json:"new,omitempty"`
+	Reindex bool        `json:"reindex"`
+}
+
+func (c *MappingChange) String() string {
+	rv := c.Path
+	for _, name := range []string{c.Field, c.Option} {
+		if name != "" {
+			if rv != "" {
+				rv += " "
+			}
+			rv += name
+		}
+	}
+	rv += " " + c.Kind
+	if c.Kind == MappingChanged {
+		rv += fmt.Sprintf(" from %v to %v", c.Old, c.New)
+	}
+	if c.Reindex {
+		rv += ", needs reindexing"
+	}
+	return rv
+}
+
+// A MappingDiff is the list of changes from one mapping to
+// another, see DiffMappings
+type MappingDiff []*MappingChange
+
+// DiffMappings compares the mapping of an index with the
+// mapping it should have instead
+func DiffMappings(from, to *IndexMapping) MappingDiff {
+	var rv MappingDiff
+	rv.options("", "", from, to, map[string]bool{
+		"types":             true,
+		"default_mapping":   true,
+		"analysis":          true,
+		"dynamic_templates": true,
+		"runtime_fields":    true,
+	}, map[string]bool{
+		"byte_array_converter": true,
+	})
+	rv.analysis(from.CustomAnalysis, to.CustomAnalysis)
+	if !reflect.DeepEqual(from.DynamicTemplates, to.DynamicTemplates) {
+		rv = append(rv, &MappingChange{
+			Kind:    MappingChanged,
+			Option:  "dynamic_templates",
+			Old:     from.DynamicTemplates,
+			New:     to.DynamicTemplates,
+			Reindex: true,
+		})
+	}
+	for _, name := range unionKeys(from.RuntimeFields, to.RuntimeFields) {
+		rv.compare("runtime_fields", name, "", from.RuntimeFields[name], to.RuntimeFields[name], false)
+	}
+
+	rv.document("default_mapping", from.DefaultMapping, to.DefaultMapping)
+	for _, name := range unionKeys(from.TypeMapping, to.TypeMapping) {
+		path := "types." + name
+		fromMapping, toMapping := from.TypeMapping[name], to.TypeMapping[name]
+		if fromMapping == nil || toMapping == nil {
+			// the documents of the type move from or to the
+			// default mapping
+			rv.compare(path, "", "", fromMapping, toMapping, true)
+			continue
+		}
+		rv.document(path, fromMapping, toMapping)
+	}
+	return rv
+}
+
+// Compatible tells whether the documents indexed with the
+// old mapping are still what the new mapping would index
+func (d MappingDiff) Compatible() bool {
+	for _, change := range d {
+		if change.Reindex {
+			return false
+		}
+	}
+	return true
+}
+
+// compare adds a change when from and to differ, either of
+// them can be nil
+func (d *MappingDiff) compare(path, field, option string, from, to interface{}, reindex bool) {
+	fromValue, toValue := reflect.ValueOf(from), reflect.ValueOf(to)
+	fromNil := !fromValue.IsValid() || (fromValue.Kind() == reflect.Ptr && fromValue.IsNil())
+	toNil := !toValue.IsValid() || (toValue.Kind() == reflect.Ptr && toValue.IsNil())
+	change := &MappingChange{
+		Path:    path,
+		Field:   field,
+		Option:  option,
+		Reindex: reindex,
+	}
+	switch {
+	case fromNil && toNil:
+		return
+	case fromNil:
+		change.Kind = MappingAdded
+		change.New = to
+	case toNil:
+		change.Kind = MappingRemoved
+		change.Old = from
+	case reflect.DeepEqual(from, to):
+		return
+	default:
+		change.Kind = MappingChanged
+		change.Old = from
+		change.New = to
+	}
+	*d = append(*d, change)
+}
+
+// options compares the options of two structs of the same
+// type by their JSON names, leaving out the skipped ones.
+// Changes of the compatible options need no reindexing.
+func (d *MappingDiff) options(path, field string, from, to interface{}, skip, compatible map[string]bool) {
+	fromValue := reflect.Indirect(reflect.ValueOf(from))
+	toValue := reflect.Indirect(reflect.ValueOf(to))
+	typ := fromValue.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || skip[name] {
+			continue
+		}
+		fromOption, toOption := fromValue.Field(i).Interface(), toValue.Field(i).Interface()
+		if !reflect.DeepEqual(fromOption, toOption) {
+			*d = append(*d, &MappingChange{
+				Kind:    MappingChanged,
+				Path:    path,
+				Field:   field,
+				Option:  name,
+				Old:     fromOption,
+				New:     toOption,
+				Reindex: !compatible[name],
+			})
+		}
+	}
+}
+
+// analysis compares the custom analysis, a changed
+// definition changes what the fields using it index
+func (d *MappingDiff) analysis(from, to *customAnalysis) {
+	if from == nil {
+		from = newCustomAnalysis()
+	}
+	if to == nil {
+		to = newCustomAnalysis()
+	}
+	kinds := []struct {
+		name     string
+		from, to map[string]map[string]interface{}
+	}{
+		{"char_filters", from.CharFilters, to.CharFilters},
+		{"tokenizers", from.Tokenizers, to.Tokenizers},
+		{"token_maps", from.TokenMaps, to.TokenMaps},
+		{"token_filters", from.TokenFilters, to.TokenFilters},
+		{"analyzers", from.Analyzers, to.Analyzers},
+		{"date_time_parsers", from.DateTimeParsers, to.DateTimeParsers},
+	}
+	for _, kind := range kinds {
+		for _, name := range unionKeys(kind.from, kind.to) {
+			fromConfig, toConfig := kind.from[name], kind.to[name]
+			d.compare("analysis."+kind.name, name, "", fromConfig, toConfig, fromConfig != nil && toConfig != nil)
+		}
+	}
+}
+
+// document compares the sections and fields of two
+// document mappings
+func (d *MappingDiff) document(path string, from, to *DocumentMapping) {
+	fromSections, fromFields := from.flatten()
+	toSections, toFields := to.flatten()
+	for _, name := range unionKeys(fromSections, toSections) {
+		// a missing section is mapped like a new one
+		fromSection, toSection := fromSections[name], toSections[name]
+		if fromSection == nil {
+			fromSection = NewDocumentMapping()
+		}
+		if toSection == nil {
+			toSection = NewDocumentMapping()
+		}
+		d.options(path, name, fromSection, toSection, map[string]bool{
+			"properties": true,
+			"fields":     true,
+		}, map[string]bool{
+			"dynamic": true,
+			"strict":  true,
+		})
+	}
+	for _, name := range unionKeys(fromFields, toFields) {
+		fromField, toField := fromFields[name], toFields[name]
+		if fromField == nil || toField == nil {
+			d.compare(path, name, "", fromField, toField, false)
+			continue
+		}
+		d.options(path, name, fromField, toField, map[string]bool{
+			"name":   true,
+			"fields": true,
+		}, map[string]bool{
+			"path":       true,
+			"similarity": true,
+		})
+	}
+}
+
+// flatten returns the sections of the mapping by their path,
+// the mapping itself under "", and its fields and sub-fields
+// by their names
+func (dm *DocumentMapping) flatten() (map[string]*DocumentMapping, map[string]*FieldMapping) {
+	sections := make(map[string]*DocumentMapping)
+	fields := make(map[string]*FieldMapping)
+	if dm == nil {
+		return sections, fields
+	}
+	var addField func(name string, field *FieldMapping)
+	addField = func(name string, field *FieldMapping) {
+		if _, ok := fields[name]; ok {
+			// several fields of one property with the same
+			// name only differ by type
+			name = fmt.Sprintf("%s (%s)", name, field.Type)
+		}
+		fields[name] = field
+		for subName, sub := range field.Fields {
+			addField(name+pathSeparator+subName, sub)
+		}
+	}
+	var walk func(path []string, section *DocumentMapping)
+	walk = func(path []string, section *DocumentMapping) {
+		sections[encodePath(path)] = section
+		for name, sub := range section.Properties {
+			subPath := append(path[:len(path):len(path)], name)
+			for _, field := range sub.Fields {
+				addField(getFieldName(encodePath(subPath), subPath, field), field)
+			}
+			walk(subPath, sub)
+		}
+	}
+	walk(nil, dm)
+	return sections, fields
+}
+
+// unionKeys returns the keys of both maps in order
+func unionKeys(maps ...interface{}) []string {
+	seen := make(map[string]bool)
+	var rv []string
+	for _, m := range maps {
+		for _, key := range reflect.ValueOf(m).MapKeys() {
+			if !seen[key.String()] {
+				seen[key.String()] = true
+				rv = append(rv, key.String())
+			}
+		}
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// A ReindexPlan says what to do for an index to have a new
+// mapping.  An index keeps the mapping it was created with,
+// so the documents have to go into a new index with the new
+// mapping, which then replaces the old index in the
+// IndexAlias searches go through.  Types are the document
+// types which index differently, unless All do, and Fields
+// are the fields which changed.
+type ReindexPlan struct {
+	All    bool     `json:"all"`
+	Types  []string `json:"types,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+	Steps  []string `json:"steps"`
+}
+
+// ReindexPlan returns the plan to get the documents indexed
+// with the new mapping, or nil when the diff is Compatible
+func (d MappingDiff) ReindexPlan() *ReindexPlan {
+	if d.Compatible() {
+		return nil
+	}
+	rv := &ReindexPlan{}
+	types := make(map[string]bool)
+	fields := make(map[string]bool)
+	for _, change := range d {
+		if !change.Reindex {
+			continue
+		}
+		if strings.HasPrefix(change.Path, "types.") {
+			types[strings.TrimPrefix(change.Path, "types.")] = true
+		} else {
+			// the index options, the analysis and the default
+			// mapping can change any document
+			rv.All = true
+		}
+		if change.Field != "" && !strings.HasPrefix(change.Path, "analysis.") {
+			fields[change.Field] = true
+		}
+	}
+	rv.Fields = unionKeys(fields)
+	reindex := "index all the documents into the new index"
+	if !rv.All {
+		rv.Types = unionKeys(types)
+		reindex += ", only the documents of the types " + strings.Join(rv.Types, ", ") + " index differently"
+	}
+	rv.Steps = []string{
+		"create an index with the new mapping",
+		reindex,
+		"swap the new index for the old one in the index alias and close the old index",
+	}
+	return rv
+}
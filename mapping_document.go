@@ -13,9 +13,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"time"
 
-	"github.com/blevesearch/bleve/registry"
+	"github.com/blevesearch/bleve/analysis/analyzers/keyword_analyzer"
+	"github.com/blevesearch/bleve/document"
 )
 
 // A DocumentMapping describes how a type of document
@@ -31,51 +33,55 @@ import (
 // If not explicitly mapped, default mapping operations
 // are used.  To disable this automatic handling, set
 // Dynamic to false.
+// The elements of a Nested sub-section are indexed as
+// hidden documents of their own, so that a NestedQuery can
+// match the conditions within one element, see
+// NewNestedQuery.  They are left out of the document
+// itself unless IncludeInParent is set.  Nested sections
+// within a nested section are not nested again.
+// A document with a value without an explicit mapping
+// anywhere in a Strict section fails to index, instead of
+// indexing the value with the defaults.  In JSON a strict
+// section can also have "dynamic": "strict".
 type DocumentMapping struct {
 	Enabled         bool                        `json:"enabled"`
 	Dynamic         bool                        `json:"dynamic"`
+	Strict          bool                        `json:"strict,omitempty"`
 	Properties      map[string]*DocumentMapping `json:"properties,omitempty"`
 	Fields          []*FieldMapping             `json:"fields,omitempty"`
 	DefaultAnalyzer string                      `json:"default_analyzer"`
+	Nested          bool                        `json:"nested,omitempty"`
+	IncludeInParent bool                        `json:"include_in_parent,omitempty"`
 }
 
-func (dm *DocumentMapping) validate(cache *registry.Cache) error {
-	var err error
+func (dm *DocumentMapping) validate(v *mappingValidator, path string) {
 	if dm.DefaultAnalyzer != "" {
-		_, err := cache.AnalyzerNamed(dm.DefaultAnalyzer)
-		if err != nil {
-			return err
-		}
+		v.analyzer(path, "default_analyzer", dm.DefaultAnalyzer)
 	}
-	for _, property := range dm.Properties {
-		err = property.validate(cache)
-		if err != nil {
-			return err
-		}
+	names := make([]string, 0, len(dm.Properties))
+	for name := range dm.Properties {
+		names = append(names, name)
 	}
-	for _, field := range dm.Fields {
-		if field.Analyzer != "" {
-			_, err = cache.AnalyzerNamed(field.Analyzer)
-			if err != nil {
-				return err
-			}
-		}
-		if field.DateFormat != "" {
-			_, err = cache.DateTimeParserNamed(field.DateFormat)
-			if err != nil {
-				return err
-			}
-		}
-		switch field.Type {
-		case "text", "datetime", "number":
-		default:
-			return fmt.Errorf("unknown field type: '%s'", field.Type)
-		}
+	sort.Strings(names)
+	for _, name := range names {
+		dm.Properties[name].validate(v, path+".properties."+name)
+	}
+	for i, field := range dm.Fields {
+		field.validate(v, fmt.Sprintf("%s.fields[%d]", path, i))
 	}
-	return nil
 }
 
 func (dm *DocumentMapping) analyzerNameForPath(path string) string {
+	if field := dm.fieldMappingForPath(path); field != nil {
+		return field.Analyzer
+	}
+	return ""
+}
+
+// fieldMappingForPath returns the field mapping of the
+// field at path, or nil if it has none.  The last element
+// of path can name a sub-field.
+func (dm *DocumentMapping) fieldMappingForPath(path string) *FieldMapping {
 	pathElements := decodePath(path)
 	last := false
 	current := dm
@@ -86,24 +92,35 @@ OUTER:
 		}
 		for name, subDocMapping := range current.Properties {
 			for _, field := range subDocMapping.Fields {
-				if field.Name == "" && name == pathElement {
+				if (field.Name == "" && name == pathElement) || field.Name == pathElement {
 					if last {
-						return field.Analyzer
+						return field
 					}
-					current = subDocMapping
-					continue OUTER
-				} else if field.Name == pathElement {
-					if last {
-						return field.Analyzer
+					if sub, ok := field.Fields[pathElements[i+1]]; ok && i == len(pathElements)-2 {
+						return sub
 					}
 					current = subDocMapping
 					continue OUTER
 				}
 			}
 		}
-		return ""
+		return nil
+	}
+	return nil
+}
+
+// aliases adds the aliases of the mapping at path to rv,
+// by the name of the alias
+func (dm *DocumentMapping) aliases(path []string, rv map[string]string) {
+	for name, subDocMapping := range dm.Properties {
+		subPath := append(path[:len(path):len(path)], name)
+		for _, field := range subDocMapping.Fields {
+			if field.Type == "alias" {
+				rv[getFieldName(encodePath(subPath), subPath, field)] = field.Path
+			}
+		}
+		subDocMapping.aliases(subPath, rv)
 	}
-	return ""
 }
 
 func (dm *DocumentMapping) documentMappingForPath(path string) *DocumentMapping {
@@ -197,10 +214,13 @@ func (dm *DocumentMapping) AddFieldMapping(fm *FieldMapping) {
 func (dm *DocumentMapping) UnmarshalJSON(data []byte) error {
 	var tmp struct {
 		Enabled         *bool                       `json:"enabled"`
-		Dynamic         *bool                       `json:"dynamic"`
+		Dynamic         interface{}                 `json:"dynamic"`
+		Strict          bool                        `json:"strict"`
 		Properties      map[string]*DocumentMapping `json:"properties"`
 		Fields          []*FieldMapping             `json:"fields"`
 		DefaultAnalyzer string                      `json:"default_analyzer"`
+		Nested          bool                        `json:"nested"`
+		IncludeInParent bool                        `json:"include_in_parent"`
 	}
 	err := json.Unmarshal(data, &tmp)
 	if err != nil {
@@ -213,11 +233,24 @@ func (dm *DocumentMapping) UnmarshalJSON(data []byte) error {
 	}
 
 	dm.Dynamic = true
-	if tmp.Dynamic != nil {
-		dm.Dynamic = *tmp.Dynamic
+	dm.Strict = tmp.Strict
+	switch dynamic := tmp.Dynamic.(type) {
+	case nil:
+	case bool:
+		dm.Dynamic = dynamic
+	case string:
+		if dynamic != "strict" {
+			return fmt.Errorf("unknown dynamic mapping '%s'", dynamic)
+		}
+		dm.Dynamic = false
+		dm.Strict = true
+	default:
+		return fmt.Errorf("dynamic must be a bool or \"strict\"")
 	}
 
 	dm.DefaultAnalyzer = tmp.DefaultAnalyzer
+	dm.Nested = tmp.Nested
+	dm.IncludeInParent = tmp.IncludeInParent
 
 	if tmp.Properties != nil {
 		dm.Properties = make(map[string]*DocumentMapping, len(tmp.Properties))
@@ -308,9 +341,43 @@ func (dm *DocumentMapping) processProperty(property interface{}, path []string,
 		return
 	}
 
+	if subDocMapping != nil && subDocMapping.Nested && context.nested == "" {
+		dm.processNested(property, path, context)
+		if !subDocMapping.IncludeInParent {
+			return
+		}
+		// the elements of an array come through here again
+		context.nested = pathString
+		defer func() {
+			context.nested = ""
+		}()
+	}
+
 	propertyValue := reflect.ValueOf(property)
-	if !propertyValue.IsValid() {
-		// cannot do anything with the zero value
+	if !propertyValue.IsValid() || (propertyValue.Kind() == reflect.Ptr && propertyValue.IsNil()) {
+		// a null is only indexed as the null value of its
+		// fields
+		if subDocMapping != nil {
+			for _, fieldMapping := range subDocMapping.Fields {
+				fieldMapping.processNull(pathString, path, indexes, context)
+			}
+		}
+		return
+	}
+
+	if subDocMapping == nil && dm.strictForPath(path) {
+		if context.err == nil {
+			context.err = fmt.Errorf("field '%s' has no mapping in a strict mapping", pathString)
+		}
+		return
+	}
+
+	if objectFields := subDocMapping.objectFields(); len(objectFields) > 0 {
+		// the whole value goes into the fields taking
+		// objects
+		for _, fieldMapping := range objectFields {
+			fieldMapping.processObject(property, pathString, path, indexes, context)
+		}
 		return
 	}
 	propertyType := propertyValue.Type()
@@ -329,7 +396,14 @@ func (dm *DocumentMapping) processProperty(property interface{}, path []string,
 			dateTimeParser := context.im.dateTimeParserNamed(context.im.DefaultDateTimeParser)
 			if dateTimeParser != nil {
 				parsedDateTime, err := dateTimeParser.ParseDateTime(propertyValueString)
+				typ := DynamicDateTime
 				if err != nil {
+					typ = DynamicString
+				}
+				if fieldMapping := context.im.dynamicFieldMapping(path, typ); fieldMapping != nil {
+					// index as the template says
+					fieldMapping.processString(propertyValueString, pathString, path, indexes, context)
+				} else if err != nil {
 					// index as text
 					fieldMapping := NewTextFieldMapping()
 					fieldMapping.processString(propertyValueString, pathString, path, indexes, context)
@@ -349,7 +423,10 @@ func (dm *DocumentMapping) processProperty(property interface{}, path []string,
 			}
 		} else {
 			// automatic indexing behavior
-			fieldMapping := NewNumericFieldMapping()
+			fieldMapping := context.im.dynamicFieldMapping(path, DynamicNumber)
+			if fieldMapping == nil {
+				fieldMapping = NewNumericFieldMapping()
+			}
 			fieldMapping.processFloat64(propertyValFloat, pathString, path, indexes, context)
 		}
 	case reflect.Struct:
@@ -362,7 +439,10 @@ func (dm *DocumentMapping) processProperty(property interface{}, path []string,
 					fieldMapping.processTime(property, pathString, path, indexes, context)
 				}
 			} else {
-				fieldMapping := NewDateTimeFieldMapping()
+				fieldMapping := context.im.dynamicFieldMapping(path, DynamicDateTime)
+				if fieldMapping == nil {
+					fieldMapping = NewDateTimeFieldMapping()
+				}
 				fieldMapping.processTime(property, pathString, path, indexes, context)
 			}
 		default:
@@ -372,3 +452,95 @@ func (dm *DocumentMapping) processProperty(property interface{}, path []string,
 		dm.walkDocument(property, path, indexes, context)
 	}
 }
+
+// strictForPath tells whether the value at path is in a
+// strict section
+func (dm *DocumentMapping) strictForPath(path []string) bool {
+	current := dm
+	for _, pathElement := range path {
+		if current.Strict {
+			return true
+		}
+		current = current.Properties[pathElement]
+		if current == nil {
+			return false
+		}
+	}
+	return current.Strict
+}
+
+// processNested indexes each element of the nested section
+// at path as a document of its own
+func (dm *DocumentMapping) processNested(property interface{}, path []string, context *walkContext) {
+	pathString := encodePath(path)
+	elements := []interface{}{property}
+	val := reflect.ValueOf(property)
+	if val.Kind() == reflect.Slice || val.Kind() == reflect.Array {
+		elements = make([]interface{}, 0, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			if val.Index(i).CanInterface() {
+				elements = append(elements, val.Index(i).Interface())
+			}
+		}
+	}
+	analyzer := context.im.analyzerNamed(keyword_analyzer.Name)
+	for i, element := range elements {
+		nested := document.NewDocument(nestedDocID(context.doc.ID, pathString, i))
+		nested.AddField(document.NewTextFieldCustom(nestedPathField, []uint64{}, []byte(pathString), document.IndexField, analyzer))
+		nestedContext := context.im.newWalkContext(nested, context.dm)
+		nestedContext.nested = pathString
+		dm.processProperty(element, path, []uint64{}, nestedContext)
+		if context.err == nil {
+			context.err = nestedContext.err
+		}
+		context.doc.AddNested(nested)
+	}
+}
+
+// objectFields returns the fields of the mapping taking
+// whole objects, the flattened and geo_shape fields
+func (dm *DocumentMapping) objectFields() []*FieldMapping {
+	if dm == nil {
+		return nil
+	}
+	var rv []*FieldMapping
+	for _, field := range dm.Fields {
+		if field.Type == "flattened" || field.Type == "geo_shape" {
+			rv = append(rv, field)
+		}
+	}
+	return rv
+}
+
+// unstoredFields adds the fields of the section which are
+// indexed but not stored to rv by name, sub fields are left
+// out as their values come from their field
+func (dm *DocumentMapping) unstoredFields(path []string, rv map[string]*FieldMapping) {
+	for name, sub := range dm.Properties {
+		if !sub.Enabled {
+			continue
+		}
+		subPath := append(path[:len(path):len(path)], name)
+		for _, field := range sub.Fields {
+			if field.Index && !field.Store {
+				rv[getFieldName(encodePath(subPath), subPath, field)] = field
+			}
+		}
+		sub.unstoredFields(subPath, rv)
+	}
+}
+
+func (dm *DocumentMapping) hasNested() bool {
+	if dm == nil {
+		return false
+	}
+	if dm.Nested {
+		return true
+	}
+	for _, property := range dm.Properties {
+		if property.hasNested() {
+			return true
+		}
+	}
+	return false
+}
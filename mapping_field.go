@@ -10,23 +10,126 @@
 package bleve
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/analyzers/keyword_analyzer"
 	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/geo"
+	"github.com/blevesearch/bleve/search/scorers"
 )
 
 // A FieldMapping describes how a specific item
 // should be put into the index.
+// The value is indexed into each of the CopyTo fields as
+// well, as their own mapping says, so that a field can be
+// built from selected fields only, instead of _all.
+// Each of the Fields is a sub-field indexing the same
+// value another way, the sub-field "raw" of the field
+// "title" is the field "title.raw".
+// Text longer than IgnoreAbove characters is not indexed,
+// only stored, so that long opaque values do not fill the
+// term dictionary.
+// A value a number or datetime field cannot index is left
+// out.  With IgnoreMalformed the name of the field is added
+// to the IgnoredField of the document, with only Coerce the
+// document fails instead.  With Coerce a number field
+// indexes strings holding a number.
+// Dates are parsed with the DateFormat and then each of the
+// DateFormats in order, until one of them can parse the
+// value, with the default date time parser of the mapping
+// when there are none.  Numbers are dates too when one of
+// the formats is epoch_millis or epoch_second.
+// A null value is indexed as the NullValue, a string for
+// text and dates or a float64 for numbers, so that
+// documents with explicit nulls can be found.  Without a
+// NullValue nulls are not indexed.
+// The terms of the field are scored with the Similarity
+// registered by that name in the scorers, like "bm25" for
+// long text or "boolean" for tags, the classic tf-idf when
+// it is empty.
 type FieldMapping struct {
-	Name               string `json:"name,omitempty"`
-	Type               string `json:"type,omitempty"`
-	Analyzer           string `json:"analyzer,omitempty"`
-	Store              bool   `json:"store,omitempty"`
-	Index              bool   `json:"index,omitempty"`
-	IncludeTermVectors bool   `json:"include_term_vectors,omitempty"`
-	IncludeInAll       bool   `json:"include_in_all,omitempty"`
-	DateFormat         string `json:"date_format,omitempty"`
+	Name               string                   `json:"name,omitempty"`
+	Type               string                   `json:"type,omitempty"`
+	Analyzer           string                   `json:"analyzer,omitempty"`
+	Store              bool                     `json:"store,omitempty"`
+	Index              bool                     `json:"index,omitempty"`
+	IncludeTermVectors bool                     `json:"include_term_vectors,omitempty"`
+	IncludePayloads    bool                     `json:"include_payloads,omitempty"`
+	IncludeInAll       bool                     `json:"include_in_all,omitempty"`
+	DocValues          bool                     `json:"docvalues,omitempty"`
+	DateFormat         string                   `json:"date_format,omitempty"`
+	DateFormats        []string                 `json:"date_formats,omitempty"`
+	PrecisionStep      uint                     `json:"precision_step,omitempty"`
+	IndexOptions       string                   `json:"index_options,omitempty"`
+	TermVector         string                   `json:"term_vector,omitempty"`
+	CopyTo             []string                 `json:"copy_to,omitempty"`
+	Fields             map[string]*FieldMapping `json:"fields,omitempty"`
+	IgnoreAbove        int                      `json:"ignore_above,omitempty"`
+	NullValue          interface{}              `json:"null_value,omitempty"`
+	Coerce             bool                     `json:"coerce,omitempty"`
+	IgnoreMalformed    bool                     `json:"ignore_malformed,omitempty"`
+	Path               string                   `json:"path,omitempty"`
+	Similarity         string                   `json:"similarity,omitempty"`
+}
+
+// IgnoredField is the field holding the names of the
+// fields with values left out as malformed, see
+// FieldMapping.IgnoreMalformed
+const IgnoredField = "_ignored"
+
+// The IndexOptions of a field say how much is indexed about
+// its terms.  IndexDocs only records which documents have
+// a term, enough for fields which are only filtered on.
+// IndexFreqs adds the term frequencies for scoring,
+// IndexPositions the positions for phrase queries and
+// IndexOffsets the offsets for highlighting.  Without
+// IndexOptions the frequencies are always indexed, the
+// positions and offsets with IncludeTermVectors.  The
+// payloads of the tokens are kept in the term vectors with
+// IncludePayloads.
+const (
+	IndexDocs      = "docs"
+	IndexFreqs     = "freqs"
+	IndexPositions = "positions"
+	IndexOffsets   = "offsets"
+)
+
+// The TermVector of a field says what its term vectors
+// keep, in place of IncludeTermVectors and IncludePayloads.
+// TermVectorYes keeps only the terms, enough for finding
+// documents like another one, and the positions, offsets
+// and payloads are added for phrase queries, highlighting
+// and payload scoring as the names say.  TermVectorNo
+// leaves the term vectors out.  The TermVector of a field
+// cannot be combined with IndexPositions and IndexOffsets.
+const (
+	TermVectorNo                           = "no"
+	TermVectorYes                          = "yes"
+	TermVectorWithPositions                = "with_positions"
+	TermVectorWithPositionsOffsets         = "with_positions_offsets"
+	TermVectorWithPositionsPayloads        = "with_positions_payloads"
+	TermVectorWithPositionsOffsetsPayloads = "with_positions_offsets_payloads"
+)
+
+// termVectors are the TermVector values with the options
+// they index with
+var termVectors = map[string]document.IndexingOptions{
+	TermVectorNo:                           0,
+	TermVectorYes:                          document.IncludeTermVectors | document.OmitTermPositions | document.OmitTermOffsets,
+	TermVectorWithPositions:                document.IncludeTermVectors | document.OmitTermOffsets,
+	TermVectorWithPositionsOffsets:         document.IncludeTermVectors,
+	TermVectorWithPositionsPayloads:        document.IncludeTermVectors | document.OmitTermOffsets | document.IncludeTermPayloads,
+	TermVectorWithPositionsOffsetsPayloads: document.IncludeTermVectors | document.IncludeTermPayloads,
 }
 
 // NewTextFieldMapping returns a default field mapping for text
@@ -60,6 +163,74 @@ func NewDateTimeFieldMapping() *FieldMapping {
 	}
 }
 
+// NewIPFieldMapping returns a default field mapping for
+// IPv4 and IPv6 addresses.  Each address is indexed as one
+// term sorting like the address, see NewIPRangeQuery for
+// searches by CIDR block.  The addresses are stored as
+// they are and never included in _all.
+func NewIPFieldMapping() *FieldMapping {
+	return &FieldMapping{
+		Type:  "ip",
+		Store: true,
+		Index: true,
+	}
+}
+
+// NewAliasFieldMapping returns a field mapping naming the
+// field at path another way.  Queries, facets and sorts on
+// the alias use the field at path, so that a field can be
+// renamed without indexing the documents again.  Nothing
+// is indexed for the alias itself, and path has to be a
+// mapped or runtime field which is no alias.
+func NewAliasFieldMapping(path string) *FieldMapping {
+	return &FieldMapping{
+		Type: "alias",
+		Path: path,
+	}
+}
+
+// NewBinaryFieldMapping returns a default field mapping
+// for binary values in base64, like attachments or hashes.
+// The values are checked to be base64 and stored as they
+// are, they are never indexed nor included in _all.
+func NewBinaryFieldMapping() *FieldMapping {
+	return &FieldMapping{
+		Type:  "binary",
+		Store: true,
+	}
+}
+
+// NewGeoShapeFieldMapping returns a default field mapping
+// for shapes, GeoJSON objects or text, or WKT, see
+// geo.ParseShape.  The shapes are always stored, a search
+// checks the shapes of the documents it finds by the cells
+// of the index.  They are never included in _all.
+func NewGeoShapeFieldMapping() *FieldMapping {
+	return &FieldMapping{
+		Type:  "geo_shape",
+		Store: true,
+		Index: true,
+	}
+}
+
+// NewFlattenedFieldMapping returns a default field mapping
+// for objects with keys which are not known in advance,
+// like user defined metadata.  The whole object is one
+// field, so that it does not add a field for every key.
+// Each value in the object is indexed as a keyword, both
+// on its own and together with its key, a search for the
+// field "labels.env" finds the documents with the value at
+// the key "env" of the object in the field "labels".  Only
+// term, match and prefix queries look up keys.  The object
+// is stored as JSON and it is never included in _all.
+func NewFlattenedFieldMapping() *FieldMapping {
+	return &FieldMapping{
+		Type:     "flattened",
+		Analyzer: keyword_analyzer.Name,
+		Index:    true,
+	}
+}
+
 // Options returns the indexing options for this field.
 func (fm *FieldMapping) Options() document.IndexingOptions {
 	var rv document.IndexingOptions
@@ -69,56 +240,352 @@ func (fm *FieldMapping) Options() document.IndexingOptions {
 	if fm.Index {
 		rv |= document.IndexField
 	}
-	if fm.IncludeTermVectors {
+	switch fm.IndexOptions {
+	case IndexDocs:
+		rv |= document.OmitTermFreqs
+	case IndexFreqs:
+	case IndexPositions:
+		rv |= document.IncludeTermVectors | document.OmitTermOffsets
+	case IndexOffsets:
 		rv |= document.IncludeTermVectors
+	default:
+		if termVector, ok := termVectors[fm.TermVector]; ok {
+			rv |= termVector
+		} else if fm.IncludeTermVectors {
+			rv |= document.IncludeTermVectors
+		}
+	}
+	if fm.TermVector == "" && fm.IncludePayloads && rv.IncludeTermVectors() {
+		rv |= document.IncludeTermPayloads
+	}
+	if fm.DocValues {
+		rv |= document.IncludeDocValues
+	}
+	return rv
+}
+
+func (fm *FieldMapping) validate(v *mappingValidator, path string) {
+	if fm.Analyzer != "" {
+		v.analyzer(path, "analyzer", fm.Analyzer)
+	}
+	if fm.DateFormat != "" {
+		v.dateTimeParser(path, "date_format", fm.DateFormat)
+	}
+	for i, format := range fm.DateFormats {
+		v.dateTimeParser(path, fmt.Sprintf("date_formats[%d]", i), format)
+	}
+	switch fm.Type {
+	case "text", "datetime", "number":
+	case "ip":
+		if nullValue, ok := fm.NullValue.(string); ok && net.ParseIP(nullValue) == nil {
+			v.add(path, "null_value", "must be an ip address for an ip field")
+		}
+	case "alias":
+		if fm.Path == "" {
+			v.add(path, "path", "alias field must have a path")
+		} else if _, ok := v.aliases[fm.Path]; ok {
+			v.add(path, "path", "cannot name the alias '%s'", fm.Path)
+		} else if _, ok := v.im.RuntimeFields[fm.Path]; !ok && v.im.mappedFieldForPath(fm.Path) == nil {
+			v.add(path, "path", "names the unmapped field '%s'", fm.Path)
+		}
+		if len(fm.CopyTo) > 0 || len(fm.Fields) > 0 {
+			v.add(path, "type", "alias field cannot have copy_to fields or sub-fields")
+		}
+	case "binary":
+		if fm.Index || fm.DocValues || len(fm.CopyTo) > 0 || len(fm.Fields) > 0 {
+			v.add(path, "type", "binary field cannot be indexed")
+		}
+	case "flattened", "geo_shape":
+		if len(fm.CopyTo) > 0 || len(fm.Fields) > 0 {
+			v.add(path, "type", "%s field cannot have copy_to fields or sub-fields", fm.Type)
+		}
+	default:
+		v.add(path, "type", "unknown field type '%s'", fm.Type).Suggestions = suggestions(fm.Type, fieldTypes)
+	}
+	switch fm.NullValue.(type) {
+	case nil:
+	case string:
+		if fm.Type == "number" {
+			v.add(path, "null_value", "must be a number for a number field")
+		}
+	case float64:
+		if fm.Type != "number" {
+			v.add(path, "null_value", "must be a string for a %s field", fm.Type)
+		}
+	default:
+		v.add(path, "null_value", "must be a string or a number")
+	}
+	if fm.IgnoreAbove < 0 {
+		v.add(path, "ignore_above", "cannot be negative")
+	}
+	if fm.PrecisionStep > 64 {
+		v.add(path, "precision_step", "must be at most 64")
+	}
+	switch fm.IndexOptions {
+	case "", IndexDocs, IndexFreqs, IndexPositions, IndexOffsets:
+	default:
+		v.add(path, "index_options", "unknown index options '%s'", fm.IndexOptions).Suggestions = suggestions(fm.IndexOptions, []string{IndexDocs, IndexFreqs, IndexPositions, IndexOffsets})
+	}
+	if _, ok := termVectors[fm.TermVector]; fm.TermVector != "" && !ok {
+		names := make([]string, 0, len(termVectors))
+		for name := range termVectors {
+			names = append(names, name)
+		}
+		v.add(path, "term_vector", "unknown term vector '%s'", fm.TermVector).Suggestions = suggestions(fm.TermVector, names)
+	} else if fm.TermVector != "" && (fm.IndexOptions == IndexPositions || fm.IndexOptions == IndexOffsets) {
+		v.add(path, "term_vector", "cannot be combined with the index options '%s'", fm.IndexOptions)
+	}
+	if fm.Similarity != "" && scorers.SimilarityNamed(fm.Similarity) == nil {
+		v.add(path, "similarity", "unknown similarity '%s'", fm.Similarity).Suggestions = suggestions(fm.Similarity, scorers.SimilarityNames())
+	}
+	for i, target := range fm.CopyTo {
+		if target == "" {
+			v.add(path, fmt.Sprintf("copy_to[%d]", i), "empty copy_to field")
+		}
+	}
+	names := make([]string, 0, len(fm.Fields))
+	for name := range fm.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sub := fm.Fields[name]
+		if name == "" || sub == nil {
+			v.add(path, "fields", "invalid sub-field '%s'", name)
+			continue
+		}
+		sub.validate(v, path+".fields."+name)
+	}
+}
+
+// copyToMapping returns the mapping the value is copied to
+// target with, the mapping of target when it has one, or
+// else this one without storing the copy.  Copies are not
+// copied on.
+func (fm *FieldMapping) copyToMapping(target string, context *walkContext) *FieldMapping {
+	var rv FieldMapping
+	if targetMapping := context.dm.fieldMappingForPath(target); targetMapping != nil {
+		rv = *targetMapping
+	} else {
+		rv = *fm
+		rv.Name = ""
+		rv.Store = false
+		rv.IncludeInAll = false
+	}
+	rv.CopyTo = nil
+	return &rv
+}
+
+// dateTimeFormats returns the names of the date time
+// parsers of the field, in the order they are tried
+func (fm *FieldMapping) dateTimeFormats() []string {
+	if fm.DateFormat == "" {
+		return fm.DateFormats
+	}
+	return append([]string{fm.DateFormat}, fm.DateFormats...)
+}
+
+// dateTimeParser returns a parser trying the date formats
+// of the field in order, or the default parser of the
+// mapping for a field without formats
+func (fm *FieldMapping) dateTimeParser(im *IndexMapping) analysis.DateTimeParser {
+	formats := []string{im.DefaultDateTimeParser}
+	if fm != nil && (fm.DateFormat != "" || len(fm.DateFormats) > 0) {
+		formats = fm.dateTimeFormats()
+	}
+	if len(formats) == 1 {
+		return im.dateTimeParserNamed(formats[0])
+	}
+	rv := make(dateTimeParsers, 0, len(formats))
+	for _, format := range formats {
+		if parser := im.dateTimeParserNamed(format); parser != nil {
+			rv = append(rv, parser)
+		}
 	}
 	return rv
 }
 
+// dateTimeParsers parses with the first parser which can
+type dateTimeParsers []analysis.DateTimeParser
+
+func (p dateTimeParsers) ParseDateTime(input string) (time.Time, error) {
+	for _, parser := range p {
+		rv, err := parser.ParseDateTime(input)
+		if err == nil {
+			return rv, nil
+		}
+	}
+	return time.Time{}, analysis.ErrInvalidDateTime
+}
+
+// precisionStep is the step numbers and dates of the field
+// are indexed with, see NewNumericFieldWithPrecisionStep
+func (fm *FieldMapping) precisionStep() uint {
+	if fm == nil || fm.PrecisionStep == 0 {
+		return document.DefaultPrecisionStep
+	}
+	return fm.PrecisionStep
+}
+
 func (fm *FieldMapping) processString(propertyValueString string, pathString string, path []string, indexes []uint64, context *walkContext) {
 	fieldName := getFieldName(pathString, path, fm)
 	options := fm.Options()
 	if fm.Type == "text" {
+		if fm.IgnoreAbove > 0 && utf8.RuneCountInString(propertyValueString) > fm.IgnoreAbove {
+			options &= document.StoreField
+		}
 		analyzer := fm.analyzerForField(path, context)
-		field := document.NewTextFieldCustom(fieldName, indexes, []byte(propertyValueString), options, analyzer)
-		context.doc.AddField(field)
+		if options != 0 {
+			field := document.NewTextFieldCustom(fieldName, indexes, []byte(propertyValueString), options, analyzer)
+			context.doc.AddField(field)
+		}
 
 		if !fm.IncludeInAll {
 			context.excludedFromAll = append(context.excludedFromAll, fieldName)
 		}
 	} else if fm.Type == "datetime" {
-		dateTimeFormat := context.im.DefaultDateTimeParser
-		if fm.DateFormat != "" {
-			dateTimeFormat = fm.DateFormat
-		}
-		dateTimeParser := context.im.dateTimeParserNamed(dateTimeFormat)
+		dateTimeParser := fm.dateTimeParser(context.im)
 		if dateTimeParser != nil {
 			parsedDateTime, err := dateTimeParser.ParseDateTime(propertyValueString)
-			if err != nil {
-				fm.processTime(parsedDateTime, pathString, path, indexes, context)
+			if err == nil {
+				fm.indexTime(parsedDateTime, fieldName, indexes, context)
+			} else {
+				fm.mismatched(propertyValueString, fieldName, context)
 			}
 		}
+	} else if fm.Type == "number" {
+		number, err := strconv.ParseFloat(strings.TrimSpace(propertyValueString), 64)
+		if fm.Coerce && err == nil {
+			fm.indexFloat64(number, fieldName, indexes, context)
+		} else {
+			fm.mismatched(propertyValueString, fieldName, context)
+		}
+	} else if fm.Type == "ip" {
+		fm.processIP(propertyValueString, fieldName, indexes, context)
+	} else if fm.Type == "binary" {
+		context.excludedFromAll = append(context.excludedFromAll, fieldName)
+		_, err := base64.StdEncoding.DecodeString(propertyValueString)
+		if err != nil {
+			fm.malformed(propertyValueString, fieldName, context)
+		} else if fm.Store {
+			context.doc.AddField(document.NewTextFieldWithIndexingOptions(fieldName, indexes, []byte(propertyValueString), document.StoreField))
+		}
+	}
+	for _, name := range fm.subFieldNames() {
+		subName := fieldName + pathSeparator + name
+		fm.subField(name).processString(propertyValueString, subName, decodePath(subName), indexes, context)
+	}
+	for _, target := range fm.CopyTo {
+		fm.copyToMapping(target, context).processString(propertyValueString, target, decodePath(target), indexes, context)
 	}
 }
 
 func (fm *FieldMapping) processFloat64(propertyValFloat float64, pathString string, path []string, indexes []uint64, context *walkContext) {
 	fieldName := getFieldName(pathString, path, fm)
 	if fm.Type == "number" {
-		options := fm.Options()
-		field := document.NewNumericFieldWithIndexingOptions(fieldName, indexes, propertyValFloat, options)
-		context.doc.AddField(field)
-
-		if !fm.IncludeInAll {
-			context.excludedFromAll = append(context.excludedFromAll, fieldName)
+		fm.indexFloat64(propertyValFloat, fieldName, indexes, context)
+	} else if fm.Type == "datetime" {
+		// only epoch formats parse numbers
+		dateTimeParser := fm.dateTimeParser(context.im)
+		if dateTimeParser != nil {
+			parsedDateTime, err := dateTimeParser.ParseDateTime(strconv.FormatFloat(propertyValFloat, 'f', -1, 64))
+			if err == nil {
+				fm.indexTime(parsedDateTime, fieldName, indexes, context)
+			} else {
+				fm.mismatched(propertyValFloat, fieldName, context)
+			}
 		}
 	}
+	for _, name := range fm.subFieldNames() {
+		subName := fieldName + pathSeparator + name
+		fm.subField(name).processFloat64(propertyValFloat, subName, decodePath(subName), indexes, context)
+	}
+	for _, target := range fm.CopyTo {
+		fm.copyToMapping(target, context).processFloat64(propertyValFloat, target, decodePath(target), indexes, context)
+	}
 }
 
 func (fm *FieldMapping) processTime(propertyValueTime time.Time, pathString string, path []string, indexes []uint64, context *walkContext) {
 	fieldName := getFieldName(pathString, path, fm)
+	if fm.Type == "number" {
+		fm.mismatched(propertyValueTime, fieldName, context)
+	} else {
+		fm.indexTime(propertyValueTime, fieldName, indexes, context)
+	}
+	for _, name := range fm.subFieldNames() {
+		subName := fieldName + pathSeparator + name
+		fm.subField(name).processTime(propertyValueTime, subName, decodePath(subName), indexes, context)
+	}
+	for _, target := range fm.CopyTo {
+		fm.copyToMapping(target, context).processTime(propertyValueTime, target, decodePath(target), indexes, context)
+	}
+}
+
+func (fm *FieldMapping) indexFloat64(propertyValFloat float64, fieldName string, indexes []uint64, context *walkContext) {
+	options := fm.Options()
+	field := document.NewNumericFieldWithPrecisionStep(fieldName, indexes, propertyValFloat, options, fm.precisionStep())
+	context.doc.AddField(field)
+
+	if !fm.IncludeInAll {
+		context.excludedFromAll = append(context.excludedFromAll, fieldName)
+	}
+}
+
+// processIP indexes the term of the address and stores
+// the address as it is
+func (fm *FieldMapping) processIP(propertyValueString string, fieldName string, indexes []uint64, context *walkContext) {
+	context.excludedFromAll = append(context.excludedFromAll, fieldName)
+	ip := net.ParseIP(strings.TrimSpace(propertyValueString))
+	if ip == nil {
+		fm.malformed(propertyValueString, fieldName, context)
+		return
+	}
+	options := fm.Options()
+	if options.IsStored() {
+		context.doc.AddField(document.NewTextFieldWithIndexingOptions(fieldName, indexes, []byte(propertyValueString), document.StoreField))
+	}
+	if options &^= document.StoreField; options != 0 {
+		context.doc.AddField(document.NewTextFieldWithIndexingOptions(fieldName, indexes, []byte(ipTerm(ip)), options))
+	}
+}
+
+// ipTerm is the term of an address in an ip field, the 16
+// bytes of its IPv6 form in hex, IPv4 addresses mapped to
+// IPv6.  The terms sort like the addresses, and the hex
+// keeps the separator bytes of the index out of them.
+func ipTerm(ip net.IP) string {
+	return hex.EncodeToString(ip.To16())
+}
+
+// mismatched leaves out a value a number or datetime field
+// cannot index.  Such values are left out silently unless
+// the field sets Coerce or IgnoreMalformed, then they are
+// malformed.
+func (fm *FieldMapping) mismatched(value interface{}, fieldName string, context *walkContext) {
+	if fm.Coerce || fm.IgnoreMalformed {
+		fm.malformed(value, fieldName, context)
+	}
+}
+
+// malformed leaves out a value the field cannot index, it
+// fails the document unless the field ignores malformed
+// values
+func (fm *FieldMapping) malformed(value interface{}, fieldName string, context *walkContext) {
+	if !fm.IgnoreMalformed {
+		if context.err == nil {
+			context.err = fmt.Errorf("malformed value '%v' for %s field '%s'", value, fm.Type, fieldName)
+		}
+		return
+	}
+	analyzer := context.im.analyzerNamed(keyword_analyzer.Name)
+	context.doc.AddField(document.NewTextFieldCustom(IgnoredField, nil, []byte(fieldName), document.IndexField|document.StoreField, analyzer))
+	context.excludedFromAll = append(context.excludedFromAll, IgnoredField)
+}
+
+func (fm *FieldMapping) indexTime(propertyValueTime time.Time, fieldName string, indexes []uint64, context *walkContext) {
 	if fm.Type == "datetime" {
 		options := fm.Options()
-		field, err := document.NewDateTimeFieldWithIndexingOptions(fieldName, indexes, propertyValueTime, options)
+		field, err := document.NewDateTimeFieldWithPrecisionStep(fieldName, indexes, propertyValueTime, options, fm.precisionStep())
 		if err == nil {
 			context.doc.AddField(field)
 		} else {
@@ -131,6 +598,154 @@ func (fm *FieldMapping) processTime(propertyValueTime time.Time, pathString stri
 	}
 }
 
+// processNull indexes the NullValue of the field in place
+// of a null
+func (fm *FieldMapping) processNull(pathString string, path []string, indexes []uint64, context *walkContext) {
+	switch nullValue := fm.NullValue.(type) {
+	case string:
+		fm.processString(nullValue, pathString, path, indexes, context)
+	case float64:
+		fm.processFloat64(nullValue, pathString, path, indexes, context)
+	}
+}
+
+// flattenedKeySeparator separates the key from the value in
+// the terms of flattened fields which have both
+const flattenedKeySeparator = "\x00"
+
+// processObject indexes the whole value of a field taking
+// objects
+func (fm *FieldMapping) processObject(property interface{}, pathString string, path []string, indexes []uint64, context *walkContext) {
+	switch fm.Type {
+	case "flattened":
+		fm.processFlattened(property, pathString, path, indexes, context)
+	case "geo_shape":
+		fm.processGeoShape(property, pathString, path, indexes, context)
+	}
+}
+
+// processGeoShape indexes the cells covering the shapes in
+// property and stores the shapes as they are
+func (fm *FieldMapping) processGeoShape(property interface{}, pathString string, path []string, indexes []uint64, context *walkContext) {
+	fieldName := getFieldName(pathString, path, fm)
+	context.excludedFromAll = append(context.excludedFromAll, fieldName)
+	values := []interface{}{property}
+	if elements, ok := property.([]interface{}); ok {
+		values = elements
+	}
+	for _, value := range values {
+		shape, err := geo.ParseShape(value)
+		if err != nil {
+			fm.malformed(value, fieldName, context)
+			continue
+		}
+		stored, ok := value.(string)
+		if !ok {
+			data, err := json.Marshal(value)
+			if err != nil {
+				fm.malformed(value, fieldName, context)
+				continue
+			}
+			stored = string(data)
+		}
+		context.doc.AddField(document.NewTextFieldWithIndexingOptions(fieldName, indexes, []byte(stored), document.StoreField))
+		if fm.Index {
+			for _, term := range shape.IndexTerms() {
+				context.doc.AddField(document.NewTextFieldWithIndexingOptions(fieldName, indexes, []byte(term), document.IndexField))
+			}
+		}
+	}
+}
+
+// processFlattened indexes all values in property as
+// keywords of the one field
+func (fm *FieldMapping) processFlattened(property interface{}, pathString string, path []string, indexes []uint64, context *walkContext) {
+	fieldName := getFieldName(pathString, path, fm)
+	context.excludedFromAll = append(context.excludedFromAll, fieldName)
+	data, err := json.Marshal(property)
+	if err != nil {
+		logger.Printf("could not flatten field '%s': %v", fieldName, err)
+		return
+	}
+	if fm.Store {
+		context.doc.AddField(document.NewTextFieldWithIndexingOptions(fieldName, indexes, data, document.StoreField))
+	}
+	if !fm.Index {
+		return
+	}
+	var value interface{}
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		logger.Printf("could not flatten field '%s': %v", fieldName, err)
+		return
+	}
+	options := fm.Options() &^ document.StoreField
+	analyzer := fm.analyzerForField(path, context)
+	keyword := context.im.analyzerNamed(keyword_analyzer.Name)
+	flattenValue(value, nil, func(key []string, value string) {
+		if fm.IgnoreAbove > 0 && utf8.RuneCountInString(value) > fm.IgnoreAbove {
+			return
+		}
+		context.doc.AddField(document.NewTextFieldCustom(fieldName, indexes, []byte(value), options, analyzer))
+		if len(key) > 0 {
+			keyed := encodePath(key) + flattenedKeySeparator + value
+			context.doc.AddField(document.NewTextFieldCustom(fieldName, indexes, []byte(keyed), options, keyword))
+		}
+	})
+}
+
+// flattenValue calls leaf with each value of a decoded JSON
+// value and the path of its key, the values in arrays have
+// the key of the array
+func flattenValue(value interface{}, key []string, leaf func(key []string, value string)) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			flattenValue(value[name], append(key[:len(key):len(key)], name), leaf)
+		}
+	case []interface{}:
+		for _, element := range value {
+			flattenValue(element, key, leaf)
+		}
+	case string:
+		leaf(key, value)
+	case float64:
+		leaf(key, strconv.FormatFloat(value, 'f', -1, 64))
+	case bool:
+		leaf(key, strconv.FormatBool(value))
+	}
+}
+
+// subFieldNames returns the names of the sub-fields in
+// order, so that documents get their fields in the same
+// order every time
+func (fm *FieldMapping) subFieldNames() []string {
+	if len(fm.Fields) == 0 {
+		return nil
+	}
+	rv := make([]string, 0, len(fm.Fields))
+	for name := range fm.Fields {
+		rv = append(rv, name)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// subField returns the mapping of the sub-field name, the
+// value is in _all once already and is not copied again
+func (fm *FieldMapping) subField(name string) *FieldMapping {
+	rv := *fm.Fields[name]
+	rv.Name = ""
+	rv.IncludeInAll = false
+	rv.CopyTo = nil
+	return &rv
+}
+
 func (fm *FieldMapping) analyzerForField(path []string, context *walkContext) *analysis.Analyzer {
 	analyzerName := context.dm.defaultAnalyzerName(path)
 	if analyzerName == "" {
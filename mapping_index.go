@@ -11,6 +11,8 @@ package bleve
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
 
 	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/analysis/analyzers/standard_analyzer"
@@ -18,6 +20,7 @@ import (
 	"github.com/blevesearch/bleve/analysis/datetime_parsers/datetime_optional"
 	"github.com/blevesearch/bleve/document"
 	"github.com/blevesearch/bleve/registry"
+	"github.com/blevesearch/bleve/search/scorers"
 )
 
 const defaultTypeField = "_type"
@@ -118,16 +121,28 @@ func newCustomAnalysis() *customAnalysis {
 // DocumentMapping is selected by the type.
 // If no mapping was determined for that type,
 // a DefaultMapping will be used.
+// Documents with a date in the TTLField are deleted
+// once that time has passed, except in memory only indexes
+// whose store does not isolate searches from the deletes.
+// Fields without a mapping
+// are mapped by the first of the DynamicTemplates which
+// matches them, or else by the defaults for their type.
+// The RuntimeFields are computed at search time, see
+// RuntimeFieldMapping.
 type IndexMapping struct {
-	TypeMapping           map[string]*DocumentMapping `json:"types,omitempty"`
-	DefaultMapping        *DocumentMapping            `json:"default_mapping"`
-	TypeField             string                      `json:"type_field"`
-	DefaultType           string                      `json:"default_type"`
-	DefaultAnalyzer       string                      `json:"default_analyzer"`
-	DefaultDateTimeParser string                      `json:"default_datetime_parser"`
-	DefaultField          string                      `json:"default_field"`
-	ByteArrayConverter    string                      `json:"byte_array_converter"`
-	CustomAnalysis        *customAnalysis             `json:"analysis,omitempty"`
+	TypeMapping           map[string]*DocumentMapping     `json:"types,omitempty"`
+	DefaultMapping        *DocumentMapping                `json:"default_mapping"`
+	TypeField             string                          `json:"type_field"`
+	DefaultType           string                          `json:"default_type"`
+	DefaultAnalyzer       string                          `json:"default_analyzer"`
+	DefaultDateTimeParser string                          `json:"default_datetime_parser"`
+	DefaultField          string                          `json:"default_field"`
+	ByteArrayConverter    string                          `json:"byte_array_converter"`
+	CustomAnalysis        *customAnalysis                 `json:"analysis,omitempty"`
+	SortField             string                          `json:"sort_field,omitempty"`
+	TTLField              string                          `json:"ttl_field,omitempty"`
+	DynamicTemplates      []*DynamicTemplate              `json:"dynamic_templates,omitempty"`
+	RuntimeFields         map[string]*RuntimeFieldMapping `json:"runtime_fields,omitempty"`
 	cache                 *registry.Cache
 }
 
@@ -207,28 +222,192 @@ func NewIndexMapping() *IndexMapping {
 	}
 }
 
-// Validate will walk the entire structure ensuring the following
-// explicitly named and default analyzers can be built
+// validate will walk the entire structure ensuring the following
+// explicitly named and default analyzers can be built, it
+// returns the first problem found, see Validate
 func (im *IndexMapping) validate() error {
-	_, err := im.cache.AnalyzerNamed(im.DefaultAnalyzer)
-	if err != nil {
-		return err
+	v := newMappingValidator(im)
+	im.check(v)
+	if len(v.errs) > 0 {
+		return v.errs[0]
 	}
-	_, err = im.cache.DateTimeParserNamed(im.DefaultDateTimeParser)
-	if err != nil {
-		return err
+	return nil
+}
+
+// check adds the problems with the mapping to v
+func (im *IndexMapping) check(v *mappingValidator) {
+	v.analyzer("", "default_analyzer", im.DefaultAnalyzer)
+	v.dateTimeParser("", "default_datetime_parser", im.DefaultDateTimeParser)
+	im.DefaultMapping.validate(v, "default_mapping")
+	types := make([]string, 0, len(im.TypeMapping))
+	for name := range im.TypeMapping {
+		types = append(types, name)
 	}
-	err = im.DefaultMapping.validate(im.cache)
-	if err != nil {
-		return err
+	sort.Strings(types)
+	for _, name := range types {
+		im.TypeMapping[name].validate(v, "types."+name)
+	}
+	for i, dt := range im.DynamicTemplates {
+		dt.validate(v, fmt.Sprintf("dynamic_templates[%d]", i))
+	}
+	names := make([]string, 0, len(im.RuntimeFields))
+	for name := range im.RuntimeFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		im.RuntimeFields[name].validate(v, "runtime_fields."+name)
 	}
+}
+
+// fieldAliases returns the targets of the alias fields of
+// all the document mappings by the name of the alias
+func (im *IndexMapping) fieldAliases() map[string]string {
+	rv := make(map[string]string)
 	for _, docMapping := range im.TypeMapping {
-		err = docMapping.validate(im.cache)
-		if err != nil {
-			return err
+		docMapping.aliases(nil, rv)
+	}
+	im.DefaultMapping.aliases(nil, rv)
+	return rv
+}
+
+// resolveAlias returns the field the alias at path names,
+// or path itself when it is no alias
+func (im *IndexMapping) resolveAlias(path string) string {
+	if target, ok := im.fieldAliases()[path]; ok {
+		return target
+	}
+	return path
+}
+
+// hasNestedMappings tells whether documents can have nested
+// documents, which searches have to leave out
+func (im *IndexMapping) hasNestedMappings() bool {
+	if im.DefaultMapping.hasNested() {
+		return true
+	}
+	for _, docMapping := range im.TypeMapping {
+		if docMapping.hasNested() {
+			return true
 		}
 	}
-	return nil
+	return false
+}
+
+// hasUnstoredFields tells whether fields are indexed
+// without being stored, so that documents cannot be rebuilt
+// from their stored fields.  Fields which only get the
+// values copied from other fields are left out.
+func (im *IndexMapping) hasUnstoredFields() bool {
+	for _, dt := range im.DynamicTemplates {
+		if dt.Mapping != nil && dt.Mapping.Index && !dt.Mapping.Store {
+			return true
+		}
+	}
+	docMappings := []*DocumentMapping{im.DefaultMapping}
+	for _, docMapping := range im.TypeMapping {
+		docMappings = append(docMappings, docMapping)
+	}
+	unstored := make(map[string]*FieldMapping)
+	for _, docMapping := range docMappings {
+		if docMapping != nil && docMapping.Enabled {
+			docMapping.unstoredFields(nil, unstored)
+		}
+	}
+	for _, docMapping := range docMappings {
+		_, fields := docMapping.flatten()
+		for _, field := range fields {
+			for _, target := range field.CopyTo {
+				delete(unstored, target)
+			}
+		}
+	}
+	return len(unstored) > 0
+}
+
+// derivedFields returns the names of the fields of doc
+// which got their values from another of its fields, as a
+// sub-field or a copy_to target, so that they are not part
+// of the data the document was indexed from
+func (im *IndexMapping) derivedFields(doc *document.Document) map[string]bool {
+	rv := make(map[string]bool)
+	for _, dt := range im.DynamicTemplates {
+		if dt.Mapping != nil {
+			for _, target := range dt.Mapping.CopyTo {
+				rv[target] = true
+			}
+		}
+	}
+	docMappings := []*DocumentMapping{im.DefaultMapping}
+	for _, docMapping := range im.TypeMapping {
+		docMappings = append(docMappings, docMapping)
+	}
+	for _, docMapping := range docMappings {
+		_, fields := docMapping.flatten()
+		for _, field := range fields {
+			for _, target := range field.CopyTo {
+				rv[target] = true
+			}
+		}
+	}
+	names := make(map[string]bool, len(doc.Fields))
+	for _, field := range doc.Fields {
+		names[field.Name()] = true
+	}
+	for name := range names {
+		path := decodePath(name)
+		if len(path) < 2 {
+			continue
+		}
+		parent := encodePath(path[:len(path)-1])
+		if !names[parent] {
+			continue
+		}
+		fieldMapping := im.fieldMappingForPath(parent)
+		if fieldMapping == nil {
+			fieldMapping = im.dynamicFieldMapping(path[:len(path)-1], "")
+		}
+		if fieldMapping != nil && fieldMapping.Fields[path[len(path)-1]] != nil {
+			rv[name] = true
+		}
+	}
+	return rv
+}
+
+// hasSimilarities tells whether any field names the
+// similarity its terms are scored with
+func (im *IndexMapping) hasSimilarities() bool {
+	for _, dt := range im.DynamicTemplates {
+		if dt.Mapping != nil && dt.Mapping.Similarity != "" {
+			return true
+		}
+	}
+	docMappings := []*DocumentMapping{im.DefaultMapping}
+	for _, docMapping := range im.TypeMapping {
+		docMappings = append(docMappings, docMapping)
+	}
+	for _, docMapping := range docMappings {
+		_, fields := docMapping.flatten()
+		for _, field := range fields {
+			if field.Similarity != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// similarityForPath returns the similarity the terms of
+// the field at path are scored with, nil for the default
+func (im *IndexMapping) similarityForPath(path string) scorers.Similarity {
+	fieldMapping := im.fieldMappingForPath(path)
+	if fieldMapping == nil {
+		fieldMapping = im.dynamicFieldMapping(decodePath(path), "")
+	}
+	if fieldMapping == nil || fieldMapping.Similarity == "" {
+		return nil
+	}
+	return scorers.SimilarityNamed(fieldMapping.Similarity)
 }
 
 // AddDocumentMapping sets a custom document mapping for the specified type
@@ -247,15 +426,19 @@ func (im *IndexMapping) mappingForType(docType string) *DocumentMapping {
 // UnmarshalJSON deserializes a JSON representation of the IndexMapping
 func (im *IndexMapping) UnmarshalJSON(data []byte) error {
 	var tmp struct {
-		TypeMapping           map[string]*DocumentMapping `json:"types"`
-		DefaultMapping        *DocumentMapping            `json:"default_mapping"`
-		TypeField             string                      `json:"type_field"`
-		DefaultType           string                      `json:"default_type"`
-		DefaultAnalyzer       string                      `json:"default_analyzer"`
-		DefaultDateTimeParser string                      `json:"default_datetime_parser"`
-		DefaultField          string                      `json:"default_field"`
-		ByteArrayConverter    string                      `json:"byte_array_converter"`
-		CustomAnalysis        *customAnalysis             `json:"analysis"`
+		TypeMapping           map[string]*DocumentMapping     `json:"types"`
+		DefaultMapping        *DocumentMapping                `json:"default_mapping"`
+		TypeField             string                          `json:"type_field"`
+		DefaultType           string                          `json:"default_type"`
+		DefaultAnalyzer       string                          `json:"default_analyzer"`
+		DefaultDateTimeParser string                          `json:"default_datetime_parser"`
+		DefaultField          string                          `json:"default_field"`
+		ByteArrayConverter    string                          `json:"byte_array_converter"`
+		CustomAnalysis        *customAnalysis                 `json:"analysis"`
+		SortField             string                          `json:"sort_field"`
+		TTLField              string                          `json:"ttl_field"`
+		DynamicTemplates      []*DynamicTemplate              `json:"dynamic_templates"`
+		RuntimeFields         map[string]*RuntimeFieldMapping `json:"runtime_fields"`
 	}
 	err := json.Unmarshal(data, &tmp)
 	if err != nil {
@@ -316,6 +499,11 @@ func (im *IndexMapping) UnmarshalJSON(data []byte) error {
 		im.ByteArrayConverter = tmp.ByteArrayConverter
 	}
 
+	im.SortField = tmp.SortField
+	im.TTLField = tmp.TTLField
+	im.DynamicTemplates = tmp.DynamicTemplates
+	im.RuntimeFields = tmp.RuntimeFields
+
 	im.DefaultMapping = NewDocumentMapping()
 	if tmp.DefaultMapping != nil {
 		im.DefaultMapping = tmp.DefaultMapping
@@ -375,6 +563,9 @@ func (im *IndexMapping) mapDocument(doc *document.Document, data interface{}) er
 	docMapping := im.mappingForType(docType)
 	walkContext := im.newWalkContext(doc, docMapping)
 	docMapping.walkDocument(data, []string{}, []uint64{}, walkContext)
+	if walkContext.err != nil {
+		return walkContext.err
+	}
 
 	// see if the _all field was disabled
 	allMapping := docMapping.documentMappingForPath("_all")
@@ -391,6 +582,10 @@ type walkContext struct {
 	im              *IndexMapping
 	dm              *DocumentMapping
 	excludedFromAll []string
+	// the first malformed value of the document
+	err error
+	// the path of the nested section being walked
+	nested string
 }
 
 func (im *IndexMapping) newWalkContext(doc *document.Document, dm *DocumentMapping) *walkContext {
@@ -402,12 +597,32 @@ func (im *IndexMapping) newWalkContext(doc *document.Document, dm *DocumentMappi
 	}
 }
 
+// flattenedTerm returns the field and term to search for
+// term in field with, field can name a key in a flattened
+// field
+func (im *IndexMapping) flattenedTerm(field, term string) (string, string) {
+	pathElements := decodePath(field)
+	for n := len(pathElements) - 1; n > 0; n-- {
+		prefix := encodePath(pathElements[:n])
+		fieldMapping := im.fieldMappingForPath(prefix)
+		if fieldMapping != nil && fieldMapping.Type == "flattened" {
+			return prefix, encodePath(pathElements[n:]) + flattenedKeySeparator + term
+		}
+	}
+	return field, term
+}
+
 // attempts to find the best analyzer to use with only a field name
 // will walk all the document types, look for field mappings at the
 // provided path, if one exists and it has an explicit analyzer
 // that is returned
 // nil should be an acceptable return value meaning we don't know
 func (im *IndexMapping) analyzerNameForPath(path string) string {
+	path = im.resolveAlias(path)
+	// the keys of flattened fields are keywords
+	if flattened, _ := im.flattenedTerm(path, ""); flattened != path {
+		return im.fieldMappingForPath(flattened).Analyzer
+	}
 	// first we look for explicit mapping on the field
 	for _, docMapping := range im.TypeMapping {
 		analyzerName := docMapping.analyzerNameForPath(path)
@@ -416,6 +631,9 @@ func (im *IndexMapping) analyzerNameForPath(path string) string {
 		}
 	}
 	// now try the default mapping
+	if analyzerName := im.DefaultMapping.analyzerNameForPath(path); analyzerName != "" {
+		return analyzerName
+	}
 	pathMapping := im.DefaultMapping.documentMappingForPath(path)
 	if pathMapping != nil {
 		if len(pathMapping.Fields) > 0 {
@@ -425,8 +643,13 @@ func (im *IndexMapping) analyzerNameForPath(path string) string {
 		}
 	}
 
-	// next we will try default analyzers for the path
+	// then the templates for fields without a mapping
 	pathDecoded := decodePath(path)
+	if fieldMapping := im.dynamicFieldMapping(pathDecoded, ""); fieldMapping != nil && fieldMapping.Analyzer != "" {
+		return fieldMapping.Analyzer
+	}
+
+	// next we will try default analyzers for the path
 	for _, docMapping := range im.TypeMapping {
 		rv := docMapping.defaultAnalyzerName(pathDecoded)
 		if rv != "" {
@@ -455,21 +678,43 @@ func (im *IndexMapping) dateTimeParserNamed(name string) analysis.DateTimeParser
 	return dateTimeParser
 }
 
-func (im *IndexMapping) datetimeParserNameForPath(path string) string {
+// fieldMappingForPath returns the explicit mapping of the
+// field at path in any of the document mappings, or nil.
+// The mapping of an alias is the one of its field.
+func (im *IndexMapping) fieldMappingForPath(path string) *FieldMapping {
+	rv := im.mappedFieldForPath(path)
+	if rv != nil && rv.Type == "alias" {
+		return im.mappedFieldForPath(rv.Path)
+	}
+	return rv
+}
 
-	// first we look for explicit mapping on the field
+// mappedFieldForPath is fieldMappingForPath without
+// following aliases
+func (im *IndexMapping) mappedFieldForPath(path string) *FieldMapping {
 	for _, docMapping := range im.TypeMapping {
-		pathMapping := docMapping.documentMappingForPath(path)
-		if pathMapping != nil {
-			if len(pathMapping.Fields) > 0 {
-				if pathMapping.Fields[0].Analyzer != "" {
-					return pathMapping.Fields[0].Analyzer
-				}
-			}
+		fieldMapping := docMapping.fieldMappingForPath(path)
+		if fieldMapping != nil {
+			return fieldMapping
 		}
 	}
+	return im.DefaultMapping.fieldMappingForPath(path)
+}
 
-	return im.DefaultDateTimeParser
+// precisionStepForPath returns the precision step the
+// numbers or dates of the field at path are indexed with
+func (im *IndexMapping) precisionStepForPath(path string) uint {
+	fieldMapping := im.fieldMappingForPath(path)
+	if fieldMapping == nil {
+		fieldMapping = im.dynamicFieldMapping(decodePath(path), "")
+	}
+	return fieldMapping.precisionStep()
+}
+
+// dateTimeParserForPath returns the parser of the dates of
+// the field at path, see FieldMapping.DateFormats
+func (im *IndexMapping) dateTimeParserForPath(path string) analysis.DateTimeParser {
+	return im.fieldMappingForPath(path).dateTimeParser(im)
 }
 
 func (im *IndexMapping) AnalyzeText(analyzerName string, text []byte) (analysis.TokenStream, error) {
@@ -479,3 +724,70 @@ func (im *IndexMapping) AnalyzeText(analyzerName string, text []byte) (analysis.
 	}
 	return analyzer.Analyze(text), nil
 }
+
+// AnalyzeTextStages is like AnalyzeText, but also returns
+// the output of every stage of the analyzer
+func (im *IndexMapping) AnalyzeTextStages(analyzerName string, text []byte) (analysis.TokenStream, []*analysis.AnalysisStage, error) {
+	analyzer, err := im.cache.AnalyzerNamed(analyzerName)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, stages := analyzer.AnalyzeStages(text)
+	return tokens, stages, nil
+}
+
+// AnalyzeField analyzes the text using the analyzer this
+// mapping would use for the field at the given path
+func (im *IndexMapping) AnalyzeField(path string, text []byte) (analysis.TokenStream, error) {
+	return im.AnalyzeText(im.analyzerNameForPath(path), text)
+}
+
+// explicitAnalyzerNameForPath returns the analyzer set
+// on a field mapping for the path, if there is one
+func (im *IndexMapping) explicitAnalyzerNameForPath(path string) string {
+	for _, docMapping := range im.TypeMapping {
+		analyzerName := docMapping.analyzerNameForPath(path)
+		if analyzerName != "" {
+			return analyzerName
+		}
+	}
+	if im.DefaultMapping != nil {
+		return im.DefaultMapping.analyzerNameForPath(path)
+	}
+	return ""
+}
+
+// analyze resolves fieldOrAnalyzer in order: a field
+// mapped with an explicit analyzer, an analyzer of that
+// name, and finally the analyzer used for dynamically
+// mapped fields at that path
+func (im *IndexMapping) analyze(fieldOrAnalyzer string, text []byte) (analysis.TokenStream, error) {
+	analyzer, err := im.analyzerFor(fieldOrAnalyzer)
+	if err != nil {
+		return nil, err
+	}
+	return analyzer.Analyze(text), nil
+}
+
+// analyzeStages is like analyze, but returns the output of
+// every stage of the analyzer
+func (im *IndexMapping) analyzeStages(fieldOrAnalyzer string, text []byte) ([]*analysis.AnalysisStage, error) {
+	analyzer, err := im.analyzerFor(fieldOrAnalyzer)
+	if err != nil {
+		return nil, err
+	}
+	_, stages := analyzer.AnalyzeStages(text)
+	return stages, nil
+}
+
+func (im *IndexMapping) analyzerFor(fieldOrAnalyzer string) (*analysis.Analyzer, error) {
+	analyzerName := im.explicitAnalyzerNameForPath(fieldOrAnalyzer)
+	if analyzerName != "" {
+		return im.cache.AnalyzerNamed(analyzerName)
+	}
+	analyzer, err := im.cache.AnalyzerNamed(fieldOrAnalyzer)
+	if err == nil {
+		return analyzer, nil
+	}
+	return im.cache.AnalyzerNamed(im.analyzerNameForPath(fieldOrAnalyzer))
+}
@@ -0,0 +1,161 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/analysis/analyzers/keyword_analyzer"
+	"github.com/blevesearch/bleve/document"
+)
+
+// A RuntimeFunction computes the values of a runtime field
+// of a document from the values of its source fields, by
+// the name of the field.  Text values are strings, numbers
+// float64 and dates time.Time.
+type RuntimeFunction func(sources map[string][]interface{}) []interface{}
+
+var runtimeFunctionsMutex sync.RWMutex
+var runtimeFunctions = make(map[string]RuntimeFunction)
+
+// RegisterRuntimeFunction makes a RuntimeFunction available
+// to the runtime fields of mappings by name
+func RegisterRuntimeFunction(name string, function RuntimeFunction) {
+	runtimeFunctionsMutex.Lock()
+	defer runtimeFunctionsMutex.Unlock()
+	runtimeFunctions[name] = function
+}
+
+func runtimeFunctionNamed(name string) RuntimeFunction {
+	runtimeFunctionsMutex.RLock()
+	defer runtimeFunctionsMutex.RUnlock()
+	return runtimeFunctions[name]
+}
+
+// A RuntimeFieldMapping describes a field which is not
+// indexed, but computed when a search reads it, so that it
+// can be added or changed without reindexing.  The
+// registered Function is called with the values of the
+// source Fields of each document, taken from their stored
+// values or else from their doc values.  Type is "text",
+// "number" or "datetime", text is analyzed with Analyzer,
+// the keyword analyzer by default.  Searches, sorting and
+// facets can use runtime fields like any other, but a
+// search reading one computes it for all documents.
+type RuntimeFieldMapping struct {
+	Type     string   `json:"type"`
+	Function string   `json:"function"`
+	Fields   []string `json:"fields"`
+	Analyzer string   `json:"analyzer,omitempty"`
+}
+
+// NewRuntimeFieldMapping returns a runtime field of type
+// typ computed by the function registered as function from
+// the fields
+func NewRuntimeFieldMapping(typ, function string, fields ...string) *RuntimeFieldMapping {
+	return &RuntimeFieldMapping{
+		Type:     typ,
+		Function: function,
+		Fields:   fields,
+	}
+}
+
+// AddRuntimeField adds the runtime field name to the mapping
+func (im *IndexMapping) AddRuntimeField(name string, rf *RuntimeFieldMapping) {
+	if im.RuntimeFields == nil {
+		im.RuntimeFields = make(map[string]*RuntimeFieldMapping)
+	}
+	im.RuntimeFields[name] = rf
+}
+
+func (rf *RuntimeFieldMapping) validate(v *mappingValidator, path string) {
+	switch rf.Type {
+	case "text", "number", "datetime":
+	default:
+		v.add(path, "type", "unknown runtime field type '%s'", rf.Type).Suggestions = suggestions(rf.Type, []string{"text", "number", "datetime"})
+	}
+	if runtimeFunctionNamed(rf.Function) == nil {
+		v.add(path, "function", "no runtime function named '%s' registered", rf.Function)
+	}
+	if len(rf.Fields) == 0 {
+		v.add(path, "fields", "runtime field has no source fields")
+	}
+	if rf.Analyzer != "" {
+		v.analyzer(path, "analyzer", rf.Analyzer)
+	}
+}
+
+// terms returns the terms the values of the runtime field
+// name would be indexed with
+func (rf *RuntimeFieldMapping) terms(name string, values []interface{}, im *IndexMapping) []string {
+	seen := make(map[string]bool)
+	rv := make([]string, 0, len(values))
+	add := func(field document.Field) {
+		_, tokenFreqs := field.Analyze()
+		for _, tf := range tokenFreqs {
+			if !seen[string(tf.Term)] {
+				seen[string(tf.Term)] = true
+				rv = append(rv, string(tf.Term))
+			}
+		}
+	}
+	for _, value := range values {
+		switch rf.Type {
+		case "text":
+			analyzerName := rf.Analyzer
+			if analyzerName == "" {
+				analyzerName = keyword_analyzer.Name
+			}
+			analyzer := im.analyzerNamed(analyzerName)
+			if analyzer == nil {
+				continue
+			}
+			add(document.NewTextFieldWithAnalyzer(name, nil, []byte(fmt.Sprint(value)), analyzer))
+		case "number":
+			if f, ok := runtimeNumber(value); ok {
+				add(document.NewNumericField(name, nil, f))
+			}
+		case "datetime":
+			t, ok := value.(time.Time)
+			if s, isString := value.(string); isString {
+				parser := im.dateTimeParserNamed(im.DefaultDateTimeParser)
+				if parser != nil {
+					var err error
+					t, err = parser.ParseDateTime(s)
+					ok = err == nil
+				}
+			}
+			if ok {
+				if field, err := document.NewDateTimeField(name, nil, t); err == nil {
+					add(field)
+				}
+			}
+		}
+	}
+	return rv
+}
+
+func runtimeNumber(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	}
+	return 0, false
+}
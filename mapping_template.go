@@ -0,0 +1,118 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	pathpkg "path"
+)
+
+// The types of values a DynamicTemplate can match on, as
+// detected when a field has no explicit mapping.  A string
+// which the default date time parser accepts is a datetime.
+const (
+	DynamicString   = "string"
+	DynamicNumber   = "number"
+	DynamicDateTime = "datetime"
+)
+
+// A DynamicTemplate maps the fields which have no explicit
+// mapping, instead of the default mapping for their type.
+// Match and Unmatch are patterns, as understood by
+// path.Match, for the name of the field, PathMatch for its
+// full path such as "user.*".  MatchType limits the
+// template to values of one detected type.  The first
+// template matching a field is used, its Mapping is applied
+// with the name of the field.
+type DynamicTemplate struct {
+	Name      string        `json:"name,omitempty"`
+	Match     string        `json:"match,omitempty"`
+	Unmatch   string        `json:"unmatch,omitempty"`
+	PathMatch string        `json:"path_match,omitempty"`
+	MatchType string        `json:"match_type,omitempty"`
+	Mapping   *FieldMapping `json:"mapping"`
+}
+
+// NewDynamicTemplate returns a template applying mapping
+// to the fields with a name matching match
+func NewDynamicTemplate(name, match string, mapping *FieldMapping) *DynamicTemplate {
+	return &DynamicTemplate{
+		Name:    name,
+		Match:   match,
+		Mapping: mapping,
+	}
+}
+
+func (dt *DynamicTemplate) validate(v *mappingValidator, path string) {
+	options := []string{"match", "unmatch", "path_match"}
+	for i, pattern := range []string{dt.Match, dt.Unmatch, dt.PathMatch} {
+		if _, err := pathpkg.Match(pattern, ""); err != nil {
+			v.add(path, options[i], "invalid pattern '%s'", pattern)
+		}
+	}
+	switch dt.MatchType {
+	case "", DynamicString, DynamicNumber, DynamicDateTime:
+	default:
+		v.add(path, "match_type", "unknown match type '%s'", dt.MatchType).Suggestions = suggestions(dt.MatchType, []string{DynamicString, DynamicNumber, DynamicDateTime})
+	}
+	if dt.Mapping == nil {
+		v.add(path, "mapping", "dynamic template has no mapping")
+		return
+	}
+	dt.Mapping.validate(v, path+".mapping")
+}
+
+// matches tells whether the template applies to the field
+// at path with a value of the detected type, an empty
+// type matches templates for any type
+func (dt *DynamicTemplate) matches(path []string, typ string) bool {
+	if dt.MatchType != "" && typ != "" && dt.MatchType != typ {
+		return false
+	}
+	name := ""
+	if len(path) > 0 {
+		name = path[len(path)-1]
+	}
+	if dt.Match != "" {
+		if ok, _ := pathpkg.Match(dt.Match, name); !ok {
+			return false
+		}
+	}
+	if dt.Unmatch != "" {
+		if ok, _ := pathpkg.Match(dt.Unmatch, name); ok {
+			return false
+		}
+	}
+	if dt.PathMatch != "" {
+		if ok, _ := pathpkg.Match(dt.PathMatch, encodePath(path)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// dynamicFieldMapping returns the mapping of the first
+// template matching the field at path, or nil
+func (im *IndexMapping) dynamicFieldMapping(path []string, typ string) *FieldMapping {
+	for _, dt := range im.DynamicTemplates {
+		if dt.matches(path, typ) {
+			// the field keeps its own name
+			rv := *dt.Mapping
+			rv.Name = ""
+			return &rv
+		}
+	}
+	return nil
+}
+
+// AddDynamicTemplate appends a template, templates added
+// earlier take precedence
+func (im *IndexMapping) AddDynamicTemplate(dt *DynamicTemplate) {
+	im.DynamicTemplates = append(im.DynamicTemplates, dt)
+}
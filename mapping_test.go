@@ -12,7 +12,9 @@ package bleve
 import (
 	"encoding/json"
 	"reflect"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/blevesearch/bleve/analysis/tokenizers/exception"
 	"github.com/blevesearch/bleve/analysis/tokenizers/regexp_tokenizer"
@@ -322,3 +324,563 @@ func TestMappingWithTokenizerDeps(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDynamicTemplates(t *testing.T) {
+	keywordMapping := NewTextFieldMapping()
+	keywordMapping.Analyzer = "keyword"
+	keywordMapping.Name = "ignored"
+
+	mapping := NewIndexMapping()
+	mapping.AddDynamicTemplate(NewDynamicTemplate("ids", "*_id", keywordMapping))
+	mapping.AddDynamicTemplate(&DynamicTemplate{
+		Name:    "times",
+		Match:   "*_at",
+		Mapping: NewDateTimeFieldMapping(),
+	})
+	mapping.AddDynamicTemplate(&DynamicTemplate{
+		Name:      "counts",
+		PathMatch: "stats.*",
+		MatchType: DynamicNumber,
+		Mapping:   &FieldMapping{Type: "number", Index: true, PrecisionStep: 8},
+	})
+	err := mapping.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jsondoc interface{}
+	err = json.Unmarshal([]byte(`{
+		"user_id": "ABC-123",
+		"created_at": "2015-02-03T04:05:06Z",
+		"name": "marty",
+		"stats": {"views": 3, "label": "top"},
+		"views": 4
+	}`), &jsondoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := document.NewDocument("1")
+	err = mapping.mapDocument(doc, jsondoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string]document.Field)
+	for _, f := range doc.Fields {
+		fields[f.Name()] = f
+	}
+	if f, ok := fields["user_id"].(*document.TextField); !ok {
+		t.Errorf("expected user_id to be text, got %T", fields["user_id"])
+	} else if _, tokens := f.Analyze(); len(tokens) != 1 {
+		t.Errorf("expected user_id to be a single token, got %d", len(tokens))
+	}
+	if _, ok := fields["created_at"].(*document.DateTimeField); !ok {
+		t.Errorf("expected created_at to be a datetime, got %T", fields["created_at"])
+	}
+	if _, ok := fields["name"].(*document.TextField); !ok {
+		t.Errorf("expected name to be text, got %T", fields["name"])
+	}
+	if _, ok := fields["stats.label"].(*document.TextField); !ok {
+		t.Errorf("expected stats.label to be text, got %T", fields["stats.label"])
+	}
+	if f, ok := fields["stats.views"].(*document.NumericField); !ok {
+		t.Errorf("expected stats.views to be a number, got %T", fields["stats.views"])
+	} else if f.Options().IsStored() {
+		t.Errorf("expected stats.views not to be stored")
+	}
+	if f, ok := fields["views"].(*document.NumericField); !ok || !f.Options().IsStored() {
+		t.Errorf("expected views to be mapped by default")
+	}
+
+	if analyzer := mapping.analyzerNameForPath("user_id"); analyzer != "keyword" {
+		t.Errorf("expected keyword analyzer for user_id, got %s", analyzer)
+	}
+	if step := mapping.precisionStepForPath("stats.views"); step != 8 {
+		t.Errorf("expected precision step 8 for stats.views, got %d", step)
+	}
+
+	// the templates survive a round trip through JSON
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var im IndexMapping
+	err = json.Unmarshal(data, &im)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(im.DynamicTemplates, mapping.DynamicTemplates) {
+		t.Errorf("expected templates %v, got %v", mapping.DynamicTemplates, im.DynamicTemplates)
+	}
+
+	invalid := []*DynamicTemplate{
+		{Name: "pattern", Match: "[", Mapping: NewTextFieldMapping()},
+		{Name: "type", MatchType: "bool", Mapping: NewTextFieldMapping()},
+		{Name: "mapping", Match: "*"},
+		{Name: "field", Match: "*", Mapping: &FieldMapping{Type: "blob"}},
+	}
+	for _, dt := range invalid {
+		mapping := NewIndexMapping()
+		mapping.AddDynamicTemplate(dt)
+		if err := mapping.validate(); err == nil {
+			t.Errorf("expected template %s to be invalid", dt.Name)
+		}
+	}
+}
+
+func TestMappingCopyTo(t *testing.T) {
+	titleMapping := NewTextFieldMapping()
+	titleMapping.CopyTo = []string{"all_text"}
+	bodyMapping := NewTextFieldMapping()
+	bodyMapping.CopyTo = []string{"all_text", "body_exact"}
+	exactMapping := NewTextFieldMapping()
+	exactMapping.Analyzer = "keyword"
+	exactMapping.Store = false
+
+	docMapping := NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("title", titleMapping)
+	docMapping.AddFieldMappingsAt("body", bodyMapping)
+	docMapping.AddFieldMappingsAt("body_exact", exactMapping)
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping = docMapping
+	err := mapping.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := document.NewDocument("1")
+	err = mapping.mapDocument(doc, map[string]interface{}{
+		"title": "beer guide",
+		"body":  "try the stout",
+		"other": "not copied",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var allText []string
+	for _, f := range doc.Fields {
+		switch f.Name() {
+		case "all_text":
+			if f.Options().IsStored() {
+				t.Errorf("expected the copy not to be stored")
+			}
+			allText = append(allText, string(f.Value()))
+		case "body_exact":
+			_, tokens := f.Analyze()
+			if len(tokens) != 1 {
+				t.Errorf("expected body_exact to be analyzed as a keyword, got %d tokens", len(tokens))
+			}
+		}
+	}
+	sort.Strings(allText)
+	if !reflect.DeepEqual(allText, []string{"beer guide", "try the stout"}) {
+		t.Errorf("expected the title and body in all_text, got %v", allText)
+	}
+
+	titleMapping.CopyTo = []string{""}
+	if err := mapping.validate(); err == nil {
+		t.Errorf("expected an empty copy_to field to be invalid")
+	}
+}
+
+func TestMappingIgnoreAbove(t *testing.T) {
+	rawMapping := NewTextFieldMapping()
+	rawMapping.Analyzer = "keyword"
+	rawMapping.Store = false
+	rawMapping.IgnoreAbove = 10
+	idMapping := NewTextFieldMapping()
+	idMapping.Analyzer = "keyword"
+	idMapping.IgnoreAbove = 4
+	titleMapping := NewTextFieldMapping()
+	titleMapping.Fields = map[string]*FieldMapping{"raw": rawMapping}
+
+	docMapping := NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("title", titleMapping)
+	docMapping.AddFieldMappingsAt("id", idMapping)
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping = docMapping
+	err := mapping.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := document.NewDocument("1")
+	err = mapping.mapDocument(doc, map[string]interface{}{
+		"title": "a title longer than ten",
+		"id":    "abcdef",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string]document.Field)
+	for _, f := range doc.Fields {
+		fields[f.Name()] = f
+	}
+	if fields["title"] == nil || !fields["title"].Options().IsIndexed() {
+		t.Errorf("expected title to be indexed")
+	}
+	if fields["title.raw"] != nil {
+		t.Errorf("expected the long title.raw to be left out")
+	}
+	id := fields["id"]
+	if id == nil || id.Options().IsIndexed() || !id.Options().IsStored() {
+		t.Errorf("expected the long id to be stored only, got %v", id)
+	}
+
+	idMapping.IgnoreAbove = -1
+	if err := mapping.validate(); err == nil {
+		t.Errorf("expected a negative ignore_above to be invalid")
+	}
+}
+
+func TestMappingNullValue(t *testing.T) {
+	statusMapping := NewTextFieldMapping()
+	statusMapping.Analyzer = "keyword"
+	statusMapping.NullValue = "NULL"
+	ratingMapping := NewNumericFieldMapping()
+	ratingMapping.NullValue = -1.0
+
+	docMapping := NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("status", statusMapping)
+	docMapping.AddFieldMappingsAt("rating", ratingMapping)
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping = docMapping
+	err := mapping.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := document.NewDocument("1")
+	err = mapping.mapDocument(doc, map[string]interface{}{
+		"status": nil,
+		"rating": nil,
+		"other":  nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Fields) != 2 {
+		t.Fatalf("expected 2 fields, got %v", doc.Fields)
+	}
+	for _, f := range doc.Fields {
+		switch f := f.(type) {
+		case *document.TextField:
+			if f.Name() != "status" || string(f.Value()) != "NULL" {
+				t.Errorf("expected status NULL, got %s %s", f.Name(), f.Value())
+			}
+		case *document.NumericField:
+			n, err := f.Number()
+			if err != nil || f.Name() != "rating" || n != -1 {
+				t.Errorf("expected rating -1, got %s %v", f.Name(), n)
+			}
+		}
+	}
+
+	ratingMapping.NullValue = "none"
+	if err := mapping.validate(); err == nil {
+		t.Errorf("expected a string null_value of a number field to be invalid")
+	}
+}
+
+func TestMappingCoerceAndIgnoreMalformed(t *testing.T) {
+	priceMapping := NewNumericFieldMapping()
+	priceMapping.Coerce = true
+	countMapping := NewNumericFieldMapping()
+	countMapping.IgnoreMalformed = true
+	dateMapping := NewDateTimeFieldMapping()
+	dateMapping.IgnoreMalformed = true
+
+	docMapping := NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("price", priceMapping)
+	docMapping.AddFieldMappingsAt("count", countMapping)
+	docMapping.AddFieldMappingsAt("date", dateMapping)
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping = docMapping
+	err := mapping.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := document.NewDocument("1")
+	err = mapping.mapDocument(doc, map[string]interface{}{
+		"price": " 42.5 ",
+		"count": "many",
+		"date":  "yesterday",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ignored []string
+	for _, f := range doc.Fields {
+		switch f.Name() {
+		case "price":
+			n, err := f.(*document.NumericField).Number()
+			if err != nil || n != 42.5 {
+				t.Errorf("expected price 42.5, got %v", n)
+			}
+		case IgnoredField:
+			ignored = append(ignored, string(f.Value()))
+		case "count", "date":
+			t.Errorf("expected malformed %s to be left out", f.Name())
+		}
+	}
+	sort.Strings(ignored)
+	if !reflect.DeepEqual(ignored, []string{"count", "date"}) {
+		t.Errorf("expected count and date ignored, got %v", ignored)
+	}
+
+	doc = document.NewDocument("2")
+	err = mapping.mapDocument(doc, map[string]interface{}{
+		"price": "cheap",
+	})
+	if err == nil {
+		t.Errorf("expected a malformed price to fail the document")
+	}
+}
+
+func TestMappingMismatchedValuesLeftOut(t *testing.T) {
+	docMapping := NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("age", NewNumericFieldMapping())
+	docMapping.AddFieldMappingsAt("born", NewNumericFieldMapping())
+	docMapping.AddFieldMappingsAt("date", NewDateTimeFieldMapping())
+	docMapping.AddFieldMappingsAt("seen", NewDateTimeFieldMapping())
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping = docMapping
+
+	// without coerce or ignore_malformed values of the wrong
+	// kind are left out without failing the document
+	doc := document.NewDocument("1")
+	err := mapping.mapDocument(doc, map[string]interface{}{
+		"age":  "old",
+		"born": time.Now(),
+		"date": "yesterday",
+		"seen": 5.0,
+		"name": "marty",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range doc.Fields {
+		switch f.Name() {
+		case "age", "born", "date", "seen", IgnoredField:
+			t.Errorf("expected %s to be left out", f.Name())
+		}
+	}
+}
+
+func TestMappingBinary(t *testing.T) {
+	hashMapping := NewBinaryFieldMapping()
+	docMapping := NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("hash", hashMapping)
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping = docMapping
+	err := mapping.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := document.NewDocument("1")
+	err = mapping.mapDocument(doc, map[string]interface{}{
+		"hash": "3q2+7w==",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Fields) != 1 {
+		t.Fatalf("expected only the hash field, got %v", doc.Fields)
+	}
+	hash := doc.Fields[0]
+	if hash.Options().IsIndexed() || !hash.Options().IsStored() || string(hash.Value()) != "3q2+7w==" {
+		t.Errorf("expected the hash to be stored only, got %v", hash)
+	}
+
+	doc = document.NewDocument("2")
+	err = mapping.mapDocument(doc, map[string]interface{}{
+		"hash": "not base64!",
+	})
+	if err == nil {
+		t.Errorf("expected a value which is not base64 to fail")
+	}
+
+	hashMapping.Index = true
+	if err := mapping.validate(); err == nil {
+		t.Errorf("expected an indexed binary field to be invalid")
+	}
+}
+
+func TestMappingStrict(t *testing.T) {
+	var docMapping DocumentMapping
+	err := json.Unmarshal([]byte(`{
+		"dynamic": "strict",
+		"properties": {
+			"name": {"fields": [{"type": "text"}]},
+			"meta": {"properties": {"source": {"fields": [{"type": "text"}]}}}
+		}
+	}`), &docMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !docMapping.Strict || docMapping.Dynamic {
+		t.Fatalf("expected a strict mapping, got %#v", docMapping)
+	}
+	mapping := NewIndexMapping()
+	mapping.DefaultMapping = &docMapping
+	err = mapping.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		data  map[string]interface{}
+		valid bool
+	}{
+		{map[string]interface{}{"name": "a", "meta": map[string]interface{}{"source": "b"}}, true},
+		{map[string]interface{}{"name": "a", "age": 3.0}, false},
+		{map[string]interface{}{"name": "a", "meta": map[string]interface{}{"origin": "b"}}, false},
+		{map[string]interface{}{"name": "a", "extra": map[string]interface{}{"more": "b"}}, false},
+	}
+	for _, test := range tests {
+		err = mapping.mapDocument(document.NewDocument("1"), test.data)
+		if test.valid && err != nil {
+			t.Errorf("expected %v to be indexed, got %v", test.data, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("expected %v with an unmapped field to fail", test.data)
+		}
+	}
+
+	data, err := json.Marshal(&docMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roundTrip DocumentMapping
+	err = json.Unmarshal(data, &roundTrip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !roundTrip.Strict {
+		t.Errorf("expected the mapping to stay strict, got %s", data)
+	}
+	err = json.Unmarshal([]byte(`{"dynamic": "sometimes"}`), &roundTrip)
+	if err == nil {
+		t.Errorf("expected an unknown dynamic mapping to fail")
+	}
+}
+
+func TestMappingValidate(t *testing.T) {
+	var mapping IndexMapping
+	err := json.Unmarshal([]byte(`{
+		"default_mapping": {
+			"properties": {
+				"name": {"fields": [{"type": "text", "analyzer": "standrd"}]},
+				"age": {"fields": [{"type": "nubmer"}]},
+				"rating": {"fields": [{"type": "number", "null_value": "none"}]},
+				"hash": {"fields": [{"type": "binary", "index": true}]}
+			}
+		},
+		"types": {
+			"post": {"default_analyzer": "englsh"}
+		}
+	}`), &mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping.Validate() == nil {
+		t.Fatal("expected the mapping to be invalid")
+	}
+	errs, ok := mapping.Validate().(MappingErrors)
+	if !ok {
+		t.Fatalf("expected MappingErrors, got %T", mapping.Validate())
+	}
+	expected := []struct {
+		path        string
+		option      string
+		suggestions []string
+	}{
+		{"default_mapping.properties.age.fields[0]", "type", []string{"number"}},
+		{"default_mapping.properties.hash.fields[0]", "type", nil},
+		{"default_mapping.properties.name.fields[0]", "analyzer", []string{"standard"}},
+		{"default_mapping.properties.rating.fields[0]", "null_value", nil},
+		{"types.post", "default_analyzer", nil},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), errs)
+	}
+	for i, e := range expected {
+		if errs[i].Path != e.path || errs[i].Option != e.option {
+			t.Errorf("expected error %d at %s.%s, got %v", i, e.path, e.option, errs[i])
+		}
+		if e.suggestions != nil && !reflect.DeepEqual(errs[i].Suggestions, e.suggestions) {
+			t.Errorf("expected suggestions %v for %s.%s, got %v", e.suggestions, e.path, e.option, errs[i].Suggestions)
+		}
+	}
+	if msg := errs[2].Error(); msg != "default_mapping.properties.name.fields[0].analyzer: no analyzer with name or type 'standrd' registered, did you mean 'standard'?" {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	// creating an index only reports the first problem
+	if err := mapping.validate(); err == nil || err.Error() != errs[0].Error() {
+		t.Errorf("expected the first problem, got %v", err)
+	}
+	if NewIndexMapping().Validate() != nil {
+		t.Errorf("expected the default mapping to be valid")
+	}
+}
+
+func TestDiffMappings(t *testing.T) {
+	build := func(analyzer string, extra bool) *IndexMapping {
+		titleMapping := NewTextFieldMapping()
+		titleMapping.Analyzer = analyzer
+		postMapping := NewDocumentMapping()
+		postMapping.AddFieldMappingsAt("title", titleMapping)
+		if extra {
+			postMapping.AddFieldMappingsAt("tags", NewTextFieldMapping())
+			postMapping.AddFieldMappingsAt("headline", NewAliasFieldMapping("title"))
+		}
+		mapping := NewIndexMapping()
+		mapping.AddDocumentMapping("post", postMapping)
+		mapping.DefaultMapping.AddFieldMappingsAt("name", NewTextFieldMapping())
+		return mapping
+	}
+
+	from := build("standard", false)
+	if diff := DiffMappings(from, build("standard", false)); len(diff) != 0 {
+		t.Errorf("expected no changes, got %v", diff)
+	}
+
+	// new fields need no reindexing
+	diff := DiffMappings(from, build("standard", true))
+	if len(diff) != 2 || !diff.Compatible() || diff.ReindexPlan() != nil {
+		t.Errorf("expected 2 compatible changes, got %v", diff)
+	}
+	for _, change := range diff {
+		if change.Kind != MappingAdded || change.Path != "types.post" {
+			t.Errorf("expected a field added to post, got %v", change)
+		}
+	}
+
+	diff = DiffMappings(from, build("keyword", false))
+	if len(diff) != 1 || diff.Compatible() {
+		t.Fatalf("expected an incompatible change, got %v", diff)
+	}
+	change := diff[0]
+	if change.Field != "title" || change.Option != "analyzer" || change.Old != "standard" || change.New != "keyword" {
+		t.Errorf("expected the analyzer of title to change, got %v", change)
+	}
+	plan := diff.ReindexPlan()
+	if plan == nil || plan.All || !reflect.DeepEqual(plan.Types, []string{"post"}) || !reflect.DeepEqual(plan.Fields, []string{"title"}) || len(plan.Steps) != 3 {
+		t.Errorf("expected to reindex the posts, got %#v", plan)
+	}
+
+	to := build("standard", false)
+	to.DefaultAnalyzer = "keyword"
+	to.DefaultMapping.Strict = true
+	diff = DiffMappings(from, to)
+	if len(diff) != 2 || diff.Compatible() {
+		t.Fatalf("expected 2 changes, got %v", diff)
+	}
+	if diff[0].Option != "default_analyzer" || !diff[0].Reindex || diff[1].Option != "strict" || diff[1].Reindex {
+		t.Errorf("expected the default analyzer to need reindexing and strict not to, got %v", diff)
+	}
+	if plan := diff.ReindexPlan(); plan == nil || !plan.All {
+		t.Errorf("expected to reindex all documents, got %#v", plan)
+	}
+}
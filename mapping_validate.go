@@ -0,0 +1,138 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/registry"
+	"github.com/blevesearch/bleve/search"
+)
+
+// A MappingError is a problem with an option of a mapping.
+// Path is where the option is in the JSON of the mapping,
+// like "default_mapping.properties.name.fields[0]", and
+// Suggestions are the names which were probably meant for a
+// name which is not known.
+type MappingError struct {
+	Path        string   `json:"path"`
+	Option      string   `json:"option"`
+	Message     string   `json:"message"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+func (e *MappingError) Error() string {
+	rv := e.Option
+	if e.Path != "" {
+		rv = e.Path + pathSeparator + e.Option
+	}
+	rv += ": " + e.Message
+	if len(e.Suggestions) > 0 {
+		rv += ", did you mean '" + strings.Join(e.Suggestions, "', '") + "'?"
+	}
+	return rv
+}
+
+// MappingErrors are all the problems with a mapping
+type MappingErrors []*MappingError
+
+func (e MappingErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks the whole mapping, it returns
+// MappingErrors with every problem found, or nil.  A
+// mapping is validated anyway when an index is created
+// with it, but then only the first problem is reported.
+func (im *IndexMapping) Validate() error {
+	v := newMappingValidator(im)
+	im.check(v)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// fieldTypes are the types of field mappings
+var fieldTypes = []string{"text", "number", "datetime", "ip", "binary", "alias", "flattened", "geo_shape"}
+
+// mappingValidator collects the problems with a mapping
+type mappingValidator struct {
+	im      *IndexMapping
+	cache   *registry.Cache
+	aliases map[string]string
+	errs    MappingErrors
+}
+
+func newMappingValidator(im *IndexMapping) *mappingValidator {
+	return &mappingValidator{
+		im:      im,
+		cache:   im.cache,
+		aliases: im.fieldAliases(),
+	}
+}
+
+func (v *mappingValidator) add(path, option, format string, args ...interface{}) *MappingError {
+	rv := &MappingError{
+		Path:    path,
+		Option:  option,
+		Message: fmt.Sprintf(format, args...),
+	}
+	v.errs = append(v.errs, rv)
+	return rv
+}
+
+// analyzer checks that the analyzer name can be built
+func (v *mappingValidator) analyzer(path, option, name string) {
+	if _, err := v.cache.AnalyzerNamed(name); err != nil {
+		_, instances := registry.AnalyzerTypesAndInstances()
+		if v.im.CustomAnalysis != nil {
+			for custom := range v.im.CustomAnalysis.Analyzers {
+				instances = append(instances, custom)
+			}
+		}
+		v.add(path, option, "%v", err).Suggestions = suggestions(name, instances)
+	}
+}
+
+// dateTimeParser checks that the date time parser name can
+// be built
+func (v *mappingValidator) dateTimeParser(path, option, name string) {
+	if _, err := v.cache.DateTimeParserNamed(name); err != nil {
+		_, instances := registry.DateTimeParserTypesAndInstances()
+		if v.im.CustomAnalysis != nil {
+			for custom := range v.im.CustomAnalysis.DateTimeParsers {
+				instances = append(instances, custom)
+			}
+		}
+		v.add(path, option, "%v", err).Suggestions = suggestions(name, instances)
+	}
+}
+
+// suggestions returns the names close to name, the ones at
+// most two edits away or starting with it
+func suggestions(name string, names []string) []string {
+	var rv []string
+	for _, candidate := range names {
+		candidate := candidate
+		_, exceeded := search.LevenshteinDistanceMax(&name, &candidate, 2)
+		if !exceeded || (name != "" && strings.HasPrefix(candidate, name)) {
+			rv = append(rv, candidate)
+		}
+	}
+	sort.Strings(rv)
+	return rv
+}
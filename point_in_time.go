@@ -0,0 +1,115 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"sync"
+
+	"github.com/blevesearch/bleve/index"
+)
+
+// A pointInTime holds a reader open for searches to use,
+// the reader is not safe for concurrent use so searches
+// of a point in time are serialized.  A closed point in
+// time has no reader.
+type pointInTime struct {
+	mutex  sync.Mutex
+	reader index.IndexReader
+	// the generation of the field lengths the reader was
+	// opened after
+	generation uint64
+}
+
+func (i *indexImpl) OpenPointInTime(name string) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	i.pointsInTimeMutex.Lock()
+	defer i.pointsInTimeMutex.Unlock()
+
+	if _, exists := i.pointsInTime[name]; exists {
+		return ErrorPointInTimeExists
+	}
+	generation := i.fieldLengths.current()
+	reader, err := i.i.Reader()
+	if err != nil {
+		return err
+	}
+	if i.pointsInTime == nil {
+		i.pointsInTime = make(map[string]*pointInTime)
+	}
+	i.pointsInTime[name] = &pointInTime{
+		reader:     reader,
+		generation: generation,
+	}
+	return nil
+}
+
+func (i *indexImpl) ClosePointInTime(name string) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	i.pointsInTimeMutex.Lock()
+	pit, exists := i.pointsInTime[name]
+	delete(i.pointsInTime, name)
+	i.pointsInTimeMutex.Unlock()
+
+	if !exists {
+		return ErrorUnknownPointInTime
+	}
+	return pit.close()
+}
+
+func (i *indexImpl) pointInTime(name string) (*pointInTime, error) {
+	i.pointsInTimeMutex.Lock()
+	defer i.pointsInTimeMutex.Unlock()
+
+	pit, exists := i.pointsInTime[name]
+	if !exists {
+		return nil, ErrorUnknownPointInTime
+	}
+	return pit, nil
+}
+
+// closePointsInTime closes all points in time, it is
+// called when the index is closed
+func (i *indexImpl) closePointsInTime() error {
+	i.pointsInTimeMutex.Lock()
+	pointsInTime := i.pointsInTime
+	i.pointsInTime = nil
+	i.pointsInTimeMutex.Unlock()
+
+	var err error
+	for _, pit := range pointsInTime {
+		if cerr := pit.close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// close waits for a search using the point in time to
+// finish before closing its reader
+func (p *pointInTime) close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	reader := p.reader
+	p.reader = nil
+	return reader.Close()
+}
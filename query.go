@@ -139,6 +139,40 @@ func ParseQuery(input []byte) (Query, error) {
 		return &rv, nil
 	}
 
+	_, hasCIDR := tmp["cidr"]
+	if hasCIDR {
+		var rv ipRangeQuery
+		err := json.Unmarshal(input, &rv)
+		if err != nil {
+			return nil, err
+		}
+		if rv.Boost() == 0 {
+			rv.SetBoost(1)
+		}
+		return &rv, nil
+	}
+	_, hasGeoShape := tmp["geo_shape"]
+	if hasGeoShape {
+		var rv geoShapeQuery
+		err := json.Unmarshal(input, &rv)
+		if err != nil {
+			return nil, err
+		}
+		if rv.Boost() == 0 {
+			rv.SetBoost(1)
+		}
+		return &rv, nil
+	}
+	_, hasNested := tmp["nested"]
+	if hasNested {
+		var rv nestedQuery
+		err := json.Unmarshal(input, &rv)
+		if err != nil {
+			return nil, err
+		}
+		return &rv, nil
+	}
+
 	_, hasSyntaxQuery := tmp["query"]
 	if hasSyntaxQuery {
 		var rv queryStringQuery
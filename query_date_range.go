@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"math"
 
+	"github.com/blevesearch/bleve/analysis"
 	"github.com/blevesearch/bleve/index"
 	"github.com/blevesearch/bleve/numeric_util"
 	"github.com/blevesearch/bleve/search"
@@ -72,22 +73,24 @@ func (q *dateRangeQuery) SetField(f string) Query {
 
 func (q *dateRangeQuery) Searcher(i index.IndexReader, m *IndexMapping, explain bool) (search.Searcher, error) {
 
-	dateTimeParserName := ""
-	if q.DateTimeParser != nil {
-		dateTimeParserName = *q.DateTimeParser
-	} else {
-		dateTimeParserName = m.datetimeParserNameForPath(q.FieldVal)
-	}
-	dateTimeParser := m.dateTimeParserNamed(dateTimeParserName)
-	if dateTimeParser == nil {
-		return nil, fmt.Errorf("no datetime parser named '%s' registered", *q.DateTimeParser)
-	}
-
 	field := q.FieldVal
 	if q.FieldVal == "" {
 		field = m.DefaultField
 	}
 
+	var dateTimeParser analysis.DateTimeParser
+	if q.DateTimeParser != nil {
+		dateTimeParser = m.dateTimeParserNamed(*q.DateTimeParser)
+		if dateTimeParser == nil {
+			return nil, fmt.Errorf("no datetime parser named '%s' registered", *q.DateTimeParser)
+		}
+	} else {
+		dateTimeParser = m.dateTimeParserForPath(field)
+		if dateTimeParser == nil {
+			return nil, fmt.Errorf("no datetime parser for field '%s'", field)
+		}
+	}
+
 	// now parse the endpoints
 	min := math.Inf(-1)
 	max := math.Inf(1)
@@ -106,7 +109,7 @@ func (q *dateRangeQuery) Searcher(i index.IndexReader, m *IndexMapping, explain
 		max = numeric_util.Int64ToFloat64(endTime.UnixNano())
 	}
 
-	return searchers.NewNumericRangeSearcher(i, &min, &max, q.InclusiveStart, q.InclusiveEnd, field, q.BoostVal, explain)
+	return searchers.NewNumericRangeSearcherWithPrecisionStep(i, &min, &max, q.InclusiveStart, q.InclusiveEnd, field, m.precisionStepForPath(field), q.BoostVal, explain)
 }
 
 func (q *dateRangeQuery) Validate() error {
@@ -0,0 +1,88 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/geo"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/searchers"
+)
+
+type geoShapeQuery struct {
+	Shape    interface{} `json:"geo_shape"`
+	Relation string      `json:"relation,omitempty"`
+	FieldVal string      `json:"field,omitempty"`
+	BoostVal float64     `json:"boost,omitempty"`
+}
+
+// NewGeoShapeQuery creates a Query finding the documents
+// with a shape in the relation to shape, which is GeoJSON
+// or WKT like the values of geo_shape fields.  The relation
+// is geo.Intersects, geo.Disjoint, geo.Within or
+// geo.Contains, empty is geo.Intersects.
+func NewGeoShapeQuery(shape interface{}, relation string) *geoShapeQuery {
+	return &geoShapeQuery{
+		Shape:    shape,
+		Relation: relation,
+		BoostVal: 1.0,
+	}
+}
+
+func (q *geoShapeQuery) Boost() float64 {
+	return q.BoostVal
+}
+
+func (q *geoShapeQuery) SetBoost(b float64) Query {
+	q.BoostVal = b
+	return q
+}
+
+func (q *geoShapeQuery) Field() string {
+	return q.FieldVal
+}
+
+func (q *geoShapeQuery) SetField(f string) Query {
+	q.FieldVal = f
+	return q
+}
+
+func (q *geoShapeQuery) relation() string {
+	if q.Relation == "" {
+		return geo.Intersects
+	}
+	return q.Relation
+}
+
+func (q *geoShapeQuery) Searcher(i index.IndexReader, m *IndexMapping, explain bool) (search.Searcher, error) {
+	shape, err := geo.ParseShape(q.Shape)
+	if err != nil {
+		return nil, err
+	}
+	field := q.FieldVal
+	if q.FieldVal == "" {
+		field = m.DefaultField
+	}
+	// the shapes are checked against the stored shapes of the
+	// field itself
+	return searchers.NewGeoShapeSearcher(i, shape, q.relation(), m.resolveAlias(field), q.BoostVal, explain)
+}
+
+func (q *geoShapeQuery) Validate() error {
+	switch q.relation() {
+	case geo.Intersects, geo.Disjoint, geo.Within, geo.Contains:
+	default:
+		return fmt.Errorf("unknown geo shape relation '%s'", q.Relation)
+	}
+	_, err := geo.ParseShape(q.Shape)
+	return err
+}
@@ -0,0 +1,99 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/searchers"
+)
+
+type ipRangeQuery struct {
+	CIDR     string  `json:"cidr"`
+	FieldVal string  `json:"field,omitempty"`
+	BoostVal float64 `json:"boost,omitempty"`
+}
+
+// NewIPRangeQuery creates a Query finding the addresses of
+// an ip field in a block in CIDR notation, like
+// "10.0.0.0/8" or "2001:db8::/32".  A single address finds
+// only that address.
+func NewIPRangeQuery(cidr string) *ipRangeQuery {
+	return &ipRangeQuery{
+		CIDR:     cidr,
+		BoostVal: 1.0,
+	}
+}
+
+func (q *ipRangeQuery) Boost() float64 {
+	return q.BoostVal
+}
+
+func (q *ipRangeQuery) SetBoost(b float64) Query {
+	q.BoostVal = b
+	return q
+}
+
+func (q *ipRangeQuery) Field() string {
+	return q.FieldVal
+}
+
+func (q *ipRangeQuery) SetField(f string) Query {
+	q.FieldVal = f
+	return q
+}
+
+// block returns the first and the last address of the
+// block, both in their 16 byte form
+func (q *ipRangeQuery) block() (net.IP, net.IP, error) {
+	cidr := strings.TrimSpace(q.CIDR)
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid ip address '%s'", q.CIDR)
+		}
+		return ip.To16(), ip.To16(), nil
+	}
+	_, block, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, nil, err
+	}
+	first := block.IP.To16()
+	last := make(net.IP, len(first))
+	copy(last, first)
+	// the mask covers the last bytes of the 16 byte form of
+	// IPv4 blocks
+	offset := len(last) - len(block.Mask)
+	for i, b := range block.Mask {
+		last[offset+i] |= ^b
+	}
+	return first, last, nil
+}
+
+func (q *ipRangeQuery) Searcher(i index.IndexReader, m *IndexMapping, explain bool) (search.Searcher, error) {
+	first, last, err := q.block()
+	if err != nil {
+		return nil, err
+	}
+	field := q.FieldVal
+	if q.FieldVal == "" {
+		field = m.DefaultField
+	}
+	return searchers.NewTermRangeSearcher(i, []byte(ipTerm(first)), []byte(ipTerm(last)), field, q.BoostVal, explain)
+}
+
+func (q *ipRangeQuery) Validate() error {
+	_, _, err := q.block()
+	return err
+}
@@ -23,6 +23,7 @@ type matchQuery struct {
 	BoostVal     float64 `json:"boost,omitempty"`
 	PrefixVal    int     `json:"prefix_length"`
 	FuzzinessVal int     `json:"fuzziness"`
+	Synonyms     string  `json:"synonyms,omitempty"`
 }
 
 // NewMatchQuery creates a Query for matching text.
@@ -31,6 +32,9 @@ type matchQuery struct {
 // Token terms resulting from this analysis are
 // used to perform term searches.  Result documents
 // must satisfy at least one of these term searches.
+// Setting Synonyms to the name of a synonym token
+// filter expands the terms with their synonyms at
+// query time, multi-word synonyms become phrases.
 func NewMatchQuery(match string) *matchQuery {
 	return &matchQuery{
 		Match:    match,
@@ -94,24 +98,18 @@ func (q *matchQuery) Searcher(i index.IndexReader, m *IndexMapping, explain bool
 	}
 
 	tokens := analyzer.Analyze([]byte(q.Match))
+	if len(tokens) > 0 && q.Synonyms != "" {
+		synonyms, err := m.synonymMapNamed(q.Synonyms)
+		if err != nil {
+			return nil, err
+		}
+		return q.synonymQuery(field, expandSynonyms(tokens, synonyms)).Searcher(i, m, explain)
+	}
 	if len(tokens) > 0 {
 
 		tqs := make([]Query, len(tokens))
-		if q.FuzzinessVal != 0 {
-			for i, token := range tokens {
-				query := NewFuzzyQuery(string(token.Term))
-				query.SetFuzziness(q.FuzzinessVal)
-				query.SetPrefix(q.PrefixVal)
-				query.SetField(field)
-				query.SetBoost(q.BoostVal)
-				tqs[i] = query
-			}
-		} else {
-			for i, token := range tokens {
-				tqs[i] = NewTermQuery(string(token.Term)).
-					SetField(field).
-					SetBoost(q.BoostVal)
-			}
+		for i, token := range tokens {
+			tqs[i] = q.termQuery(string(token.Term), field)
 		}
 
 		shouldQuery := NewDisjunctionQueryMin(tqs, 1).
@@ -123,6 +121,45 @@ func (q *matchQuery) Searcher(i index.IndexReader, m *IndexMapping, explain bool
 	return noneQuery.Searcher(i, m, explain)
 }
 
+func (q *matchQuery) termQuery(term, field string) Query {
+	if q.FuzzinessVal != 0 {
+		query := NewFuzzyQuery(term)
+		query.SetFuzziness(q.FuzzinessVal)
+		query.SetPrefix(q.PrefixVal)
+		query.SetField(field)
+		query.SetBoost(q.BoostVal)
+		return query
+	}
+	return NewTermQuery(term).
+		SetField(field).
+		SetBoost(q.BoostVal)
+}
+
+// synonymQuery builds a disjunction with one clause for
+// each group, alternatives within a group are themselves
+// a disjunction of terms and phrases
+func (q *matchQuery) synonymQuery(field string, groups []synonymGroup) Query {
+	qs := make([]Query, 0, len(groups))
+	for _, group := range groups {
+		alternatives := make([]Query, 0, len(group))
+		for _, alternative := range group {
+			if len(alternative) == 1 {
+				if alternative[0] != "" {
+					alternatives = append(alternatives, q.termQuery(alternative[0], field))
+				}
+			} else {
+				alternatives = append(alternatives, NewPhraseQuery(alternative, field).SetBoost(q.BoostVal))
+			}
+		}
+		if len(alternatives) == 1 {
+			qs = append(qs, alternatives[0])
+		} else if len(alternatives) > 1 {
+			qs = append(qs, NewDisjunctionQueryMin(alternatives, 1).SetBoost(q.BoostVal))
+		}
+	}
+	return NewDisjunctionQueryMin(qs, 1).SetBoost(q.BoostVal)
+}
+
 func (q *matchQuery) Validate() error {
 	return nil
 }
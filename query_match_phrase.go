@@ -22,6 +22,7 @@ type matchPhraseQuery struct {
 	FieldVal    string  `json:"field,omitempty"`
 	Analyzer    string  `json:"analyzer,omitempty"`
 	BoostVal    float64 `json:"boost,omitempty"`
+	Synonyms    string  `json:"synonyms,omitempty"`
 }
 
 // NewMatchPhraseQuery creates a new Query object
@@ -31,6 +32,9 @@ type matchPhraseQuery struct {
 // Token terms resulting from this analysis are
 // used to build a search phrase.  Result documents
 // must match this phrase.
+// Setting Synonyms to the name of a synonym token
+// filter expands the phrase at query time, documents
+// matching any of the resulting phrases are returned.
 func NewMatchPhraseQuery(matchPhrase string) *matchPhraseQuery {
 	return &matchPhraseQuery{
 		MatchPhrase: matchPhrase,
@@ -74,6 +78,21 @@ func (q *matchPhraseQuery) Searcher(i index.IndexReader, m *IndexMapping, explai
 	}
 
 	tokens := analyzer.Analyze([]byte(q.MatchPhrase))
+	if len(tokens) > 0 && q.Synonyms != "" {
+		synonyms, err := m.synonymMapNamed(q.Synonyms)
+		if err != nil {
+			return nil, err
+		}
+		phrases := synonymPhrases(expandSynonyms(tokens, synonyms))
+		phraseQueries := make([]Query, len(phrases))
+		for j, phrase := range phrases {
+			phraseQueries[j] = NewPhraseQuery(phrase, field).SetBoost(q.BoostVal)
+		}
+		if len(phraseQueries) == 1 {
+			return phraseQueries[0].Searcher(i, m, explain)
+		}
+		return NewDisjunctionQueryMin(phraseQueries, 1).SetBoost(q.BoostVal).Searcher(i, m, explain)
+	}
 	if len(tokens) > 0 {
 		phrase := tokenStreamToPhrase(tokens)
 		phraseQuery := NewPhraseQuery(phrase, field).SetBoost(q.BoostVal)
@@ -0,0 +1,247 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search"
+	"github.com/blevesearch/bleve/search/searchers"
+)
+
+// The elements of a nested section are indexed as
+// documents with the id of their document, the path of the
+// section and their position, separated by a 0 byte.  They
+// follow their document in id order.  Their nestedPathField
+// holds the path.
+const nestedSeparator = "\x00"
+
+const nestedPathField = "_nested"
+
+func nestedDocID(id, path string, i int) string {
+	return id + nestedSeparator + path + nestedSeparator + strconv.Itoa(i)
+}
+
+// nestedParentID returns the id of the document a nested
+// document belongs to
+func nestedParentID(id string) string {
+	if i := strings.Index(id, nestedSeparator); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
+
+func isNestedDocID(id string) bool {
+	return strings.Contains(id, nestedSeparator)
+}
+
+// nestedElement returns the path of the nested section and
+// the position of the element a nested document indexes
+func nestedElement(id string) (path string, i int, ok bool) {
+	parts := strings.Split(id, nestedSeparator)
+	if len(parts) != 3 {
+		return "", 0, false
+	}
+	i, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return parts[1], i, true
+}
+
+// addNestedDocuments adds the stored fields of the nested
+// documents of doc to its Nested
+func addNestedDocuments(indexReader index.IndexReader, doc *document.Document) (err error) {
+	reader, err := indexReader.DocIDReader(doc.ID+nestedSeparator, doc.ID+"\x01")
+	if err != nil {
+		return err
+	}
+	var ids []string
+	id, err := reader.Next()
+	for err == nil && id != "" {
+		if strings.HasPrefix(id, doc.ID+nestedSeparator) {
+			ids = append(ids, id)
+		}
+		id, err = reader.Next()
+	}
+	if cerr := reader.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		nested, err := indexReader.Document(id)
+		if err != nil {
+			return err
+		}
+		if nested != nil {
+			doc.AddNested(nested)
+		}
+	}
+	return nil
+}
+
+// nestedDocCount counts the nested documents of the index,
+// each has one term in the nestedPathField
+func nestedDocCount(indexReader index.IndexReader) (count uint64, err error) {
+	dict, err := indexReader.FieldDict(nestedPathField)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := dict.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	entry, err := dict.Next()
+	for err == nil && entry != nil {
+		count += entry.Count
+		entry, err = dict.Next()
+	}
+	return count, err
+}
+
+type nestedQuery struct {
+	Path     string  `json:"nested"`
+	Query    Query   `json:"query"`
+	BoostVal float64 `json:"boost,omitempty"`
+}
+
+// NewNestedQuery creates a Query matching the documents
+// with an element of the nested section at path which
+// matches query on its own, so that all conditions of
+// query hold within the same element.  The fields of query
+// are named by their full path, like "comments.author".
+func NewNestedQuery(path string, query Query) *nestedQuery {
+	return &nestedQuery{
+		Path:     path,
+		Query:    query,
+		BoostVal: 1.0,
+	}
+}
+
+func (q *nestedQuery) Boost() float64 {
+	return q.BoostVal
+}
+
+func (q *nestedQuery) SetBoost(b float64) Query {
+	q.BoostVal = b
+	return q
+}
+
+func (q *nestedQuery) Field() string {
+	return ""
+}
+
+func (q *nestedQuery) SetField(f string) Query {
+	return q
+}
+
+func (q *nestedQuery) Searcher(i index.IndexReader, m *IndexMapping, explain bool) (search.Searcher, error) {
+	pathSearcher, err := searchers.NewTermSearcher(i, q.Path, nestedPathField, 0, explain)
+	if err != nil {
+		return nil, err
+	}
+	searcher, err := q.Query.Searcher(i, m, explain)
+	if err != nil {
+		_ = pathSearcher.Close()
+		return nil, err
+	}
+	searcher, err = searchers.NewConjunctionSearcher(i, []search.Searcher{searcher, pathSearcher}, explain)
+	if err != nil {
+		return nil, err
+	}
+	return searchers.NewNestedSearcher(searcher, nestedParentID, explain), nil
+}
+
+func (q *nestedQuery) Validate() error {
+	if q.Path == "" {
+		return fmt.Errorf("nested query needs a path")
+	}
+	if q.Query == nil {
+		return fmt.Errorf("nested query needs a query")
+	}
+	return q.Query.Validate()
+}
+
+func (q *nestedQuery) UnmarshalJSON(data []byte) error {
+	tmp := struct {
+		Path     string          `json:"nested"`
+		Query    json.RawMessage `json:"query"`
+		BoostVal float64         `json:"boost,omitempty"`
+	}{}
+	err := json.Unmarshal(data, &tmp)
+	if err != nil {
+		return err
+	}
+	q.Path = tmp.Path
+	q.Query, err = ParseQuery(tmp.Query)
+	if err != nil {
+		return err
+	}
+	q.BoostVal = tmp.BoostVal
+	if q.BoostVal == 0 {
+		q.BoostVal = 1
+	}
+	return nil
+}
+
+// nestedIndexOps adds the nested documents of the
+// documents of a batch, and deletes those they no longer
+// have
+func (i *indexImpl) nestedIndexOps(ops map[string]*document.Document) (rv map[string]*document.Document, err error) {
+	rv = make(map[string]*document.Document, len(ops))
+	for id, doc := range ops {
+		rv[id] = doc
+		if doc != nil {
+			for _, nested := range doc.Nested {
+				rv[nested.ID] = nested
+			}
+		}
+	}
+
+	indexReader, err := i.i.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := indexReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	for id := range ops {
+		var reader index.DocIDReader
+		reader, err = indexReader.DocIDReader(id+nestedSeparator, id+"\x01")
+		if err != nil {
+			return nil, err
+		}
+		var nestedID string
+		nestedID, err = reader.Next()
+		for err == nil && nestedID != "" {
+			if _, ok := rv[nestedID]; !ok && strings.HasPrefix(nestedID, id+nestedSeparator) {
+				rv[nestedID] = nil
+			}
+			nestedID, err = reader.Next()
+		}
+		if cerr := reader.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rv, nil
+}
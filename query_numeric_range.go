@@ -70,7 +70,7 @@ func (q *numericRangeQuery) Searcher(i index.IndexReader, m *IndexMapping, expla
 	if q.FieldVal == "" {
 		field = m.DefaultField
 	}
-	return searchers.NewNumericRangeSearcher(i, q.Min, q.Max, q.InclusiveMin, q.InclusiveMax, field, q.BoostVal, explain)
+	return searchers.NewNumericRangeSearcherWithPrecisionStep(i, q.Min, q.Max, q.InclusiveMin, q.InclusiveMax, field, m.precisionStepForPath(field), q.BoostVal, explain)
 }
 
 func (q *numericRangeQuery) Validate() error {
@@ -54,7 +54,8 @@ func (q *prefixQuery) Searcher(i index.IndexReader, m *IndexMapping, explain boo
 	if q.FieldVal == "" {
 		field = m.DefaultField
 	}
-	return searchers.NewTermPrefixSearcher(i, q.Prefix, field, q.BoostVal, explain)
+	field, prefix := m.flattenedTerm(field, q.Prefix)
+	return searchers.NewTermPrefixSearcher(i, prefix, field, q.BoostVal, explain)
 }
 
 func (q *prefixQuery) Validate() error {
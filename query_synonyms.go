@@ -0,0 +1,122 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/analysis"
+	"github.com/blevesearch/bleve/analysis/token_filters/synonym_filter"
+)
+
+// a synonymGroup is the set of alternative word
+// sequences which may appear at one place in the
+// query text, the first alternative is always the
+// original text
+type synonymGroup [][]string
+
+func (im *IndexMapping) synonymMapNamed(name string) (*synonym_filter.SynonymMap, error) {
+	tokenFilter, err := im.cache.TokenFilterNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	synonymFilter, ok := tokenFilter.(*synonym_filter.SynonymFilter)
+	if !ok {
+		return nil, fmt.Errorf("token filter named '%s' is not a synonym filter", name)
+	}
+	return synonymFilter.SynonymMap(), nil
+}
+
+// expandSynonyms groups the analyzed query tokens, any
+// sequence of tokens with synonyms becomes a single group
+// holding all the alternatives.  Position gaps (for
+// example from removed stop words) become groups
+// containing the empty string so phrases can skip them.
+func expandSynonyms(tokens analysis.TokenStream, synonyms *synonym_filter.SynonymMap) []synonymGroup {
+	rv := make([]synonymGroup, 0, len(tokens))
+	lastPosition := 0
+	for i := 0; i < len(tokens); {
+		if lastPosition != 0 {
+			for gap := tokens[i].Position - lastPosition; gap > 1; gap-- {
+				rv = append(rv, synonymGroup{{""}})
+			}
+		}
+
+		matchLen := 0
+		var outputs [][]string
+		maxLength := synonyms.MaxLength()
+		if maxLength > len(tokens)-i {
+			maxLength = len(tokens) - i
+		}
+		for length := maxLength; length > 0 && outputs == nil; length-- {
+			words := make([]string, length)
+			for j := 0; j < length; j++ {
+				words[j] = string(tokens[i+j].Term)
+			}
+			outputs = synonyms.Lookup(words)
+			if outputs != nil {
+				matchLen = length
+			}
+		}
+
+		if outputs == nil {
+			rv = append(rv, synonymGroup{{string(tokens[i].Term)}})
+			lastPosition = tokens[i].Position
+			i++
+			continue
+		}
+
+		words := make([]string, matchLen)
+		for j := 0; j < matchLen; j++ {
+			words[j] = string(tokens[i+j].Term)
+		}
+		group := synonymGroup{words}
+		for _, output := range outputs {
+			if !sameSynonym(output, words) {
+				group = append(group, output)
+			}
+		}
+		rv = append(rv, group)
+		lastPosition = tokens[i+matchLen-1].Position
+		i += matchLen
+	}
+	return rv
+}
+
+func sameSynonym(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// synonymPhrases returns every phrase which can be built
+// by choosing one alternative from each group
+func synonymPhrases(groups []synonymGroup) [][]string {
+	rv := [][]string{{}}
+	for _, group := range groups {
+		next := make([][]string, 0, len(rv)*len(group))
+		for _, phrase := range rv {
+			for _, alternative := range group {
+				newPhrase := make([]string, 0, len(phrase)+len(alternative))
+				newPhrase = append(newPhrase, phrase...)
+				newPhrase = append(newPhrase, alternative...)
+				next = append(next, newPhrase)
+			}
+		}
+		rv = next
+	}
+	return rv
+}
@@ -53,7 +53,8 @@ func (q *termQuery) Searcher(i index.IndexReader, m *IndexMapping, explain bool)
 	if q.FieldVal == "" {
 		field = m.DefaultField
 	}
-	return searchers.NewTermSearcher(i, q.Term, field, q.BoostVal, explain)
+	field, term := m.flattenedTerm(field, q.Term)
+	return searchers.NewTermSearcher(i, term, field, q.BoostVal, explain)
 }
 
 func (q *termQuery) Validate() error {
@@ -0,0 +1,53 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"time"
+
+	"github.com/blevesearch/bleve/index"
+)
+
+// RefreshManual as refresh interval makes changes visible
+// to searches only on Refresh
+const RefreshManual time.Duration = -1
+
+func (i *indexImpl) SetRefreshInterval(interval time.Duration) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	refreshableIndex, ok := i.i.(index.RefreshableIndex)
+	if !ok {
+		if interval != 0 {
+			return ErrorRefreshUnsupported
+		}
+		return nil
+	}
+	refreshableIndex.SetRefreshInterval(interval)
+	return nil
+}
+
+func (i *indexImpl) Refresh() error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	if refreshableIndex, ok := i.i.(index.RefreshableIndex); ok {
+		return refreshableIndex.Refresh()
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/index"
+)
+
+// ReindexOptions configure a Reindex.  Transform returns
+// the data to index for a document of the source, or nil to
+// leave the document out, without one the stored fields are
+// indexed as they are.  The documents are copied BatchSize
+// at a time, Progress is called after each batch with the
+// counts so far.  With Resume a reindex which was stopped
+// goes on after the last batch it copied.
+type ReindexOptions struct {
+	Transform UpdateFunc
+	BatchSize int
+	Progress  func(*ReindexResult)
+	Resume    bool
+}
+
+// A ReindexResult counts the documents read from the
+// source and those indexed into the destination, LastID is
+// the id of the last document read.
+type ReindexResult struct {
+	Processed uint64        `json:"processed"`
+	Indexed   uint64        `json:"indexed"`
+	Batches   uint64        `json:"batches"`
+	LastID    string        `json:"last_id"`
+	Took      time.Duration `json:"took"`
+}
+
+const defaultReindexBatchSize = 100
+
+// the id of the last document copied is kept in the
+// destination along with each batch, until the reindex is
+// done
+var reindexInternalKey = []byte("_reindex_last_id")
+
+// Reindex copies all documents of src into dst, which is
+// usually an index created with a new mapping.  The
+// documents are rebuilt from their stored fields, see
+// DocumentFields, fields which were not stored are lost.
+// They are read in id order a batch at a time, so src can
+// be changed while the reindex runs, documents changed
+// after they were copied are not copied again.
+func Reindex(src, dst Index, opts *ReindexOptions) (*ReindexResult, error) {
+	return reindex(src, dst, opts, reindexInternalKey, nil)
+}
+
+// reindex copies src into dst, keeping the last id copied
+// under key unless it is nil.  Each batch is read and
+// written while holding lock, if there is one.
+func reindex(src, dst Index, opts *ReindexOptions, key []byte, lock sync.Locker) (rv *ReindexResult, err error) {
+	start := time.Now()
+	if opts == nil {
+		opts = &ReindexOptions{}
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultReindexBatchSize
+	}
+	srcIndex, _, err := src.Advanced()
+	if err != nil {
+		return nil, err
+	}
+
+	rv = &ReindexResult{}
+	if opts.Resume && key != nil {
+		var lastID []byte
+		lastID, err = dst.GetInternal(key)
+		if err != nil {
+			return nil, err
+		}
+		rv.LastID = string(lastID)
+	}
+	copyBatch := func(ids []string) error {
+		if lock != nil {
+			lock.Lock()
+			defer lock.Unlock()
+		}
+		batch := dst.NewBatch()
+		for _, id := range ids {
+			doc, err := src.Document(id)
+			if err != nil {
+				return err
+			}
+			if doc == nil {
+				// deleted since the ids were read
+				continue
+			}
+			rv.Processed++
+			var data interface{} = DocumentFields(src.Mapping(), doc)
+			if opts.Transform != nil {
+				data, err = opts.Transform(id, data.(map[string]interface{}))
+				if err != nil {
+					return err
+				}
+				if data == nil {
+					continue
+				}
+			}
+			err = batch.Index(id, data)
+			if err != nil {
+				return err
+			}
+			rv.Indexed++
+		}
+		if key != nil {
+			batch.SetInternal(key, []byte(ids[len(ids)-1]))
+		}
+		return dst.Batch(batch)
+	}
+	for {
+		var ids []string
+		ids, err = docIDsAfter(srcIndex, rv.LastID, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		err = copyBatch(ids)
+		if err != nil {
+			return nil, err
+		}
+		rv.LastID = ids[len(ids)-1]
+		rv.Batches++
+		rv.Took = time.Since(start)
+		if opts.Progress != nil {
+			opts.Progress(rv)
+		}
+	}
+	if key != nil {
+		err = dst.DeleteInternal(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rv.Took = time.Since(start)
+	return rv, nil
+}
+
+// docIDsAfter returns up to n ids of documents following
+// after in id order, the reader is only held while they
+// are read.  Nested documents are left out, they are
+// copied with their document.
+func docIDsAfter(i index.Index, after string, n int) (ids []string, err error) {
+	reader, err := i.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := reader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	docIDReader, err := reader.DocIDReader(after, "")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := docIDReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	ids = make([]string, 0, n)
+	id, err := docIDReader.Next()
+	for err == nil && id != "" && len(ids) < n {
+		if id != after && !isNestedDocID(id) {
+			ids = append(ids, id)
+		}
+		id, err = docIDReader.Next()
+	}
+	return ids, err
+}
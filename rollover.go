@@ -0,0 +1,321 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/document"
+)
+
+// A RolloverPolicy tells a Rollover when to start a new
+// index and when to retire old ones, zero values are not
+// checked.  The current index is rolled over once it is
+// MaxAge old, holds MaxDocs documents or MaxSize bytes, as
+// far as its stats tell the size.  Older indexes are
+// deleted beyond MaxIndexes, or once the index after them
+// was started more than Retention ago so that all of their
+// documents are older than that.  With a CheckInterval the
+// policy is checked in the background.
+type RolloverPolicy struct {
+	MaxAge  time.Duration
+	MaxDocs uint64
+	MaxSize uint64
+
+	MaxIndexes int
+	Retention  time.Duration
+
+	CheckInterval time.Duration
+}
+
+// rolloverCreatedKey records when an index of a Rollover
+// was started
+var rolloverCreatedKey = []byte("_rollover_created")
+
+type rolloverIndex struct {
+	seq     uint64
+	path    string
+	created time.Time
+	index   Index
+}
+
+// A Rollover manages a family of indexes in a directory,
+// one per period of time, for logs and events.  Documents
+// are written to and deleted from the newest index, the
+// older ones are only searched, and the alias searches
+// across all of them.
+type Rollover struct {
+	dir     string
+	mapping *IndexMapping
+	policy  RolloverPolicy
+	alias   IndexAlias
+
+	m       sync.Mutex
+	indexes []*rolloverIndex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     sync.WaitGroup
+}
+
+// routeToNewest writes to the last index of an alias
+func routeToNewest(id string, doc *document.Document, n int) int {
+	return n - 1
+}
+
+// NewRollover opens the indexes in dir, or starts the first
+// one with mapping when there are none.  The indexes are
+// named by a sequence number.
+func NewRollover(dir string, mapping *IndexMapping, policy RolloverPolicy) (*Rollover, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	rv := &Rollover{
+		dir:     dir,
+		mapping: mapping,
+		policy:  policy,
+		alias:   NewIndexAlias(),
+		stop:    make(chan struct{}),
+	}
+	rv.alias.SetRouting(routeToNewest)
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		seq, err := strconv.ParseUint(info.Name(), 10, 64)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		ri, err := openRolloverIndex(filepath.Join(dir, info.Name()), seq)
+		if err != nil {
+			_ = rv.Close()
+			return nil, err
+		}
+		rv.indexes = append(rv.indexes, ri)
+	}
+	sort.Sort(rolloverIndexesBySeq(rv.indexes))
+	for _, ri := range rv.indexes {
+		rv.alias.Add(ri.index)
+	}
+	if len(rv.indexes) == 0 {
+		_, err = rv.rollover()
+		if err != nil {
+			_ = rv.Close()
+			return nil, err
+		}
+	}
+
+	if policy.CheckInterval > 0 {
+		rv.done.Add(1)
+		go rv.checkLoop()
+	}
+	return rv, nil
+}
+
+type rolloverIndexesBySeq []*rolloverIndex
+
+func (r rolloverIndexesBySeq) Len() int           { return len(r) }
+func (r rolloverIndexesBySeq) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r rolloverIndexesBySeq) Less(i, j int) bool { return r[i].seq < r[j].seq }
+
+func openRolloverIndex(path string, seq uint64) (*rolloverIndex, error) {
+	index, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rv := &rolloverIndex{
+		seq:   seq,
+		path:  path,
+		index: index,
+	}
+	val, err := index.GetInternal(rolloverCreatedKey)
+	if err == nil && val != nil {
+		rv.created, err = time.Parse(time.RFC3339Nano, string(val))
+	}
+	if err != nil {
+		_ = index.Close()
+		return nil, err
+	}
+	return rv, nil
+}
+
+// Alias searches across the indexes of the rollover and
+// writes to the newest one
+func (r *Rollover) Alias() IndexAlias {
+	return r.alias
+}
+
+// Indexes returns the paths of the indexes, oldest first
+func (r *Rollover) Indexes() []string {
+	r.m.Lock()
+	defer r.m.Unlock()
+	rv := make([]string, len(r.indexes))
+	for i, ri := range r.indexes {
+		rv[i] = ri.path
+	}
+	return rv
+}
+
+// Rollover starts a new index, regardless of the policy,
+// and returns its path
+func (r *Rollover) Rollover() (string, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.rollover()
+}
+
+// rollover starts the next index, m is held
+func (r *Rollover) rollover() (string, error) {
+	seq := uint64(1)
+	if len(r.indexes) > 0 {
+		seq = r.indexes[len(r.indexes)-1].seq + 1
+	}
+	path := filepath.Join(r.dir, fmt.Sprintf("%06d", seq))
+	index, err := New(path, r.mapping)
+	if err != nil {
+		return "", err
+	}
+	ri := &rolloverIndex{
+		seq:     seq,
+		path:    path,
+		created: time.Now(),
+		index:   index,
+	}
+	err = index.SetInternal(rolloverCreatedKey, []byte(ri.created.Format(time.RFC3339Nano)))
+	if err != nil {
+		_ = index.Close()
+		_ = os.RemoveAll(path)
+		return "", err
+	}
+	r.indexes = append(r.indexes, ri)
+	r.alias.Add(index)
+	return path, nil
+}
+
+// Check rolls the current index over and deletes old
+// indexes as the policy says.  It returns the path of the
+// new index, if any, and those of the deleted ones.
+func (r *Rollover) Check() (started string, retired []string, err error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if len(r.indexes) == 0 {
+		return "", nil, ErrorIndexClosed
+	}
+
+	roll, err := r.needsRollover(r.indexes[len(r.indexes)-1])
+	if err != nil {
+		return "", nil, err
+	}
+	if roll {
+		started, err = r.rollover()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	now := time.Now()
+	for len(r.indexes) > 1 {
+		expired := r.policy.Retention > 0 && now.Sub(r.indexes[1].created) > r.policy.Retention
+		if !expired && (r.policy.MaxIndexes <= 0 || len(r.indexes) <= r.policy.MaxIndexes) {
+			break
+		}
+		oldest := r.indexes[0]
+		err = r.retire(oldest)
+		if err != nil {
+			return started, retired, err
+		}
+		retired = append(retired, oldest.path)
+	}
+	return started, retired, nil
+}
+
+func (r *Rollover) needsRollover(current *rolloverIndex) (bool, error) {
+	if r.policy.MaxAge > 0 && time.Since(current.created) >= r.policy.MaxAge {
+		return true, nil
+	}
+	if r.policy.MaxDocs > 0 {
+		count, err := current.index.DocCount()
+		if err != nil {
+			return false, err
+		}
+		if count >= r.policy.MaxDocs {
+			return true, nil
+		}
+	}
+	if r.policy.MaxSize > 0 {
+		stats, err := current.index.Stats().Index()
+		if err != nil {
+			return false, err
+		}
+		if stats.Size >= r.policy.MaxSize {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// retire removes the oldest index from the alias and
+// deletes it, m is held
+func (r *Rollover) retire(ri *rolloverIndex) error {
+	r.alias.Remove(ri.index)
+	r.indexes = r.indexes[1:]
+	err := ri.index.Close()
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(ri.path)
+}
+
+func (r *Rollover) checkLoop() {
+	defer r.done.Done()
+	ticker := time.NewTicker(r.policy.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+		_, _, err := r.Check()
+		if err != nil {
+			logger.Printf("error checking rollover of %s: %v", r.dir, err)
+		}
+	}
+}
+
+// Close stops the background checks and closes the alias
+// and the indexes
+func (r *Rollover) Close() error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	r.done.Wait()
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	err := r.alias.Close()
+	for _, ri := range r.indexes {
+		if cerr := ri.index.Close(); err == nil {
+			err = cerr
+		}
+	}
+	r.indexes = nil
+	return err
+}
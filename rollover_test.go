@@ -0,0 +1,127 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRollover(t *testing.T) {
+	defer func() {
+		err := os.RemoveAll("testrollover")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	policy := RolloverPolicy{
+		MaxDocs:    3,
+		MaxIndexes: 2,
+	}
+	r, err := NewRollover("testrollover", NewIndexMapping(), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alias := r.Alias()
+	for i, id := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		err = alias.Index(id, map[string]interface{}{"name": "event"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		started, retired, err := r.Check()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (i%3 == 2) != (started != "") {
+			t.Errorf("after %d documents expected a rollover %t, got %q", i+1, i%3 == 2, started)
+		}
+		if i == 5 && len(retired) != 1 {
+			t.Errorf("expected 1 retired index, got %v", retired)
+		}
+	}
+
+	indexes := r.Indexes()
+	if len(indexes) != 2 {
+		t.Fatalf("expected 2 indexes, got %v", indexes)
+	}
+	if _, err := os.Stat(filepath.Join("testrollover", "000001")); !os.IsNotExist(err) {
+		t.Errorf("expected the retired index to be deleted, got %v", err)
+	}
+	count, err := alias.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("expected 4 documents, got %d", count)
+	}
+	res, err := alias.Search(NewSearchRequest(NewMatchQuery("event")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 4 {
+		t.Errorf("expected 4 hits, got %d", res.Total)
+	}
+
+	// the indexes and their age survive a reopen
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.MaxAge = time.Hour
+	r, err = NewRollover("testrollover", NewIndexMapping(), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened := r.Indexes(); len(reopened) != 2 || reopened[1] != indexes[1] {
+		t.Errorf("expected indexes %v, got %v", indexes, reopened)
+	}
+	started, _, err := r.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started != "" {
+		t.Errorf("expected no rollover, got %s", started)
+	}
+
+	// the background check retires indexes past retention
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy = RolloverPolicy{
+		MaxAge:        10 * time.Millisecond,
+		Retention:     10 * time.Millisecond,
+		CheckInterval: 5 * time.Millisecond,
+	}
+	r, err = NewRollover("testrollover", NewIndexMapping(), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		indexes := r.Indexes()
+		if indexes[0] != filepath.Join("testrollover", "000002") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected old indexes to be retired, got %v", indexes)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
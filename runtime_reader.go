@@ -0,0 +1,383 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/numeric_util"
+)
+
+// runtimeIndexReader adds the runtime fields of the mapping
+// to an index reader.  The terms of a runtime field are
+// computed for all documents the first time the field is
+// read, and kept for as long as the reader.
+type runtimeIndexReader struct {
+	index.IndexReader
+	m      *IndexMapping
+	mutex  sync.Mutex
+	fields map[string]*runtimeFieldTerms
+}
+
+// runtimeFieldTerms are the terms of a runtime field by
+// document, the documents are in id order
+type runtimeFieldTerms struct {
+	ids   []string
+	terms map[string][]string
+}
+
+func newRuntimeIndexReader(indexReader index.IndexReader, m *IndexMapping) *runtimeIndexReader {
+	return &runtimeIndexReader{
+		IndexReader: indexReader,
+		m:           m,
+		fields:      make(map[string]*runtimeFieldTerms),
+	}
+}
+
+func (r *runtimeIndexReader) fieldTerms(field string) (*runtimeFieldTerms, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if rv, ok := r.fields[field]; ok {
+		return rv, nil
+	}
+	rf := r.m.RuntimeFields[field]
+	function := runtimeFunctionNamed(rf.Function)
+	if function == nil {
+		return nil, fmt.Errorf("no runtime function named '%s' registered", rf.Function)
+	}
+	dvReader, err := r.IndexReader.DocValueReader(rf.Fields)
+	if err != nil {
+		return nil, err
+	}
+	idReader, err := r.IndexReader.DocIDReader("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = idReader.Close()
+	}()
+	rv := &runtimeFieldTerms{
+		terms: make(map[string][]string),
+	}
+	id, err := idReader.Next()
+	for err == nil && id != "" {
+		var sources map[string][]interface{}
+		sources, err = r.sourceValues(id, rf.Fields, dvReader)
+		if err != nil {
+			return nil, err
+		}
+		terms := rf.terms(field, function(sources), r.m)
+		if len(terms) > 0 {
+			rv.ids = append(rv.ids, id)
+			rv.terms[id] = terms
+		}
+		id, err = idReader.Next()
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(rv.ids)
+	r.fields[field] = rv
+	return rv, nil
+}
+
+// sourceValues returns the values of the source fields of
+// a document, a field without stored values has those of
+// its doc values
+func (r *runtimeIndexReader) sourceValues(id string, fields []string, dvReader index.DocValueReader) (map[string][]interface{}, error) {
+	rv := make(map[string][]interface{}, len(fields))
+	wanted := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		wanted[field] = true
+	}
+	doc, err := r.IndexReader.Document(id)
+	if err != nil {
+		return nil, err
+	}
+	if doc != nil {
+		for _, field := range doc.Fields {
+			if !wanted[field.Name()] {
+				continue
+			}
+			var value interface{}
+			switch field := field.(type) {
+			case *document.TextField:
+				value = string(field.Value())
+			case *document.NumericField:
+				value, err = field.Number()
+			case *document.DateTimeField:
+				value, err = field.DateTime()
+			default:
+				continue
+			}
+			if err == nil {
+				rv[field.Name()] = append(rv[field.Name()], value)
+			}
+		}
+	}
+	stored := make(map[string]bool, len(rv))
+	for field := range rv {
+		stored[field] = true
+	}
+	err = dvReader.VisitDocValues(id, func(field string, term []byte) {
+		if stored[field] || !wanted[field] {
+			return
+		}
+		if value, ok := r.docValue(field, term); ok {
+			rv[field] = append(rv[field], value)
+		}
+	})
+	return rv, err
+}
+
+// docValue decodes a doc value as the mapping of its field
+// says, numbers and dates are only taken from their full
+// precision terms
+func (r *runtimeIndexReader) docValue(field string, term []byte) (interface{}, bool) {
+	fieldMapping := r.m.fieldMappingForPath(field)
+	if fieldMapping == nil || (fieldMapping.Type != "number" && fieldMapping.Type != "datetime") {
+		return string(term), true
+	}
+	prefixCoded := numeric_util.PrefixCoded(term)
+	shift, err := prefixCoded.Shift()
+	if err != nil || shift != 0 {
+		return nil, false
+	}
+	i, err := prefixCoded.Int64()
+	if err != nil {
+		return nil, false
+	}
+	if fieldMapping.Type == "datetime" {
+		return time.Unix(0, i).UTC(), true
+	}
+	return numeric_util.Int64ToFloat64(i), true
+}
+
+func (r *runtimeIndexReader) TermFieldReader(term []byte, field string) (index.TermFieldReader, error) {
+	if _, ok := r.m.RuntimeFields[field]; !ok {
+		return r.IndexReader.TermFieldReader(term, field)
+	}
+	fieldTerms, err := r.fieldTerms(field)
+	if err != nil {
+		return nil, err
+	}
+	rv := &runtimeTermFieldReader{}
+	for _, id := range fieldTerms.ids {
+		for _, t := range fieldTerms.terms[id] {
+			if t == string(term) {
+				rv.docs = append(rv.docs, &index.TermFieldDoc{
+					Term: t,
+					ID:   id,
+					Freq: 1,
+					Norm: 1,
+				})
+				break
+			}
+		}
+	}
+	return rv, nil
+}
+
+func (r *runtimeIndexReader) FieldDict(field string) (index.FieldDict, error) {
+	return r.FieldDictRange(field, nil, nil)
+}
+
+func (r *runtimeIndexReader) FieldDictRange(field string, startTerm []byte, endTerm []byte) (index.FieldDict, error) {
+	if _, ok := r.m.RuntimeFields[field]; !ok {
+		return r.IndexReader.FieldDictRange(field, startTerm, endTerm)
+	}
+	return r.fieldDict(field, func(term string) bool {
+		return (startTerm == nil || bytes.Compare([]byte(term), startTerm) >= 0) &&
+			(endTerm == nil || bytes.Compare([]byte(term), endTerm) <= 0)
+	})
+}
+
+func (r *runtimeIndexReader) FieldDictPrefix(field string, termPrefix []byte) (index.FieldDict, error) {
+	if _, ok := r.m.RuntimeFields[field]; !ok {
+		return r.IndexReader.FieldDictPrefix(field, termPrefix)
+	}
+	return r.fieldDict(field, func(term string) bool {
+		return strings.HasPrefix(term, string(termPrefix))
+	})
+}
+
+func (r *runtimeIndexReader) fieldDict(field string, accept func(term string) bool) (index.FieldDict, error) {
+	fieldTerms, err := r.fieldTerms(field)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]uint64)
+	for _, terms := range fieldTerms.terms {
+		for _, term := range terms {
+			if accept(term) {
+				counts[term]++
+			}
+		}
+	}
+	rv := &runtimeFieldDict{
+		entries: make([]*index.DictEntry, 0, len(counts)),
+	}
+	for term, count := range counts {
+		rv.entries = append(rv.entries, &index.DictEntry{
+			Term:  term,
+			Count: count,
+		})
+	}
+	sort.Sort(rv)
+	return rv, nil
+}
+
+// FieldDictAutomaton walks the terms of a runtime field,
+// and of the fields of the reader it wraps when that
+// reader cannot walk automata itself
+func (r *runtimeIndexReader) FieldDictAutomaton(field string, automaton index.Automaton) (index.FieldDict, error) {
+	if _, ok := r.m.RuntimeFields[field]; !ok {
+		if automatonReader, ok := r.IndexReader.(index.AutomatonIndexReader); ok {
+			return automatonReader.FieldDictAutomaton(field, automaton)
+		}
+	}
+	fieldDict, err := r.FieldDict(field)
+	if err != nil {
+		return nil, err
+	}
+	return &automatonFieldDict{
+		FieldDict: fieldDict,
+		automaton: automaton,
+	}, nil
+}
+
+// TermDocSet returns no set for a runtime field, nor when
+// the reader it wraps keeps none, the searchers fall back
+// to their readers
+func (r *runtimeIndexReader) TermDocSet(term []byte, field string) (index.DocSet, error) {
+	if _, ok := r.m.RuntimeFields[field]; ok {
+		return nil, nil
+	}
+	if docSetReader, ok := r.IndexReader.(index.DocSetIndexReader); ok {
+		return docSetReader.TermDocSet(term, field)
+	}
+	return nil, nil
+}
+
+func (r *runtimeIndexReader) SortField() string {
+	if sortedReader, ok := r.IndexReader.(index.SortedIndexReader); ok {
+		return sortedReader.SortField()
+	}
+	return ""
+}
+
+func (r *runtimeIndexReader) SortedDocIDReader() (index.SortedDocIDReader, error) {
+	if sortedReader, ok := r.IndexReader.(index.SortedIndexReader); ok {
+		return sortedReader.SortedDocIDReader()
+	}
+	return nil, fmt.Errorf("index is not sorted")
+}
+
+func (r *runtimeIndexReader) DocValueReader(fields []string) (index.DocValueReader, error) {
+	rv := &runtimeDocValueReader{}
+	var indexed []string
+	for _, field := range fields {
+		if _, ok := r.m.RuntimeFields[field]; !ok {
+			indexed = append(indexed, field)
+			continue
+		}
+		fieldTerms, err := r.fieldTerms(field)
+		if err != nil {
+			return nil, err
+		}
+		rv.fields = append(rv.fields, field)
+		rv.terms = append(rv.terms, fieldTerms)
+	}
+	if len(indexed) > 0 || len(rv.fields) == 0 {
+		dvReader, err := r.IndexReader.DocValueReader(indexed)
+		if err != nil {
+			return nil, err
+		}
+		rv.dvReader = dvReader
+	}
+	return rv, nil
+}
+
+type runtimeTermFieldReader struct {
+	docs []*index.TermFieldDoc
+	next int
+}
+
+func (r *runtimeTermFieldReader) Next() (*index.TermFieldDoc, error) {
+	if r.next >= len(r.docs) {
+		return nil, nil
+	}
+	r.next++
+	return r.docs[r.next-1], nil
+}
+
+func (r *runtimeTermFieldReader) Advance(ID string) (*index.TermFieldDoc, error) {
+	r.next += sort.Search(len(r.docs)-r.next, func(i int) bool {
+		return r.docs[r.next+i].ID >= ID
+	})
+	return r.Next()
+}
+
+func (r *runtimeTermFieldReader) Count() uint64 {
+	return uint64(len(r.docs))
+}
+
+func (r *runtimeTermFieldReader) Close() error {
+	return nil
+}
+
+type runtimeFieldDict struct {
+	entries []*index.DictEntry
+	next    int
+}
+
+func (d *runtimeFieldDict) Len() int           { return len(d.entries) }
+func (d *runtimeFieldDict) Swap(i, j int)      { d.entries[i], d.entries[j] = d.entries[j], d.entries[i] }
+func (d *runtimeFieldDict) Less(i, j int) bool { return d.entries[i].Term < d.entries[j].Term }
+
+func (d *runtimeFieldDict) Next() (*index.DictEntry, error) {
+	if d.next >= len(d.entries) {
+		return nil, nil
+	}
+	d.next++
+	return d.entries[d.next-1], nil
+}
+
+func (d *runtimeFieldDict) Close() error {
+	return nil
+}
+
+type runtimeDocValueReader struct {
+	dvReader index.DocValueReader
+	fields   []string
+	terms    []*runtimeFieldTerms
+}
+
+func (r *runtimeDocValueReader) VisitDocValues(id string, visitor index.DocValueVisitor) error {
+	if r.dvReader != nil {
+		err := r.dvReader.VisitDocValues(id, visitor)
+		if err != nil {
+			return err
+		}
+	}
+	for i, field := range r.fields {
+		for _, term := range r.terms[i].terms[id] {
+			visitor(field, []byte(term))
+		}
+	}
+	return nil
+}
@@ -78,6 +78,8 @@ type FacetRequest struct {
 	Field          string           `json:"field"`
 	NumericRanges  []*numericRange  `json:"numeric_ranges,omitempty"`
 	DateTimeRanges []*dateTimeRange `json:"date_ranges,omitempty"`
+	DateHistogram  bool             `json:"date_histogram,omitempty"`
+	RareTermsMax   int              `json:"rare_terms_max_count,omitempty"`
 }
 
 // NewFacetRequest creates a facet on the specified
@@ -90,6 +92,34 @@ func NewFacetRequest(field string, size int) *FacetRequest {
 	}
 }
 
+// NewDateHistogramFacetRequest creates a facet
+// which groups the date values of the specified
+// field into evenly spaced buckets.  The interval
+// is chosen automatically so that no more than
+// the specified number of buckets are returned.
+func NewDateHistogramFacetRequest(field string, buckets int) *FacetRequest {
+	return &FacetRequest{
+		Field:         field,
+		Size:          buckets,
+		DateHistogram: true,
+	}
+}
+
+// NewRareTermsFacetRequest creates a facet which
+// returns up to size terms of the specified field
+// occurring in no more than maxCount documents,
+// least frequent first.  The counts are approximate,
+// a small number of rare terms may be missed.  Across
+// several indexes a term too frequent in one of them
+// but rare in the others is counted in the others only.
+func NewRareTermsFacetRequest(field string, size, maxCount int) *FacetRequest {
+	return &FacetRequest{
+		Field:        field,
+		Size:         size,
+		RareTermsMax: maxCount,
+	}
+}
+
 // AddDateTimeRange adds a bucket to a field
 // containing date values.  Documents with a
 // date value falling into this range are tabulated
@@ -156,16 +186,25 @@ func (h *HighlightRequest) AddField(field string) {
 // Facets describe the set of facets to be computed.
 // Explain triggers inclusion of additional search
 // result score explanations.
+// Sort orders the results by the value of a field
+// instead of by score, searches for all documents of an
+// index with the same SortField stop early.
+// PointInTime names a point in time opened with
+// Index.OpenPointInTime to search instead of the current
+// state of the index, so that pages of results fetched by
+// several requests are consistent.
 //
 // A special field named "*" can be used to return all fields.
 type SearchRequest struct {
-	Query     Query             `json:"query"`
-	Size      int               `json:"size"`
-	From      int               `json:"from"`
-	Highlight *HighlightRequest `json:"highlight"`
-	Fields    []string          `json:"fields"`
-	Facets    FacetsRequest     `json:"facets"`
-	Explain   bool              `json:"explain"`
+	Query       Query             `json:"query"`
+	Size        int               `json:"size"`
+	From        int               `json:"from"`
+	Highlight   *HighlightRequest `json:"highlight"`
+	Fields      []string          `json:"fields"`
+	Facets      FacetsRequest     `json:"facets"`
+	Explain     bool              `json:"explain"`
+	Sort        string            `json:"sort,omitempty"`
+	PointInTime string            `json:"point_in_time,omitempty"`
 }
 
 // AddFacet adds a FacetRequest to this SearchRequest
@@ -180,13 +219,15 @@ func (r *SearchRequest) AddFacet(facetName string, f *FacetRequest) {
 // a SearchRequest
 func (r *SearchRequest) UnmarshalJSON(input []byte) error {
 	var temp struct {
-		Q         json.RawMessage   `json:"query"`
-		Size      int               `json:"size"`
-		From      int               `json:"from"`
-		Highlight *HighlightRequest `json:"highlight"`
-		Fields    []string          `json:"fields"`
-		Facets    FacetsRequest     `json:"facets"`
-		Explain   bool              `json:"explain"`
+		Q           json.RawMessage   `json:"query"`
+		Size        int               `json:"size"`
+		From        int               `json:"from"`
+		Highlight   *HighlightRequest `json:"highlight"`
+		Fields      []string          `json:"fields"`
+		Facets      FacetsRequest     `json:"facets"`
+		Explain     bool              `json:"explain"`
+		Sort        string            `json:"sort"`
+		PointInTime string            `json:"point_in_time"`
 	}
 
 	err := json.Unmarshal(input, &temp)
@@ -200,6 +241,8 @@ func (r *SearchRequest) UnmarshalJSON(input []byte) error {
 	r.Highlight = temp.Highlight
 	r.Fields = temp.Fields
 	r.Facets = temp.Facets
+	r.Sort = temp.Sort
+	r.PointInTime = temp.PointInTime
 	r.Query, err = ParseQuery(temp.Q)
 	if err != nil {
 		return err
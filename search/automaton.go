@@ -0,0 +1,164 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package search
+
+import (
+	"fmt"
+)
+
+// lazyDFA numbers the states of an automaton as they are
+// reached and remembers the transitions between them, so
+// that walking a dictionary works out each state and each
+// transition only once
+type lazyDFA struct {
+	numbers map[string]int
+	// the next state plus one for each byte, 0 when the
+	// transition was not worked out yet
+	transitions [][256]int32
+}
+
+// number returns the number of the state with the key,
+// isNew is true when the state was not reached before
+func (d *lazyDFA) number(key string) (n int, isNew bool) {
+	if d.numbers == nil {
+		d.numbers = make(map[string]int)
+	}
+	n, ok := d.numbers[key]
+	if ok {
+		return n, false
+	}
+	n = len(d.transitions)
+	d.numbers[key] = n
+	d.transitions = append(d.transitions, [256]int32{})
+	return n, true
+}
+
+func (d *lazyDFA) next(state int, b byte) (int, bool) {
+	next := d.transitions[state][b]
+	return int(next) - 1, next > 0
+}
+
+func (d *lazyDFA) setNext(state int, b byte, next int) {
+	d.transitions[state][b] = int32(next + 1)
+}
+
+// LevenshteinAutomaton matches the terms starting with a
+// prefix which are within a Levenshtein distance of a
+// term.  The distance is counted in bytes, the same as by
+// LevenshteinDistance.
+type LevenshteinAutomaton struct {
+	term      string
+	prefix    string
+	fuzziness int
+	dfa       lazyDFA
+	states    []*levenshteinState
+}
+
+type levenshteinState struct {
+	// the number of bytes of the prefix seen, -1 once one
+	// differs
+	prefix int
+	// the distances of the prefixes of the term to the
+	// bytes seen, fuzziness+1 for all those further
+	row []int
+}
+
+func NewLevenshteinAutomaton(term, prefix string, fuzziness int) *LevenshteinAutomaton {
+	rv := LevenshteinAutomaton{
+		term:      term,
+		prefix:    prefix,
+		fuzziness: fuzziness,
+	}
+	row := make([]int, len(term)+1)
+	for j := range row {
+		row[j] = rv.capped(j)
+	}
+	rv.add(&levenshteinState{row: row})
+	return &rv
+}
+
+func (a *LevenshteinAutomaton) capped(distance int) int {
+	if distance > a.fuzziness {
+		return a.fuzziness + 1
+	}
+	return distance
+}
+
+func (a *LevenshteinAutomaton) add(s *levenshteinState) int {
+	if s.prefix < 0 {
+		// the row no longer matters
+		s.row = nil
+	}
+	n, isNew := a.dfa.number(fmt.Sprint(s.prefix, s.row))
+	if isNew {
+		a.states = append(a.states, s)
+	}
+	return n
+}
+
+func (a *LevenshteinAutomaton) Start() int {
+	return 0
+}
+
+func (a *LevenshteinAutomaton) IsMatch(state int) bool {
+	s := a.states[state]
+	return s.prefix == len(a.prefix) && s.row[len(a.term)] <= a.fuzziness
+}
+
+func (a *LevenshteinAutomaton) CanMatch(state int) bool {
+	s := a.states[state]
+	if s.prefix < 0 {
+		return false
+	}
+	for _, distance := range s.row {
+		if distance <= a.fuzziness {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *LevenshteinAutomaton) Accept(state int, b byte) int {
+	if next, ok := a.dfa.next(state, b); ok {
+		return next
+	}
+	s := a.states[state]
+	rv := levenshteinState{
+		prefix: s.prefix,
+	}
+	if rv.prefix >= 0 && rv.prefix < len(a.prefix) {
+		if a.prefix[rv.prefix] == b {
+			rv.prefix++
+		} else {
+			rv.prefix = -1
+		}
+	}
+	if rv.prefix >= 0 {
+		rv.row = make([]int, len(s.row))
+		rv.row[0] = a.capped(s.row[0] + 1)
+		for j := 1; j < len(rv.row); j++ {
+			cost := 1
+			if a.term[j-1] == b {
+				cost = 0
+			}
+			distance := s.row[j] + 1
+			if rv.row[j-1]+1 < distance {
+				distance = rv.row[j-1] + 1
+			}
+			if s.row[j-1]+cost < distance {
+				distance = s.row[j-1] + cost
+			}
+			rv.row[j] = a.capped(distance)
+		}
+	}
+	next := a.add(&rv)
+	a.dfa.setNext(state, b, next)
+	return next
+}
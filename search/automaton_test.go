@@ -0,0 +1,105 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package search
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type testAutomaton interface {
+	Start() int
+	IsMatch(state int) bool
+	CanMatch(state int) bool
+	Accept(state int, b byte) int
+}
+
+// automatonMatches runs the automaton over the term, it
+// also checks that CanMatch holds on the way to a match
+func automatonMatches(t *testing.T, a testAutomaton, term string) bool {
+	state := a.Start()
+	for i := 0; i < len(term); i++ {
+		if !a.CanMatch(state) {
+			if a.IsMatch(a.Accept(state, term[i])) {
+				t.Errorf("%q matched through a state which cannot match", term)
+			}
+			return false
+		}
+		state = a.Accept(state, term[i])
+	}
+	return a.IsMatch(state)
+}
+
+var automatonTerms = []string{
+	"", "w", "wa", "wat", "water", "waters", "watter", "wafer", "later",
+	"atec", "aphex", "wander", "abc", "cab", "bcab", "the cat", "concat",
+	"WATER", "über", "üüü", "caté", "\xffab", "ab\xe2", "x", "xxx", "b",
+}
+
+func TestLevenshteinAutomaton(t *testing.T) {
+	for _, test := range []struct {
+		term      string
+		prefix    string
+		fuzziness int
+	}{
+		{"water", "", 1},
+		{"water", "", 2},
+		{"water", "wa", 2},
+		{"ab", "", 0},
+		{"über", "", 1},
+	} {
+		a := NewLevenshteinAutomaton(test.term, test.prefix, test.fuzziness)
+		for _, term := range automatonTerms {
+			ld, exceeded := LevenshteinDistanceMax(&test.term, &term, test.fuzziness)
+			expected := !exceeded && ld <= test.fuzziness && strings.HasPrefix(term, test.prefix)
+			if automatonMatches(t, a, term) != expected {
+				t.Errorf("%s~%d with prefix %q: expected match of %q %t", test.term, test.fuzziness, test.prefix, term, expected)
+			}
+		}
+	}
+
+	a := NewLevenshteinAutomaton("water", "", 1)
+	state := a.Start()
+	for _, b := range []byte("xyz") {
+		state = a.Accept(state, b)
+	}
+	if a.CanMatch(state) {
+		t.Errorf("expected no match past xyz")
+	}
+}
+
+func TestRegexpAutomaton(t *testing.T) {
+	for _, pattern := range []string{
+		"wat.*", "a[bc]+", "^ab", "b$", `\bcat`, `cat\b`, "(?i)WAT", "ü+",
+		"x*", "^$", "a|b", "(?s).", `\x{FFFD}`, "er$|^c", "^w.t.r$",
+	} {
+		re := regexp.MustCompile(pattern)
+		prefix, _ := re.LiteralPrefix()
+		a, err := NewRegexpAutomaton(re, prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, term := range automatonTerms {
+			expected := re.MatchString(term) && strings.HasPrefix(term, prefix)
+			if automatonMatches(t, a, term) != expected {
+				t.Errorf("%s: expected match of %q %t", pattern, term, expected)
+			}
+		}
+	}
+
+	a, err := NewRegexpAutomaton(regexp.MustCompile("^ab"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.CanMatch(a.Accept(a.Start(), 'b')) {
+		t.Errorf("expected no match of ^ab past b")
+	}
+}
@@ -0,0 +1,170 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package collectors
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search"
+)
+
+// TopFieldCollector collects the first matches in the
+// order of a field, see search.DocumentMatchesBySortKey.
+// The value of a document is the smallest of its doc
+// values for the field.
+type TopFieldCollector struct {
+	k             int
+	skip          int
+	field         string
+	indexReader   index.IndexReader
+	results       search.DocumentMatchesBySortKey
+	took          time.Duration
+	maxScore      float64
+	total         uint64
+	facetsBuilder *search.FacetsBuilder
+}
+
+func NewTopFieldCollector(k, skip int, field string, indexReader index.IndexReader) *TopFieldCollector {
+	return &TopFieldCollector{
+		k:           k,
+		skip:        skip,
+		field:       field,
+		indexReader: indexReader,
+		results:     make(search.DocumentMatchesBySortKey, 0, k+skip),
+	}
+}
+
+func (tfc *TopFieldCollector) Total() uint64 {
+	return tfc.total
+}
+
+func (tfc *TopFieldCollector) MaxScore() float64 {
+	return tfc.maxScore
+}
+
+func (tfc *TopFieldCollector) Took() time.Duration {
+	return tfc.took
+}
+
+func (tfc *TopFieldCollector) Collect(searcher search.Searcher) error {
+	startTime := time.Now()
+	err := tfc.collect(searcher)
+	// compute search duration
+	tfc.took = time.Since(startTime)
+	return err
+}
+
+func (tfc *TopFieldCollector) collect(searcher search.Searcher) error {
+	dvReader, err := tfc.indexReader.DocValueReader([]string{tfc.field})
+	if err != nil {
+		return err
+	}
+
+	// a searcher returning its matches in the order of the
+	// field can stop after the first k+skip, unless facets
+	// need to see all of them
+	if sortedSearcher, ok := searcher.(search.SortedSearcher); ok && tfc.facetsBuilder == nil {
+		sorted, err := sortedSearcher.SortBy(tfc.field)
+		if err != nil {
+			return err
+		}
+		if sorted {
+			tfc.total = searcher.Count()
+			for len(tfc.results) < tfc.k+tfc.skip {
+				next, err := searcher.Next()
+				if err != nil || next == nil {
+					return err
+				}
+				err = tfc.setSortKey(dvReader, next)
+				if err != nil {
+					return err
+				}
+				if next.Score > tfc.maxScore {
+					tfc.maxScore = next.Score
+				}
+				tfc.results = append(tfc.results, next)
+			}
+			return nil
+		}
+	}
+
+	next, err := searcher.Next()
+	for err == nil && next != nil {
+		err = tfc.setSortKey(dvReader, next)
+		if err != nil {
+			break
+		}
+		tfc.collectSingle(next)
+		if tfc.facetsBuilder != nil {
+			err = tfc.facetsBuilder.Update(next)
+			if err != nil {
+				break
+			}
+		}
+		next, err = searcher.Next()
+	}
+	return err
+}
+
+func (tfc *TopFieldCollector) setSortKey(dvReader index.DocValueReader, dm *search.DocumentMatch) error {
+	return dvReader.VisitDocValues(dm.ID, func(field string, term []byte) {
+		if dm.SortKey == nil || bytes.Compare(term, dm.SortKey) < 0 {
+			dm.SortKey = append([]byte(nil), term...)
+		}
+	})
+}
+
+func (tfc *TopFieldCollector) collectSingle(dm *search.DocumentMatch) {
+	// increment total hits
+	tfc.total++
+
+	// update max score
+	if dm.Score > tfc.maxScore {
+		tfc.maxScore = dm.Score
+	}
+
+	if tfc.k+tfc.skip <= 0 {
+		return
+	}
+	i := sort.Search(len(tfc.results), func(i int) bool {
+		return search.SortKeyLess(dm, tfc.results[i])
+	})
+	if i >= tfc.k+tfc.skip {
+		return
+	}
+	if len(tfc.results) < tfc.k+tfc.skip {
+		tfc.results = append(tfc.results, nil)
+	}
+	copy(tfc.results[i+1:], tfc.results[i:])
+	tfc.results[i] = dm
+}
+
+func (tfc *TopFieldCollector) Results() search.DocumentMatchCollection {
+	if len(tfc.results) > tfc.skip {
+		rv := make(search.DocumentMatchCollection, len(tfc.results)-tfc.skip)
+		copy(rv, tfc.results[tfc.skip:])
+		return rv
+	}
+	return search.DocumentMatchCollection{}
+}
+
+func (tfc *TopFieldCollector) SetFacetsBuilder(facetsBuilder *search.FacetsBuilder) {
+	tfc.facetsBuilder = facetsBuilder
+}
+
+func (tfc *TopFieldCollector) FacetResults() search.FacetResults {
+	if tfc.facetsBuilder != nil {
+		return tfc.facetsBuilder.Results()
+	}
+	return search.FacetResults{}
+}
@@ -0,0 +1,192 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package facets
+
+import (
+	"time"
+
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/numeric_util"
+	"github.com/blevesearch/bleve/search"
+)
+
+// dateHistogramInterval is either a fixed duration
+// or a whole number of calendar months
+type dateHistogramInterval struct {
+	duration time.Duration
+	months   int
+}
+
+func (i *dateHistogramInterval) truncate(t time.Time) time.Time {
+	if i.months == 0 {
+		return t.Truncate(i.duration)
+	}
+	monthsSinceZero := t.Year()*12 + int(t.Month()) - 1
+	monthsSinceZero -= monthsSinceZero % i.months
+	return time.Date(monthsSinceZero/12, time.Month(monthsSinceZero%12+1), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (i *dateHistogramInterval) next(t time.Time) time.Time {
+	if i.months == 0 {
+		return t.Add(i.duration)
+	}
+	return t.AddDate(0, i.months, 0)
+}
+
+// bucketsBetween returns the number of buckets
+// needed to cover the range from start to end
+// (both inclusive), start must already be truncated
+func (i *dateHistogramInterval) bucketsBetween(start, end time.Time) int64 {
+	if i.months == 0 {
+		return int64(end.Sub(start)/i.duration) + 1
+	}
+	months := (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month())
+	return int64(months/i.months) + 1
+}
+
+var fixedDateHistogramIntervals = []*dateHistogramInterval{
+	{duration: time.Second},
+	{duration: 5 * time.Second},
+	{duration: 10 * time.Second},
+	{duration: 30 * time.Second},
+	{duration: time.Minute},
+	{duration: 5 * time.Minute},
+	{duration: 10 * time.Minute},
+	{duration: 30 * time.Minute},
+	{duration: time.Hour},
+	{duration: 3 * time.Hour},
+	{duration: 12 * time.Hour},
+	{duration: 24 * time.Hour},
+	{duration: 7 * 24 * time.Hour},
+	{months: 1},
+	{months: 3},
+	{months: 12},
+}
+
+// yearMultiples are used to grow the interval
+// beyond one year for very wide ranges
+var yearMultiples = []int{1, 2, 5}
+
+// DateHistogramFacetBuilder groups date values into
+// regularly spaced buckets.  Rather than requiring
+// the caller to choose an interval, one is picked
+// automatically so that the number of buckets
+// does not exceed the requested size.
+type DateHistogramFacetBuilder struct {
+	buckets    int
+	field      string
+	timeCounts map[int64]int
+	total      int
+	missing    int
+}
+
+func NewDateHistogramFacetBuilder(field string, buckets int) *DateHistogramFacetBuilder {
+	return &DateHistogramFacetBuilder{
+		buckets:    buckets,
+		field:      field,
+		timeCounts: make(map[int64]int),
+	}
+}
+
+func (fb *DateHistogramFacetBuilder) Field() string {
+	return fb.field
+}
+
+func (fb *DateHistogramFacetBuilder) Update(ft index.FieldTerms) {
+	terms, ok := ft[fb.field]
+	if ok {
+		for _, term := range terms {
+			// only consider the values which are shifted 0
+			prefixCoded := numeric_util.PrefixCoded(term)
+			shift, err := prefixCoded.Shift()
+			if err == nil && shift == 0 {
+				i64, err := prefixCoded.Int64()
+				if err == nil {
+					fb.timeCounts[i64]++
+					fb.total++
+				}
+			}
+		}
+	} else {
+		fb.missing++
+	}
+}
+
+// chooseInterval returns the smallest interval
+// which covers the range min-max in no more than
+// the requested number of buckets
+func (fb *DateHistogramFacetBuilder) chooseInterval(min, max time.Time) *dateHistogramInterval {
+	for _, interval := range fixedDateHistogramIntervals {
+		if interval.bucketsBetween(interval.truncate(min), max) <= int64(fb.buckets) {
+			return interval
+		}
+	}
+	for years := 10; ; years *= 10 {
+		for _, multiple := range yearMultiples {
+			interval := &dateHistogramInterval{months: 12 * years / 10 * multiple}
+			if interval.bucketsBetween(interval.truncate(min), max) <= int64(fb.buckets) {
+				return interval
+			}
+		}
+	}
+}
+
+func (fb *DateHistogramFacetBuilder) Result() *search.FacetResult {
+	rv := search.FacetResult{
+		Field:   fb.field,
+		Total:   fb.total,
+		Missing: fb.missing,
+	}
+
+	rv.DateHistogram = make(search.DateRangeFacets, 0)
+	if len(fb.timeCounts) == 0 || fb.buckets < 1 {
+		rv.Other = fb.total
+		return &rv
+	}
+
+	first := true
+	var min, max int64
+	for i64 := range fb.timeCounts {
+		if first || i64 < min {
+			min = i64
+		}
+		if first || i64 > max {
+			max = i64
+		}
+		first = false
+	}
+	minTime := time.Unix(0, min).UTC()
+	maxTime := time.Unix(0, max).UTC()
+
+	interval := fb.chooseInterval(minTime, maxTime)
+
+	bucketCounts := make(map[int64]int)
+	for i64, count := range fb.timeCounts {
+		bucketStart := interval.truncate(time.Unix(0, i64).UTC())
+		bucketCounts[bucketStart.UnixNano()] += count
+	}
+
+	// walk the buckets in order, including the
+	// empty ones, so the histogram has no gaps
+	for start := interval.truncate(minTime); !start.After(maxTime); {
+		end := interval.next(start)
+		startString := start.Format(time.RFC3339Nano)
+		endString := end.Format(time.RFC3339Nano)
+		rv.DateHistogram = append(rv.DateHistogram, &search.DateRangeFacet{
+			Name:  startString,
+			Start: &startString,
+			End:   &endString,
+			Count: bucketCounts[start.UnixNano()],
+		})
+		start = end
+	}
+
+	return &rv
+}
@@ -0,0 +1,103 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package facets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/index"
+	nu "github.com/blevesearch/bleve/numeric_util"
+)
+
+func TestDateHistogramFacetBuilder(t *testing.T) {
+	tests := []struct {
+		buckets   int
+		times     []time.Time
+		starts    []string
+		counts    []int
+		numMissed int
+	}{
+		{
+			buckets: 4,
+			times: []time.Time{
+				time.Date(2015, 3, 1, 10, 15, 0, 0, time.UTC),
+				time.Date(2015, 3, 1, 10, 20, 0, 0, time.UTC),
+				time.Date(2015, 3, 1, 11, 45, 0, 0, time.UTC),
+				time.Date(2015, 3, 1, 13, 5, 0, 0, time.UTC),
+			},
+			starts: []string{
+				"2015-03-01T10:00:00Z",
+				"2015-03-01T11:00:00Z",
+				"2015-03-01T12:00:00Z",
+				"2015-03-01T13:00:00Z",
+			},
+			counts: []int{2, 1, 0, 1},
+		},
+		{
+			buckets: 5,
+			times: []time.Time{
+				time.Date(2014, 1, 15, 0, 0, 0, 0, time.UTC),
+				time.Date(2014, 6, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2014, 11, 30, 0, 0, 0, 0, time.UTC),
+			},
+			starts: []string{
+				"2014-01-01T00:00:00Z",
+				"2014-04-01T00:00:00Z",
+				"2014-07-01T00:00:00Z",
+				"2014-10-01T00:00:00Z",
+			},
+			counts: []int{1, 1, 0, 1},
+		},
+		{
+			buckets: 3,
+			times: []time.Time{
+				time.Date(1901, 1, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			starts: []string{
+				"1900-01-01T00:00:00Z",
+				"1950-01-01T00:00:00Z",
+				"2000-01-01T00:00:00Z",
+			},
+			counts:    []int{1, 0, 1},
+			numMissed: 1,
+		},
+	}
+
+	for _, test := range tests {
+		fb := NewDateHistogramFacetBuilder("date", test.buckets)
+		for _, tm := range test.times {
+			term := nu.MustNewPrefixCodedInt64(tm.UnixNano(), 0)
+			fb.Update(index.FieldTerms{"date": []string{string(term)}})
+		}
+		for i := 0; i < test.numMissed; i++ {
+			fb.Update(index.FieldTerms{"other": []string{"x"}})
+		}
+		result := fb.Result()
+		if result.Total != len(test.times) {
+			t.Errorf("expected total %d, got %d", len(test.times), result.Total)
+		}
+		if result.Missing != test.numMissed {
+			t.Errorf("expected missing %d, got %d", test.numMissed, result.Missing)
+		}
+		if len(result.DateHistogram) != len(test.starts) {
+			t.Fatalf("expected %d buckets, got %d: %v", len(test.starts), len(result.DateHistogram), result.DateHistogram)
+		}
+		for i, bucket := range result.DateHistogram {
+			if *bucket.Start != test.starts[i] {
+				t.Errorf("expected bucket %d to start at %s, got %s", i, test.starts[i], *bucket.Start)
+			}
+			if bucket.Count != test.counts[i] {
+				t.Errorf("expected bucket %d count %d, got %d", i, test.counts[i], bucket.Count)
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package facets
+
+import (
+	"hash/fnv"
+	"sort"
+
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search"
+)
+
+const rareTermsBloomBits = 1 << 16
+const rareTermsBloomHashes = 3
+
+// bloomFilter is a minimal fixed size bloom filter
+// used to remember terms which are no longer rare
+type bloomFilter struct {
+	bits []uint64
+}
+
+func newBloomFilter(numBits int) *bloomFilter {
+	return &bloomFilter{
+		bits: make([]uint64, (numBits+63)/64),
+	}
+}
+
+func (b *bloomFilter) locations(term string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(term))
+	sum := h.Sum64()
+	h1 := sum & 0xffffffff
+	h2 := sum >> 32
+	numBits := uint64(len(b.bits) * 64)
+	rv := make([]uint64, rareTermsBloomHashes)
+	for i := range rv {
+		rv[i] = (h1 + uint64(i)*h2) % numBits
+	}
+	return rv
+}
+
+func (b *bloomFilter) Add(term string) {
+	for _, loc := range b.locations(term) {
+		b.bits[loc/64] |= 1 << (loc % 64)
+	}
+}
+
+func (b *bloomFilter) Test(term string) bool {
+	for _, loc := range b.locations(term) {
+		if b.bits[loc/64]&(1<<(loc%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// RareTermsFacetBuilder finds the terms occurring
+// no more than maxCount times.  Exact counts are only
+// kept while a term is still rare, once it exceeds
+// maxCount it is dropped from the map and recorded in
+// a bloom filter so that later occurrences are ignored.
+// As a result memory use is bounded by the number of
+// rare terms, at the cost of occasionally missing a
+// rare term which collides in the bloom filter.
+type RareTermsFacetBuilder struct {
+	size       int
+	maxCount   int
+	field      string
+	termsCount map[string]int
+	frequent   *bloomFilter
+	total      int
+	missing    int
+}
+
+func NewRareTermsFacetBuilder(field string, size, maxCount int) *RareTermsFacetBuilder {
+	return &RareTermsFacetBuilder{
+		size:       size,
+		maxCount:   maxCount,
+		field:      field,
+		termsCount: make(map[string]int),
+		frequent:   newBloomFilter(rareTermsBloomBits),
+	}
+}
+
+func (fb *RareTermsFacetBuilder) Field() string {
+	return fb.field
+}
+
+func (fb *RareTermsFacetBuilder) Update(ft index.FieldTerms) {
+	terms, ok := ft[fb.field]
+	if ok {
+		for _, term := range terms {
+			fb.total++
+			existingCount, existed := fb.termsCount[term]
+			if !existed && fb.frequent.Test(term) {
+				continue
+			}
+			if existingCount+1 > fb.maxCount {
+				delete(fb.termsCount, term)
+				fb.frequent.Add(term)
+				continue
+			}
+			fb.termsCount[term] = existingCount + 1
+		}
+	} else {
+		fb.missing++
+	}
+}
+
+func (fb *RareTermsFacetBuilder) Result() *search.FacetResult {
+	rv := search.FacetResult{
+		Field:        fb.field,
+		Total:        fb.total,
+		Missing:      fb.missing,
+		RareTermsMax: fb.maxCount,
+	}
+
+	rv.RareTerms = make([]*search.TermFacet, 0, len(fb.termsCount))
+
+	for term, count := range fb.termsCount {
+		tf := &search.TermFacet{
+			Term:  term,
+			Count: count,
+		}
+
+		rv.RareTerms = append(rv.RareTerms, tf)
+	}
+
+	sort.Sort(search.RareTermFacets(rv.RareTerms))
+
+	// we now have the list of the rarest N facets
+	trimTopN := fb.size
+	if trimTopN > len(rv.RareTerms) {
+		trimTopN = len(rv.RareTerms)
+	}
+	rv.RareTerms = rv.RareTerms[:trimTopN]
+
+	notOther := 0
+	for _, tf := range rv.RareTerms {
+		notOther += tf.Count
+	}
+	rv.Other = fb.total - notOther
+
+	return &rv
+}
@@ -0,0 +1,64 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package facets
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search"
+)
+
+func TestRareTermsFacetBuilder(t *testing.T) {
+	docs := [][]string{
+		{"blog"},
+		{"blog", "news"},
+		{"blog"},
+		{"comment"},
+		{"news"},
+		{"blog", "feedback"},
+	}
+
+	fb := NewRareTermsFacetBuilder("type", 10, 2)
+	for _, terms := range docs {
+		fb.Update(index.FieldTerms{"type": terms})
+	}
+	fb.Update(index.FieldTerms{"category": []string{"x"}})
+
+	result := fb.Result()
+	expectedTerms := search.TermFacets{
+		&search.TermFacet{Term: "comment", Count: 1},
+		&search.TermFacet{Term: "feedback", Count: 1},
+		&search.TermFacet{Term: "news", Count: 2},
+	}
+	if !reflect.DeepEqual(result.RareTerms, expectedTerms) {
+		t.Errorf("expected %v, got %v", expectedTerms, result.RareTerms)
+	}
+	if result.Total != 8 {
+		t.Errorf("expected total 8, got %d", result.Total)
+	}
+	if result.Missing != 1 {
+		t.Errorf("expected missing 1, got %d", result.Missing)
+	}
+	if result.Other != 4 {
+		t.Errorf("expected other 4, got %d", result.Other)
+	}
+
+	// size limits the result to the rarest terms
+	fb = NewRareTermsFacetBuilder("type", 1, 2)
+	for _, terms := range docs {
+		fb.Update(index.FieldTerms{"type": terms})
+	}
+	result = fb.Result()
+	if len(result.RareTerms) != 1 || result.RareTerms[0].Term != "comment" {
+		t.Errorf("expected only comment, got %v", result.RareTerms)
+	}
+}
@@ -18,11 +18,14 @@ import (
 type FacetBuilder interface {
 	Update(index.FieldTerms)
 	Result() *FacetResult
+	Field() string
 }
 
 type FacetsBuilder struct {
 	indexReader index.IndexReader
 	facets      map[string]FacetBuilder
+	fields      []string
+	dvReader    index.DocValueReader
 }
 
 func NewFacetsBuilder(indexReader index.IndexReader) *FacetsBuilder {
@@ -34,10 +37,21 @@ func NewFacetsBuilder(indexReader index.IndexReader) *FacetsBuilder {
 
 func (fb *FacetsBuilder) Add(name string, facetBuilder FacetBuilder) {
 	fb.facets[name] = facetBuilder
+	fb.fields = append(fb.fields, facetBuilder.Field())
 }
 
 func (fb *FacetsBuilder) Update(docMatch *DocumentMatch) error {
-	fieldTerms, err := fb.indexReader.DocumentFieldTerms(docMatch.ID)
+	if fb.dvReader == nil {
+		dvReader, err := fb.indexReader.DocValueReader(fb.fields)
+		if err != nil {
+			return err
+		}
+		fb.dvReader = dvReader
+	}
+	fieldTerms := make(index.FieldTerms, len(fb.fields))
+	err := fb.dvReader.VisitDocValues(docMatch.ID, func(field string, term []byte) {
+		fieldTerms[field] = append(fieldTerms[field], string(term))
+	})
 	if err != nil {
 		return err
 	}
@@ -70,6 +84,19 @@ func (tf TermFacets) Len() int           { return len(tf) }
 func (tf TermFacets) Swap(i, j int)      { tf[i], tf[j] = tf[j], tf[i] }
 func (tf TermFacets) Less(i, j int) bool { return tf[i].Count > tf[j].Count }
 
+// RareTermFacets orders terms by ascending count,
+// breaking ties by term so results are stable
+type RareTermFacets []*TermFacet
+
+func (tf RareTermFacets) Len() int      { return len(tf) }
+func (tf RareTermFacets) Swap(i, j int) { tf[i], tf[j] = tf[j], tf[i] }
+func (tf RareTermFacets) Less(i, j int) bool {
+	if tf[i].Count == tf[j].Count {
+		return tf[i].Term < tf[j].Term
+	}
+	return tf[i].Count < tf[j].Count
+}
+
 type NumericRangeFacet struct {
 	Name  string   `json:"name"`
 	Min   *float64 `json:"min,omitempty"`
@@ -116,10 +143,30 @@ func (drf DateRangeFacets) Add(dateRangeFacet *DateRangeFacet) DateRangeFacets {
 	return drf
 }
 
+// addBucket merges histogram buckets by their start
+// rather than by pointer, buckets from different
+// indexes only line up when the same interval was chosen
+func (drf DateRangeFacets) addBucket(bucket *DateRangeFacet) DateRangeFacets {
+	for _, existingDr := range drf {
+		if existingDr.Name == bucket.Name {
+			existingDr.Count += bucket.Count
+			return drf
+		}
+	}
+	drf = append(drf, bucket)
+	return drf
+}
+
 func (drf DateRangeFacets) Len() int           { return len(drf) }
 func (drf DateRangeFacets) Swap(i, j int)      { drf[i], drf[j] = drf[j], drf[i] }
 func (drf DateRangeFacets) Less(i, j int) bool { return drf[i].Count > drf[j].Count }
 
+type dateHistogramBuckets DateRangeFacets
+
+func (b dateHistogramBuckets) Len() int           { return len(b) }
+func (b dateHistogramBuckets) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b dateHistogramBuckets) Less(i, j int) bool { return *b[i].Start < *b[j].Start }
+
 type FacetResult struct {
 	Field         string             `json:"field"`
 	Total         int                `json:"total"`
@@ -128,6 +175,9 @@ type FacetResult struct {
 	Terms         TermFacets         `json:"terms,omitempty"`
 	NumericRanges NumericRangeFacets `json:"numeric_ranges,omitempty"`
 	DateRanges    DateRangeFacets    `json:"date_ranges,omitempty"`
+	DateHistogram DateRangeFacets    `json:"date_histogram,omitempty"`
+	RareTerms     TermFacets         `json:"rare_terms,omitempty"`
+	RareTermsMax  int                `json:"rare_terms_max_count,omitempty"`
 }
 
 func (fr *FacetResult) Merge(other *FacetResult) {
@@ -149,6 +199,27 @@ func (fr *FacetResult) Merge(other *FacetResult) {
 			fr.DateRanges = fr.DateRanges.Add(dr)
 		}
 	}
+	if fr.DateHistogram != nil && other.DateHistogram != nil {
+		for _, b := range other.DateHistogram {
+			fr.DateHistogram = fr.DateHistogram.addBucket(b)
+		}
+	}
+	if fr.RareTerms != nil && other.RareTerms != nil {
+		for _, term := range other.RareTerms {
+			fr.RareTerms = fr.RareTerms.Add(term)
+		}
+		// a term rare in each index can be too frequent
+		// once the counts are added up
+		rareTerms := fr.RareTerms[:0]
+		for _, term := range fr.RareTerms {
+			if fr.RareTermsMax > 0 && term.Count > fr.RareTermsMax {
+				fr.Other += term.Count
+				continue
+			}
+			rareTerms = append(rareTerms, term)
+		}
+		fr.RareTerms = rareTerms
+	}
 }
 
 func (fr *FacetResult) Fixup(size int) {
@@ -179,6 +250,19 @@ func (fr *FacetResult) Fixup(size int) {
 			}
 			fr.DateRanges = fr.DateRanges[0:size]
 		}
+	} else if fr.DateHistogram != nil {
+		// histogram buckets stay in chronological order
+		// and the builder already limited their number
+		sort.Sort(dateHistogramBuckets(fr.DateHistogram))
+	} else if fr.RareTerms != nil {
+		sort.Sort(RareTermFacets(fr.RareTerms))
+		if len(fr.RareTerms) > size {
+			moveToOther := fr.RareTerms[size:]
+			for _, mto := range moveToOther {
+				fr.Other += mto.Count
+			}
+			fr.RareTerms = fr.RareTerms[0:size]
+		}
 	}
 }
 
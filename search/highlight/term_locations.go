@@ -22,6 +22,7 @@ type TermLocation struct {
 	Pos            int
 	Start          int
 	End            int
+	Payload        []byte
 }
 
 func (tl *TermLocation) Overlaps(other *TermLocation) bool {
@@ -91,6 +92,7 @@ func OrderTermLocations(tlm search.TermLocationMap) TermLocations {
 				Pos:            int(location.Pos),
 				Start:          int(location.Start),
 				End:            int(location.End),
+				Payload:        location.Payload,
 			}
 			rv = append(rv, &tl)
 		}
@@ -0,0 +1,233 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"unicode/utf8"
+)
+
+// RegexpAutomaton matches the terms starting with a prefix
+// which a regular expression matches anywhere, the same
+// as Regexp.MatchString.  The program of the expression is
+// run on all threads at once, a state is the set of
+// instructions waiting for the next rune.
+type RegexpAutomaton struct {
+	prog   *syntax.Prog
+	prefix string
+	// the expression only matches at the start of a term
+	anchored bool
+	dfa      lazyDFA
+	states   []*regexpState
+}
+
+type regexpState struct {
+	// the number of bytes of the prefix seen, -1 once one
+	// differs
+	prefix  int
+	matched bool
+	// no term through the state is matched
+	dead bool
+	pcs  []uint32
+	// the rune before, reduced to what the empty width
+	// assertions look at, -1 at the start of the term
+	prev rune
+	// the bytes of an incomplete rune
+	partial []byte
+	// a term ending in the state is matched
+	matchAtEnd bool
+}
+
+func NewRegexpAutomaton(pattern *regexp.Regexp, prefix string) (*RegexpAutomaton, error) {
+	re, err := syntax.Parse(pattern.String(), syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	rv := RegexpAutomaton{
+		prog:     prog,
+		prefix:   prefix,
+		anchored: prog.StartCond()&syntax.EmptyBeginText != 0,
+	}
+	rv.add(&regexpState{prev: -1})
+	return &rv, nil
+}
+
+func (a *RegexpAutomaton) add(s *regexpState) int {
+	if s.prefix < 0 || (a.anchored && !s.matched && len(s.pcs) == 0 && s.prev != -1) {
+		s = &regexpState{prefix: -1, dead: true}
+	} else if s.matched {
+		s = &regexpState{prefix: s.prefix, matched: true, matchAtEnd: true}
+	} else {
+		end := *s
+		a.flush(&end)
+		if end.matched {
+			s.matchAtEnd = true
+		} else {
+			_, s.matchAtEnd = a.closure(&end, syntax.EmptyOpContext(end.prev, -1))
+		}
+	}
+	n, isNew := a.dfa.number(fmt.Sprint(s.prefix, s.matched, s.dead, s.pcs, s.prev, s.partial))
+	if isNew {
+		a.states = append(a.states, s)
+	}
+	return n
+}
+
+// closure follows the instructions of the state which do
+// not consume a rune, it returns those which do and whether
+// the expression matched
+func (a *RegexpAutomaton) closure(s *regexpState, context syntax.EmptyOp) ([]uint32, bool) {
+	stack := make([]uint32, 0, len(s.pcs)+1)
+	stack = append(stack, s.pcs...)
+	if !a.anchored || s.prev == -1 {
+		stack = append(stack, uint32(a.prog.Start))
+	}
+	visited := make(map[uint32]bool)
+	rv := make([]uint32, 0)
+	matched := false
+	for len(stack) > 0 {
+		pc := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[pc] {
+			continue
+		}
+		visited[pc] = true
+		inst := &a.prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			stack = append(stack, inst.Out, inst.Arg)
+		case syntax.InstCapture, syntax.InstNop:
+			stack = append(stack, inst.Out)
+		case syntax.InstEmptyWidth:
+			if syntax.EmptyOp(inst.Arg)&^context == 0 {
+				stack = append(stack, inst.Out)
+			}
+		case syntax.InstMatch:
+			matched = true
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			rv = append(rv, pc)
+		}
+	}
+	return rv, matched
+}
+
+func matchRune(inst *syntax.Inst, r rune) bool {
+	switch inst.Op {
+	case syntax.InstRune1:
+		return r == inst.Rune[0]
+	case syntax.InstRuneAny:
+		return true
+	case syntax.InstRuneAnyNotNL:
+		return r != '\n'
+	}
+	return inst.MatchRune(r)
+}
+
+type pcs []uint32
+
+func (p pcs) Len() int           { return len(p) }
+func (p pcs) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p pcs) Less(i, j int) bool { return p[i] < p[j] }
+
+// step moves the threads of the state over a rune
+func (a *RegexpAutomaton) step(s *regexpState, r rune) {
+	waiting, matched := a.closure(s, syntax.EmptyOpContext(s.prev, r))
+	if matched {
+		s.matched = true
+		return
+	}
+	next := make(map[uint32]bool)
+	for _, pc := range waiting {
+		inst := &a.prog.Inst[pc]
+		if matchRune(inst, r) {
+			next[inst.Out] = true
+		}
+	}
+	s.pcs = make([]uint32, 0, len(next))
+	for pc := range next {
+		s.pcs = append(s.pcs, pc)
+	}
+	sort.Sort(pcs(s.pcs))
+	switch {
+	case r == '\n':
+		s.prev = '\n'
+	case syntax.IsWordChar(r):
+		s.prev = 'a'
+	default:
+		s.prev = ' '
+	}
+}
+
+// decode steps over the complete runes of the bytes of
+// the state
+func (a *RegexpAutomaton) decode(s *regexpState) {
+	for len(s.partial) > 0 && !s.matched && utf8.FullRune(s.partial) {
+		r, size := utf8.DecodeRune(s.partial)
+		a.step(s, r)
+		s.partial = s.partial[size:]
+	}
+}
+
+// flush steps over the bytes of an incomplete rune at the
+// end of a term, each is an invalid rune
+func (a *RegexpAutomaton) flush(s *regexpState) {
+	for i := 0; i < len(s.partial) && !s.matched; i++ {
+		a.step(s, utf8.RuneError)
+	}
+	s.partial = nil
+}
+
+func (a *RegexpAutomaton) Start() int {
+	return 0
+}
+
+func (a *RegexpAutomaton) IsMatch(state int) bool {
+	s := a.states[state]
+	return s.prefix == len(a.prefix) && s.matchAtEnd
+}
+
+func (a *RegexpAutomaton) CanMatch(state int) bool {
+	return !a.states[state].dead
+}
+
+func (a *RegexpAutomaton) Accept(state int, b byte) int {
+	if next, ok := a.dfa.next(state, b); ok {
+		return next
+	}
+	s := a.states[state]
+	rv := regexpState{
+		prefix:  s.prefix,
+		matched: s.matched,
+		dead:    s.dead,
+		pcs:     s.pcs,
+		prev:    s.prev,
+	}
+	if rv.prefix >= 0 && rv.prefix < len(a.prefix) {
+		if a.prefix[rv.prefix] == b {
+			rv.prefix++
+		} else {
+			rv.prefix = -1
+		}
+	}
+	if !rv.matched && !rv.dead {
+		rv.partial = append(append([]byte{}, s.partial...), b)
+		a.decode(&rv)
+	}
+	next := a.add(&rv)
+	a.dfa.setNext(state, b, next)
+	return next
+}
@@ -11,7 +11,6 @@ package scorers
 
 import (
 	"fmt"
-	"math"
 
 	"github.com/blevesearch/bleve/index"
 	"github.com/blevesearch/bleve/search"
@@ -24,6 +23,7 @@ type TermQueryScorer struct {
 	docTerm                uint64
 	docTotal               uint64
 	idf                    float64
+	similarity             Similarity
 	explain                bool
 	idfExplanation         *search.Explanation
 	queryNorm              float64
@@ -32,13 +32,20 @@ type TermQueryScorer struct {
 }
 
 func NewTermQueryScorer(queryTerm string, queryField string, queryBoost float64, docTotal, docTerm uint64, explain bool) *TermQueryScorer {
+	return NewTermQueryScorerWithSimilarity(queryTerm, queryField, queryBoost, docTotal, docTerm, ClassicSimilarity{}, explain)
+}
+
+// NewTermQueryScorerWithSimilarity scores the matches of
+// the term with similarity instead of the classic tf-idf
+func NewTermQueryScorerWithSimilarity(queryTerm string, queryField string, queryBoost float64, docTotal, docTerm uint64, similarity Similarity, explain bool) *TermQueryScorer {
 	rv := TermQueryScorer{
 		queryTerm:   queryTerm,
 		queryField:  queryField,
 		queryBoost:  queryBoost,
 		docTerm:     docTerm,
 		docTotal:    docTotal,
-		idf:         1.0 + math.Log(float64(docTotal)/float64(docTerm+1.0)),
+		idf:         similarity.Idf(docTotal, docTerm),
+		similarity:  similarity,
 		explain:     explain,
 		queryWeight: 1.0,
 	}
@@ -87,25 +94,33 @@ func (s *TermQueryScorer) Score(termMatch *index.TermFieldDoc) *search.DocumentM
 	var scoreExplanation *search.Explanation
 
 	// need to compute score
-	var tf float64
-	if termMatch.Freq < MaxSqrtCache {
-		tf = SqrtCache[int(termMatch.Freq)]
-	} else {
-		tf = math.Sqrt(float64(termMatch.Freq))
-	}
-	score := tf * termMatch.Norm * s.idf
+	tf := s.similarity.Tf(termMatch.Freq, termMatch.Norm)
+	score := tf * s.idf
 
 	if s.explain {
-		childrenExplanations := make([]*search.Explanation, 3)
-		childrenExplanations[0] = &search.Explanation{
-			Value:   tf,
-			Message: fmt.Sprintf("tf(termFreq(%s:%s)=%d", s.queryField, string(s.queryTerm), termMatch.Freq),
-		}
-		childrenExplanations[1] = &search.Explanation{
-			Value:   termMatch.Norm,
-			Message: fmt.Sprintf("fieldNorm(field=%s, doc=%s)", s.queryField, termMatch.ID),
+		var childrenExplanations []*search.Explanation
+		if classic, ok := s.similarity.(ClassicSimilarity); ok {
+			// the classic tf is a product with the norm
+			childrenExplanations = []*search.Explanation{
+				&search.Explanation{
+					Value:   classic.Tf(termMatch.Freq, 1.0),
+					Message: fmt.Sprintf("tf(termFreq(%s:%s)=%d", s.queryField, string(s.queryTerm), termMatch.Freq),
+				},
+				&search.Explanation{
+					Value:   termMatch.Norm,
+					Message: fmt.Sprintf("fieldNorm(field=%s, doc=%s)", s.queryField, termMatch.ID),
+				},
+				s.idfExplanation,
+			}
+		} else {
+			childrenExplanations = []*search.Explanation{
+				&search.Explanation{
+					Value:   tf,
+					Message: fmt.Sprintf("tf(termFreq(%s:%s)=%d, fieldNorm(field=%s, doc=%s)=%f)", s.queryField, string(s.queryTerm), termMatch.Freq, s.queryField, termMatch.ID, termMatch.Norm),
+				},
+				s.idfExplanation,
+			}
 		}
-		childrenExplanations[2] = s.idfExplanation
 		scoreExplanation = &search.Explanation{
 			Value:    score,
 			Message:  fmt.Sprintf("fieldWeight(%s:%s in %s), product of:", s.queryField, string(s.queryTerm), termMatch.ID),
@@ -146,9 +161,10 @@ func (s *TermQueryScorer) Score(termMatch *index.TermFieldDoc) *search.DocumentM
 			}
 
 			loc := search.Location{
-				Pos:   float64(v.Pos),
-				Start: float64(v.Start),
-				End:   float64(v.End),
+				Pos:     float64(v.Pos),
+				Start:   float64(v.Start),
+				End:     float64(v.End),
+				Payload: v.Payload,
 			}
 
 			if len(v.ArrayPositions) > 0 {
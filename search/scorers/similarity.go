@@ -0,0 +1,123 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package scorers
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// A Similarity says how well a document matches a term.
+// Idf weighs the term by the number of documents with it,
+// docTerm of docTotal, and Tf weighs its freq in a document,
+// with the norm of the field, 1 over the square root of the
+// number of terms in the field.  The score of a match is
+// the product of the two.
+type Similarity interface {
+	Idf(docTotal, docTerm uint64) float64
+	Tf(freq uint64, norm float64) float64
+}
+
+// The names of the built in similarities
+const (
+	ClassicSimilarityName = "classic"
+	BM25SimilarityName    = "bm25"
+	BooleanSimilarityName = "boolean"
+)
+
+// ClassicSimilarity is tf-idf, the default similarity
+type ClassicSimilarity struct{}
+
+func (ClassicSimilarity) Idf(docTotal, docTerm uint64) float64 {
+	return 1.0 + math.Log(float64(docTotal)/float64(docTerm+1.0))
+}
+
+func (ClassicSimilarity) Tf(freq uint64, norm float64) float64 {
+	if freq < MaxSqrtCache {
+		return SqrtCache[int(freq)] * norm
+	}
+	return math.Sqrt(float64(freq)) * norm
+}
+
+// BM25Similarity saturates the frequencies of terms, K1
+// says how fast, and normalizes by the length of the field
+// relative to the AvgFieldLength, as much as B says.
+// Without an AvgFieldLength the lengths are not normalized,
+// a field mapping naming a BM25Similarity without one gets
+// the average length of the field in the index at search
+// time instead.
+type BM25Similarity struct {
+	K1             float64
+	B              float64
+	AvgFieldLength float64
+}
+
+func (s *BM25Similarity) Idf(docTotal, docTerm uint64) float64 {
+	return math.Log(1.0 + (float64(docTotal)-float64(docTerm)+0.5)/(float64(docTerm)+0.5))
+}
+
+func (s *BM25Similarity) Tf(freq uint64, norm float64) float64 {
+	lengthNorm := 1.0
+	if s.AvgFieldLength > 0 && norm > 0 {
+		fieldLength := 1.0 / (norm * norm)
+		lengthNorm = 1.0 - s.B + s.B*fieldLength/s.AvgFieldLength
+	}
+	f := float64(freq)
+	return f * (s.K1 + 1.0) / (f + s.K1*lengthNorm)
+}
+
+// BooleanSimilarity scores each matching term with 1, so
+// that only the boosts of the queries count, like for tags
+type BooleanSimilarity struct{}
+
+func (BooleanSimilarity) Idf(docTotal, docTerm uint64) float64 {
+	return 1.0
+}
+
+func (BooleanSimilarity) Tf(freq uint64, norm float64) float64 {
+	return 1.0
+}
+
+var similaritiesMutex sync.RWMutex
+var similarities = map[string]Similarity{
+	ClassicSimilarityName: ClassicSimilarity{},
+	BM25SimilarityName:    &BM25Similarity{K1: 1.2, B: 0.75},
+	BooleanSimilarityName: BooleanSimilarity{},
+}
+
+// RegisterSimilarity makes a Similarity available to field
+// mappings by name
+func RegisterSimilarity(name string, similarity Similarity) {
+	similaritiesMutex.Lock()
+	defer similaritiesMutex.Unlock()
+	similarities[name] = similarity
+}
+
+// SimilarityNamed returns the Similarity registered as
+// name, or nil
+func SimilarityNamed(name string) Similarity {
+	similaritiesMutex.RLock()
+	defer similaritiesMutex.RUnlock()
+	return similarities[name]
+}
+
+// SimilarityNames returns the names of the registered
+// similarities, sorted
+func SimilarityNames() []string {
+	similaritiesMutex.RLock()
+	defer similaritiesMutex.RUnlock()
+	rv := make([]string, 0, len(similarities))
+	for name := range similarities {
+		rv = append(rv, name)
+	}
+	sort.Strings(rv)
+	return rv
+}
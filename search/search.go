@@ -9,11 +9,16 @@
 
 package search
 
+import (
+	"bytes"
+)
+
 type Location struct {
 	Pos            float64   `json:"pos"`
 	Start          float64   `json:"start"`
 	End            float64   `json:"end"`
 	ArrayPositions []float64 `json:"array_positions"`
+	Payload        []byte    `json:"payload,omitempty"`
 }
 
 type Locations []*Location
@@ -43,6 +48,11 @@ type DocumentMatch struct {
 	Locations FieldTermLocationMap   `json:"locations,omitempty"`
 	Fragments FieldFragmentMap       `json:"fragments,omitempty"`
 	Fields    map[string]interface{} `json:"fields,omitempty"`
+
+	// SortKey is the value of the sort field of a search
+	// sorted by field, nil when the document has none, it is
+	// serialized so remote results can be merged in order
+	SortKey []byte `json:"sort_key"`
 }
 
 func (dm *DocumentMatch) AddFieldValue(name string, value interface{}) {
@@ -71,6 +81,28 @@ func (c DocumentMatchCollection) Len() int           { return len(c) }
 func (c DocumentMatchCollection) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
 func (c DocumentMatchCollection) Less(i, j int) bool { return c[i].Score > c[j].Score }
 
+// DocumentMatchesBySortKey orders matches by ascending
+// SortKey, matches without one come last and ties are
+// broken by id
+type DocumentMatchesBySortKey []*DocumentMatch
+
+func (c DocumentMatchesBySortKey) Len() int      { return len(c) }
+func (c DocumentMatchesBySortKey) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c DocumentMatchesBySortKey) Less(i, j int) bool {
+	return SortKeyLess(c[i], c[j])
+}
+
+func SortKeyLess(a, b *DocumentMatch) bool {
+	if (a.SortKey == nil) != (b.SortKey == nil) {
+		return a.SortKey != nil
+	}
+	cmp := bytes.Compare(a.SortKey, b.SortKey)
+	if cmp != 0 {
+		return cmp < 0
+	}
+	return a.ID < b.ID
+}
+
 type Searcher interface {
 	Next() (*DocumentMatch, error)
 	Advance(ID string) (*DocumentMatch, error)
@@ -80,3 +112,12 @@ type Searcher interface {
 	Count() uint64
 	Min() int
 }
+
+// A SortedSearcher can return its matches in the order of
+// a field instead of id order, SortBy reports whether it
+// will do so for the field.  A sorted searcher can no
+// longer Advance.
+type SortedSearcher interface {
+	Searcher
+	SortBy(field string) (bool, error)
+}
@@ -0,0 +1,54 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package searchers
+
+import (
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search"
+)
+
+// A docSetSearcher can give the documents it matches as a
+// DocSet, it gives nil when the index reader keeps none
+type docSetSearcher interface {
+	docSet() (index.DocSet, error)
+}
+
+// searchersDocSet puts together the DocSets of the
+// searchers which give one, all is false when some do not
+func searchersDocSet(searchers []search.Searcher, combine func(a, b index.DocSet) index.DocSet) (rv index.DocSet, all bool, err error) {
+	all = true
+	for _, searcher := range searchers {
+		var docs index.DocSet
+		if s, ok := searcher.(docSetSearcher); ok {
+			docs, err = s.docSet()
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		if docs == nil {
+			all = false
+			continue
+		}
+		if rv == nil {
+			rv = docs
+		} else {
+			rv = combine(rv, docs)
+		}
+	}
+	return rv, all, nil
+}
+
+func intersectDocSets(a, b index.DocSet) index.DocSet {
+	return a.Intersect(b)
+}
+
+func unionDocSets(a, b index.DocSet) index.DocSet {
+	return a.Union(b)
+}
@@ -27,6 +27,12 @@ type ConjunctionSearcher struct {
 	currs       []*search.DocumentMatch
 	currentID   string
 	scorer      *scorers.ConjunctionQueryScorer
+	// documents matched by the searchers which give a
+	// DocSet, candidates walks them when there are any
+	docsInitialized bool
+	docs            index.DocSet
+	docsAll         bool
+	candidates      index.DocIDReader
 }
 
 func NewConjunctionSearcher(indexReader index.IndexReader, qsearchers []search.Searcher, explain bool) (*ConjunctionSearcher, error) {
@@ -85,6 +91,57 @@ func (s *ConjunctionSearcher) initSearchers() error {
 	return nil
 }
 
+func (s *ConjunctionSearcher) initDocSet() error {
+	if s.docsInitialized {
+		return nil
+	}
+	var err error
+	s.docs, s.docsAll, err = searchersDocSet(s.searchers, intersectDocSets)
+	if err != nil {
+		return err
+	}
+	if s.docs != nil {
+		s.candidates = s.docs.DocIDReader()
+	}
+	s.docsInitialized = true
+	return nil
+}
+
+// docSet is the intersection of the DocSets of the
+// searchers, there is none unless all of them give one
+func (s *ConjunctionSearcher) docSet() (index.DocSet, error) {
+	err := s.initDocSet()
+	if err != nil || !s.docsAll {
+		return nil, err
+	}
+	return s.docs, nil
+}
+
+// nextCandidate moves the searchers to the first candidate
+// from id on that all of them match.  Searchers are only
+// advanced to documents in the DocSet of the others, and
+// whatever a searcher without one matches moves the
+// candidates along.
+func (s *ConjunctionSearcher) nextCandidate(id string, err error) (*search.DocumentMatch, error) {
+OUTER:
+	for err == nil && id != "" {
+		for i, searcher := range s.searchers {
+			if s.currs[i] == nil || s.currs[i].ID < id {
+				s.currs[i], err = searcher.Advance(id)
+				if err != nil || s.currs[i] == nil {
+					return nil, err
+				}
+			}
+			if s.currs[i].ID != id {
+				id, err = s.candidates.Advance(s.currs[i].ID)
+				continue OUTER
+			}
+		}
+		return s.scorer.Score(s.currs), nil
+	}
+	return nil, err
+}
+
 func (s *ConjunctionSearcher) Weight() float64 {
 	var rv float64
 	for _, searcher := range s.searchers {
@@ -100,6 +157,13 @@ func (s *ConjunctionSearcher) SetQueryNorm(qnorm float64) {
 }
 
 func (s *ConjunctionSearcher) Next() (*search.DocumentMatch, error) {
+	err := s.initDocSet()
+	if err != nil {
+		return nil, err
+	}
+	if s.candidates != nil {
+		return s.nextCandidate(s.candidates.Next())
+	}
 	if !s.initialized {
 		err := s.initSearchers()
 		if err != nil {
@@ -107,7 +171,6 @@ func (s *ConjunctionSearcher) Next() (*search.DocumentMatch, error) {
 		}
 	}
 	var rv *search.DocumentMatch
-	var err error
 OUTER:
 	for s.currentID != "" {
 		for i, termSearcher := range s.searchers {
@@ -156,13 +219,19 @@ OUTER:
 }
 
 func (s *ConjunctionSearcher) Advance(ID string) (*search.DocumentMatch, error) {
+	err := s.initDocSet()
+	if err != nil {
+		return nil, err
+	}
+	if s.candidates != nil {
+		return s.nextCandidate(s.candidates.Advance(ID))
+	}
 	if !s.initialized {
 		err := s.initSearchers()
 		if err != nil {
 			return nil, err
 		}
 	}
-	var err error
 	for i, searcher := range s.searchers {
 		s.currs[i], err = searcher.Advance(ID)
 		if err != nil {
@@ -174,6 +243,9 @@ func (s *ConjunctionSearcher) Advance(ID string) (*search.DocumentMatch, error)
 }
 
 func (s *ConjunctionSearcher) Count() uint64 {
+	if s.docs != nil {
+		return s.docs.Count()
+	}
 	// for now return a worst case
 	var sum uint64
 	for _, searcher := range s.searchers {
@@ -183,6 +255,12 @@ func (s *ConjunctionSearcher) Count() uint64 {
 }
 
 func (s *ConjunctionSearcher) Close() error {
+	if s.candidates != nil {
+		err := s.candidates.Close()
+		if err != nil {
+			return err
+		}
+	}
 	for _, searcher := range s.searchers {
 		err := searcher.Close()
 		if err != nil {
@@ -174,6 +174,20 @@ func (s *DisjunctionSearcher) Count() uint64 {
 	return sum
 }
 
+// docSet is the union of the DocSets of the searchers,
+// when at least min of them have to match the documents
+// are not a simple union and there is none
+func (s *DisjunctionSearcher) docSet() (index.DocSet, error) {
+	if s.min > 1 {
+		return nil, nil
+	}
+	docs, all, err := searchersDocSet(s.searchers, unionDocSets)
+	if err != nil || !all {
+		return nil, err
+	}
+	return docs, nil
+}
+
 func (s *DisjunctionSearcher) Close() error {
 	for _, searcher := range s.searchers {
 		err := searcher.Close()
@@ -32,28 +32,45 @@ func NewFuzzySearcher(indexReader index.IndexReader, term string, prefix, fuzzin
 		}
 	}
 
-	// find the terms with this prefix
+	// readers which walk an automaton only go through the
+	// terms within the distance, otherwise those with this
+	// prefix are checked one by one
 	var fieldDict index.FieldDict
 	var err error
-	if len(prefixTerm) > 0 {
+	automatonReader, walkAutomaton := indexReader.(index.AutomatonIndexReader)
+	if walkAutomaton {
+		automaton := search.NewLevenshteinAutomaton(term, prefixTerm, fuzziness)
+		fieldDict, err = automatonReader.FieldDictAutomaton(field, automaton)
+	} else if len(prefixTerm) > 0 {
 		fieldDict, err = indexReader.FieldDictPrefix(field, []byte(prefixTerm))
 	} else {
 		fieldDict, err = indexReader.FieldDict(field)
 	}
+	if err != nil {
+		return nil, err
+	}
 
 	// enumerate terms and check levenshtein distance
 	candidateTerms := make([]string, 0)
 	tfd, err := fieldDict.Next()
 	for err == nil && tfd != nil {
-		ld, exceeded := search.LevenshteinDistanceMax(&term, &tfd.Term, fuzziness)
-		if !exceeded && ld <= fuzziness {
+		if walkAutomaton {
 			candidateTerms = append(candidateTerms, tfd.Term)
+		} else {
+			ld, exceeded := search.LevenshteinDistanceMax(&term, &tfd.Term, fuzziness)
+			if !exceeded && ld <= fuzziness {
+				candidateTerms = append(candidateTerms, tfd.Term)
+			}
 		}
 		tfd, err = fieldDict.Next()
 	}
 	if err != nil {
 		return nil, err
 	}
+	err = fieldDict.Close()
+	if err != nil {
+		return nil, err
+	}
 
 	// enumerate all the terms in the range
 	qsearchers := make([]search.Searcher, 0, 25)
@@ -0,0 +1,122 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package searchers
+
+import (
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/geo"
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search"
+)
+
+// A GeoShapeSearcher finds the documents with a shape in
+// the field in the relation to the shape of the query.
+// The documents in the cells covering the query shape are
+// candidates, their shapes are read from the stored values
+// of the field and checked.  All documents with a shape are
+// candidates for the disjoint relation.
+type GeoShapeSearcher struct {
+	indexReader index.IndexReader
+	field       string
+	shape       *geo.Shape
+	relation    string
+	searcher    search.Searcher
+}
+
+func NewGeoShapeSearcher(indexReader index.IndexReader, shape *geo.Shape, relation string, field string, boost float64, explain bool) (*GeoShapeSearcher, error) {
+	terms := shape.QueryTerms()
+	if relation == geo.Disjoint {
+		terms = []string{geo.AllTerm}
+	}
+	qsearchers := make([]search.Searcher, 0, len(terms))
+	for _, term := range terms {
+		termSearcher, err := NewTermSearcher(indexReader, term, field, boost, explain)
+		if err != nil {
+			for _, qsearcher := range qsearchers {
+				_ = qsearcher.Close()
+			}
+			return nil, err
+		}
+		qsearchers = append(qsearchers, termSearcher)
+	}
+	searcher, err := NewDisjunctionSearcher(indexReader, qsearchers, 1, explain)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoShapeSearcher{
+		indexReader: indexReader,
+		field:       field,
+		shape:       shape,
+		relation:    relation,
+		searcher:    searcher,
+	}, nil
+}
+
+func (s *GeoShapeSearcher) Count() uint64 {
+	return s.searcher.Count()
+}
+
+func (s *GeoShapeSearcher) Weight() float64 {
+	return s.searcher.Weight()
+}
+
+func (s *GeoShapeSearcher) SetQueryNorm(qnorm float64) {
+	s.searcher.SetQueryNorm(qnorm)
+}
+
+func (s *GeoShapeSearcher) Min() int {
+	return 0
+}
+
+func (s *GeoShapeSearcher) Next() (*search.DocumentMatch, error) {
+	return s.check(s.searcher.Next())
+}
+
+func (s *GeoShapeSearcher) Advance(ID string) (*search.DocumentMatch, error) {
+	return s.check(s.searcher.Advance(ID))
+}
+
+// check skips the candidates with a shape which is not in
+// the relation
+func (s *GeoShapeSearcher) check(rv *search.DocumentMatch, err error) (*search.DocumentMatch, error) {
+	for err == nil && rv != nil {
+		var ok bool
+		ok, err = s.matches(rv.ID)
+		if err != nil || ok {
+			break
+		}
+		rv, err = s.searcher.Next()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+func (s *GeoShapeSearcher) matches(id string) (bool, error) {
+	doc, err := s.indexReader.Document(id)
+	if err != nil || doc == nil {
+		return false, err
+	}
+	shape := &geo.Shape{}
+	for _, field := range doc.Fields {
+		if field, ok := field.(*document.TextField); ok && field.Name() == s.field {
+			stored, err := geo.ParseShape(string(field.Value()))
+			if err == nil {
+				shape.Add(stored)
+			}
+		}
+	}
+	return !shape.IsEmpty() && shape.Relate(s.relation, s.shape), nil
+}
+
+func (s *GeoShapeSearcher) Close() error {
+	return s.searcher.Close()
+}
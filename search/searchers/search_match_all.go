@@ -10,6 +10,8 @@
 package searchers
 
 import (
+	"fmt"
+
 	"github.com/blevesearch/bleve/index"
 	"github.com/blevesearch/bleve/search"
 	"github.com/blevesearch/bleve/search/scorers"
@@ -18,6 +20,7 @@ import (
 type MatchAllSearcher struct {
 	indexReader index.IndexReader
 	reader      index.DocIDReader
+	sorted      index.SortedDocIDReader
 	scorer      *scorers.ConstantScorer
 }
 
@@ -46,8 +49,30 @@ func (s *MatchAllSearcher) SetQueryNorm(qnorm float64) {
 	s.scorer.SetQueryNorm(qnorm)
 }
 
+// SortBy makes the searcher return the documents in the
+// order of field, which is only possible when the index
+// is sorted by it
+func (s *MatchAllSearcher) SortBy(field string) (bool, error) {
+	sortedReader, ok := s.indexReader.(index.SortedIndexReader)
+	if !ok || sortedReader.SortField() != field {
+		return false, nil
+	}
+	sorted, err := sortedReader.SortedDocIDReader()
+	if err != nil {
+		return false, err
+	}
+	s.sorted = sorted
+	return true, nil
+}
+
 func (s *MatchAllSearcher) Next() (*search.DocumentMatch, error) {
-	id, err := s.reader.Next()
+	var id string
+	var err error
+	if s.sorted != nil {
+		id, err = s.sorted.Next()
+	} else {
+		id, err = s.reader.Next()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -64,6 +89,9 @@ func (s *MatchAllSearcher) Next() (*search.DocumentMatch, error) {
 }
 
 func (s *MatchAllSearcher) Advance(ID string) (*search.DocumentMatch, error) {
+	if s.sorted != nil {
+		return nil, fmt.Errorf("cannot advance a sorted match all searcher")
+	}
 	id, err := s.reader.Advance(ID)
 	if err != nil {
 		return nil, err
@@ -81,6 +109,12 @@ func (s *MatchAllSearcher) Advance(ID string) (*search.DocumentMatch, error) {
 }
 
 func (s *MatchAllSearcher) Close() error {
+	if s.sorted != nil {
+		err := s.sorted.Close()
+		if err != nil {
+			return err
+		}
+	}
 	return s.reader.Close()
 }
 
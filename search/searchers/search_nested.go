@@ -0,0 +1,193 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package searchers
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/search"
+)
+
+// A NestedSearcher turns the matches of hidden nested
+// documents into matches of the documents they belong to.
+// parent gives the id of the document a nested document
+// belongs to, the nested documents of a document have to
+// follow it in id order.  The score of a document is the
+// average score of its matching nested documents.
+type NestedSearcher struct {
+	searcher search.Searcher
+	parent   func(id string) string
+	explain  bool
+	next     *search.DocumentMatch
+	started  bool
+}
+
+func NewNestedSearcher(searcher search.Searcher, parent func(id string) string, explain bool) *NestedSearcher {
+	return &NestedSearcher{
+		searcher: searcher,
+		parent:   parent,
+		explain:  explain,
+	}
+}
+
+func (s *NestedSearcher) Count() uint64 {
+	return s.searcher.Count()
+}
+
+func (s *NestedSearcher) Weight() float64 {
+	return s.searcher.Weight()
+}
+
+func (s *NestedSearcher) SetQueryNorm(qnorm float64) {
+	s.searcher.SetQueryNorm(qnorm)
+}
+
+func (s *NestedSearcher) Min() int {
+	return 0
+}
+
+func (s *NestedSearcher) Next() (*search.DocumentMatch, error) {
+	if !s.started {
+		var err error
+		s.next, err = s.searcher.Next()
+		if err != nil {
+			return nil, err
+		}
+		s.started = true
+	}
+	return s.group()
+}
+
+func (s *NestedSearcher) Advance(ID string) (*search.DocumentMatch, error) {
+	// the nested documents of ID follow it
+	if !s.started || (s.next != nil && s.next.ID < ID) {
+		var err error
+		s.next, err = s.searcher.Advance(ID)
+		if err != nil {
+			return nil, err
+		}
+		s.started = true
+	}
+	for s.next != nil && s.parent(s.next.ID) < ID {
+		var err error
+		s.next, err = s.searcher.Next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.group()
+}
+
+// group puts together the matches of the nested documents
+// of the next document
+func (s *NestedSearcher) group() (*search.DocumentMatch, error) {
+	if s.next == nil {
+		return nil, nil
+	}
+	rv := &search.DocumentMatch{
+		ID: s.parent(s.next.ID),
+	}
+	var children []*search.Explanation
+	n := 0
+	for s.next != nil && s.parent(s.next.ID) == rv.ID {
+		n++
+		rv.Score += s.next.Score
+		if s.next.Expl != nil {
+			children = append(children, s.next.Expl)
+		}
+		for field, terms := range s.next.Locations {
+			for term, locations := range terms {
+				if rv.Locations == nil {
+					rv.Locations = make(search.FieldTermLocationMap)
+				}
+				if rv.Locations[field] == nil {
+					rv.Locations[field] = make(search.TermLocationMap)
+				}
+				rv.Locations[field][term] = append(rv.Locations[field][term], locations...)
+			}
+		}
+		var err error
+		s.next, err = s.searcher.Next()
+		if err != nil {
+			return nil, err
+		}
+	}
+	rv.Score /= float64(n)
+	if s.explain {
+		rv.Expl = &search.Explanation{
+			Value:    rv.Score,
+			Message:  fmt.Sprintf("average of %d nested documents", n),
+			Children: children,
+		}
+	}
+	return rv, nil
+}
+
+func (s *NestedSearcher) Close() error {
+	return s.searcher.Close()
+}
+
+// An IDFilterSearcher only returns the matches of a
+// searcher with an id which keep accepts, it can be
+// sorted when the searcher can.
+type IDFilterSearcher struct {
+	searcher search.Searcher
+	keep     func(id string) bool
+}
+
+func NewIDFilterSearcher(searcher search.Searcher, keep func(id string) bool) *IDFilterSearcher {
+	return &IDFilterSearcher{
+		searcher: searcher,
+		keep:     keep,
+	}
+}
+
+func (s *IDFilterSearcher) Count() uint64 {
+	return s.searcher.Count()
+}
+
+func (s *IDFilterSearcher) Weight() float64 {
+	return s.searcher.Weight()
+}
+
+func (s *IDFilterSearcher) SetQueryNorm(qnorm float64) {
+	s.searcher.SetQueryNorm(qnorm)
+}
+
+func (s *IDFilterSearcher) Min() int {
+	return s.searcher.Min()
+}
+
+func (s *IDFilterSearcher) SortBy(field string) (bool, error) {
+	sorted, ok := s.searcher.(search.SortedSearcher)
+	if !ok {
+		return false, nil
+	}
+	return sorted.SortBy(field)
+}
+
+func (s *IDFilterSearcher) Next() (*search.DocumentMatch, error) {
+	return s.skip(s.searcher.Next())
+}
+
+func (s *IDFilterSearcher) Advance(ID string) (*search.DocumentMatch, error) {
+	return s.skip(s.searcher.Advance(ID))
+}
+
+func (s *IDFilterSearcher) skip(rv *search.DocumentMatch, err error) (*search.DocumentMatch, error) {
+	for err == nil && rv != nil && !s.keep(rv.ID) {
+		rv, err = s.searcher.Next()
+	}
+	return rv, err
+}
+
+func (s *IDFilterSearcher) Close() error {
+	return s.searcher.Close()
+}
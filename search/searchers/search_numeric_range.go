@@ -13,6 +13,7 @@ import (
 	"bytes"
 	"math"
 
+	"github.com/blevesearch/bleve/document"
 	"github.com/blevesearch/bleve/index"
 	"github.com/blevesearch/bleve/numeric_util"
 	"github.com/blevesearch/bleve/search"
@@ -28,6 +29,15 @@ type NumericRangeSearcher struct {
 }
 
 func NewNumericRangeSearcher(indexReader index.IndexReader, min *float64, max *float64, inclusiveMin, inclusiveMax *bool, field string, boost float64, explain bool) (*NumericRangeSearcher, error) {
+	return NewNumericRangeSearcherWithPrecisionStep(indexReader, min, max, inclusiveMin, inclusiveMax, field, document.DefaultPrecisionStep, boost, explain)
+}
+
+// NewNumericRangeSearcherWithPrecisionStep searches a field
+// indexed with precisionStep.  The range is split into the
+// ranges of terms of each precision, and only the terms of
+// those ranges found in the dictionary of the field are
+// searched, so wide ranges over sparse values stay cheap.
+func NewNumericRangeSearcherWithPrecisionStep(indexReader index.IndexReader, min *float64, max *float64, inclusiveMin, inclusiveMax *bool, field string, precisionStep uint, boost float64, explain bool) (*NumericRangeSearcher, error) {
 	// account for unbounded edges
 	if min == nil {
 		negInf := math.Inf(-1)
@@ -54,15 +64,18 @@ func NewNumericRangeSearcher(indexReader index.IndexReader, min *float64, max *f
 	if !*inclusiveMax && maxInt64 != math.MinInt64 {
 		maxInt64--
 	}
-	// FIXME hard-coded precision, should match field declaration
-	termRanges := splitInt64Range(minInt64, maxInt64, 4)
-	terms := termRanges.Enumerate()
-	// enumerate all the terms in the range
+	termRanges := splitInt64Range(minInt64, maxInt64, precisionStep)
+	terms, err := termRanges.dictionaryTerms(indexReader, field)
+	if err != nil {
+		return nil, err
+	}
 	qsearchers := make([]search.Searcher, len(terms))
 	for i, term := range terms {
-		var err error
-		qsearchers[i], err = NewTermSearcher(indexReader, string(term), field, 1.0, explain)
+		qsearchers[i], err = NewTermSearcher(indexReader, term, field, 1.0, explain)
 		if err != nil {
+			for _, qsearcher := range qsearchers[:i] {
+				_ = qsearcher.Close()
+			}
 			return nil, err
 		}
 	}
@@ -144,6 +157,31 @@ func (tr termRanges) Enumerate() [][]byte {
 	return rv
 }
 
+// dictionaryTerms returns the terms of the ranges which are
+// in the dictionary of the field
+func (tr termRanges) dictionaryTerms(indexReader index.IndexReader, field string) (rv []string, err error) {
+	for _, tri := range tr {
+		var dict index.FieldDict
+		dict, err = indexReader.FieldDictRange(field, tri.startTerm, tri.endTerm)
+		if err != nil {
+			return nil, err
+		}
+		var entry *index.DictEntry
+		entry, err = dict.Next()
+		for err == nil && entry != nil {
+			rv = append(rv, entry.Term)
+			entry, err = dict.Next()
+		}
+		if cerr := dict.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rv, nil
+}
+
 func splitInt64Range(minBound, maxBound int64, precisionStep uint) termRanges {
 	rv := make(termRanges, 0)
 	if minBound > maxBound {
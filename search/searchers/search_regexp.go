@@ -32,18 +32,32 @@ func NewRegexpSearcher(indexReader index.IndexReader, pattern *regexp.Regexp, fi
 		// there is no pattern
 		candidateTerms = append(candidateTerms, prefixTerm)
 	} else {
+		// readers which walk an automaton only go through
+		// the terms matching the pattern, otherwise those with
+		// the prefix are checked one by one
 		var fieldDict index.FieldDict
 		var err error
-		if len(prefixTerm) > 0 {
+		automatonReader, walkAutomaton := indexReader.(index.AutomatonIndexReader)
+		if walkAutomaton {
+			var automaton *search.RegexpAutomaton
+			automaton, err = search.NewRegexpAutomaton(pattern, prefixTerm)
+			if err != nil {
+				return nil, err
+			}
+			fieldDict, err = automatonReader.FieldDictAutomaton(field, automaton)
+		} else if len(prefixTerm) > 0 {
 			fieldDict, err = indexReader.FieldDictPrefix(field, []byte(prefixTerm))
 		} else {
 			fieldDict, err = indexReader.FieldDict(field)
 		}
+		if err != nil {
+			return nil, err
+		}
 
 		// enumerate the terms and check against regexp
 		tfd, err := fieldDict.Next()
 		for err == nil && tfd != nil {
-			if pattern.MatchString(tfd.Term) {
+			if walkAutomaton || pattern.MatchString(tfd.Term) {
 				candidateTerms = append(candidateTerms, tfd.Term)
 			}
 			tfd, err = fieldDict.Next()
@@ -51,6 +65,10 @@ func NewRegexpSearcher(indexReader index.IndexReader, pattern *regexp.Regexp, fi
 		if err != nil {
 			return nil, err
 		}
+		err = fieldDict.Close()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// enumerate all the terms in the range
@@ -24,12 +24,24 @@ type TermSearcher struct {
 	scorer      *scorers.TermQueryScorer
 }
 
+// A SimilarityIndexReader chooses the similarity scoring
+// the terms of each field, nil is the classic tf-idf
+type SimilarityIndexReader interface {
+	Similarity(field string) scorers.Similarity
+}
+
 func NewTermSearcher(indexReader index.IndexReader, term string, field string, boost float64, explain bool) (*TermSearcher, error) {
 	reader, err := indexReader.TermFieldReader([]byte(term), field)
 	if err != nil {
 		return nil, err
 	}
-	scorer := scorers.NewTermQueryScorer(term, field, boost, indexReader.DocCount(), reader.Count(), explain)
+	var similarity scorers.Similarity = scorers.ClassicSimilarity{}
+	if r, ok := indexReader.(SimilarityIndexReader); ok {
+		if s := r.Similarity(field); s != nil {
+			similarity = s
+		}
+	}
+	scorer := scorers.NewTermQueryScorerWithSimilarity(term, field, boost, indexReader.DocCount(), reader.Count(), similarity, explain)
 	return &TermSearcher{
 		indexReader: indexReader,
 		term:        term,
@@ -86,6 +98,13 @@ func (s *TermSearcher) Advance(ID string) (*search.DocumentMatch, error) {
 	return docMatch, nil
 }
 
+func (s *TermSearcher) docSet() (index.DocSet, error) {
+	if r, ok := s.indexReader.(index.DocSetIndexReader); ok {
+		return r.TermDocSet([]byte(s.term), s.field)
+	}
+	return nil, nil
+}
+
 func (s *TermSearcher) Close() error {
 	return s.reader.Close()
 }
@@ -0,0 +1,83 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package searchers
+
+import (
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search"
+)
+
+type TermRangeSearcher struct {
+	indexReader index.IndexReader
+	min         []byte
+	max         []byte
+	field       string
+	explain     bool
+	searcher    *DisjunctionSearcher
+}
+
+// NewTermRangeSearcher searches the terms of the field from
+// min to max, both inclusive, in the order of their bytes
+func NewTermRangeSearcher(indexReader index.IndexReader, min, max []byte, field string, boost float64, explain bool) (*TermRangeSearcher, error) {
+	terms, err := termRanges{{startTerm: min, endTerm: max}}.dictionaryTerms(indexReader, field)
+	if err != nil {
+		return nil, err
+	}
+	qsearchers := make([]search.Searcher, len(terms))
+	for i, term := range terms {
+		qsearchers[i], err = NewTermSearcher(indexReader, term, field, boost, explain)
+		if err != nil {
+			for _, qsearcher := range qsearchers[:i] {
+				_ = qsearcher.Close()
+			}
+			return nil, err
+		}
+	}
+	searcher, err := NewDisjunctionSearcher(indexReader, qsearchers, 0, explain)
+	if err != nil {
+		return nil, err
+	}
+	return &TermRangeSearcher{
+		indexReader: indexReader,
+		min:         min,
+		max:         max,
+		field:       field,
+		explain:     explain,
+		searcher:    searcher,
+	}, nil
+}
+
+func (s *TermRangeSearcher) Count() uint64 {
+	return s.searcher.Count()
+}
+
+func (s *TermRangeSearcher) Weight() float64 {
+	return s.searcher.Weight()
+}
+
+func (s *TermRangeSearcher) SetQueryNorm(qnorm float64) {
+	s.searcher.SetQueryNorm(qnorm)
+}
+
+func (s *TermRangeSearcher) Next() (*search.DocumentMatch, error) {
+	return s.searcher.Next()
+}
+
+func (s *TermRangeSearcher) Advance(ID string) (*search.DocumentMatch, error) {
+	return s.searcher.Advance(ID)
+}
+
+func (s *TermRangeSearcher) Close() error {
+	return s.searcher.Close()
+}
+
+func (s *TermRangeSearcher) Min() int {
+	return 0
+}
@@ -10,6 +10,7 @@
 package bleve
 
 import (
+	"encoding/json"
 	"reflect"
 	"strings"
 	"testing"
@@ -115,3 +116,43 @@ func TestSearchResultMerge(t *testing.T) {
 		t.Errorf("expected %#v, got %#v", expected, l)
 	}
 }
+
+func TestSearchResultJSONSortKey(t *testing.T) {
+	sr := &SearchResult{
+		Total: 2,
+		Hits: search.DocumentMatchCollection{
+			&search.DocumentMatch{
+				ID:      "a",
+				SortKey: []byte("alice"),
+			},
+			&search.DocumentMatch{
+				ID:      "b",
+				SortKey: []byte{},
+			},
+			&search.DocumentMatch{
+				ID: "c",
+			},
+		},
+	}
+	buf, err := json.Marshal(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got SearchResult
+	err = json.Unmarshal(buf, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Hits) != 3 {
+		t.Fatalf("expected 3 hits, got %d", len(got.Hits))
+	}
+	if string(got.Hits[0].SortKey) != "alice" {
+		t.Errorf("expected sort key alice, got %q", got.Hits[0].SortKey)
+	}
+	if got.Hits[1].SortKey == nil || len(got.Hits[1].SortKey) != 0 {
+		t.Errorf("expected empty sort key, got %#v", got.Hits[1].SortKey)
+	}
+	if got.Hits[2].SortKey != nil {
+		t.Errorf("expected nil sort key, got %#v", got.Hits[2].SortKey)
+	}
+}
@@ -0,0 +1,400 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/index"
+)
+
+// Every document indexed gets a new sequence number, so a
+// client can tell whether a document changed since it
+// read it, and only write it if it did not.  The last
+// sequence number given out and the sequence number of
+// each document are internal values, a document which
+// does not exist has sequence number 0.
+//
+// External versions are given by the client instead, a
+// write with a version is rejected unless the version is
+// greater than the one of the last write with a version.
+// The version of a document is kept when it is deleted,
+// so that replaying older writes cannot bring it back.
+// These versions are never purged, an index deleting
+// documents with versions keeps one internal value per
+// deleted document.
+
+// internalPrefix starts the internal keys kept by bleve
+// itself, they cannot be set or deleted by the user
+var internalPrefix = []byte{0}
+
+var seqInternalKey = []byte("\x00seq")
+
+func docSeqInternalKey(id string) []byte {
+	return append([]byte("\x00seq:"), id...)
+}
+
+func docVersionInternalKey(id string) []byte {
+	return append([]byte("\x00version:"), id...)
+}
+
+func isReservedInternalKey(key []byte) bool {
+	return bytes.HasPrefix(key, internalPrefix)
+}
+
+// sequence numbers are reserved this many at a time, the
+// end of the reservation is written before any of them is
+// given out so that none is given out twice after a crash.
+// Close writes the last one given out instead.
+const seqReservation = 1 << 16
+
+// versions are encoded like sequence numbers
+func encodeSeq(seq uint64) []byte {
+	rv := make([]byte, 8)
+	binary.BigEndian.PutUint64(rv, seq)
+	return rv
+}
+
+func decodeSeq(val []byte) (uint64, error) {
+	if val == nil {
+		return 0, nil
+	}
+	if len(val) != 8 {
+		return 0, ErrorSeqCorrupt
+	}
+	return binary.BigEndian.Uint64(val), nil
+}
+
+func readSeq(indexReader index.IndexReader, key []byte) (uint64, error) {
+	val, err := indexReader.GetInternal(key)
+	if err != nil {
+		return 0, err
+	}
+	return decodeSeq(val)
+}
+
+// nextSeqs gives out n sequence numbers and returns the
+// last one given out before them
+func (i *indexImpl) nextSeqs(n uint64) (uint64, error) {
+	i.seqMutex.Lock()
+	defer i.seqMutex.Unlock()
+
+	if i.seq+n > i.seqReserved {
+		reserved := i.seq + n + seqReservation
+		err := i.i.SetInternal(seqInternalKey, encodeSeq(reserved))
+		if err != nil {
+			return 0, err
+		}
+		i.seqReserved = reserved
+	}
+	seq := i.seq
+	i.seq += n
+	return seq, nil
+}
+
+// releaseSeqs writes the last sequence number given out
+// in place of the reservation, it is called on Close
+func (i *indexImpl) releaseSeqs() error {
+	i.seqMutex.Lock()
+	defer i.seqMutex.Unlock()
+
+	if i.seqReserved <= i.seq {
+		return nil
+	}
+	err := i.i.SetInternal(seqInternalKey, encodeSeq(i.seq))
+	if err != nil {
+		return err
+	}
+	i.seqReserved = i.seq
+	return nil
+}
+
+// A VersionConflictError is returned by Index.Batch when
+// operations with external versions were skipped, as the
+// versions were not greater than those of the documents.
+// The other operations of the batch were executed.
+type VersionConflictError struct {
+	// Versions holds the current version of each document
+	// whose operation was skipped
+	Versions map[string]uint64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s, %d operations skipped", ErrorVersionConflict, len(e.Versions))
+}
+
+// executeBatch gives the documents of the batch new
+// sequence numbers and executes it.  With ifSeqs the batch
+// is only executed if the documents have the sequence
+// numbers given, ErrorSeqConflict is returned otherwise.
+// With versions the operations are skipped unless their
+// versions are greater than those of the documents, the
+// rest is executed and a *VersionConflictError returned.
+//
+// Batches with ifSeqs or versions hold writeMutex while
+// they check and write, other batches only share it so
+// that they are executed concurrently.  Of two such
+// batches writing the same document, the one the index
+// executes last keeps its data and its sequence number.
+func (i *indexImpl) executeBatch(b *index.Batch, ifSeqs, versions map[string]uint64) (seqs map[string]uint64, err error) {
+	if i.readOnly {
+		return nil, ErrorIndexReadOnly
+	}
+	for key := range b.InternalOps {
+		if isReservedInternalKey([]byte(key)) {
+			return nil, ErrorInternalKeyReserved
+		}
+	}
+	if i.m.hasNestedMappings() {
+		// the separator would make them nested documents
+		for id := range b.IndexOps {
+			if isNestedDocID(id) {
+				return nil, ErrorNestedSeparatorInID
+			}
+		}
+	}
+
+	var stale map[string]uint64
+	if len(ifSeqs) > 0 || len(versions) > 0 {
+		i.writeMutex.Lock()
+		defer i.writeMutex.Unlock()
+
+		var indexReader index.IndexReader
+		indexReader, err = i.i.Reader()
+		if err != nil {
+			return nil, err
+		}
+		for id, ifSeq := range ifSeqs {
+			var seq uint64
+			seq, err = readSeq(indexReader, docSeqInternalKey(id))
+			if err == nil && seq != ifSeq {
+				err = ErrorSeqConflict
+			}
+			if err != nil {
+				break
+			}
+		}
+		for id, version := range versions {
+			if err != nil {
+				break
+			}
+			var current uint64
+			current, err = readSeq(indexReader, docVersionInternalKey(id))
+			if err == nil && version <= current {
+				if stale == nil {
+					stale = make(map[string]uint64)
+				}
+				stale[id] = current
+			}
+		}
+		if cerr := indexReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		i.writeMutex.RLock()
+		defer i.writeMutex.RUnlock()
+	}
+
+	// the batch of the caller is left as it is
+	ops := b.IndexOps
+	if len(stale) > 0 {
+		ops = make(map[string]*document.Document, len(b.IndexOps))
+		for id, doc := range b.IndexOps {
+			if _, ok := stale[id]; !ok {
+				ops[id] = doc
+			}
+		}
+	}
+	indexOps := ops
+	if i.m.hasNestedMappings() {
+		indexOps, err = i.nestedIndexOps(ops)
+		if err != nil {
+			return nil, err
+		}
+	}
+	batch := &index.Batch{
+		IndexOps:    indexOps,
+		InternalOps: make(map[string][]byte, len(b.InternalOps)+len(ops)+len(versions)),
+	}
+	for key, val := range b.InternalOps {
+		batch.InternalOps[key] = val
+	}
+	for id, version := range versions {
+		if _, ok := stale[id]; !ok {
+			batch.InternalOps[string(docVersionInternalKey(id))] = encodeSeq(version)
+		}
+	}
+	var n uint64
+	for _, doc := range ops {
+		if doc != nil {
+			n++
+		}
+	}
+	seq, err := i.nextSeqs(n)
+	if err != nil {
+		return nil, err
+	}
+	seqs = make(map[string]uint64, len(ops))
+	for id, doc := range ops {
+		if doc == nil {
+			batch.InternalOps[string(docSeqInternalKey(id))] = nil
+			continue
+		}
+		seq++
+		batch.InternalOps[string(docSeqInternalKey(id))] = encodeSeq(seq)
+		seqs[id] = seq
+	}
+
+	if len(batch.IndexOps) > 0 || len(batch.InternalOps) > 0 {
+		err = i.i.Batch(batch)
+		i.fieldLengths.invalidate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(stale) > 0 {
+		return seqs, &VersionConflictError{Versions: stale}
+	}
+	return seqs, nil
+}
+
+// Seq returns the sequence number of the document, 0 if
+// it does not exist
+func (i *indexImpl) Seq(id string) (seq uint64, err error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return 0, ErrorIndexClosed
+	}
+
+	indexReader, err := i.i.Reader()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := indexReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	return readSeq(indexReader, docSeqInternalKey(id))
+}
+
+// IndexIfSeq indexes the data only if the document has
+// sequence number ifSeq, 0 to only index a new document.
+// It returns the new sequence number of the document.
+func (i *indexImpl) IndexIfSeq(id string, data interface{}, ifSeq uint64) (uint64, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return 0, ErrorIndexClosed
+	}
+
+	doc := document.NewDocument(id)
+	err := i.m.mapDocument(doc, data)
+	if err != nil {
+		return 0, err
+	}
+	batch := index.NewBatch()
+	batch.Update(doc)
+	seqs, err := i.executeBatch(batch, map[string]uint64{id: ifSeq}, nil)
+	if err != nil {
+		return 0, err
+	}
+	return seqs[id], nil
+}
+
+// DeleteIfSeq deletes the document only if it has
+// sequence number ifSeq
+func (i *indexImpl) DeleteIfSeq(id string, ifSeq uint64) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	batch := index.NewBatch()
+	batch.Delete(id)
+	_, err := i.executeBatch(batch, map[string]uint64{id: ifSeq}, nil)
+	return err
+}
+
+// Version returns the external version of the document,
+// 0 if it was never written with one
+func (i *indexImpl) Version(id string) (version uint64, err error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return 0, ErrorIndexClosed
+	}
+
+	indexReader, err := i.i.Reader()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := indexReader.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	return readSeq(indexReader, docVersionInternalKey(id))
+}
+
+// IndexVersion indexes the data only if version is greater
+// than the version of the document
+func (i *indexImpl) IndexVersion(id string, data interface{}, version uint64) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	doc := document.NewDocument(id)
+	err := i.m.mapDocument(doc, data)
+	if err != nil {
+		return err
+	}
+	batch := index.NewBatch()
+	batch.Update(doc)
+	_, err = i.executeBatch(batch, nil, map[string]uint64{id: version})
+	if _, ok := err.(*VersionConflictError); ok {
+		return ErrorVersionConflict
+	}
+	return err
+}
+
+// DeleteVersion deletes the document only if version is
+// greater than the version of the document
+func (i *indexImpl) DeleteVersion(id string, version uint64) error {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	if !i.open {
+		return ErrorIndexClosed
+	}
+
+	batch := index.NewBatch()
+	batch.Delete(id)
+	_, err := i.executeBatch(batch, nil, map[string]uint64{id: version})
+	if _, ok := err.(*VersionConflictError); ok {
+		return ErrorVersionConflict
+	}
+	return err
+}
@@ -0,0 +1,221 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/blevesearch/bleve/index"
+	"github.com/blevesearch/bleve/search/scorers"
+)
+
+// similarityIndexReader tells the term searchers the
+// similarity the mapping names for each field, it passes
+// the optional interfaces of the reader it wraps on
+type similarityIndexReader struct {
+	index.IndexReader
+	m *IndexMapping
+
+	// the average field lengths of the state of the index
+	// the reader was opened in
+	lengths    *fieldLengthCache
+	generation uint64
+
+	// the BM25 similarities with the average length of
+	// their fields, found once per reader
+	bm25Mutex sync.Mutex
+	bm25      map[string]scorers.Similarity
+}
+
+// newSimilarityIndexReader wraps indexReader, which was
+// opened after generation of lengths
+func newSimilarityIndexReader(indexReader index.IndexReader, m *IndexMapping, lengths *fieldLengthCache, generation uint64) *similarityIndexReader {
+	return &similarityIndexReader{
+		IndexReader: indexReader,
+		m:           m,
+		lengths:     lengths,
+		generation:  generation,
+	}
+}
+
+// fieldLengthCache keeps the average field lengths found
+// by the readers of an index until the index is changed,
+// so that they are found once and not on every search.
+// Each change starts a new generation.
+type fieldLengthCache struct {
+	mutex      sync.Mutex
+	generation uint64
+	lengths    map[string]float64
+}
+
+// current returns the generation, readers opened after it
+// see the index of that generation or a newer one
+func (c *fieldLengthCache) current() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.generation
+}
+
+// invalidate drops the lengths once the index changed
+func (c *fieldLengthCache) invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	c.lengths = nil
+}
+
+func (c *fieldLengthCache) get(generation uint64, field string) (float64, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation != c.generation {
+		return 0, false
+	}
+	length, ok := c.lengths[field]
+	return length, ok
+}
+
+// put keeps the length found by a reader of generation,
+// unless the index changed since
+func (c *fieldLengthCache) put(generation uint64, field string, length float64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if c.lengths == nil {
+		c.lengths = make(map[string]float64)
+	}
+	c.lengths[field] = length
+}
+
+func (r *similarityIndexReader) Similarity(field string) scorers.Similarity {
+	similarity := r.m.similarityForPath(field)
+	if bm25, ok := similarity.(*scorers.BM25Similarity); ok && bm25.AvgFieldLength == 0 {
+		return r.bm25Similarity(field, bm25)
+	}
+	return similarity
+}
+
+// bm25Similarity returns bm25 with the average length of
+// the field, or bm25 itself when it cannot be found
+func (r *similarityIndexReader) bm25Similarity(field string, bm25 *scorers.BM25Similarity) scorers.Similarity {
+	r.bm25Mutex.Lock()
+	defer r.bm25Mutex.Unlock()
+
+	if similarity, ok := r.bm25[field]; ok {
+		return similarity
+	}
+	var similarity scorers.Similarity = bm25
+	avgFieldLength, ok := r.lengths.get(r.generation, field)
+	if !ok {
+		var err error
+		avgFieldLength, err = r.avgFieldLength(field)
+		if err != nil {
+			logger.Printf("could not find the average length of field '%s': %v", field, err)
+		} else {
+			r.lengths.put(r.generation, field, avgFieldLength)
+		}
+	}
+	if avgFieldLength > 0 {
+		similarity = &scorers.BM25Similarity{
+			K1:             bm25.K1,
+			B:              bm25.B,
+			AvgFieldLength: avgFieldLength,
+		}
+	}
+	if r.bm25 == nil {
+		r.bm25 = make(map[string]scorers.Similarity)
+	}
+	r.bm25[field] = similarity
+	return similarity
+}
+
+// avgFieldLength is the number of terms in the field over
+// the number of documents with the field.  The norm of a
+// field is 1 over the square root of its length, so each
+// document adds up freq times norm squared to 1 over its
+// terms.  It takes a pass over the postings of the field,
+// which is why the lengths are cached.
+func (r *similarityIndexReader) avgFieldLength(field string) (rv float64, err error) {
+	dict, err := r.IndexReader.FieldDict(field)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if cerr := dict.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	var length, docs float64
+	entry, err := dict.Next()
+	for err == nil && entry != nil {
+		var reader index.TermFieldReader
+		reader, err = r.IndexReader.TermFieldReader([]byte(entry.Term), field)
+		if err != nil {
+			return 0, err
+		}
+		var tfd *index.TermFieldDoc
+		tfd, err = reader.Next()
+		for err == nil && tfd != nil {
+			length += float64(tfd.Freq)
+			docs += float64(tfd.Freq) * tfd.Norm * tfd.Norm
+			tfd, err = reader.Next()
+		}
+		if cerr := reader.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return 0, err
+		}
+		entry, err = dict.Next()
+	}
+	if err != nil || docs == 0 {
+		return 0, err
+	}
+	return length / docs, nil
+}
+
+func (r *similarityIndexReader) FieldDictAutomaton(field string, automaton index.Automaton) (index.FieldDict, error) {
+	if automatonReader, ok := r.IndexReader.(index.AutomatonIndexReader); ok {
+		return automatonReader.FieldDictAutomaton(field, automaton)
+	}
+	fieldDict, err := r.IndexReader.FieldDict(field)
+	if err != nil {
+		return nil, err
+	}
+	return &automatonFieldDict{
+		FieldDict: fieldDict,
+		automaton: automaton,
+	}, nil
+}
+
+// TermDocSet returns no set when the reader it wraps
+// keeps none, the searchers fall back to their readers
+func (r *similarityIndexReader) TermDocSet(term []byte, field string) (index.DocSet, error) {
+	if docSetReader, ok := r.IndexReader.(index.DocSetIndexReader); ok {
+		return docSetReader.TermDocSet(term, field)
+	}
+	return nil, nil
+}
+
+func (r *similarityIndexReader) SortField() string {
+	if sortedReader, ok := r.IndexReader.(index.SortedIndexReader); ok {
+		return sortedReader.SortField()
+	}
+	return ""
+}
+
+func (r *similarityIndexReader) SortedDocIDReader() (index.SortedDocIDReader, error) {
+	if sortedReader, ok := r.IndexReader.(index.SortedIndexReader); ok {
+		return sortedReader.SortedDocIDReader()
+	}
+	return nil, fmt.Errorf("index is not sorted")
+}
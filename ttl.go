@@ -0,0 +1,66 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"time"
+
+	"github.com/blevesearch/bleve/index/store/inmem"
+	"github.com/blevesearch/bleve/numeric_util"
+)
+
+// startTTLSweeper deletes the expired documents every
+// Config.TTLSweepInterval when the mapping has a TTLField.
+// The readers of the mem store see its writes as they are
+// made, so a sweep in the background would change the index
+// under the searches, it is not started for that store.
+func (i *indexImpl) startTTLSweeper() {
+	if i.m.TTLField == "" || i.meta.Storage == inmem.Name {
+		return
+	}
+	i.ttlStop = make(chan struct{})
+	i.ttlDone.Add(1)
+	go func() {
+		defer i.ttlDone.Done()
+		ticker := time.NewTicker(Config.TTLSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-i.ttlStop:
+				return
+			case <-ticker.C:
+			}
+			_, err := i.deleteExpired(i.ttlStop)
+			if err != nil && err != ErrorCanceled {
+				logger.Printf("error deleting expired documents: %v", err)
+			}
+		}
+	}()
+}
+
+func (i *indexImpl) stopTTLSweeper() {
+	i.ttlStopOnce.Do(func() {
+		if i.ttlStop != nil {
+			close(i.ttlStop)
+		}
+	})
+	i.ttlDone.Wait()
+}
+
+// deleteExpired deletes the documents with a time in the
+// TTLField which has passed.  They are deleted a batch at a
+// time as the range query finds them, so a sweep costs in
+// proportion to the expired documents and not the index.
+func (i *indexImpl) deleteExpired(cancel <-chan struct{}) (uint64, error) {
+	now := numeric_util.Int64ToFloat64(time.Now().UnixNano())
+	inclusive := true
+	q := NewNumericRangeInclusiveQuery(nil, &now, nil, &inclusive).SetField(i.m.TTLField)
+	return i.DeleteByQuery(q, cancel)
+}
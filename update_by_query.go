@@ -0,0 +1,320 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/document"
+	"github.com/blevesearch/bleve/search"
+)
+
+// An UpdateFunc returns the new data of a document
+// matching an update by query, or nil to leave the
+// document as it is.  fields holds the stored fields of
+// the document as built by DocumentFields, which is all of
+// its data as Index.UpdateByQuery refuses mappings with
+// fields indexed but not stored.
+type UpdateFunc func(id string, fields map[string]interface{}) (interface{}, error)
+
+// An UpdateByQueryRequest describes an update of all
+// documents matching Query.  The documents are updated
+// BatchSize at a time, Progress is called after each
+// batch with the counts so far.
+type UpdateByQueryRequest struct {
+	Query     Query
+	Update    UpdateFunc
+	BatchSize int
+	Progress  func(*UpdateByQueryResult)
+}
+
+// An UpdateByQueryResult counts the documents matching
+// the query, those passed to the update function and
+// those it updated.  The matches are counted as they are
+// found, so Total only counts them all once the update is
+// done.
+type UpdateByQueryResult struct {
+	Total     uint64        `json:"total"`
+	Processed uint64        `json:"processed"`
+	Updated   uint64        `json:"updated"`
+	Batches   uint64        `json:"batches"`
+	Took      time.Duration `json:"took"`
+}
+
+const defaultUpdateByQueryBatchSize = 100
+
+var matchingIDsCount uint64
+
+// matchingIDs calls page with the ids of the documents
+// matching q, up to pageSize at a time.  The documents are
+// found in a point in time of the index, so page can change
+// them without moving the matches still to come.  A nil
+// cancel channel never cancels.
+func matchingIDs(i *indexImpl, q Query, pageSize int, cancel <-chan struct{}, page func(ids []string) error) (err error) {
+	name := fmt.Sprintf("_matching_ids_%d", atomic.AddUint64(&matchingIDsCount, 1))
+	err = i.OpenPointInTime(name)
+	if err != nil {
+		return err
+	}
+	pit, err := i.pointInTime(name)
+	if err != nil {
+		return err
+	}
+
+	var searcher search.Searcher
+	defer func() {
+		pit.mutex.Lock()
+		if searcher != nil && pit.reader != nil {
+			if serr := searcher.Close(); err == nil && serr != nil {
+				err = serr
+			}
+		}
+		pit.mutex.Unlock()
+		if cerr := i.ClosePointInTime(name); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+
+	// next fills ids with the next page of matches, the
+	// point in time is only locked while it does so that
+	// closing the index is not held up
+	ids := make([]string, 0, pageSize)
+	next := func() error {
+		pit.mutex.Lock()
+		defer pit.mutex.Unlock()
+		if pit.reader == nil {
+			return ErrorIndexClosed
+		}
+		if searcher == nil {
+			var err error
+			searcher, err = i.querySearcher(i.searchReader(pit.reader, pit.generation), q, false)
+			if err != nil {
+				return err
+			}
+		}
+		ids = ids[:0]
+		for len(ids) < pageSize {
+			match, err := searcher.Next()
+			if err != nil {
+				return err
+			}
+			if match == nil {
+				break
+			}
+			ids = append(ids, match.ID)
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case <-cancel:
+			return ErrorCanceled
+		default:
+		}
+		err = next()
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		err = page(ids)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// updateByQuery applies the update function of the
+// request to every document matching its query.  The
+// matching documents are found in a point in time of the
+// index, so documents indexed while the update runs are
+// not updated.  Documents deleted while it runs are
+// skipped.
+func updateByQuery(i *indexImpl, req *UpdateByQueryRequest) (rv *UpdateByQueryResult, err error) {
+	start := time.Now()
+	batchSize := req.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultUpdateByQueryBatchSize
+	}
+
+	rv = &UpdateByQueryResult{}
+	err = matchingIDs(i, req.Query, batchSize, nil, func(ids []string) error {
+		rv.Total += uint64(len(ids))
+		batch := i.NewBatch()
+		for _, id := range ids {
+			doc, err := i.Document(id)
+			if err != nil {
+				return err
+			}
+			if doc == nil {
+				continue
+			}
+			rv.Processed++
+			data, err := req.Update(id, DocumentFields(i.m, doc))
+			if err != nil {
+				return err
+			}
+			if data == nil {
+				continue
+			}
+			err = batch.Index(id, data)
+			if err != nil {
+				return err
+			}
+		}
+		if batch.Size() > 0 {
+			err := i.Batch(batch)
+			if err != nil {
+				return err
+			}
+			rv.Updated += uint64(batch.Size())
+		}
+		rv.Batches++
+		rv.Took = time.Since(start)
+		if req.Progress != nil {
+			req.Progress(rv)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	rv.Took = time.Since(start)
+	return rv, nil
+}
+
+// DocumentFields rebuilds the data of a document indexed
+// with mapping m from its stored fields.  Fields with a
+// path are put in nested maps and fields with several
+// values in arrays, fields which were not stored are
+// missing.  Sub-fields and copy_to targets are left out,
+// as indexing the document again fills them from their
+// source fields.  The Nested documents are put back as the
+// elements of their sections, in order.  Values come back
+// as they were stored, so flattened objects and shapes are
+// their JSON.
+func DocumentFields(m *IndexMapping, doc *document.Document) map[string]interface{} {
+	rv := make(map[string]interface{})
+	derived := m.derivedFields(doc)
+	for _, field := range doc.Fields {
+		if derived[field.Name()] {
+			continue
+		}
+		var value interface{}
+		switch field := field.(type) {
+		case *document.TextField:
+			value = string(field.Value())
+		case *document.NumericField:
+			n, err := field.Number()
+			if err == nil {
+				value = n
+			}
+		case *document.DateTimeField:
+			d, err := field.DateTime()
+			if err == nil {
+				value = d
+			}
+		}
+		if value == nil {
+			continue
+		}
+
+		path := decodePath(field.Name())
+		m := parentMap(rv, path)
+		name := path[len(path)-1]
+		switch existing := m[name].(type) {
+		case nil:
+			m[name] = value
+		case []interface{}:
+			m[name] = append(existing, value)
+		default:
+			m[name] = []interface{}{existing, value}
+		}
+	}
+
+	sections := make(map[string]map[int]interface{})
+	for _, nested := range doc.Nested {
+		path, i, ok := nestedElement(nested.ID)
+		if !ok {
+			continue
+		}
+		value := valueAtPath(DocumentFields(m, nested), decodePath(path))
+		if value == nil {
+			continue
+		}
+		if sections[path] == nil {
+			sections[path] = make(map[int]interface{})
+		}
+		sections[path][i] = value
+	}
+	for path, values := range sections {
+		positions := make([]int, 0, len(values))
+		for i := range values {
+			positions = append(positions, i)
+		}
+		sort.Ints(positions)
+		elements := make([]interface{}, len(positions))
+		for j, i := range positions {
+			elements[j] = values[i]
+		}
+		// the elements replace the values included in the
+		// document with IncludeInParent
+		elementPath := decodePath(path)
+		parentMap(rv, elementPath)[elementPath[len(elementPath)-1]] = elements
+	}
+	return rv
+}
+
+// valueAtPath returns the value at path in nested maps, or
+// nil
+func valueAtPath(m map[string]interface{}, path []string) interface{} {
+	var rv interface{} = m
+	for _, name := range path {
+		m, ok := rv.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		rv = m[name]
+	}
+	return rv
+}
+
+// PatchFields returns an UpdateFunc setting the fields of
+// patch in every document, fields with a path are set in
+// nested maps.  The other fields of the documents keep
+// their stored values.
+func PatchFields(patch map[string]interface{}) UpdateFunc {
+	return func(id string, fields map[string]interface{}) (interface{}, error) {
+		for key, value := range patch {
+			path := decodePath(key)
+			parentMap(fields, path)[path[len(path)-1]] = value
+		}
+		return fields, nil
+	}
+}
+
+// parentMap returns the map holding the last element of
+// path, the maps on the way are created where missing
+func parentMap(m map[string]interface{}, path []string) map[string]interface{} {
+	for _, name := range path[:len(path)-1] {
+		nested, ok := m[name].(map[string]interface{})
+		if !ok {
+			nested = make(map[string]interface{})
+			m[name] = nested
+		}
+		m = nested
+	}
+	return m
+}
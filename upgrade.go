@@ -0,0 +1,83 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package bleve
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func upgradeIndex(path string) error {
+	i, err := openIndexUsing(path, map[string]interface{}{
+		"upgrade": true,
+	})
+	if err != nil {
+		return err
+	}
+	return i.Close()
+}
+
+func upgradeIndexCopy(path, newPath string) (err error) {
+	if _, err = os.Stat(path); os.IsNotExist(err) {
+		return ErrorIndexPathDoesNotExist
+	}
+	if _, err = os.Stat(newPath); err == nil {
+		return ErrorIndexPathExists
+	}
+	err = copyDir(path, newPath)
+	if err == nil {
+		err = upgradeIndex(newPath)
+	}
+	if err != nil {
+		_ = os.RemoveAll(newPath)
+	}
+	return err
+}
+
+// copyDir copies the files below path to newPath
+func copyDir(path, newPath string) error {
+	return filepath.Walk(path, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(newPath, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+		return copyFile(name, target, info.Mode())
+	})
+}
+
+func copyFile(name, target string, mode os.FileMode) (err error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	return err
+}
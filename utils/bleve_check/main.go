@@ -0,0 +1,64 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blevesearch/bleve"
+	_ "github.com/blevesearch/bleve/config"
+)
+
+var indexPath = flag.String("index", "", "index path")
+var repair = flag.Bool("repair", false, "repair the problems which can be repaired")
+
+func main() {
+	flag.Parse()
+	if *indexPath == "" {
+		log.Fatal("specify index to check")
+	}
+
+	// without repair the index is only read, so that it can
+	// be checked while other processes read it
+	var index bleve.Index
+	var err error
+	if *repair {
+		index, err = bleve.Open(*indexPath)
+	} else {
+		index, err = bleve.OpenReadOnly(*indexPath)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result, err := bleve.CheckIndex(index, *repair)
+	if cerr := index.Close(); err == nil && cerr != nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, problem := range result.Problems {
+		status := ""
+		if problem.Repaired {
+			status = " (repaired)"
+		}
+		fmt.Printf("%s: %s%s\n", problem.Kind, problem.Description, status)
+	}
+	unrepaired := result.Unrepaired()
+	fmt.Printf("%d problems found, %d repaired\n", len(result.Problems), len(result.Problems)-unrepaired)
+	if unrepaired > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/blevesearch/bleve"
+	_ "github.com/blevesearch/bleve/config"
+)
+
+var indexPath = flag.String("index", "", "index path")
+var copyPath = flag.String("copy", "", "upgrade a copy at this path, leaving the index as it is")
+
+func main() {
+	flag.Parse()
+	if *indexPath == "" {
+		log.Fatal("specify index to upgrade")
+	}
+
+	var err error
+	if *copyPath != "" {
+		err = bleve.UpgradeCopy(*indexPath, *copyPath)
+	} else {
+		err = bleve.Upgrade(*indexPath)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}